@@ -0,0 +1,52 @@
+/*
+ * 文件作用：账户凭证加密迁移工具，把存量明文凭证加密、把旧密钥加密的凭证用当前密钥重新加密
+ * 负责功能：
+ *   - 读取配置（或环境变量）中的当前密钥和旧密钥
+ *   - 批量检查并重新加密 APIKey、APIKeys、AccessToken、RefreshToken、SessionKey
+ *   - 支持只统计不写库（-dry-run）
+ *   - 存在无法解密的账户时以非 0 状态码退出
+ * 重要程度：⭐⭐ 辅助（运维迁移）
+ * 依赖模块：config, repository
+ */
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"go-aiproxy/internal/config"
+	"go-aiproxy/internal/repository"
+)
+
+func main() {
+	configPath := flag.String("config", "configs/config.yaml", "配置文件路径")
+	dryRun := flag.Bool("dry-run", false, "只统计需要加密的账户，不写库")
+	flag.Parse()
+
+	if err := config.Load(*configPath); err != nil {
+		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+		os.Exit(2)
+	}
+	if err := repository.InitMySQL(); err != nil {
+		fmt.Fprintf(os.Stderr, "MySQL 连接失败: %v\n", err)
+		os.Exit(2)
+	}
+
+	result, err := repository.EncryptAccountCredentials(*dryRun)
+	repository.CloseMySQL()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "凭证加密失败: %v\n", err)
+		os.Exit(2)
+	}
+
+	action := "已加密"
+	if *dryRun {
+		action = "待加密"
+	}
+	fmt.Printf("账户数: %d | %s: %d | 无法解密: %d\n", result.Total, action, result.Updated, len(result.Failed))
+	if len(result.Failed) > 0 {
+		fmt.Printf("无法解密的账户 ID（检查 old_credential_keys 是否包含加密时使用的密钥）: %v\n", result.Failed)
+		os.Exit(1)
+	}
+}
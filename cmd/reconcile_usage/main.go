@@ -0,0 +1,74 @@
+/*
+ * 文件作用：每日用量对账工具，比对 daily_usage 汇总与 request_logs 明细
+ * 负责功能：
+ *   - 按用户+模型比对请求数、Token、费用
+ *   - 输出存在差异的行及合计
+ *   - 存在差异时以非 0 状态码退出（便于定时任务告警）
+ * 重要程度：⭐⭐ 辅助（运维对账）
+ * 依赖模块：config, repository
+ */
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"go-aiproxy/internal/config"
+	"go-aiproxy/internal/repository"
+)
+
+func main() {
+	configPath := flag.String("config", "configs/config.yaml", "配置文件路径")
+	date := flag.String("date", time.Now().AddDate(0, 0, -1).Format("2006-01-02"), "对账日期 YYYY-MM-DD（默认昨天）")
+	showAll := flag.Bool("all", false, "输出所有行（默认只输出有差异的行）")
+	flag.Parse()
+
+	if err := config.Load(*configPath); err != nil {
+		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+		os.Exit(2)
+	}
+	if err := repository.InitMySQL(); err != nil {
+		fmt.Fprintf(os.Stderr, "MySQL 连接失败: %v\n", err)
+		os.Exit(2)
+	}
+
+	diffs, err := repository.NewDailyUsageRepository().Reconcile(*date)
+	repository.CloseMySQL()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "对账失败: %v\n", err)
+		os.Exit(2)
+	}
+
+	fmt.Printf("对账日期: %s（daily_usage 汇总 vs request_logs 明细）\n", *date)
+	fmt.Printf("%-8s %-40s %12s %12s %14s %14s %14s %14s\n",
+		"用户", "模型", "汇总请求", "明细请求", "汇总Token", "明细Token", "汇总费用", "明细费用")
+
+	var diffCount int
+	var summaryCost, logCost float64
+	var summaryRequests, logRequests int64
+	for i := range diffs {
+		d := &diffs[i]
+		summaryCost += d.SummaryCost
+		logCost += d.LogCost
+		summaryRequests += d.SummaryRequests
+		logRequests += d.LogRequests
+
+		hasDiff := d.HasDiff()
+		if hasDiff {
+			diffCount++
+		}
+		if hasDiff || *showAll {
+			fmt.Printf("%-8d %-40s %12d %12d %14d %14d %14.6f %14.6f\n",
+				d.UserID, d.Model, d.SummaryRequests, d.LogRequests, d.SummaryTokens, d.LogTokens, d.SummaryCost, d.LogCost)
+		}
+	}
+
+	fmt.Printf("\n合计 | 行数: %d | 差异行: %d | 请求: %d / %d | 费用: %.6f / %.6f\n",
+		len(diffs), diffCount, summaryRequests, logRequests, summaryCost, logCost)
+
+	if diffCount > 0 {
+		os.Exit(1)
+	}
+}
@@ -4,8 +4,8 @@
  *   - 加载配置文件和环境变量
  *   - 初始化MySQL数据库连接和自动迁移
  *   - 注册路由和中间件
- *   - 启动健康检查服务
- *   - 优雅关闭服务（信号处理）
+ *   - 启动健康检查服务和账号预热探测
+ *   - 优雅关闭服务（信号处理，等待进行中的流式请求 drain）
  * 重要程度：⭐⭐⭐⭐⭐ 核心（程序启动入口）
  * 依赖模块：config, handler, middleware, repository, service
  */
@@ -24,10 +24,12 @@ import (
 	"syscall"
 	"time"
 
+	"go-aiproxy/internal/alert"
 	"go-aiproxy/internal/config"
 	"go-aiproxy/internal/handler"
 	"go-aiproxy/internal/middleware"
 	"go-aiproxy/internal/model"
+	"go-aiproxy/internal/proxy/scheduler"
 	"go-aiproxy/internal/repository"
 	"go-aiproxy/internal/service"
 	"go-aiproxy/pkg/logger"
@@ -148,31 +150,98 @@ func main() {
 			configService.GetAccountErrorThreshold())
 	}
 
+	// 请求重试配置
+	retryConfig := configService.GetRetryConfig()
+	scheduler.SetRetryConfig(retryConfig)
+	log.Info("请求重试配置 | 最大重试: %d | 延迟: %v | 退避: %.2f", retryConfig.MaxRetries, retryConfig.RetryDelay, retryConfig.RetryBackoff)
+
+	// 恢复账户冷却期配置
+	scheduler.SetWarmupConfig(configService.GetWarmupConfig())
+
+	// 账户成功率熔断配置
+	scheduler.SetSuccessRateConfig(configService.GetSuccessRateConfig())
+
 	// 设置配置变更回调
 	handler.SetConfigChangeCallback(func(key, value string) {
-		switch key {
-		case model.ConfigSessionTTL:
+		switch {
+		case key == model.ConfigSessionTTL:
 			log.Info("会话 TTL 配置已更新: %s", value)
-		case model.ConfigAccountHealthCheckEnabled, model.ConfigAccountHealthCheckInterval:
+		case key == model.ConfigAccountHealthCheckEnabled, key == model.ConfigAccountHealthCheckInterval:
 			healthCheckService.OnConfigChange(key, value)
+		case service.IsRetryConfigKey(key):
+			retryConfig := configService.GetRetryConfig()
+			scheduler.SetRetryConfig(retryConfig)
+			log.Info("请求重试配置已更新 | 最大重试: %d | 延迟: %v | 退避: %.2f", retryConfig.MaxRetries, retryConfig.RetryDelay, retryConfig.RetryBackoff)
+		case service.IsWarmupConfigKey(key):
+			warmupConfig := configService.GetWarmupConfig()
+			scheduler.SetWarmupConfig(warmupConfig)
+			log.Info("恢复账户冷却期配置已更新 | 时长: %v | 初始比例: %.0f%% | 连续成功放开: %d",
+				warmupConfig.Duration, warmupConfig.InitialRatio*100, warmupConfig.SuccessRelease)
+		case service.IsSuccessRateConfigKey(key):
+			successRateConfig := configService.GetSuccessRateConfig()
+			scheduler.SetSuccessRateConfig(successRateConfig)
+			log.Info("账户成功率熔断配置已更新 | 窗口: %d | 阈值: %.0f%% | 熔断: %v",
+				successRateConfig.WindowSize, successRateConfig.Threshold*100, successRateConfig.Cooldown)
 		}
 	})
 
 	// JWT 配置
 	log.Info("JWT 配置 | 密钥: %s | 过期: %d小时", maskJWTSecret(config.Cfg.JWT.Secret), config.Cfg.JWT.ExpireHours)
 
+	// 监控指标配置
+	if config.Cfg.Metrics.Enabled {
+		if config.Cfg.Metrics.Token == "" {
+			log.Warn("Prometheus 指标已开启 (/metrics)，但未配置 metrics.token，端点无需认证即可访问")
+		} else {
+			log.Info("Prometheus 指标已开启 (/metrics)，需使用 metrics.token 认证")
+		}
+	}
+
+	// 请求日志转发配置
+	if service.GetLogForwarder() != nil {
+		cfg := config.Cfg.LogForward
+		log.Info("请求日志转发已开启 | URL: %s | 队列: %d | 协程: %d | 重试: %d",
+			cfg.URL, cfg.GetQueueSize(), cfg.GetWorkers(), cfg.GetMaxRetries())
+	} else if config.Cfg.LogForward.Enabled {
+		log.Warn("请求日志转发已开启但未配置 log_forward.url，转发不会生效")
+	}
+
+	// 计费 webhook 配置（启动时即开始推送积压事件）
+	if service.GetBillingWebhook() != nil {
+		cfg := config.Cfg.BillingWebhook
+		log.Info("计费 webhook 已开启 | URL: %s | 签名: %v | 协程: %d | 重试扫描: %v",
+			cfg.URL, cfg.Secret != "", cfg.GetWorkers(), cfg.GetRetryInterval())
+	} else if config.Cfg.BillingWebhook.Enabled {
+		log.Warn("计费 webhook 已开启但未配置 billing_webhook.url，推送不会生效")
+	}
+
+	// 账户告警配置
+	if alert.Enabled() {
+		cfg := config.Cfg.Alert
+		log.Info("账户告警已开启 | 格式: %s | 去抖: %d 分钟", cfg.GetFormat(), cfg.GetDebounce())
+	} else if config.Cfg.Alert.Enabled {
+		log.Warn("账户告警已开启但未配置 alert.url，告警不会生效")
+	}
+
 	// 设置 Gin 为 release 模式，避免debug日志输出到控制台
 	gin.SetMode(gin.ReleaseMode)
 
 	// 创建路由
 	r := gin.New()
 
+	// 可信代理：只有来自可信代理的请求才会使用 X-Forwarded-For 解析客户端 IP（未配置时只信任本机和内网地址）
+	if err := r.SetTrustedProxies(config.Cfg.Server.GetTrustedProxies()); err != nil {
+		log.Error("可信代理配置无效，改用默认值（本机和内网地址）: %v", err)
+		r.SetTrustedProxies(config.DefaultTrustedProxies())
+	}
+
 	// 基础中间件
 	r.Use(middleware.Logger())
+	r.Use(middleware.RequestBodyLimit()) // 必须在读取请求体的中间件之前
 	r.Use(middleware.Recovery())
 	r.Use(middleware.CORS())
-	// 启用 Gzip 压缩（API 响应为主；静态资源使用预压缩 .gz 直出，避免 chunked 断流）
-	r.Use(gzip.Gzip(gzip.DefaultCompression, gzip.WithExcludedPaths([]string{"/v1", "/assets"})))
+	// 启用 Gzip 压缩（API 响应为主；静态资源使用预压缩 .gz 直出，避免 chunked 断流；实时请求流为 SSE，不压缩）
+	r.Use(gzip.Gzip(gzip.DefaultCompression, gzip.WithExcludedPaths([]string{"/v1", "/assets", "/api/admin/live"})))
 
 	// 注册路由
 	handler.RegisterRoutes(r)
@@ -209,6 +278,9 @@ func main() {
 		}
 	}()
 
+	// 后台预热：探测各账号就绪情况，让第一批请求命中健康账号
+	healthCheckService.RunStartupProbe()
+
 	// 设置信号监听
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
@@ -228,15 +300,30 @@ func main() {
 		log.Info("健康检查服务已停止")
 	}
 
-	// 创建超时上下文
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	// 创建超时上下文（drain 窗口，流式请求可能持续较久）
+	drainTimeout := config.Cfg.Server.GetShutdownDrainTimeout()
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
 	defer cancel()
 
-	// 优雅关闭 HTTP 服务器
-	if err := srv.Shutdown(ctx); err != nil {
+	// 优雅关闭 HTTP 服务器：立即停止接受新请求，并等待进行中的请求完成
+	log.Info("等待进行中的流式请求完成 | 数量: %d | 最长等待: %v", handler.ActiveStreamCount(), drainTimeout)
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- srv.Shutdown(ctx)
+	}()
+	if handler.WaitActiveStreams(ctx) {
+		log.Info("流式请求已全部完成")
+	} else {
+		log.Warn("drain 窗口超时，强制关闭剩余流式请求 | 数量: %d", handler.ActiveStreamCount())
+	}
+	if err := <-shutdownErr; err != nil {
 		log.Error("服务关闭出错: %v", err)
+		srv.Close()
 	}
 
+	// 写入内存中尚未落库的小时用量
+	service.GetHourlyUsageAggregator().Flush()
+
 	// 关闭数据库连接
 	if err := repository.CloseMySQL(); err != nil {
 		log.Error("关闭 MySQL 连接出错: %v", err)
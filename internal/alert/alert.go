@@ -0,0 +1,260 @@
+/*
+ * 文件作用：账户告警通知，账户被封/限流等关键状态变化时推送 webhook
+ * 负责功能：
+ *   - 告警开关与 webhook 配置（见 config.AlertConfig）
+ *   - Slack / 钉钉 / 飞书 / 通用 JSON 消息格式
+ *   - 同一账户去抖（去抖时间内只告警一次）
+ *   - 凭证即将过期 / 已过期 / SessionKey 失效告警
+ *   - 独立协程异步发送，不阻塞健康检查和代理请求
+ * 重要程度：⭐⭐⭐ 一般（运维告警）
+ * 依赖模块：config, model, logger
+ */
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"go-aiproxy/internal/config"
+	"go-aiproxy/internal/model"
+	"go-aiproxy/pkg/logger"
+)
+
+// AccountAlert 账户状态告警内容
+type AccountAlert struct {
+	AccountID   uint      `json:"account_id"`
+	AccountName string    `json:"account_name"`
+	Platform    string    `json:"platform"`
+	OldStatus   string    `json:"old_status"`
+	NewStatus   string    `json:"new_status"`
+	Error       string    `json:"error"`
+	Time        time.Time `json:"time"`
+}
+
+// alertStatuses 需要告警的目标状态
+var alertStatuses = map[string]bool{
+	model.AccountStatusRateLimited: true,
+	model.AccountStatusSuspended:   true,
+	model.AccountStatusBanned:      true,
+}
+
+// 凭证告警类型（作为告警的 NewStatus 发送，不是账户真实状态）
+const (
+	CredentialExpiring          = "credential_expiring"
+	CredentialExpired           = "credential_expired"
+	CredentialSessionKeyInvalid = "session_key_invalid"
+)
+
+// statusLabels 状态中文名（用于消息文本）
+var statusLabels = map[string]string{
+	model.AccountStatusValid:          "正常",
+	model.AccountStatusInvalid:        "失效",
+	model.AccountStatusRateLimited:    "限流",
+	model.AccountStatusSuspended:      "疑似封号",
+	model.AccountStatusBanned:         "确认封号",
+	model.AccountStatusTokenExpired:   "Token 过期",
+	model.AccountStatusCostLimited:    "费用超限",
+	model.AccountStatusRequestLimited: "请求数超限",
+	CredentialExpiring:                "凭证即将过期",
+	CredentialExpired:                 "凭证已过期",
+	CredentialSessionKeyInvalid:       "SessionKey 失效",
+}
+
+// Notifier 告警发送器
+type Notifier struct {
+	cfg    config.AlertConfig
+	queue  chan *AccountAlert
+	client *http.Client
+	log    *logger.Logger
+
+	mu       sync.Mutex
+	lastSent map[uint]time.Time // accountID -> 上次告警时间
+}
+
+var (
+	notifier     *Notifier
+	notifierOnce sync.Once
+)
+
+// GetNotifier 获取告警发送器单例，未启用时返回 nil
+func GetNotifier() *Notifier {
+	notifierOnce.Do(func() {
+		if config.Cfg == nil || !config.Cfg.Alert.Enabled || config.Cfg.Alert.URL == "" {
+			return
+		}
+		cfg := config.Cfg.Alert
+		notifier = &Notifier{
+			cfg:      cfg,
+			queue:    make(chan *AccountAlert, 100),
+			client:   &http.Client{Timeout: time.Duration(cfg.GetTimeout()) * time.Second},
+			log:      logger.GetLogger("alert"),
+			lastSent: make(map[uint]time.Time),
+		}
+		go notifier.worker()
+	})
+	return notifier
+}
+
+// Enabled 告警是否启用（调用方可据此跳过准备告警数据的开销）
+func Enabled() bool {
+	return GetNotifier() != nil
+}
+
+// ShouldAlert 判断状态变化是否需要告警
+func ShouldAlert(oldStatus, newStatus string) bool {
+	return alertStatuses[newStatus] && oldStatus != newStatus
+}
+
+// NotifyAccountStatus 账户状态变化时提交告警（非阻塞）
+// account 为状态变化前的账户信息
+func NotifyAccountStatus(account *model.Account, newStatus, errMsg string) {
+	n := GetNotifier()
+	if n == nil || account == nil || !ShouldAlert(account.Status, newStatus) {
+		return
+	}
+	if !n.allow(account.ID) {
+		return
+	}
+
+	if len(errMsg) > 300 {
+		errMsg = errMsg[:300] + "..."
+	}
+	entry := &AccountAlert{
+		AccountID:   account.ID,
+		AccountName: account.Name,
+		Platform:    account.Platform,
+		OldStatus:   account.Status,
+		NewStatus:   newStatus,
+		Error:       errMsg,
+		Time:        time.Now(),
+	}
+
+	select {
+	case n.queue <- entry:
+	default:
+		n.log.Warn("告警队列已满，丢弃告警 | AccountID: %d | NewStatus: %s", account.ID, newStatus)
+	}
+}
+
+// NotifyCredential 凭证即将过期或失效时提交告警（非阻塞）
+// 不走状态去抖（避免压掉随后的封号告警），由调用方保证同一凭证只告警一次
+func NotifyCredential(account *model.Account, kind, detail string) {
+	n := GetNotifier()
+	if n == nil || account == nil {
+		return
+	}
+
+	entry := &AccountAlert{
+		AccountID:   account.ID,
+		AccountName: account.Name,
+		Platform:    account.Platform,
+		OldStatus:   account.Status,
+		NewStatus:   kind,
+		Error:       detail,
+		Time:        time.Now(),
+	}
+
+	select {
+	case n.queue <- entry:
+	default:
+		n.log.Warn("告警队列已满，丢弃告警 | AccountID: %d | Kind: %s", account.ID, kind)
+	}
+}
+
+// allow 去抖：同一账户在去抖时间内只告警一次
+func (n *Notifier) allow(accountID uint) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	debounce := time.Duration(n.cfg.GetDebounce()) * time.Minute
+	now := time.Now()
+	if last, ok := n.lastSent[accountID]; ok && now.Sub(last) < debounce {
+		return false
+	}
+	n.lastSent[accountID] = now
+
+	// 顺带清理过期记录，避免 map 无限增长
+	for id, last := range n.lastSent {
+		if now.Sub(last) >= debounce {
+			delete(n.lastSent, id)
+		}
+	}
+	return true
+}
+
+// worker 从队列取告警并发送
+func (n *Notifier) worker() {
+	for entry := range n.queue {
+		if err := n.send(entry); err != nil {
+			n.log.Error("发送账户告警失败 | AccountID: %d | Error: %v", entry.AccountID, err)
+		} else {
+			n.log.Info("已发送账户告警 | AccountID: %d | %s -> %s", entry.AccountID, entry.OldStatus, entry.NewStatus)
+		}
+	}
+}
+
+// send 按配置格式发送一条告警
+func (n *Notifier) send(entry *AccountAlert) error {
+	body, err := json.Marshal(n.buildPayload(entry))
+	if err != nil {
+		return err
+	}
+
+	resp, err := n.client.Post(n.cfg.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// buildPayload 构建各平台的消息体
+func (n *Notifier) buildPayload(entry *AccountAlert) interface{} {
+	text := formatText(entry)
+	switch n.cfg.GetFormat() {
+	case "slack":
+		return map[string]interface{}{"text": text}
+	case "dingtalk":
+		return map[string]interface{}{
+			"msgtype": "text",
+			"text":    map[string]string{"content": text},
+		}
+	case "feishu":
+		return map[string]interface{}{
+			"msg_type": "text",
+			"content":  map[string]string{"text": text},
+		}
+	default:
+		return entry
+	}
+}
+
+// formatText 生成告警文本
+func formatText(entry *AccountAlert) string {
+	text := fmt.Sprintf("[账户告警] %s (ID: %d, 平台: %s)\n状态: %s -> %s\n时间: %s",
+		entry.AccountName, entry.AccountID, entry.Platform,
+		statusLabel(entry.OldStatus), statusLabel(entry.NewStatus),
+		entry.Time.Format("2006-01-02 15:04:05"))
+	if entry.Error != "" {
+		text += "\n错误: " + entry.Error
+	}
+	return text
+}
+
+// statusLabel 获取状态中文名
+func statusLabel(status string) string {
+	if label, ok := statusLabels[status]; ok {
+		return label
+	}
+	return status
+}
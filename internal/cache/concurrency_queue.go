@@ -0,0 +1,164 @@
+/*
+ * 文件作用：账户并发槽位优先级等待队列
+ * 负责功能：
+ *   - 账户并发已满时请求按优先级排队等待槽位
+ *   - 槽位释放时按优先级（同优先级先到先得）移交给等待者
+ *   - 最大等待时间与客户端断开时退出队列
+ *   - 队列与并发计数一样只在当前进程内，优先级只在同一实例的请求之间生效
+ * 重要程度：⭐⭐⭐ 一般（付费用户优先调度）
+ * 依赖模块：无
+ */
+package cache
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// concurrencyQueuePollInterval 等待期间定期派发的间隔
+// 槽位也可能因 TTL 过期而不是 Release 释放，需要主动检查
+const concurrencyQueuePollInterval = 500 * time.Millisecond
+
+// concurrencyWaiter 排队中的请求
+type concurrencyWaiter struct {
+	priority int
+	seq      uint64
+	limit    int
+	granted  bool          // 已被分配槽位（持有队列锁时读写）
+	ready    chan struct{} // 分配槽位后关闭
+}
+
+// accountWaitQueue 单个账户的等待队列，按优先级降序、入队顺序升序排列
+type accountWaitQueue struct {
+	mu      sync.Mutex
+	waiters []*concurrencyWaiter
+}
+
+// insertLocked 按优先级插入等待者（需要持有锁）
+func (q *accountWaitQueue) insertLocked(w *concurrencyWaiter) {
+	i := sort.Search(len(q.waiters), func(i int) bool {
+		return q.waiters[i].priority < w.priority
+	})
+	q.waiters = append(q.waiters, nil)
+	copy(q.waiters[i+1:], q.waiters[i:])
+	q.waiters[i] = w
+}
+
+// removeLocked 移除等待者（需要持有锁）
+func (q *accountWaitQueue) removeLocked(w *concurrencyWaiter) {
+	for i, item := range q.waiters {
+		if item == w {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// canCutInLocked 新请求是否可以不排队直接抢槽位：队列为空或优先级高于队首（需要持有锁）
+func (q *accountWaitQueue) canCutInLocked(priority int) bool {
+	return len(q.waiters) == 0 || priority > q.waiters[0].priority
+}
+
+// concurrencyWaitSeq 入队序号，保证同优先级先到先得
+var concurrencyWaitSeq atomic.Uint64
+
+// getOrCreateWaitQueue 获取或创建账户等待队列
+func (m *ConcurrencyManager) getOrCreateWaitQueue(accountID uint) *accountWaitQueue {
+	val, _ := m.accountWaitQueues.LoadOrStore(accountID, &accountWaitQueue{})
+	return val.(*accountWaitQueue)
+}
+
+// AcquireAccountWithPriority 获取账户并发槽位，已满时按优先级排队
+// maxWait <= 0 时不排队：队列中有同等或更高优先级的等待者时直接返回失败，避免插队
+func (m *ConcurrencyManager) AcquireAccountWithPriority(ctx context.Context, accountID uint, limit, priority int, maxWait time.Duration) (bool, int64) {
+	counter := m.getOrCreateAccountCounter(accountID)
+	ttl := getConcurrencyTTL()
+	q := m.getOrCreateWaitQueue(accountID)
+
+	q.mu.Lock()
+	if q.canCutInLocked(priority) {
+		if acquired, count := counter.Acquire(limit, ttl); acquired {
+			q.mu.Unlock()
+			return true, int64(count)
+		}
+	}
+	if maxWait <= 0 {
+		q.mu.Unlock()
+		return false, int64(counter.Count(ttl))
+	}
+	w := &concurrencyWaiter{
+		priority: priority,
+		seq:      concurrencyWaitSeq.Add(1),
+		limit:    limit,
+		ready:    make(chan struct{}),
+	}
+	q.insertLocked(w)
+	q.mu.Unlock()
+
+	timer := time.NewTimer(maxWait)
+	defer timer.Stop()
+	ticker := time.NewTicker(concurrencyQueuePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.ready:
+			return true, int64(counter.Count(ttl))
+		case <-ticker.C:
+			m.dispatchAccount(accountID)
+		case <-timer.C:
+			return m.leaveWaitQueue(q, w, counter, ttl)
+		case <-ctx.Done():
+			return m.leaveWaitQueue(q, w, counter, ttl)
+		}
+	}
+}
+
+// leaveWaitQueue 超时或取消时退出队列；退出前恰好分到槽位则视为获取成功，由调用方负责释放
+func (m *ConcurrencyManager) leaveWaitQueue(q *accountWaitQueue, w *concurrencyWaiter, counter *ConcurrencyCounter, ttl time.Duration) (bool, int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if w.granted {
+		return true, int64(counter.Count(ttl))
+	}
+	q.removeLocked(w)
+	return false, int64(counter.Count(ttl))
+}
+
+// dispatchAccount 有空闲槽位时按队列顺序分配给等待者
+func (m *ConcurrencyManager) dispatchAccount(accountID uint) {
+	val, ok := m.accountWaitQueues.Load(accountID)
+	if !ok {
+		return
+	}
+	q := val.(*accountWaitQueue)
+	counter := m.getOrCreateAccountCounter(accountID)
+	ttl := getConcurrencyTTL()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.waiters) > 0 {
+		head := q.waiters[0]
+		if acquired, _ := counter.Acquire(head.limit, ttl); !acquired {
+			return
+		}
+		head.granted = true
+		close(head.ready)
+		q.waiters = q.waiters[1:]
+	}
+}
+
+// GetAccountQueueLength 获取账户当前排队请求数
+func (m *ConcurrencyManager) GetAccountQueueLength(accountID uint) int {
+	val, ok := m.accountWaitQueues.Load(accountID)
+	if !ok {
+		return 0
+	}
+	q := val.(*accountWaitQueue)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.waiters)
+}
@@ -0,0 +1,139 @@
+/*
+ * 文件作用：请求幂等去重存储，相同 Idempotency-Key 的并发请求合并为一次上游调用
+ * 负责功能：
+ *   - 按 API Key + 路径 + Idempotency-Key 记录进行中和已完成的请求
+ *   - 进行中的重复请求等待首个请求完成，首个成功时共享其响应
+ *   - 首个请求失败时移除记录，重复请求重新竞争执行
+ *   - 成功结果按去重窗口过期，定期清理
+ * 重要程度：⭐⭐⭐ 一般（防重复调用与重复计费；本存储只管当前进程，跨实例由数据库占用记录拦截）
+ * 依赖模块：无
+ */
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MaxIdempotencyBodySize 可共享的响应体上限，超过时视为不可重放（重复请求重新执行）
+const MaxIdempotencyBodySize = 4 << 20
+
+// IdempotencyResult 首个请求的成功响应（流式请求只记录完成状态，Body 为空）
+type IdempotencyResult struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// IdempotencyEntry 一个 Idempotency-Key 对应的请求
+type IdempotencyEntry struct {
+	Fingerprint string // 请求体哈希，相同 Key 不同请求体时拒绝
+	Stream      bool   // 首个请求是否为流式（流式重复请求直接拒绝）
+
+	done     chan struct{}
+	result   *IdempotencyResult
+	expireAt time.Time
+}
+
+// Done 首个请求完成时关闭
+func (e *IdempotencyEntry) Done() <-chan struct{} {
+	return e.done
+}
+
+// Result 首个请求的成功响应，应在 Done 关闭后调用；首个请求失败时返回 nil
+func (e *IdempotencyEntry) Result() *IdempotencyResult {
+	return e.result
+}
+
+// IdempotencyStore 幂等去重存储
+type IdempotencyStore struct {
+	mu      sync.Mutex
+	entries map[string]*IdempotencyEntry
+}
+
+var (
+	globalIdempotencyStore *IdempotencyStore
+	idempotencyStoreOnce   sync.Once
+)
+
+// GetIdempotencyStore 获取幂等去重存储单例
+func GetIdempotencyStore() *IdempotencyStore {
+	idempotencyStoreOnce.Do(func() {
+		globalIdempotencyStore = &IdempotencyStore{
+			entries: make(map[string]*IdempotencyEntry),
+		}
+		go globalIdempotencyStore.cleanupLoop(time.Minute)
+	})
+	return globalIdempotencyStore
+}
+
+// IdempotencyKey 存储键：按 API Key 和路径隔离，不同 Key 使用相同 Idempotency-Key 互不影响
+func IdempotencyKey(apiKeyID uint, path, idempotencyKey string) string {
+	h := sha256.New()
+	h.Write([]byte(strconv.FormatUint(uint64(apiKeyID), 10)))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write([]byte(idempotencyKey))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// IdempotencyFingerprint 请求体指纹
+func IdempotencyFingerprint(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// Begin 登记请求：没有进行中或未过期的记录时创建记录并返回 leader=true（由调用方执行并调用 Complete）
+// 否则返回已有记录，调用方等待或拒绝
+func (s *IdempotencyStore) Begin(key, fingerprint string, stream bool) (entry *IdempotencyEntry, leader bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.entries[key]; ok {
+		if existing.expireAt.IsZero() || time.Now().Before(existing.expireAt) {
+			return existing, false
+		}
+	}
+	entry = &IdempotencyEntry{
+		Fingerprint: fingerprint,
+		Stream:      stream,
+		done:        make(chan struct{}),
+	}
+	s.entries[key] = entry
+	return entry, true
+}
+
+// Complete 首个请求结束：result 为 nil 表示失败，移除记录让重复请求重新执行；否则在窗口内保留结果
+func (s *IdempotencyStore) Complete(key string, entry *IdempotencyEntry, result *IdempotencyResult, window time.Duration) {
+	s.mu.Lock()
+	if result != nil && len(result.Body) <= MaxIdempotencyBodySize {
+		entry.result = result
+		entry.expireAt = time.Now().Add(window)
+	} else if s.entries[key] == entry {
+		delete(s.entries, key)
+	}
+	s.mu.Unlock()
+	close(entry.done)
+}
+
+// cleanupLoop 定期清理过期记录（进行中的记录没有过期时间，不清理）
+func (s *IdempotencyStore) cleanupLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := time.Now()
+		s.mu.Lock()
+		for k, entry := range s.entries {
+			if !entry.expireAt.IsZero() && now.After(entry.expireAt) {
+				delete(s.entries, k)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
@@ -2,7 +2,7 @@
  * 文件作用：内存缓存实现，提供会话存储、并发管理和不可用标记
  * 负责功能：
  *   - 会话绑定存储（SessionStore）
- *   - 并发计数管理（ConcurrencyManager）
+ *   - 并发计数管理（ConcurrencyManager，含优先级等待队列）
  *   - 账户不可用标记（UnavailableMarker）
  *   - 过期数据自动清理
  * 重要程度：⭐⭐⭐⭐ 重要（内存缓存核心）
@@ -333,13 +333,32 @@ func (s *SessionStore) ClearByUser(userID uint) int {
 	return len(sessionIDs)
 }
 
+// SessionFilter 会话列表过滤条件（为 0 的字段不过滤）
+type SessionFilter struct {
+	AccountID uint
+	UserID    uint
+	APIKeyID  uint
+}
+
+// match 会话是否满足过滤条件
+func (f SessionFilter) match(binding *MemorySessionBinding) bool {
+	return (f.AccountID == 0 || binding.AccountID == f.AccountID) &&
+		(f.UserID == 0 || binding.UserID == f.UserID) &&
+		(f.APIKeyID == 0 || binding.APIKeyID == f.APIKeyID)
+}
+
 // ListAll 列出所有会话（带分页）
 func (s *SessionStore) ListAll(offset, limit int) ([]*MemorySessionBinding, int) {
+	return s.List(SessionFilter{}, offset, limit)
+}
+
+// List 按条件列出会话（带分页）
+func (s *SessionStore) List(filter SessionFilter, offset, limit int) ([]*MemorySessionBinding, int) {
 	var all []*MemorySessionBinding
 
 	s.bindings.Range(func(key, value interface{}) bool {
 		binding := value.(*MemorySessionBinding)
-		if !binding.IsExpired() {
+		if !binding.IsExpired() && filter.match(binding) {
 			all = append(all, binding)
 		}
 		return true
@@ -480,6 +499,10 @@ type ConcurrencyManager struct {
 	userCounters    sync.Map // userID -> *ConcurrencyCounter
 	accountLimits   sync.Map // accountID -> int (自定义限制)
 
+	accountWaitQueues sync.Map // accountID -> *accountWaitQueue (并发已满时的优先级等待队列)
+
+	accountModelCounters sync.Map // accountModelKey -> *ConcurrencyCounter (按模型并发)
+
 	cleanupInterval time.Duration
 	stopCleanup     chan struct{}
 	cleanupOnce     sync.Once
@@ -550,6 +573,12 @@ func (m *ConcurrencyManager) cleanExpired() {
 		counter.Count(ttl) // Count 会触发清理
 		return true
 	})
+
+	m.accountModelCounters.Range(func(key, value interface{}) bool {
+		counter := value.(*ConcurrencyCounter)
+		counter.Count(ttl) // Count 会触发清理
+		return true
+	})
 }
 
 // getConcurrencyTTL 获取并发TTL
@@ -601,10 +630,11 @@ func (m *ConcurrencyManager) AcquireAccountWithLimit(ctx context.Context, accoun
 	return acquired, int64(count)
 }
 
-// ReleaseAccount 释放账户并发槽位
+// ReleaseAccount 释放账户并发槽位，有排队请求时移交给队首
 func (m *ConcurrencyManager) ReleaseAccount(ctx context.Context, accountID uint) {
 	counter := m.getOrCreateAccountCounter(accountID)
 	counter.Release()
+	m.dispatchAccount(accountID)
 }
 
 // GetAccountConcurrency 获取账户当前并发数
@@ -614,11 +644,73 @@ func (m *ConcurrencyManager) GetAccountConcurrency(accountID uint) int64 {
 	return int64(counter.Count(ttl))
 }
 
+// GetAllAccountConcurrency 获取所有账户当前的并发数（只包含并发大于 0 的账户）
+func (m *ConcurrencyManager) GetAllAccountConcurrency() map[uint]int64 {
+	ttl := getConcurrencyTTL()
+	result := make(map[uint]int64)
+	m.accountCounters.Range(func(key, value interface{}) bool {
+		if count := value.(*ConcurrencyCounter).Count(ttl); count > 0 {
+			result[key.(uint)] = int64(count)
+		}
+		return true
+	})
+	return result
+}
+
 // ResetAccountConcurrency 重置账户并发计数
 func (m *ConcurrencyManager) ResetAccountConcurrency(accountID uint) {
 	if val, ok := m.accountCounters.Load(accountID); ok {
 		val.(*ConcurrencyCounter).Reset()
 	}
+	m.accountModelCounters.Range(func(key, value interface{}) bool {
+		if key.(accountModelKey).accountID == accountID {
+			value.(*ConcurrencyCounter).Reset()
+		}
+		return true
+	})
+}
+
+// accountModelKey 按模型并发计数的键（account:id:model）
+type accountModelKey struct {
+	accountID uint
+	model     string
+}
+
+// getOrCreateAccountModelCounter 获取或创建账户+模型计数器
+func (m *ConcurrencyManager) getOrCreateAccountModelCounter(accountID uint, modelName string) *ConcurrencyCounter {
+	val, _ := m.accountModelCounters.LoadOrStore(accountModelKey{accountID: accountID, model: modelName}, &ConcurrencyCounter{})
+	return val.(*ConcurrencyCounter)
+}
+
+// AcquireAccountModel 获取账户在指定模型上的并发槽位
+func (m *ConcurrencyManager) AcquireAccountModel(ctx context.Context, accountID uint, modelName string, limit int) (bool, int64) {
+	counter := m.getOrCreateAccountModelCounter(accountID, modelName)
+	ttl := getConcurrencyTTL()
+	acquired, count := counter.Acquire(limit, ttl)
+	return acquired, int64(count)
+}
+
+// ReleaseAccountModel 释放账户在指定模型上的并发槽位
+func (m *ConcurrencyManager) ReleaseAccountModel(ctx context.Context, accountID uint, modelName string) {
+	counter := m.getOrCreateAccountModelCounter(accountID, modelName)
+	counter.Release()
+}
+
+// GetAccountModelConcurrency 获取账户各模型当前并发数（只包含有占用的模型）
+func (m *ConcurrencyManager) GetAccountModelConcurrency(accountID uint) map[string]int64 {
+	ttl := getConcurrencyTTL()
+	result := make(map[string]int64)
+	m.accountModelCounters.Range(func(key, value interface{}) bool {
+		k := key.(accountModelKey)
+		if k.accountID != accountID {
+			return true
+		}
+		if count := value.(*ConcurrencyCounter).Count(ttl); count > 0 {
+			result[k.model] = int64(count)
+		}
+		return true
+	})
+	return result
 }
 
 // AcquireUser 获取用户并发槽位
@@ -666,6 +758,20 @@ func (m *ConcurrencyManager) Stats() (accountCount, userCount int) {
 	return
 }
 
+// SlotsInUse 获取当前占用的并发槽位总数（账户维度、用户维度）
+func (m *ConcurrencyManager) SlotsInUse() (accountSlots, userSlots int64) {
+	ttl := getConcurrencyTTL()
+	m.accountCounters.Range(func(_, value interface{}) bool {
+		accountSlots += int64(value.(*ConcurrencyCounter).Count(ttl))
+		return true
+	})
+	m.userCounters.Range(func(_, value interface{}) bool {
+		userSlots += int64(value.(*ConcurrencyCounter).Count(ttl))
+		return true
+	})
+	return
+}
+
 // ==================== 不可用标记 ====================
 
 // unavailableMark 不可用标记
@@ -0,0 +1,160 @@
+/*
+ * 文件作用：响应缓存，确定性请求（temperature=0 的非流式请求）直接返回上次的响应
+ * 负责功能：
+ *   - 按 API Key + 路径 + 请求体哈希缓存响应体和原始 usage
+ *   - 按条目 TTL 过期，定期清理
+ *   - 条目数超过上限时先清理过期条目，仍超限时随机淘汰
+ * 重要程度：⭐⭐⭐ 一般（重复调用省钱，缓存只保存在当前进程内）
+ * 依赖模块：无
+ */
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxResponseCacheBodySize 单个响应体的缓存上限，超过时不缓存
+const maxResponseCacheBodySize = 1 << 20
+
+// ResponseCacheEntry 缓存的响应
+type ResponseCacheEntry struct {
+	Body                 []byte // 返回给客户端的响应体
+	Model                string // 计费模型（发生回退时为实际模型）
+	InputTokens          int    // 原始 usage（未应用倍率），命中时按缓存命中系数计费
+	OutputTokens         int
+	CacheReadInputTokens int
+	ThinkingTokens       int
+
+	PromptCacheCreationTokens int // Claude prompt caching 统计（原始值），命中时按当前倍率透传到响应头
+	PromptCacheReadTokens     int
+
+	CreatedAt time.Time
+	expireAt  time.Time
+}
+
+// ResponseCache 响应缓存
+type ResponseCache struct {
+	mu      sync.Mutex
+	entries map[string]*ResponseCacheEntry
+	hits    int64
+	misses  int64
+}
+
+var (
+	globalResponseCache *ResponseCache
+	responseCacheOnce   sync.Once
+)
+
+// GetResponseCache 获取响应缓存单例
+func GetResponseCache() *ResponseCache {
+	responseCacheOnce.Do(func() {
+		globalResponseCache = &ResponseCache{
+			entries: make(map[string]*ResponseCacheEntry),
+		}
+		go globalResponseCache.cleanupLoop(time.Minute)
+	})
+	return globalResponseCache
+}
+
+// ResponseCacheKey 缓存键：按 API Key 隔离，不同 Key 之间不共享响应
+func ResponseCacheKey(apiKeyID uint, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(strconv.FormatUint(uint64(apiKeyID), 10)))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Get 获取缓存的响应，不存在或已过期返回 nil
+func (c *ResponseCache) Get(key string) *ResponseCacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if ok && time.Now().After(entry.expireAt) {
+		delete(c.entries, key)
+		ok = false
+	}
+	if !ok {
+		c.misses++
+		return nil
+	}
+	c.hits++
+	return entry
+}
+
+// Set 缓存响应，maxEntries 为条目数上限（<=0 不限制）
+func (c *ResponseCache) Set(key string, entry *ResponseCacheEntry, ttl time.Duration, maxEntries int) {
+	if ttl <= 0 || len(entry.Body) == 0 || len(entry.Body) > maxResponseCacheBodySize {
+		return
+	}
+	now := time.Now()
+	entry.CreatedAt = now
+	entry.expireAt = now.Add(ttl)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.entries[key]; !exists && maxEntries > 0 && len(c.entries) >= maxEntries {
+		c.purgeExpiredLocked(now)
+		for k := range c.entries {
+			if len(c.entries) < maxEntries {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = entry
+}
+
+// Clear 清空缓存，返回清除的条目数
+func (c *ResponseCache) Clear() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	count := len(c.entries)
+	c.entries = make(map[string]*ResponseCacheEntry)
+	return count
+}
+
+// ResponseCacheStats 响应缓存统计
+type ResponseCacheStats struct {
+	Entries int   `json:"entries"`
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+}
+
+// Stats 获取缓存统计（命中/未命中为进程启动以来的累计值）
+func (c *ResponseCache) Stats() ResponseCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return ResponseCacheStats{Entries: len(c.entries), Hits: c.hits, Misses: c.misses}
+}
+
+// purgeExpiredLocked 清理过期条目（调用方持有锁）
+func (c *ResponseCache) purgeExpiredLocked(now time.Time) {
+	for k, entry := range c.entries {
+		if now.After(entry.expireAt) {
+			delete(c.entries, k)
+		}
+	}
+}
+
+// cleanupLoop 定期清理过期条目
+func (c *ResponseCache) cleanupLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		c.mu.Lock()
+		c.purgeExpiredLocked(time.Now())
+		c.mu.Unlock()
+	}
+}
@@ -0,0 +1,81 @@
+/*
+ * 文件作用：OpenAI Responses 会话链路映射，response_id -> 会话哈希
+ * 负责功能：
+ *   - 记录每个响应 ID 所属的会话哈希
+ *   - 多轮对话通过 previous_response_id 找回整条链路的会话哈希
+ *   - 过期数据自动清理（TTL 与会话粘性一致）
+ * 重要程度：⭐⭐⭐ 一般（Responses 多轮对话账户粘性）
+ * 依赖模块：config
+ */
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// responseSessionEntry 响应 ID 对应的会话信息
+type responseSessionEntry struct {
+	sessionID string
+	expireAt  time.Time
+}
+
+// ResponseSessionStore 响应 ID -> 会话哈希映射
+type ResponseSessionStore struct {
+	entries sync.Map // responseID -> *responseSessionEntry
+}
+
+var (
+	globalResponseSessionStore *ResponseSessionStore
+	responseSessionStoreOnce   sync.Once
+)
+
+// GetResponseSessionStore 获取响应会话映射单例
+func GetResponseSessionStore() *ResponseSessionStore {
+	responseSessionStoreOnce.Do(func() {
+		globalResponseSessionStore = &ResponseSessionStore{}
+		go globalResponseSessionStore.cleanupLoop(time.Minute)
+	})
+	return globalResponseSessionStore
+}
+
+// Set 记录响应 ID 所属的会话哈希
+func (s *ResponseSessionStore) Set(responseID, sessionID string) {
+	if responseID == "" || sessionID == "" {
+		return
+	}
+	s.entries.Store(responseID, &responseSessionEntry{
+		sessionID: sessionID,
+		expireAt:  time.Now().Add(getSessionTTL()),
+	})
+}
+
+// Get 根据响应 ID 获取会话哈希，不存在或已过期返回空字符串
+func (s *ResponseSessionStore) Get(responseID string) string {
+	value, ok := s.entries.Load(responseID)
+	if !ok {
+		return ""
+	}
+	entry := value.(*responseSessionEntry)
+	if time.Now().After(entry.expireAt) {
+		s.entries.Delete(responseID)
+		return ""
+	}
+	return entry.sessionID
+}
+
+// cleanupLoop 定期清理过期映射
+func (s *ResponseSessionStore) cleanupLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := time.Now()
+		s.entries.Range(func(key, value interface{}) bool {
+			if now.After(value.(*responseSessionEntry).expireAt) {
+				s.entries.Delete(key)
+			}
+			return true
+		})
+	}
+}
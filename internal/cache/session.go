@@ -2,7 +2,7 @@
  * 文件作用：会话缓存服务，管理会话绑定和并发控制
  * 负责功能：
  *   - 会话-账户绑定（实现会话粘性）
- *   - 账户并发计数管理
+ *   - 账户并发计数管理（含优先级排队）
  *   - 账户不可用标记管理
  *   - 用户并发计数管理
  *   - API Key使用量计数
@@ -13,6 +13,8 @@ package cache
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"sync"
 	"time"
 
@@ -55,6 +57,7 @@ var (
 // SessionBinding 会话绑定信息
 type SessionBinding struct {
 	SessionID    string    `json:"session_id"`
+	SessionHash  string    `json:"session_hash,omitempty"` // 会话 ID 哈希（列表展示用，避免直接暴露客户端 session）
 	AccountID    uint      `json:"account_id"`
 	Platform     string    `json:"platform"`
 	Model        string    `json:"model,omitempty"`
@@ -142,9 +145,23 @@ func (s *SessionCache) ClearAccountSessions(ctx context.Context, accountID uint)
 	return int64(count), nil
 }
 
+// ClearSessionsByAccounts 批量清除多个账户的所有会话，返回各账户清除的会话数
+func (s *SessionCache) ClearSessionsByAccounts(ctx context.Context, accountIDs []uint) map[uint]int64 {
+	result := make(map[uint]int64, len(accountIDs))
+	for _, accountID := range accountIDs {
+		result[accountID] = int64(s.sessionStore.ClearByAccount(accountID))
+	}
+	return result
+}
+
 // ListAllSessions 列出所有会话绑定
 func (s *SessionCache) ListAllSessions(ctx context.Context, offset, limit int64) ([]SessionBinding, int64, error) {
-	bindings, total := s.sessionStore.ListAll(int(offset), int(limit))
+	return s.ListSessions(ctx, SessionFilter{}, offset, limit)
+}
+
+// ListSessions 按条件列出会话绑定（按最后使用时间倒序）
+func (s *SessionCache) ListSessions(ctx context.Context, filter SessionFilter, offset, limit int64) ([]SessionBinding, int64, error) {
+	bindings, total := s.sessionStore.List(filter, int(offset), int(limit))
 
 	now := time.Now()
 	result := make([]SessionBinding, len(bindings))
@@ -155,6 +172,7 @@ func (s *SessionCache) ListAllSessions(ctx context.Context, offset, limit int64)
 		}
 		result[i] = SessionBinding{
 			SessionID:    b.SessionID,
+			SessionHash:  sessionHash(b.SessionID),
 			AccountID:    b.AccountID,
 			Platform:     b.Platform,
 			Model:        b.Model,
@@ -171,6 +189,12 @@ func (s *SessionCache) ListAllSessions(ctx context.Context, offset, limit int64)
 	return result, int64(total), nil
 }
 
+// sessionHash 会话 ID 的短哈希
+func sessionHash(sessionID string) string {
+	sum := sha256.Sum256([]byte(sessionID))
+	return hex.EncodeToString(sum[:8])
+}
+
 // ==================== 临时不可用标记 ====================
 
 // MarkAccountUnavailable 标记账户临时不可用
@@ -229,17 +253,50 @@ func (s *SessionCache) AcquireConcurrencyWithLimit(ctx context.Context, accountI
 	return acquired, current, nil
 }
 
+// AcquireConcurrencyWithPriority 获取并发槽位，已满时按优先级排队等待，最长 maxWait（<=0 不等待）
+func (s *SessionCache) AcquireConcurrencyWithPriority(ctx context.Context, accountID uint, limit, priority int, maxWait time.Duration) (bool, int64, error) {
+	acquired, current := s.concurrencyManager.AcquireAccountWithPriority(ctx, accountID, limit, priority, maxWait)
+	return acquired, current, nil
+}
+
+// GetAccountQueueLength 获取账户当前排队请求数
+func (s *SessionCache) GetAccountQueueLength(accountID uint) int {
+	return s.concurrencyManager.GetAccountQueueLength(accountID)
+}
+
 // ReleaseConcurrency 释放并发槽位
 func (s *SessionCache) ReleaseConcurrency(ctx context.Context, accountID uint) error {
 	s.concurrencyManager.ReleaseAccount(ctx, accountID)
 	return nil
 }
 
+// AcquireModelConcurrency 获取账户在指定模型上的并发槽位
+func (s *SessionCache) AcquireModelConcurrency(ctx context.Context, accountID uint, modelName string, limit int) (bool, int64, error) {
+	acquired, current := s.concurrencyManager.AcquireAccountModel(ctx, accountID, modelName, limit)
+	return acquired, current, nil
+}
+
+// ReleaseModelConcurrency 释放账户在指定模型上的并发槽位
+func (s *SessionCache) ReleaseModelConcurrency(ctx context.Context, accountID uint, modelName string) error {
+	s.concurrencyManager.ReleaseAccountModel(ctx, accountID, modelName)
+	return nil
+}
+
+// GetAccountModelConcurrency 获取账户各模型当前并发数
+func (s *SessionCache) GetAccountModelConcurrency(accountID uint) map[string]int64 {
+	return s.concurrencyManager.GetAccountModelConcurrency(accountID)
+}
+
 // GetAccountConcurrency 获取账户当前并发数
 func (s *SessionCache) GetAccountConcurrency(ctx context.Context, accountID uint) (int64, error) {
 	return s.concurrencyManager.GetAccountConcurrency(accountID), nil
 }
 
+// GetAllAccountConcurrency 获取所有账户当前并发数（只包含并发大于 0 的账户）
+func (s *SessionCache) GetAllAccountConcurrency() map[uint]int64 {
+	return s.concurrencyManager.GetAllAccountConcurrency()
+}
+
 // ResetAccountConcurrency 重置账户并发计数
 func (s *SessionCache) ResetAccountConcurrency(ctx context.Context, accountID uint) error {
 	s.concurrencyManager.ResetAccountConcurrency(accountID)
@@ -0,0 +1,118 @@
+/*
+ * 文件作用：API Key 令牌桶限速器，允许一定突发并按稳态速率放行请求
+ * 负责功能：
+ *   - 按 API Key 隔离的令牌桶
+ *   - 按时间惰性补充令牌，取令牌与补充在同一把锁内完成
+ *   - 令牌不足时计算需要等待的时间（Retry-After）
+ *   - 清理已补满的空闲桶
+ *   - 数据库令牌桶不可用时的本实例兜底
+ * 重要程度：⭐⭐⭐ 一般（API Key 限速）
+ * 依赖模块：无
+ */
+package cache
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// tokenBucketCleanupInterval 清理已补满的桶的间隔
+const tokenBucketCleanupInterval = 10 * time.Minute
+
+// tokenBucket 单个 API Key 的令牌桶状态
+type tokenBucket struct {
+	tokens     float64
+	lastRefill time.Time
+	fullAt     time.Time // 预计补满的时间，之后删除与满桶等价
+}
+
+// TokenBucketLimiter 令牌桶限速器
+// 桶状态只保存在当前进程内，限速只对本实例生效；多实例共享的桶在数据库中（见 APIKeyTokenBucketRepository），
+// 本限速器只在数据库不可用时兜底
+type TokenBucketLimiter struct {
+	mu          sync.Mutex
+	buckets     map[uint]*tokenBucket // apiKeyID -> 令牌桶
+	lastCleanup time.Time
+	now         func() time.Time // 时钟（测试时注入）
+}
+
+var (
+	globalTokenBucketLimiter *TokenBucketLimiter
+	tokenBucketLimiterOnce   sync.Once
+)
+
+// GetTokenBucketLimiter 获取令牌桶限速器单例
+func GetTokenBucketLimiter() *TokenBucketLimiter {
+	tokenBucketLimiterOnce.Do(func() {
+		globalTokenBucketLimiter = newTokenBucketLimiter(time.Now)
+	})
+	return globalTokenBucketLimiter
+}
+
+// newTokenBucketLimiter 使用指定时钟创建令牌桶限速器
+func newTokenBucketLimiter(now func() time.Time) *TokenBucketLimiter {
+	return &TokenBucketLimiter{
+		buckets:     make(map[uint]*tokenBucket),
+		lastCleanup: now(),
+		now:         now,
+	}
+}
+
+// Allow 尝试从 API Key 的桶中取一个令牌
+// capacity 为桶容量（允许的突发请求数），refillRate 为每秒补充的令牌数
+// 参数 <= 0 时不限速；拒绝时返回需要等待的时间
+func (l *TokenBucketLimiter) Allow(apiKeyID uint, capacity int, refillRate float64) (bool, time.Duration) {
+	if capacity <= 0 || refillRate <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.cleanupLocked(now)
+
+	limit := float64(capacity)
+	bucket, ok := l.buckets[apiKeyID]
+	if !ok {
+		bucket = &tokenBucket{tokens: limit, lastRefill: now}
+		l.buckets[apiKeyID] = bucket
+	}
+
+	// 按经过的时间补充令牌，不超过容量（容量调小后立即生效）
+	if elapsed := now.Sub(bucket.lastRefill).Seconds(); elapsed > 0 {
+		bucket.tokens += elapsed * refillRate
+	}
+	bucket.tokens = math.Min(bucket.tokens, limit)
+	bucket.lastRefill = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		bucket.fullAt = now.Add(time.Duration((limit - bucket.tokens) / refillRate * float64(time.Second)))
+		return true, 0
+	}
+
+	wait := time.Duration((1 - bucket.tokens) / refillRate * float64(time.Second))
+	return false, wait
+}
+
+// Reset 清除 API Key 的桶（参数变更后重新计算）
+func (l *TokenBucketLimiter) Reset(apiKeyID uint) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.buckets, apiKeyID)
+}
+
+// cleanupLocked 定期清理已补满的桶（再次请求时按满桶新建），调用方需持有锁
+func (l *TokenBucketLimiter) cleanupLocked(now time.Time) {
+	if now.Sub(l.lastCleanup) < tokenBucketCleanupInterval {
+		return
+	}
+	l.lastCleanup = now
+	for id, bucket := range l.buckets {
+		if !now.Before(bucket.fullAt) {
+			delete(l.buckets, id)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock 可手动推进的时钟
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestTokenBucketLimiter() (*TokenBucketLimiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	return newTokenBucketLimiter(clock.Now), clock
+}
+
+func TestTokenBucketBurst(t *testing.T) {
+	limiter, _ := newTestTokenBucketLimiter()
+
+	// 满桶允许 capacity 个突发请求
+	for i := 0; i < 5; i++ {
+		if allowed, _ := limiter.Allow(1, 5, 1); !allowed {
+			t.Fatalf("第 %d 个突发请求被拒绝", i+1)
+		}
+	}
+
+	// 桶空后拒绝，等待时间为补充一个令牌的时间
+	allowed, wait := limiter.Allow(1, 5, 1)
+	if allowed {
+		t.Fatal("桶空后仍放行")
+	}
+	if wait != time.Second {
+		t.Fatalf("等待时间 = %v, want %v", wait, time.Second)
+	}
+
+	// 不同 API Key 的桶互不影响
+	if allowed, _ := limiter.Allow(2, 5, 1); !allowed {
+		t.Fatal("其他 API Key 被拒绝")
+	}
+}
+
+func TestTokenBucketSteadyRefill(t *testing.T) {
+	limiter, clock := newTestTokenBucketLimiter()
+
+	// 容量 2，每秒补充 2 个令牌
+	for i := 0; i < 2; i++ {
+		limiter.Allow(1, 2, 2)
+	}
+	if allowed, wait := limiter.Allow(1, 2, 2); allowed || wait != 500*time.Millisecond {
+		t.Fatalf("桶空时 Allow = (%v, %v), want (false, 500ms)", allowed, wait)
+	}
+
+	// 稳态：每 500ms 补充一个令牌，正好放行一个请求
+	for i := 0; i < 10; i++ {
+		clock.Advance(500 * time.Millisecond)
+		if allowed, _ := limiter.Allow(1, 2, 2); !allowed {
+			t.Fatalf("第 %d 个稳态请求被拒绝", i+1)
+		}
+		if allowed, _ := limiter.Allow(1, 2, 2); allowed {
+			t.Fatalf("第 %d 个周期内超出稳态速率仍放行", i+1)
+		}
+	}
+
+	// 部分补充：250ms 后还差半个令牌
+	clock.Advance(250 * time.Millisecond)
+	if allowed, wait := limiter.Allow(1, 2, 2); allowed || wait != 250*time.Millisecond {
+		t.Fatalf("部分补充后 Allow = (%v, %v), want (false, 250ms)", allowed, wait)
+	}
+
+	// 长时间空闲后补充不超过容量
+	clock.Advance(time.Hour)
+	for i := 0; i < 2; i++ {
+		if allowed, _ := limiter.Allow(1, 2, 2); !allowed {
+			t.Fatalf("空闲后第 %d 个请求被拒绝", i+1)
+		}
+	}
+	if allowed, _ := limiter.Allow(1, 2, 2); allowed {
+		t.Fatal("空闲后补充超过了容量")
+	}
+}
+
+func TestTokenBucketUnlimited(t *testing.T) {
+	limiter, _ := newTestTokenBucketLimiter()
+	for _, params := range []struct {
+		capacity int
+		rate     float64
+	}{{0, 1}, {5, 0}, {-1, -1}} {
+		if allowed, wait := limiter.Allow(1, params.capacity, params.rate); !allowed || wait != 0 {
+			t.Fatalf("Allow(capacity=%d, rate=%v) = (%v, %v), want (true, 0)", params.capacity, params.rate, allowed, wait)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+/*
+ * 文件作用：用户套餐短期缓存，API Key 认证时的预算检查不必每个请求都查库
+ * 负责功能：
+ *   - 按用户套餐 ID 缓存套餐快照（含套餐模板）
+ *   - 短 TTL 过期，额度调整、用量变化最多延迟一个 TTL 生效
+ *   - 读取时返回副本，调用方修改不影响缓存
+ * 重要程度：⭐⭐⭐ 一般（认证热路径性能）
+ * 依赖模块：model
+ */
+package cache
+
+import (
+	"sync"
+	"time"
+
+	"go-aiproxy/internal/model"
+)
+
+// userPackageCacheTTL 套餐快照缓存时长
+// 预算是否超卖由数据库原子预扣判断，快照只用于用量比例、告警和调度参数，短暂过期可以接受
+const userPackageCacheTTL = 5 * time.Second
+
+// userPackageEntry 套餐快照
+type userPackageEntry struct {
+	userPackage model.UserPackage
+	expireAt    time.Time
+}
+
+// UserPackageCache 用户套餐短期缓存
+type UserPackageCache struct {
+	entries sync.Map // userPackageID -> *userPackageEntry
+}
+
+var (
+	globalUserPackageCache *UserPackageCache
+	userPackageCacheOnce   sync.Once
+)
+
+// GetUserPackageCache 获取用户套餐缓存单例
+func GetUserPackageCache() *UserPackageCache {
+	userPackageCacheOnce.Do(func() {
+		globalUserPackageCache = &UserPackageCache{}
+	})
+	return globalUserPackageCache
+}
+
+// Get 获取套餐快照副本，不存在或已过期返回 nil
+func (c *UserPackageCache) Get(id uint) *model.UserPackage {
+	value, ok := c.entries.Load(id)
+	if !ok {
+		return nil
+	}
+	entry := value.(*userPackageEntry)
+	if time.Now().After(entry.expireAt) {
+		c.entries.Delete(id)
+		return nil
+	}
+	up := entry.userPackage
+	return &up
+}
+
+// Set 缓存套餐快照（保存副本）
+func (c *UserPackageCache) Set(up *model.UserPackage) {
+	if up == nil {
+		return
+	}
+	c.entries.Store(up.ID, &userPackageEntry{
+		userPackage: *up,
+		expireAt:    time.Now().Add(userPackageCacheTTL),
+	})
+}
+
+// Invalidate 删除套餐快照（本实例修改套餐后调用）
+func (c *UserPackageCache) Invalidate(id uint) {
+	c.entries.Delete(id)
+}
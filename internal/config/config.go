@@ -2,7 +2,9 @@
  * 文件作用：应用配置加载，从YAML文件读取系统配置
  * 负责功能：
  *   - 配置文件解析（YAML格式）
- *   - 服务器/数据库/JWT/缓存配置
+ *   - 服务器/数据库/JWT/缓存/监控指标配置（含优雅关闭 drain 窗口）
+ *   - 日志转发/账户告警/计费 webhook 配置
+ *   - 账户凭证加密密钥（支持环境变量覆盖、旧密钥轮换）
  *   - 配置默认值处理
  *   - 全局配置实例管理
  * 重要程度：⭐⭐⭐⭐ 重要（系统配置核心）
@@ -13,21 +15,63 @@ package config
 import (
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	Server ServerConfig `yaml:"server"`
-	MySQL  MySQLConfig  `yaml:"mysql"`
-	JWT    JWTConfig    `yaml:"jwt"`
-	Log    LogConfig    `yaml:"log"`
-	Cache  CacheConfig  `yaml:"cache"`
+	Server  ServerConfig  `yaml:"server"`
+	MySQL   MySQLConfig   `yaml:"mysql"`
+	JWT     JWTConfig     `yaml:"jwt"`
+	Log     LogConfig     `yaml:"log"`
+	Cache   CacheConfig   `yaml:"cache"`
+	Metrics MetricsConfig `yaml:"metrics"`
+
+	LogForward LogForwardConfig `yaml:"log_forward"`
+
+	Alert AlertConfig `yaml:"alert"`
+
+	BillingWebhook BillingWebhookConfig `yaml:"billing_webhook"`
+
+	Crypto CryptoConfig `yaml:"crypto"`
 }
 
 type ServerConfig struct {
-	Port int    `yaml:"port"`
-	Mode string `yaml:"mode"`
+	Port                 int      `yaml:"port"`
+	Mode                 string   `yaml:"mode"`
+	TrustedProxies       []string `yaml:"trusted_proxies"`        // 可信反向代理（IP/CIDR），仅信任其传递的 X-Forwarded-For；未配置时只信任本机和内网地址
+	ShutdownDrainTimeout int      `yaml:"shutdown_drain_timeout"` // 优雅关闭时等待进行中请求（含流式）完成的最长时间（秒），默认 60
+}
+
+// defaultTrustedProxies 未配置可信代理时的默认值：本机和内网地址（同机或内网部署的 nginx 等反向代理）
+// 公网来源的 X-Forwarded-For 不被采信，客户端无法伪造 IP 绕过 API Key IP 白名单
+var defaultTrustedProxies = []string{
+	"127.0.0.0/8", "::1/128",
+	"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7",
+}
+
+// GetTrustedProxies 获取可信代理列表，未配置时返回本机和内网地址
+// 反向代理在公网或其他网段时需配置为实际的代理地址
+func (c *ServerConfig) GetTrustedProxies() []string {
+	if len(c.TrustedProxies) == 0 {
+		return defaultTrustedProxies
+	}
+	return c.TrustedProxies
+}
+
+// DefaultTrustedProxies 默认可信代理列表（配置无效时回退使用）
+func DefaultTrustedProxies() []string {
+	return defaultTrustedProxies
+}
+
+// GetShutdownDrainTimeout 获取优雅关闭的 drain 窗口
+func (c *ServerConfig) GetShutdownDrainTimeout() time.Duration {
+	if c.ShutdownDrainTimeout <= 0 {
+		return 60 * time.Second
+	}
+	return time.Duration(c.ShutdownDrainTimeout) * time.Second
 }
 
 type LogConfig struct {
@@ -105,6 +149,168 @@ func (c *CacheConfig) GetDefaultConcurrencyMax() int {
 	return c.DefaultConcurrencyMax
 }
 
+// MetricsConfig Prometheus 指标配置
+type MetricsConfig struct {
+	Enabled bool   `yaml:"enabled"` // 是否开放 /metrics 端点
+	Token   string `yaml:"token"`   // 独立的访问令牌（Authorization: Bearer <token>），为空则不校验
+}
+
+// LogForwardConfig 请求日志转发配置（脱敏后 POST 到外部日志系统）
+type LogForwardConfig struct {
+	Enabled    bool   `yaml:"enabled"`     // 是否启用转发
+	URL        string `yaml:"url"`         // 接收日志的 webhook 地址
+	Token      string `yaml:"token"`       // 可选，以 Authorization: Bearer <token> 发送
+	QueueSize  int    `yaml:"queue_size"`  // 发送队列长度，队列满时丢弃
+	Workers    int    `yaml:"workers"`     // 发送协程数
+	MaxRetries int    `yaml:"max_retries"` // 单条日志最大重试次数，超过后丢弃
+	Timeout    int    `yaml:"timeout"`     // 单次请求超时（秒）
+}
+
+// GetQueueSize 获取发送队列长度
+func (c *LogForwardConfig) GetQueueSize() int {
+	if c.QueueSize <= 0 {
+		return 1000
+	}
+	return c.QueueSize
+}
+
+// GetWorkers 获取发送协程数
+func (c *LogForwardConfig) GetWorkers() int {
+	if c.Workers <= 0 {
+		return 2
+	}
+	return c.Workers
+}
+
+// GetMaxRetries 获取最大重试次数
+func (c *LogForwardConfig) GetMaxRetries() int {
+	if c.MaxRetries <= 0 {
+		return 3
+	}
+	return c.MaxRetries
+}
+
+// GetTimeout 获取单次请求超时（秒）
+func (c *LogForwardConfig) GetTimeout() int {
+	if c.Timeout <= 0 {
+		return 5
+	}
+	return c.Timeout
+}
+
+// AlertConfig 账户告警 webhook 配置（账户被封/限流时通知）
+type AlertConfig struct {
+	Enabled  bool   `yaml:"enabled"`  // 是否启用告警
+	URL      string `yaml:"url"`      // webhook 地址
+	Format   string `yaml:"format"`   // 消息格式: generic(默认), slack, dingtalk, feishu
+	Debounce int    `yaml:"debounce"` // 去抖时间（分钟），同一账户在该时间内只告警一次
+	Timeout  int    `yaml:"timeout"`  // 单次请求超时（秒）
+}
+
+// GetFormat 获取消息格式
+func (c *AlertConfig) GetFormat() string {
+	if c.Format == "" {
+		return "generic"
+	}
+	return c.Format
+}
+
+// GetDebounce 获取去抖时间（分钟）
+func (c *AlertConfig) GetDebounce() int {
+	if c.Debounce <= 0 {
+		return 30
+	}
+	return c.Debounce
+}
+
+// GetTimeout 获取单次请求超时（秒）
+func (c *AlertConfig) GetTimeout() int {
+	if c.Timeout <= 0 {
+		return 5
+	}
+	return c.Timeout
+}
+
+// BillingWebhookConfig 计费 webhook 配置（每笔消费实时推送到外部计费系统）
+// 事件先持久化再发送，失败按退避重试直到成功，接收方按 event_id 去重
+type BillingWebhookConfig struct {
+	Enabled       bool   `yaml:"enabled"`        // 是否启用推送
+	URL           string `yaml:"url"`            // 接收计费事件的地址
+	Secret        string `yaml:"secret"`         // HMAC-SHA256 签名密钥，为空时不签名
+	Workers       int    `yaml:"workers"`        // 发送协程数
+	Timeout       int    `yaml:"timeout"`        // 单次请求超时（秒）
+	RetryInterval int    `yaml:"retry_interval"` // 扫描待重试事件的间隔（秒）
+	MaxBackoff    int    `yaml:"max_backoff"`    // 重试退避上限（秒）
+}
+
+// GetWorkers 获取发送协程数
+func (c *BillingWebhookConfig) GetWorkers() int {
+	if c.Workers <= 0 {
+		return 2
+	}
+	return c.Workers
+}
+
+// GetTimeout 获取单次请求超时（秒）
+func (c *BillingWebhookConfig) GetTimeout() int {
+	if c.Timeout <= 0 {
+		return 5
+	}
+	return c.Timeout
+}
+
+// GetRetryInterval 获取扫描待重试事件的间隔
+func (c *BillingWebhookConfig) GetRetryInterval() time.Duration {
+	if c.RetryInterval <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(c.RetryInterval) * time.Second
+}
+
+// GetMaxBackoff 获取重试退避上限
+func (c *BillingWebhookConfig) GetMaxBackoff() time.Duration {
+	if c.MaxBackoff <= 0 {
+		return time.Hour
+	}
+	return time.Duration(c.MaxBackoff) * time.Second
+}
+
+// CryptoConfig 账户凭证字段级加密配置（AES-GCM）
+// 轮换密钥：新密钥填入 credential_key，原密钥移入 old_credential_keys，执行 cmd/encrypt_credentials 重新加密后再移除旧密钥
+type CryptoConfig struct {
+	CredentialKey     string   `yaml:"credential_key"`      // 当前密钥，为空时不加密（新数据明文存储）
+	OldCredentialKeys []string `yaml:"old_credential_keys"` // 轮换前的旧密钥，只用于解密
+}
+
+// 环境变量优先于配置文件，避免密钥写入配置文件
+const (
+	envCredentialKey     = "AIPROXY_CREDENTIAL_KEY"
+	envOldCredentialKeys = "AIPROXY_OLD_CREDENTIAL_KEYS" // 多个密钥以逗号分隔
+)
+
+// GetCredentialKey 获取当前密钥
+func (c *CryptoConfig) GetCredentialKey() string {
+	if key := strings.TrimSpace(os.Getenv(envCredentialKey)); key != "" {
+		return key
+	}
+	return strings.TrimSpace(c.CredentialKey)
+}
+
+// GetOldCredentialKeys 获取旧密钥列表
+func (c *CryptoConfig) GetOldCredentialKeys() []string {
+	keys := c.OldCredentialKeys
+	if env := os.Getenv(envOldCredentialKeys); strings.TrimSpace(env) != "" {
+		keys = strings.Split(env, ",")
+	}
+	result := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if key = strings.TrimSpace(key); key != "" {
+			result = append(result, key)
+		}
+	}
+	return result
+}
+
 var Cfg *Config
 
 func Load(path string) error {
@@ -5,6 +5,7 @@
  *   - HTTP状态码/关键词匹配
  *   - 目标账户状态确定
  *   - 规则优先级处理
+ *   - 重试动作规则匹配（供重试层判断重试/换账户/立即失败）
  * 重要程度：⭐⭐⭐⭐ 重要（错误处理核心）
  * 依赖模块：model, repository, logger
  */
@@ -89,6 +90,24 @@ func (m *ErrorRuleMatcher) Match(httpStatusCode int, errMsg string) *MatchResult
 	return &MatchResult{Matched: false}
 }
 
+// MatchRetryAction 匹配配置了重试动作的规则，未匹配返回 nil
+// 只在配置了动作的规则中查找，未配置动作的规则（仅决定账户状态）不会遮挡优先级更低的动作规则
+func (m *ErrorRuleMatcher) MatchRetryAction(httpStatusCode int, errMsg string) *model.ErrorRule {
+	m.mu.RLock()
+	rules := m.cache
+	m.mu.RUnlock()
+
+	errMsgLower := strings.ToLower(errMsg)
+
+	for _, rule := range rules {
+		if rule.RetryAction != "" && m.matchRule(&rule, httpStatusCode, errMsgLower) {
+			return &rule
+		}
+	}
+
+	return nil
+}
+
 // matchRule 检查单条规则是否匹配
 func (m *ErrorRuleMatcher) matchRule(rule *model.ErrorRule, httpStatusCode int, errMsgLower string) bool {
 	// 检查HTTP状态码
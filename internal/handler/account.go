@@ -3,9 +3,12 @@
  * 负责功能：
  *   - 账户列表查询（含费用统计）
  *   - 账户创建/更新/删除
+ *   - 账户批量导入/导出
  *   - 账户启用/禁用
- *   - 账户健康检查触发
+ *   - 账户健康检查触发（含一键检测报告）
  *   - 账户并发和缓存管理
+ *   - 账户分组管理（含组内调度策略）
+ *   - 组织管理员只能管理本组织账户
  * 重要程度：⭐⭐⭐⭐ 重要（账户管理核心）
  * 依赖模块：service, model, repository
  */
@@ -13,8 +16,10 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"strconv"
 
+	"go-aiproxy/internal/middleware"
 	"go-aiproxy/internal/model"
 	"go-aiproxy/internal/repository"
 	"go-aiproxy/internal/service"
@@ -37,6 +42,11 @@ func NewAccountHandler() *AccountHandler {
 	}
 }
 
+// orgService 返回限定为当前管理员组织的账户服务（超级管理员不限制）
+func (h *AccountHandler) orgService(c *gin.Context) *service.AccountService {
+	return h.service.WithOrg(middleware.GetOrgID(c))
+}
+
 // Account endpoints
 
 func (h *AccountHandler) Create(c *gin.Context) {
@@ -46,7 +56,7 @@ func (h *AccountHandler) Create(c *gin.Context) {
 		return
 	}
 
-	account, err := h.service.Create(&req)
+	account, err := h.orgService(c).Create(&req)
 	if err != nil {
 		response.BadRequest(c, err.Error())
 		return
@@ -55,6 +65,40 @@ func (h *AccountHandler) Create(c *gin.Context) {
 	response.Created(c, account)
 }
 
+// ImportAccounts 批量导入账户（JSON/CSV），返回每条的导入结果
+func (h *AccountHandler) ImportAccounts(c *gin.Context) {
+	var req service.AccountImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	result, err := h.orgService(c).ImportAccounts(&req)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	response.Success(c, result)
+}
+
+// ExportAccounts 导出账户配置，凭证脱敏或加密
+func (h *AccountHandler) ExportAccounts(c *gin.Context) {
+	var req service.AccountExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	result, err := h.orgService(c).ExportAccounts(&req)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	response.Success(c, result)
+}
+
 func (h *AccountHandler) Get(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
@@ -83,8 +127,12 @@ func (h *AccountHandler) Update(c *gin.Context) {
 		response.BadRequest(c, err.Error())
 		return
 	}
+	// 只有超级管理员可以调整账户所属组织
+	if middleware.GetOrgID(c) != 0 {
+		req.OrgID = nil
+	}
 
-	account, err := h.service.Update(uint(id), &req)
+	account, err := h.orgService(c).Update(uint(id), &req)
 	if err != nil {
 		response.InternalError(c, err.Error())
 		return
@@ -114,7 +162,7 @@ func (h *AccountHandler) List(c *gin.Context) {
 	platform := c.Query("platform")
 	status := c.Query("status")
 
-	accounts, total, err := h.service.List(page, pageSize, platform, status)
+	accounts, total, err := h.orgService(c).List(page, pageSize, platform, status)
 	if err != nil {
 		response.InternalError(c, err.Error())
 		return
@@ -141,13 +189,15 @@ func (h *AccountHandler) List(c *gin.Context) {
 		TodayCost          float64 `json:"today_cost"`
 		TotalCost          float64 `json:"total_cost"`
 		BudgetUtilization  float64 `json:"budget_utilization"`
+		DailyRequestsUsed  int     `json:"daily_requests_used"` // 当日已用请求数（每日请求上限计数）
 		CurrentConcurrency int64   `json:"current_concurrency"`
 	}
 
 	items := make([]AccountWithUsage, len(accounts))
 	for i, acc := range accounts {
 		items[i] = AccountWithUsage{
-			Account: acc,
+			Account:           acc,
+			DailyRequestsUsed: acc.DailyRequestsUsed(),
 		}
 		if usage, ok := usageMap[acc.ID]; ok {
 			items[i].TodayTokens = usage.TodayTokens
@@ -199,6 +249,31 @@ func (h *AccountHandler) UpdateStatus(c *gin.Context) {
 	response.Success(c, nil)
 }
 
+// SetMaintenance 切换账户维护模式
+func (h *AccountHandler) SetMaintenance(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "invalid account id")
+		return
+	}
+
+	var req struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	account, err := h.service.SetMaintenanceMode(uint(id), req.Enabled)
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+
+	response.Success(c, account)
+}
+
 func (h *AccountHandler) GetTypes(c *gin.Context) {
 	types := []gin.H{
 		{"value": model.AccountTypeClaudeOfficial, "label": "Claude Official", "platform": "claude"},
@@ -263,6 +338,10 @@ func (h *AccountHandler) UpdateGroup(c *gin.Context) {
 
 	group, err := h.service.UpdateGroup(uint(id), &req)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidGroupStrategy) {
+			response.BadRequest(c, err.Error())
+			return
+		}
 		response.InternalError(c, err.Error())
 		return
 	}
@@ -427,6 +506,56 @@ func (h *AccountHandler) GetHealthCheckStatus(c *gin.Context) {
 	response.Success(c, status)
 }
 
+// GetHealthSummary 获取账户健康汇总（按平台状态计数、临近限流、高错误率账户）
+func (h *AccountHandler) GetHealthSummary(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "5"))
+	if limit < 1 {
+		limit = 5
+	}
+	if limit > 50 {
+		limit = 50
+	}
+
+	summary, err := h.service.GetHealthSummary(limit)
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+
+	response.Success(c, summary)
+}
+
+// GetCredentialHealth 获取账户凭证健康度（Token 剩余时间、最近刷新、SessionKey 校验结果）
+func (h *AccountHandler) GetCredentialHealth(c *gin.Context) {
+	report, err := service.GetAccountHealthCheckService().GetCredentialHealth()
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+
+	response.Success(c, report)
+}
+
+// CheckAllAccounts 一键检测账户可用性，返回每个账户的结果明细（默认不改变账户状态）
+func (h *AccountHandler) CheckAllAccounts(c *gin.Context) {
+	var req service.AccountCheckAllRequest
+	// 请求体可选，为空时只读检测全部账户
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.BadRequest(c, err.Error())
+			return
+		}
+	}
+
+	report, err := service.GetAccountHealthCheckService().CheckAllAccounts(&req)
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+
+	response.Success(c, report)
+}
+
 // TriggerHealthCheck 手动触发全局健康检测
 func (h *AccountHandler) TriggerHealthCheck(c *gin.Context) {
 	healthCheckService := service.GetAccountHealthCheckService()
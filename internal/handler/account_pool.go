@@ -0,0 +1,195 @@
+/*
+ * 文件作用：账户池管理接口
+ * 负责功能：
+ *   - 账户池 CRUD
+ *   - 查看池内账户、批量加入/移出账户
+ * 重要程度：⭐⭐⭐ 一般（大规模账户管理）
+ * 依赖模块：service
+ */
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"go-aiproxy/internal/service"
+	"go-aiproxy/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AccountPoolHandler struct {
+	service *service.AccountPoolService
+}
+
+func NewAccountPoolHandler() *AccountPoolHandler {
+	return &AccountPoolHandler{service: service.NewAccountPoolService()}
+}
+
+// isPoolRequestError 请求参数校验失败（策略、模型映射格式）
+func isPoolRequestError(err error) bool {
+	return errors.Is(err, service.ErrInvalidGroupStrategy) || errors.Is(err, service.ErrInvalidPoolModelMapping)
+}
+
+func (h *AccountPoolHandler) Create(c *gin.Context) {
+	var req service.CreatePoolRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	pool, err := h.service.Create(&req)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	response.Created(c, pool)
+}
+
+func (h *AccountPoolHandler) Get(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "invalid pool id")
+		return
+	}
+
+	pool, err := h.service.GetByID(uint(id))
+	if err != nil {
+		response.NotFound(c, "pool not found")
+		return
+	}
+
+	response.Success(c, pool)
+}
+
+func (h *AccountPoolHandler) Update(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "invalid pool id")
+		return
+	}
+
+	var req service.UpdatePoolRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	pool, err := h.service.Update(uint(id), &req)
+	if err != nil {
+		if isPoolRequestError(err) {
+			response.BadRequest(c, err.Error())
+			return
+		}
+		response.InternalError(c, err.Error())
+		return
+	}
+
+	response.Success(c, pool)
+}
+
+func (h *AccountPoolHandler) Delete(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "invalid pool id")
+		return
+	}
+
+	if err := h.service.Delete(uint(id)); err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+func (h *AccountPoolHandler) List(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	pools, total, err := h.service.List(page, pageSize)
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+
+	response.Success(c, gin.H{
+		"items": pools,
+		"total": total,
+		"page":  page,
+	})
+}
+
+func (h *AccountPoolHandler) GetAll(c *gin.Context) {
+	pools, err := h.service.GetAll()
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+
+	response.Success(c, pools)
+}
+
+// GetAccounts 池内账户列表
+func (h *AccountPoolHandler) GetAccounts(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "invalid pool id")
+		return
+	}
+
+	accounts, err := h.service.GetAccounts(uint(id))
+	if err != nil {
+		response.NotFound(c, "pool not found")
+		return
+	}
+
+	response.Success(c, accounts)
+}
+
+// AddAccounts 批量加入账户
+func (h *AccountPoolHandler) AddAccounts(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "invalid pool id")
+		return
+	}
+
+	var req struct {
+		AccountIDs []uint `json:"account_ids" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	added, err := h.service.AddAccounts(uint(id), req.AccountIDs)
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+
+	response.Success(c, gin.H{"added": added})
+}
+
+func (h *AccountPoolHandler) RemoveAccount(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "invalid pool id")
+		return
+	}
+
+	accountID, err := strconv.ParseUint(c.Param("accountId"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "invalid account id")
+		return
+	}
+
+	if err := h.service.RemoveAccount(uint(id), uint(accountID)); err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+
+	response.Success(c, nil)
+}
@@ -6,15 +6,18 @@
  *   - 模型启用/禁用切换
  *   - 默认模型初始化和重置
  *   - 获取支持的平台列表
+ *   - /v1/models 聚合端点（按 API Key 权限返回 OpenAI 格式模型列表）
  * 重要程度：⭐⭐⭐ 一般（模型配置管理）
- * 依赖模块：repository, model
+ * 依赖模块：repository, model, middleware
  */
 package handler
 
 import (
 	"net/http"
 	"strconv"
+	"strings"
 
+	"go-aiproxy/internal/middleware"
 	"go-aiproxy/internal/model"
 	"go-aiproxy/internal/repository"
 	"go-aiproxy/pkg/response"
@@ -121,6 +124,10 @@ func (h *AIModelHandler) Update(c *gin.Context) {
 	existing.MaxOutput = updates.MaxOutput
 	existing.InputPrice = updates.InputPrice
 	existing.OutputPrice = updates.OutputPrice
+	existing.ThinkingPrice = updates.ThinkingPrice
+	existing.LongContextThreshold = updates.LongContextThreshold
+	existing.LongContextInputMultiplier = updates.LongContextInputMultiplier
+	existing.LongContextOutputMultiplier = updates.LongContextOutputMultiplier
 	existing.Enabled = updates.Enabled
 	existing.IsDefault = updates.IsDefault
 	existing.SortOrder = updates.SortOrder
@@ -204,3 +211,73 @@ func (h *AIModelHandler) ToggleEnabled(c *gin.Context) {
 
 	response.Success(c, m)
 }
+
+// openAIModelObject OpenAI models list 格式的单个模型
+type openAIModelObject struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"` // 模型所属平台: claude/openai/gemini
+}
+
+// ListForAPIKey /v1/models 聚合端点
+// 返回当前 API Key 可用的模型：模型已启用、平台在 Key 允许范围内且有启用账户、模型在 Key 允许列表内且未被禁止
+func (h *AIModelHandler) ListForAPIKey(c *gin.Context) {
+	enabled := true
+	models, err := h.repo.List("", &enabled)
+	if err != nil {
+		response.CustomError(c, http.StatusInternalServerError, "internal_error", "获取模型列表失败")
+		return
+	}
+
+	platforms, err := repository.NewAccountRepository().GetEnabledPlatforms()
+	if err != nil {
+		response.CustomError(c, http.StatusInternalServerError, "internal_error", "获取账户平台失败")
+		return
+	}
+	accountPlatforms := make(map[string]bool, len(platforms))
+	for _, p := range platforms {
+		accountPlatforms[p] = true
+	}
+
+	var blocked []string
+	if key := middleware.GetAPIKey(c); key != nil && key.BlockedModels != "" {
+		blocked = strings.Split(key.BlockedModels, ",")
+	}
+
+	seen := make(map[string]bool, len(models))
+	data := make([]openAIModelObject, 0, len(models))
+	for _, m := range models {
+		if seen[m.Name] || !accountPlatforms[m.Platform] {
+			continue
+		}
+		if !middleware.CheckPlatformAccess(c, m.Platform) || !middleware.CheckModelAccess(c, m.Name) {
+			continue
+		}
+		if isModelBlocked(blocked, m.Name) {
+			continue
+		}
+		seen[m.Name] = true
+		data = append(data, openAIModelObject{
+			ID:      m.Name,
+			Object:  "model",
+			Created: m.CreatedAt.Unix(),
+			OwnedBy: m.Platform,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"object": "list",
+		"data":   data,
+	})
+}
+
+// isModelBlocked 模型是否在 Key 的禁止列表中
+func isModelBlocked(blocked []string, name string) bool {
+	for _, b := range blocked {
+		if strings.TrimSpace(b) == name {
+			return true
+		}
+	}
+	return false
+}
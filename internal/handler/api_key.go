@@ -4,7 +4,9 @@
  *   - API Key 列表查询
  *   - API Key 创建（用户/管理员）
  *   - API Key 删除/禁用
+ *   - IP 白名单编辑和最近访问 IP 查看
  *   - API Key 使用量统计
+ *   - 组织管理员只能查看本组织的 API Key
  * 重要程度：⭐⭐⭐⭐ 重要（API Key管理核心）
  * 依赖模块：service
  */
@@ -15,6 +17,7 @@ import (
 	"strconv"
 	"strings"
 
+	"go-aiproxy/internal/middleware"
 	"go-aiproxy/internal/service"
 	"go-aiproxy/pkg/response"
 
@@ -285,6 +288,241 @@ func (h *APIKeyHandler) AdminToggleStatus(c *gin.Context) {
 	response.Success(c, gin.H{"status": key.Status})
 }
 
+// AdminUpdateAllowedIPs 管理员更新 API Key 的 IP 白名单
+// PUT /api/admin/api-keys/:id/allowed-ips
+func (h *APIKeyHandler) AdminUpdateAllowedIPs(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的 API Key ID")
+		return
+	}
+
+	var req struct {
+		AllowedIPs string `json:"allowed_ips"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "无效的请求数据")
+		return
+	}
+
+	key, err := h.service.AdminUpdateAllowedIPs(uint(id), strings.TrimSpace(req.AllowedIPs))
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	response.Success(c, gin.H{"allowed_ips": key.AllowedIPs})
+}
+
+// AdminUpdateModelFallback 管理员更新 API Key 的模型回退设置
+// PUT /api/admin/api-keys/:id/model-fallback
+func (h *APIKeyHandler) AdminUpdateModelFallback(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的 API Key ID")
+		return
+	}
+
+	var req struct {
+		ModelFallback         string `json:"model_fallback"`
+		DisableModelFallback  bool   `json:"disable_model_fallback"`
+		CrossPlatformFallback *bool  `json:"cross_platform_fallback"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "无效的请求数据")
+		return
+	}
+
+	key, err := h.service.AdminUpdateModelFallback(uint(id), strings.TrimSpace(req.ModelFallback), req.DisableModelFallback, req.CrossPlatformFallback)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	response.Success(c, gin.H{
+		"model_fallback":          key.ModelFallback,
+		"disable_model_fallback":  key.DisableModelFallback,
+		"cross_platform_fallback": key.CrossPlatformFallback,
+	})
+}
+
+// AdminUpdatePriority 管理员更新 API Key 的调度优先级
+// PUT /api/admin/api-keys/:id/priority
+func (h *APIKeyHandler) AdminUpdatePriority(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的 API Key ID")
+		return
+	}
+
+	var req struct {
+		Priority int `json:"priority"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "无效的请求数据")
+		return
+	}
+
+	key, err := h.service.AdminUpdatePriority(uint(id), req.Priority)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	response.Success(c, gin.H{"priority": key.Priority})
+}
+
+// AdminUpdateMaxOutputTokens 管理员更新 API Key 的单请求输出 token 上限
+// PUT /api/admin/api-keys/:id/max-output-tokens
+func (h *APIKeyHandler) AdminUpdateMaxOutputTokens(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的 API Key ID")
+		return
+	}
+
+	var req struct {
+		MaxOutputTokensLimit int `json:"max_output_tokens_limit"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "无效的请求数据")
+		return
+	}
+
+	key, err := h.service.AdminUpdateMaxOutputTokens(uint(id), req.MaxOutputTokensLimit)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	response.Success(c, gin.H{"max_output_tokens_limit": key.MaxOutputTokensLimit})
+}
+
+// AdminUpdateDegradeResponse 管理员更新 API Key 的降级响应设置
+// PUT /api/admin/api-keys/:id/degrade-response
+func (h *APIKeyHandler) AdminUpdateDegradeResponse(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的 API Key ID")
+		return
+	}
+
+	var req struct {
+		DegradeResponseEnabled bool   `json:"degrade_response_enabled"`
+		DegradeResponseText    string `json:"degrade_response_text"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "无效的请求数据")
+		return
+	}
+
+	key, err := h.service.AdminUpdateDegradeResponse(uint(id), req.DegradeResponseEnabled, req.DegradeResponseText)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	response.Success(c, gin.H{
+		"degrade_response_enabled": key.DegradeResponseEnabled,
+		"degrade_response_text":    key.DegradeResponseText,
+	})
+}
+
+// AdminUpdateSystemPrompt 管理员更新 API Key 的 Claude system prompt 前缀、后缀
+// PUT /api/admin/api-keys/:id/system-prompt
+func (h *APIKeyHandler) AdminUpdateSystemPrompt(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的 API Key ID")
+		return
+	}
+
+	var req struct {
+		SystemPromptPrefix string `json:"system_prompt_prefix"`
+		SystemPromptSuffix string `json:"system_prompt_suffix"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "无效的请求数据")
+		return
+	}
+
+	key, err := h.service.AdminUpdateSystemPrompt(uint(id), req.SystemPromptPrefix, req.SystemPromptSuffix)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	response.Success(c, gin.H{
+		"system_prompt_prefix": key.SystemPromptPrefix,
+		"system_prompt_suffix": key.SystemPromptSuffix,
+	})
+}
+
+// AdminUpdateCanary 管理员更新 API Key 的灰度规则
+// PUT /api/admin/api-keys/:id/canary
+func (h *APIKeyHandler) AdminUpdateCanary(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的 API Key ID")
+		return
+	}
+
+	var req struct {
+		CanaryTag     string `json:"canary_tag"`
+		CanaryPercent int    `json:"canary_percent"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "无效的请求数据")
+		return
+	}
+
+	key, err := h.service.AdminUpdateCanary(uint(id), req.CanaryTag, req.CanaryPercent)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	response.Success(c, gin.H{"canary_tag": key.CanaryTag, "canary_percent": key.CanaryPercent})
+}
+
+// AdminUpdateForceAccount 管理员设置 API Key 是否允许强制指定账户（X-Force-Account-Id）
+// PUT /api/admin/api-keys/:id/force-account
+func (h *APIKeyHandler) AdminUpdateForceAccount(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的 API Key ID")
+		return
+	}
+
+	var req struct {
+		AllowForceAccount bool `json:"allow_force_account"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "无效的请求数据")
+		return
+	}
+
+	key, err := h.service.AdminUpdateForceAccount(uint(id), req.AllowForceAccount)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	response.Success(c, gin.H{"allow_force_account": key.AllowForceAccount})
+}
+
+// AdminGetIPAccess 管理员查看 API Key 最近命中/拒绝的 IP
+// GET /api/admin/api-keys/:id/ip-access
+func (h *APIKeyHandler) AdminGetIPAccess(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的 API Key ID")
+		return
+	}
+
+	response.Success(c, gin.H{"items": h.service.GetIPAccessRecords(uint(id))})
+}
+
 // AdminListAll 管理员获取所有 API Key（带用户信息）
 func (h *APIKeyHandler) AdminListAll(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
@@ -297,7 +535,7 @@ func (h *APIKeyHandler) AdminListAll(c *gin.Context) {
 		pageSize = 20
 	}
 
-	keys, total, err := h.service.AdminListAll(page, pageSize)
+	keys, total, err := h.service.WithOrg(middleware.GetOrgID(c)).AdminListAll(page, pageSize)
 	if err != nil {
 		response.InternalError(c, "获取 API Key 列表失败")
 		return
@@ -345,7 +583,7 @@ func (h *APIKeyHandler) AdminLookup(c *gin.Context) {
 		ids = append(ids, id)
 	}
 
-	keys, err := h.service.AdminLookup(ids)
+	keys, err := h.service.WithOrg(middleware.GetOrgID(c)).AdminLookup(ids)
 	if err != nil {
 		response.InternalError(c, "获取 API Key 信息失败")
 		return
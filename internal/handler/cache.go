@@ -59,7 +59,19 @@ func (h *CacheHandler) ListSessions(c *gin.Context) {
 		limit = 100
 	}
 
-	sessions, total, err := h.cacheService.ListAllSessions(ctx, offset, limit)
+	// 可选过滤：按账户 / 用户 / API Key 查看会话绑定
+	var filter service.SessionFilter
+	if id, err := strconv.ParseUint(c.Query("account_id"), 10, 32); err == nil {
+		filter.AccountID = uint(id)
+	}
+	if id, err := strconv.ParseUint(c.Query("user_id"), 10, 32); err == nil {
+		filter.UserID = uint(id)
+	}
+	if id, err := strconv.ParseUint(c.Query("api_key_id"), 10, 32); err == nil {
+		filter.APIKeyID = uint(id)
+	}
+
+	sessions, total, err := h.cacheService.ListSessions(ctx, filter, offset, limit)
 	if err != nil {
 		response.InternalError(c, err.Error())
 		return
@@ -146,6 +158,28 @@ func (h *CacheHandler) ClearAccountSessions(c *gin.Context) {
 	})
 }
 
+// UnbindAccountSessions 按账户批量解绑会话（把这些账户上的所有会话踢掉，下次请求重新选账户）
+func (h *CacheHandler) UnbindAccountSessions(c *gin.Context) {
+	var req struct {
+		AccountIDs []uint `json:"account_ids" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "account_ids is required")
+		return
+	}
+
+	counts := h.cacheService.ClearSessionsByAccounts(c.Request.Context(), req.AccountIDs)
+	var total int64
+	for _, count := range counts {
+		total += count
+	}
+
+	response.Success(c, gin.H{
+		"deleted_count": total,
+		"accounts":      counts,
+	})
+}
+
 // ListUnavailableAccounts 列出所有不可用账户
 func (h *CacheHandler) ListUnavailableAccounts(c *gin.Context) {
 	ctx := c.Request.Context()
@@ -238,6 +272,7 @@ func (h *CacheHandler) GetAccountConcurrency(c *gin.Context) {
 		"account_id": accountID,
 		"current":    current,
 		"limit":      limit,
+		"models":     h.cacheService.GetAccountModelConcurrency(uint(accountID)),
 	})
 }
 
@@ -0,0 +1,76 @@
+/*
+ * 文件作用：灰度分流，按比例把请求分到灰度组（只调度灰度标签账户）或对照组
+ * 负责功能：
+ *   - 灰度规则：API Key 配置优先，其次全局配置（热更新）
+ *   - 按会话哈希或随机分流
+ *   - 分组写入请求上下文，用于请求日志染色
+ * 重要程度：⭐⭐⭐ 一般（新账户 / 中转站灰度上线）
+ * 依赖模块：service, model
+ */
+package handler
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"strings"
+
+	"go-aiproxy/internal/model"
+	"go-aiproxy/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// trafficGroupCtxKey 本请求的灰度染色分组（写入请求日志）
+const trafficGroupCtxKey = "traffic_group"
+
+// getCanaryRule 获取本请求生效的灰度规则：API Key 配置了灰度标签时优先，否则使用全局配置
+func getCanaryRule(c *gin.Context) *service.CanaryRule {
+	global := service.GetConfigService().GetCanaryRule()
+	if v, ok := c.Get("api_key"); ok {
+		if key, ok := v.(*model.APIKey); ok && strings.TrimSpace(key.CanaryTag) != "" {
+			rule := &service.CanaryRule{
+				Tag:       strings.TrimSpace(key.CanaryTag),
+				Percent:   key.CanaryPercent,
+				BySession: true,
+			}
+			if global != nil {
+				rule.BySession = global.BySession
+			}
+			return rule
+		}
+	}
+	return global
+}
+
+// assignCanary 为请求分配灰度分组，返回灰度标签（未启用时为空）和是否分到灰度组
+// 分组同时写入上下文 trafficGroupCtxKey
+func assignCanary(c *gin.Context, sessionID string) (string, bool) {
+	rule := getCanaryRule(c)
+	if rule == nil {
+		return "", false
+	}
+
+	canary := inCanaryBucket(rule, sessionID)
+	group := model.TrafficGroupControl
+	if canary {
+		group = model.TrafficGroupCanary
+	}
+	c.Set(trafficGroupCtxKey, group)
+	return rule.Tag, canary
+}
+
+// inCanaryBucket 按规则判断是否落入灰度比例：按会话分流时同一会话结果固定，没有会话 ID 时随机
+func inCanaryBucket(rule *service.CanaryRule, sessionID string) bool {
+	if rule.Percent <= 0 {
+		return false
+	}
+	if rule.Percent >= 100 {
+		return true
+	}
+	if rule.BySession && sessionID != "" {
+		h := fnv.New32a()
+		h.Write([]byte(rule.Tag + ":" + sessionID))
+		return int(h.Sum32()%100) < rule.Percent
+	}
+	return rand.Intn(100) < rule.Percent
+}
@@ -0,0 +1,360 @@
+/*
+ * 文件作用：Claude Message Batches API 代理处理器
+ * 负责功能：
+ *   - 创建批次（仅从 Claude Console API Key 账户中选择）
+ *   - 查询批次状态、拉取批次结果（落到创建批次的同一账户）
+ *   - 拉取结果时流式转发并汇总 usage，按批处理折扣计费（每个批次只计费一次）
+ * 重要程度：⭐⭐⭐ 一般（离线批量任务）
+ * 依赖模块：adapter, scheduler, model
+ */
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"go-aiproxy/internal/middleware"
+	"go-aiproxy/internal/model"
+	"go-aiproxy/internal/proxy/adapter"
+	"go-aiproxy/internal/proxy/scheduler"
+	"go-aiproxy/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// batchBillingCtxKey 标记当前请求按批处理折扣计费（recordUsage 读取）
+const batchBillingCtxKey = "batch_billing"
+
+// ClaudeCreateBatch 创建消息批次 POST /claude/v1/messages/batches
+// Message Batches 只支持 API Key 认证，因此只从 Claude Console 账户中选择
+func (h *ProxyHandler) ClaudeCreateBatch(c *gin.Context) {
+	log := logger.GetLogger("proxy")
+
+	rawBody, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		if middleware.HandleRequestTooLarge(c, err) {
+			return
+		}
+		claudeBatchError(c, http.StatusBadRequest, "invalid_request_error", "failed to read request body")
+		return
+	}
+
+	// 只解析路由需要的模型字段
+	var basic struct {
+		Requests []struct {
+			Params struct {
+				Model string `json:"model"`
+			} `json:"params"`
+		} `json:"requests"`
+	}
+	if err := json.Unmarshal(rawBody, &basic); err != nil {
+		claudeBatchError(c, http.StatusBadRequest, "invalid_request_error", "invalid JSON: "+err.Error())
+		return
+	}
+	if len(basic.Requests) == 0 {
+		claudeBatchError(c, http.StatusBadRequest, "invalid_request_error", "requests must not be empty")
+		return
+	}
+
+	// 检查批次内所有模型是否启用
+	models := make(map[string]bool)
+	for _, item := range basic.Requests {
+		models[item.Params.Model] = true
+	}
+	for modelName := range models {
+		if !h.checkModelEnabled(c, modelName) {
+			return
+		}
+	}
+
+	// 选择账户（用第一个请求的模型过滤 AllowedModels）
+	// 与其他代理接口走同一套过滤：套餐分组、组织、强制指定账户、灰度、偏好 region；批次不是对话，不做会话粘性
+	firstModel := basic.Requests[0].Params.Model
+	account, err := h.createRetryRequest(c).
+		WithSessionID("").
+		WithAccountTypes(model.AccountTypeClaudeConsole).
+		SelectAccount(c.Request.Context(), model.AccountTypeClaudeConsole+","+firstModel)
+	if err != nil {
+		if errors.Is(err, scheduler.ErrNoAvailableAccount) {
+			customMsg, _ := getCustomErrorMessage(model.ErrorTypeNoAvailableAccount, err.Error())
+			claudeBatchError(c, http.StatusServiceUnavailable, "api_error", customMsg)
+			return
+		}
+		if errors.Is(err, scheduler.ErrForcedAccountUnavailable) {
+			claudeBatchError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		claudeBatchError(c, http.StatusInternalServerError, "api_error", err.Error())
+		return
+	}
+
+	resp, err := adapter.SendClaudeBatchRequest(c.Request.Context(), account, http.MethodPost, "", rawBody, clientHeadersOf(c))
+	if err != nil {
+		errorType, statusCode := getProxyErrorTypeAndCode(err)
+		customMsg, _ := getCustomErrorMessage(errorType, err.Error())
+		claudeBatchError(c, statusCode, "api_error", customMsg)
+		return
+	}
+	defer resp.Body.Close()
+
+	respBody, err := adapter.ReadResponseBody(resp)
+	if err != nil {
+		claudeBatchError(c, http.StatusBadGateway, "api_error", "failed to read upstream response")
+		return
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		var created struct {
+			ID               string `json:"id"`
+			ProcessingStatus string `json:"processing_status"`
+		}
+		if json.Unmarshal(respBody, &created) == nil && created.ID != "" {
+			userID, apiKeyID, _, _ := h.getUserInfo(c)
+			batch := &model.MessageBatch{
+				BatchID:          created.ID,
+				AccountID:        account.ID,
+				UserID:           userID,
+				APIKeyID:         apiKeyID,
+				RequestCount:     len(basic.Requests),
+				ProcessingStatus: created.ProcessingStatus,
+			}
+			if err := h.batchRepo.Create(batch); err != nil {
+				log.Error("保存批次记录失败 | BatchID: %s | AccountID: %d | Error: %v", created.ID, account.ID, err)
+			} else {
+				log.Info("创建消息批次 | BatchID: %s | AccountID: %d | UserID: %d | Requests: %d",
+					created.ID, account.ID, userID, len(basic.Requests))
+			}
+		}
+	}
+
+	c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), respBody)
+}
+
+// ClaudeGetBatch 查询消息批次状态 GET /claude/v1/messages/batches/:batch_id
+func (h *ProxyHandler) ClaudeGetBatch(c *gin.Context) {
+	batch, account, ok := h.loadBatchAccount(c)
+	if !ok {
+		return
+	}
+
+	resp, err := adapter.SendClaudeBatchRequest(c.Request.Context(), account, http.MethodGet, "/"+batch.BatchID, nil, clientHeadersOf(c))
+	if err != nil {
+		errorType, statusCode := getProxyErrorTypeAndCode(err)
+		customMsg, _ := getCustomErrorMessage(errorType, err.Error())
+		claudeBatchError(c, statusCode, "api_error", customMsg)
+		return
+	}
+	defer resp.Body.Close()
+
+	respBody, err := adapter.ReadResponseBody(resp)
+	if err != nil {
+		claudeBatchError(c, http.StatusBadGateway, "api_error", "failed to read upstream response")
+		return
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		var status struct {
+			ProcessingStatus string `json:"processing_status"`
+		}
+		if json.Unmarshal(respBody, &status) == nil && status.ProcessingStatus != "" && status.ProcessingStatus != batch.ProcessingStatus {
+			h.batchRepo.UpdateStatus(batch.BatchID, status.ProcessingStatus)
+		}
+	}
+
+	c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), respBody)
+}
+
+// ClaudeGetBatchResults 拉取消息批次结果 GET /claude/v1/messages/batches/:batch_id/results
+// 结果为 JSONL，边转发边汇总 usage；完整转发后按模型记录用量（每个批次只计费一次）
+func (h *ProxyHandler) ClaudeGetBatchResults(c *gin.Context) {
+	log := logger.GetLogger("proxy")
+
+	batch, account, ok := h.loadBatchAccount(c)
+	if !ok {
+		return
+	}
+
+	resp, err := adapter.SendClaudeBatchRequest(c.Request.Context(), account, http.MethodGet, "/"+batch.BatchID+"/results", nil, clientHeadersOf(c))
+	if err != nil {
+		errorType, statusCode := getProxyErrorTypeAndCode(err)
+		customMsg, _ := getCustomErrorMessage(errorType, err.Error())
+		claudeBatchError(c, statusCode, "api_error", customMsg)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := adapter.ReadResponseBody(resp)
+		c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), respBody)
+		return
+	}
+
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", resp.Header.Get("Content-Type"))
+
+	collector := newBatchUsageCollector()
+	if _, err := io.Copy(io.MultiWriter(c.Writer, collector), resp.Body); err != nil {
+		// 未完整转发（客户端断开或上游中断）不计费，下次拉取时再计
+		log.Warn("批次结果转发中断 | BatchID: %s | Error: %v", batch.BatchID, err)
+		return
+	}
+	collector.Flush()
+
+	recorded, err := h.batchRepo.MarkUsageRecorded(batch.BatchID)
+	if err != nil {
+		log.Error("标记批次计费失败 | BatchID: %s | Error: %v", batch.BatchID, err)
+		return
+	}
+	if !recorded {
+		log.Debug("批次用量已计费，跳过 | BatchID: %s", batch.BatchID)
+		return
+	}
+
+	log.Info("批次结果计费 | BatchID: %s | AccountID: %d | Succeeded: %d | Models: %d",
+		batch.BatchID, account.ID, collector.succeeded, len(collector.usage))
+
+	// 按批处理折扣计费，每个模型记录一条
+	c.Set(batchBillingCtxKey, true)
+	summary, _ := json.Marshal(gin.H{
+		"batch_id":  batch.BatchID,
+		"succeeded": collector.succeeded,
+		"errored":   collector.errored,
+	})
+	modelNames := make([]string, 0, len(collector.usage))
+	for modelName := range collector.usage {
+		modelNames = append(modelNames, modelName)
+	}
+	sort.Strings(modelNames)
+	for _, modelName := range modelNames {
+		h.recordUsage(c, modelName, collector.usage[modelName], false, nil, summary, http.StatusOK, account.ID)
+	}
+}
+
+// loadBatchAccount 加载批次记录和绑定账户，并校验批次归属
+func (h *ProxyHandler) loadBatchAccount(c *gin.Context) (*model.MessageBatch, *model.Account, bool) {
+	batchID := c.Param("batch_id")
+	batch, err := h.batchRepo.GetByBatchID(batchID)
+	if err != nil {
+		claudeBatchError(c, http.StatusNotFound, "not_found_error", fmt.Sprintf("batch %s not found", batchID))
+		return nil, nil, false
+	}
+
+	userID, _, _, _ := h.getUserInfo(c)
+	if batch.UserID != userID {
+		// 不暴露其他用户的批次是否存在
+		claudeBatchError(c, http.StatusNotFound, "not_found_error", fmt.Sprintf("batch %s not found", batchID))
+		return nil, nil, false
+	}
+
+	account, err := h.accountRepo.GetByID(batch.AccountID)
+	if err != nil {
+		claudeBatchError(c, http.StatusServiceUnavailable, "api_error", "the account that created this batch is no longer available")
+		return nil, nil, false
+	}
+	return batch, account, true
+}
+
+// clientHeadersOf 提取客户端请求头（透传给上游，敏感头由适配器过滤）
+func clientHeadersOf(c *gin.Context) map[string]string {
+	headers := make(map[string]string)
+	for key, values := range c.Request.Header {
+		if len(values) > 0 {
+			headers[key] = values[0]
+		}
+	}
+	return headers
+}
+
+// claudeBatchError 返回 Claude 格式的错误
+func claudeBatchError(c *gin.Context, statusCode int, errorType, message string) {
+	c.JSON(statusCode, gin.H{
+		"type": "error",
+		"error": gin.H{
+			"type":    errorType,
+			"message": message,
+		},
+	})
+}
+
+// batchUsageCollector 逐行解析批次结果 JSONL，按模型汇总 usage
+type batchUsageCollector struct {
+	buf       []byte
+	usage     map[string]*adapter.StreamResult
+	succeeded int
+	errored   int
+}
+
+func newBatchUsageCollector() *batchUsageCollector {
+	return &batchUsageCollector{
+		usage: make(map[string]*adapter.StreamResult),
+	}
+}
+
+// Write 实现 io.Writer，按换行切分完整的一行再解析
+func (w *batchUsageCollector) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		idx := bytes.IndexByte(w.buf, '\n')
+		if idx < 0 {
+			break
+		}
+		w.parseLine(w.buf[:idx])
+		w.buf = w.buf[idx+1:]
+	}
+	return len(p), nil
+}
+
+// Flush 解析末尾没有换行的最后一行
+func (w *batchUsageCollector) Flush() {
+	if len(w.buf) > 0 {
+		w.parseLine(w.buf)
+		w.buf = nil
+	}
+}
+
+// parseLine 解析单条结果，只统计成功的请求（失败/过期/取消的请求上游不计费）
+func (w *batchUsageCollector) parseLine(line []byte) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return
+	}
+
+	var item struct {
+		Result struct {
+			Type    string `json:"type"`
+			Message struct {
+				Model string `json:"model"`
+				Usage struct {
+					InputTokens              int `json:"input_tokens"`
+					OutputTokens             int `json:"output_tokens"`
+					CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+					CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+				} `json:"usage"`
+			} `json:"message"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(line, &item); err != nil {
+		return
+	}
+	if item.Result.Type != "succeeded" {
+		w.errored++
+		return
+	}
+	w.succeeded++
+
+	msg := item.Result.Message
+	usage, ok := w.usage[msg.Model]
+	if !ok {
+		usage = &adapter.StreamResult{}
+		w.usage[msg.Model] = usage
+	}
+	usage.InputTokens += msg.Usage.InputTokens
+	usage.OutputTokens += msg.Usage.OutputTokens
+	usage.CacheCreationInputTokens += msg.Usage.CacheCreationInputTokens
+	usage.CacheReadInputTokens += msg.Usage.CacheReadInputTokens
+}
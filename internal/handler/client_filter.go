@@ -4,6 +4,7 @@
  *   - 全局过滤配置管理
  *   - 客户端类型定义（Claude Code/Cursor/Cline等）
  *   - 过滤规则CRUD
+ *   - 访问规则CRUD
  *   - 规则测试验证
  *   - 缓存刷新
  * 重要程度：⭐⭐⭐ 一般（客户端过滤功能）
@@ -330,6 +331,79 @@ func (h *ClientFilterHandler) ToggleRule(c *gin.Context) {
 	response.Success(c, rule)
 }
 
+// ==================== 访问规则管理 ====================
+
+// ListAccessRules 获取所有访问规则
+func (h *ClientFilterHandler) ListAccessRules(c *gin.Context) {
+	rules, err := h.service.GetAllAccessRules()
+	if err != nil {
+		response.InternalError(c, "获取访问规则失败: "+err.Error())
+		return
+	}
+
+	response.Success(c, rules)
+}
+
+// CreateAccessRule 创建访问规则
+func (h *ClientFilterHandler) CreateAccessRule(c *gin.Context) {
+	var rule model.ClientAccessRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		response.BadRequest(c, "无效的数据")
+		return
+	}
+
+	if rule.Name == "" {
+		response.BadRequest(c, "name 不能为空")
+		return
+	}
+
+	if err := h.service.CreateAccessRule(&rule); err != nil {
+		response.BadRequest(c, "创建访问规则失败: "+err.Error())
+		return
+	}
+
+	response.Created(c, rule)
+}
+
+// UpdateAccessRule 更新访问规则
+func (h *ClientFilterHandler) UpdateAccessRule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "无效的 ID")
+		return
+	}
+
+	var rule model.ClientAccessRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		response.BadRequest(c, "无效的数据")
+		return
+	}
+
+	rule.ID = uint(id)
+	if err := h.service.UpdateAccessRule(&rule); err != nil {
+		response.BadRequest(c, "更新访问规则失败: "+err.Error())
+		return
+	}
+
+	response.Success(c, rule)
+}
+
+// DeleteAccessRule 删除访问规则
+func (h *ClientFilterHandler) DeleteAccessRule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "无效的 ID")
+		return
+	}
+
+	if err := h.service.DeleteAccessRule(uint(id)); err != nil {
+		response.InternalError(c, "删除访问规则失败: "+err.Error())
+		return
+	}
+
+	response.Success(c, nil)
+}
+
 // ==================== 测试 ====================
 
 // TestValidation 测试验证
@@ -106,6 +106,26 @@ func (h *ConfigHandler) notifyConfigChange(configs map[string]string) {
 			configChangeCallback(model.ConfigAccountHealthCheckInterval, configs[model.ConfigAccountHealthCheckInterval])
 		}
 	}
+
+	// 检查是否更新了请求重试配置（多项同时修改只通知一次，回调读取完整的最新配置）
+	for key, value := range configs {
+		if service.IsRetryConfigKey(key) {
+			if configChangeCallback != nil {
+				configChangeCallback(key, value)
+			}
+			break
+		}
+	}
+
+	// 恢复账户冷却期配置同理
+	for key, value := range configs {
+		if service.IsWarmupConfigKey(key) {
+			if configChangeCallback != nil {
+				configChangeCallback(key, value)
+			}
+			break
+		}
+	}
 }
 
 // ConfigChangeCallback 配置变更回调函数类型
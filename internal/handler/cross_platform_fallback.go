@@ -0,0 +1,110 @@
+/*
+ * 文件作用：Claude → OpenAI 跨平台兜底，Claude 账户全部不可用时改用 OpenAI 账户处理 Claude 请求
+ * 负责功能：
+ *   - 按 API Key 判断是否开启跨平台兜底
+ *   - 以 OpenAI 平台重新调度账户并转换请求/响应格式
+ *   - 通过 X-Cross-Platform-Fallback 响应头告知客户端
+ * 重要程度：⭐⭐⭐ 一般（请求可用性）
+ * 依赖模块：adapter, scheduler, service, model
+ */
+package handler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"go-aiproxy/internal/model"
+	"go-aiproxy/internal/proxy/adapter"
+	"go-aiproxy/internal/proxy/scheduler"
+	"go-aiproxy/internal/service"
+	"go-aiproxy/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// crossPlatformFallbackValue 跨平台兜底响应头的值
+const crossPlatformFallbackValue = "claude->openai"
+
+// shouldCrossPlatformFallback Claude 请求失败后是否转给 OpenAI 账户
+// 只在 Claude 账户全部不可用且 API Key 开启了跨平台兜底时生效
+func shouldCrossPlatformFallback(c *gin.Context, err error) bool {
+	if !errors.Is(err, scheduler.ErrAllAccountsFailed) {
+		return false
+	}
+	if v, ok := c.Get("api_key"); ok {
+		if key, ok := v.(*model.APIKey); ok {
+			return key.CrossPlatformFallback
+		}
+	}
+	return false
+}
+
+// markCrossPlatformFallback 通过响应头告知客户端本次请求发生了跨平台转换
+func markCrossPlatformFallback(c *gin.Context) {
+	if c.Writer.Written() {
+		// 流式响应头已发送，改用 HTTP Trailer 告知
+		c.Writer.Header().Set(http.TrailerPrefix+model.CrossPlatformFallbackHeader, crossPlatformFallbackValue)
+	} else {
+		c.Header(model.CrossPlatformFallbackHeader, crossPlatformFallbackValue)
+	}
+}
+
+// newCrossPlatformRetryRequest 创建兜底调度请求
+// 不使用会话粘性，避免会话被绑定到 OpenAI 账户，Claude 账户恢复后仍按原会话调度
+func (h *ProxyHandler) newCrossPlatformRetryRequest(c *gin.Context, claudeModel string) *scheduler.RetryableRequest {
+	return h.createRetryRequest(c).
+		WithSessionID("").
+		WithStrictSession(false).
+		WithOriginalModel(claudeModel)
+}
+
+// executeClaudeViaOpenAI 非流式跨平台兜底，返回执行结果和实际使用的 OpenAI 模型（用于计费）
+func (h *ProxyHandler) executeClaudeViaOpenAI(c *gin.Context, req *adapter.Request) (*scheduler.ExecuteResult, string, error) {
+	logger.GetLogger("proxy").Warn("Claude 账户全部不可用，跨平台兜底到 OpenAI | Model: %s | APIKeyID: %d",
+		req.Model, c.GetUint("api_key_id"))
+
+	defaultModel := service.GetConfigService().GetCrossPlatformFallbackModel()
+	retryReq := h.newCrossPlatformRetryRequest(c, req.Model)
+
+	var targetModel string
+	result, err := retryReq.ExecuteWithRetry(
+		c.Request.Context(),
+		model.PlatformOpenAI+","+req.Model,
+		func(ctx context.Context, account *model.Account) (*adapter.Response, error) {
+			targetModel = adapter.ResolveCrossPlatformModel(account, req.Model, defaultModel)
+			return adapter.SendClaudeViaOpenAI(ctx, account, req, targetModel)
+		},
+	)
+	if err != nil {
+		return nil, "", err
+	}
+	markCrossPlatformFallback(c)
+	return result, targetModel, nil
+}
+
+// executeClaudeStreamViaOpenAI 流式跨平台兜底，OpenAI 流式响应转换为 Claude SSE 写入 writer
+func (h *ProxyHandler) executeClaudeStreamViaOpenAI(c *gin.Context, req *adapter.Request, writer io.Writer) (*scheduler.StreamExecuteResult, string, error) {
+	logger.GetLogger("proxy").Warn("Claude 账户全部不可用，跨平台兜底到 OpenAI（流式） | Model: %s | APIKeyID: %d",
+		req.Model, c.GetUint("api_key_id"))
+
+	defaultModel := service.GetConfigService().GetCrossPlatformFallbackModel()
+	retryReq := h.newCrossPlatformRetryRequest(c, req.Model)
+
+	var targetModel string
+	result, err := retryReq.ExecuteStreamWithRetry(
+		c.Request.Context(),
+		model.PlatformOpenAI+","+req.Model,
+		func(ctx context.Context, account *model.Account, w io.Writer) (*adapter.StreamResult, error) {
+			targetModel = adapter.ResolveCrossPlatformModel(account, req.Model, defaultModel)
+			return adapter.SendClaudeStreamViaOpenAI(ctx, account, req, targetModel, w)
+		},
+		writer,
+	)
+	if err != nil {
+		return nil, "", err
+	}
+	markCrossPlatformFallback(c)
+	return result, targetModel, nil
+}
@@ -0,0 +1,179 @@
+/*
+ * 文件作用：降级响应，账户全部不可用时按 API Key 配置返回预设内容而不是报错
+ * 负责功能：
+ *   - 按 API Key 判断是否开启降级响应，取预设文本（为空时使用全局配置）
+ *   - 按 Claude / OpenAI / Gemini 协议格式返回 200 的 assistant 回复，usage 为 0
+ *   - 流式和非流式都支持，通过 X-Degraded-Response 响应头告知客户端
+ * 重要程度：⭐⭐⭐ 一般（请求可用性）
+ * 依赖模块：scheduler, service, model
+ */
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-aiproxy/internal/model"
+	"go-aiproxy/internal/proxy/scheduler"
+	"go-aiproxy/internal/service"
+	"go-aiproxy/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// degradeResponseText 请求失败后是否返回降级响应及其文本
+// 只在账户全部不可用且 API Key 开启了降级响应时生效
+func degradeResponseText(c *gin.Context, err error) (string, bool) {
+	if !errors.Is(err, scheduler.ErrAllAccountsFailed) {
+		return "", false
+	}
+	v, ok := c.Get("api_key")
+	if !ok {
+		return "", false
+	}
+	key, ok := v.(*model.APIKey)
+	if !ok || !key.DegradeResponseEnabled {
+		return "", false
+	}
+
+	logger.GetLogger("proxy").Warn("账户全部不可用，返回降级响应 | APIKeyID: %d", key.ID)
+	if text := strings.TrimSpace(key.DegradeResponseText); text != "" {
+		return text, true
+	}
+	return service.GetConfigService().GetDegradeResponseText(), true
+}
+
+// markDegradedResponse 通过响应头告知客户端本次返回的是降级响应
+func markDegradedResponse(c *gin.Context) {
+	if c.Writer.Written() {
+		// 流式响应头已发送，改用 HTTP Trailer 告知
+		c.Writer.Header().Set(http.TrailerPrefix+model.DegradedResponseHeader, "true")
+	} else {
+		c.Header(model.DegradedResponseHeader, "true")
+	}
+}
+
+// degradeResponseID 降级响应的消息 ID
+func degradeResponseID(prefix string) string {
+	return fmt.Sprintf("%sdegraded_%d", prefix, time.Now().UnixNano())
+}
+
+// writeClaudeDegradeResponse 以 Claude Messages 格式返回降级响应
+func writeClaudeDegradeResponse(c *gin.Context, modelName, text string) {
+	markDegradedResponse(c)
+	c.JSON(http.StatusOK, gin.H{
+		"id":            degradeResponseID("msg_"),
+		"type":          "message",
+		"role":          "assistant",
+		"model":         modelName,
+		"content":       []gin.H{{"type": "text", "text": text}},
+		"stop_reason":   "end_turn",
+		"stop_sequence": nil,
+		"usage": gin.H{
+			"input_tokens":  0,
+			"output_tokens": 0,
+		},
+	})
+}
+
+// writeOpenAIDegradeResponse 以 OpenAI Chat Completions 格式返回降级响应
+func writeOpenAIDegradeResponse(c *gin.Context, modelName, text string) {
+	markDegradedResponse(c)
+	c.JSON(http.StatusOK, gin.H{
+		"id":      degradeResponseID("chatcmpl-"),
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   modelName,
+		"choices": []gin.H{
+			{
+				"index":         0,
+				"message":       gin.H{"role": "assistant", "content": text},
+				"finish_reason": "stop",
+			},
+		},
+		"usage": gin.H{
+			"prompt_tokens":     0,
+			"completion_tokens": 0,
+			"total_tokens":      0,
+		},
+	})
+}
+
+// writeGeminiDegradeResponse 以 Gemini 原生格式返回降级响应
+func writeGeminiDegradeResponse(c *gin.Context, text string) {
+	markDegradedResponse(c)
+	c.JSON(http.StatusOK, gin.H{
+		"candidates": []gin.H{
+			{
+				"content": gin.H{
+					"parts": []gin.H{{"text": text}},
+					"role":  "model",
+				},
+				"finishReason": "STOP",
+			},
+		},
+		"usageMetadata": gin.H{
+			"promptTokenCount":     0,
+			"candidatesTokenCount": 0,
+			"totalTokenCount":      0,
+		},
+	})
+}
+
+// writeClaudeDegradeStream 以 Claude 流式事件返回降级响应（完整的 message_start 到 message_stop）
+func writeClaudeDegradeStream(c *gin.Context, modelName, text string) {
+	markDegradedResponse(c)
+	events := []struct {
+		name string
+		data gin.H
+	}{
+		{"message_start", gin.H{"type": "message_start", "message": gin.H{
+			"id":            degradeResponseID("msg_"),
+			"type":          "message",
+			"role":          "assistant",
+			"model":         modelName,
+			"content":       []gin.H{},
+			"stop_reason":   nil,
+			"stop_sequence": nil,
+			"usage":         gin.H{"input_tokens": 0, "output_tokens": 0},
+		}}},
+		{"content_block_start", gin.H{"type": "content_block_start", "index": 0, "content_block": gin.H{"type": "text", "text": ""}}},
+		{"content_block_delta", gin.H{"type": "content_block_delta", "index": 0, "delta": gin.H{"type": "text_delta", "text": text}}},
+		{"content_block_stop", gin.H{"type": "content_block_stop", "index": 0}},
+		{"message_delta", gin.H{"type": "message_delta", "delta": gin.H{"stop_reason": "end_turn", "stop_sequence": nil}, "usage": gin.H{"output_tokens": 0}}},
+		{"message_stop", gin.H{"type": "message_stop"}},
+	}
+	for _, event := range events {
+		data, _ := json.Marshal(event.data)
+		c.Writer.Write([]byte("event: " + event.name + "\ndata: " + string(data) + "\n\n"))
+	}
+	c.Writer.Flush()
+}
+
+// writeOpenAIDegradeStream 以 OpenAI 流式 chunk 返回降级响应（内容 chunk + 结束 chunk + [DONE]）
+func writeOpenAIDegradeStream(c *gin.Context, modelName, text string) {
+	markDegradedResponse(c)
+	id := degradeResponseID("chatcmpl-")
+	created := time.Now().Unix()
+	chunks := []gin.H{
+		{
+			"id": id, "object": "chat.completion.chunk", "created": created, "model": modelName,
+			"choices": []gin.H{{"index": 0, "delta": gin.H{"role": "assistant", "content": text}, "finish_reason": nil}},
+		},
+		{
+			"id": id, "object": "chat.completion.chunk", "created": created, "model": modelName,
+			"choices": []gin.H{{"index": 0, "delta": gin.H{}, "finish_reason": "stop"}},
+			"usage":   gin.H{"prompt_tokens": 0, "completion_tokens": 0, "total_tokens": 0},
+		},
+	}
+	for _, chunk := range chunks {
+		data, _ := json.Marshal(chunk)
+		c.Writer.Write([]byte("data: " + string(data) + "\n\n"))
+	}
+	c.Writer.Write([]byte("data: [DONE]\n\n"))
+	c.Writer.Flush()
+}
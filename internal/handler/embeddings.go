@@ -0,0 +1,145 @@
+/*
+ * 文件作用：OpenAI 兼容 embeddings 接口代理处理器
+ * 负责功能：
+ *   - /v1/embeddings 请求透传（只调度支持 embeddings 的 OpenAI / Gemini API Key 账户）
+ *   - 按输入 token 计费（embeddings 没有输出 token）
+ * 重要程度：⭐⭐⭐ 一般（文本向量化）
+ * 依赖模块：adapter, scheduler, model
+ */
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"go-aiproxy/internal/middleware"
+	"go-aiproxy/internal/model"
+	"go-aiproxy/internal/proxy/adapter"
+	"go-aiproxy/internal/proxy/scheduler"
+	"go-aiproxy/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OpenAIEmbeddings 文本向量化 POST /v1/embeddings
+// 模型名可带账户类型前缀（如 gemini-api,gemini-embedding-001）指定账户类型
+func (h *ProxyHandler) OpenAIEmbeddings(c *gin.Context) {
+	rawBody, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		if middleware.HandleRequestTooLarge(c, err) {
+			return
+		}
+		response.CustomBadRequest(c, "failed to read request body")
+		return
+	}
+
+	// 只解析路由需要的字段，input 原样透传
+	var basic struct {
+		Model string          `json:"model"`
+		Input json.RawMessage `json:"input"`
+	}
+	if err := json.Unmarshal(rawBody, &basic); err != nil {
+		response.CustomBadRequest(c, "invalid JSON: "+err.Error())
+		return
+	}
+	if basic.Model == "" || len(basic.Input) == 0 {
+		response.CustomBadRequest(c, "model and input are required")
+		return
+	}
+
+	accountTypes := adapter.EmbeddingAccountTypes
+	if accountType := scheduler.DetectAccountType(basic.Model); accountType != "" {
+		if !isEmbeddingAccountType(accountType) {
+			response.CustomBadRequest(c, fmt.Sprintf("account type %s does not support embeddings", accountType))
+			return
+		}
+		accountTypes = []string{accountType}
+	}
+
+	actualModel := scheduler.GetActualModel(basic.Model)
+	if !h.checkModelEnabled(c, actualModel) {
+		return
+	}
+	c.Set("request_body", rawBody)
+
+	// 上游不认识账户类型前缀，转发前去掉
+	req := &adapter.Request{
+		Model:   actualModel,
+		RawBody: adapter.ReplaceBodyModel(rawBody, actualModel),
+	}
+
+	// embeddings 无会话上下文，不使用会话粘性；不同模型向量维度不同，不做模型回退
+	retryReq := h.createRetryRequest(c).
+		WithSessionID("").
+		WithStrictSession(false).
+		WithOriginalModel(actualModel).
+		WithAccountTypes(accountTypes...)
+
+	var embedding *adapter.EmbeddingResult
+	result, err := retryReq.ExecuteWithRetry(
+		c.Request.Context(),
+		basic.Model,
+		func(ctx context.Context, account *model.Account) (*adapter.Response, error) {
+			res, err := adapter.SendEmbeddings(ctx, account, retryReq.ApplyModel(account, req).RawBody)
+			if err != nil {
+				return nil, err
+			}
+			embedding = res
+			return &adapter.Response{Model: res.Model, InputTokens: res.InputTokens}, nil
+		},
+	)
+	if err != nil {
+		if writeUpstreamErrorBody(c, err) {
+			return
+		}
+		errorType, statusCode := getProxyErrorTypeAndCode(err)
+		response.CustomError(c, statusCode, errorType, err.Error())
+		return
+	}
+
+	// 返回给用户的 usage 与对话接口一致按倍率展示
+	priceRate := 1.0
+	if rate, ok := c.Get("api_key_price_rate"); ok {
+		if r, ok := rate.(float64); ok {
+			priceRate = r
+		}
+	}
+	body := rateEmbeddingUsage(embedding.Body, embedding.InputTokens, priceRate)
+
+	// 日志只记录摘要，不保存向量数据
+	summary, _ := json.Marshal(gin.H{
+		"object": "list",
+		"model":  embedding.Model,
+		"usage":  json.RawMessage(adapter.BodyField(body, "usage")),
+	})
+	c.Set(accountRegionCtxKey, result.Region)
+	h.recordNonStreamUsage(c, actualModel, result.Response, rawBody, summary, http.StatusOK, result.AccountID)
+
+	c.Data(http.StatusOK, embedding.ContentType, body)
+}
+
+// isEmbeddingAccountType 账户类型是否支持 embeddings
+func isEmbeddingAccountType(accountType string) bool {
+	for _, t := range adapter.EmbeddingAccountTypes {
+		if t == accountType {
+			return true
+		}
+	}
+	return false
+}
+
+// rateEmbeddingUsage 按倍率改写响应中的 usage，倍率为 1 时原样返回
+func rateEmbeddingUsage(body []byte, inputTokens int, priceRate float64) []byte {
+	if priceRate == 1 {
+		return body
+	}
+	rated := int(float64(inputTokens) * priceRate)
+	usage, _ := json.Marshal(gin.H{
+		"prompt_tokens": rated,
+		"total_tokens":  rated,
+	})
+	return adapter.ReplaceBodyField(body, "usage", usage)
+}
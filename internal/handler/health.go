@@ -0,0 +1,93 @@
+/*
+ * 文件作用：健康检查端点处理器，供负载均衡 / Kubernetes 探针使用
+ * 负责功能：
+ *   - 存活探针（/healthz）：进程能响应即返回 200，不检查任何依赖
+ *   - 就绪探针（/readyz）：MySQL 可连接且至少有一个可调度账户才返回 200，否则 503
+ *   - 依赖检查带短超时，数据库慢时探针按失败返回而不是挂起
+ * 重要程度：⭐⭐⭐ 一般（部署编排）
+ * 依赖模块：repository
+ */
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"go-aiproxy/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readinessCheckTimeout 就绪探针单项依赖检查的超时时间
+const readinessCheckTimeout = 2 * time.Second
+
+type HealthHandler struct {
+	accountRepo *repository.AccountRepository
+}
+
+func NewHealthHandler() *HealthHandler {
+	return &HealthHandler{
+		accountRepo: repository.NewAccountRepository(),
+	}
+}
+
+// Liveness 存活探针 GET /healthz
+func (h *HealthHandler) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readiness 就绪探针 GET /readyz
+// 缓存（会话、并发槽位等）均在进程内存中，没有外部缓存依赖需要检查
+func (h *HealthHandler) Readiness(c *gin.Context) {
+	checks := gin.H{}
+	ready := true
+
+	if err := h.pingDatabase(c.Request.Context()); err != nil {
+		checks["mysql"] = err.Error()
+		checks["accounts"] = "skipped"
+		ready = false
+	} else {
+		checks["mysql"] = "ok"
+		count, err := h.countSchedulableAccounts(c.Request.Context())
+		switch {
+		case err != nil:
+			checks["accounts"] = err.Error()
+			ready = false
+		case count == 0:
+			checks["accounts"] = "no schedulable account"
+			ready = false
+		default:
+			checks["accounts"] = "ok"
+		}
+	}
+
+	if !ready {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "checks": checks})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "checks": checks})
+}
+
+// pingDatabase 带超时检查数据库连接
+func (h *HealthHandler) pingDatabase(ctx context.Context) error {
+	db := repository.GetDB()
+	if db == nil {
+		return errors.New("database not initialized")
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, readinessCheckTimeout)
+	defer cancel()
+	return sqlDB.PingContext(ctx)
+}
+
+// countSchedulableAccounts 带超时统计可调度账户数量
+func (h *HealthHandler) countSchedulableAccounts(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, readinessCheckTimeout)
+	defer cancel()
+	return h.accountRepo.CountSchedulable(ctx)
+}
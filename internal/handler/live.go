@@ -0,0 +1,104 @@
+/*
+ * 文件作用：实时请求流处理器，以 SSE 推送请求完成和重试事件（管理后台监控台）
+ * 负责功能：
+ *   - 订阅事件总线，支持按 API Key / 模型 / 账户过滤
+ *   - 连接数达到上限时返回 429
+ *   - 定时发送心跳注释，避免代理/浏览器因空闲断开
+ *   - 客户端断开时自动取消订阅
+ * 重要程度：⭐⭐ 辅助（运维排查）
+ * 依赖模块：livefeed
+ */
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-aiproxy/internal/livefeed"
+	"go-aiproxy/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// liveKeepAliveInterval 实时请求流心跳间隔
+const liveKeepAliveInterval = 15 * time.Second
+
+// LiveHandler 实时请求流处理器
+type LiveHandler struct {
+	bus *livefeed.Bus
+}
+
+// NewLiveHandler 创建实时请求流处理器
+func NewLiveHandler() *LiveHandler {
+	return &LiveHandler{bus: livefeed.GetBus()}
+}
+
+// Stream 实时请求流（SSE）
+// @Summary 实时请求流
+// @Description 以 SSE 推送请求完成和重试事件（已脱敏），可按 api_key_id / model / account_id 过滤
+// @Tags 请求日志
+// @Produce text/event-stream
+// @Param api_key_id query int false "API Key ID"
+// @Param account_id query int false "账户 ID"
+// @Param model query string false "模型"
+// @Router /api/admin/live [get]
+func (h *LiveHandler) Stream(c *gin.Context) {
+	filter := livefeed.Filter{Model: c.Query("model")}
+	if v := c.Query("api_key_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			response.BadRequest(c, "无效的 api_key_id")
+			return
+		}
+		filter.APIKeyID = uint(id)
+	}
+	if v := c.Query("account_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			response.BadRequest(c, "无效的 account_id")
+			return
+		}
+		filter.AccountID = uint(id)
+	}
+
+	sub, err := h.bus.Subscribe(filter)
+	if err != nil {
+		response.TooManyRequests(c, err.Error())
+		return
+	}
+	defer h.bus.Unsubscribe(sub)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Write([]byte(": connected\n\n"))
+	c.Writer.Flush()
+
+	ticker := time.NewTicker(liveKeepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-ticker.C:
+			if _, err := c.Writer.Write(sseKeepAliveFrame); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case event := <-sub.Events():
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			if _, err := c.Writer.Write([]byte("data: " + string(data) + "\n\n")); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
@@ -0,0 +1,87 @@
+/*
+ * 文件作用：Prometheus 指标端点处理器
+ * 负责功能：
+ *   - 输出 Prometheus 文本格式指标（/metrics）
+ *   - 采集时统计账户状态分布、并发槽位占用、会话绑定数
+ * 重要程度：⭐⭐⭐ 一般（监控对接）
+ * 依赖模块：metrics, repository, cache
+ */
+package handler
+
+import (
+	"strconv"
+	"sync"
+
+	"go-aiproxy/internal/cache"
+	"go-aiproxy/internal/metrics"
+	"go-aiproxy/internal/repository"
+	"go-aiproxy/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MetricsHandler struct{}
+
+var registerGaugesOnce sync.Once
+
+func NewMetricsHandler() *MetricsHandler {
+	registerGaugesOnce.Do(registerStateGauges)
+	return &MetricsHandler{}
+}
+
+// Serve 输出全部指标
+func (h *MetricsHandler) Serve(c *gin.Context) {
+	c.Header("Content-Type", metrics.ContentType)
+	c.Status(200)
+	if err := metrics.WriteText(c.Writer); err != nil {
+		logger.GetLogger("metrics").Warn("输出指标失败: %v", err)
+	}
+}
+
+// registerStateGauges 注册采集时计算的状态类指标
+func registerStateGauges() {
+	accountRepo := repository.NewAccountRepository()
+
+	metrics.NewGaugeFunc(
+		"aiproxy_accounts",
+		"Accounts by platform, status and enabled flag.",
+		[]string{"platform", "status", "enabled"},
+		func() []metrics.GaugeSample {
+			counts, err := accountRepo.CountByPlatformAndStatus()
+			if err != nil {
+				logger.GetLogger("metrics").Warn("统计账户状态失败: %v", err)
+				return nil
+			}
+			samples := make([]metrics.GaugeSample, 0, len(counts))
+			for _, item := range counts {
+				samples = append(samples, metrics.GaugeSample{
+					LabelValues: []string{item.Platform, item.Status, strconv.FormatBool(item.Enabled)},
+					Value:       float64(item.Count),
+				})
+			}
+			return samples
+		},
+	)
+
+	metrics.NewGaugeFunc(
+		"aiproxy_concurrency_slots_in_use",
+		"Concurrency slots currently held, by scope (account or user).",
+		[]string{"scope"},
+		func() []metrics.GaugeSample {
+			accountSlots, userSlots := cache.GetConcurrencyManager().SlotsInUse()
+			return []metrics.GaugeSample{
+				{LabelValues: []string{"account"}, Value: float64(accountSlots)},
+				{LabelValues: []string{"user"}, Value: float64(userSlots)},
+			}
+		},
+	)
+
+	metrics.NewGaugeFunc(
+		"aiproxy_session_bindings",
+		"Active (unexpired) session sticky bindings.",
+		nil,
+		func() []metrics.GaugeSample {
+			return []metrics.GaugeSample{{Value: float64(cache.GetSessionStore().Count())}}
+		},
+	)
+}
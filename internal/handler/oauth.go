@@ -5,6 +5,7 @@
  *   - OAuth授权码交换Token
  *   - Cookie认证方式支持
  *   - PKCE代码验证器生成
+ *   - 过期OAuth会话清理
  *   - TLS指纹伪装（绕过bot检测）
  * 重要程度：⭐⭐⭐⭐ 重要（OAuth账户授权核心）
  * 依赖模块：service, logger
@@ -52,6 +53,10 @@ const (
 	OpenAIAuthorizeURL = "https://auth.openai.com/oauth/authorize" // 注意：路径是 /oauth/authorize 不是 /authorize
 	OpenAIRedirectURI  = "http://localhost:1455/auth/callback"
 	OpenAIScope        = "openid profile email offline_access" // offline_access 用于获取 refresh_token
+
+	// OAuth 会话有效期及清理间隔
+	oauthSessionTTL             = 10 * time.Minute
+	oauthSessionCleanupInterval = time.Minute
 )
 
 // OAuthHandler OAuth 处理器
@@ -79,7 +84,33 @@ type ProxyConfig struct {
 
 // NewOAuthHandler 创建 OAuth 处理器
 func NewOAuthHandler() *OAuthHandler {
-	return &OAuthHandler{}
+	h := &OAuthHandler{}
+	go h.sessionCleanupLoop()
+	return h
+}
+
+// sessionCleanupLoop 定期清理过期会话（用户生成授权链接后未完成授权的会话）
+func (h *OAuthHandler) sessionCleanupLoop() {
+	ticker := time.NewTicker(oauthSessionCleanupInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		h.cleanupExpiredSessions()
+	}
+}
+
+// cleanupExpiredSessions 删除超过有效期的会话
+func (h *OAuthHandler) cleanupExpiredSessions() {
+	removed := 0
+	h.sessions.Range(func(key, value interface{}) bool {
+		if session, ok := value.(*OAuthSession); ok && time.Since(session.CreatedAt) > oauthSessionTTL {
+			h.sessions.Delete(key)
+			removed++
+		}
+		return true
+	})
+	if removed > 0 {
+		logger.Debug("[oauth] 清理过期会话 %d 个", removed)
+	}
 }
 
 // getEffectiveProxy 获取有效代理配置（优先使用请��指定的，否则使用默认代理）
@@ -200,19 +231,16 @@ func (h *OAuthHandler) Exchange(c *gin.Context) {
 		return
 	}
 
-	// 获取会话
-	sessionData, ok := h.sessions.Load(req.SessionID)
+	// 获取并删除会话（一次性使用）
+	sessionData, ok := h.sessions.LoadAndDelete(req.SessionID)
 	if !ok {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的会话 ID"})
 		return
 	}
 	session := sessionData.(*OAuthSession)
 
-	// 删除会话（一次性使用）
-	h.sessions.Delete(req.SessionID)
-
 	// 检查会话是否过期（10分钟）
-	if time.Since(session.CreatedAt) > 10*time.Minute {
+	if time.Since(session.CreatedAt) > oauthSessionTTL {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "会话已过期"})
 		return
 	}
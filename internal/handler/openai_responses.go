@@ -3,10 +3,11 @@
  * 负责功能：
  *   - OpenAI Responses API 转发
  *   - Codex CLI 专用接口处理
+ *   - 非 Codex CLI 请求适配（字段裁剪和 instructions 可配置，见 responses_compat.go）
  *   - 流式/非流式响应转换
- *   - 模型映射和费用统计
+ *   - 模型映射和费用统计（含 reasoning token 单独计费）
  * 重要程度：⭐⭐⭐⭐ 重要（Codex CLI专用接口）
- * 依赖模块：scheduler, adapter, service, repository
+ * 依赖模块：scheduler, adapter, service, metrics, cache
  */
 package handler
 
@@ -16,6 +17,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"regexp"
@@ -23,10 +25,12 @@ import (
 	"strings"
 	"time"
 
+	"go-aiproxy/internal/cache"
+	"go-aiproxy/internal/metrics"
+	"go-aiproxy/internal/middleware"
 	"go-aiproxy/internal/model"
 	"go-aiproxy/internal/proxy/adapter"
 	"go-aiproxy/internal/proxy/scheduler"
-	"go-aiproxy/internal/repository"
 	"go-aiproxy/internal/service"
 	"go-aiproxy/pkg/logger"
 	"go-aiproxy/pkg/response"
@@ -40,12 +44,14 @@ type OpenAIResponsesHandler struct {
 	scheduler           *scheduler.Scheduler
 	usageService        *service.UsageService
 	pricingService      *service.PricingService
-	dailyUsageRepo      *repository.DailyUsageRepository
 	modelMappingService *service.ModelMappingService
 }
 
 // DefaultCodexInstructions 默认的 Codex CLI instructions
 // 参考 claude-relay 的 openaiRoutes.js
+// responseIDCtxKey 本次上游响应的 response.id（用于 previous_response_id 会话链路）
+const responseIDCtxKey = "openai_response_id"
+
 const DefaultCodexInstructions = `You are Codex, based on GPT-5. You are running as a coding agent in the Codex CLI on a user's computer.
 
 ## General
@@ -74,7 +80,6 @@ func NewOpenAIResponsesHandler() *OpenAIResponsesHandler {
 		scheduler:           scheduler.GetScheduler(),
 		usageService:        service.NewUsageService(),
 		pricingService:      service.NewPricingService(),
-		dailyUsageRepo:      repository.NewDailyUsageRepository(),
 		modelMappingService: service.NewModelMappingService(),
 	}
 }
@@ -105,10 +110,24 @@ func (h *OpenAIResponsesHandler) HandleResponses(c *gin.Context) {
 	// 读取原始请求体
 	rawBody, err := io.ReadAll(c.Request.Body)
 	if err != nil {
+		if middleware.HandleRequestTooLarge(c, err) {
+			return
+		}
 		response.CustomBadRequest(c, "failed to read request body")
 		return
 	}
 
+	// 内容审查（命中时拒绝或脱敏）
+	rawBody, ok := applyContentFilter(c, rawBody, service.ContentFormatOpenAI)
+	if !ok {
+		return
+	}
+
+	// 输出 token 上限（超限时改写或拒绝）
+	if rawBody, ok = applyMaxTokensLimit(c, rawBody, adapter.RequestFormatResponses); !ok {
+		return
+	}
+
 	// 解析请求体获取基本信息
 	var reqBody map[string]interface{}
 	if err := json.Unmarshal(rawBody, &reqBody); err != nil {
@@ -147,29 +166,9 @@ func (h *OpenAIResponsesHandler) HandleResponses(c *gin.Context) {
 	userAgent := c.GetHeader("User-Agent")
 	isCodexCLI := strings.HasPrefix(userAgent, "codex_vscode/") || strings.HasPrefix(userAgent, "codex_cli_rs/")
 
-	// 如果不是 Codex CLI 请求，进行适配（参考 claude-relay）
+	// 非 Codex CLI 请求强制流式，字段裁剪和 instructions 在选中账户后按账户配置适配
 	if !isCodexCLI {
-		log.Info("非 Codex CLI 请求，应用适配")
-
-		// 移除不需要的字段
-		fieldsToRemove := []string{"temperature", "top_p", "max_output_tokens", "user", "text_formatting", "truncation", "text", "service_tier"}
-		for _, field := range fieldsToRemove {
-			delete(reqBody, field)
-		}
-
-		// 设置默认的 Codex instructions
-		reqBody["instructions"] = DefaultCodexInstructions
-
-		// 确保 stream 为 true
-		reqBody["stream"] = true
 		isStream = true
-
-		// 重新序列化请求体
-		rawBody, err = json.Marshal(reqBody)
-		if err != nil {
-			response.CustomBadRequest(c, "failed to marshal request body")
-			return
-		}
 	}
 
 	// 获取请求路径
@@ -185,17 +184,55 @@ func (h *OpenAIResponsesHandler) HandleResponses(c *gin.Context) {
 	log.Info("会话哈希 - SessionID: %s", sessionID)
 
 	// 选择账户（支持 openai-responses 和 openai 两种类型，支持会话粘性）
+	// 与其他代理接口走同一套过滤：套餐分组、组织、强制指定账户、灰度、偏好 region
 	ctx := context.Background()
-	accountTypes := []string{model.AccountTypeOpenAIResponses, model.AccountTypeOpenAI}
-	account, err := h.scheduler.SelectAccountByTypesWithSession(ctx, accountTypes, modelName, sessionID, userID, apiKeyID)
+	account, err := h.createRetryRequest(c, sessionID).
+		WithAccountTypes(model.AccountTypeOpenAIResponses, model.AccountTypeOpenAI).
+		SelectAccount(ctx, model.PlatformOpenAI+","+modelName)
 	if err != nil {
 		log.Error("选择账户失败: %v", err)
+		metrics.ObserveProxyRequest(model.PlatformOpenAI, false, 0)
+		if errors.Is(err, scheduler.ErrSessionAccountUnavailable) {
+			response.CustomError(c, http.StatusConflict, model.ErrorTypeSessionAccountUnavailable, err.Error())
+			return
+		}
+		if errors.Is(err, scheduler.ErrForcedAccountUnavailable) {
+			response.CustomError(c, http.StatusBadRequest, model.ErrorTypeForcedAccountUnavailable, err.Error())
+			return
+		}
 		response.CustomError(c, http.StatusServiceUnavailable, "no_available_account", err.Error())
 		return
 	}
 
 	log.Info("选中账户 - ID: %d, Name: %s, BaseURL: %s", account.ID, account.Name, account.BaseURL)
 
+	// 每日请求上限：发起请求前占用当日请求数，上游未成功响应时退回
+	if !h.scheduler.ReserveDailyRequest(account) {
+		metrics.ObserveProxyRequest(model.PlatformOpenAI, false, 0)
+		response.CustomError(c, http.StatusServiceUnavailable, "no_available_account", "account daily request limit reached")
+		return
+	}
+	upstreamOK := false
+	defer func() {
+		if !upstreamOK {
+			h.scheduler.ReleaseDailyRequest(account)
+		}
+	}()
+
+	// 如果不是 Codex CLI 请求，按账户（或全局）配置进行适配（参考 claude-relay）
+	if !isCodexCLI {
+		rule := responsesCompatRuleFor(account)
+		log.Info("非 Codex CLI 请求，应用适配 - 删除字段: %v, Instructions: %s", rule.stripFields, rule.instructions)
+		applyResponsesCompat(reqBody, rule)
+
+		// 重新序列化请求体
+		rawBody, err = json.Marshal(reqBody)
+		if err != nil {
+			response.CustomBadRequest(c, "failed to marshal request body")
+			return
+		}
+	}
+
 	// 构建目标 URL: baseURL + path
 	// 参考 claude-relay: const targetUrl = `${fullAccount.baseApi}${req.path}`
 	baseURL := account.BaseURL
@@ -213,8 +250,16 @@ func (h *OpenAIResponsesHandler) HandleResponses(c *gin.Context) {
 
 	log.Info("转发目标 - TargetURL: %s", targetURL)
 
+	// 请求超时（X-Request-Timeout）的截止时间传递到上游请求
+	upstreamCtx := ctx
+	if deadline, ok := c.Request.Context().Deadline(); ok {
+		var cancel context.CancelFunc
+		upstreamCtx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+
 	// 创建 HTTP 请求
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", targetURL, bytes.NewReader(rawBody))
+	httpReq, err := http.NewRequestWithContext(upstreamCtx, "POST", targetURL, bytes.NewReader(rawBody))
 	if err != nil {
 		log.Error("创建请求失败: %v", err)
 		response.CustomError(c, http.StatusInternalServerError, "internal_error", err.Error())
@@ -222,7 +267,8 @@ func (h *OpenAIResponsesHandler) HandleResponses(c *gin.Context) {
 	}
 
 	// 设置请求头
-	h.setRequestHeaders(httpReq, c, account)
+	authKind := h.setRequestHeaders(httpReq, c, account)
+	log.Info("认证凭证 - Kind: %s", authKind)
 
 	// 发送请求 - 流式请求使用流式客户端（10分钟超时）
 	var client *http.Client
@@ -231,48 +277,63 @@ func (h *OpenAIResponsesHandler) HandleResponses(c *gin.Context) {
 	} else {
 		client = adapter.GetHTTPClient(account)
 	}
+	upstreamStart := time.Now()
 	resp, err := client.Do(httpReq)
 	if err != nil {
 		log.Error("请求失败 - 网络错误: %v", err)
+		metrics.ObserveProxyRequest(account.Platform, false, 1)
+		if errors.Is(upstreamCtx.Err(), context.DeadlineExceeded) {
+			response.CustomError(c, http.StatusGatewayTimeout, model.ErrorTypeRequestTimeout, err.Error())
+			return
+		}
 		response.CustomError(c, http.StatusBadGateway, "upstream_error", err.Error())
 		return
 	}
 	defer resp.Body.Close()
+	// 上游压缩的流先解压，SSE 解析和转发只处理明文
+	adapter.DecodeResponseBody(resp)
 
 	// 处理错误响应
 	if resp.StatusCode != http.StatusOK {
+		metrics.ObserveUpstreamLatency(account.Platform, isStream, time.Since(upstreamStart))
+		metrics.ObserveProxyRequest(account.Platform, false, 1)
 		h.handleErrorResponse(c, resp, account, log)
 		return
 	}
 
+	upstreamOK = true
+
+	// 记录上游限流窗口（x-ratelimit-* 响应头）
+	updateRateLimitWindow(account.ID, adapter.ExtractRateLimitHeaders(resp.Header))
+
 	// 记录开始时间
 	startTime := time.Now()
 
 	// 处理响应
 	if isStream {
-		h.handleStreamResponse(c, resp, account, userID, apiKeyID, modelName, log)
+		h.handleStreamResponse(c, resp, account, userID, apiKeyID, modelName, rawBody, log)
 	} else {
 		h.handleNormalResponse(c, resp, account, userID, apiKeyID, modelName, log)
 	}
 
+	// 记录本轮响应 ID 所属会话，下一轮通过 previous_response_id 沿用
+	if responseID := c.GetString(responseIDCtxKey); responseID != "" {
+		cache.GetResponseSessionStore().Set(responseID, sessionID)
+	}
+	metrics.ObserveUpstreamLatency(account.Platform, isStream, time.Since(upstreamStart))
+	metrics.ObserveProxyRequest(account.Platform, true, 1)
+
 	log.Info("请求完成 - 耗时: %v", time.Since(startTime))
 }
 
-// setRequestHeaders 设置请求头
-func (h *OpenAIResponsesHandler) setRequestHeaders(httpReq *http.Request, c *gin.Context, account *model.Account) {
+// setRequestHeaders 设置请求头，返回本次使用的认证凭证类型
+func (h *OpenAIResponsesHandler) setRequestHeaders(httpReq *http.Request, c *gin.Context, account *model.Account) string {
 	// 基本头部
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("Accept", "text/event-stream")
 
-	// 认证令牌优先级: SessionKey > AccessToken > APIKey
-	authToken := ""
-	if account.SessionKey != "" {
-		authToken = account.SessionKey
-	} else if account.AccessToken != "" {
-		authToken = account.AccessToken
-	} else if account.APIKey != "" {
-		authToken = account.APIKey
-	}
+	// 认证令牌与健康检查共用同一选择逻辑
+	authToken, authKind := account.GetAuthToken()
 	httpReq.Header.Set("Authorization", "Bearer "+authToken)
 
 	// 透传客户端头部
@@ -299,6 +360,7 @@ func (h *OpenAIResponsesHandler) setRequestHeaders(httpReq *http.Request, c *gin
 			httpReq.Header.Set("chatgpt-account-id", account.OrganizationID)
 		}
 	}
+	return authKind
 }
 
 // handleErrorResponse 处理错误响应
@@ -320,7 +382,10 @@ func (h *OpenAIResponsesHandler) handleErrorResponse(c *gin.Context, resp *http.
 // handleStreamResponse 处理流式响应
 // 参考 claude-relay: openaiResponsesRelayService._handleStreamResponse
 // 直接转发原始字节流，同时解析 usage 数据
-func (h *OpenAIResponsesHandler) handleStreamResponse(c *gin.Context, resp *http.Response, account *model.Account, userID, apiKeyID uint, modelName string, log *logger.Logger) {
+func (h *OpenAIResponsesHandler) handleStreamResponse(c *gin.Context, resp *http.Response, account *model.Account, userID, apiKeyID uint, modelName string, rawBody []byte, log *logger.Logger) {
+	// 登记为进行中的流式请求（优雅关闭时等待其结束）
+	defer beginStream()()
+
 	// 设置 SSE 响应头
 	c.Header("Content-Type", "text/event-stream")
 	c.Header("Cache-Control", "no-cache")
@@ -330,6 +395,16 @@ func (h *OpenAIResponsesHandler) handleStreamResponse(c *gin.Context, resp *http
 	// 立即刷新头部
 	c.Writer.Flush()
 
+	// 上游长时间无输出时发送 SSE 心跳
+	keepAliveWriter := newStreamKeepAliveWriter(c.Writer)
+	defer keepAliveWriter.Stop()
+
+	// 客户端要求隐藏思考内容时过滤 reasoning 事件（usage 仍从上游原始数据解析）
+	streamWriter, closeFilter := wrapThinkingFilter(c, keepAliveWriter, adapter.StreamFormatResponses)
+
+	// 上游未返回 usage 时按转发的响应文本估算输出 token
+	streamWriter, estimator := wrapUsageEstimator(streamWriter, adapter.StreamFormatResponses, modelName)
+
 	// 获取倍率
 	priceRate := 1.0
 	if rate, ok := c.Get("api_key_price_rate"); ok {
@@ -339,8 +414,9 @@ func (h *OpenAIResponsesHandler) handleStreamResponse(c *gin.Context, resp *http
 	}
 
 	var inputTokens, outputTokens int
-	var cacheReadTokens, cacheCreationTokens int
-	var actualModel string
+	var cacheReadTokens, cacheCreationTokens, reasoningTokens int
+	var actualModel, responseID string
+	var completed bool // 是否收到正常终止事件，未收到视为疑似截断
 	var buffer strings.Builder
 
 	ctx := c.Request.Context()
@@ -381,16 +457,16 @@ func (h *OpenAIResponsesHandler) handleStreamResponse(c *gin.Context, resp *http
 			}
 
 			// 转发给客户端
-			_, writeErr := c.Writer.Write(chunk)
+			_, writeErr := streamWriter.Write(chunk)
 			if writeErr != nil {
 				log.Warn("OpenAI Responses Stream 写入客户端失败: %v", writeErr)
 				goto done
 			}
-			c.Writer.Flush()
+			keepAliveWriter.Flush()
 
 			// 同时解析 usage 数据（解析原始数据，不是修改后的）
 			buffer.Write(buf[:n])
-			h.parseSSEForUsage(&buffer, &actualModel, &inputTokens, &outputTokens, &cacheReadTokens, &cacheCreationTokens, log)
+			h.parseSSEForUsage(&buffer, &actualModel, &responseID, &inputTokens, &outputTokens, &cacheReadTokens, &cacheCreationTokens, &reasoningTokens, &completed, log)
 		}
 
 		if err != nil {
@@ -407,9 +483,15 @@ func (h *OpenAIResponsesHandler) handleStreamResponse(c *gin.Context, resp *http
 	}
 
 done:
+	closeFilter()
+
 	// 处理剩余 buffer
 	if buffer.Len() > 0 {
-		h.parseSSEForUsage(&buffer, &actualModel, &inputTokens, &outputTokens, &cacheReadTokens, &cacheCreationTokens, log)
+		h.parseSSEForUsage(&buffer, &actualModel, &responseID, &inputTokens, &outputTokens, &cacheReadTokens, &cacheCreationTokens, &reasoningTokens, &completed, log)
+	}
+
+	if responseID != "" {
+		c.Set(responseIDCtxKey, responseID)
 	}
 
 	// 记录使用量
@@ -417,27 +499,42 @@ done:
 		actualModel = modelName
 	}
 
-	// 应用倍率到 token（用于日志记录和费用计算）
+	// 上游未返回 usage 时用本地估算值兜底
+	usage := &adapter.StreamResult{
+		InputTokens:              inputTokens,
+		OutputTokens:             outputTokens,
+		CacheReadInputTokens:     cacheReadTokens,
+		CacheCreationInputTokens: cacheCreationTokens,
+	}
+	applyUsageEstimate(usage, estimator, actualModel, rawBody)
+	inputTokens, outputTokens = usage.InputTokens, usage.OutputTokens
+
+	// 应用倍率到 token（用于日志输出）
 	ratedInputTokens := int(float64(inputTokens) * priceRate)
 	ratedOutputTokens := int(float64(outputTokens) * priceRate)
-	ratedCacheReadTokens := int(float64(cacheReadTokens) * priceRate)
-	ratedCacheCreationTokens := int(float64(cacheCreationTokens) * priceRate)
 
-	log.Info("Stream 完成 - Model: %s, 原始Token(in:%d/out:%d), 倍率:%.2f, 计费Token(in:%d/out:%d)",
-		actualModel, inputTokens, outputTokens, priceRate, ratedInputTokens, ratedOutputTokens)
+	log.Info("Stream 完成 - Model: %s, 原始Token(in:%d/out:%d/reasoning:%d), 倍率:%.2f, 计费Token(in:%d/out:%d)",
+		actualModel, inputTokens, outputTokens, reasoningTokens, priceRate, ratedInputTokens, ratedOutputTokens)
 
 	// 标记账户成功（更新 last_used_at 和 request_count）
-	h.scheduler.MarkAccountSuccess(account.ID)
+	// 疑似截断不标记成功，避免掩盖账户问题
+	truncated := !completed
+	if truncated {
+		log.Warn("Stream 疑似截断，未收到正常终止事件 - AccountID: %d, Model: %s", account.ID, actualModel)
+	} else {
+		h.scheduler.MarkAccountSuccess(account.ID)
+	}
 
-	// 记录使用统计（使用倍率后的 token）
+	// 记录使用统计（传入原始 token，recordUsage 内应用倍率）
 	if ratedInputTokens > 0 || ratedOutputTokens > 0 {
-		h.recordUsage(c, userID, apiKeyID, account.ID, actualModel, ratedInputTokens, ratedOutputTokens, ratedCacheReadTokens, ratedCacheCreationTokens)
+		c.Set(accountRegionCtxKey, account.Region)
+		h.recordUsage(c, userID, apiKeyID, account.ID, actualModel, inputTokens, outputTokens, cacheReadTokens, cacheCreationTokens, reasoningTokens, truncated, usage.Estimated)
 	}
 }
 
-// parseSSEForUsage 从 SSE 数据中解析 usage 信息
+// parseSSEForUsage 从 SSE 数据中解析 usage 信息，收到 [DONE] / response.completed / response.incomplete 时置 completed
 // 参考 claude-relay: openaiResponsesRelayService 的 usage 解析
-func (h *OpenAIResponsesHandler) parseSSEForUsage(buffer *strings.Builder, actualModel *string, inputTokens, outputTokens, cacheReadTokens, cacheCreationTokens *int, log *logger.Logger) {
+func (h *OpenAIResponsesHandler) parseSSEForUsage(buffer *strings.Builder, actualModel, responseID *string, inputTokens, outputTokens, cacheReadTokens, cacheCreationTokens, reasoningTokens *int, completed *bool, log *logger.Logger) {
 	data := buffer.String()
 
 	// 查找完整的 SSE 事件（以 \n\n 分隔）
@@ -456,6 +553,7 @@ func (h *OpenAIResponsesHandler) parseSSEForUsage(buffer *strings.Builder, actua
 			if strings.HasPrefix(line, "data: ") {
 				jsonStr := strings.TrimPrefix(line, "data: ")
 				if jsonStr == "[DONE]" {
+					*completed = true
 					continue
 				}
 
@@ -464,13 +562,21 @@ func (h *OpenAIResponsesHandler) parseSSEForUsage(buffer *strings.Builder, actua
 					continue
 				}
 
+				eventType, _ := eventData["type"].(string)
+				if eventType == "response.completed" || eventType == "response.incomplete" {
+					*completed = true
+				}
+
 				// 检查 response.completed 事件
-				if eventType, ok := eventData["type"].(string); ok && eventType == "response.completed" {
+				if eventType == "response.completed" {
 					if resp, ok := eventData["response"].(map[string]interface{}); ok {
 						if m, ok := resp["model"].(string); ok {
 							*actualModel = m
 							log.Debug("捕获实际模型: %s", m)
 						}
+						if id, ok := resp["id"].(string); ok {
+							*responseID = id
+						}
 						if usage, ok := resp["usage"].(map[string]interface{}); ok {
 							// 基础 token
 							if it, ok := usage["input_tokens"].(float64); ok {
@@ -501,8 +607,11 @@ func (h *OpenAIResponsesHandler) parseSSEForUsage(buffer *strings.Builder, actua
 								*cacheCreationTokens = int(cct)
 							}
 
-							log.Debug("捕获 usage: input=%d, output=%d, cacheRead=%d, cacheCreation=%d",
-								*inputTokens, *outputTokens, *cacheReadTokens, *cacheCreationTokens)
+							// reasoning token（o 系列 / gpt-5），包含在 output_tokens 中
+							*reasoningTokens = parseReasoningTokens(usage)
+
+							log.Debug("捕获 usage: input=%d, output=%d, cacheRead=%d, cacheCreation=%d, reasoning=%d",
+								*inputTokens, *outputTokens, *cacheReadTokens, *cacheCreationTokens, *reasoningTokens)
 						}
 					}
 				}
@@ -515,11 +624,25 @@ func (h *OpenAIResponsesHandler) parseSSEForUsage(buffer *strings.Builder, actua
 	buffer.WriteString(data)
 }
 
+// parseReasoningTokens 从 usage.output_tokens_details.reasoning_tokens 解析推理 token
+func parseReasoningTokens(usage map[string]interface{}) int {
+	if details, ok := usage["output_tokens_details"].(map[string]interface{}); ok {
+		if rt, ok := details["reasoning_tokens"].(float64); ok {
+			return int(rt)
+		}
+	}
+	return 0
+}
+
 // handleNormalResponse 处理非流式响应
 func (h *OpenAIResponsesHandler) handleNormalResponse(c *gin.Context, resp *http.Response, account *model.Account, userID, apiKeyID uint, modelName string, log *logger.Logger) {
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
 		log.Error("读取响应失败: %v", err)
+		if errors.Is(c.Request.Context().Err(), context.DeadlineExceeded) {
+			response.CustomError(c, http.StatusGatewayTimeout, model.ErrorTypeRequestTimeout, err.Error())
+			return
+		}
 		response.CustomError(c, http.StatusBadGateway, "upstream_error", err.Error())
 		return
 	}
@@ -535,13 +658,16 @@ func (h *OpenAIResponsesHandler) handleNormalResponse(c *gin.Context, resp *http
 	// 解析响应获取 usage
 	var respData map[string]interface{}
 	var inputTokens, outputTokens int
-	var cacheReadTokens, cacheCreationTokens int
+	var cacheReadTokens, cacheCreationTokens, reasoningTokens int
 	var actualModel string
 
 	if err := json.Unmarshal(respBody, &respData); err == nil {
 		if m, ok := respData["model"].(string); ok {
 			actualModel = m
 		}
+		if id, ok := respData["id"].(string); ok && id != "" {
+			c.Set(responseIDCtxKey, id)
+		}
 		if usage, ok := respData["usage"].(map[string]interface{}); ok {
 			if it, ok := usage["input_tokens"].(float64); ok {
 				inputTokens = int(it)
@@ -565,6 +691,7 @@ func (h *OpenAIResponsesHandler) handleNormalResponse(c *gin.Context, resp *http
 			if cct, ok := usage["cache_creation_input_tokens"].(float64); ok && cacheCreationTokens == 0 {
 				cacheCreationTokens = int(cct)
 			}
+			reasoningTokens = parseReasoningTokens(usage)
 
 			// 如果倍率不为1，修改响应中的 token 数量
 			if priceRate != 1.0 {
@@ -579,21 +706,20 @@ func (h *OpenAIResponsesHandler) handleNormalResponse(c *gin.Context, resp *http
 		actualModel = modelName
 	}
 
-	// 应用倍率到 token（用于日志记录和费用计算）
+	// 应用倍率到 token（用于日志输出）
 	ratedInputTokens := int(float64(inputTokens) * priceRate)
 	ratedOutputTokens := int(float64(outputTokens) * priceRate)
-	ratedCacheReadTokens := int(float64(cacheReadTokens) * priceRate)
-	ratedCacheCreationTokens := int(float64(cacheCreationTokens) * priceRate)
 
-	log.Info("非流式响应 - Model: %s, 原始Token(in:%d/out:%d), 倍率:%.2f, 计费Token(in:%d/out:%d)",
-		actualModel, inputTokens, outputTokens, priceRate, ratedInputTokens, ratedOutputTokens)
+	log.Info("非流式响应 - Model: %s, 原始Token(in:%d/out:%d/reasoning:%d), 倍率:%.2f, 计费Token(in:%d/out:%d)",
+		actualModel, inputTokens, outputTokens, reasoningTokens, priceRate, ratedInputTokens, ratedOutputTokens)
 
 	// 标记账户成功（更新 last_used_at 和 request_count）
 	h.scheduler.MarkAccountSuccess(account.ID)
 
-	// 记录使用统计（使用倍率后的 token）
+	// 记录使用统计（传入原始 token，recordUsage 内应用倍率）
 	if ratedInputTokens > 0 || ratedOutputTokens > 0 {
-		h.recordUsage(c, userID, apiKeyID, account.ID, actualModel, ratedInputTokens, ratedOutputTokens, ratedCacheReadTokens, ratedCacheCreationTokens)
+		c.Set(accountRegionCtxKey, account.Region)
+		h.recordUsage(c, userID, apiKeyID, account.ID, actualModel, inputTokens, outputTokens, cacheReadTokens, cacheCreationTokens, reasoningTokens, false, false)
 	}
 
 	// 返回响应（已应用倍率）
@@ -602,7 +728,7 @@ func (h *OpenAIResponsesHandler) handleNormalResponse(c *gin.Context, resp *http
 
 // applyRateToUsageMap 将倍率应用到 usage map 中的 token 字段
 func (h *OpenAIResponsesHandler) applyRateToUsageMap(usage map[string]interface{}, rate float64) {
-	tokenFields := []string{"input_tokens", "output_tokens", "cached_tokens", "cache_creation_input_tokens", "cache_read_input_tokens", "total_tokens"}
+	tokenFields := []string{"input_tokens", "output_tokens", "cached_tokens", "cache_creation_input_tokens", "cache_read_input_tokens", "reasoning_tokens", "total_tokens"}
 	for _, field := range tokenFields {
 		if val, ok := usage[field].(float64); ok {
 			usage[field] = int(val * rate)
@@ -629,6 +755,7 @@ func (h *OpenAIResponsesHandler) applyRateToSSEChunk(chunk []byte, rate float64)
 		"cached_tokens",
 		"cache_creation_input_tokens",
 		"cache_read_input_tokens",
+		"reasoning_tokens",
 		"total_tokens",
 	}
 
@@ -651,10 +778,15 @@ func (h *OpenAIResponsesHandler) applyRateToSSEChunk(chunk []byte, rate float64)
 }
 
 // recordUsage 记录使用量到 Redis 和 MySQL
-func (h *OpenAIResponsesHandler) recordUsage(c *gin.Context, userID, apiKeyID, accountID uint, modelName string, inputTokens, outputTokens, cacheReadTokens, cacheCreationTokens int) {
-	log := logger.GetLogger("openai-responses")
-	log.Info("Usage - User: %d, APIKey: %d, Account: %d, Model: %s, Input: %d, Output: %d, CacheRead: %d, CacheCreation: %d",
-		userID, apiKeyID, accountID, modelName, inputTokens, outputTokens, cacheReadTokens, cacheCreationTokens)
+// token 参数为上游返回的原始 token：用户计费使用倍率后的 token，账户成本使用原始 token
+// reasoningTokens 为推理 token（包含在 outputTokens 中），按模型的思考价格单独计费
+// truncated 为流式响应疑似截断，按配置的截断计费系数计费
+// estimated 为 token 是本地估算值（上游未返回 usage），按配置决定是否计费
+func (h *OpenAIResponsesHandler) recordUsage(c *gin.Context, userID, apiKeyID, accountID uint, modelName string, inputTokens, outputTokens, cacheReadTokens, cacheCreationTokens, reasoningTokens int, truncated, estimated bool) {
+	log := logger.GetLogger("openai-responses").Ctx(c.Request.Context())
+	requestID := c.GetString(middleware.RequestIDCtxKey)
+	log.Info("Usage - User: %d, APIKey: %d, Account: %d, Model: %s, Input: %d, Output: %d, CacheRead: %d, CacheCreation: %d, Reasoning: %d",
+		userID, apiKeyID, accountID, modelName, inputTokens, outputTokens, cacheReadTokens, cacheCreationTokens, reasoningTokens)
 
 	ctx := context.Background()
 
@@ -671,6 +803,9 @@ func (h *OpenAIResponsesHandler) recordUsage(c *gin.Context, userID, apiKeyID, a
 	ratedOutputTokens := int(float64(outputTokens) * priceRate)
 	ratedCacheCreationTokens := int(float64(cacheCreationTokens) * priceRate)
 	ratedCacheReadTokens := int(float64(cacheReadTokens) * priceRate)
+	ratedReasoningTokens := int(float64(reasoningTokens) * priceRate)
+	// 指标记录上游原始 token 数，倍率只影响计费
+	metrics.AddTokens(model.PlatformOpenAI, inputTokens, outputTokens, cacheCreationTokens, cacheReadTokens)
 
 	// 计算费用（使用倍率后的 token）
 	tokenUsage := &service.TokenUsage{
@@ -678,13 +813,35 @@ func (h *OpenAIResponsesHandler) recordUsage(c *gin.Context, userID, apiKeyID, a
 		OutputTokens:             ratedOutputTokens,
 		CacheReadInputTokens:     ratedCacheReadTokens,
 		CacheCreationInputTokens: ratedCacheCreationTokens,
+		ThinkingTokens:           ratedReasoningTokens,
+		ContextTokens:            inputTokens + cacheCreationTokens + cacheReadTokens,
 	}
-	costBreakdown, err := h.pricingService.CalculateCost(ctx, modelName, tokenUsage, 1.0) // 倍率已应用到token，这里用1.0
+	costRate := 1.0 // 倍率已应用到 token，这里只叠加截断系数
+	if truncated {
+		costRate = service.GetConfigService().GetStreamTruncatedPriceRatio()
+	}
+	if estimated && !service.GetConfigService().GetTokenEstimateBilling() {
+		log.Warn("上游未返回 usage，估算 token 不计费 - Model: %s, Input: %d, Output: %d", modelName, inputTokens, outputTokens)
+		costRate = 0
+	}
+	costBreakdown, err := h.pricingService.CalculateCost(ctx, modelName, tokenUsage, costRate)
 	if err != nil {
 		log.Error("计算费用失败: %v", err)
 		costBreakdown = &service.CostBreakdown{}
 	}
 
+	// 账户成本使用原始 token（付给上游的费用，不受用户倍率和截断系数影响）
+	accountCost, err := h.pricingService.CalculateAccountCost(ctx, modelName, &service.TokenUsage{
+		InputTokens:              inputTokens,
+		OutputTokens:             outputTokens,
+		CacheReadInputTokens:     cacheReadTokens,
+		CacheCreationInputTokens: cacheCreationTokens,
+		ThinkingTokens:           reasoningTokens,
+	}, false)
+	if err != nil {
+		log.Error("计算账户成本失败: %v", err)
+	}
+
 	// 使用辅助函数构建请求日志
 	requestLog := BuildRequestLog(
 		accountID,
@@ -697,6 +854,14 @@ func (h *OpenAIResponsesHandler) recordUsage(c *gin.Context, userID, apiKeyID, a
 		c.GetHeader("User-Agent"),
 		"",
 	)
+	requestLog.Region = c.GetString(accountRegionCtxKey)
+	requestLog.RequestID = requestID
+	requestLog.LongContext = costBreakdown.LongContext
+	requestLog.ThinkingTokens = ratedReasoningTokens
+	requestLog.ThinkingCost = costBreakdown.ThinkingCost
+	requestLog.Truncated = truncated
+	requestLog.TokensEstimated = estimated
+	requestLog.AccountCost = accountCost
 
 	// 设置用户信息
 	uid := userID
@@ -708,7 +873,10 @@ func (h *OpenAIResponsesHandler) recordUsage(c *gin.Context, userID, apiKeyID, a
 	CompleteLogFull(requestLog, true, 200, "",
 		ratedInputTokens, ratedOutputTokens, ratedCacheCreationTokens, ratedCacheReadTokens,
 		costBreakdown.InputCost, costBreakdown.OutputCost, costBreakdown.CacheCreateCost, costBreakdown.CacheReadCost,
-		0)
+		requestDuration(c))
+
+	// 脱敏后转发到外部日志系统
+	forwardRequestLog(requestID, requestLog)
 
 	// 记录到 Redis（倍率已应用，这里用 1.0）
 	if err := h.usageService.RecordRequest(ctx, userID, apiKeyID, requestLog, 1.0); err != nil {
@@ -721,32 +889,29 @@ func (h *OpenAIResponsesHandler) recordUsage(c *gin.Context, userID, apiKeyID, a
 		log.Error("记录模型使用统计失败: %v", err)
 	}
 
-	// 记录账户费用到 Redis
+	// 记录账户成本（原始 token 计算，与用户计费分开）
 	if accountID > 0 {
-		if err := h.usageService.IncrementAccountCost(ctx, accountID, costBreakdown.TotalCost); err != nil {
+		if err := h.usageService.IncrementAccountCost(ctx, accountID, accountCost); err != nil {
 			log.Error("记录账户费用失败: %v", err)
 		}
 	}
 
-	// 增量更新 MySQL 每日汇总（使用倍率后的 token）
-	dailyUsage := &model.DailyUsage{
-		RequestCount:             1,
-		InputTokens:              int64(ratedInputTokens),
-		OutputTokens:             int64(ratedOutputTokens),
-		CacheCreationInputTokens: int64(ratedCacheCreationTokens),
-		CacheReadInputTokens:     int64(ratedCacheReadTokens),
-		TotalTokens:              int64(ratedInputTokens + ratedOutputTokens),
-		InputCost:                costBreakdown.InputCost,
-		OutputCost:               costBreakdown.OutputCost,
-		CacheCreateCost:          costBreakdown.CacheCreateCost,
-		CacheReadCost:            costBreakdown.CacheReadCost,
-		TotalCost:                costBreakdown.TotalCost,
-	}
-	if err := h.dailyUsageRepo.IncrementUsage(userID, modelName, dailyUsage); err != nil {
-		log.Error("更新每日汇总失败: %v", err)
-	}
+	log.Info("使用记录已保存 - Cost: %.6f, AccountCost: %.6f", costBreakdown.TotalCost, accountCost)
+}
 
-	log.Info("使用记录已保存 - Cost: %.6f", costBreakdown.TotalCost)
+// createRetryRequest 创建账户选择请求，套餐分组、组织、强制指定账户等约束与 ProxyHandler.createRetryRequest 一致
+// sessionID 使用 Responses 自己的会话哈希（见 generateSessionHash）
+func (h *OpenAIResponsesHandler) createRetryRequest(c *gin.Context, sessionID string) *scheduler.RetryableRequest {
+	userID, apiKeyID := h.getUserInfo(c)
+	return scheduler.NewRetryableRequest(h.scheduler, nil).
+		WithSessionID(sessionID).
+		WithUserInfo(userID, apiKeyID, c.ClientIP(), c.GetHeader("User-Agent")).
+		WithPreferredRegion(getPreferredRegion(c)).
+		WithCanary(assignCanary(c, sessionID)).
+		WithStrictSession(isStrictSession(c)).
+		WithAccountGroups(getPackageAccountGroups(c)).
+		WithOrg(c.GetUint("api_key_org_id")).
+		WithForcedAccount(c.GetUint("force_account_id"))
 }
 
 // getUserInfo 获取用户信息
@@ -767,12 +932,25 @@ func (h *OpenAIResponsesHandler) getUserInfo(c *gin.Context) (userID, apiKeyID u
 // generateSessionHash 生成会话哈希，用于粘性会话保持
 // 参考 claude-relay 的 sessionHelper.js 实现
 // 优先级：
+//  0. 请求体中的 previous_response_id（多轮对话沿用整条链路的会话哈希）
 //  1. 客户端提供的 Session_id 请求头
 //  2. 请求体中的 instructions 字段（类似 system prompt）
 //  3. 第一条 input 消息内容
 func (h *OpenAIResponsesHandler) generateSessionHash(c *gin.Context, reqBody map[string]interface{}) string {
 	log := logger.GetLogger("openai-responses")
 
+	// 0. previous_response_id：上游保存了对话状态，整条链路必须落到同一账户
+	// 每轮响应 ID 都不同，先查上一轮响应所属的会话哈希；查不到（如重启后）再用 ID 本身的哈希
+	if prevID, ok := reqBody["previous_response_id"].(string); ok && prevID != "" {
+		if sessionID := cache.GetResponseSessionStore().Get(prevID); sessionID != "" {
+			log.Debug("使用 previous_response_id 所属会话: %s -> %s", prevID, sessionID)
+			return sessionID
+		}
+		log.Debug("使用 previous_response_id 生成哈希: %s", prevID)
+		hash := sha256.Sum256([]byte(prevID))
+		return hex.EncodeToString(hash[:])[:32]
+	}
+
 	// 1. 最高优先级：使用客户端提供的 Session_id 请求头
 	// 注意：Gin 的 GetHeader 不区分大小写，但请求头名称可能被规范化
 	sessionHeader := c.GetHeader("Session_id")
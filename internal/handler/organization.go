@@ -0,0 +1,131 @@
+/*
+ * 文件作用：组织（租户）管理处理器，仅超级管理员可用
+ * 负责功能：
+ *   - 组织列表查询
+ *   - 组织创建/更新/删除（仍有用户、账户、API Key 或套餐归属时拒绝删除）
+ * 重要程度：⭐⭐⭐ 一般（多租户管理）
+ * 依赖模块：repository, model
+ */
+package handler
+
+import (
+	"strconv"
+
+	"go-aiproxy/internal/model"
+	"go-aiproxy/internal/repository"
+	"go-aiproxy/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OrganizationHandler struct {
+	repo *repository.OrganizationRepository
+}
+
+func NewOrganizationHandler() *OrganizationHandler {
+	return &OrganizationHandler{
+		repo: repository.NewOrganizationRepository(),
+	}
+}
+
+// List 获取所有组织
+func (h *OrganizationHandler) List(c *gin.Context) {
+	orgs, err := h.repo.List()
+	if err != nil {
+		response.InternalError(c, "获取组织列表失败")
+		return
+	}
+	response.Success(c, orgs)
+}
+
+// Create 创建组织
+func (h *OrganizationHandler) Create(c *gin.Context) {
+	var req struct {
+		Name        string `json:"name" binding:"required"`
+		Description string `json:"description"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	org := &model.Organization{
+		Name:        req.Name,
+		Description: req.Description,
+	}
+	if err := h.repo.Create(org); err != nil {
+		response.BadRequest(c, "创建组织失败，名称可能已存在")
+		return
+	}
+
+	response.Created(c, org)
+}
+
+// Update 更新组织
+func (h *OrganizationHandler) Update(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的组织 ID")
+		return
+	}
+
+	org, err := h.repo.GetByID(uint(id))
+	if err != nil {
+		response.NotFound(c, "组织不存在")
+		return
+	}
+
+	var req struct {
+		Name        string  `json:"name"`
+		Description *string `json:"description"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "参数错误")
+		return
+	}
+
+	if req.Name != "" {
+		org.Name = req.Name
+	}
+	if req.Description != nil {
+		org.Description = *req.Description
+	}
+
+	if err := h.repo.Update(org); err != nil {
+		response.BadRequest(c, "更新组织失败，名称可能已存在")
+		return
+	}
+
+	response.Success(c, org)
+}
+
+// Delete 删除组织（组织下仍有数据时拒绝，避免数据归属到不存在的组织）
+func (h *OrganizationHandler) Delete(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的组织 ID")
+		return
+	}
+
+	if _, err := h.repo.GetByID(uint(id)); err != nil {
+		response.NotFound(c, "组织不存在")
+		return
+	}
+
+	count, err := h.repo.CountMembers(uint(id))
+	if err != nil {
+		response.InternalError(c, "检查组织数据失败")
+		return
+	}
+	if count > 0 {
+		response.BadRequest(c, "组织下仍有用户、账户、API Key 或套餐，请先迁移或删除")
+		return
+	}
+
+	if err := h.repo.Delete(uint(id)); err != nil {
+		response.InternalError(c, "删除组织失败")
+		return
+	}
+
+	response.Success(c, nil)
+}
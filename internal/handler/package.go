@@ -5,12 +5,14 @@
  *   - 用户套餐分配和管理
  *   - 用户可用套餐查询
  *   - 套餐状态管理（有效、过期）
+ *   - 套餐按组织隔离（组织管理员只管理本组织套餐，用户只能看到本组织套餐）
  * 重要程度：⭐⭐⭐ 一般（套餐功能）
  * 依赖模块：repository, model
  */
 package handler
 
 import (
+	"go-aiproxy/internal/middleware"
 	"go-aiproxy/internal/model"
 	"go-aiproxy/internal/repository"
 	"go-aiproxy/pkg/response"
@@ -34,11 +36,16 @@ func NewPackageHandler() *PackageHandler {
 	}
 }
 
+// orgPackages 返回限定为当前管理员组织的套餐仓库（超级管理员不限制）
+func (h *PackageHandler) orgPackages(c *gin.Context) *repository.PackageRepository {
+	return h.packageRepo.WithOrg(middleware.GetOrgID(c))
+}
+
 // ========== 套餐模板管理 (管理员) ==========
 
 // ListPackages 获取所有套餐
 func (h *PackageHandler) ListPackages(c *gin.Context) {
-	packages, err := h.packageRepo.GetAll()
+	packages, err := h.orgPackages(c).GetAll()
 	if err != nil {
 		response.InternalError(c, "获取套餐列表失败")
 		return
@@ -58,6 +65,9 @@ func (h *PackageHandler) CreatePackage(c *gin.Context) {
 		MonthlyQuota  float64 `json:"monthly_quota"`  // 订阅类型：每月额度
 		QuotaAmount   float64 `json:"quota_amount"`   // 额度类型：总额度
 		AllowedModels string  `json:"allowed_models"` // 允许的模型
+		Priority      int     `json:"priority"`       // 调度优先级
+		AccountGroups string  `json:"account_groups"` // 可调度的账户分组 ID（逗号分隔）
+		OrgID         uint    `json:"org_id"`         // 所属组织（仅超级管理员可指定）
 		Description   string  `json:"description"`
 	}
 
@@ -76,11 +86,14 @@ func (h *PackageHandler) CreatePackage(c *gin.Context) {
 		MonthlyQuota:  req.MonthlyQuota,
 		QuotaAmount:   req.QuotaAmount,
 		AllowedModels: req.AllowedModels,
+		Priority:      req.Priority,
+		AccountGroups: req.AccountGroups,
+		OrgID:         req.OrgID,
 		Description:   req.Description,
 		Status:        "active",
 	}
 
-	if err := h.packageRepo.Create(pkg); err != nil {
+	if err := h.orgPackages(c).Create(pkg); err != nil {
 		response.InternalError(c, "创建套餐失败")
 		return
 	}
@@ -92,7 +105,8 @@ func (h *PackageHandler) CreatePackage(c *gin.Context) {
 func (h *PackageHandler) UpdatePackage(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
 
-	pkg, err := h.packageRepo.GetByID(uint(id))
+	packageRepo := h.orgPackages(c)
+	pkg, err := packageRepo.GetByID(uint(id))
 	if err != nil {
 		response.NotFound(c, "套餐不存在")
 		return
@@ -107,6 +121,9 @@ func (h *PackageHandler) UpdatePackage(c *gin.Context) {
 		MonthlyQuota  *float64 `json:"monthly_quota"`
 		QuotaAmount   *float64 `json:"quota_amount"`
 		AllowedModels *string  `json:"allowed_models"`
+		Priority      *int     `json:"priority"`
+		AccountGroups *string  `json:"account_groups"`
+		OrgID         *uint    `json:"org_id"`
 		Description   string   `json:"description"`
 		Status        string   `json:"status"`
 	}
@@ -140,6 +157,16 @@ func (h *PackageHandler) UpdatePackage(c *gin.Context) {
 	if req.AllowedModels != nil {
 		pkg.AllowedModels = *req.AllowedModels
 	}
+	if req.Priority != nil {
+		pkg.Priority = *req.Priority
+	}
+	if req.AccountGroups != nil {
+		pkg.AccountGroups = *req.AccountGroups
+	}
+	// 只有超级管理员可以调整套餐所属组织
+	if req.OrgID != nil && middleware.GetOrgID(c) == 0 {
+		pkg.OrgID = *req.OrgID
+	}
 	if req.Description != "" {
 		pkg.Description = req.Description
 	}
@@ -147,7 +174,7 @@ func (h *PackageHandler) UpdatePackage(c *gin.Context) {
 		pkg.Status = req.Status
 	}
 
-	if err := h.packageRepo.Update(pkg); err != nil {
+	if err := packageRepo.Update(pkg); err != nil {
 		response.InternalError(c, "更新套餐失败")
 		return
 	}
@@ -159,7 +186,7 @@ func (h *PackageHandler) UpdatePackage(c *gin.Context) {
 func (h *PackageHandler) DeletePackage(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
 
-	if err := h.packageRepo.Delete(uint(id)); err != nil {
+	if err := h.orgPackages(c).Delete(uint(id)); err != nil {
 		response.InternalError(c, "删除套餐失败")
 		return
 	}
@@ -195,8 +222,8 @@ func (h *PackageHandler) AssignPackage(c *gin.Context) {
 		return
 	}
 
-	// 获取套餐模板
-	pkg, err := h.packageRepo.GetByID(req.PackageID)
+	// 获取套餐模板（组织管理员只能分配本组织的套餐）
+	pkg, err := h.orgPackages(c).GetByID(req.PackageID)
 	if err != nil {
 		response.NotFound(c, "套餐不存在")
 		return
@@ -360,9 +387,9 @@ func (h *PackageHandler) GetMyActivePackages(c *gin.Context) {
 	response.Success(c, packages)
 }
 
-// GetAvailablePackages 获取可购买的套餐列表
+// GetAvailablePackages 获取可购买的套餐列表（只返回用户所在组织的套餐）
 func (h *PackageHandler) GetAvailablePackages(c *gin.Context) {
-	packages, err := h.packageRepo.GetActive()
+	packages, err := h.packageRepo.GetActiveByOrg(middleware.GetOrgID(c))
 	if err != nil {
 		response.InternalError(c, "获取套餐列表失败")
 		return
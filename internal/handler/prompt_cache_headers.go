@@ -0,0 +1,44 @@
+/*
+ * 文件作用：Claude prompt caching 统计透传，通过响应头告知客户端缓存写入/命中的 token 数
+ * 负责功能：
+ *   - 从解析到的 usage 取缓存写入、缓存命中 token，应用 API Key 倍率后写入响应头
+ *   - 非流式直接设置响应头；流式响应头已在首字节前发送，改用 HTTP Trailer
+ *   - 只透传统计，不影响计费口径
+ * 重要程度：⭐⭐ 辅助（客户端缓存监控）
+ * 依赖模块：无
+ */
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// prompt caching 统计响应头（倍率后的 token 数）
+const (
+	CacheCreationTokensHeader = "X-Cache-Creation-Tokens"
+	CacheReadTokensHeader     = "X-Cache-Read-Tokens"
+)
+
+// setPromptCacheHeaders 把倍率后的缓存写入/命中 token 数写入响应头
+func setPromptCacheHeaders(c *gin.Context, cacheCreationTokens, cacheReadTokens int) {
+	// 获取倍率（由中间件设置）
+	priceRate := 1.0
+	if rate, ok := c.Get("api_key_price_rate"); ok {
+		if r, ok := rate.(float64); ok {
+			priceRate = r
+		}
+	}
+	creation := strconv.Itoa(int(float64(cacheCreationTokens) * priceRate))
+	read := strconv.Itoa(int(float64(cacheReadTokens) * priceRate))
+	if c.Writer.Written() {
+		// 流式响应头已发送，改用 HTTP Trailer 告知
+		c.Writer.Header().Set(http.TrailerPrefix+CacheCreationTokensHeader, creation)
+		c.Writer.Header().Set(http.TrailerPrefix+CacheReadTokensHeader, read)
+		return
+	}
+	c.Header(CacheCreationTokensHeader, creation)
+	c.Header(CacheReadTokensHeader, read)
+}
@@ -1,20 +1,24 @@
 /*
  * 文件作用：代理转发核心处理器，处理所有AI平台的API请求转发
  * 负责功能：
- *   - Claude API 转发（/claude/v1/messages）
+ *   - Claude API 转发（/claude/v1/messages，批处理见 claude_batch.go）
  *   - OpenAI API 转发（/openai/v1/chat/completions）
  *   - Gemini API 转发
- *   - 流式/非流式响应处理
- *   - 请求重试和账户切换
- *   - 使用量记录和费用统计
- *   - 限流头解析和账户状态更新
+ *   - Embeddings 转发（见 embeddings.go）
+ *   - 流式/非流式响应处理（含 SSE 心跳保活，非流式确定性请求的响应缓存见 response_cache.go）
+ *   - 流式响应按需隐藏 thinking / reasoning 内容
+ *   - 请求重试和账户切换（含灰度分流，见 canary.go）
+ *   - 使用量记录和费用统计（用户计费按倍率后 token，账户成本按原始 token）
+ *   - 限流头解析和账户状态更新（OAuth 用量查询带超时，每账户每分钟最多一次）
  * 重要程度：⭐⭐⭐⭐⭐ 核心（代理转发的主要入口）
- * 依赖模块：scheduler, adapter, service, model
+ * 依赖模块：scheduler, adapter, service, model, metrics
  */
 package handler
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -23,8 +27,11 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"go-aiproxy/internal/metrics"
+	"go-aiproxy/internal/middleware"
 	"go-aiproxy/internal/model"
 	"go-aiproxy/internal/proxy/adapter"
 	"go-aiproxy/internal/proxy/scheduler"
@@ -36,29 +43,44 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	// preferredRegionHeader 客户端指定偏好 region 的请求头
+	preferredRegionHeader = "X-Preferred-Region"
+	// accountRegionCtxKey 命中账户的 region（写入请求日志）
+	accountRegionCtxKey = "account_region"
+	// metadataUserIDCtxKey Claude 请求体 metadata.user_id（会话粘性，见 getSessionID）
+	metadataUserIDCtxKey = "claude_metadata_user_id"
+
+	// claudeOAuthUsageTimeout OAuth Usage API 单次调用超时
+	claudeOAuthUsageTimeout = 10 * time.Second
+	// claudeOAuthUsageInterval 同一账户两次 OAuth Usage API 调用的最小间隔
+	claudeOAuthUsageInterval = time.Minute
+)
+
+// claudeOAuthUsageFetchedAt 各账户最近一次调用 OAuth Usage API 的时间（accountID -> time.Time）
+var claudeOAuthUsageFetchedAt sync.Map
+
 type ProxyHandler struct {
 	scheduler       *scheduler.Scheduler
-	retryConfig     *scheduler.RetryConfig
 	usageService    *service.UsageService
 	pricingService  *service.PricingService
 	userRepo        *repository.UserRepository
-	dailyUsageRepo  *repository.DailyUsageRepository
 	apiKeyService   *service.APIKeyService
 	accountRepo     *repository.AccountRepository
 	userPackageRepo *repository.UserPackageRepository
+	batchRepo       *repository.MessageBatchRepository
 }
 
 func NewProxyHandler() *ProxyHandler {
 	return &ProxyHandler{
 		scheduler:       scheduler.GetScheduler(),
-		retryConfig:     &scheduler.DefaultRetryConfig,
 		usageService:    service.NewUsageService(),
 		pricingService:  service.NewPricingService(),
 		userRepo:        repository.NewUserRepository(),
-		dailyUsageRepo:  repository.NewDailyUsageRepository(),
 		apiKeyService:   service.NewAPIKeyService(),
 		accountRepo:     repository.NewAccountRepository(),
 		userPackageRepo: repository.NewUserPackageRepository(),
+		batchRepo:       repository.NewMessageBatchRepository(),
 	}
 }
 
@@ -73,6 +95,14 @@ func NewRateWriter(w io.Writer, rate float64) *RateWriter {
 	return &RateWriter{writer: w, rate: rate}
 }
 
+// wrapRateWriter 倍率不为 1 时才插入 RateWriter，倍率为 1 直接返回原 writer，减少流式写入的调用层数
+func wrapRateWriter(w io.Writer, rate float64) io.Writer {
+	if rate == 1.0 {
+		return w
+	}
+	return NewRateWriter(w, rate)
+}
+
 // Write 实现 io.Writer 接口，写入时修改 token 值
 func (rw *RateWriter) Write(p []byte) (n int, err error) {
 	if rw.rate == 1.0 {
@@ -112,6 +142,9 @@ func applyRateToSSEChunk(chunk []byte, rate float64) []byte {
 		"promptTokenCount",
 		"candidatesTokenCount",
 		"totalTokenCount",
+		"cachedContentTokenCount",
+		"thoughtsTokenCount",
+		"toolUsePromptTokenCount",
 	}
 
 	for _, field := range tokenFields {
@@ -134,7 +167,8 @@ func applyRateToSSEChunk(chunk []byte, rate float64) []byte {
 
 // getSessionID 获取会话ID
 // 优先使用请求头中的 x-session-id（Claude Code 每个窗口会发不同的 session）
-// 如果没有则使用 API Key ID
+// 其次使用 Claude 请求体中的 metadata.user_id（同一用户的多个窗口共享），取哈希避免超长键
+// 都没有则使用 API Key ID
 func (h *ProxyHandler) getSessionID(c *gin.Context) string {
 	// 优先使用 Claude Code 的 x-session-id
 	if sessionID := c.GetHeader("x-session-id"); sessionID != "" {
@@ -146,6 +180,16 @@ func (h *ProxyHandler) getSessionID(c *gin.Context) string {
 		}
 		return sessionID
 	}
+	if metadataUserID := c.GetString(metadataUserIDCtxKey); metadataUserID != "" {
+		hash := sha256.Sum256([]byte(metadataUserID))
+		userKey := "user:" + hex.EncodeToString(hash[:])[:32]
+		if apiKeyID, ok := c.Get("api_key_id"); ok {
+			if id, ok := apiKeyID.(uint); ok {
+				return fmt.Sprintf("apikey:%d:%s", id, userKey)
+			}
+		}
+		return userKey
+	}
 	// 回退到 API Key ID
 	if apiKeyID, ok := c.Get("api_key_id"); ok {
 		if id, ok := apiKeyID.(uint); ok {
@@ -172,12 +216,187 @@ func (h *ProxyHandler) getUserInfo(c *gin.Context) (userID, apiKeyID uint, clien
 	return
 }
 
-// createRetryRequest 创建带用户信息的重试请求
+// createRetryRequest 创建带用户信息的重试请求（每次取最新的运行时重试配置）
 func (h *ProxyHandler) createRetryRequest(c *gin.Context) *scheduler.RetryableRequest {
 	userID, apiKeyID, clientIP, userAgent := h.getUserInfo(c)
-	return scheduler.NewRetryableRequest(h.scheduler, h.retryConfig).
-		WithSessionID(h.getSessionID(c)).
-		WithUserInfo(userID, apiKeyID, clientIP, userAgent)
+	sessionID := h.getSessionID(c)
+	return scheduler.NewRetryableRequest(h.scheduler, nil).
+		WithSessionID(sessionID).
+		WithUserInfo(userID, apiKeyID, clientIP, userAgent).
+		WithPreferredRegion(getPreferredRegion(c)).
+		WithCanary(assignCanary(c, sessionID)).
+		WithStrictSession(isStrictSession(c)).
+		WithPriority(getRequestPriority(c)).
+		WithAccountGroups(getPackageAccountGroups(c)).
+		WithOrg(c.GetUint("api_key_org_id")).
+		WithForcedAccount(c.GetUint("force_account_id"))
+}
+
+// getPackageAccountGroups 获取 API Key 所绑定套餐限定的账户分组，未限定返回 nil
+func getPackageAccountGroups(c *gin.Context) []uint {
+	if v, ok := c.Get("api_key_package_account_groups"); ok {
+		if groupIDs, ok := v.([]uint); ok {
+			return groupIDs
+		}
+	}
+	return nil
+}
+
+// getRequestPriority 获取调度优先级：API Key 与所绑定套餐的优先级取较大者
+func getRequestPriority(c *gin.Context) int {
+	priority := c.GetInt("api_key_package_priority")
+	if v, ok := c.Get("api_key"); ok {
+		if key, ok := v.(*model.APIKey); ok && key.Priority > priority {
+			priority = key.Priority
+		}
+	}
+	return priority
+}
+
+// getPreferredRegion 获取偏好 region：请求头 X-Preferred-Region 优先，其次 API Key 配置
+func getPreferredRegion(c *gin.Context) string {
+	if region := strings.TrimSpace(c.GetHeader(preferredRegionHeader)); region != "" {
+		return region
+	}
+	if v, ok := c.Get("api_key"); ok {
+		if key, ok := v.(*model.APIKey); ok {
+			return key.PreferredRegion
+		}
+	}
+	return ""
+}
+
+// isStrictSession 是否严格会话粘性：请求头 X-Session-Stickiness 优先，其次 API Key 配置
+func isStrictSession(c *gin.Context) bool {
+	if stickiness := strings.TrimSpace(c.GetHeader(model.SessionStickinessHeader)); stickiness != "" {
+		return strings.EqualFold(stickiness, model.SessionStickinessStrict)
+	}
+	if v, ok := c.Get("api_key"); ok {
+		if key, ok := v.(*model.APIKey); ok {
+			return key.SessionStickiness == model.SessionStickinessStrict
+		}
+	}
+	return false
+}
+
+// isStripThinking 流式响应是否隐藏思考内容：请求头 X-Strip-Thinking 优先，其次 API Key 配置
+func isStripThinking(c *gin.Context) bool {
+	if value := strings.TrimSpace(c.GetHeader(model.StripThinkingHeader)); value != "" {
+		strip, _ := strconv.ParseBool(value)
+		return strip
+	}
+	if v, ok := c.Get("api_key"); ok {
+		if key, ok := v.(*model.APIKey); ok {
+			return key.StripThinking
+		}
+	}
+	return false
+}
+
+// wrapThinkingFilter 需要隐藏思考内容时插入 ThinkingFilterWriter，返回包装后的 writer 和流结束时的收尾函数
+func wrapThinkingFilter(c *gin.Context, w io.Writer, format string) (io.Writer, func()) {
+	if !isStripThinking(c) {
+		return w, func() {}
+	}
+	filterWriter := adapter.NewThinkingFilterWriter(w, format)
+	return filterWriter, filterWriter.Close
+}
+
+// applyContentFilter 转发前审查请求内容
+// 命中拒绝时已写入 403 响应并返回 false；脱敏时返回脱敏后的请求体
+func applyContentFilter(c *gin.Context, rawBody []byte, format string) ([]byte, bool) {
+	filterService := service.GetContentFilterService()
+	if !filterService.IsEnabled() {
+		return rawBody, true
+	}
+	result := filterService.Inspect(c.Request.Context(), &service.ContentFilterRequest{
+		Format:   format,
+		Body:     rawBody,
+		UserID:   c.GetUint("api_key_user_id"),
+		APIKeyID: c.GetUint("api_key_id"),
+		ClientIP: c.ClientIP(),
+		Path:     c.Request.URL.Path,
+	})
+	if result == nil {
+		return rawBody, true
+	}
+	if result.Blocked {
+		response.Error(c, http.StatusForbidden, result.Message)
+		return nil, false
+	}
+	return result.Body, true
+}
+
+// applyMaxTokensLimit 请求的输出 token 上限超过限制时改写为上限值或拒绝
+// API Key 设置了上限时优先使用，否则使用全局配置；拒绝时已写入 400 响应并返回 false
+func applyMaxTokensLimit(c *gin.Context, rawBody []byte, format string) ([]byte, bool) {
+	configService := service.GetConfigService()
+	limit := configService.GetMaxOutputTokensLimit()
+	if v, ok := c.Get("api_key"); ok {
+		if key, ok := v.(*model.APIKey); ok && key.MaxOutputTokensLimit > 0 {
+			limit = key.MaxOutputTokensLimit
+		}
+	}
+	if limit <= 0 {
+		return rawBody, true
+	}
+
+	requested := adapter.RequestedMaxTokens(rawBody, format)
+	if requested <= limit {
+		return rawBody, true
+	}
+
+	log := logger.GetLogger("proxy").Ctx(c.Request.Context())
+	if configService.GetMaxOutputTokensReject() {
+		log.WarnZ("请求输出上限超过限制，已拒绝",
+			logger.Uint("api_key_id", c.GetUint("api_key_id")),
+			logger.Int("requested", requested),
+			logger.Int("limit", limit),
+		)
+		response.Error(c, http.StatusBadRequest, fmt.Sprintf("max_tokens %d exceeds the limit of %d", requested, limit))
+		return nil, false
+	}
+	log.InfoZ("请求输出上限超过限制，已改写为上限值",
+		logger.Uint("api_key_id", c.GetUint("api_key_id")),
+		logger.Int("requested", requested),
+		logger.Int("limit", limit),
+	)
+	return adapter.ClampMaxTokens(rawBody, format, limit), true
+}
+
+// modelFallbacks 获取模型回退链（不含模型本身）
+// API Key 禁用回退时返回空；API Key 配置了回退链时优先使用，否则使用全局配置
+func (h *ProxyHandler) modelFallbacks(c *gin.Context, modelName string) []string {
+	var chains map[string]string
+	if v, ok := c.Get("api_key"); ok {
+		if key, ok := v.(*model.APIKey); ok {
+			if key.DisableModelFallback {
+				return nil
+			}
+			if key.ModelFallback != "" {
+				chains, _ = model.ParseModelFallbackChains(key.ModelFallback)
+			}
+		}
+	}
+	if chains == nil {
+		chains = service.GetConfigService().GetModelFallbackChains()
+	}
+	return model.ResolveModelFallbacks(chains, modelName)
+}
+
+// applyModelFallback 发生模型回退时通过响应头告知客户端，返回计费使用的模型名
+func (h *ProxyHandler) applyModelFallback(c *gin.Context, retryReq *scheduler.RetryableRequest, originalModel string) string {
+	path := retryReq.FallbackPath()
+	if path == "" {
+		return originalModel
+	}
+	if c.Writer.Written() {
+		// 流式响应头已发送，改用 HTTP Trailer 告知
+		c.Writer.Header().Set(http.TrailerPrefix+model.ModelFallbackHeader, path)
+	} else {
+		c.Header(model.ModelFallbackHeader, path)
+	}
+	return retryReq.FallbackModel()
 }
 
 // checkModelEnabled 检查模型是否启用
@@ -201,7 +420,14 @@ func (h *ProxyHandler) checkModelEnabled(c *gin.Context, modelName string) bool
 // OpenAI 非流式响应（带重试）
 // originalModel: 客户端请求的原始模型名（映射前），用于账户 ModelMapping 检查
 func (h *ProxyHandler) handleOpenAINonStreamWithRetry(c *gin.Context, req *adapter.Request, accountType string, originalModel string) {
-	retryReq := h.createRetryRequest(c).WithOriginalModel(originalModel)
+	// 确定性请求命中响应缓存时直接返回
+	cacheKey, cacheTTL := responseCacheKeyFor(c, adapter.RequestFormatOpenAI)
+	if cacheKey != "" && h.serveCachedResponse(c, cacheKey, adapter.RequestFormatOpenAI) {
+		return
+	}
+
+	retryReq := h.createRetryRequest(c).WithOriginalModel(originalModel).
+		WithFallbackModels(h.modelFallbacks(c, originalModel))
 
 	modelName := req.Model
 	if accountType != "" {
@@ -216,11 +442,18 @@ func (h *ProxyHandler) handleOpenAINonStreamWithRetry(c *gin.Context, req *adapt
 			if adp == nil {
 				return nil, adapter.ErrNoAdapter
 			}
-			return adp.Send(ctx, account, req)
+			return adp.Send(ctx, account, retryReq.ApplyModel(account, req))
 		},
 	)
 
 	if err != nil {
+		if text, ok := degradeResponseText(c, err); ok {
+			writeOpenAIDegradeResponse(c, originalModel, text)
+			return
+		}
+		if writeUpstreamErrorBody(c, err) {
+			return
+		}
 		// 根据错误类型返回自定义错误
 		errorType, statusCode := getProxyErrorTypeAndCode(err)
 		response.CustomError(c, statusCode, errorType, err.Error())
@@ -245,19 +478,16 @@ func (h *ProxyHandler) handleOpenAINonStreamWithRetry(c *gin.Context, req *adapt
 	ratedInputTokens := int(float64(resp.InputTokens) * priceRate)
 	ratedOutputTokens := int(float64(resp.OutputTokens) * priceRate)
 
-	// 构建响应体用于日志记录（使用倍率后的 token）
-	responseBody, _ := json.Marshal(gin.H{
+	// 构建 OpenAI 格式响应体（使用倍率后的 token），同时用于日志记录
+	openAIBody := gin.H{
 		"id":      resp.ID,
 		"object":  "chat.completion",
 		"model":   resp.Model,
 		"choices": []gin.H{
 			{
-				"index": 0,
-				"message": gin.H{
-					"role":    "assistant",
-					"content": resp.Content,
-				},
-				"finish_reason": convertStopReason(resp.StopReason),
+				"index":         0,
+				"message":       openAIChatMessage(resp),
+				"finish_reason": openAIFinishReason(resp),
 			},
 		},
 		"usage": gin.H{
@@ -265,7 +495,8 @@ func (h *ProxyHandler) handleOpenAINonStreamWithRetry(c *gin.Context, req *adapt
 			"completion_tokens": ratedOutputTokens,
 			"total_tokens":      ratedInputTokens + ratedOutputTokens,
 		},
-	})
+	}
+	responseBody, _ := json.Marshal(openAIBody)
 
 	// 获取请求体
 	var requestBody []byte
@@ -273,35 +504,24 @@ func (h *ProxyHandler) handleOpenAINonStreamWithRetry(c *gin.Context, req *adapt
 		requestBody = rb.([]byte)
 	}
 
-	// 记录使用统计（使用原始模型名）
-	h.recordNonStreamUsage(c, originalModel, resp, requestBody, responseBody, 200, result.AccountID)
+	// 记录使用统计（使用原始模型名，发生回退时按实际模型计费）
+	billingModel := h.applyModelFallback(c, retryReq, originalModel)
+	c.Set(accountRegionCtxKey, result.Region)
+	h.recordNonStreamUsage(c, billingModel, resp, requestBody, responseBody, 200, result.AccountID)
+	updateRateLimitWindow(result.AccountID, resp.Headers)
+	storeCachedResponse(cacheKey, cacheTTL, billingModel, resp, responseBody)
+	h.mirrorToShadow(c, retryReq, req, result.AccountID, resp)
 
 	// 返回 OpenAI 格式（使用倍率后的 token）
-	c.JSON(http.StatusOK, gin.H{
-		"id":      resp.ID,
-		"object":  "chat.completion",
-		"model":   resp.Model,
-		"choices": []gin.H{
-			{
-				"index": 0,
-				"message": gin.H{
-					"role":    "assistant",
-					"content": resp.Content,
-				},
-				"finish_reason": convertStopReason(resp.StopReason),
-			},
-		},
-		"usage": gin.H{
-			"prompt_tokens":     ratedInputTokens,
-			"completion_tokens": ratedOutputTokens,
-			"total_tokens":      ratedInputTokens + ratedOutputTokens,
-		},
-	})
+	c.JSON(http.StatusOK, openAIBody)
 }
 
 // OpenAI 流式响应（带重试）
 // originalModel: 客户端请求的原始模型名（映射前），用于账户 ModelMapping 检查
 func (h *ProxyHandler) handleOpenAIStreamWithRetry(c *gin.Context, req *adapter.Request, accountType string, originalModel string) {
+	// 登记为进行中的流式请求（优雅关闭时等待其结束）
+	defer beginStream()()
+
 	// 设置 SSE 响应头
 	c.Header("Content-Type", "text/event-stream")
 	c.Header("Cache-Control", "no-cache")
@@ -321,13 +541,23 @@ func (h *ProxyHandler) handleOpenAIStreamWithRetry(c *gin.Context, req *adapter.
 		}
 	}
 
-	// 使用 RateWriter 包装 writer，在写入时修改 token 值
-	rateWriter := NewRateWriter(writer, priceRate)
+	// 使用 KeepAliveWriter 包装 writer，上游长时间无输出时发送 SSE 心跳
+	keepAliveWriter := newStreamKeepAliveWriter(writer)
+
+	// 倍率不为 1 时使用 RateWriter 包装 writer，在写入时修改 token 值
+	rateWriter := wrapRateWriter(keepAliveWriter, priceRate)
+
+	// 客户端要求隐藏思考内容时过滤 thinking 事件（adapter 仍从上游数据解析思考 token 计费）
+	filterWriter, closeFilter := wrapThinkingFilter(c, rateWriter, adapter.StreamFormatOpenAI)
 
 	// 使用 TailWriter 捕获末尾 2KB 响应（包装 RateWriter）
-	tailWriter := adapter.NewTailWriter(rateWriter, 2048)
+	tailWriter := adapter.NewTailWriter(filterWriter, 2048)
+
+	// 上游未返回 usage 时按转发的响应文本估算输出 token
+	streamWriter, estimator := wrapUsageEstimator(tailWriter, adapter.StreamFormatOpenAI, req.Model)
 
-	retryReq := h.createRetryRequest(c).WithOriginalModel(originalModel)
+	retryReq := h.createRetryRequest(c).WithOriginalModel(originalModel).
+		WithFallbackModels(h.modelFallbacks(c, originalModel))
 
 	modelName := req.Model
 	if accountType != "" {
@@ -342,20 +572,23 @@ func (h *ProxyHandler) handleOpenAIStreamWithRetry(c *gin.Context, req *adapter.
 			if adp == nil {
 				return nil, adapter.ErrNoAdapter
 			}
-			return adp.SendStream(ctx, account, req, w)
+			return adp.SendStream(ctx, account, retryReq.ApplyModel(account, req), w)
 		},
-		tailWriter,
+		streamWriter,
 	)
+	closeFilter()
+	keepAliveWriter.Stop()
 
 	if err != nil {
-		errEvent := map[string]interface{}{
-			"error": map[string]string{
-				"message": err.Error(),
-				"type":    "api_error",
-			},
+		// 客户端已断开：adapter 已中断上游读取，重试层已记录日志，无需再写错误事件
+		if errors.Is(err, scheduler.ErrClientCanceled) {
+			return
+		}
+		if text, ok := degradeResponseText(c, err); ok && len(tailWriter.Tail()) == 0 {
+			writeOpenAIDegradeStream(c, originalModel, text)
+			return
 		}
-		data, _ := json.Marshal(errEvent)
-		writer.Write([]byte("data: " + string(data) + "\n\n"))
+		writeOpenAIStreamError(writer, err, originalModel, len(tailWriter.Tail()) > 0)
 		return
 	}
 
@@ -368,9 +601,15 @@ func (h *ProxyHandler) handleOpenAIStreamWithRetry(c *gin.Context, req *adapter.
 	// 获取响应末尾内容
 	responseTail := tailWriter.Tail()
 
-	// 记录使用统计（使用原始模型名）
+	// 记录使用统计（使用原始模型名，发生回退时按实际模型计费）
 	if result != nil && result.Result != nil {
-		h.recordUsage(c, originalModel, result.Result, true, requestBody, responseTail, 200, result.AccountID)
+		applyUsageEstimate(result.Result, estimator, req.Model, requestBody)
+		c.Set(accountRegionCtxKey, result.Region)
+		h.recordUsage(c, h.applyModelFallback(c, retryReq, originalModel), result.Result, true, requestBody, responseTail, 200, result.AccountID)
+		updateRateLimitWindow(result.AccountID, result.Result.Headers)
+		if !result.Result.Truncated() {
+			h.mirrorToShadow(c, retryReq, req, result.AccountID, nil)
+		}
 	}
 
 	writer.Write([]byte("data: [DONE]\n\n"))
@@ -379,7 +618,14 @@ func (h *ProxyHandler) handleOpenAIStreamWithRetry(c *gin.Context, req *adapter.
 // Claude 非流式响应（带重试）
 // originalModel: 客户端请求的原始模型名（映射前），用于账户 ModelMapping 检查
 func (h *ProxyHandler) handleClaudeNonStreamWithRetry(c *gin.Context, req *adapter.Request, accountType string, originalModel string) {
-	retryReq := h.createRetryRequest(c).WithOriginalModel(originalModel)
+	// 确定性请求命中响应缓存时直接返回
+	cacheKey, cacheTTL := responseCacheKeyFor(c, adapter.RequestFormatClaude)
+	if cacheKey != "" && h.serveCachedResponse(c, cacheKey, adapter.RequestFormatClaude) {
+		return
+	}
+
+	retryReq := h.createRetryRequest(c).WithOriginalModel(originalModel).
+		WithFallbackModels(h.modelFallbacks(c, originalModel))
 
 	modelName := req.Model
 	if accountType != "" {
@@ -394,18 +640,31 @@ func (h *ProxyHandler) handleClaudeNonStreamWithRetry(c *gin.Context, req *adapt
 			if adp == nil {
 				return nil, adapter.ErrNoAdapter
 			}
-			return adp.Send(ctx, account, req)
+			return adp.Send(ctx, account, retryReq.ApplyModel(account, req))
 		},
 	)
 
+	// Claude 账户全部不可用时跨平台兜底到 OpenAI 账户（按实际 OpenAI 模型计费）
+	var crossPlatformModel string
+	if err != nil && shouldCrossPlatformFallback(c, err) {
+		result, crossPlatformModel, err = h.executeClaudeViaOpenAI(c, req)
+	}
+
 	if err != nil {
+		if text, ok := degradeResponseText(c, err); ok {
+			writeClaudeDegradeResponse(c, originalModel, text)
+			return
+		}
+		if writeUpstreamErrorBody(c, err) {
+			return
+		}
 		// 使用自定义错误消息
 		errorType, statusCode := getProxyErrorTypeAndCode(err)
 		customMsg, _ := getCustomErrorMessage(errorType, err.Error())
 		c.JSON(statusCode, gin.H{
 			"type": "error",
 			"error": gin.H{
-				"type":    "api_error",
+				"type":    apiErrorType(err, claudeTimeoutErrorType),
 				"message": customMsg,
 			},
 		})
@@ -437,14 +696,21 @@ func (h *ProxyHandler) handleClaudeNonStreamWithRetry(c *gin.Context, req *adapt
 	ratedInputTokens := int(float64(resp.InputTokens) * priceRate)
 	ratedOutputTokens := int(float64(resp.OutputTokens) * priceRate)
 
+	// 跨平台兜底的响应可能包含工具调用，需要转换为 tool_use 块
+	var content interface{} = []gin.H{{"type": "text", "text": resp.Content}}
+	if crossPlatformModel != "" {
+		content = adapter.ClaudeContentBlocks(resp)
+	}
+
 	// 构建响应体用于日志记录（使用倍率后的 token）
 	responseBody, _ := json.Marshal(gin.H{
-		"id":          resp.ID,
-		"type":        "message",
-		"role":        "assistant",
-		"model":       resp.Model,
-		"content":     []gin.H{{"type": "text", "text": resp.Content}},
-		"stop_reason": resp.StopReason,
+		"id":            resp.ID,
+		"type":          "message",
+		"role":          "assistant",
+		"model":         resp.Model,
+		"content":       content,
+		"stop_reason":   resp.StopReason,
+		"stop_sequence": claudeStopSequence(resp.StopSequence),
 		"usage": gin.H{
 			"input_tokens":  ratedInputTokens,
 			"output_tokens": ratedOutputTokens,
@@ -457,20 +723,32 @@ func (h *ProxyHandler) handleClaudeNonStreamWithRetry(c *gin.Context, req *adapt
 		requestBody = rb.([]byte)
 	}
 
-	// 记录使用统计（使用原始模型名）
-	h.recordNonStreamUsage(c, originalModel, resp, requestBody, responseBody, 200, result.AccountID)
+	// 记录使用统计（使用原始模型名，发生回退时按实际模型计费）
+	billingModel := crossPlatformModel
+	if billingModel == "" {
+		billingModel = h.applyModelFallback(c, retryReq, originalModel)
+	}
+	c.Set(accountRegionCtxKey, result.Region)
+	h.recordNonStreamUsage(c, billingModel, resp, requestBody, responseBody, 200, result.AccountID)
+	setPromptCacheHeaders(c, resp.PromptCacheCreationTokens, resp.PromptCacheReadTokens)
 
 	// 更新账号用量状态（从响应头获取）
 	h.updateAccountUsageStatus(result.AccountID, resp.Headers)
+	storeCachedResponse(cacheKey, cacheTTL, billingModel, resp, responseBody)
+	// 跨平台兜底的主请求来自 OpenAI 账户，不复制给 Claude 影子账户
+	if crossPlatformModel == "" {
+		h.mirrorToShadow(c, retryReq, req, result.AccountID, resp)
+	}
 
 	// 返回 Claude 格式（使用倍率后的 token）
 	c.JSON(http.StatusOK, gin.H{
-		"id":          resp.ID,
-		"type":        "message",
-		"role":        "assistant",
-		"model":       resp.Model,
-		"content":     []gin.H{{"type": "text", "text": resp.Content}},
-		"stop_reason": resp.StopReason,
+		"id":            resp.ID,
+		"type":          "message",
+		"role":          "assistant",
+		"model":         resp.Model,
+		"content":       content,
+		"stop_reason":   resp.StopReason,
+		"stop_sequence": claudeStopSequence(resp.StopSequence),
 		"usage": gin.H{
 			"input_tokens":  ratedInputTokens,
 			"output_tokens": ratedOutputTokens,
@@ -481,6 +759,9 @@ func (h *ProxyHandler) handleClaudeNonStreamWithRetry(c *gin.Context, req *adapt
 // Claude 流式响应（带重试）
 // originalModel: 客户端请求的原始模型名（映射前），用于账户 ModelMapping 检查
 func (h *ProxyHandler) handleClaudeStreamWithRetry(c *gin.Context, req *adapter.Request, accountType string, originalModel string) {
+	// 登记为进行中的流式请求（优雅关闭时等待其结束）
+	defer beginStream()()
+
 	// 设置 SSE 响应头
 	c.Header("Content-Type", "text/event-stream")
 	c.Header("Cache-Control", "no-cache")
@@ -503,13 +784,23 @@ func (h *ProxyHandler) handleClaudeStreamWithRetry(c *gin.Context, req *adapter.
 	log := logger.GetLogger("proxy")
 	log.Debug("Claude Stream 倍率 | Rate: %.2f | Model: %s", priceRate, req.Model)
 
-	// 使用 RateWriter 包装 writer，在写入时修改 token 值
-	rateWriter := NewRateWriter(writer, priceRate)
+	// 使用 KeepAliveWriter 包装 writer，上游长时间无输出时发送 SSE 心跳
+	keepAliveWriter := newStreamKeepAliveWriter(writer)
+
+	// 倍率不为 1 时使用 RateWriter 包装 writer，在写入时修改 token 值
+	rateWriter := wrapRateWriter(keepAliveWriter, priceRate)
+
+	// 客户端要求隐藏思考内容时过滤 thinking 事件（adapter 仍从上游数据解析思考 token 计费）
+	filterWriter, closeFilter := wrapThinkingFilter(c, rateWriter, adapter.StreamFormatClaude)
 
 	// 使用 TailWriter 捕获末尾 2KB 响应（包装 RateWriter）
-	tailWriter := adapter.NewTailWriter(rateWriter, 2048)
+	tailWriter := adapter.NewTailWriter(filterWriter, 2048)
+
+	// 上游未返回 usage 时按转发的响应文本估算输出 token
+	streamWriter, estimator := wrapUsageEstimator(tailWriter, adapter.StreamFormatClaude, req.Model)
 
-	retryReq := h.createRetryRequest(c).WithOriginalModel(originalModel)
+	retryReq := h.createRetryRequest(c).WithOriginalModel(originalModel).
+		WithFallbackModels(h.modelFallbacks(c, originalModel))
 
 	modelName := req.Model
 	if accountType != "" {
@@ -524,21 +815,29 @@ func (h *ProxyHandler) handleClaudeStreamWithRetry(c *gin.Context, req *adapter.
 			if adp == nil {
 				return nil, adapter.ErrNoAdapter
 			}
-			return adp.SendStream(ctx, account, req, w)
+			return adp.SendStream(ctx, account, retryReq.ApplyModel(account, req), w)
 		},
-		tailWriter,
+		streamWriter,
 	)
 
+	// Claude 账户全部不可用时跨平台兜底到 OpenAI 账户（按实际 OpenAI 模型计费）
+	var crossPlatformModel string
+	if err != nil && shouldCrossPlatformFallback(c, err) {
+		result, crossPlatformModel, err = h.executeClaudeStreamViaOpenAI(c, req, streamWriter)
+	}
+	closeFilter()
+	keepAliveWriter.Stop()
+
 	if err != nil {
-		writer.Write([]byte("event: error\n"))
-		errData, _ := json.Marshal(gin.H{
-			"type": "error",
-			"error": gin.H{
-				"type":    "api_error",
-				"message": err.Error(),
-			},
-		})
-		writer.Write([]byte("data: " + string(errData) + "\n\n"))
+		// 客户端已断开：adapter 已中断上游读取，重试层已记录日志，无需再写错误事件
+		if errors.Is(err, scheduler.ErrClientCanceled) {
+			return
+		}
+		if text, ok := degradeResponseText(c, err); ok && len(tailWriter.Tail()) == 0 {
+			writeClaudeDegradeStream(c, originalModel, text)
+			return
+		}
+		writeClaudeStreamError(writer, err, len(tailWriter.Tail()) > 0)
 		return
 	}
 
@@ -551,11 +850,21 @@ func (h *ProxyHandler) handleClaudeStreamWithRetry(c *gin.Context, req *adapter.
 	// 获取响应末尾内容
 	responseTail := tailWriter.Tail()
 
-	// 记录使用统计（使用原始模型名）
+	// 记录使用统计（使用原始模型名，发生回退时按实际模型计费）
 	if result != nil && result.Result != nil {
-		h.recordUsage(c, originalModel, result.Result, true, requestBody, responseTail, 200, result.AccountID)
+		billingModel := crossPlatformModel
+		if billingModel == "" {
+			billingModel = h.applyModelFallback(c, retryReq, originalModel)
+		}
+		applyUsageEstimate(result.Result, estimator, billingModel, requestBody)
+		c.Set(accountRegionCtxKey, result.Region)
+		h.recordUsage(c, billingModel, result.Result, true, requestBody, responseTail, 200, result.AccountID)
+		setPromptCacheHeaders(c, result.Result.CacheCreationInputTokens, result.Result.CacheReadInputTokens)
 		// 更新账号用量状态（从响应头获取）
 		h.updateAccountUsageStatus(result.AccountID, result.Result.Headers)
+		if crossPlatformModel == "" && !result.Result.Truncated() {
+			h.mirrorToShadow(c, retryReq, req, result.AccountID, nil)
+		}
 	}
 }
 
@@ -567,6 +876,9 @@ func (h *ProxyHandler) ClaudeMessages(c *gin.Context) {
 	// 1. 读取原始请求体（不做任何解析）
 	rawBody, err := io.ReadAll(c.Request.Body)
 	if err != nil {
+		if middleware.HandleRequestTooLarge(c, err) {
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{
 			"type": "error",
 			"error": gin.H{
@@ -577,16 +889,30 @@ func (h *ProxyHandler) ClaudeMessages(c *gin.Context) {
 		return
 	}
 
+	// 内容审查（命中时拒绝或脱敏）
+	rawBody, ok := applyContentFilter(c, rawBody, service.ContentFormatClaude)
+	if !ok {
+		return
+	}
+
+	// 输出 token 上限（超限时改写或拒绝）
+	if rawBody, ok = applyMaxTokensLimit(c, rawBody, adapter.RequestFormatClaude); !ok {
+		return
+	}
+
 	log := logger.GetLogger("proxy")
 	log.Debug("ClaudeMessages 原始请求体 | 长度: %d | 前500字符: %s", len(rawBody), truncateForLog(string(rawBody), 500))
 
 	// 保存原始请求体到 context 用于日志记录
 	c.Set("request_body", rawBody)
 
-	// 2. 只提取必要字段用于路由（model, stream）
+	// 2. 只提取必要字段用于路由（model, stream）和会话粘性（metadata.user_id），请求体仍原样透传
 	var basic struct {
-		Model  string `json:"model"`
-		Stream bool   `json:"stream"`
+		Model    string `json:"model"`
+		Stream   bool   `json:"stream"`
+		Metadata struct {
+			UserID string `json:"user_id"`
+		} `json:"metadata"`
 	}
 	if err := json.Unmarshal(rawBody, &basic); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -598,6 +924,9 @@ func (h *ProxyHandler) ClaudeMessages(c *gin.Context) {
 		})
 		return
 	}
+	if basic.Metadata.UserID != "" {
+		c.Set(metadataUserIDCtxKey, basic.Metadata.UserID)
+	}
 
 	// 3. 提取客户端 headers
 	clientHeaders := make(map[string]string)
@@ -611,17 +940,22 @@ func (h *ProxyHandler) ClaudeMessages(c *gin.Context) {
 	accountType := "claude"
 	actualModel := scheduler.GetActualModel(basic.Model) // 去掉可能的 "type," 前缀
 
+	// model[1m] 表示请求 1M 上下文：去掉后缀并补全 anthropic-beta 头
+	actualModel, rawBody = adapter.ApplyContext1M(actualModel, rawBody, clientHeaders)
+
 	// 5. 检查模型是否启用（不再做全局模型映射，只在账号级别映射）
 	if !h.checkModelEnabled(c, actualModel) {
 		return
 	}
 
-	// 6. 构建透传请求（模型映射由调度器在账号级别处理）
+	// 6. 构建透传请求（账号级模型映射在发送前由 ApplyModel 改写请求体）
 	req := &adapter.Request{
-		Model:   actualModel,
-		Stream:  basic.Stream,
-		RawBody: rawBody,
-		Headers: clientHeaders,
+		Model:        actualModel,
+		Stream:       basic.Stream,
+		RawBody:      rawBody,
+		Headers:      clientHeaders,
+		ImageInline:  newImageInlineOptions(),
+		SystemPrompt: newSystemPromptOptions(c),
 	}
 
 	if req.Stream {
@@ -637,10 +971,24 @@ func (h *ProxyHandler) OpenAIChatCompletions(c *gin.Context) {
 	// 读取原始请求体用于日志记录
 	rawBody, err := io.ReadAll(c.Request.Body)
 	if err != nil {
+		if middleware.HandleRequestTooLarge(c, err) {
+			return
+		}
 		response.CustomBadRequest(c, "failed to read request body")
 		return
 	}
 
+	// 内容审查（命中时拒绝或脱敏）
+	rawBody, ok := applyContentFilter(c, rawBody, service.ContentFormatOpenAI)
+	if !ok {
+		return
+	}
+
+	// 输出 token 上限（超限时改写或拒绝）
+	if rawBody, ok = applyMaxTokensLimit(c, rawBody, adapter.RequestFormatOpenAI); !ok {
+		return
+	}
+
 	var req adapter.Request
 	if err := json.Unmarshal(rawBody, &req); err != nil {
 		response.CustomBadRequest(c, err.Error())
@@ -672,6 +1020,14 @@ func (h *ProxyHandler) OpenAIChatCompletions(c *gin.Context) {
 	}
 }
 
+// claudeStopSequence Claude 响应的 stop_sequence 字段，未由停止序列触发时为 null（与官方响应一致）
+func claudeStopSequence(stopSequence string) interface{} {
+	if stopSequence == "" {
+		return nil
+	}
+	return stopSequence
+}
+
 // convertStopReason 转换停止原因为 OpenAI 格式
 func convertStopReason(reason string) string {
 	switch reason {
@@ -686,11 +1042,38 @@ func convertStopReason(reason string) string {
 	}
 }
 
+// openAIChatMessage 构建 OpenAI 格式的 assistant 消息，有工具调用时带上 tool_calls
+func openAIChatMessage(resp *adapter.Response) gin.H {
+	message := gin.H{
+		"role":    "assistant",
+		"content": resp.Content,
+	}
+	if len(resp.ToolCalls) > 0 {
+		message["tool_calls"] = resp.ToolCalls
+		if resp.Content == "" {
+			message["content"] = nil
+		}
+	}
+	return message
+}
+
+// openAIFinishReason 转换结束原因，有工具调用时为 tool_calls
+func openAIFinishReason(resp *adapter.Response) string {
+	reason := convertStopReason(resp.StopReason)
+	if len(resp.ToolCalls) > 0 && (reason == "" || reason == "stop") {
+		return "tool_calls"
+	}
+	return reason
+}
+
 // GeminiChat Gemini 原生格式接口 POST /gemini/v1/chat
 func (h *ProxyHandler) GeminiChat(c *gin.Context) {
 	// 读取原始请求体用于日志记录
 	rawBody, err := io.ReadAll(c.Request.Body)
 	if err != nil {
+		if middleware.HandleRequestTooLarge(c, err) {
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": gin.H{
 				"code":    400,
@@ -701,6 +1084,17 @@ func (h *ProxyHandler) GeminiChat(c *gin.Context) {
 		return
 	}
 
+	// 内容审查（命中时拒绝或脱敏）
+	rawBody, ok := applyContentFilter(c, rawBody, service.ContentFormatGemini)
+	if !ok {
+		return
+	}
+
+	// 输出 token 上限（超限时改写或拒绝）
+	if rawBody, ok = applyMaxTokensLimit(c, rawBody, adapter.RequestFormatGemini); !ok {
+		return
+	}
+
 	var req adapter.Request
 	if err := json.Unmarshal(rawBody, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -737,7 +1131,13 @@ func (h *ProxyHandler) GeminiChat(c *gin.Context) {
 }
 
 func (h *ProxyHandler) handleGeminiNonStream(c *gin.Context, req *adapter.Request, originalModel string) {
-	retryReq := h.createRetryRequest(c)
+	// 确定性请求命中响应缓存时直接返回
+	cacheKey, cacheTTL := responseCacheKeyFor(c, adapter.RequestFormatGemini)
+	if cacheKey != "" && h.serveCachedResponse(c, cacheKey, adapter.RequestFormatGemini) {
+		return
+	}
+
+	retryReq := h.createRetryRequest(c).WithFallbackModels(h.modelFallbacks(c, originalModel))
 
 	result, err := retryReq.ExecuteWithRetry(
 		c.Request.Context(),
@@ -747,16 +1147,24 @@ func (h *ProxyHandler) handleGeminiNonStream(c *gin.Context, req *adapter.Reques
 			if adp == nil {
 				return nil, adapter.ErrNoAdapter
 			}
-			return adp.Send(ctx, account, req)
+			return adp.Send(ctx, account, retryReq.ApplyModel(account, req))
 		},
 	)
 
 	if err != nil {
-		c.JSON(http.StatusBadGateway, gin.H{
+		if text, ok := degradeResponseText(c, err); ok {
+			writeGeminiDegradeResponse(c, text)
+			return
+		}
+		if writeUpstreamErrorBody(c, err) {
+			return
+		}
+		code, status := geminiErrorStatus(err)
+		c.JSON(code, gin.H{
 			"error": gin.H{
-				"code":    502,
+				"code":    code,
 				"message": err.Error(),
-				"status":  "UNAVAILABLE",
+				"status":  status,
 			},
 		})
 		return
@@ -782,9 +1190,8 @@ func (h *ProxyHandler) handleGeminiNonStream(c *gin.Context, req *adapter.Reques
 		}
 	}
 
-	// 应用倍率到返回给用户的 token 值
-	ratedInputTokens := int(float64(resp.InputTokens) * priceRate)
-	ratedOutputTokens := int(float64(resp.OutputTokens) * priceRate)
+	// 返回给用户的 usageMetadata（Google 计费口径，使用倍率后的 token）
+	usageMetadata := ratedGeminiUsageMetadata(resp, priceRate)
 
 	// 构建响应体用于日志记录（使用倍率后的 token）
 	responseBody, _ := json.Marshal(gin.H{
@@ -797,11 +1204,7 @@ func (h *ProxyHandler) handleGeminiNonStream(c *gin.Context, req *adapter.Reques
 				"finishReason": convertGeminiStopReason(resp.StopReason),
 			},
 		},
-		"usageMetadata": gin.H{
-			"promptTokenCount":     ratedInputTokens,
-			"candidatesTokenCount": ratedOutputTokens,
-			"totalTokenCount":      ratedInputTokens + ratedOutputTokens,
-		},
+		"usageMetadata": usageMetadata,
 	})
 
 	// 获取请求体
@@ -810,8 +1213,12 @@ func (h *ProxyHandler) handleGeminiNonStream(c *gin.Context, req *adapter.Reques
 		requestBody = rb.([]byte)
 	}
 
-	// 记录使用统计（使用原始模型名）
-	h.recordNonStreamUsage(c, originalModel, resp, requestBody, responseBody, 200, result.AccountID)
+	// 记录使用统计（使用原始模型名，发生回退时按实际模型计费）
+	billingModel := h.applyModelFallback(c, retryReq, originalModel)
+	c.Set(accountRegionCtxKey, result.Region)
+	h.recordNonStreamUsage(c, billingModel, resp, requestBody, responseBody, 200, result.AccountID)
+	storeCachedResponse(cacheKey, cacheTTL, billingModel, resp, responseBody)
+	h.mirrorToShadow(c, retryReq, req, result.AccountID, resp)
 
 	// 返回 Gemini 原生格式（使用倍率后的 token）
 	c.JSON(http.StatusOK, gin.H{
@@ -824,15 +1231,30 @@ func (h *ProxyHandler) handleGeminiNonStream(c *gin.Context, req *adapter.Reques
 				"finishReason": convertGeminiStopReason(resp.StopReason),
 			},
 		},
-		"usageMetadata": gin.H{
-			"promptTokenCount":     ratedInputTokens,
-			"candidatesTokenCount": ratedOutputTokens,
-			"totalTokenCount":      ratedInputTokens + ratedOutputTokens,
-		},
+		"usageMetadata": usageMetadata,
 	})
 }
 
+// ratedGeminiUsageMetadata 按 Google 口径还原 usageMetadata 并应用倍率：
+// promptTokenCount 含缓存命中部分，candidatesTokenCount 不含思考 token
+func ratedGeminiUsageMetadata(resp *adapter.Response, priceRate float64) adapter.GeminiUsageMetadata {
+	rated := func(tokens int) int {
+		return int(float64(tokens) * priceRate)
+	}
+	usage := adapter.GeminiUsageMetadata{
+		PromptTokenCount:        rated(resp.InputTokens + resp.CacheReadInputTokens),
+		CandidatesTokenCount:    rated(resp.OutputTokens - resp.ThinkingTokens),
+		CachedContentTokenCount: rated(resp.CacheReadInputTokens),
+		ThoughtsTokenCount:      rated(resp.ThinkingTokens),
+	}
+	usage.TotalTokenCount = usage.PromptTokenCount + usage.CandidatesTokenCount + usage.ThoughtsTokenCount
+	return usage
+}
+
 func (h *ProxyHandler) handleGeminiStream(c *gin.Context, req *adapter.Request, originalModel string) {
+	// 登记为进行中的流式请求（优雅关闭时等待其结束）
+	defer beginStream()()
+
 	// 设置 SSE 响应头
 	c.Header("Content-Type", "text/event-stream")
 	c.Header("Cache-Control", "no-cache")
@@ -852,13 +1274,22 @@ func (h *ProxyHandler) handleGeminiStream(c *gin.Context, req *adapter.Request,
 		}
 	}
 
-	// 使用 RateWriter 包装 writer，在写入时修改 token 值
-	rateWriter := NewRateWriter(writer, priceRate)
+	// 使用 KeepAliveWriter 包装 writer，上游长时间无输出时发送 SSE 心跳
+	keepAliveWriter := newStreamKeepAliveWriter(writer)
+
+	// 倍率不为 1 时使用 RateWriter 包装 writer，在写入时修改 token 值
+	rateWriter := wrapRateWriter(keepAliveWriter, priceRate)
+
+	// 客户端要求隐藏思考内容时过滤 thinking 事件（adapter 仍从上游数据解析思考 token 计费）
+	filterWriter, closeFilter := wrapThinkingFilter(c, rateWriter, adapter.StreamFormatOpenAI)
 
 	// 使用 TailWriter 捕获末尾 2KB 响应（包装 RateWriter）
-	tailWriter := adapter.NewTailWriter(rateWriter, 2048)
+	tailWriter := adapter.NewTailWriter(filterWriter, 2048)
+
+	// 上游未返回 usage 时按转发的响应文本估算输出 token
+	streamWriter, estimator := wrapUsageEstimator(tailWriter, adapter.StreamFormatOpenAI, req.Model)
 
-	retryReq := h.createRetryRequest(c)
+	retryReq := h.createRetryRequest(c).WithFallbackModels(h.modelFallbacks(c, originalModel))
 
 	result, err := retryReq.ExecuteStreamWithRetry(
 		c.Request.Context(),
@@ -868,17 +1299,28 @@ func (h *ProxyHandler) handleGeminiStream(c *gin.Context, req *adapter.Request,
 			if adp == nil {
 				return nil, adapter.ErrNoAdapter
 			}
-			return adp.SendStream(ctx, account, req, w)
+			return adp.SendStream(ctx, account, retryReq.ApplyModel(account, req), w)
 		},
-		tailWriter,
+		streamWriter,
 	)
+	closeFilter()
+	keepAliveWriter.Stop()
 
 	if err != nil {
+		// 客户端已断开：adapter 已中断上游读取，重试层已记录日志，无需再写错误事件
+		if errors.Is(err, scheduler.ErrClientCanceled) {
+			return
+		}
+		if text, ok := degradeResponseText(c, err); ok && len(tailWriter.Tail()) == 0 {
+			writeOpenAIDegradeStream(c, originalModel, text)
+			return
+		}
+		code, status := geminiErrorStatus(err)
 		errData, _ := json.Marshal(gin.H{
 			"error": gin.H{
-				"code":    502,
+				"code":    code,
 				"message": err.Error(),
-				"status":  "UNAVAILABLE",
+				"status":  status,
 			},
 		})
 		writer.Write([]byte("data: " + string(errData) + "\n\n"))
@@ -894,9 +1336,14 @@ func (h *ProxyHandler) handleGeminiStream(c *gin.Context, req *adapter.Request,
 	// 获取响应末尾内容
 	responseTail := tailWriter.Tail()
 
-	// 记录使用统计（使用原始模型名）
+	// 记录使用统计（使用原始模型名，发生回退时按实际模型计费）
 	if result != nil && result.Result != nil {
-		h.recordUsage(c, originalModel, result.Result, true, requestBody, responseTail, 200, result.AccountID)
+		applyUsageEstimate(result.Result, estimator, req.Model, requestBody)
+		c.Set(accountRegionCtxKey, result.Region)
+		h.recordUsage(c, h.applyModelFallback(c, retryReq, originalModel), result.Result, true, requestBody, responseTail, 200, result.AccountID)
+		if !result.Result.Truncated() {
+			h.mirrorToShadow(c, retryReq, req, result.AccountID, nil)
+		}
 	}
 }
 
@@ -966,7 +1413,10 @@ func (h *ProxyHandler) updateAccountUsageStatus(accountID uint, headers map[stri
 			return
 		}
 
-		// 4. 调用 OAuth Usage API 获取详细用量
+		// 4. 调用 OAuth Usage API 获取详细用量（每账户每分钟最多一次，失败不重试，等下个周期）
+		if !acquireClaudeOAuthUsageSlot(accountID) {
+			return
+		}
 		usageData, err := h.fetchClaudeOAuthUsage(account)
 		if err != nil {
 			log.DebugZ("获取 Claude OAuth 用量失败",
@@ -985,6 +1435,8 @@ func (h *ProxyHandler) updateAccountUsageStatus(accountID uint, headers map[stri
 				logger.Err(err),
 			)
 		} else {
+			// 调度按模型族检查窗口（opus 窗口满时仍可接 sonnet 请求）
+			h.scheduler.UpdateClaudeUsageWindows(accountID, usageData.Windows())
 			log.DebugZ("更新账号详细用量",
 				logger.Uint("account_id", accountID),
 				logger.String("account_name", account.Name),
@@ -995,6 +1447,61 @@ func (h *ProxyHandler) updateAccountUsageStatus(accountID uint, headers map[stri
 	}()
 }
 
+// newImageInlineOptions 按全局配置生成图片 URL 内联选项（仅对开启图片内联的 Claude 账户生效）
+func newImageInlineOptions() *adapter.ImageInlineOptions {
+	configService := service.GetConfigService()
+	return &adapter.ImageInlineOptions{
+		MaxSize:       configService.GetImageInlineMaxSize(),
+		Timeout:       configService.GetImageInlineTimeout(),
+		MaxImages:     configService.GetImageInlineMaxCount(),
+		SkipOnFailure: configService.GetImageInlineSkipOnFailure(),
+	}
+}
+
+// newSystemPromptOptions 按全局开关、作用范围和 API Key 配置生成 system prompt 注入选项（仅对 Claude 账户生效），未开启时返回 nil
+func newSystemPromptOptions(c *gin.Context) *adapter.SystemPromptOptions {
+	configService := service.GetConfigService()
+	if !configService.GetSystemPromptInjectEnabled() {
+		return nil
+	}
+
+	scope := configService.GetSystemPromptInjectScope()
+	opts := &adapter.SystemPromptOptions{IncludeAccount: scope != service.SystemPromptScopeAPIKey}
+	if scope != service.SystemPromptScopeAccount {
+		if key := middleware.GetAPIKey(c); key != nil {
+			opts.Prefix, opts.Suffix = key.SystemPromptPrefix, key.SystemPromptSuffix
+		}
+	}
+	return opts
+}
+
+// updateRateLimitWindow 从 OpenAI 兼容上游的 x-ratelimit-* 响应头解析限流窗口，调度据此避开快耗尽的账户
+func updateRateLimitWindow(accountID uint, headers map[string]string) {
+	window := adapter.ParseRateLimitHeaders(headers, time.Now())
+	if window == nil {
+		return
+	}
+	go scheduler.GetScheduler().UpdateRateLimitWindow(accountID, window)
+}
+
+// acquireClaudeOAuthUsageSlot 检查账户距上次调用 OAuth Usage API 是否已超过最小间隔，是则记录本次调用时间并返回 true
+// 调用前即记录时间，失败的调用同样占用本周期，避免上游异常时每个请求都去调用
+func acquireClaudeOAuthUsageSlot(accountID uint) bool {
+	now := time.Now()
+	for {
+		last, loaded := claudeOAuthUsageFetchedAt.LoadOrStore(accountID, now)
+		if !loaded {
+			return true
+		}
+		if now.Sub(last.(time.Time)) < claudeOAuthUsageInterval {
+			return false
+		}
+		if claudeOAuthUsageFetchedAt.CompareAndSwap(accountID, last, now) {
+			return true
+		}
+	}
+}
+
 // safeFloat 安全获取 float64 指针值
 func safeFloat(f *float64) float64 {
 	if f == nil {
@@ -1026,28 +1533,25 @@ func (h *ProxyHandler) fetchClaudeOAuthUsage(account *model.Account) (*repositor
 			)
 		}
 		if defaultProxy != nil && defaultProxy.Enabled {
-			proxyConfig := &adapter.ProxyConfig{
-				Type:     defaultProxy.Type,
-				Host:     defaultProxy.Host,
-				Port:     defaultProxy.Port,
-				Username: defaultProxy.Username,
-				Password: defaultProxy.Password,
-			}
-			client = adapter.GetChromeTLSClientWithProxy(proxyConfig)
+			client = adapter.GetChromeTLSClientWithProxy(adapter.NewProxyConfig(defaultProxy))
 			log.DebugZ("OAuth Usage API 使用默认代理",
 				logger.Uint("account_id", account.ID),
 				logger.String("proxy_name", defaultProxy.Name),
 			)
 		} else {
 			// 没有代理，直连（可能会被 Cloudflare 拦截）
-			client = &http.Client{Timeout: 30 * time.Second}
+			client = &http.Client{Timeout: claudeOAuthUsageTimeout}
 			log.DebugZ("OAuth Usage API 无代理，直连",
 				logger.Uint("account_id", account.ID),
 			)
 		}
 	}
 
-	req, err := http.NewRequest("GET", "https://api.anthropic.com/api/oauth/usage", nil)
+	// 代理客户端的超时面向长时间的对话请求，这里用 context 单独限制用量查询的耗时
+	ctx, cancel := context.WithTimeout(context.Background(), claudeOAuthUsageTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.anthropic.com/api/oauth/usage", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -1079,7 +1583,7 @@ func (h *ProxyHandler) fetchClaudeOAuthUsage(account *model.Account) (*repositor
 
 // recordUsage 记录使用统计（异步执行）
 func (h *ProxyHandler) recordUsage(c *gin.Context, modelName string, usage *adapter.StreamResult, isStream bool, requestBody []byte, responseBody []byte, upstreamStatusCode int, accountID uint) {
-	log := logger.GetLogger("proxy")
+	log := logger.GetLogger("proxy").Ctx(c.Request.Context())
 
 	// 从 context 获取 API Key 信息
 	apiKeyID, _ := c.Get("api_key_id")
@@ -1121,6 +1625,49 @@ func (h *ProxyHandler) recordUsage(c *gin.Context, modelName string, usage *adap
 	ratedOutputTokens := int(float64(usage.OutputTokens) * priceRate)
 	ratedCacheCreationTokens := int(float64(usage.CacheCreationInputTokens) * priceRate)
 	ratedCacheReadTokens := int(float64(usage.CacheReadInputTokens) * priceRate)
+	ratedThinkingTokens := int(float64(usage.ThinkingTokens) * priceRate)
+
+	// 批处理请求按折扣计费
+	isBatch := c.GetBool(batchBillingCtxKey)
+
+	// 流式响应疑似截断时按配置系数计费（1 照常，0 不计费）
+	truncated := isStream && usage.Truncated()
+	costRate := 1.0 // 倍率已应用到 token，这里只叠加截断系数
+	if truncated {
+		costRate = service.GetConfigService().GetStreamTruncatedPriceRatio()
+		log.WarnZ("流式响应疑似截断",
+			logger.String("model", modelName),
+			logger.Uint("account_id", accountID),
+			logger.Float64("cost_rate", costRate),
+		)
+	}
+
+	// token 为本地估算（上游未返回 usage）时按配置决定是否计费
+	if usage.Estimated {
+		estimateBilling := service.GetConfigService().GetTokenEstimateBilling()
+		if !estimateBilling {
+			costRate = 0
+		}
+		log.WarnZ("上游未返回 usage，使用本地估算 token",
+			logger.String("model", modelName),
+			logger.Uint("account_id", accountID),
+			logger.Int("input_tokens", usage.InputTokens),
+			logger.Int("output_tokens", usage.OutputTokens),
+			logger.Bool("billing", estimateBilling),
+		)
+	}
+
+	// 响应缓存命中时按缓存命中系数计费（未请求上游）
+	cacheHit := c.GetBool(responseCacheHitCtxKey)
+	if cacheHit {
+		costRate = service.GetConfigService().GetResponseCacheHitPriceRatio()
+	}
+
+	// 请求耗时（到记录使用统计时响应已结束）
+	durationMs := requestDuration(c).Milliseconds()
+	requestID := c.GetString(middleware.RequestIDCtxKey)
+	region := c.GetString(accountRegionCtxKey)
+	trafficGroup := c.GetString(trafficGroupCtxKey)
 
 	log.InfoZ("使用统计",
 		logger.String("model", modelName),
@@ -1134,6 +1681,11 @@ func (h *ProxyHandler) recordUsage(c *gin.Context, modelName string, usage *adap
 	// 异步记录使用统计
 	go func() {
 		ctx := context.Background()
+		platform := scheduler.DetectPlatform(modelName)
+		if !cacheHit {
+			// 指标记录上游原始 token 数，倍率只影响计费
+			metrics.AddTokens(platform, usage.InputTokens, usage.OutputTokens, usage.CacheCreationInputTokens, usage.CacheReadInputTokens)
+		}
 
 		// 计算费用（使用倍率后的 token）
 		tokenUsage := &service.TokenUsage{
@@ -1141,8 +1693,16 @@ func (h *ProxyHandler) recordUsage(c *gin.Context, modelName string, usage *adap
 			OutputTokens:             ratedOutputTokens,
 			CacheCreationInputTokens: ratedCacheCreationTokens,
 			CacheReadInputTokens:     ratedCacheReadTokens,
+			ThinkingTokens:           ratedThinkingTokens,
+			ContextTokens:            usage.InputTokens + usage.CacheCreationInputTokens + usage.CacheReadInputTokens, // 长上下文档位按原始 token 判断
+		}
+		var costBreakdown *service.CostBreakdown
+		var err error
+		if isBatch {
+			costBreakdown, err = h.pricingService.CalculateBatchCost(ctx, modelName, tokenUsage, costRate)
+		} else {
+			costBreakdown, err = h.pricingService.CalculateCost(ctx, modelName, tokenUsage, costRate)
 		}
-		costBreakdown, err := h.pricingService.CalculateCost(ctx, modelName, tokenUsage, 1.0) // 倍率已应用到token，这里用1.0
 		if err != nil {
 			log.ErrorZ("计算费用失败",
 				logger.Uint("user_id", uid),
@@ -1152,14 +1712,36 @@ func (h *ProxyHandler) recordUsage(c *gin.Context, modelName string, usage *adap
 			return
 		}
 
+		// 账户成本使用原始 token（付给上游的费用，不受用户倍率和截断系数影响），缓存命中没有上游费用
+		var accountCost float64
+		if !cacheHit {
+			accountCost, err = h.pricingService.CalculateAccountCost(ctx, modelName, &service.TokenUsage{
+				InputTokens:              usage.InputTokens,
+				OutputTokens:             usage.OutputTokens,
+				CacheCreationInputTokens: usage.CacheCreationInputTokens,
+				CacheReadInputTokens:     usage.CacheReadInputTokens,
+				ThinkingTokens:           usage.ThinkingTokens,
+			}, isBatch)
+		}
+		if err != nil {
+			log.ErrorZ("计算账户成本失败",
+				logger.Uint("account_id", accountID),
+				logger.String("model", modelName),
+				logger.Err(err),
+			)
+		}
+
 		// 构建请求日志（使用倍率后的 token）
 		requestLog := &model.RequestLog{
 			AccountID:                accountID,
 			UserID:                   &uid,
 			APIKeyID:                 &keyID,
-			Platform:                 scheduler.DetectPlatform(modelName),
+			Platform:                 platform,
 			Model:                    modelName,
 			Endpoint:                 c.Request.URL.Path,
+			Region:                   region,
+			TrafficGroup:             trafficGroup,
+			RequestID:                requestID,
 			Method:                   c.Request.Method,
 			Path:                     c.Request.URL.Path,
 			RequestIP:                c.ClientIP(),
@@ -1168,14 +1750,22 @@ func (h *ProxyHandler) recordUsage(c *gin.Context, modelName string, usage *adap
 			OutputTokens:             ratedOutputTokens,
 			CacheCreationInputTokens: ratedCacheCreationTokens,
 			CacheReadInputTokens:     ratedCacheReadTokens,
+			ThinkingTokens:           ratedThinkingTokens,
 			TotalTokens:              ratedInputTokens + ratedOutputTokens + ratedCacheCreationTokens + ratedCacheReadTokens,
 			InputCost:                costBreakdown.InputCost,
 			OutputCost:               costBreakdown.OutputCost,
+			ThinkingCost:             costBreakdown.ThinkingCost,
 			CacheCreateCost:          costBreakdown.CacheCreateCost,
 			CacheReadCost:            costBreakdown.CacheReadCost,
 			TotalCost:                costBreakdown.TotalCost,
+			LongContext:              costBreakdown.LongContext,
+			AccountCost:              accountCost,
 			Success:                  true,
+			Truncated:                truncated,
+			TokensEstimated:          usage.Estimated,
+			ResponseCacheHit:         cacheHit,
 			StatusCode:               200,
+			Duration:                 durationMs,
 			UpstreamStatusCode:       upstreamStatusCode,
 			CreatedAt:                time.Now(),
 		}
@@ -1200,6 +1790,9 @@ func (h *ProxyHandler) recordUsage(c *gin.Context, modelName string, usage *adap
 		// 直接保存请求日志到数据库
 		LogRequest(requestLog)
 
+		// 脱敏后转发到外部日志系统
+		forwardRequestLog(requestID, requestLog)
+
 		// 记录到 Redis（倍率已应用，这里用 1.0）
 		if err := h.usageService.RecordRequest(ctx, uid, keyID, requestLog, 1.0); err != nil {
 			log.ErrorZ("记录使用统计失败",
@@ -1221,39 +1814,17 @@ func (h *ProxyHandler) recordUsage(c *gin.Context, modelName string, usage *adap
 			)
 		}
 
-		// 记录账户费用到 Redis
+		// 记录账户成本（原始 token 计算，与用户计费分开）
 		if accountID > 0 {
-			if err := h.usageService.IncrementAccountCost(ctx, accountID, costBreakdown.TotalCost); err != nil {
+			if err := h.usageService.IncrementAccountCost(ctx, accountID, accountCost); err != nil {
 				log.ErrorZ("记录账户费用失败",
 					logger.Uint("account_id", accountID),
-					logger.Float64("total_cost", costBreakdown.TotalCost),
+					logger.Float64("account_cost", accountCost),
 					logger.Err(err),
 				)
 			}
 		}
 
-		// 增量更新 MySQL 每日汇总（使用倍率后的 token）
-		dailyUsage := &model.DailyUsage{
-			RequestCount:             1,
-			InputTokens:              int64(ratedInputTokens),
-			OutputTokens:             int64(ratedOutputTokens),
-			CacheCreationInputTokens: int64(ratedCacheCreationTokens),
-			CacheReadInputTokens:     int64(ratedCacheReadTokens),
-			TotalTokens:              totalTokens,
-			InputCost:                costBreakdown.InputCost,
-			OutputCost:               costBreakdown.OutputCost,
-			CacheCreateCost:          costBreakdown.CacheCreateCost,
-			CacheReadCost:            costBreakdown.CacheReadCost,
-			TotalCost:                costBreakdown.TotalCost,
-		}
-		if err := h.dailyUsageRepo.IncrementUsage(uid, modelName, dailyUsage); err != nil {
-			log.ErrorZ("更新每日汇总失败",
-				logger.Uint("user_id", uid),
-				logger.String("model", modelName),
-				logger.Err(err),
-			)
-		}
-
 		// 更新 API Key 使用统计（MySQL）
 		if keyID > 0 {
 			if err := h.apiKeyService.IncrementUsage(keyID, totalTokens, costBreakdown.TotalCost); err != nil {
@@ -1300,8 +1871,10 @@ func (h *ProxyHandler) recordUsage(c *gin.Context, modelName string, usage *adap
 			logger.Int("output_tokens", ratedOutputTokens),
 			logger.Int("cache_creation_tokens", ratedCacheCreationTokens),
 			logger.Int("cache_read_tokens", ratedCacheReadTokens),
+			logger.Int("thinking_tokens", ratedThinkingTokens),
 			logger.Float64("input_cost", costBreakdown.InputCost),
 			logger.Float64("output_cost", costBreakdown.OutputCost),
+			logger.Float64("thinking_cost", costBreakdown.ThinkingCost),
 			logger.Float64("total_cost", costBreakdown.TotalCost),
 			logger.Float64("price_rate", priceRate),
 			logger.String("client_ip", c.ClientIP()),
@@ -1312,12 +1885,44 @@ func (h *ProxyHandler) recordUsage(c *gin.Context, modelName string, usage *adap
 // recordNonStreamUsage 记录非流式请求的使用统计
 func (h *ProxyHandler) recordNonStreamUsage(c *gin.Context, modelName string, resp *adapter.Response, requestBody []byte, responseBody []byte, upstreamStatusCode int, accountID uint) {
 	usage := &adapter.StreamResult{
-		InputTokens:  resp.InputTokens,
-		OutputTokens: resp.OutputTokens,
+		InputTokens:          resp.InputTokens,
+		OutputTokens:         resp.OutputTokens,
+		CacheReadInputTokens: resp.CacheReadInputTokens,
+		ThinkingTokens:       resp.ThinkingTokens,
 	}
 	h.recordUsage(c, modelName, usage, false, requestBody, responseBody, upstreamStatusCode, accountID)
 }
 
+// statusClientClosedRequest 客户端主动断开时使用的状态码（nginx 约定的 499）
+const statusClientClosedRequest = 499
+
+// writeUpstreamErrorBody 开启透传上游错误体时，原样返回上游错误体并保留上游状态码
+// 仅用于非流式请求（流式响应头已发出，无法再改状态码）；返回 false 表示未写入，按自定义错误处理
+func writeUpstreamErrorBody(c *gin.Context, err error) bool {
+	if !service.GetConfigService().GetPassthroughUpstreamError() {
+		return false
+	}
+	var upstreamErr *adapter.UpstreamError
+	if !errors.As(err, &upstreamErr) || len(upstreamErr.Body) == 0 {
+		return false
+	}
+
+	contentType := "application/json"
+	if !json.Valid(upstreamErr.Body) {
+		contentType = "text/plain; charset=utf-8"
+	}
+	c.Data(upstreamErr.StatusCode, contentType, upstreamErr.Body)
+	return true
+}
+
+// geminiErrorStatus Gemini 格式错误的状态码和 status：请求超时为 504 DEADLINE_EXCEEDED，其余为 502 UNAVAILABLE
+func geminiErrorStatus(err error) (int, string) {
+	if errors.Is(err, scheduler.ErrRequestTimeout) {
+		return http.StatusGatewayTimeout, "DEADLINE_EXCEEDED"
+	}
+	return http.StatusBadGateway, "UNAVAILABLE"
+}
+
 // getProxyErrorTypeAndCode 根据错误判断错误类型和HTTP状态码
 // 如果是未知错误，会自动发现并注册到数据库
 func getProxyErrorTypeAndCode(err error) (string, int) {
@@ -1325,6 +1930,31 @@ func getProxyErrorTypeAndCode(err error) (string, int) {
 		return model.ErrorTypeUpstreamError, http.StatusBadGateway
 	}
 
+	// 客户端主动断开（由重试层根据请求上下文判断），不是上游问题
+	if errors.Is(err, scheduler.ErrClientCanceled) {
+		return model.ErrorTypeClientCanceled, statusClientClosedRequest
+	}
+
+	// 超过客户端指定的请求超时（X-Request-Timeout）
+	if errors.Is(err, scheduler.ErrRequestTimeout) {
+		return model.ErrorTypeRequestTimeout, http.StatusGatewayTimeout
+	}
+
+	// 严格粘性会话的绑定账户不可用，由客户端决定是否重开对话
+	if errors.Is(err, scheduler.ErrSessionAccountUnavailable) {
+		return model.ErrorTypeSessionAccountUnavailable, http.StatusConflict
+	}
+
+	// 强制指定的账户不存在或与请求不匹配（权限已在认证中间件校验）
+	if errors.Is(err, scheduler.ErrForcedAccountUnavailable) {
+		return model.ErrorTypeForcedAccountUnavailable, http.StatusBadRequest
+	}
+
+	// 账户并发全满且排队等待超时
+	if errors.Is(err, scheduler.ErrAccountConcurrencyFull) {
+		return model.ErrorTypeAccountConcurrency, http.StatusServiceUnavailable
+	}
+
 	// 优先根据上游状态码判断
 	var upstreamErr *adapter.UpstreamError
 	if errors.As(err, &upstreamErr) {
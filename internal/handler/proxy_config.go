@@ -6,6 +6,7 @@
  *   - 代理启用/禁用
  *   - 默认代理设置
  *   - 代理连通性测试
+ *   - 备用代理格式校验
  * 重要程度：⭐⭐⭐ 一般（代理配置管理）
  * 依赖模块：service, model
  */
@@ -79,6 +80,10 @@ func CreateProxyConfig(c *gin.Context) {
 	if proxy.Type == "" {
 		proxy.Type = model.ProxyTypeHTTP
 	}
+	if err := validateFallbackURLs(&proxy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	if err := service.GetProxyService().Create(&proxy); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -112,6 +117,11 @@ func UpdateProxyConfig(c *gin.Context) {
 		return
 	}
 
+	if err := validateFallbackURLs(&proxy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	proxy.ID = uint(id)
 	proxy.CreatedAt = existing.CreatedAt
 
@@ -123,6 +133,22 @@ func UpdateProxyConfig(c *gin.Context) {
 	c.JSON(http.StatusOK, proxy)
 }
 
+// validateFallbackURLs 校验备用代理 URL 格式
+func validateFallbackURLs(p *model.Proxy) error {
+	for _, fallbackURL := range p.GetFallbackURLs() {
+		u, err := url.Parse(fallbackURL)
+		if err != nil || u.Hostname() == "" || u.Port() == "" {
+			return fmt.Errorf("备用代理格式错误: %s", fallbackURL)
+		}
+		switch u.Scheme {
+		case model.ProxyTypeHTTP, model.ProxyTypeHTTPS, model.ProxyTypeSOCKS5:
+		default:
+			return fmt.Errorf("备用代理类型不支持: %s", u.Scheme)
+		}
+	}
+	return nil
+}
+
 // DeleteProxyConfig 删除代理配置
 func DeleteProxyConfig(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -4,9 +4,11 @@
  *   - 请求日志列表查询（分页、筛选）
  *   - 请求汇总统计
  *   - 账户负载统计
+ *   - region 延迟统计
+ *   - 请求重放（调试）
  *   - 按时间范围查询
  * 重要程度：⭐⭐⭐ 一般（日志查询功能）
- * 依赖模块：repository
+ * 依赖模块：repository, service
  */
 package handler
 
@@ -16,18 +18,21 @@ import (
 	"time"
 
 	"go-aiproxy/internal/repository"
+	"go-aiproxy/internal/service"
 	"go-aiproxy/pkg/response"
 
 	"github.com/gin-gonic/gin"
 )
 
 type RequestLogHandler struct {
-	repo *repository.RequestLogRepository
+	repo          *repository.RequestLogRepository
+	replayService *service.RequestReplayService
 }
 
 func NewRequestLogHandler() *RequestLogHandler {
 	return &RequestLogHandler{
-		repo: repository.NewRequestLogRepository(),
+		repo:          repository.NewRequestLogRepository(),
+		replayService: service.NewRequestReplayService(),
 	}
 }
 
@@ -50,6 +55,12 @@ func (h *RequestLogHandler) List(c *gin.Context) {
 	if success := c.Query("success"); success != "" {
 		filters["success"] = success == "true"
 	}
+	if requestID := c.Query("request_id"); requestID != "" {
+		filters["request_id"] = requestID
+	}
+	if group := c.Query("traffic_group"); group != "" {
+		filters["traffic_group"] = group
+	}
 	if startTime := c.Query("start_time"); startTime != "" {
 		if t, err := time.Parse(time.RFC3339, startTime); err == nil {
 			filters["start_time"] = t
@@ -121,3 +132,58 @@ func (h *RequestLogHandler) GetAccountLoadStats(c *gin.Context) {
 
 	response.Success(c, stats)
 }
+
+// GetRegionLatencyStats 获取各 region 延迟统计
+func (h *RequestLogHandler) GetRegionLatencyStats(c *gin.Context) {
+	// 默认最近24小时
+	endTime := time.Now()
+	startTime := endTime.Add(-24 * time.Hour)
+
+	if start := c.Query("start_time"); start != "" {
+		if t, err := time.Parse(time.RFC3339, start); err == nil {
+			startTime = t
+		}
+	}
+	if end := c.Query("end_time"); end != "" {
+		if t, err := time.Parse(time.RFC3339, end); err == nil {
+			endTime = t
+		}
+	}
+
+	stats, err := h.repo.GetRegionLatencyStats(startTime, endTime)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(c, stats)
+}
+
+// Replay 重放请求日志，返回上游原始响应（不计费）
+// POST /api/admin/logs/:id/replay
+func (h *RequestLogHandler) Replay(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的日志ID")
+		return
+	}
+
+	var req struct {
+		AccountID uint `json:"account_id"` // 重放使用的账户，为 0 时使用原账户
+	}
+	// 请求体可选
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.BadRequest(c, err.Error())
+			return
+		}
+	}
+
+	result, err := h.replayService.Replay(uint(id), req.AccountID)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.Success(c, result)
+}
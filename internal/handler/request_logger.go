@@ -3,9 +3,11 @@
  * 负责功能：
  *   - 请求日志异步写入
  *   - 日志对象构建
+ *   - 请求耗时计算、转发到外部日志系统
+ *   - 推送到管理后台实时请求流
  *   - 单例模式延迟初始化
  * 重要程度：⭐⭐⭐ 一般（日志记录）
- * 依赖模块：model, repository
+ * 依赖模块：model, repository, service, middleware, livefeed
  */
 package handler
 
@@ -15,8 +17,13 @@ import (
 	"sync"
 	"time"
 
+	"go-aiproxy/internal/livefeed"
+	"go-aiproxy/internal/middleware"
 	"go-aiproxy/internal/model"
 	"go-aiproxy/internal/repository"
+	"go-aiproxy/internal/service"
+
+	"github.com/gin-gonic/gin"
 )
 
 // RequestLogger 请求日志记录器
@@ -42,6 +49,51 @@ func getRequestLogger() *RequestLogger {
 // LogRequest 记录请求
 func LogRequest(log *model.RequestLog) {
 	go getRequestLogger().repo.Create(log)
+	publishLiveRequest(log)
+}
+
+// publishLiveRequest 推送到实时请求流（无订阅者时直接返回），只包含统计字段
+func publishLiveRequest(log *model.RequestLog) {
+	feed := livefeed.GetBus()
+	if !feed.Active() {
+		return
+	}
+	event := &livefeed.Event{
+		Type:        livefeed.EventRequest,
+		Time:        log.CreatedAt,
+		RequestID:   log.RequestID,
+		AccountID:   log.AccountID,
+		Platform:    log.Platform,
+		Model:       log.Model,
+		Success:     log.Success,
+		StatusCode:  log.StatusCode,
+		LatencyMs:   log.Duration,
+		TotalTokens: log.TotalTokens,
+		TotalCost:   log.TotalCost,
+		Message:     log.Error,
+	}
+	if log.UserID != nil {
+		event.UserID = *log.UserID
+	}
+	if log.APIKeyID != nil {
+		event.APIKeyID = *log.APIKeyID
+	}
+	feed.Publish(event)
+}
+
+// requestDuration 获取请求已耗时（从 Logger 中间件记录的开始时间算起）
+func requestDuration(c *gin.Context) time.Duration {
+	if start, ok := c.Get(middleware.RequestStartCtxKey); ok {
+		if t, ok := start.(time.Time); ok {
+			return time.Since(t)
+		}
+	}
+	return 0
+}
+
+// forwardRequestLog 脱敏后转发到外部日志系统（未启用时忽略）
+func forwardRequestLog(requestID string, log *model.RequestLog) {
+	service.GetLogForwarder().Forward(service.NewForwardedLog(requestID, log))
 }
 
 // BuildRequestLog 构建请求日志
@@ -157,7 +209,7 @@ func CompleteLogFull(log *model.RequestLog, success bool, statusCode int, errMsg
 	log.OutputCost = outputCost
 	log.CacheCreateCost = cacheCreateCost
 	log.CacheReadCost = cacheReadCost
-	log.TotalCost = inputCost + outputCost + cacheCreateCost + cacheReadCost
+	log.TotalCost = inputCost + outputCost + cacheCreateCost + cacheReadCost + log.ThinkingCost // 思考/推理费用由调用方预先设置
 	log.Duration = duration.Milliseconds()
 	LogRequest(log)
 }
@@ -0,0 +1,205 @@
+/*
+ * 文件作用：响应缓存接入，确定性请求命中缓存时直接返回，不请求上游
+ * 负责功能：
+ *   - 判断请求是否可缓存（API Key 开启、非流式、temperature=0、单个候选，不含工具和终端用户标识）
+ *   - 缓存只在当前实例内，多实例部署时各实例分别缓存
+ *   - 命中时返回缓存响应（X-Cache: HIT）并按缓存命中系数计费，Claude 格式附带 prompt caching 统计响应头
+ *   - 上游成功后写入缓存
+ * 重要程度：⭐⭐⭐ 一般（重复调用省钱）
+ * 依赖模块：cache, service, model, adapter
+ */
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-aiproxy/internal/cache"
+	"go-aiproxy/internal/model"
+	"go-aiproxy/internal/proxy/adapter"
+	"go-aiproxy/internal/service"
+	"go-aiproxy/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// responseCacheHeader 响应头，标记是否命中响应缓存（HIT / MISS）
+	responseCacheHeader = "X-Cache"
+	// responseCacheHitCtxKey 本请求由响应缓存返回（计费按缓存命中系数，无账户成本）
+	responseCacheHitCtxKey = "response_cache_hit"
+)
+
+// responseCacheKeyFor 返回本请求的响应缓存键和缓存时间，不可缓存时返回空键
+// 只缓存确定性请求；客户端可用 Cache-Control: no-cache / no-store 跳过缓存
+func responseCacheKeyFor(c *gin.Context, format string) (string, time.Duration) {
+	v, ok := c.Get("api_key")
+	if !ok {
+		return "", 0
+	}
+	key, ok := v.(*model.APIKey)
+	if !ok || !key.ResponseCacheEnabled {
+		return "", 0
+	}
+	// 强制指定账户用于调试单个账户，不走缓存
+	if _, forced := c.Get("force_account_id"); forced {
+		return "", 0
+	}
+	cacheControl := strings.ToLower(c.GetHeader("Cache-Control"))
+	if strings.Contains(cacheControl, "no-cache") || strings.Contains(cacheControl, "no-store") {
+		return "", 0
+	}
+
+	var requestBody []byte
+	if rb, ok := c.Get("request_body"); ok {
+		requestBody, _ = rb.([]byte)
+	}
+	if !isDeterministicRequest(requestBody, format) {
+		return "", 0
+	}
+
+	ttl := time.Duration(key.ResponseCacheTTL) * time.Second
+	if ttl <= 0 {
+		ttl = service.GetConfigService().GetResponseCacheTTL()
+	}
+	if ttl <= 0 {
+		return "", 0
+	}
+	return cache.ResponseCacheKey(key.ID, c.Request.URL.Path, requestBody), ttl
+}
+
+// isDeterministicRequest 请求是否可缓存：非流式、显式 temperature=0、只要一个候选
+// 未设置 temperature 时上游使用默认值（通常为 1），结果带随机性，不缓存
+// 带工具定义、工具结果或终端用户标识的请求包含调用方上下文，不缓存
+func isDeterministicRequest(body []byte, format string) bool {
+	var req map[string]interface{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return false
+	}
+	if stream, _ := req["stream"].(bool); stream {
+		return false
+	}
+	if hasSensitiveContext(req) {
+		return false
+	}
+
+	temperature, ok := req["temperature"].(float64)
+	switch format {
+	case adapter.RequestFormatOpenAI:
+		if n, has := req["n"].(float64); has && n > 1 {
+			return false
+		}
+	case adapter.RequestFormatGemini:
+		for _, configKey := range []string{"generationConfig", "generation_config"} {
+			config, _ := req[configKey].(map[string]interface{})
+			if t, has := config["temperature"].(float64); has {
+				temperature, ok = t, true
+			}
+			if n, has := config["candidateCount"].(float64); has && n > 1 {
+				return false
+			}
+		}
+	}
+	return ok && temperature == 0
+}
+
+// hasSensitiveContext 请求是否包含不应缓存的上下文：
+// 工具定义（tools / functions）、工具结果（tool_result 内容块、tool / function 角色消息、Gemini functionResponse）、
+// 终端用户标识（metadata.user_id、OpenAI user）
+func hasSensitiveContext(req map[string]interface{}) bool {
+	for _, key := range []string{"tools", "functions"} {
+		if tools, ok := req[key].([]interface{}); ok && len(tools) > 0 {
+			return true
+		}
+	}
+	if metadata, ok := req["metadata"].(map[string]interface{}); ok {
+		if userID, _ := metadata["user_id"].(string); userID != "" {
+			return true
+		}
+	}
+	if user, _ := req["user"].(string); user != "" {
+		return true
+	}
+
+	for _, key := range []string{"messages", "contents"} {
+		messages, _ := req[key].([]interface{})
+		for _, msg := range messages {
+			msgMap, ok := msg.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if role, _ := msgMap["role"].(string); role == "tool" || role == "function" {
+				return true
+			}
+			blocks, _ := msgMap["content"].([]interface{})
+			if parts, ok := msgMap["parts"].([]interface{}); ok {
+				blocks = parts
+			}
+			for _, block := range blocks {
+				blockMap, ok := block.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if blockMap["type"] == "tool_result" || blockMap["functionResponse"] != nil || blockMap["function_response"] != nil {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// serveCachedResponse 命中响应缓存时直接返回缓存的响应并记录使用统计，返回 false 表示未命中
+// format 为请求格式，Claude 格式与上游响应一样附带 prompt caching 统计响应头（取缓存时的 usage）
+func (h *ProxyHandler) serveCachedResponse(c *gin.Context, cacheKey string, format string) bool {
+	entry := cache.GetResponseCache().Get(cacheKey)
+	if entry == nil {
+		c.Header(responseCacheHeader, "MISS")
+		return false
+	}
+
+	logger.GetLogger("proxy").Ctx(c.Request.Context()).InfoZ("响应缓存命中",
+		logger.Uint("api_key_id", c.GetUint("api_key_id")),
+		logger.String("model", entry.Model),
+		logger.String("cached_at", entry.CreatedAt.Format(time.RFC3339)),
+	)
+
+	var requestBody []byte
+	if rb, ok := c.Get("request_body"); ok {
+		requestBody, _ = rb.([]byte)
+	}
+	c.Set(responseCacheHitCtxKey, true)
+	h.recordUsage(c, entry.Model, &adapter.StreamResult{
+		InputTokens:          entry.InputTokens,
+		OutputTokens:         entry.OutputTokens,
+		CacheReadInputTokens: entry.CacheReadInputTokens,
+		ThinkingTokens:       entry.ThinkingTokens,
+	}, false, requestBody, entry.Body, 0, 0)
+
+	c.Header(responseCacheHeader, "HIT")
+	if format == adapter.RequestFormatClaude {
+		setPromptCacheHeaders(c, entry.PromptCacheCreationTokens, entry.PromptCacheReadTokens)
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", entry.Body)
+	return true
+}
+
+// storeCachedResponse 上游成功后写入响应缓存（保存原始 usage，命中时按当时的倍率重新计费）
+func storeCachedResponse(cacheKey string, ttl time.Duration, billingModel string, resp *adapter.Response, responseBody []byte) {
+	if cacheKey == "" {
+		return
+	}
+	cache.GetResponseCache().Set(cacheKey, &cache.ResponseCacheEntry{
+		Body:                 responseBody,
+		Model:                billingModel,
+		InputTokens:          resp.InputTokens,
+		OutputTokens:         resp.OutputTokens,
+		CacheReadInputTokens: resp.CacheReadInputTokens,
+		ThinkingTokens:       resp.ThinkingTokens,
+
+		PromptCacheCreationTokens: resp.PromptCacheCreationTokens,
+		PromptCacheReadTokens:     resp.PromptCacheReadTokens,
+	}, ttl, service.GetConfigService().GetResponseCacheMaxEntries())
+}
@@ -0,0 +1,65 @@
+/*
+ * 文件作用：OpenAI Responses 非 Codex CLI 请求适配，按配置裁剪字段和处理 instructions
+ * 负责功能：
+ *   - 账户配置优先，未配置时使用全局配置
+ *   - 删除上游不接受的字段（可自定义列表或关闭）
+ *   - instructions 替换 / 仅缺省时填入 / 保持原样
+ * 重要程度：⭐⭐⭐ 一般（不同上游对字段的容忍度不同）
+ * 依赖模块：service, model
+ */
+package handler
+
+import (
+	"strings"
+
+	"go-aiproxy/internal/model"
+	"go-aiproxy/internal/service"
+)
+
+// responsesCompatRule 非 Codex CLI 请求的适配规则
+type responsesCompatRule struct {
+	stripFields  []string
+	instructions string
+}
+
+// responsesCompatRuleFor 获取账户生效的适配规则，账户未配置的项使用全局配置
+func responsesCompatRuleFor(account *model.Account) responsesCompatRule {
+	configService := service.GetConfigService()
+
+	stripFields := account.ResponsesStripFields
+	if stripFields == "" {
+		stripFields = configService.GetResponsesCompatStripFields()
+	}
+	instructions := account.ResponsesInstructions
+	if !service.IsValidResponsesInstructionsMode(instructions) {
+		instructions = configService.GetResponsesCompatInstructions()
+	}
+
+	rule := responsesCompatRule{instructions: instructions}
+	if stripFields != service.ResponsesStripFieldsNone {
+		for _, field := range strings.Split(stripFields, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				rule.stripFields = append(rule.stripFields, field)
+			}
+		}
+	}
+	return rule
+}
+
+// applyResponsesCompat 按规则适配请求体（原地修改），并强制流式
+func applyResponsesCompat(reqBody map[string]interface{}, rule responsesCompatRule) {
+	for _, field := range rule.stripFields {
+		delete(reqBody, field)
+	}
+
+	switch rule.instructions {
+	case service.ResponsesInstructionsOverride:
+		reqBody["instructions"] = DefaultCodexInstructions
+	case service.ResponsesInstructionsFill:
+		if instructions, _ := reqBody["instructions"].(string); instructions == "" {
+			reqBody["instructions"] = DefaultCodexInstructions
+		}
+	}
+
+	reqBody["stream"] = true
+}
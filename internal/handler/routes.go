@@ -3,7 +3,10 @@
  * 负责功能：
  *   - 公开接口路由（登录、注册、验证码）
  *   - 管理后台路由（/api/admin/*）
+ *   - 组织隔离：全局配置类接口仅超级管理员可用，带 :id 的资源接口校验组织归属
  *   - 代理转发路由（/claude/*, /openai/*, /responses）
+ *   - 监控指标路由（/metrics）、实时请求流（/api/admin/live，SSE）
+ *   - 健康检查路由（/health、/healthz 存活、/readyz 就绪）
  *   - 中间件配置（JWT、API Key、操作日志）
  *   - 静态文件服务
  * 重要程度：⭐⭐⭐⭐⭐ 核心（所有请求的入口）
@@ -12,7 +15,9 @@
 package handler
 
 import (
+	"go-aiproxy/internal/config"
 	"go-aiproxy/internal/middleware"
+	"go-aiproxy/internal/model"
 	"go-aiproxy/internal/repository"
 
 	"github.com/gin-gonic/gin"
@@ -24,6 +29,17 @@ func RegisterRoutes(r *gin.Engine) {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
 
+	// 存活 / 就绪探针（供 Kubernetes 等编排系统分别配置）
+	healthHandler := NewHealthHandler()
+	r.GET("/healthz", healthHandler.Liveness)
+	r.GET("/readyz", healthHandler.Readiness)
+
+	// Prometheus 指标（独立令牌保护，不走 JWT）
+	if config.Cfg != nil && config.Cfg.Metrics.Enabled {
+		metricsHandler := NewMetricsHandler()
+		r.GET("/metrics", middleware.MetricsAuth(config.Cfg.Metrics.Token), metricsHandler.Serve)
+	}
+
 	// 全局操作日志中间件（放在认证之后，记录所有写操作）
 	r.Use(middleware.OperationLogger())
 
@@ -62,14 +78,25 @@ func RegisterRoutes(r *gin.Engine) {
 	// ========== 代理转发接口 (需要 API Key 认证) ==========
 	proxyGroup := r.Group("")
 	proxyGroup.Use(middleware.APIKeyAuth())
+	proxyGroup.Use(middleware.APIKeyRateLimit())        // API Key 令牌桶限速
 	proxyGroup.Use(middleware.ClientFilter())           // 客户端过滤
 	proxyGroup.Use(middleware.CheckAllowedClients())    // API Key 客户端限制检查
+	proxyGroup.Use(middleware.Idempotency())            // Idempotency-Key 重复请求合并
 	proxyGroup.Use(middleware.UserConcurrencyControl()) // 用户并发控制
+	proxyGroup.Use(middleware.RequestTimeout())         // X-Request-Timeout 请求超时
 	{
 		// ========== 按平台区分的路由 ==========
 		// Claude 平台 - 使用 Claude 原生格式
 		proxyGroup.POST("/claude/v1/messages", proxyHandler.ClaudeMessages)
 
+		// Claude Message Batches API（异步批量，半价计费，查询和拉结果落到创建批次的账户）
+		proxyGroup.POST("/claude/v1/messages/batches", proxyHandler.ClaudeCreateBatch)
+		proxyGroup.GET("/claude/v1/messages/batches/:batch_id", proxyHandler.ClaudeGetBatch)
+		proxyGroup.GET("/claude/v1/messages/batches/:batch_id/results", proxyHandler.ClaudeGetBatchResults)
+		proxyGroup.POST("/v1/messages/batches", proxyHandler.ClaudeCreateBatch)
+		proxyGroup.GET("/v1/messages/batches/:batch_id", proxyHandler.ClaudeGetBatch)
+		proxyGroup.GET("/v1/messages/batches/:batch_id/results", proxyHandler.ClaudeGetBatchResults)
+
 		// OpenAI 平台 - 使用 OpenAI 原生格式
 		proxyGroup.POST("/openai/v1/chat/completions", proxyHandler.OpenAIChatCompletions)
 
@@ -87,6 +114,14 @@ func RegisterRoutes(r *gin.Engine) {
 
 		// Gemini 平台 - 使用 Gemini 原生格式
 		proxyGroup.POST("/gemini/v1/chat", proxyHandler.GeminiChat)
+
+		// 文本向量化（OpenAI 兼容格式，调度 OpenAI / Gemini API Key 账户）
+		proxyGroup.POST("/v1/embeddings", proxyHandler.OpenAIEmbeddings)
+		proxyGroup.POST("/openai/v1/embeddings", proxyHandler.OpenAIEmbeddings)
+
+		// 模型列表聚合（OpenAI models list 格式，按 API Key 权限过滤）
+		proxyGroup.GET("/v1/models", modelHandler.ListForAPIKey)
+		proxyGroup.GET("/openai/v1/models", modelHandler.ListForAPIKey)
 	}
 
 	// API Key Handler
@@ -145,8 +180,21 @@ func RegisterRoutes(r *gin.Engine) {
 		admin := api.Group("/admin")
 		admin.Use(middleware.AdminRequired())
 		{
+			// 全局配置类接口只对超级管理员开放，组织管理员只能管理本组织的用户、账户、API Key 和套餐
+			superAdmin := middleware.SuperAdminRequired()
+
+			// 组织管理
+			orgHandler := NewOrganizationHandler()
+			organizations := admin.Group("/organizations", superAdmin)
+			{
+				organizations.GET("", orgHandler.List)
+				organizations.POST("", orgHandler.Create)
+				organizations.PUT("/:id", orgHandler.Update)
+				organizations.DELETE("/:id", orgHandler.Delete)
+			}
+
 			// 用户管理
-			users := admin.Group("/users")
+			users := admin.Group("/users", orgGuard("id", orgOfModel(&model.User{})), orgGuard("keyId", orgOfModel(&model.APIKey{})))
 			{
 				users.GET("", userHandler.List)
 				users.POST("", userHandler.Create)          // 创建用户
@@ -169,23 +217,38 @@ func RegisterRoutes(r *gin.Engine) {
 			}
 
 			// API Key 管理（所有用户的）
-			adminAPIKeys := admin.Group("/api-keys")
+			adminAPIKeys := admin.Group("/api-keys", orgGuard("id", orgOfModel(&model.APIKey{})))
 			{
-				adminAPIKeys.GET("/lookup", apiKeyHandler.AdminLookup)          // 按ID批量查询 API Key（用于前端映射显示）
-				adminAPIKeys.GET("", apiKeyHandler.AdminListAll)                // 获取所有 API Key
-				adminAPIKeys.GET("/:id/logs", apiKeyHandler.AdminGetAPIKeyLogs) // 获取 API Key 使用日志
+				adminAPIKeys.GET("/lookup", apiKeyHandler.AdminLookup)                               // 按ID批量查询 API Key（用于前端映射显示）
+				adminAPIKeys.GET("", apiKeyHandler.AdminListAll)                                     // 获取所有 API Key
+				adminAPIKeys.GET("/:id/logs", apiKeyHandler.AdminGetAPIKeyLogs)                      // 获取 API Key 使用日志
+				adminAPIKeys.PUT("/:id/allowed-ips", apiKeyHandler.AdminUpdateAllowedIPs)            // 更新 IP 白名单
+				adminAPIKeys.PUT("/:id/model-fallback", apiKeyHandler.AdminUpdateModelFallback)      // 更新模型回退设置
+				adminAPIKeys.PUT("/:id/priority", apiKeyHandler.AdminUpdatePriority)                 // 更新调度优先级
+				adminAPIKeys.PUT("/:id/force-account", apiKeyHandler.AdminUpdateForceAccount)        // 更新强制指定账户权限
+				adminAPIKeys.PUT("/:id/max-output-tokens", apiKeyHandler.AdminUpdateMaxOutputTokens) // 更新单请求输出 token 上限
+				adminAPIKeys.PUT("/:id/system-prompt", apiKeyHandler.AdminUpdateSystemPrompt)        // 更新 Claude system prompt 前缀/后缀
+				adminAPIKeys.PUT("/:id/degrade-response", apiKeyHandler.AdminUpdateDegradeResponse)  // 更新降级响应设置
+				adminAPIKeys.PUT("/:id/canary", apiKeyHandler.AdminUpdateCanary)                     // 更新灰度规则
+				adminAPIKeys.GET("/:id/ip-access", apiKeyHandler.AdminGetIPAccess)                   // 最近命中/拒绝的 IP
 			}
 
 			// 账户管理
-			accounts := admin.Group("/accounts")
+			accounts := admin.Group("/accounts", orgGuard("id", orgOfModel(&model.Account{})))
 			{
 				accounts.GET("/types", accountHandler.GetTypes)
+				accounts.GET("/health-summary", superAdmin, accountHandler.GetHealthSummary)       // 账户健康汇总（仪表盘）
+				accounts.GET("/credential-health", superAdmin, accountHandler.GetCredentialHealth) // 凭证到期看板
+				accounts.POST("/check-all", superAdmin, accountHandler.CheckAllAccounts)           // 一键检测账户可用性（只读报告）
 				accounts.GET("", accountHandler.List)
 				accounts.POST("", accountHandler.Create)
+				accounts.POST("/import", accountHandler.ImportAccounts) // 批量导入（JSON/CSV）
+				accounts.POST("/export", accountHandler.ExportAccounts) // 批量导出（凭证脱敏或加密）
 				accounts.GET("/:id", accountHandler.Get)
 				accounts.PUT("/:id", accountHandler.Update)
 				accounts.DELETE("/:id", accountHandler.Delete)
 				accounts.PUT("/:id/status", accountHandler.UpdateStatus)
+				accounts.PUT("/:id/maintenance", accountHandler.SetMaintenance) // 切换维护模式（不参与调度，健康检查照常）
 				// 健康检测相关操作
 				accounts.POST("/:id/health-check", accountHandler.HealthCheck)   // 手动触发单个账号健康检测
 				accounts.POST("/:id/recover", accountHandler.ForceRecover)       // 强制恢复账号
@@ -193,7 +256,7 @@ func RegisterRoutes(r *gin.Engine) {
 			}
 
 			// 健康检测服务管理
-			healthCheck := admin.Group("/health-check")
+			healthCheck := admin.Group("/health-check", superAdmin)
 			{
 				healthCheck.GET("/status", accountHandler.GetHealthCheckStatus) // 获取健康检测服务状态
 				healthCheck.POST("/trigger", accountHandler.TriggerHealthCheck) // 手动触发全局健康检测
@@ -204,12 +267,27 @@ func RegisterRoutes(r *gin.Engine) {
 			{
 				groups.GET("", accountHandler.ListGroups)
 				groups.GET("/all", accountHandler.GetAllGroups)
-				groups.POST("", accountHandler.CreateGroup)
-				groups.GET("/:id", accountHandler.GetGroup)
-				groups.PUT("/:id", accountHandler.UpdateGroup)
-				groups.DELETE("/:id", accountHandler.DeleteGroup)
-				groups.POST("/:id/accounts", accountHandler.AddAccountToGroup)
-				groups.DELETE("/:id/accounts/:accountId", accountHandler.RemoveAccountFromGroup)
+				groups.POST("", superAdmin, accountHandler.CreateGroup)
+				groups.GET("/:id", superAdmin, accountHandler.GetGroup)
+				groups.PUT("/:id", superAdmin, accountHandler.UpdateGroup)
+				groups.DELETE("/:id", superAdmin, accountHandler.DeleteGroup)
+				groups.POST("/:id/accounts", superAdmin, accountHandler.AddAccountToGroup)
+				groups.DELETE("/:id/accounts/:accountId", superAdmin, accountHandler.RemoveAccountFromGroup)
+			}
+
+			// 账户池管理（池内账户继承池配置，池作为一个逻辑账户参与调度）
+			accountPoolHandler := NewAccountPoolHandler()
+			pools := admin.Group("/account-pools", superAdmin)
+			{
+				pools.GET("", accountPoolHandler.List)
+				pools.GET("/all", accountPoolHandler.GetAll)
+				pools.POST("", accountPoolHandler.Create)
+				pools.GET("/:id", accountPoolHandler.Get)
+				pools.PUT("/:id", accountPoolHandler.Update)
+				pools.DELETE("/:id", accountPoolHandler.Delete)
+				pools.GET("/:id/accounts", accountPoolHandler.GetAccounts)
+				pools.POST("/:id/accounts", accountPoolHandler.AddAccounts)
+				pools.DELETE("/:id/accounts/:accountId", accountPoolHandler.RemoveAccount)
 			}
 
 			// OAuth 授权
@@ -221,16 +299,26 @@ func RegisterRoutes(r *gin.Engine) {
 			}
 
 			// 请求日志
-			logs := admin.Group("/logs")
+			logs := admin.Group("/logs", superAdmin)
 			{
 				logs.GET("", requestLogHandler.List)
 				logs.GET("/summary", requestLogHandler.GetSummary)
 				logs.GET("/account-load", requestLogHandler.GetAccountLoadStats)
+				logs.GET("/region-latency", requestLogHandler.GetRegionLatencyStats)
+				logs.POST("/:id/replay", requestLogHandler.Replay)               // 重放请求（不计费）
 				logs.GET("/usage-summary", usageHandler.AdminGetAllUsageSummary) // 所有用户使用汇总（MySQL）
 			}
 
+			// 实时请求流（SSE）
+			liveHandler := NewLiveHandler()
+			admin.GET("/live", superAdmin, liveHandler.Stream)
+
+			// 用量报表
+			admin.GET("/usage/export", superAdmin, usageHandler.AdminExportUsageReport) // 导出用量报表（CSV，按用户/模型/账户分组）
+			admin.GET("/usage/hourly", superAdmin, usageHandler.AdminGetHourlyUsage)    // 某天 24 小时用量趋势（保留最近 7 天）
+
 			// 操作日志
-			opLogs := admin.Group("/operation-logs")
+			opLogs := admin.Group("/operation-logs", superAdmin)
 			{
 				opLogs.GET("", operationLogHandler.List)
 				opLogs.GET("/stats", operationLogHandler.GetStats)
@@ -243,17 +331,17 @@ func RegisterRoutes(r *gin.Engine) {
 			{
 				models.GET("", modelHandler.List)
 				models.GET("/platforms", modelHandler.GetPlatforms)
-				models.POST("", modelHandler.Create)
+				models.POST("", superAdmin, modelHandler.Create)
 				models.GET("/:id", modelHandler.Get)
-				models.PUT("/:id", modelHandler.Update)
-				models.DELETE("/:id", modelHandler.Delete)
-				models.PUT("/:id/toggle", modelHandler.ToggleEnabled)
-				models.POST("/init-defaults", modelHandler.InitDefaults)
-				models.POST("/reset-defaults", modelHandler.ResetDefaults)
+				models.PUT("/:id", superAdmin, modelHandler.Update)
+				models.DELETE("/:id", superAdmin, modelHandler.Delete)
+				models.PUT("/:id/toggle", superAdmin, modelHandler.ToggleEnabled)
+				models.POST("/init-defaults", superAdmin, modelHandler.InitDefaults)
+				models.POST("/reset-defaults", superAdmin, modelHandler.ResetDefaults)
 			}
 
 			// 模型映射管理
-			modelMappings := admin.Group("/model-mappings")
+			modelMappings := admin.Group("/model-mappings", superAdmin)
 			{
 				modelMappings.GET("", modelMappingHandler.List)
 				modelMappings.POST("", modelMappingHandler.Create)
@@ -266,23 +354,24 @@ func RegisterRoutes(r *gin.Engine) {
 			}
 
 			// 缓存管理
-			cache := admin.Group("/cache")
+			cache := admin.Group("/cache", superAdmin)
 			{
-				cache.GET("/stats", cacheHandler.GetStats)                       // 获取缓存统计
-				cache.GET("/sessions", cacheHandler.ListSessions)                // 列出所有会话
-				cache.DELETE("/sessions/:sessionId", cacheHandler.RemoveSession) // 移除会话
-				cache.GET("/accounts", cacheHandler.ListAccountsCache)           // 列出有缓存的账号（聚合）
-				cache.GET("/users", cacheHandler.ListUsersCache)                 // 列出有缓存的用户（聚合）
-				cache.GET("/unavailable", cacheHandler.ListUnavailableAccounts)  // 列出不可用账户
-				cache.POST("/clear", cacheHandler.ClearCache)                    // 按类型清理缓存
-				cache.DELETE("/users/:id", cacheHandler.ClearUserCache)          // 清理用户缓存
-				cache.DELETE("/api-keys/:id", cacheHandler.ClearAPIKeyCache)     // 清理 API Key 缓存
-				cache.GET("/config", cacheHandler.GetCacheConfig)                // 获取缓存配置
-				cache.PUT("/config", cacheHandler.UpdateCacheConfig)             // 更新缓存配置
+				cache.GET("/stats", cacheHandler.GetStats)                         // 获取缓存统计
+				cache.GET("/sessions", cacheHandler.ListSessions)                  // 列出所有会话
+				cache.DELETE("/sessions/:sessionId", cacheHandler.RemoveSession)   // 移除会话
+				cache.POST("/sessions/unbind", cacheHandler.UnbindAccountSessions) // 按账户批量解绑会话
+				cache.GET("/accounts", cacheHandler.ListAccountsCache)             // 列出有缓存的账号（聚合）
+				cache.GET("/users", cacheHandler.ListUsersCache)                   // 列出有缓存的用户（聚合）
+				cache.GET("/unavailable", cacheHandler.ListUnavailableAccounts)    // 列出不可用账户
+				cache.POST("/clear", cacheHandler.ClearCache)                      // 按类型清理缓存
+				cache.DELETE("/users/:id", cacheHandler.ClearUserCache)            // 清理用户缓存
+				cache.DELETE("/api-keys/:id", cacheHandler.ClearAPIKeyCache)       // 清理 API Key 缓存
+				cache.GET("/config", cacheHandler.GetCacheConfig)                  // 获取缓存配置
+				cache.PUT("/config", cacheHandler.UpdateCacheConfig)               // 更新缓存配置
 			}
 
 			// 账户缓存管理（并发控制和不可用标记）
-			accountCache := admin.Group("/accounts/:id/cache")
+			accountCache := admin.Group("/accounts/:id/cache", orgGuard("id", orgOfModel(&model.Account{})))
 			{
 				accountCache.DELETE("/sessions", cacheHandler.ClearAccountSessions)       // 清除账户会话
 				accountCache.POST("/unavailable", cacheHandler.MarkAccountUnavailable)    // 标记账户不可用
@@ -294,7 +383,7 @@ func RegisterRoutes(r *gin.Engine) {
 
 			// 系统配置管理
 			configHandler := NewConfigHandler()
-			configs := admin.Group("/configs")
+			configs := admin.Group("/configs", superAdmin)
 			{
 				configs.GET("", configHandler.GetAll)                           // 获取所有配置
 				configs.GET("/category/:category", configHandler.GetByCategory) // 获取分类配置
@@ -303,7 +392,7 @@ func RegisterRoutes(r *gin.Engine) {
 
 			// 套餐管理
 			adminPkgHandler := NewPackageHandler()
-			packages := admin.Group("/packages")
+			packages := admin.Group("/packages", orgGuard("id", orgOfModel(&model.Package{})))
 			{
 				packages.GET("", adminPkgHandler.ListPackages)         // 获取所有套餐
 				packages.POST("", adminPkgHandler.CreatePackage)       // 创建套餐
@@ -312,7 +401,7 @@ func RegisterRoutes(r *gin.Engine) {
 			}
 
 			// 用户套餐管理
-			userPackages := admin.Group("/user-packages")
+			userPackages := admin.Group("/user-packages", orgGuard("user_id", orgOfModel(&model.User{})), orgGuard("id", orgOfUserPackage))
 			{
 				userPackages.GET("/user/:user_id", adminPkgHandler.ListUserPackages) // 获取用户的套餐
 				userPackages.POST("/user/:user_id", adminPkgHandler.AssignPackage)   // 给用户分配套餐
@@ -323,22 +412,22 @@ func RegisterRoutes(r *gin.Engine) {
 			// 代理配置管理
 			proxyConfigs := admin.Group("/proxy-configs")
 			{
-				proxyConfigs.GET("", ListProxyConfigs)                    // 获取代理列表
-				proxyConfigs.GET("/enabled", GetEnabledProxyConfigs)      // 获取启用的代理（用于下拉选择）
-				proxyConfigs.GET("/default", GetDefaultProxyConfig)       // 获取默认代理
-				proxyConfigs.DELETE("/default", ClearDefaultProxyConfig)  // 清除默认代理
-				proxyConfigs.POST("", CreateProxyConfig)                  // 创建代理
-				proxyConfigs.POST("/test", TestProxyConnectivity)         // 测试代理连通性
-				proxyConfigs.GET("/:id", GetProxyConfig)                  // 获取单个代理
-				proxyConfigs.PUT("/:id", UpdateProxyConfig)               // 更新代理
-				proxyConfigs.DELETE("/:id", DeleteProxyConfig)            // 删除代理
-				proxyConfigs.PUT("/:id/toggle", ToggleProxyConfigEnabled) // 切换启用状态
-				proxyConfigs.PUT("/:id/default", SetDefaultProxyConfig)   // 设置为默认代理
+				proxyConfigs.GET("", superAdmin, ListProxyConfigs)                    // 获取代理列表
+				proxyConfigs.GET("/enabled", GetEnabledProxyConfigs)                  // 获取启用的代理（用于下拉选择）
+				proxyConfigs.GET("/default", superAdmin, GetDefaultProxyConfig)       // 获取默认代理
+				proxyConfigs.DELETE("/default", superAdmin, ClearDefaultProxyConfig)  // 清除默认代理
+				proxyConfigs.POST("", superAdmin, CreateProxyConfig)                  // 创建代理
+				proxyConfigs.POST("/test", superAdmin, TestProxyConnectivity)         // 测试代理连通性
+				proxyConfigs.GET("/:id", superAdmin, GetProxyConfig)                  // 获取单个代理
+				proxyConfigs.PUT("/:id", superAdmin, UpdateProxyConfig)               // 更新代理
+				proxyConfigs.DELETE("/:id", superAdmin, DeleteProxyConfig)            // 删除代理
+				proxyConfigs.PUT("/:id/toggle", superAdmin, ToggleProxyConfigEnabled) // 切换启用状态
+				proxyConfigs.PUT("/:id/default", superAdmin, SetDefaultProxyConfig)   // 设置为默认代理
 			}
 
 			// 系统监控
 			monitorHandler := NewSystemMonitorHandler()
-			monitor := admin.Group("/monitor")
+			monitor := admin.Group("/monitor", superAdmin)
 			{
 				monitor.GET("", monitorHandler.GetMonitorData)           // 获取完整监控数据
 				monitor.GET("/system", monitorHandler.GetSystemStats)    // 系统资源
@@ -351,7 +440,7 @@ func RegisterRoutes(r *gin.Engine) {
 
 			// 错误消息管理
 			errorMsgHandler := NewErrorMessageHandler()
-			errorMessages := admin.Group("/error-messages")
+			errorMessages := admin.Group("/error-messages", superAdmin)
 			{
 				errorMessages.GET("", errorMsgHandler.List)
 				errorMessages.GET("/code/:code", errorMsgHandler.GetByCode)
@@ -368,7 +457,7 @@ func RegisterRoutes(r *gin.Engine) {
 
 			// 系统日志查看
 			systemLogHandler := NewSystemLogHandler()
-			sysLogs := admin.Group("/system-logs")
+			sysLogs := admin.Group("/system-logs", superAdmin)
 			{
 				sysLogs.GET("/files", systemLogHandler.ListFiles)       // 获取日志文件列表
 				sysLogs.GET("/read", systemLogHandler.ReadFile)         // 读取日志内容
@@ -379,7 +468,7 @@ func RegisterRoutes(r *gin.Engine) {
 
 			// 客户端过滤管理
 			clientFilterHandler := NewClientFilterHandler()
-			clientFilter := admin.Group("/client-filter")
+			clientFilter := admin.Group("/client-filter", superAdmin)
 			{
 				// 全局配置
 				clientFilter.GET("/config", clientFilterHandler.GetConfig)
@@ -408,11 +497,20 @@ func RegisterRoutes(r *gin.Engine) {
 					rules.DELETE("/:id", clientFilterHandler.DeleteRule)
 					rules.PUT("/:id/toggle", clientFilterHandler.ToggleRule)
 				}
+
+				// 访问规则管理（UA 正则 + 路径前缀）
+				accessRules := clientFilter.Group("/access-rules")
+				{
+					accessRules.GET("", clientFilterHandler.ListAccessRules)
+					accessRules.POST("", clientFilterHandler.CreateAccessRule)
+					accessRules.PUT("/:id", clientFilterHandler.UpdateAccessRule)
+					accessRules.DELETE("/:id", clientFilterHandler.DeleteAccessRule)
+				}
 			}
 
 			// 错误规则管理
 			errorRuleHandler := NewErrorRuleHandler()
-			errorRules := admin.Group("/error-rules")
+			errorRules := admin.Group("/error-rules", superAdmin)
 			{
 				errorRules.GET("", errorRuleHandler.List)
 				errorRules.POST("", errorRuleHandler.Create)
@@ -0,0 +1,130 @@
+/*
+ * 文件作用：影子流量（dark traffic），把成功的真实请求异步复制给影子账户做对比测试
+ * 负责功能：
+ *   - 主请求成功后按配置比例复制请求，发给同平台的影子账户（统一按非流式发送）
+ *   - 记录影子账户的成功率、延迟和与主请求结果的一致性（日志 + metrics）
+ *   - 影子结果不返回客户端、不计费、不影响账户状态；同时进行的影子请求超过上限时丢弃
+ * 重要程度：⭐⭐ 辅助（新上游质量评估）
+ * 依赖模块：scheduler, adapter, service, metrics
+ */
+package handler
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go-aiproxy/internal/metrics"
+	"go-aiproxy/internal/proxy/adapter"
+	"go-aiproxy/internal/proxy/scheduler"
+	"go-aiproxy/internal/service"
+	"go-aiproxy/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// shadowRequestTimeout 单个影子请求的超时（不受客户端断开影响）
+const shadowRequestTimeout = 5 * time.Minute
+
+// 影子响应与主响应的一致性
+const (
+	shadowConsistencyMatch    = "match"     // 内容一致
+	shadowConsistencySameStop = "same_stop" // 内容不同但结束原因一致
+	shadowConsistencyMismatch = "mismatch"  // 内容和结束原因都不同
+	shadowConsistencyUnknown  = "unknown"   // 主请求为流式，没有完整内容可对比
+)
+
+// shadowInFlight 正在进行的影子请求数
+var shadowInFlight atomic.Int64
+
+// mirrorToShadow 主请求成功后按比例把请求异步复制给影子账户
+// primary 为主请求的非流式响应，流式请求传 nil（只记录成功率和延迟）
+func (h *ProxyHandler) mirrorToShadow(c *gin.Context, retryReq *scheduler.RetryableRequest, req *adapter.Request, primaryAccountID uint, primary *adapter.Response) {
+	configService := service.GetConfigService()
+	percent := configService.GetShadowTrafficPercent()
+	if percent <= 0 || rand.Intn(100) >= percent {
+		return
+	}
+
+	accounts := h.scheduler.ShadowAccounts(primaryAccountID, req.Model)
+	if len(accounts) == 0 {
+		return
+	}
+
+	log := logger.GetLogger("proxy").Ctx(c.Request.Context())
+	maxInFlight := int64(configService.GetShadowTrafficMaxInFlight())
+	for _, account := range accounts {
+		adp := adapter.Get(account.Type)
+		if adp == nil {
+			continue
+		}
+		if shadowInFlight.Add(1) > maxInFlight {
+			shadowInFlight.Add(-1)
+			metrics.ObserveShadowRequest(account.ID, metrics.ShadowResultDropped, 0, "")
+			continue
+		}
+
+		shadowReq := *retryReq.ApplyModel(account, req)
+		if shadowReq.Stream {
+			shadowReq.Stream = false
+			if len(shadowReq.RawBody) > 0 {
+				shadowReq.RawBody = adapter.SetBodyField(shadowReq.RawBody, "stream", []byte("false"))
+			}
+		}
+
+		go func() {
+			defer shadowInFlight.Add(-1)
+			ctx, cancel := context.WithTimeout(context.Background(), shadowRequestTimeout)
+			defer cancel()
+
+			start := time.Now()
+			resp, err := adp.Send(ctx, account, &shadowReq)
+			latency := time.Since(start)
+			if err == nil && resp.Error != nil {
+				err = errors.New(resp.Error.Message)
+			}
+			if err != nil {
+				metrics.ObserveShadowRequest(account.ID, metrics.ResultFailure, latency, "")
+				log.WarnZ("影子请求失败",
+					logger.Uint("shadow_account_id", account.ID),
+					logger.String("shadow_account_name", account.Name),
+					logger.Uint("primary_account_id", primaryAccountID),
+					logger.String("model", shadowReq.Model),
+					logger.Duration("latency", latency),
+					logger.Err(err),
+				)
+				return
+			}
+
+			consistency := shadowConsistency(primary, resp)
+			metrics.ObserveShadowRequest(account.ID, metrics.ResultSuccess, latency, consistency)
+			log.InfoZ("影子请求完成",
+				logger.Uint("shadow_account_id", account.ID),
+				logger.String("shadow_account_name", account.Name),
+				logger.Uint("primary_account_id", primaryAccountID),
+				logger.String("model", shadowReq.Model),
+				logger.Duration("latency", latency),
+				logger.Int("input_tokens", resp.InputTokens),
+				logger.Int("output_tokens", resp.OutputTokens),
+				logger.String("consistency", consistency),
+			)
+		}()
+	}
+}
+
+// shadowConsistency 对比影子响应与主响应
+func shadowConsistency(primary, shadow *adapter.Response) string {
+	if primary == nil {
+		return shadowConsistencyUnknown
+	}
+	if strings.TrimSpace(primary.Content) == strings.TrimSpace(shadow.Content) {
+		return shadowConsistencyMatch
+	}
+	if primary.StopReason != "" && primary.StopReason == shadow.StopReason {
+		return shadowConsistencySameStop
+	}
+	return shadowConsistencyMismatch
+}
@@ -0,0 +1,51 @@
+/*
+ * 文件作用：活跃流式请求跟踪，供优雅关闭时等待进行中的流式响应写完
+ * 负责功能：
+ *   - 流式入口登记、结束注销（WaitGroup + 计数）
+ *   - 关闭时在 drain 窗口内等待全部流式请求结束
+ * 重要程度：⭐⭐⭐ 一般（发布/重启时避免截断回答）
+ * 依赖模块：无
+ */
+package handler
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	activeStreams     sync.WaitGroup
+	activeStreamCount atomic.Int64
+)
+
+// beginStream 登记一个进行中的流式请求，返回结束时调用的注销函数
+func beginStream() func() {
+	activeStreams.Add(1)
+	activeStreamCount.Add(1)
+	return func() {
+		activeStreamCount.Add(-1)
+		activeStreams.Done()
+	}
+}
+
+// ActiveStreamCount 返回进行中的流式请求数
+func ActiveStreamCount() int64 {
+	return activeStreamCount.Load()
+}
+
+// WaitActiveStreams 等待进行中的流式请求全部结束，ctx 超时返回 false
+func WaitActiveStreams(ctx context.Context) bool {
+	done := make(chan struct{})
+	go func() {
+		activeStreams.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,76 @@
+/*
+ * 文件作用：流式请求失败时的 SSE 收尾
+ * 负责功能：
+ *   - OpenAI 格式：已输出内容时先发 finish_reason=error 的终止 chunk，最后始终发送 [DONE]
+ *   - Claude 格式：已输出内容时先发 message_stop 结束消息，再发 error 事件
+ *   - 请求超时（X-Request-Timeout）使用明确的超时错误类型
+ * 重要程度：⭐⭐⭐ 一般（客户端 SSE 解析兼容性）
+ * 依赖模块：scheduler
+ */
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"go-aiproxy/internal/model"
+	"go-aiproxy/internal/proxy/scheduler"
+
+	"github.com/gin-gonic/gin"
+)
+
+// claudeTimeoutErrorType Claude 格式的超时错误类型
+const claudeTimeoutErrorType = "timeout_error"
+
+// apiErrorType 返回给客户端的 error.type：请求超时使用 timeoutType，其余为 api_error
+func apiErrorType(err error, timeoutType string) string {
+	if errors.Is(err, scheduler.ErrRequestTimeout) {
+		return timeoutType
+	}
+	return "api_error"
+}
+
+// writeOpenAIStreamError 写入 OpenAI 格式的流式错误并结束流
+// started 表示此前已向客户端输出过 chunk：此时错误放在带 finish_reason=error 的 chunk 中，保证已开始的 choice 有终止标记
+func writeOpenAIStreamError(w io.Writer, err error, modelName string, started bool) {
+	errBody := gin.H{
+		"message": err.Error(),
+		"type":    apiErrorType(err, model.ErrorTypeRequestTimeout),
+	}
+	event := gin.H{"error": errBody}
+	if started {
+		event = gin.H{
+			"object":  "chat.completion.chunk",
+			"created": time.Now().Unix(),
+			"model":   modelName,
+			"choices": []gin.H{{
+				"index":         0,
+				"delta":         gin.H{},
+				"finish_reason": "error",
+			}},
+			"error": errBody,
+		}
+	}
+	data, _ := json.Marshal(event)
+	w.Write([]byte("data: " + string(data) + "\n\n"))
+	w.Write([]byte("data: [DONE]\n\n"))
+}
+
+// writeClaudeStreamError 写入 Claude 格式的流式错误
+// started 表示此前已向客户端输出过事件：先发 message_stop 让客户端正常结束已开始的消息，再发 error 事件告知失败
+func writeClaudeStreamError(w io.Writer, err error, started bool) {
+	if started {
+		w.Write([]byte("event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"))
+	}
+	data, _ := json.Marshal(gin.H{
+		"type": "error",
+		"error": gin.H{
+			"type":    apiErrorType(err, claudeTimeoutErrorType),
+			"message": err.Error(),
+		},
+	})
+	w.Write([]byte("event: error\n"))
+	w.Write([]byte("data: " + string(data) + "\n\n"))
+}
@@ -0,0 +1,127 @@
+/*
+ * 文件作用：流式响应保活写入器，在上游长时间无输出时向客户端发送 SSE 注释心跳
+ * 负责功能：
+ *   - 空闲超时后写入 ": ping" 注释帧（只在事件边界写入，不插入到未写完的事件中间）
+ *   - 真实数据写入时重置定时器
+ *   - 与上游数据写入互斥，避免帧交错
+ * 重要程度：⭐⭐⭐ 一般（长推理模型的连接保活）
+ * 依赖模块：service
+ */
+package handler
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+
+	"go-aiproxy/internal/service"
+)
+
+// sseKeepAliveFrame SSE 注释行，客户端会忽略该帧
+var sseKeepAliveFrame = []byte(": ping\n\n")
+
+// KeepAliveWriter 保活写入器，包装底层 writer 并在空闲时发送心跳
+type KeepAliveWriter struct {
+	mu       sync.Mutex
+	writer   io.Writer
+	interval time.Duration
+	timer    *time.Timer
+	stopped  bool
+	written  bool    // 是否写入过真实数据
+	tail     [4]byte // 最近写入的末尾字节，用于判断上一个事件是否已写完
+}
+
+// NewKeepAliveWriter 创建保活写入器，interval <= 0 时不发送心跳
+func NewKeepAliveWriter(w io.Writer, interval time.Duration) *KeepAliveWriter {
+	kw := &KeepAliveWriter{writer: w, interval: interval}
+	if interval > 0 {
+		kw.timer = time.AfterFunc(interval, kw.ping)
+	}
+	return kw
+}
+
+// newStreamKeepAliveWriter 按系统配置的心跳间隔创建保活写入器
+func newStreamKeepAliveWriter(w io.Writer) *KeepAliveWriter {
+	return NewKeepAliveWriter(w, service.GetConfigService().GetStreamKeepAliveInterval())
+}
+
+// Write 实现 io.Writer 接口，写入真实数据并重置心跳定时器
+func (kw *KeepAliveWriter) Write(p []byte) (int, error) {
+	kw.mu.Lock()
+	defer kw.mu.Unlock()
+
+	n, err := kw.writer.Write(p)
+	if n > 0 {
+		kw.written = true
+		kw.recordTailLocked(p[:n])
+	}
+	if kw.timer != nil && !kw.stopped {
+		kw.timer.Reset(kw.interval)
+	}
+	return n, err
+}
+
+// Flush 实现 http.Flusher 接口（如果底层 writer 支持）
+func (kw *KeepAliveWriter) Flush() {
+	kw.mu.Lock()
+	defer kw.mu.Unlock()
+	kw.flushLocked()
+}
+
+// Stop 停止心跳，请求结束前必须调用
+func (kw *KeepAliveWriter) Stop() {
+	kw.mu.Lock()
+	defer kw.mu.Unlock()
+
+	kw.stopped = true
+	if kw.timer != nil {
+		kw.timer.Stop()
+	}
+}
+
+// ping 定时器触发时写入心跳帧
+func (kw *KeepAliveWriter) ping() {
+	kw.mu.Lock()
+	defer kw.mu.Unlock()
+
+	if kw.stopped {
+		return
+	}
+	// 上游事件只写了一部分（未以空行结束），此时写入心跳会插进事件中间破坏帧，等下个周期再检查
+	if !kw.atEventBoundaryLocked() {
+		kw.timer.Reset(kw.interval)
+		return
+	}
+	if _, err := kw.writer.Write(sseKeepAliveFrame); err != nil {
+		// 客户端已断开，不再发送心跳
+		kw.stopped = true
+		return
+	}
+	kw.flushLocked()
+	kw.timer.Reset(kw.interval)
+}
+
+func (kw *KeepAliveWriter) flushLocked() {
+	if f, ok := kw.writer.(interface{ Flush() }); ok {
+		f.Flush()
+	}
+}
+
+// recordTailLocked 记录最近写入的末尾字节，调用方需持有锁
+func (kw *KeepAliveWriter) recordTailLocked(p []byte) {
+	if len(p) >= len(kw.tail) {
+		copy(kw.tail[:], p[len(p)-len(kw.tail):])
+		return
+	}
+	copy(kw.tail[:], kw.tail[len(p):])
+	copy(kw.tail[len(kw.tail)-len(p):], p)
+}
+
+// atEventBoundaryLocked 是否处于 SSE 事件边界：尚未写入数据，或最近写入以空行（\n\n 或 \r\n\r\n）结束
+func (kw *KeepAliveWriter) atEventBoundaryLocked() bool {
+	if !kw.written {
+		return true
+	}
+	return bytes.HasSuffix(kw.tail[:], []byte("\n\n")) || bytes.Equal(kw.tail[:], []byte("\r\n\r\n"))
+}
@@ -0,0 +1,45 @@
+/*
+ * 文件作用：流式请求 token 估算兜底，部分中转站的流式响应不带 usage 时避免 token 为 0 无法计费
+ * 负责功能：
+ *   - 开启估算时包装流式 writer，累计响应文本的输出 token
+ *   - 上游 usage 为 0 时用请求体和响应文本的估算值填充，并标记为估算
+ * 重要程度：⭐⭐⭐ 一般（计费兜底）
+ * 依赖模块：adapter, service
+ */
+package handler
+
+import (
+	"io"
+
+	"go-aiproxy/internal/proxy/adapter"
+	"go-aiproxy/internal/service"
+)
+
+// wrapUsageEstimator 开启 token 估算时包装流式 writer，未开启时原样返回且估算器为 nil
+func wrapUsageEstimator(w io.Writer, format, modelName string) (io.Writer, *adapter.UsageEstimateWriter) {
+	if !service.GetConfigService().GetTokenEstimateEnabled() {
+		return w, nil
+	}
+	estimator := adapter.NewUsageEstimateWriter(w, format, modelName)
+	return estimator, estimator
+}
+
+// applyUsageEstimate 上游没有返回输入或输出 token 时分别用本地估算值兜底
+// 输入按请求体加注入的 system prompt 估算（有缓存 token 时视为上游已返回输入用量），输出按已转发的响应文本估算
+func applyUsageEstimate(usage *adapter.StreamResult, estimator *adapter.UsageEstimateWriter, modelName string, requestBody []byte) {
+	if usage == nil || estimator == nil {
+		return
+	}
+	if usage.InputTokens == 0 && usage.CacheCreationInputTokens == 0 && usage.CacheReadInputTokens == 0 {
+		if n := adapter.EstimateRequestTokens(modelName, requestBody) + usage.SystemPromptTokens; n > 0 {
+			usage.InputTokens = n
+			usage.Estimated = true
+		}
+	}
+	if usage.OutputTokens == 0 {
+		if n := estimator.OutputTokens(); n > 0 {
+			usage.OutputTokens = n
+			usage.Estimated = true
+		}
+	}
+}
@@ -5,19 +5,24 @@
  *   - 每日/月度使用统计
  *   - 按模型使用统计
  *   - 使用记录列表查询
- *   - 管理员全局统计查询
+ *   - 管理员全局统计查询（含账户成本和毛利）
  *   - API Key 使用统计
+ *   - 用量报表导出（CSV）
+ *   - 当日小时级用量趋势
  * 重要程度：⭐⭐⭐⭐ 重要（数据统计核心）
  * 依赖模块：service, repository
  */
 package handler
 
 import (
+	"fmt"
 	"strconv"
 	"time"
 
+	"go-aiproxy/internal/model"
 	"go-aiproxy/internal/repository"
 	"go-aiproxy/internal/service"
+	"go-aiproxy/pkg/logger"
 	"go-aiproxy/pkg/response"
 
 	"github.com/gin-gonic/gin"
@@ -592,7 +597,7 @@ func (h *UsageHandler) AdminGetUserUsageRecords(c *gin.Context) {
 	})
 }
 
-// AdminGetAllUsageSummary 管理员获取所有用户的使用汇总（从 MySQL）
+// AdminGetAllUsageSummary 管理员获取所有用户的使用汇总（从 MySQL），含账户成本和毛利
 func (h *UsageHandler) AdminGetAllUsageSummary(c *gin.Context) {
 	startDate := c.Query("start_date")
 	endDate := c.Query("end_date")
@@ -624,12 +629,93 @@ func (h *UsageHandler) AdminGetAllUsageSummary(c *gin.Context) {
 	}
 
 	response.Success(c, gin.H{
-		"start_date":     startDate,
-		"end_date":       endDate,
-		"total_requests": totalSummary.TotalRequests,
-		"total_tokens":   totalSummary.TotalTokens,
-		"total_cost":     totalSummary.TotalCost,
-		"daily":          dailySummaries,
-		"models":         modelSummaries,
+		"start_date":         startDate,
+		"end_date":           endDate,
+		"total_requests":     totalSummary.TotalRequests,
+		"total_tokens":       totalSummary.TotalTokens,
+		"total_cost":         totalSummary.TotalCost,
+		"total_account_cost": totalSummary.AccountCost,
+		"gross_profit":       totalSummary.TotalCost - totalSummary.AccountCost,
+		"daily":              dailySummaries,
+		"models":             modelSummaries,
+	})
+}
+
+// AdminGetHourlyUsage 管理员获取某天 24 个小时的用量趋势（只保留最近 7 天）
+// GET /api/admin/usage/hourly?date=YYYY-MM-DD，date 为空时为当天
+func (h *UsageHandler) AdminGetHourlyUsage(c *gin.Context) {
+	date := c.Query("date")
+	if date == "" {
+		date = time.Now().Format("2006-01-02")
+	} else if _, err := time.Parse("2006-01-02", date); err != nil {
+		response.BadRequest(c, "invalid date, expected YYYY-MM-DD")
+		return
+	}
+
+	points, err := service.GetHourlyUsageAggregator().GetHourlyUsage(date)
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+
+	var totalRequests, totalTokens int64
+	var totalCost float64
+	for i := range points {
+		totalRequests += points[i].RequestCount
+		totalTokens += points[i].TotalTokens
+		totalCost += points[i].TotalCost
+	}
+
+	response.Success(c, gin.H{
+		"date":           date,
+		"retention_days": model.HourlyUsageRetentionDays,
+		"total_requests": totalRequests,
+		"total_tokens":   totalTokens,
+		"total_cost":     totalCost,
+		"hours":          points,
 	})
 }
+
+// AdminExportUsageReport 管理员导出用量报表（CSV，按用户/模型/账户分组）
+// GET /api/admin/usage/export?start=&end=&group_by=user|model|account&user_id=&account_id=
+func (h *UsageHandler) AdminExportUsageReport(c *gin.Context) {
+	filter := &model.UsageReportFilter{
+		StartDate: c.Query("start"),
+		EndDate:   c.Query("end"),
+		GroupBy:   c.DefaultQuery("group_by", model.UsageReportGroupByUser),
+	}
+	if filter.StartDate == "" || filter.EndDate == "" {
+		filter.EndDate = time.Now().Format("2006-01-02")
+		filter.StartDate = time.Now().AddDate(0, 0, -30).Format("2006-01-02")
+	}
+	if v := c.Query("user_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			response.BadRequest(c, "invalid user_id")
+			return
+		}
+		filter.UserID = uint(id)
+	}
+	if v := c.Query("account_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			response.BadRequest(c, "invalid account_id")
+			return
+		}
+		filter.AccountID = uint(id)
+	}
+	if err := service.ValidateUsageReportFilter(filter); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	filename := fmt.Sprintf("usage_%s_%s_%s.csv", filter.GroupBy, filter.StartDate, filter.EndDate)
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(200)
+
+	// 已开始写响应，出错时只能中断输出并记录日志
+	if err := h.usageService.ExportUsageReport(c.Writer, filter); err != nil {
+		logger.GetLogger("usage").Error("导出用量报表失败: %v", err)
+	}
+}
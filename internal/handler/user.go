@@ -6,6 +6,7 @@
  *   - 用户创建/更新/删除
  *   - 密码修改
  *   - JWT Token 生成
+ *   - 组织管理员只能管理本组织用户
  * 重要程度：⭐⭐⭐⭐ 重要（用户管理核心）
  * 依赖模块：service
  */
@@ -14,6 +15,7 @@ package handler
 import (
 	"strconv"
 
+	"go-aiproxy/internal/middleware"
 	"go-aiproxy/internal/service"
 	"go-aiproxy/pkg/response"
 
@@ -164,11 +166,16 @@ func (h *UserHandler) ChangePassword(c *gin.Context) {
 
 // Admin endpoints
 
+// orgService 返回限定为当前管理员组织的用户服务（超级管理员不限制）
+func (h *UserHandler) orgService(c *gin.Context) *service.UserService {
+	return h.service.WithOrg(middleware.GetOrgID(c))
+}
+
 func (h *UserHandler) List(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
 
-	users, total, err := h.service.ListWithKeyBalances(page, pageSize)
+	users, total, err := h.orgService(c).ListWithKeyBalances(page, pageSize)
 	if err != nil {
 		response.InternalError(c, err.Error())
 		return
@@ -189,7 +196,7 @@ func (h *UserHandler) Create(c *gin.Context) {
 		return
 	}
 
-	user, err := h.service.AdminCreateUser(&req)
+	user, err := h.orgService(c).AdminCreateUser(&req)
 	if err != nil {
 		response.BadRequest(c, err.Error())
 		return
@@ -226,8 +233,12 @@ func (h *UserHandler) Update(c *gin.Context) {
 		response.BadRequest(c, err.Error())
 		return
 	}
+	// 只有超级管理员可以调整用户所属组织
+	if middleware.GetOrgID(c) != 0 {
+		req.OrgID = nil
+	}
 
-	user, err := h.service.Update(uint(id), &req)
+	user, err := h.orgService(c).Update(uint(id), &req)
 	if err != nil {
 		response.InternalError(c, err.Error())
 		return
@@ -259,7 +270,7 @@ func (h *UserHandler) BatchUpdatePriceRate(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.BatchUpdatePriceRate(&req); err != nil {
+	if err := h.orgService(c).BatchUpdatePriceRate(&req); err != nil {
 		response.InternalError(c, err.Error())
 		return
 	}
@@ -279,7 +290,7 @@ func (h *UserHandler) UpdateAllPriceRate(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.UpdateAllPriceRate(req.PriceRate); err != nil {
+	if err := h.orgService(c).UpdateAllPriceRate(req.PriceRate); err != nil {
 		response.InternalError(c, err.Error())
 		return
 	}
@@ -292,7 +303,7 @@ func (h *UserHandler) UpdateAllPriceRate(c *gin.Context) {
 
 // ListAllUsers 获取所有用户列表（不分页，用于批量操作）
 func (h *UserHandler) ListAllUsers(c *gin.Context) {
-	users, err := h.service.ListAll()
+	users, err := h.orgService(c).ListAll()
 	if err != nil {
 		response.InternalError(c, err.Error())
 		return
@@ -0,0 +1,178 @@
+/*
+ * 文件作用：实时请求流事件总线，把请求完成和重试事件推送给管理后台的监控台
+ * 负责功能：
+ *   - 内存事件总线（进程内，不跨实例）
+ *   - 订阅数上限，超过时拒绝新订阅
+ *   - 按 API Key / 模型 / 账户过滤
+ *   - 非阻塞发布：无订阅者时直接返回，订阅者消费慢时丢弃事件，不阻塞代理主流程
+ *   - 事件只包含统计字段（不含请求头、请求体和密钥），错误信息截断
+ * 重要程度：⭐⭐ 辅助（运维排查）
+ * 依赖模块：无
+ */
+package livefeed
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 事件类型
+const (
+	EventRequest = "request" // 请求完成（成功或失败）
+	EventRetry   = "retry"   // 上游失败准备重试
+)
+
+const (
+	// MaxSubscribers 同时连接的监控台数量上限
+	MaxSubscribers = 10
+	// subscriberBuffer 每个订阅者的事件缓冲
+	subscriberBuffer = 256
+	// maxMessageLen 错误信息最大长度（按字符）
+	maxMessageLen = 200
+)
+
+// ErrTooManySubscribers 订阅数已达上限
+var ErrTooManySubscribers = errors.New("实时请求流连接数已达上限")
+
+// Event 实时请求流事件（已脱敏）
+type Event struct {
+	Type        string    `json:"type"`
+	Time        time.Time `json:"time"`
+	RequestID   string    `json:"request_id,omitempty"`
+	UserID      uint      `json:"user_id,omitempty"`
+	APIKeyID    uint      `json:"api_key_id,omitempty"`
+	AccountID   uint      `json:"account_id,omitempty"`
+	AccountName string    `json:"account_name,omitempty"`
+	Platform    string    `json:"platform,omitempty"`
+	Model       string    `json:"model,omitempty"`
+	Success     bool      `json:"success"`
+	StatusCode  int       `json:"status_code,omitempty"`
+	LatencyMs   int64     `json:"latency_ms"`
+	TotalTokens int       `json:"total_tokens,omitempty"`
+	TotalCost   float64   `json:"total_cost,omitempty"`
+	Attempt     int       `json:"attempt,omitempty"`
+	Message     string    `json:"message,omitempty"`
+}
+
+// Filter 订阅过滤条件，零值表示不过滤
+type Filter struct {
+	APIKeyID  uint
+	AccountID uint
+	Model     string
+}
+
+// match 事件是否满足过滤条件
+func (f Filter) match(e *Event) bool {
+	if f.APIKeyID != 0 && e.APIKeyID != f.APIKeyID {
+		return false
+	}
+	if f.AccountID != 0 && e.AccountID != f.AccountID {
+		return false
+	}
+	if f.Model != "" && e.Model != f.Model {
+		return false
+	}
+	return true
+}
+
+// Subscriber 订阅者
+type Subscriber struct {
+	filter  Filter
+	events  chan *Event
+	dropped int64
+}
+
+// Events 事件通道
+func (s *Subscriber) Events() <-chan *Event {
+	return s.events
+}
+
+// Dropped 因消费过慢被丢弃的事件数
+func (s *Subscriber) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// Bus 事件总线
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[*Subscriber]struct{}
+	count       int32 // 订阅者数量，发布时无锁判断是否有订阅者
+}
+
+var (
+	bus     *Bus
+	busOnce sync.Once
+)
+
+// GetBus 获取事件总线单例
+func GetBus() *Bus {
+	busOnce.Do(func() {
+		bus = &Bus{subscribers: make(map[*Subscriber]struct{})}
+	})
+	return bus
+}
+
+// Subscribe 订阅事件，超过连接数上限时返回 ErrTooManySubscribers
+// 调用方必须在连接断开时调用 Unsubscribe
+func (b *Bus) Subscribe(filter Filter) (*Subscriber, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.subscribers) >= MaxSubscribers {
+		return nil, ErrTooManySubscribers
+	}
+	sub := &Subscriber{filter: filter, events: make(chan *Event, subscriberBuffer)}
+	b.subscribers[sub] = struct{}{}
+	atomic.StoreInt32(&b.count, int32(len(b.subscribers)))
+	return sub, nil
+}
+
+// Unsubscribe 取消订阅（可重复调用）
+func (b *Bus) Unsubscribe(sub *Subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.subscribers, sub)
+	atomic.StoreInt32(&b.count, int32(len(b.subscribers)))
+}
+
+// Active 是否有订阅者，调用方可据此跳过事件构建
+func (b *Bus) Active() bool {
+	return atomic.LoadInt32(&b.count) > 0
+}
+
+// Publish 发布事件（非阻塞），订阅者缓冲已满时丢弃
+func (b *Bus) Publish(e *Event) {
+	if !b.Active() {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Message = truncate(e.Message, maxMessageLen)
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subscribers {
+		if !sub.filter.match(e) {
+			continue
+		}
+		select {
+		case sub.events <- e:
+		default:
+			atomic.AddInt64(&sub.dropped, 1)
+		}
+	}
+}
+
+// truncate 按字符截断
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max]) + "..."
+}
@@ -0,0 +1,167 @@
+/*
+ * 文件作用：代理核心指标定义和埋点辅助函数
+ * 负责功能：
+ *   - 各平台请求总数/成功/失败计数
+ *   - 重试次数、上游响应延迟直方图
+ *   - 账户状态标记计数、Token 用量计数
+ *   - 灰度组 / 对照组请求结果和耗时
+ *   - 影子账户复制请求的结果、耗时和与主请求的一致性
+ * 重要程度：⭐⭐⭐ 一般（监控指标）
+ * 依赖模块：无
+ */
+package metrics
+
+import (
+	"strconv"
+	"time"
+)
+
+// 请求结果标签值
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+// unknownPlatform 未选中任何账户时的平台标签
+const unknownPlatform = "unknown"
+
+var (
+	proxyRequests = NewCounterVec(
+		"aiproxy_requests_total",
+		"Proxy requests by platform and result.",
+		"platform", "result",
+	)
+
+	requestRetries = NewHistogramVec(
+		"aiproxy_request_retries",
+		"Retries per proxy request (attempts minus one).",
+		[]float64{0, 1, 2, 3, 4, 5, 8},
+		"platform",
+	)
+
+	upstreamLatency = NewHistogramVec(
+		"aiproxy_upstream_latency_seconds",
+		"Upstream call latency per attempt; streaming calls cover the whole stream.",
+		[]float64{0.25, 0.5, 1, 2, 5, 10, 20, 30, 60, 120, 300},
+		"platform", "stream",
+	)
+
+	accountStatusMarks = NewCounterVec(
+		"aiproxy_account_status_marks_total",
+		"Account status updates from MarkAccountSuccess/MarkAccountError by target status.",
+		"result", "status",
+	)
+
+	tokensUsed = NewCounterVec(
+		"aiproxy_tokens_total",
+		"Tokens reported by upstream providers, before price rates are applied.",
+		"platform", "type",
+	)
+
+	trafficGroupRequests = NewCounterVec(
+		"aiproxy_traffic_group_requests_total",
+		"Proxy requests by canary traffic group and result.",
+		"group", "result",
+	)
+
+	trafficGroupDuration = NewHistogramVec(
+		"aiproxy_traffic_group_duration_seconds",
+		"Total proxy request duration (including retries) by canary traffic group.",
+		[]float64{0.25, 0.5, 1, 2, 5, 10, 20, 30, 60, 120, 300},
+		"group",
+	)
+
+	shadowRequests = NewCounterVec(
+		"aiproxy_shadow_requests_total",
+		"Mirrored (dark traffic) requests by shadow account and result (success / failure / dropped).",
+		"account_id", "result",
+	)
+
+	shadowLatency = NewHistogramVec(
+		"aiproxy_shadow_latency_seconds",
+		"Shadow account call latency for mirrored requests.",
+		[]float64{0.25, 0.5, 1, 2, 5, 10, 20, 30, 60, 120, 300},
+		"account_id",
+	)
+
+	shadowConsistency = NewCounterVec(
+		"aiproxy_shadow_consistency_total",
+		"Successful mirrored requests by how the shadow response compares to the primary one (match / same_stop / mismatch / unknown).",
+		"account_id", "consistency",
+	)
+)
+
+// ShadowResultDropped 影子请求因并发上限被丢弃
+const ShadowResultDropped = "dropped"
+
+// ObserveProxyRequest 记录一次代理请求的最终结果和重试次数
+// attempts 为实际调用上游的次数，为 0 表示未选中任何账户
+func ObserveProxyRequest(platform string, success bool, attempts int) {
+	if platform == "" {
+		platform = unknownPlatform
+	}
+	result := ResultFailure
+	if success {
+		result = ResultSuccess
+	}
+	proxyRequests.Inc(platform, result)
+
+	if attempts > 0 {
+		requestRetries.Observe(float64(attempts-1), platform)
+	}
+}
+
+// ObserveUpstreamLatency 记录一次上游调用耗时
+func ObserveUpstreamLatency(platform string, stream bool, d time.Duration) {
+	if platform == "" {
+		platform = unknownPlatform
+	}
+	streamLabel := "false"
+	if stream {
+		streamLabel = "true"
+	}
+	upstreamLatency.Observe(d.Seconds(), platform, streamLabel)
+}
+
+// IncAccountStatusMark 记录一次账户状态标记
+func IncAccountStatusMark(success bool, status string) {
+	result := ResultFailure
+	if success {
+		result = ResultSuccess
+	}
+	accountStatusMarks.Inc(result, status)
+}
+
+// AddTokens 记录上游返回的 token 用量（原始数量，未应用价格倍率）
+func AddTokens(platform string, input, output, cacheCreation, cacheRead int) {
+	if platform == "" {
+		platform = unknownPlatform
+	}
+	tokensUsed.Add(float64(input), platform, "input")
+	tokensUsed.Add(float64(output), platform, "output")
+	tokensUsed.Add(float64(cacheCreation), platform, "cache_creation")
+	tokensUsed.Add(float64(cacheRead), platform, "cache_read")
+}
+
+// ObserveTrafficGroup 记录灰度染色请求的最终结果和总耗时（含重试）
+func ObserveTrafficGroup(group string, success bool, d time.Duration) {
+	result := ResultFailure
+	if success {
+		result = ResultSuccess
+	}
+	trafficGroupRequests.Inc(group, result)
+	trafficGroupDuration.Observe(d.Seconds(), group)
+}
+
+// ObserveShadowRequest 记录一次影子请求的结果、耗时和一致性（失败或丢弃时 consistency 为空）
+func ObserveShadowRequest(accountID uint, result string, d time.Duration, consistency string) {
+	id := strconv.FormatUint(uint64(accountID), 10)
+	shadowRequests.Inc(id, result)
+	if result == ShadowResultDropped {
+		return
+	}
+	shadowLatency.Observe(d.Seconds(), id)
+	if consistency != "" {
+		shadowConsistency.Inc(id, consistency)
+	}
+}
@@ -0,0 +1,291 @@
+/*
+ * 文件作用：轻量级指标注册表，按 Prometheus 文本格式输出指标
+ * 负责功能：
+ *   - 计数器（CounterVec）
+ *   - 直方图（HistogramVec）
+ *   - 采集时计算的仪表盘（GaugeFunc）
+ *   - Prometheus 文本格式（text/plain; version=0.0.4）输出
+ * 重要程度：⭐⭐⭐ 一般（监控指标基础设施）
+ * 依赖模块：无
+ */
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType Prometheus 文本格式的 Content-Type
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// collector 可输出到文本格式的指标
+type collector interface {
+	writeTo(w *bufio.Writer)
+}
+
+var (
+	registryMu sync.RWMutex
+	collectors []collector
+)
+
+func register(c collector) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	collectors = append(collectors, c)
+}
+
+// WriteText 按注册顺序输出所有指标
+func WriteText(w io.Writer) error {
+	registryMu.RLock()
+	list := make([]collector, len(collectors))
+	copy(list, collectors)
+	registryMu.RUnlock()
+
+	bw := bufio.NewWriter(w)
+	for _, c := range list {
+		c.writeTo(bw)
+	}
+	return bw.Flush()
+}
+
+// ==================== 计数器 ====================
+
+type counterEntry struct {
+	labelValues []string
+	value       float64
+}
+
+// CounterVec 带标签的计数器
+type CounterVec struct {
+	name       string
+	help       string
+	labelNames []string
+
+	mu     sync.Mutex
+	values map[string]*counterEntry
+}
+
+// NewCounterVec 创建并注册计数器
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	c := &CounterVec{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		values:     make(map[string]*counterEntry),
+	}
+	register(c)
+	return c
+}
+
+// Inc 计数加一
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add 计数增加 v（v 必须为非负数）
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	key := labelKey(labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.values[key]
+	if !ok {
+		entry = &counterEntry{labelValues: append([]string(nil), labelValues...)}
+		c.values[key] = entry
+	}
+	entry.value += v
+}
+
+func (c *CounterVec) writeTo(w *bufio.Writer) {
+	writeHeader(w, c.name, c.help, "counter")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range sortedKeys(c.values) {
+		entry := c.values[key]
+		writeSample(w, c.name, c.labelNames, entry.labelValues, "", "", entry.value)
+	}
+}
+
+// ==================== 直方图 ====================
+
+type histogramEntry struct {
+	labelValues []string
+	counts      []uint64 // 与 buckets 一一对应（非累计）
+	sum         float64
+	count       uint64
+}
+
+// HistogramVec 带标签的直方图
+type HistogramVec struct {
+	name       string
+	help       string
+	labelNames []string
+	buckets    []float64
+
+	mu     sync.Mutex
+	values map[string]*histogramEntry
+}
+
+// NewHistogramVec 创建并注册直方图，buckets 为递增的上界
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	h := &HistogramVec{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		buckets:    sorted,
+		values:     make(map[string]*histogramEntry),
+	}
+	register(h)
+	return h
+}
+
+// Observe 记录一次观测值
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	key := labelKey(labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entry, ok := h.values[key]
+	if !ok {
+		entry = &histogramEntry{
+			labelValues: append([]string(nil), labelValues...),
+			counts:      make([]uint64, len(h.buckets)),
+		}
+		h.values[key] = entry
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			entry.counts[i]++
+			break
+		}
+	}
+	entry.sum += v
+	entry.count++
+}
+
+func (h *HistogramVec) writeTo(w *bufio.Writer) {
+	writeHeader(w, h.name, h.help, "histogram")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range sortedKeys(h.values) {
+		entry := h.values[key]
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += entry.counts[i]
+			writeSample(w, h.name+"_bucket", h.labelNames, entry.labelValues, "le", formatFloat(upper), float64(cumulative))
+		}
+		writeSample(w, h.name+"_bucket", h.labelNames, entry.labelValues, "le", "+Inf", float64(entry.count))
+		writeSample(w, h.name+"_sum", h.labelNames, entry.labelValues, "", "", entry.sum)
+		writeSample(w, h.name+"_count", h.labelNames, entry.labelValues, "", "", float64(entry.count))
+	}
+}
+
+// ==================== 仪表盘 ====================
+
+// GaugeSample 仪表盘采样值
+type GaugeSample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// GaugeFunc 采集时通过回调计算的仪表盘
+type GaugeFunc struct {
+	name       string
+	help       string
+	labelNames []string
+	fn         func() []GaugeSample
+}
+
+// NewGaugeFunc 创建并注册仪表盘，每次输出时调用 fn 获取当前值
+func NewGaugeFunc(name, help string, labelNames []string, fn func() []GaugeSample) *GaugeFunc {
+	g := &GaugeFunc{name: name, help: help, labelNames: labelNames, fn: fn}
+	register(g)
+	return g
+}
+
+func (g *GaugeFunc) writeTo(w *bufio.Writer) {
+	writeHeader(w, g.name, g.help, "gauge")
+	for _, s := range g.fn() {
+		writeSample(w, g.name, g.labelNames, s.LabelValues, "", "", s.Value)
+	}
+}
+
+// ==================== 文本格式输出 ====================
+
+func writeHeader(w *bufio.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, escapeHelp(help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, typ)
+}
+
+func writeSample(w *bufio.Writer, name string, labelNames, labelValues []string, extraName, extraValue string, value float64) {
+	w.WriteString(name)
+
+	var pairs []string
+	for i, ln := range labelNames {
+		lv := ""
+		if i < len(labelValues) {
+			lv = labelValues[i]
+		}
+		pairs = append(pairs, ln+`="`+escapeLabelValue(lv)+`"`)
+	}
+	if extraName != "" {
+		pairs = append(pairs, extraName+`="`+extraValue+`"`)
+	}
+	if len(pairs) > 0 {
+		w.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+
+	w.WriteString(" " + formatFloat(value) + "\n")
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+// labelKey 标签值拼接为 map key（\xff 不会出现在合法 UTF-8 标签值中）
+func labelKey(labelValues []string) string {
+	return strings.Join(labelValues, "\xff")
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -3,6 +3,9 @@
  * 负责功能：
  *   - API Key 解析（支持多种Header格式）
  *   - API Key 有效性验证
+ *   - API Key IP 白名单校验
+ *   - 强制指定账户（X-Force-Account-Id）权限校验
+ *   - 套餐预算检查（超额拒绝、告警响应头）
  *   - 用户/API Key 信息注入上下文
  *   - 费率倍率应用
  *   - 请求日志记录
@@ -12,6 +15,7 @@
 package middleware
 
 import (
+	"strconv"
 	"strings"
 
 	"go-aiproxy/internal/model"
@@ -27,6 +31,7 @@ import (
 func APIKeyAuth() gin.HandlerFunc {
 	apiKeyService := service.NewAPIKeyService()
 	userRepo := repository.NewUserRepository()
+	userPackageRepo := repository.NewUserPackageRepository()
 	configService := service.GetConfigService()
 	log := logger.GetLogger("auth")
 
@@ -61,12 +66,41 @@ func APIKeyAuth() gin.HandlerFunc {
 			return
 		}
 
-		log.Debug("API Key 认证成功 | IP: %s | KeyID: %d | UserID: %d", c.ClientIP(), key.ID, key.UserID)
+		// IP 白名单校验（ClientIP 已按可信代理解析 X-Forwarded-For）
+		clientIP := c.ClientIP()
+		if key.AllowedIPs != "" {
+			ipAllowed := key.IsIPAllowed(clientIP)
+			apiKeyService.RecordIPAccess(key.ID, clientIP, ipAllowed)
+			if !ipAllowed {
+				log.Warn("API Key IP 限制 | KeyID: %d | IP: %s | 允许: %s", key.ID, clientIP, key.AllowedIPs)
+				response.CustomForbiddenAbort(c, model.ErrorTypeIPNotAllowed, "当前 IP ("+clientIP+") 不在此 API Key 的白名单内")
+				return
+			}
+		}
+
+		// 强制指定账户仅限管理员授权的 Key，普通 Key 带该请求头直接拒绝
+		if forceAccount := strings.TrimSpace(c.GetHeader(model.ForceAccountHeader)); forceAccount != "" {
+			if !key.AllowForceAccount {
+				log.Warn("API Key 无强制指定账户权限 | KeyID: %d | IP: %s | AccountID: %s", key.ID, clientIP, forceAccount)
+				response.CustomForbiddenAbort(c, model.ErrorTypeForbidden, "此 API Key 无权使用 "+model.ForceAccountHeader)
+				return
+			}
+			accountID, err := strconv.ParseUint(forceAccount, 10, 32)
+			if err != nil || accountID == 0 {
+				response.CustomBadRequestAbort(c, "invalid "+model.ForceAccountHeader+": "+forceAccount)
+				return
+			}
+			log.Warn("强制指定账户请求 | KeyID: %d | IP: %s | AccountID: %d | Path: %s", key.ID, clientIP, accountID, c.Request.URL.Path)
+			c.Set("force_account_id", uint(accountID))
+		}
+
+		log.Debug("API Key 认证成功 | IP: %s | KeyID: %d | UserID: %d", clientIP, key.ID, key.UserID)
 
 		// 将 API Key 信息存储到 Context 中
 		c.Set("api_key", key)
 		c.Set("api_key_id", key.ID)
 		c.Set("api_key_user_id", key.UserID)
+		c.Set("api_key_org_id", key.OrgID)
 		c.Set("api_key_allowed_platforms", key.AllowedPlatforms)
 		c.Set("api_key_allowed_models", key.AllowedModels)
 		c.Set("api_key_rate_limit", key.RateLimit)
@@ -75,6 +109,15 @@ func APIKeyAuth() gin.HandlerFunc {
 		if key.UserPackageID != nil {
 			c.Set("api_key_package_id", *key.UserPackageID)
 			c.Set("api_key_billing_type", key.BillingType)
+
+			// 套餐预算检查，超额请求不打到上游
+			ok, release := checkPackageBudget(c, userPackageRepo, *key.UserPackageID)
+			if !ok {
+				return
+			}
+			if release != nil {
+				defer release()
+			}
 		}
 
 		// 计算有效倍率
@@ -0,0 +1,76 @@
+/*
+ * 文件作用：请求体大小限制中间件，防止超大请求体撑爆内存
+ * 负责功能：
+ *   - 按端点选择请求体上限（Claude 多模态端点允许更大）
+ *   - Content-Length 超限直接拒绝（413）
+ *   - 使用 http.MaxBytesReader 包装 Body，读取超限时报错
+ * 重要程度：⭐⭐⭐⭐ 重要（代理入口内存保护）
+ * 依赖模块：service, model, pkg/response
+ */
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go-aiproxy/internal/model"
+	"go-aiproxy/internal/service"
+	"go-aiproxy/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestBodyLimit 请求体大小限制
+// 必须注册在任何读取 Body 的中间件之前，保证限制发生在 ReadAll 之前
+func RequestBodyLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 前置中间件可能已包装 Body，按 ContentLength 判断空请求体（chunked 时为 -1）
+		if c.Request.Body == nil || c.Request.ContentLength == 0 {
+			c.Next()
+			return
+		}
+
+		limit := requestBodyLimit(c.Request.URL.Path)
+		if limit <= 0 {
+			c.Next()
+			return
+		}
+
+		// 声明了 Content-Length 且已超限，无需读取直接拒绝
+		if c.Request.ContentLength > limit {
+			AbortRequestTooLarge(c, limit)
+			return
+		}
+
+		// chunked 或声明长度不可信时，由 MaxBytesReader 在读取阶段截断
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
+
+// requestBodyLimit 按路径获取请求体上限（字节），0 表示不限制
+func requestBodyLimit(path string) int64 {
+	configService := service.GetConfigService()
+	if strings.HasPrefix(path, "/claude/") {
+		return configService.GetMaxRequestBodySizeClaude()
+	}
+	return configService.GetMaxRequestBodySize()
+}
+
+// AbortRequestTooLarge 返回 413 错误并中断请求
+func AbortRequestTooLarge(c *gin.Context, limit int64) {
+	response.CustomErrorAbort(c, http.StatusRequestEntityTooLarge, model.ErrorTypeRequestTooLarge,
+		fmt.Sprintf("request body too large, limit is %d MB", limit>>20))
+}
+
+// HandleRequestTooLarge 读取 Body 出错时，如果是超限错误则返回 413，返回值表示是否已处理
+func HandleRequestTooLarge(c *gin.Context, err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	if !errors.As(err, &maxBytesErr) {
+		return false
+	}
+	AbortRequestTooLarge(c, maxBytesErr.Limit)
+	return true
+}
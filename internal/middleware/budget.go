@@ -0,0 +1,113 @@
+/*
+ * 文件作用：套餐预算检查，在请求打到上游之前拦截超额用户
+ * 负责功能：
+ *   - 惰性重置套餐周期用量
+ *   - 用量达到 100% 时拒绝请求（402）
+ *   - 用量达到告警阈值时返回 X-Budget-Warning 响应头
+ *   - 并发请求在数据库中原子预扣额度，防止多实例并发超卖
+ *   - 套餐快照短期缓存，认证热路径不必每次查库
+ *   - 透传套餐调度优先级、可调度账户分组
+ * 重要程度：⭐⭐⭐⭐ 重要（计费保护）
+ * 依赖模块：cache, repository, service, model
+ */
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"go-aiproxy/internal/cache"
+	"go-aiproxy/internal/model"
+	"go-aiproxy/internal/repository"
+	"go-aiproxy/internal/service"
+	"go-aiproxy/pkg/logger"
+	"go-aiproxy/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// budgetReservationStale 预扣从 0 变为正数后超过该时长仍未清零的视为残留（进程退出未释放），下次预扣时清零
+const budgetReservationStale = 10 * time.Minute
+
+// loadUserPackage 读取用户套餐（短期缓存）
+// 需要惰性重置周期用量时改读数据库最新数据再重置，避免用缓存快照整行覆盖用量
+func loadUserPackage(userPackageRepo *repository.UserPackageRepository, packageID uint) (*model.UserPackage, error) {
+	packageCache := cache.GetUserPackageCache()
+	if userPackage := packageCache.Get(packageID); userPackage != nil && !userPackage.ResetPeriodUsageIfNeeded() {
+		return userPackage, nil
+	}
+
+	userPackage, err := userPackageRepo.GetByID(packageID)
+	if err != nil {
+		return nil, err
+	}
+	// 惰性重置周期用量（与扣费阶段一致）
+	if userPackage.ResetPeriodUsageIfNeeded() {
+		userPackageRepo.Update(userPackage)
+	}
+	packageCache.Set(userPackage)
+	return userPackage, nil
+}
+
+// checkPackageBudget 检查 API Key 绑定套餐的预算
+// 返回 false 表示请求已被拒绝；release 不为 nil 时需要在请求结束后调用以释放预扣
+func checkPackageBudget(c *gin.Context, userPackageRepo *repository.UserPackageRepository, packageID uint) (ok bool, release func()) {
+	log := logger.GetLogger("auth")
+
+	userPackage, err := loadUserPackage(userPackageRepo, packageID)
+	if err != nil || userPackage == nil {
+		// 套餐读取失败不阻断请求，扣费阶段会再次处理
+		log.Warn("读取用户套餐失败 | PackageID: %d | Error: %v", packageID, err)
+		return true, nil
+	}
+	if userPackage.Package != nil {
+		// 套餐调度优先级，供重试层排队使用
+		c.Set("api_key_package_priority", userPackage.Package.Priority)
+		// 套餐限定的账户分组，供重试层过滤候选账户
+		if groupIDs := userPackage.Package.AccountGroupIDs(); len(groupIDs) > 0 {
+			c.Set("api_key_package_account_groups", groupIDs)
+		}
+	}
+
+	ratio, remaining, limited := userPackage.BudgetUsage()
+	if !limited {
+		return true, nil
+	}
+
+	if ratio >= 1 {
+		log.Warn("套餐预算已用尽 | PackageID: %d | UserID: %d | Usage: %.1f%%", packageID, userPackage.UserID, ratio*100)
+		response.CustomErrorAbort(c, http.StatusPaymentRequired, model.ErrorTypeBudgetExceeded,
+			fmt.Sprintf("budget exceeded: %.1f%% of package quota used", ratio*100))
+		return false, nil
+	}
+
+	configService := service.GetConfigService()
+	if warnPercent := configService.GetBudgetWarningPercent(); warnPercent > 0 && ratio*100 >= warnPercent {
+		c.Header("X-Budget-Warning", fmt.Sprintf("%.1f%% of package quota used, %.4f USD remaining", ratio*100, remaining))
+	}
+
+	// 预扣：进行中的请求尚未扣费，避免并发请求（包括其他实例上的）一起通过检查后超卖
+	amount := configService.GetBudgetReserveAmount()
+	if amount <= 0 {
+		return true, nil
+	}
+	reserved, err := userPackageRepo.ReserveBudget(userPackage, amount, time.Now().Add(-budgetReservationStale))
+	if err != nil {
+		// 预扣失败不阻断请求，扣费阶段仍以实际用量为准
+		log.Warn("套餐预算预扣出错 | PackageID: %d | Error: %v", packageID, err)
+		return true, nil
+	}
+	if !reserved {
+		log.Warn("套餐预算预扣失败 | PackageID: %d | UserID: %d | Remaining: %.4f",
+			packageID, userPackage.UserID, remaining)
+		response.CustomErrorAbort(c, http.StatusPaymentRequired, model.ErrorTypeBudgetExceeded,
+			fmt.Sprintf("budget exceeded: %.4f USD remaining is reserved by in-flight requests", remaining))
+		return false, nil
+	}
+	return true, func() {
+		if err := userPackageRepo.ReleaseBudget(packageID, amount); err != nil {
+			log.Warn("释放套餐预算预扣失败 | PackageID: %d | Amount: %.4f | Error: %v", packageID, amount, err)
+		}
+	}
+}
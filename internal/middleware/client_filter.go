@@ -2,6 +2,7 @@
  * 文件作用：客户端过滤中间件，验证和识别请求来源客户端
  * 负责功能：
  *   - 客户端类型识别（Claude Code/Cursor/Cline等）
+ *   - 访问规则匹配（UA 正则 + 路径前缀，allow/deny）
  *   - 过滤规则匹配
  *   - 请求体解析（提取model字段）
  *   - 验证结果日志记录
@@ -37,8 +38,29 @@ func ClientFilter() gin.HandlerFunc {
 			return
 		}
 
+		// 访问规则按序匹配，命中即决定放行或拒绝
+		if rule := filterService.MatchAccessRule(c.GetHeader("User-Agent"), c.Request.URL.Path); rule != nil {
+			if rule.Action == model.AccessRuleActionAllow {
+				c.Next()
+				return
+			}
+			log.Warn("访问规则拒绝 | IP: %s | 规则: %s | Path: %s | UA: %s",
+				c.ClientIP(), rule.Name, c.Request.URL.Path, c.GetHeader("User-Agent"))
+			if rule.Message != "" {
+				response.Forbidden(c, rule.Message)
+				c.Abort()
+				return
+			}
+			response.CustomForbiddenAbort(c, model.ErrorTypeClientNotAllowed, "客户端无权访问该路径 ("+rule.Name+")")
+			return
+		}
+
 		// 构建请求上下文
 		reqCtx := buildRequestContext(c)
+		if c.IsAborted() {
+			// 请求体超限，已返回 413
+			return
+		}
 
 		// 执行验证
 		result := filterService.ValidateRequest(reqCtx)
@@ -89,6 +111,9 @@ func buildRequestContext(c *gin.Context) *service.RequestContext {
 	// 解析请求体（仅 POST/PUT/PATCH）
 	if c.Request.Method == "POST" || c.Request.Method == "PUT" || c.Request.Method == "PATCH" {
 		bodyBytes, err := io.ReadAll(c.Request.Body)
+		if err != nil && HandleRequestTooLarge(c, err) {
+			return ctx
+		}
 		if err == nil && len(bodyBytes) > 0 {
 			// 重置 Body 以便后续处理
 			c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
@@ -157,6 +182,10 @@ func ClientFilterOptional() gin.HandlerFunc {
 
 		// 构建请求上下文
 		reqCtx := buildRequestContext(c)
+		if c.IsAborted() {
+			// 请求体超限，已返回 413
+			return
+		}
 
 		// 执行验证
 		result := filterService.ValidateRequest(reqCtx)
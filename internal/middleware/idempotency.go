@@ -0,0 +1,257 @@
+/*
+ * 文件作用：请求幂等中间件，按 Idempotency-Key 头合并客户端重试产生的重复请求
+ * 负责功能：
+ *   - 相同 Key 的首个请求正常执行，成功响应在去重窗口内保留
+ *   - 非流式重复请求等待首个请求完成后返回相同响应（不再调用上游、不再计费）
+ *   - 流式重复请求直接返回 409；首个请求失败时重复请求正常放行
+ *   - 相同 Key 但请求体不同时返回 409，防止误用
+ *   - 首个请求在数据库占用幂等键（唯一索引），其他实例上的重复请求返回 409
+ * 重要程度：⭐⭐⭐ 一般（防重复调用与重复计费）
+ * 依赖模块：cache, repository, service, model, pkg/response
+ */
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-aiproxy/internal/cache"
+	"go-aiproxy/internal/model"
+	"go-aiproxy/internal/repository"
+	"go-aiproxy/internal/service"
+	"go-aiproxy/pkg/logger"
+	"go-aiproxy/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// IdempotencyKeyHeader 客户端指定幂等键的请求头
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader 响应头，标记本次响应是首个请求结果的重放
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	// maxIdempotencyKeyLength 幂等键最大长度
+	maxIdempotencyKeyLength = 255
+	// idempotencyLease 数据库中进行中记录的租约，长于单个请求的最长处理时间；实例异常退出后到期可重新占用
+	idempotencyLease = 10 * time.Minute
+	// idempotencyCleanupInterval 过期幂等记录清理间隔
+	idempotencyCleanupInterval = 10 * time.Minute
+)
+
+// Idempotency 按 Idempotency-Key 头对 POST 请求去重
+// 未携带该头或去重窗口为 0 时不处理；需注册在 API Key 认证之后、用户并发控制之前（等待中的重复请求不占并发）
+// 同一实例内的重复请求等待并共享首个请求的响应；其他实例上的重复请求由数据库占用记录拦截，返回 409
+func Idempotency() gin.HandlerFunc {
+	recordRepo := repository.NewIdempotencyRecordRepository()
+	go cleanupIdempotencyRecords(recordRepo)
+
+	return func(c *gin.Context) {
+		idempotencyKey := strings.TrimSpace(c.GetHeader(IdempotencyKeyHeader))
+		if idempotencyKey == "" || c.Request.Method != http.MethodPost {
+			c.Next()
+			return
+		}
+
+		window := service.GetConfigService().GetIdempotencyWindow()
+		if window <= 0 {
+			c.Next()
+			return
+		}
+
+		if len(idempotencyKey) > maxIdempotencyKeyLength {
+			response.CustomErrorAbort(c, http.StatusBadRequest, model.ErrorTypeBadRequest,
+				"invalid Idempotency-Key: must not exceed 255 characters")
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			if !HandleRequestTooLarge(c, err) {
+				response.CustomErrorAbort(c, http.StatusBadRequest, model.ErrorTypeInvalidRequest, "failed to read request body")
+			}
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		store := cache.GetIdempotencyStore()
+		key := cache.IdempotencyKey(c.GetUint("api_key_id"), c.Request.URL.Path, idempotencyKey)
+		fingerprint := cache.IdempotencyFingerprint(body)
+		stream := isStreamBody(body)
+
+		for {
+			entry, leader := store.Begin(key, fingerprint, stream)
+			if leader {
+				record, claimed := claimIdempotencyKey(c, recordRepo, key)
+				if !claimed {
+					// 其他实例上相同请求进行中或已完成：结束本地记录，本实例等待中的重复请求同样拒绝
+					store.Complete(key, entry, nil, window)
+					response.CustomErrorAbort(c, http.StatusConflict, model.ErrorTypeIdempotencyConflict,
+						"a request with the same Idempotency-Key is already in progress or completed")
+					return
+				}
+				runIdempotent(c, store, recordRepo, key, entry, record)
+				return
+			}
+
+			if entry.Fingerprint != fingerprint {
+				response.CustomErrorAbort(c, http.StatusConflict, model.ErrorTypeIdempotencyKeyReused,
+					"Idempotency-Key was already used with a different request body")
+				return
+			}
+			if entry.Stream {
+				response.CustomErrorAbort(c, http.StatusConflict, model.ErrorTypeIdempotencyConflict,
+					"a request with the same Idempotency-Key is already in progress or completed")
+				return
+			}
+
+			select {
+			case <-entry.Done():
+			case <-c.Request.Context().Done():
+				// 客户端已断开或请求超时到期，不再等待
+				if errors.Is(c.Request.Context().Err(), context.DeadlineExceeded) {
+					response.CustomErrorAbort(c, http.StatusGatewayTimeout, model.ErrorTypeRequestTimeout,
+						"request timed out waiting for the request with the same Idempotency-Key")
+				} else {
+					c.Abort()
+				}
+				return
+			}
+
+			if result := entry.Result(); result != nil {
+				replayIdempotentResult(c, result)
+				return
+			}
+			// 首个请求失败：重新登记，由某一个重复请求重新执行
+		}
+	}
+}
+
+// claimIdempotencyKey 在数据库中占用幂等键，返回占用记录（数据库出错时为 nil）和是否可以执行
+// 数据库出错时退化为只在本实例内去重，不阻断请求
+func claimIdempotencyKey(c *gin.Context, recordRepo *repository.IdempotencyRecordRepository, key string) (*model.IdempotencyRecord, bool) {
+	record := &model.IdempotencyRecord{
+		KeyHash:  key,
+		APIKeyID: c.GetUint("api_key_id"),
+		Path:     c.Request.URL.Path,
+		Status:   model.IdempotencyStatusInFlight,
+		ExpireAt: time.Now().Add(idempotencyLease),
+	}
+	claimed, err := recordRepo.Claim(record)
+	if err != nil {
+		logger.GetLogger("proxy").Ctx(c.Request.Context()).Warn("占用幂等键失败，只在本实例内去重 | APIKeyID: %d | Path: %s | Error: %v",
+			record.APIKeyID, record.Path, err)
+		return nil, true
+	}
+	return record, claimed
+}
+
+// runIdempotent 作为首个请求执行后续处理，记录成功响应供重复请求重放
+// record 不为 nil 时结束后同步数据库占用记录：可重放的成功结果保留到去重窗口结束，否则删除
+func runIdempotent(c *gin.Context, store *cache.IdempotencyStore, recordRepo *repository.IdempotencyRecordRepository, key string, entry *cache.IdempotencyEntry, record *model.IdempotencyRecord) {
+	writer := &idempotencyWriter{ResponseWriter: c.Writer, capture: !entry.Stream}
+	c.Writer = writer
+
+	var result *cache.IdempotencyResult
+	// 处理过程中 panic 时同样结束记录，避免重复请求一直等待
+	defer func() {
+		window := service.GetConfigService().GetIdempotencyWindow()
+		store.Complete(key, entry, result, window)
+		if record == nil {
+			return
+		}
+		var err error
+		if result != nil && len(result.Body) <= cache.MaxIdempotencyBodySize {
+			err = recordRepo.Complete(record.ID, time.Now().Add(window))
+		} else {
+			err = recordRepo.Release(record.ID)
+		}
+		if err != nil {
+			logger.GetLogger("proxy").Warn("更新幂等记录失败 | ID: %d | Error: %v", record.ID, err)
+		}
+	}()
+
+	c.Next()
+
+	status := writer.Status()
+	// 没有写出响应（如客户端断开）或为降级响应（账户全部不可用）时不共享给重复请求
+	if !writer.Written() || status < 200 || status >= 300 || writer.overflow || writer.Header().Get(model.DegradedResponseHeader) != "" {
+		return
+	}
+	header := writer.Header().Clone()
+	header.Del("Content-Length")
+	header.Del("Date")
+	result = &cache.IdempotencyResult{StatusCode: status, Header: header, Body: writer.body.Bytes()}
+}
+
+// cleanupIdempotencyRecords 定期清理数据库中过期的幂等记录
+func cleanupIdempotencyRecords(recordRepo *repository.IdempotencyRecordRepository) {
+	ticker := time.NewTicker(idempotencyCleanupInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if _, err := recordRepo.DeleteExpired(time.Now()); err != nil {
+			logger.GetLogger("proxy").Warn("清理过期幂等记录失败 | Error: %v", err)
+		}
+	}
+}
+
+// replayIdempotentResult 返回首个请求的响应
+func replayIdempotentResult(c *gin.Context, result *cache.IdempotencyResult) {
+	logger.GetLogger("proxy").Ctx(c.Request.Context()).InfoZ("重复请求，返回首个请求的响应",
+		logger.Uint("api_key_id", c.GetUint("api_key_id")),
+		logger.String("path", c.Request.URL.Path),
+	)
+	for name, values := range result.Header {
+		for _, value := range values {
+			c.Writer.Header().Add(name, value)
+		}
+	}
+	c.Header(IdempotentReplayedHeader, "true")
+	c.Data(result.StatusCode, result.Header.Get("Content-Type"), result.Body)
+	c.Abort()
+}
+
+// isStreamBody 请求体是否为流式请求（各协议都使用顶层 stream 字段）
+func isStreamBody(body []byte) bool {
+	var req struct {
+		Stream bool `json:"stream"`
+	}
+	return json.Unmarshal(body, &req) == nil && req.Stream
+}
+
+// idempotencyWriter 记录首个请求写出的响应体（流式请求不记录）
+type idempotencyWriter struct {
+	gin.ResponseWriter
+	capture  bool
+	overflow bool
+	body     bytes.Buffer
+}
+
+func (w *idempotencyWriter) Write(data []byte) (int, error) {
+	w.record(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *idempotencyWriter) WriteString(s string) (int, error) {
+	w.record([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// record 追加响应体，超过上限时放弃记录
+func (w *idempotencyWriter) record(data []byte) {
+	if !w.capture || w.overflow {
+		return
+	}
+	if w.body.Len()+len(data) > cache.MaxIdempotencyBodySize {
+		w.overflow = true
+		w.body.Reset()
+		return
+	}
+	w.body.Write(data)
+}
@@ -3,7 +3,7 @@
  * 负责功能：
  *   - JWT Token 解析和验证
  *   - 用户信息注入上下文
- *   - 管理员权限验证
+ *   - 管理员权限验证（组织管理员 / 超级管理员）
  * 重要程度：⭐⭐⭐⭐ 重要（后台认证核心）
  * 依赖模块：pkg/utils
  */
@@ -50,6 +50,7 @@ func JWTAuth() gin.HandlerFunc {
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
 		c.Set("role", claims.Role)
+		c.Set("org_id", claims.OrgID)
 		c.Next()
 	}
 }
@@ -67,3 +68,23 @@ func AdminRequired() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// SuperAdminRequired 要求超级管理员（不属于任何组织的管理员），用于组织管理和全局配置
+func SuperAdminRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("role") != "admin" || GetOrgID(c) != 0 {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"code":    403,
+				"message": "Super admin access required",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// GetOrgID 当前登录者所属组织，0 表示不属于任何组织
+// 管理接口以此限定可见数据：超级管理员为 0 不限制，组织管理员只能看到本组织数据
+func GetOrgID(c *gin.Context) uint {
+	return c.GetUint("org_id")
+}
@@ -1,7 +1,7 @@
 /*
  * 文件作用：HTTP请求日志中间件，记录所有HTTP请求的详细信息
  * 负责功能：
- *   - 请求ID生成和传递
+ *   - 请求ID生成和传递（复用合法的客户端 X-Request-Id）
  *   - 请求/响应时间记录
  *   - 请求体大小统计
  *   - 敏感信息脱敏（token/password）
@@ -11,7 +11,6 @@
 package middleware
 
 import (
-	"bytes"
 	"crypto/rand"
 	"encoding/hex"
 	"io"
@@ -28,6 +27,8 @@ const (
 	RequestIDHeader = "X-Request-ID"
 	// RequestIDCtxKey Gin Context中的request_id字段名
 	RequestIDCtxKey = "request_id"
+	// RequestStartCtxKey Gin Context中的请求开始时间字段名
+	RequestStartCtxKey = "request_start"
 )
 
 // generateRequestID 生成唯一的请求ID
@@ -37,6 +38,25 @@ func generateRequestID() string {
 	return hex.EncodeToString(b)
 }
 
+// maxClientRequestIDLen 客户端传入 request_id 的最大长度
+const maxClientRequestIDLen = 64
+
+// validClientRequestID 校验客户端传入的 request_id，只允许字母数字和 -_.:，防止日志注入
+func validClientRequestID(id string) bool {
+	if id == "" || len(id) > maxClientRequestIDLen {
+		return false
+	}
+	for _, ch := range id {
+		switch {
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch >= '0' && ch <= '9':
+		case ch == '-' || ch == '_' || ch == '.' || ch == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 // responseBodyWriter 用于捕获响应体大小
 type responseBodyWriter struct {
 	gin.ResponseWriter
@@ -49,6 +69,18 @@ func (w *responseBodyWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// countingReadCloser 统计已读取的请求体字节数
+type countingReadCloser struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
 // getRealClientIP 获取真实客户端IP（支持代理）
 func getRealClientIP(c *gin.Context) string {
 	// 优先检查 X-Forwarded-For
@@ -84,14 +116,15 @@ func Logger() gin.HandlerFunc {
 		path := c.Request.URL.Path
 		query := c.Request.URL.RawQuery
 
-		// 获取或生成 request_id
+		// 获取或生成 request_id（复用客户端传入的 X-Request-Id，格式不合法时重新生成）
 		requestID := c.GetHeader(RequestIDHeader)
-		if requestID == "" {
+		if !validClientRequestID(requestID) {
 			requestID = generateRequestID()
 		}
 
 		// 注入到 Gin Context
 		c.Set(RequestIDCtxKey, requestID)
+		c.Set(RequestStartCtxKey, start)
 
 		// 注入到 Go Context（用于日志）
 		ctx := logger.SetRequestID(c.Request.Context(), requestID)
@@ -100,13 +133,11 @@ func Logger() gin.HandlerFunc {
 		// 设置响应头
 		c.Header(RequestIDHeader, requestID)
 
-		// 获取请求体大小
-		var requestBodySize int64
+		// 统计请求体大小（边读边计数，不预先把请求体读入内存，大小限制由 RequestBodyLimit 负责）
+		var bodyCounter *countingReadCloser
 		if c.Request.Body != nil {
-			// 读取请求体以获取大小，然后重新设置
-			bodyBytes, _ := io.ReadAll(c.Request.Body)
-			requestBodySize = int64(len(bodyBytes))
-			c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+			bodyCounter = &countingReadCloser{ReadCloser: c.Request.Body}
+			c.Request.Body = bodyCounter
 		}
 
 		// 包装 ResponseWriter 以捕获响应体大小
@@ -129,6 +160,13 @@ func Logger() gin.HandlerFunc {
 		host := c.Request.Host
 		protocol := c.Request.Proto
 		responseBodySize := rbw.size
+		requestBodySize := c.Request.ContentLength
+		if requestBodySize < 0 {
+			requestBodySize = 0
+			if bodyCounter != nil {
+				requestBodySize = bodyCounter.n
+			}
+		}
 
 		// 获取用户信息（如果已认证）
 		var userID uint
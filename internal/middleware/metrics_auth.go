@@ -0,0 +1,40 @@
+/*
+ * 文件作用：监控指标端点认证中间件，使用独立令牌保护 /metrics
+ * 负责功能：
+ *   - 校验 Authorization: Bearer <token>
+ *   - 常量时间比较，避免时序攻击
+ * 重要程度：⭐⭐ 辅助（监控端点访问控制）
+ * 依赖模块：无
+ */
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MetricsAuth 校验监控令牌，token 为空时不校验
+func MetricsAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.Next()
+			return
+		}
+
+		authHeader := c.GetHeader("Authorization")
+		provided := strings.TrimPrefix(authHeader, "Bearer ")
+		if authHeader == "" || provided == authHeader ||
+			subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"code":    401,
+				"message": "Invalid metrics token",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -1,11 +1,12 @@
 /*
  * 文件作用：操作日志中间件，记录管理员的所有写操作供审计
  * 负责功能：
- *   - 拦截POST/PUT/DELETE请求
+ *   - 拦截POST/PUT/DELETE请求及导出请求
  *   - 解析操作类型和目标
  *   - 记录请求前后数据变更
  *   - 自动获取目标名称
  *   - 敏感字段脱敏
+ *   - 响应体只缓冲前 64KB，超出时按 HTTP 状态码记录结果
  * 重要程度：⭐⭐⭐ 一般（审计功能）
  * 依赖模块：repository, model, logger
  */
@@ -119,8 +120,12 @@ func init() {
 		{regexp.MustCompile(`^/api/admin/configs/sync/trigger$`), model.ModuleConfig, model.ActionSync, nil, nil, nil, descTriggerSync},
 		{regexp.MustCompile(`^/api/admin/cache/config$`), model.ModuleCache, model.ActionUpdate, nil, nil, nil, descUpdateCacheConfig},
 
+		// 用量报表
+		{regexp.MustCompile(`^/api/admin/usage/export$`), model.ModuleUsage, model.ActionExport, nil, nil, nil, descExportUsageReport},
+
 		// 缓存管理
 		{regexp.MustCompile(`^/api/admin/cache/clear$`), model.ModuleCache, model.ActionClear, nil, nil, nil, descClearCache},
+		{regexp.MustCompile(`^/api/admin/cache/sessions/unbind$`), model.ModuleCache, model.ActionClear, nil, nil, nil, descUnbindAccountSessions},
 		{regexp.MustCompile(`^/api/admin/cache/sessions/(.+)$`), model.ModuleCache, model.ActionDelete, nil, nil, nil, descRemoveSession},
 		{regexp.MustCompile(`^/api/admin/cache/users/(\d+)$`), model.ModuleCache, model.ActionClear, getPathID, nil, getUsernameByID, descClearUserCache},
 		{regexp.MustCompile(`^/api/admin/cache/api-keys/(\d+)$`), model.ModuleCache, model.ActionClear, getPathID, nil, getAPIKeyNameByID, descClearAPIKeyCache},
@@ -509,6 +514,17 @@ func descUpdateConfig(c *gin.Context, body map[string]interface{}) string {
 	return "更新系统配置"
 }
 
+func descExportUsageReport(c *gin.Context, body map[string]interface{}) string {
+	desc := "导出用量报表 " + c.Query("start") + " ~ " + c.Query("end") + "，按 " + c.DefaultQuery("group_by", "user") + " 分组"
+	if userID := c.Query("user_id"); userID != "" {
+		desc += "，用户 #" + userID
+	}
+	if accountID := c.Query("account_id"); accountID != "" {
+		desc += "，账户 #" + accountID
+	}
+	return desc
+}
+
 func descTriggerSync(c *gin.Context, body map[string]interface{}) string {
 	return "手动触发数据同步"
 }
@@ -528,6 +544,19 @@ func descRemoveSession(c *gin.Context, body map[string]interface{}) string {
 	return "移除会话"
 }
 
+func descUnbindAccountSessions(c *gin.Context, body map[string]interface{}) string {
+	if ids, ok := body["account_ids"].([]interface{}); ok {
+		parts := make([]string, 0, len(ids))
+		for _, id := range ids {
+			if v, ok := id.(float64); ok {
+				parts = append(parts, "#"+strconv.FormatFloat(v, 'f', -1, 64))
+			}
+		}
+		return "批量解绑账户会话: " + strings.Join(parts, ", ")
+	}
+	return "批量解绑账户会话"
+}
+
 func descClearUserCache(c *gin.Context, body map[string]interface{}) string {
 	return "清理用户 #" + c.Param("id") + " 缓存"
 }
@@ -658,15 +687,26 @@ func sanitizeBody(body map[string]interface{}) map[string]interface{} {
 	return sanitized
 }
 
+// maxOperationLogResponseBody 操作日志解析响应时最多缓冲的字节数（导出等大响应不整体读入内存）
+const maxOperationLogResponseBody = 64 << 10
+
 // responseWriter 包装 gin.ResponseWriter 以捕获响应
 type responseWriter struct {
 	gin.ResponseWriter
-	body   *bytes.Buffer
-	status int
+	body     *bytes.Buffer
+	status   int
+	overflow bool // 响应体超过缓冲上限，不再缓冲也不解析
 }
 
 func (w *responseWriter) Write(b []byte) (int, error) {
-	w.body.Write(b)
+	if !w.overflow {
+		if w.body.Len()+len(b) > maxOperationLogResponseBody {
+			w.overflow = true
+			w.body = bytes.NewBuffer(nil)
+		} else {
+			w.body.Write(b)
+		}
+	}
 	return w.ResponseWriter.Write(b)
 }
 
@@ -680,15 +720,14 @@ func OperationLogger() gin.HandlerFunc {
 	repo := repository.NewOperationLogRepository()
 
 	return func(c *gin.Context) {
-		// 只记录写操作（POST/PUT/DELETE）和登录
+		// 只记录写操作（POST/PUT/DELETE）、登录和导出（GET .../export）
 		method := c.Request.Method
-		if method == "GET" || method == "HEAD" || method == "OPTIONS" {
+		path := c.Request.URL.Path
+		if method == "HEAD" || method == "OPTIONS" || (method == "GET" && !strings.HasSuffix(path, "/export")) {
 			c.Next()
 			return
 		}
 
-		path := c.Request.URL.Path
-
 		// 跳过代理转发接口
 		if strings.HasPrefix(path, "/v1/") || strings.HasPrefix(path, "/gemini/") {
 			c.Next()
@@ -703,7 +742,8 @@ func OperationLogger() gin.HandlerFunc {
 				// 检查方法是否匹配
 				if (method == "POST" && (m.Action == model.ActionCreate || m.Action == model.ActionLogin || m.Action == model.ActionSync || m.Action == model.ActionClear || m.Action == model.ActionTest)) ||
 					(method == "PUT" && (m.Action == model.ActionUpdate || m.Action == model.ActionEnable || m.Action == model.ActionDisable)) ||
-					(method == "DELETE" && (m.Action == model.ActionDelete || m.Action == model.ActionClear)) {
+					(method == "DELETE" && (m.Action == model.ActionDelete || m.Action == model.ActionClear)) ||
+					(method == "GET" && m.Action == model.ActionExport) {
 					mapping = m
 					break
 				}
@@ -721,7 +761,11 @@ func OperationLogger() gin.HandlerFunc {
 		var bodyBytes []byte
 		var bodyMap map[string]interface{}
 		if c.Request.Body != nil {
-			bodyBytes, _ = io.ReadAll(c.Request.Body)
+			var err error
+			bodyBytes, err = io.ReadAll(c.Request.Body)
+			if err != nil && HandleRequestTooLarge(c, err) {
+				return
+			}
 			c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 			json.Unmarshal(bodyBytes, &bodyMap)
 		}
@@ -750,16 +794,22 @@ func OperationLogger() gin.HandlerFunc {
 			usernameStr = username.(string)
 		}
 
-		// 解析响应
+		// 解析响应（响应体过大时跳过解析，只按状态码判断结果）
 		var respData map[string]interface{}
-		json.Unmarshal(rw.body.Bytes(), &respData)
 		respCode := 0
 		respMsg := ""
-		if code, ok := respData["code"].(float64); ok {
-			respCode = int(code)
-		}
-		if msg, ok := respData["message"].(string); ok {
-			respMsg = msg
+		if rw.overflow {
+			if rw.status >= 400 {
+				respCode = rw.status
+			}
+		} else {
+			json.Unmarshal(rw.body.Bytes(), &respData)
+			if code, ok := respData["code"].(float64); ok {
+				respCode = int(code)
+			}
+			if msg, ok := respData["message"].(string); ok {
+				respMsg = msg
+			}
 		}
 
 		// 对于登录操作，从响应中提取用户信息（因为登录前 context 中还没有用户信息）
@@ -0,0 +1,62 @@
+/*
+ * 文件作用：API Key 令牌桶限速中间件，平滑限制单个 Key 的请求速率
+ * 负责功能：
+ *   - 按 API Key 的桶容量和填充速率限速（0 表示不限速）
+ *   - 桶状态保存在数据库中，多实例共享同一个桶
+ *   - 数据库出错时退化为本实例内存令牌桶，不阻断请求
+ *   - 超限返回 429 并附带 Retry-After 响应头
+ * 重要程度：⭐⭐⭐ 一般（API Key 限速）
+ * 依赖模块：cache, repository, model
+ */
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"go-aiproxy/internal/cache"
+	"go-aiproxy/internal/model"
+	"go-aiproxy/internal/repository"
+	"go-aiproxy/pkg/logger"
+	"go-aiproxy/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyRateLimit API Key 令牌桶限速中间件，需放在 APIKeyAuth 之后
+func APIKeyRateLimit() gin.HandlerFunc {
+	bucketRepo := repository.NewAPIKeyTokenBucketRepository()
+	limiter := cache.GetTokenBucketLimiter()
+	log := logger.GetLogger("middleware")
+
+	return func(c *gin.Context) {
+		key := GetAPIKey(c)
+		if key == nil || key.TokenBucketCapacity <= 0 || key.TokenBucketRate <= 0 {
+			c.Next()
+			return
+		}
+
+		allowed, wait, err := bucketRepo.Take(key.ID, key.TokenBucketCapacity, key.TokenBucketRate, time.Now())
+		if err != nil {
+			log.Warn("令牌桶读写数据库失败，只在本实例内限速 | KeyID: %d | Error: %v", key.ID, err)
+			allowed, wait = limiter.Allow(key.ID, key.TokenBucketCapacity, key.TokenBucketRate)
+		}
+		if !allowed {
+			retryAfter := int(math.Ceil(wait.Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			log.Warn("API Key 令牌桶限速 | KeyID: %d | Capacity: %d | Rate: %.2f/s | RetryAfter: %ds",
+				key.ID, key.TokenBucketCapacity, key.TokenBucketRate, retryAfter)
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			response.CustomTooManyRequestsAbort(c, model.ErrorTypeRateLimit,
+				fmt.Sprintf("rate limit exceeded: capacity %d, %.2f requests/second, retry after %d seconds",
+					key.TokenBucketCapacity, key.TokenBucketRate, retryAfter))
+			return
+		}
+
+		c.Next()
+	}
+}
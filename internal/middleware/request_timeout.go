@@ -0,0 +1,63 @@
+/*
+ * 文件作用：客户端可配置的请求超时中间件
+ * 负责功能：
+ *   - 解析 X-Request-Timeout 头（秒），为请求上下文设置截止时间
+ *   - 超过全局上限（max_request_timeout）时按上限处理
+ *   - 截止时间随请求上下文传递到重试层和上游 HTTP 请求，到期返回 504
+ * 重要程度：⭐⭐⭐ 一般（客户端超时控制）
+ * 依赖模块：service, model, pkg/response
+ */
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-aiproxy/internal/model"
+	"go-aiproxy/internal/service"
+	"go-aiproxy/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestTimeoutHeader 客户端指定请求整体超时（秒）的请求头
+const RequestTimeoutHeader = "X-Request-Timeout"
+
+// RequestTimeout 按 X-Request-Timeout 头设置请求整体超时
+// 未携带该头或全局上限为 0 时不设置超时；头的值不是正整数时返回 400
+func RequestTimeout() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw := strings.TrimSpace(c.GetHeader(RequestTimeoutHeader))
+		if raw == "" {
+			c.Next()
+			return
+		}
+
+		maxTimeout := service.GetConfigService().GetMaxRequestTimeout()
+		if maxTimeout <= 0 {
+			c.Next()
+			return
+		}
+
+		seconds, err := strconv.Atoi(raw)
+		if err != nil || seconds <= 0 {
+			response.CustomErrorAbort(c, http.StatusBadRequest, model.ErrorTypeBadRequest,
+				"invalid X-Request-Timeout: must be a positive number of seconds")
+			return
+		}
+
+		// 先按秒比较再换算，避免超大值乘法溢出成负数
+		timeout := maxTimeout
+		if seconds < int(maxTimeout/time.Second) {
+			timeout = time.Duration(seconds) * time.Second
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
@@ -4,14 +4,25 @@
  *   - 账户基础信息（名称、类型、状态）
  *   - OAuth凭证（Access/Refresh Token）
  *   - API密钥（Key/Secret）
- *   - 配额限制（并发、每日预算）
- *   - 分组关联
+ *   - 配额限制（并发、按模型并发、每日预算、每日请求数）
+ *   - 分组关联、分组调度策略
+ *   - 账户池归属（配置继承见 account_pool.go）
+ *   - 凭证字段加密存储（见 account_crypto.go）
+ *   - region / 标签（就近调度）
+ *   - 上游超时配置
+ *   - 上游限流窗口（x-ratelimit-* 响应头，剩余比例供调度避让）
+ *   - Claude 用量窗口（5H/7D 共享窗口、7D Opus/Sonnet 按模型族窗口）
+ *   - 多 API Key 轮换池
+ *   - 认证凭证选择（健康检查与转发共用）
+ *   - 所属组织（多租户隔离）
  * 重要程度：⭐⭐⭐⭐ 重要（核心数据结构）
  * 依赖模块：gorm
  */
 package model
 
 import (
+	"encoding/json"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -40,14 +51,23 @@ const (
 
 // 账户状态常量
 const (
-	AccountStatusValid        = "valid"         // 正常
-	AccountStatusInvalid      = "invalid"       // 无效/失效（兼容旧代码）
-	AccountStatusRateLimited  = "rate_limited"  // 限流中
-	AccountStatusOverloaded   = "overloaded"    // 过载
-	AccountStatusTokenExpired = "token_expired" // Token 过期，需要刷新
-	AccountStatusSuspended    = "suspended"     // 疑似封号，待验证
-	AccountStatusBanned       = "banned"        // 确认封号
-	AccountStatusDisabled     = "disabled"      // 手动禁用
+	AccountStatusValid          = "valid"           // 正常
+	AccountStatusInvalid        = "invalid"         // 无效/失效（兼容旧代码）
+	AccountStatusRateLimited    = "rate_limited"    // 限流中
+	AccountStatusOverloaded     = "overloaded"      // 过载
+	AccountStatusTokenExpired   = "token_expired"   // Token 过期，需要刷新
+	AccountStatusSuspended      = "suspended"       // 疑似封号，待验证
+	AccountStatusBanned         = "banned"          // 确认封号
+	AccountStatusDisabled       = "disabled"        // 手动禁用
+	AccountStatusCostLimited    = "cost_limited"    // 当日费用达到每日预算，次日自动恢复
+	AccountStatusRequestLimited = "request_limited" // 当日请求数达到每日请求上限，次日自动恢复
+)
+
+// 认证凭证类型常量（GetAuthToken 返回，用于日志定位）
+const (
+	AuthTokenKindSessionKey  = "session_key"
+	AuthTokenKindAccessToken = "access_token"
+	AuthTokenKindAPIKey      = "api_key"
 )
 
 // Account 账户模型
@@ -60,19 +80,47 @@ type Account struct {
 	Enabled   bool           `gorm:"default:true" json:"enabled"`             // 是否启用
 	Priority  int            `gorm:"default:50" json:"priority"`              // 优先级 1-100
 	Weight    int            `gorm:"default:100" json:"weight"`               // 权重
+	OrgID     uint           `gorm:"default:0;index" json:"org_id"`           // 所属组织（0=不属于任何组织）
+
+	// 就近调度：region 和自定义标签（如 us-west、jp-proxy）
+	Region string `gorm:"size:50;index" json:"region,omitempty"` // 所在 region / 上游端点
+	Tags   string `gorm:"size:500" json:"tags,omitempty"`        // 标签（逗号分隔）
+
+	// 账户池：未单独配置的可用模型、模型映射、Base URL、代理、并发上限从池继承（见 account_pool.go）
+	PoolID *uint `gorm:"index" json:"pool_id,omitempty"`
+
+	// 维护模式：不参与调度，但健康检查照常进行
+	MaintenanceMode  bool       `gorm:"default:false" json:"maintenance_mode"`    // 是否处于维护模式
+	MaintenanceSince *time.Time `json:"maintenance_since,omitempty"`             // 进入维护模式的时间
 
-	// 通用认证字段
-	APIKey      string `gorm:"size:500" json:"api_key,omitempty"`       // API Key
+	// 影子账户：不参与正式调度，只接收按比例复制的真实流量用于对比测试（结果不返回客户端、不计费）
+	ShadowMode bool `gorm:"default:false;index" json:"shadow_mode"`
+
+	// 通用认证字段（APIKey、AccessToken、RefreshToken 配置密钥后加密存储，见 account_crypto.go）
+	APIKey      string `gorm:"size:1000" json:"api_key,omitempty"`      // API Key
 	APISecret   string `gorm:"size:500" json:"api_secret,omitempty"`    // API Secret
-	AccessToken string `gorm:"size:2000" json:"access_token,omitempty"` // Access Token
-	RefreshToken string `gorm:"size:2000" json:"refresh_token,omitempty"` // Refresh Token
+	AccessToken string `gorm:"type:text" json:"access_token,omitempty"` // Access Token
+	RefreshToken string `gorm:"type:text" json:"refresh_token,omitempty"` // Refresh Token
 	TokenExpiry *time.Time `json:"token_expiry,omitempty"`               // Token 过期时间
+	TokenRefreshLockUntil *time.Time `json:"-"`                            // Token 刷新锁到期时间（多实例互斥，失败时兼作冷却）
+	TokenRefreshedAt *time.Time `json:"token_refreshed_at,omitempty"`      // 最近一次 Token 刷新成功时间
+
+	// 多 API Key 轮换（Claude Console）：与 APIKey 一起组成 Key 池，请求时轮询选用
+	APIKeys        string `gorm:"type:text" json:"api_keys,omitempty"`         // 额外的 API Key 列表（JSON 数组）
+	InvalidAPIKeys string `gorm:"type:text" json:"invalid_api_keys,omitempty"` // 健康检查标记为失效的 Key（JSON 数组）
 
 	// Claude Official 专用
-	SessionKey        string `gorm:"type:text" json:"session_key,omitempty"`        // Session Key
-	OrganizationID    string `gorm:"size:100" json:"organization_id,omitempty"`    // 组织 ID
-	SubscriptionLevel string `gorm:"size:20" json:"subscription_level,omitempty"`  // 订阅级别: free/pro/team
-	OpusAccess        bool   `gorm:"default:false" json:"opus_access"`             // 是否有 Opus 权限
+	SessionKey          string     `gorm:"type:text" json:"session_key,omitempty"`      // Session Key
+	SessionKeyValid     *bool      `json:"session_key_valid,omitempty"`                 // 最近一次 SessionKey 轻量校验结果，nil 表示未校验
+	SessionKeyCheckedAt *time.Time `json:"session_key_checked_at,omitempty"`            // 最近一次 SessionKey 校验时间
+	OrganizationID      string     `gorm:"size:100" json:"organization_id,omitempty"`   // 组织 ID
+	SubscriptionLevel   string     `gorm:"size:20" json:"subscription_level,omitempty"` // 订阅级别: free/pro/team
+	OpusAccess          bool       `gorm:"default:false" json:"opus_access"`            // 是否有 Opus 权限
+	AnthropicVersion    string     `gorm:"size:30" json:"anthropic_version,omitempty"`  // 请求缺少 anthropic-version 头时补全的值，默认 2023-06-01
+	XApp                string     `gorm:"size:50" json:"x_app,omitempty"`              // 请求缺少 x-app 头时补全的值，默认 cli
+	InlineImageURLs     bool       `gorm:"default:false" json:"inline_image_urls"`      // 把请求中的图片 URL 下载后改写为 base64 再发上游（中转站只接受 base64 图片时开启）
+	SystemPromptPrefix  string     `gorm:"type:text" json:"system_prompt_prefix,omitempty"` // 转发前合并到请求 system 开头的内容（全局开关开启且作用范围包含账户时生效）
+	SystemPromptSuffix  string     `gorm:"type:text" json:"system_prompt_suffix,omitempty"` // 转发前合并到请求 system 末尾的内容
 
 	// AWS Bedrock 专用
 	AWSAccessKey    string `gorm:"size:100" json:"aws_access_key,omitempty"`
@@ -85,13 +133,33 @@ type Account struct {
 	AzureDeploymentName string `gorm:"size:100" json:"azure_deployment_name,omitempty"`
 	AzureAPIVersion    string `gorm:"size:20" json:"azure_api_version,omitempty"`
 
+	// OpenAI Responses 专用：非 Codex CLI 请求的适配，为空时使用全局配置
+	ResponsesStripFields  string `gorm:"size:500" json:"responses_strip_fields,omitempty"` // 转发前删除的字段（逗号分隔），none 表示不删除
+	ResponsesInstructions string `gorm:"size:20" json:"responses_instructions,omitempty"`  // instructions 处理方式: override / fill / keep
+
 	// 通用配置
-	BaseURL        string  `gorm:"size:200" json:"base_url,omitempty"`        // 自定义 Base URL
-	ProxyID        *uint   `gorm:"index" json:"proxy_id,omitempty"`           // 关联的代理 ID
-	ModelMapping   string  `gorm:"type:text" json:"model_mapping,omitempty"`  // 模型映射 JSON
-	AllowedModels  string  `gorm:"type:text" json:"allowed_models,omitempty"` // 允许的模型列表
-	MaxConcurrency int     `gorm:"default:5" json:"max_concurrency"`          // 最大并发数
-	DailyBudget    float64 `gorm:"default:0" json:"daily_budget"`             // 每日预算（美元），0 表示不限制
+	BaseURL           string  `gorm:"size:200" json:"base_url,omitempty"`           // 自定义 Base URL
+	ProxyID           *uint   `gorm:"index" json:"proxy_id,omitempty"`              // 关联的代理 ID
+	ModelMapping      string  `gorm:"type:text" json:"model_mapping,omitempty"`     // 模型映射 JSON
+	AllowedModels     string  `gorm:"type:text" json:"allowed_models,omitempty"`    // 允许的模型列表
+	MaxConcurrency    int     `gorm:"default:5" json:"max_concurrency"`             // 最大并发数
+	ModelConcurrency  string  `gorm:"type:text" json:"model_concurrency,omitempty"` // 按模型并发上限 JSON（{"claude-opus-4": 2}），未配置的模型只受 MaxConcurrency 限制
+	DailyBudget       float64 `gorm:"default:0" json:"daily_budget"`                // 每日预算（美元），0 表示不限制，达到后当日停止调度
+	DailyCost         float64 `gorm:"default:0" json:"daily_cost"`                  // 当日累计费用（美元），跨天后重新累计
+	DailyCostDate     string  `gorm:"size:10" json:"daily_cost_date,omitempty"`     // DailyCost 对应的日期 YYYY-MM-DD（本地时间）
+	DailyRequestLimit int     `gorm:"default:0" json:"daily_request_limit"`         // 每日请求数上限，0 表示不限制，达到后当日停止调度（与每日预算独立生效）
+	DailyRequestCount int     `gorm:"default:0" json:"daily_request_count"`         // 当日请求数（设置了上限时发起请求前占用、失败退回），跨天后重新计数
+	DailyRequestDate  string  `gorm:"size:10" json:"daily_request_date,omitempty"`  // DailyRequestCount 对应的日期 YYYY-MM-DD（本地时间）
+
+	// 上游超时（秒），0 表示使用默认值
+	ConnectTimeout int `gorm:"default:0" json:"connect_timeout"` // 建连超时，默认 30 秒
+	ReadTimeout    int `gorm:"default:0" json:"read_timeout"`    // 等待响应头超时，默认不限制
+	RequestTimeout int `gorm:"default:0" json:"request_timeout"` // 非流式请求整体超时，默认 120 秒
+	StreamTimeout  int `gorm:"default:0" json:"stream_timeout"`  // 流式请求整体超时，默认 600 秒
+
+	// 健康检查探测端点，未配置时按账户类型使用默认检查
+	HealthCheckURL    string `gorm:"size:500" json:"health_check_url,omitempty"` // 探测 URL（完整 URL，或以 / 开头的路径拼接在 BaseURL 后）
+	HealthCheckExpect int    `gorm:"default:0" json:"health_check_expect"`       // 期望状态码，0 表示任意 2xx
 
 	// 关联对象
 	Proxy *Proxy `gorm:"foreignKey:ProxyID" json:"proxy,omitempty"` // 代理配置
@@ -109,6 +177,7 @@ type Account struct {
 	LastHealthCheckAt      *time.Time `json:"last_health_check_at,omitempty"`              // 最后健康检测时间
 	NextHealthCheckAt      *time.Time `json:"next_health_check_at,omitempty"`              // 下次健康检测时间
 	HealthCheckInterval    int        `gorm:"default:0" json:"health_check_interval"`      // 当前检测间隔（秒）
+	RecoveredAt            *time.Time `json:"recovered_at,omitempty"`                      // 最近一次从异常状态恢复的时间（调度冷却期起点）
 
 	// Claude 用量字段 (从 OAuth Usage API 获取)
 	UsageStatus          string     `gorm:"size:30" json:"usage_status,omitempty"`            // 5H窗口状态: allowed/allowed_warning/rejected
@@ -122,6 +191,17 @@ type Account struct {
 	SevenDayResetsAt          *time.Time `json:"seven_day_resets_at"`          // 7天窗口重置时间
 	SevenDaySonnetUtilization *float64   `json:"seven_day_sonnet_utilization"` // 7天Sonnet窗口用量百分比 (0-100)
 	SevenDaySonnetResetsAt    *time.Time `json:"seven_day_sonnet_resets_at"`   // 7天Sonnet窗口重置时间
+	SevenDayOpusUtilization   *float64   `json:"seven_day_opus_utilization"`   // 7天Opus窗口用量百分比 (0-100)
+	SevenDayOpusResetsAt      *time.Time `json:"seven_day_opus_resets_at"`     // 7天Opus窗口重置时间
+
+	// 通用限流窗口（OpenAI/ChatGPT 响应头 x-ratelimit-*，成功响应后更新），nil 表示上游未返回
+	RateLimitLimitRequests     *int       `json:"rate_limit_limit_requests,omitempty"`     // 请求数窗口上限
+	RateLimitRemainingRequests *int       `json:"rate_limit_remaining_requests,omitempty"` // 剩余请求数
+	RateLimitResetRequestsAt   *time.Time `json:"rate_limit_reset_requests_at,omitempty"`  // 请求数窗口重置时间
+	RateLimitLimitTokens       *int       `json:"rate_limit_limit_tokens,omitempty"`       // token 窗口上限
+	RateLimitRemainingTokens   *int       `json:"rate_limit_remaining_tokens,omitempty"`   // 剩余 token
+	RateLimitResetTokensAt     *time.Time `json:"rate_limit_reset_tokens_at,omitempty"`    // token 窗口重置时间
+	RateLimitUpdatedAt         *time.Time `json:"rate_limit_updated_at,omitempty"`         // 限流窗口更新时间
 
 	// 时间戳
 	CreatedAt time.Time      `json:"created_at"`
@@ -136,6 +216,292 @@ func (a *Account) TableName() string {
 	return "accounts"
 }
 
+// IsSchedulable 账户是否可参与调度（启用、状态正常、不在维护模式且不是影子账户）
+func (a *Account) IsSchedulable() bool {
+	return a.Enabled && a.Status == AccountStatusValid && !a.MaintenanceMode && !a.ShadowMode
+}
+
+// RateLimitWindow 上游限流窗口快照（从响应头解析），字段为 nil 表示上游未返回
+type RateLimitWindow struct {
+	LimitRequests     *int
+	RemainingRequests *int
+	ResetRequestsAt   *time.Time
+	LimitTokens       *int
+	RemainingTokens   *int
+	ResetTokensAt     *time.Time
+	UpdatedAt         time.Time
+}
+
+// Headroom 限流窗口剩余比例（0-1，请求数和 token 取较小者）
+// 窗口已过重置时间或上限未知的维度视为充足
+func (w *RateLimitWindow) Headroom(now time.Time) float64 {
+	headroom := 1.0
+	for _, dim := range []struct {
+		limit, remaining *int
+		resetAt          *time.Time
+	}{
+		{w.LimitRequests, w.RemainingRequests, w.ResetRequestsAt},
+		{w.LimitTokens, w.RemainingTokens, w.ResetTokensAt},
+	} {
+		if dim.limit == nil || dim.remaining == nil || *dim.limit <= 0 {
+			continue
+		}
+		if dim.resetAt != nil && !now.Before(*dim.resetAt) {
+			continue
+		}
+		if ratio := float64(*dim.remaining) / float64(*dim.limit); ratio < headroom {
+			headroom = ratio
+		}
+	}
+	if headroom < 0 {
+		return 0
+	}
+	return headroom
+}
+
+// StoredRateLimitWindow 返回账户持久化的限流窗口，未记录时返回 nil
+func (a *Account) StoredRateLimitWindow() *RateLimitWindow {
+	if a.RateLimitUpdatedAt == nil {
+		return nil
+	}
+	return &RateLimitWindow{
+		LimitRequests:     a.RateLimitLimitRequests,
+		RemainingRequests: a.RateLimitRemainingRequests,
+		ResetRequestsAt:   a.RateLimitResetRequestsAt,
+		LimitTokens:       a.RateLimitLimitTokens,
+		RemainingTokens:   a.RateLimitRemainingTokens,
+		ResetTokensAt:     a.RateLimitResetTokensAt,
+		UpdatedAt:         *a.RateLimitUpdatedAt,
+	}
+}
+
+// Claude 模型族（7 天窗口按模型族分别限额）
+const (
+	ClaudeModelFamilyOpus   = "opus"
+	ClaudeModelFamilySonnet = "sonnet"
+	ClaudeModelFamilyHaiku  = "haiku"
+)
+
+// ClaudeModelFamily 按模型名判断 Claude 模型族，无法识别时返回空
+func ClaudeModelFamily(modelName string) string {
+	name := strings.ToLower(modelName)
+	for _, family := range []string{ClaudeModelFamilyOpus, ClaudeModelFamilySonnet, ClaudeModelFamilyHaiku} {
+		if strings.Contains(name, family) {
+			return family
+		}
+	}
+	return ""
+}
+
+// Claude 用量窗口名（与 OAuth Usage API 返回的字段名一致）
+const (
+	ClaudeWindowFiveHour       = "five_hour"
+	ClaudeWindowSevenDay       = "seven_day"
+	ClaudeWindowSevenDayOpus   = "seven_day_opus"
+	ClaudeWindowSevenDaySonnet = "seven_day_sonnet"
+)
+
+// ClaudeUsageWindow 单个用量窗口，Utilization 为 nil 表示上游未返回
+type ClaudeUsageWindow struct {
+	Utilization *float64
+	ResetsAt    *time.Time
+}
+
+// Exhausted 窗口是否已用满（用量达到 100% 且未到重置时间）
+func (w ClaudeUsageWindow) Exhausted(now time.Time) bool {
+	if w.Utilization == nil || *w.Utilization < 100 {
+		return false
+	}
+	return w.ResetsAt == nil || now.Before(*w.ResetsAt)
+}
+
+// ClaudeUsageWindows Claude OAuth Usage API 返回的各窗口用量
+// 5H、7D 窗口所有模型共享；7D Opus、7D Sonnet 窗口只限制对应模型族
+type ClaudeUsageWindows struct {
+	FiveHour       ClaudeUsageWindow
+	SevenDay       ClaudeUsageWindow
+	SevenDayOpus   ClaudeUsageWindow
+	SevenDaySonnet ClaudeUsageWindow
+}
+
+// ExhaustedWindow 返回限制请求模型的已用满窗口名，模型可用时返回空
+// 第二个返回值表示该窗口只限制请求模型所属的模型族（其他模型族仍可用）
+func (w *ClaudeUsageWindows) ExhaustedWindow(modelName string, now time.Time) (string, bool) {
+	if w.FiveHour.Exhausted(now) {
+		return ClaudeWindowFiveHour, false
+	}
+	if w.SevenDay.Exhausted(now) {
+		return ClaudeWindowSevenDay, false
+	}
+	switch ClaudeModelFamily(modelName) {
+	case ClaudeModelFamilyOpus:
+		if w.SevenDayOpus.Exhausted(now) {
+			return ClaudeWindowSevenDayOpus, true
+		}
+	case ClaudeModelFamilySonnet:
+		if w.SevenDaySonnet.Exhausted(now) {
+			return ClaudeWindowSevenDaySonnet, true
+		}
+	}
+	return "", false
+}
+
+// StoredClaudeUsageWindows 返回账户持久化的 Claude 用量窗口，未记录时返回 nil
+func (a *Account) StoredClaudeUsageWindows() *ClaudeUsageWindows {
+	if a.FiveHourUtilization == nil && a.SevenDayUtilization == nil &&
+		a.SevenDayOpusUtilization == nil && a.SevenDaySonnetUtilization == nil {
+		return nil
+	}
+	return &ClaudeUsageWindows{
+		FiveHour:       ClaudeUsageWindow{Utilization: a.FiveHourUtilization, ResetsAt: a.FiveHourResetsAt},
+		SevenDay:       ClaudeUsageWindow{Utilization: a.SevenDayUtilization, ResetsAt: a.SevenDayResetsAt},
+		SevenDayOpus:   ClaudeUsageWindow{Utilization: a.SevenDayOpusUtilization, ResetsAt: a.SevenDayOpusResetsAt},
+		SevenDaySonnet: ClaudeUsageWindow{Utilization: a.SevenDaySonnetUtilization, ResetsAt: a.SevenDaySonnetResetsAt},
+	}
+}
+
+// DailyRequestsUsed 当日已用请求数（计数日期不是今天时为 0）
+func (a *Account) DailyRequestsUsed() int {
+	if a.DailyRequestDate != time.Now().Format("2006-01-02") {
+		return 0
+	}
+	return a.DailyRequestCount
+}
+
+// GetAuthToken 选择本账户使用的认证凭证，返回 token 及其类型
+// 优先级: SessionKey > AccessToken > APIKey，健康检查与实际转发必须共用，避免探测和转发用的不是同一个 token
+func (a *Account) GetAuthToken() (token, kind string) {
+	switch {
+	case a.SessionKey != "":
+		return a.SessionKey, AuthTokenKindSessionKey
+	case a.AccessToken != "":
+		return a.AccessToken, AuthTokenKindAccessToken
+	case a.APIKey != "":
+		return a.APIKey, AuthTokenKindAPIKey
+	}
+	return "", ""
+}
+
+// APIKeyPool 账户的全部 API Key（APIKey 在前，去空去重）
+func (a *Account) APIKeyPool() []string {
+	keys := make([]string, 0, 1)
+	if a.APIKey != "" {
+		keys = append(keys, a.APIKey)
+	}
+	for _, key := range parseKeyList(a.APIKeys) {
+		if !containsString(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// AvailableAPIKeys Key 池中未被标记失效的 API Key
+// 全部失效时返回完整的 Key 池，交给上游返回真实错误
+func (a *Account) AvailableAPIKeys() []string {
+	pool := a.APIKeyPool()
+	invalid := parseKeyList(a.InvalidAPIKeys)
+	if len(invalid) == 0 {
+		return pool
+	}
+	keys := make([]string, 0, len(pool))
+	for _, key := range pool {
+		if !containsString(invalid, key) {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return pool
+	}
+	return keys
+}
+
+// ParseAPIKeys 解析 JSON 数组格式的 API Key 列表
+func ParseAPIKeys(raw string) ([]string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	var keys []string
+	if err := json.Unmarshal([]byte(raw), &keys); err != nil {
+		return nil, err
+	}
+	result := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if key = strings.TrimSpace(key); key != "" {
+			result = append(result, key)
+		}
+	}
+	return result, nil
+}
+
+func parseKeyList(raw string) []string {
+	keys, _ := ParseAPIKeys(raw)
+	return keys
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseModelConcurrency 解析按模型并发上限 JSON（模型名 -> 上限）
+func ParseModelConcurrency(raw string) (map[string]int, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	var limits map[string]int
+	if err := json.Unmarshal([]byte(raw), &limits); err != nil {
+		return nil, err
+	}
+	return limits, nil
+}
+
+// GetModelConcurrencyLimit 获取模型的并发上限，0 表示未单独配置
+// 优先精确匹配，其次按最长前缀匹配（如 claude-opus-4 覆盖 claude-opus-4-20250514）
+func (a *Account) GetModelConcurrencyLimit(modelName string) int {
+	limits, _ := ParseModelConcurrency(a.ModelConcurrency)
+	if len(limits) == 0 || modelName == "" {
+		return 0
+	}
+	if limit, ok := limits[modelName]; ok && limit > 0 {
+		return limit
+	}
+	limit, matched := 0, 0
+	for prefix, l := range limits {
+		if l > 0 && len(prefix) > matched && strings.HasPrefix(modelName, prefix) {
+			limit, matched = l, len(prefix)
+		}
+	}
+	return limit
+}
+
+// MatchesRegion 账户 region 或任一标签与偏好一致（不区分大小写）
+func (a *Account) MatchesRegion(region string) bool {
+	if region == "" {
+		return false
+	}
+	return strings.EqualFold(a.Region, region) || a.HasTag(region)
+}
+
+// HasTag 账户是否带有指定标签（不区分大小写）
+func (a *Account) HasTag(tag string) bool {
+	if tag == "" {
+		return false
+	}
+	for _, t := range strings.Split(a.Tags, ",") {
+		if strings.EqualFold(strings.TrimSpace(t), tag) {
+			return true
+		}
+	}
+	return false
+}
+
 // GetPlatformByType 根据账户类型获取平台
 func GetPlatformByType(accountType string) string {
 	switch accountType {
@@ -150,13 +516,30 @@ func GetPlatformByType(accountType string) string {
 	}
 }
 
+// 账户分组调度策略
+const (
+	AccountGroupStrategyRandom     = "random"      // 随机（按权重随机，与全局调度一致）
+	AccountGroupStrategyRoundRobin = "round_robin" // 加权轮询（组内严格按权重比例分流）
+	AccountGroupStrategyFailover   = "failover"    // 故障转移（组内按优先级，前面的账户不可用才用后面的）
+)
+
+// IsValidAccountGroupStrategy 是否为支持的分组调度策略
+func IsValidAccountGroupStrategy(strategy string) bool {
+	switch strategy {
+	case AccountGroupStrategyRandom, AccountGroupStrategyRoundRobin, AccountGroupStrategyFailover:
+		return true
+	}
+	return false
+}
+
 // AccountGroup 账户分组
 type AccountGroup struct {
 	ID          uint           `gorm:"primarykey" json:"id"`
 	Name        string         `gorm:"size:100;not null;uniqueIndex" json:"name"`
 	Description string         `gorm:"size:500" json:"description,omitempty"`
-	Platform    string         `gorm:"size:20" json:"platform,omitempty"` // 限定平台
-	IsDefault   bool           `gorm:"default:false" json:"is_default"`   // 是否默认分组
+	Platform    string         `gorm:"size:20" json:"platform,omitempty"`      // 限定平台
+	IsDefault   bool           `gorm:"default:false" json:"is_default"`        // 是否默认分组
+	Strategy    string         `gorm:"size:20;default:random" json:"strategy"` // 组内调度策略
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
@@ -0,0 +1,28 @@
+/*
+ * 文件作用：账户变更事件模型，多实例部署时广播账户状态变化
+ * 负责功能：
+ *   - 记录账户状态变更（来源实例、平台、新状态）
+ *   - 各实例按自增 ID 增量拉取，刷新调度器缓存
+ * 重要程度：⭐⭐⭐ 一般（多实例缓存一致性）
+ * 依赖模块：无
+ */
+package model
+
+import (
+	"time"
+)
+
+// AccountChangeEvent 账户变更事件
+type AccountChangeEvent struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	AccountID  uint      `gorm:"index" json:"account_id"`    // 变更的账户 ID，0 表示批量变更
+	Platform   string    `gorm:"size:20" json:"platform"`    // 账户平台，为空表示刷新所有平台
+	Status     string    `gorm:"size:20" json:"status"`      // 变更后的状态（仅供排查）
+	InstanceID string    `gorm:"size:64" json:"instance_id"` // 发布事件的实例
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`    // 创建时间
+}
+
+// TableName 表名
+func (AccountChangeEvent) TableName() string {
+	return "account_change_events"
+}
@@ -0,0 +1,65 @@
+/*
+ * 文件作用：账户凭证字段加密存储，GORM 读写钩子自动加解密
+ * 负责功能：
+ *   - 写库前用当前密钥加密 APIKey、APIKeys、AccessToken、RefreshToken、SessionKey
+ *   - 写库后、读库后解密，上层拿到的始终是明文
+ *   - 未配置密钥时原样存取，加密前写入的明文旧数据可直接读取
+ * 重要程度：⭐⭐⭐⭐ 重要（凭证安全）
+ * 依赖模块：gorm, utils
+ */
+package model
+
+import (
+	"fmt"
+
+	"go-aiproxy/pkg/utils"
+
+	"gorm.io/gorm"
+)
+
+// CredentialColumns 加密存储的凭证列（与 credentialFields 顺序一致，迁移工具按列读写）
+var CredentialColumns = []string{"api_key", "api_keys", "access_token", "refresh_token", "session_key"}
+
+// credentialFields 加密存储的凭证字段
+func (a *Account) credentialFields() []*string {
+	return []*string{&a.APIKey, &a.APIKeys, &a.AccessToken, &a.RefreshToken, &a.SessionKey}
+}
+
+// EncryptCredentials 用当前密钥加密凭证字段（已加密的字段保持不变）
+func (a *Account) EncryptCredentials() error {
+	for i, field := range a.credentialFields() {
+		encrypted, err := utils.EncryptField(*field)
+		if err != nil {
+			return fmt.Errorf("encrypt %s: %w", CredentialColumns[i], err)
+		}
+		*field = encrypted
+	}
+	return nil
+}
+
+// DecryptCredentials 解密凭证字段（明文字段保持不变）
+func (a *Account) DecryptCredentials() error {
+	for i, field := range a.credentialFields() {
+		plaintext, err := utils.DecryptField(*field)
+		if err != nil {
+			return fmt.Errorf("account %d %s: %w", a.ID, CredentialColumns[i], err)
+		}
+		*field = plaintext
+	}
+	return nil
+}
+
+// BeforeSave 写库前加密凭证（Create、Save、Updates 结构体时触发；按 map 更新凭证列需自行调用 utils.EncryptField）
+func (a *Account) BeforeSave(tx *gorm.DB) error {
+	return a.EncryptCredentials()
+}
+
+// AfterSave 写库后恢复明文，调用方继续使用的仍是明文
+func (a *Account) AfterSave(tx *gorm.DB) error {
+	return a.DecryptCredentials()
+}
+
+// AfterFind 读库后解密
+func (a *Account) AfterFind(tx *gorm.DB) error {
+	return a.DecryptCredentials()
+}
@@ -0,0 +1,79 @@
+/*
+ * 文件作用：账户池数据模型，把多个同质账户虚拟成一个逻辑账户
+ * 负责功能：
+ *   - 池统一配置（可用模型、模型映射、Base URL、代理、并发上限）
+ *   - 池作为一个调度单位的优先级、权重和池内调度策略
+ *   - 池内账户的配置继承（账户未单独配置的字段使用池配置）
+ * 重要程度：⭐⭐⭐ 一般（大规模账户管理）
+ * 依赖模块：gorm
+ */
+package model
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AccountPool 账户池：池内账户只需填写凭证，其余配置从池继承
+type AccountPool struct {
+	ID          uint   `gorm:"primarykey" json:"id"`
+	Name        string `gorm:"size:100;not null;uniqueIndex" json:"name"`
+	Description string `gorm:"size:500" json:"description,omitempty"`
+	Platform    string `gorm:"size:20;index" json:"platform"` // 池内账户所属平台
+	Enabled     bool   `gorm:"default:true" json:"enabled"`   // 停用后池内账户都不参与调度
+
+	// 调度：池作为一个逻辑账户按 Priority * Weight 参与选择，选中后按 Strategy 在池内选账户
+	Priority int    `gorm:"default:50" json:"priority"`
+	Weight   int    `gorm:"default:100" json:"weight"`
+	Strategy string `gorm:"size:20;default:random" json:"strategy"` // random / round_robin / failover（同账户分组策略）
+
+	// 统一配置，池内账户未单独配置时继承
+	BaseURL          string `gorm:"size:200" json:"base_url,omitempty"`
+	ProxyID          *uint  `gorm:"index" json:"proxy_id,omitempty"`
+	AllowedModels    string `gorm:"type:text" json:"allowed_models,omitempty"`
+	ModelMapping     string `gorm:"type:text" json:"model_mapping,omitempty"`
+	MaxConcurrency   int    `gorm:"default:0" json:"max_concurrency"` // 每个账户的并发上限，0 表示使用账户自身配置
+	ModelConcurrency string `gorm:"type:text" json:"model_concurrency,omitempty"`
+
+	Proxy *Proxy `gorm:"foreignKey:ProxyID" json:"proxy,omitempty"`
+
+	// 池内账户数（列表查询时填充）
+	AccountCount int64 `gorm:"-" json:"account_count"`
+
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (p *AccountPool) TableName() string {
+	return "account_pools"
+}
+
+// ApplyPool 继承账户池配置：账户未单独配置的字段使用池配置，池设置了并发上限时覆盖账户的并发上限
+// 只用于调度和健康检查的内存副本，不要写回数据库
+func (a *Account) ApplyPool(pool *AccountPool) {
+	if pool == nil {
+		return
+	}
+	if a.BaseURL == "" {
+		a.BaseURL = pool.BaseURL
+	}
+	if a.ProxyID == nil && pool.ProxyID != nil {
+		a.ProxyID = pool.ProxyID
+		a.Proxy = pool.Proxy
+	}
+	if strings.TrimSpace(a.AllowedModels) == "" {
+		a.AllowedModels = pool.AllowedModels
+	}
+	if mapping := strings.TrimSpace(a.ModelMapping); mapping == "" || mapping == "{}" {
+		a.ModelMapping = pool.ModelMapping
+	}
+	if pool.MaxConcurrency > 0 {
+		a.MaxConcurrency = pool.MaxConcurrency
+	}
+	if a.ModelConcurrency == "" {
+		a.ModelConcurrency = pool.ModelConcurrency
+	}
+}
@@ -2,7 +2,8 @@
  * 文件作用：AI模型数据模型，定义模型配置和定价信息
  * 负责功能：
  *   - 模型基础信息（名称、平台、提供商）
- *   - 定价配置（输入/输出/缓存价格）
+ *   - 定价配置（输入/输出/缓存/思考价格）
+ *   - 长上下文（1M context）分段定价
  *   - 模型能力和限制
  *   - 别名和分类
  * 重要程度：⭐⭐⭐ 一般（模型数据结构）
@@ -18,27 +19,32 @@ import (
 
 // AIModel AI 模型定义
 type AIModel struct {
-	ID               uint           `gorm:"primarykey" json:"id"`
-	Name             string         `gorm:"size:100;not null;uniqueIndex" json:"name"`              // 模型名称，如 claude-3-5-sonnet
-	DisplayName      string         `gorm:"size:100" json:"display_name"`                           // 显示名称
-	Platform         string         `gorm:"size:20;not null;index" json:"platform"`                 // 平台: claude/openai/gemini
-	Provider         string         `gorm:"size:50" json:"provider"`                                // 提供商: anthropic/openai/google
-	Description      string         `gorm:"size:500" json:"description"`                            // 描述
-	Category         string         `gorm:"size:30" json:"category"`                                // 分类: chat/completion/embedding/image
-	ContextSize      int            `gorm:"default:0" json:"context_size"`                          // 上下文长度
-	MaxOutput        int            `gorm:"default:0" json:"max_output"`                            // 最大输出长度
-	InputPrice       float64        `gorm:"type:decimal(10,6);default:0" json:"input_price"`        // 输入价格 ($/1M tokens)
-	OutputPrice      float64        `gorm:"type:decimal(10,6);default:0" json:"output_price"`       // 输出价格 ($/1M tokens)
-	CacheCreatePrice float64        `gorm:"type:decimal(10,6);default:0" json:"cache_create_price"` // 缓存创建价格 ($/1M tokens)
-	CacheReadPrice   float64        `gorm:"type:decimal(10,6);default:0" json:"cache_read_price"`   // 缓存读取价格 ($/1M tokens)
-	Enabled          bool           `gorm:"default:true" json:"enabled"`                            // 是否启用
-	IsDefault        bool           `gorm:"default:false" json:"is_default"`                        // 是否默认模型
-	SortOrder        int            `gorm:"default:0" json:"sort_order"`                            // 排序
-	Aliases          string         `gorm:"type:text" json:"aliases"`                               // 别名列表，逗号分隔
-	Capabilities     string         `gorm:"type:text" json:"capabilities"`                          // 能力列表 JSON
-	CreatedAt        time.Time      `json:"created_at"`
-	UpdatedAt        time.Time      `json:"updated_at"`
-	DeletedAt        gorm.DeletedAt `gorm:"index" json:"-"`
+	ID               uint    `gorm:"primarykey" json:"id"`
+	Name             string  `gorm:"size:100;not null;uniqueIndex" json:"name"`              // 模型名称，如 claude-3-5-sonnet
+	DisplayName      string  `gorm:"size:100" json:"display_name"`                           // 显示名称
+	Platform         string  `gorm:"size:20;not null;index" json:"platform"`                 // 平台: claude/openai/gemini
+	Provider         string  `gorm:"size:50" json:"provider"`                                // 提供商: anthropic/openai/google
+	Description      string  `gorm:"size:500" json:"description"`                            // 描述
+	Category         string  `gorm:"size:30" json:"category"`                                // 分类: chat/completion/embedding/image
+	ContextSize      int     `gorm:"default:0" json:"context_size"`                          // 上下文长度
+	MaxOutput        int     `gorm:"default:0" json:"max_output"`                            // 最大输出长度
+	InputPrice       float64 `gorm:"type:decimal(10,6);default:0" json:"input_price"`        // 输入价格 ($/1M tokens)
+	OutputPrice      float64 `gorm:"type:decimal(10,6);default:0" json:"output_price"`       // 输出价格 ($/1M tokens)
+	CacheCreatePrice float64 `gorm:"type:decimal(10,6);default:0" json:"cache_create_price"` // 缓存创建价格 ($/1M tokens)
+	CacheReadPrice   float64 `gorm:"type:decimal(10,6);default:0" json:"cache_read_price"`   // 缓存读取价格 ($/1M tokens)
+	ThinkingPrice    float64 `gorm:"type:decimal(10,6);default:0" json:"thinking_price"`     // 思考/推理价格 ($/1M tokens)，用于 extended thinking 和 OpenAI reasoning token，0 表示与输出价格相同
+	// 长上下文档位：输入上下文（含缓存）超过阈值时整次请求按倍数计价，阈值为 0 表示不分档
+	LongContextThreshold        int            `gorm:"default:0" json:"long_context_threshold"`                           // 长上下文阈值 (tokens)，如 200000
+	LongContextInputMultiplier  float64        `gorm:"type:decimal(6,3);default:0" json:"long_context_input_multiplier"`  // 超阈值后输入/缓存价格倍数
+	LongContextOutputMultiplier float64        `gorm:"type:decimal(6,3);default:0" json:"long_context_output_multiplier"` // 超阈值后输出/思考价格倍数，0 表示同输入倍数
+	Enabled                     bool           `gorm:"default:true" json:"enabled"`                                       // 是否启用
+	IsDefault                   bool           `gorm:"default:false" json:"is_default"`                                   // 是否默认模型
+	SortOrder                   int            `gorm:"default:0" json:"sort_order"`                                       // 排序
+	Aliases                     string         `gorm:"type:text" json:"aliases"`                                          // 别名列表，逗号分隔
+	Capabilities                string         `gorm:"type:text" json:"capabilities"`                                     // 能力列表 JSON
+	CreatedAt                   time.Time      `json:"created_at"`
+	UpdatedAt                   time.Time      `json:"updated_at"`
+	DeletedAt                   gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 func (m *AIModel) TableName() string {
@@ -49,14 +55,14 @@ func (m *AIModel) TableName() string {
 var DefaultModels = []AIModel{
 	// Claude 4.5 系列 (2025)
 	{Name: "claude-opus-4-5-20251101", DisplayName: "Claude Opus 4.5", Platform: "claude", Provider: "anthropic", Category: "chat", ContextSize: 200000, MaxOutput: 32000, InputPrice: 15.0, OutputPrice: 75.0, Enabled: true, IsDefault: true, SortOrder: 1},
-	{Name: "claude-sonnet-4-5-20250929", DisplayName: "Claude Sonnet 4.5", Platform: "claude", Provider: "anthropic", Category: "chat", ContextSize: 200000, MaxOutput: 64000, InputPrice: 3.0, OutputPrice: 15.0, Enabled: true, SortOrder: 2},
+	{Name: "claude-sonnet-4-5-20250929", DisplayName: "Claude Sonnet 4.5", Platform: "claude", Provider: "anthropic", Category: "chat", ContextSize: 200000, MaxOutput: 64000, InputPrice: 3.0, OutputPrice: 15.0, LongContextThreshold: 200000, LongContextInputMultiplier: 2.0, LongContextOutputMultiplier: 1.5, Enabled: true, SortOrder: 2},
 	{Name: "claude-haiku-4-5-20251001", DisplayName: "Claude Haiku 4.5", Platform: "claude", Provider: "anthropic", Category: "chat", ContextSize: 200000, MaxOutput: 8192, InputPrice: 1.0, OutputPrice: 5.0, Enabled: true, SortOrder: 3},
 
 	// Claude 4.1 系列 (2025)
 	{Name: "claude-opus-4-1-20250805", DisplayName: "Claude Opus 4.1", Platform: "claude", Provider: "anthropic", Category: "chat", ContextSize: 200000, MaxOutput: 32000, InputPrice: 15.0, OutputPrice: 75.0, Enabled: true, SortOrder: 4},
 
 	// Claude 4 系列 (2025)
-	{Name: "claude-sonnet-4-20250514", DisplayName: "Claude Sonnet 4", Platform: "claude", Provider: "anthropic", Category: "chat", ContextSize: 1000000, MaxOutput: 64000, InputPrice: 3.0, OutputPrice: 15.0, Enabled: true, SortOrder: 5},
+	{Name: "claude-sonnet-4-20250514", DisplayName: "Claude Sonnet 4", Platform: "claude", Provider: "anthropic", Category: "chat", ContextSize: 1000000, MaxOutput: 64000, InputPrice: 3.0, OutputPrice: 15.0, LongContextThreshold: 200000, LongContextInputMultiplier: 2.0, LongContextOutputMultiplier: 1.5, Enabled: true, SortOrder: 5},
 	{Name: "claude-opus-4-20250514", DisplayName: "Claude Opus 4", Platform: "claude", Provider: "anthropic", Category: "chat", ContextSize: 200000, MaxOutput: 32000, InputPrice: 15.0, OutputPrice: 75.0, Enabled: true, SortOrder: 6},
 
 	// Claude 3.7 系列 (2025)
@@ -82,4 +88,10 @@ var DefaultModels = []AIModel{
 
 	// Gemini 2.0 系列 (2024-2025)
 	{Name: "gemini-2.0-flash", DisplayName: "Gemini 2.0 Flash", Platform: "gemini", Provider: "google", Category: "chat", ContextSize: 1048576, MaxOutput: 8192, InputPrice: 0.1, OutputPrice: 0.4, Enabled: true, SortOrder: 23, Aliases: "gemini-2.0-flash-exp"},
+
+	// Embedding 模型（只有输入价格，/v1/embeddings 按输入 token 计费）
+	{Name: "text-embedding-3-small", DisplayName: "Text Embedding 3 Small", Platform: "openai", Provider: "openai", Category: "embedding", ContextSize: 8191, InputPrice: 0.02, Enabled: true, SortOrder: 30},
+	{Name: "text-embedding-3-large", DisplayName: "Text Embedding 3 Large", Platform: "openai", Provider: "openai", Category: "embedding", ContextSize: 8191, InputPrice: 0.13, Enabled: true, SortOrder: 31},
+	{Name: "text-embedding-ada-002", DisplayName: "Text Embedding Ada 002", Platform: "openai", Provider: "openai", Category: "embedding", ContextSize: 8191, InputPrice: 0.1, Enabled: true, SortOrder: 32},
+	{Name: "gemini-embedding-001", DisplayName: "Gemini Embedding", Platform: "gemini", Provider: "google", Category: "embedding", ContextSize: 2048, InputPrice: 0.15, Enabled: true, SortOrder: 33},
 }
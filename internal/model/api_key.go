@@ -4,8 +4,18 @@
  *   - API Key基础信息（名称、状态）
  *   - Key哈希存储
  *   - 套餐绑定
- *   - 权限控制（平台、模型、客户端）
+ *   - 权限控制（平台、模型、客户端、IP 白名单）
  *   - 限制配置（频率、每日限制）
+ *   - 会话粘性策略
+ *   - 流式响应隐藏思考内容
+ *   - 响应缓存（确定性请求）
+ *   - 跨平台兜底开关
+ *   - 降级响应（账户全部不可用时返回预设内容）
+ *   - 调度优先级
+ *   - 强制指定账户权限（调试/灰度）
+ *   - 单请求输出 token 上限
+ *   - Claude system prompt 注入（前缀/后缀）
+ *   - 所属组织（与所属用户一致，调度时只使用同组织账户）
  *   - Key生成和验证方法
  * 重要程度：⭐⭐⭐⭐ 重要（核心数据结构）
  * 依赖模块：gorm
@@ -16,6 +26,10 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
 	"time"
 
 	"gorm.io/gorm"
@@ -25,10 +39,33 @@ const (
 	APIKeyPrefix = "sk-" // API Key 前缀
 )
 
+// 会话粘性策略
+const (
+	SessionStickinessBestEffort = "best_effort" // 尽力粘性：绑定账户不可用时换账户
+	SessionStickinessStrict     = "strict"      // 严格粘性：绑定账户不可用时返回错误
+
+	SessionStickinessHeader = "X-Session-Stickiness" // 客户端指定粘性策略的请求头
+)
+
+// StripThinkingHeader 客户端要求流式响应隐藏 thinking / reasoning 内容的请求头（true / false）
+const StripThinkingHeader = "X-Strip-Thinking"
+
+// DegradedResponseHeader 响应头，告知客户端本次返回的是降级响应（预设内容，未调用上游）
+const DegradedResponseHeader = "X-Degraded-Response"
+
+// ForceAccountHeader 绕过调度直接使用指定账户的请求头（账户 ID，仅 AllowForceAccount 的 Key 可用）
+const ForceAccountHeader = "X-Force-Account-Id"
+
+// IsValidSessionStickiness 是否为合法的会话粘性策略（空表示默认）
+func IsValidSessionStickiness(s string) bool {
+	return s == "" || s == SessionStickinessBestEffort || s == SessionStickinessStrict
+}
+
 // APIKey API 密钥模型
 type APIKey struct {
 	ID          uint           `gorm:"primarykey" json:"id"`
 	UserID      uint           `gorm:"index;not null" json:"user_id"`             // 所属用户
+	OrgID       uint           `gorm:"default:0;index" json:"org_id"`             // 所属组织（与所属用户一致）
 	Name        string         `gorm:"size:100;not null" json:"name"`             // 名称
 	KeyHash     string         `gorm:"size:64;uniqueIndex;not null" json:"-"`     // Key 的 SHA256 哈希
 	KeyFull     string         `gorm:"size:100" json:"key_full"`                  // 完整的 Key (管理员可见)
@@ -46,9 +83,54 @@ type APIKey struct {
 	AllowedModels    string `gorm:"type:text" json:"allowed_models,omitempty"`     // 允许的模型列表 (逗号分隔)
 	BlockedModels    string `gorm:"type:text" json:"blocked_models,omitempty"`     // 禁止的模型列表 (逗号分隔)
 	AllowedClients   string `gorm:"size:200" json:"allowed_clients,omitempty"`     // 允许的客户端类型 (逗号分隔, 如: claude_code,codex_cli)
+	AllowedIPs       string `gorm:"type:text" json:"allowed_ips,omitempty"`        // IP 白名单 (逗号或换行分隔, 支持单 IP 和 CIDR, 空表示不限制)
+
+	// 就近调度：优先选择该 region（或标签）的账户，请求头 X-Preferred-Region 可覆盖
+	PreferredRegion string `gorm:"size:50" json:"preferred_region,omitempty"`
+
+	// 灰度：CanaryPercent% 的请求只调度带 CanaryTag 标签的账户（覆盖全局灰度配置），标签为空时使用全局配置
+	CanaryTag     string `gorm:"size:50" json:"canary_tag,omitempty"`
+	CanaryPercent int    `gorm:"default:0" json:"canary_percent"`
+
+	// 会话粘性策略：best_effort(默认，绑定账户不可用时换账户) / strict(绑定账户不可用时直接报错)
+	// 请求头 X-Session-Stickiness 可覆盖
+	SessionStickiness string `gorm:"size:20" json:"session_stickiness,omitempty"`
+
+	// 流式响应隐藏 thinking / reasoning 内容，只转发最终答案（思考 token 照常计费），请求头 X-Strip-Thinking 可覆盖
+	StripThinking bool `gorm:"default:false" json:"strip_thinking"`
+
+	// 响应缓存：temperature=0 的非流式请求按请求体缓存响应，相同请求直接返回（请求头 Cache-Control: no-cache 跳过）
+	ResponseCacheEnabled bool `gorm:"default:false" json:"response_cache_enabled"`
+	ResponseCacheTTL     int  `gorm:"default:0" json:"response_cache_ttl"` // 缓存时间（秒），0 使用全局配置
+
+	// 模型回退
+	ModelFallback        string `gorm:"type:text" json:"model_fallback,omitempty"`   // 模型回退链（覆盖全局配置，每行一条，如 opus->sonnet->haiku）
+	DisableModelFallback bool   `gorm:"default:false" json:"disable_model_fallback"` // 禁用模型回退
+
+	// 跨平台兜底：Claude 账户全部不可用时把 Claude 请求转换为 OpenAI 格式发给 OpenAI 账户
+	CrossPlatformFallback bool `gorm:"default:false" json:"cross_platform_fallback"`
+
+	// 降级响应：账户全部不可用时以 200 返回预设文本作为 assistant 回复（usage 为 0），而不是报错
+	DegradeResponseEnabled bool   `gorm:"default:false" json:"degrade_response_enabled"`
+	DegradeResponseText    string `gorm:"type:text" json:"degrade_response_text,omitempty"` // 预设文本，为空使用全局配置
+
+	// 调度优先级：账户并发全满排队时数值大的先拿到槽位，与套餐优先级取较大者（仅管理员可设置）
+	Priority int `gorm:"default:0" json:"priority"`
+
+	// 允许通过请求头 X-Force-Account-Id 绕过调度直接使用指定账户，失败不切换账户（调试/灰度用，仅管理员可设置）
+	AllowForceAccount bool `gorm:"default:false" json:"allow_force_account"`
+
+	// 单请求输出 token 上限，超过时按全局配置改写或拒绝，0 表示使用全局配置（仅管理员可设置）
+	MaxOutputTokensLimit int `gorm:"default:0" json:"max_output_tokens_limit"`
+
+	// Claude 请求转发前合并到 system 开头/末尾的内容（全局开关开启且作用范围包含 API Key 时生效，仅管理员可设置）
+	SystemPromptPrefix string `gorm:"type:text" json:"system_prompt_prefix,omitempty"`
+	SystemPromptSuffix string `gorm:"type:text" json:"system_prompt_suffix,omitempty"`
 
 	// 限制配置
 	RateLimit     int        `gorm:"default:60" json:"rate_limit"`               // 每分钟请求限制
+	TokenBucketCapacity int     `gorm:"default:0" json:"token_bucket_capacity"` // 令牌桶容量，即允许的突发请求数 (0=不限速)
+	TokenBucketRate     float64 `gorm:"default:0" json:"token_bucket_rate"`     // 令牌桶填充速率，即稳态每秒请求数 (0=不限速)
 	DailyLimit    int        `gorm:"default:0" json:"daily_limit"`               // 每日请求限制 (0=不限)
 	MonthlyQuota  float64    `gorm:"type:decimal(10,2);default:0" json:"monthly_quota"` // 月额度 (美元，0=不限)
 	ExpiresAt     *time.Time `json:"expires_at,omitempty"`                       // 过期时间
@@ -121,3 +203,75 @@ func (k *APIKey) GetEffectivePriceRate(userPriceRate float64) float64 {
 	// 否则使用用户的倍率
 	return userPriceRate
 }
+
+// ParseAllowedIPs 解析 IP 白名单配置，单 IP 会转换为 /32 或 /128 的 CIDR
+func ParseAllowedIPs(allowedIPs string) ([]*net.IPNet, error) {
+	fields := strings.FieldsFunc(allowedIPs, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r' || r == ' ' || r == '\t'
+	})
+
+	nets := make([]*net.IPNet, 0, len(fields))
+	for _, field := range fields {
+		if strings.Contains(field, "/") {
+			_, ipNet, err := net.ParseCIDR(field)
+			if err != nil {
+				return nil, fmt.Errorf("无效的 CIDR: %s", field)
+			}
+			nets = append(nets, ipNet)
+			continue
+		}
+
+		ip := net.ParseIP(field)
+		if ip == nil {
+			return nil, fmt.Errorf("无效的 IP: %s", field)
+		}
+		bits := 128
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+			bits = 32
+		}
+		nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return nets, nil
+}
+
+// allowedIPNets 按白名单配置字符串缓存解析结果，避免每个请求重复解析（解析失败时缓存 nil）
+// 白名单只在管理员编辑时变化，条目数有限，不做淘汰
+var allowedIPNets sync.Map // string -> []*net.IPNet
+
+// cachedAllowedIPs 获取白名单配置的解析结果，同一配置只解析一次
+func cachedAllowedIPs(allowedIPs string) []*net.IPNet {
+	if v, ok := allowedIPNets.Load(allowedIPs); ok {
+		return v.([]*net.IPNet)
+	}
+	nets, err := ParseAllowedIPs(allowedIPs)
+	if err != nil {
+		nets = nil
+	}
+	allowedIPNets.Store(allowedIPs, nets)
+	return nets
+}
+
+// IsIPAllowed 检查客户端 IP 是否在白名单内（未配置白名单时允许所有 IP）
+func (k *APIKey) IsIPAllowed(clientIP string) bool {
+	if strings.TrimSpace(k.AllowedIPs) == "" {
+		return true
+	}
+
+	nets := cachedAllowedIPs(k.AllowedIPs)
+	if len(nets) == 0 {
+		// 配置无法解析时拒绝访问，避免白名单失效
+		return false
+	}
+
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,25 @@
+/*
+ * 文件作用：API Key 令牌桶状态模型，多实例共享的限速桶
+ * 负责功能：
+ *   - 每个 API Key 一行，记录剩余令牌数和上次补充时间
+ *   - 取令牌时按经过的时间补充，在一条条件更新中完成
+ * 重要程度：⭐⭐⭐ 一般（API Key 限速）
+ * 依赖模块：无
+ */
+package model
+
+import (
+	"time"
+)
+
+// APIKeyTokenBucket API Key 令牌桶状态
+type APIKeyTokenBucket struct {
+	APIKeyID   uint      `gorm:"primaryKey;autoIncrement:false" json:"api_key_id"`
+	Tokens     float64   `gorm:"not null;default:0" json:"tokens"`    // 上次补充后剩余的令牌数
+	RefilledAt time.Time `gorm:"type:datetime(6)" json:"refilled_at"` // 上次补充时间（微秒精度）
+}
+
+// TableName 表名
+func (APIKeyTokenBucket) TableName() string {
+	return "api_key_token_buckets"
+}
@@ -0,0 +1,30 @@
+/*
+ * 文件作用：计费 webhook 事件模型，待推送的消费明细持久化队列
+ * 负责功能：
+ *   - 记录每笔消费的推送内容（JSON）
+ *   - 记录重试次数、下次重试时间和最近一次失败原因
+ *   - 推送成功后删除，表中只保留未送达的事件
+ * 重要程度：⭐⭐⭐ 一般（计费系统对接）
+ * 依赖模块：无
+ */
+package model
+
+import (
+	"time"
+)
+
+// BillingWebhookEvent 待推送的计费事件
+type BillingWebhookEvent struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	EventID     string    `gorm:"size:64;uniqueIndex" json:"event_id"` // 事件唯一 ID，接收方据此去重
+	Payload     string    `gorm:"type:text" json:"payload"`            // 推送的 JSON 内容
+	Attempts    int       `gorm:"default:0" json:"attempts"`           // 已尝试次数
+	NextRetryAt time.Time `gorm:"index" json:"next_retry_at"`          // 下次重试时间（也作为发送中的租约，避免重复发送）
+	LastError   string    `gorm:"size:500" json:"last_error,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// TableName 表名
+func (BillingWebhookEvent) TableName() string {
+	return "billing_webhook_events"
+}
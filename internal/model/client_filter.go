@@ -4,6 +4,7 @@
  *   - 客户端类型定义（Claude Code、Codex、Gemini等）
  *   - 过滤规则配置（UA、Header、Body检查）
  *   - 全局过滤配置
+ *   - 客户端访问规则（UA 正则 + 路径前缀）
  *   - 预定义规则模板
  * 重要程度：⭐⭐⭐⭐ 重要（安全过滤数据结构）
  * 依赖模块：无
@@ -62,6 +63,27 @@ type ClientFilterConfig struct {
 	UpdatedAt            time.Time `json:"updated_at"`
 }
 
+// 访问规则动作
+const (
+	AccessRuleActionAllow = "allow"
+	AccessRuleActionDeny  = "deny"
+)
+
+// ClientAccessRule 客户端访问规则（UA 正则 + 路径前缀 + allow/deny）
+// 按 Priority 降序、ID 升序依次匹配，命中第一条即决定放行或拒绝；都不命中时走原有的客户端过滤
+type ClientAccessRule struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	Name             string    `gorm:"size:100;not null" json:"name"`       // 规则名称
+	UserAgentPattern string    `gorm:"size:500" json:"user_agent_pattern"` // User-Agent 正则，空表示匹配任意 UA
+	PathPrefix       string    `gorm:"size:200" json:"path_prefix"`        // 路径前缀，空表示匹配任意路径
+	Action           string    `gorm:"size:10;not null" json:"action"`     // allow / deny
+	Message          string    `gorm:"size:500" json:"message"`            // 拒绝时返回的错误消息，空则使用默认消息
+	Enabled          bool      `gorm:"default:true" json:"enabled"`        // 是否启用
+	Priority         int       `gorm:"default:0" json:"priority"`          // 优先级（越大越先匹配）
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
 // APIKeyClientFilter API Key 级别的客户端过滤配置
 // 在 APIKey 模型中添加字段：AllowedClients string `gorm:"size:200" json:"allowed_clients"`
 
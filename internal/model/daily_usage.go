@@ -2,9 +2,11 @@
  * 文件作用：每日使用汇总数据模型，存储用户每日使用统计
  * 负责功能：
  *   - 每日Token使用量汇总
- *   - 每日费用统计
+ *   - 每日费用统计（用户计费、账户成本）
  *   - 按模型分组统计
  *   - 增量更新支持
+ *   - 对账差异结构
+ *   - 用量报表导出结构
  * 重要程度：⭐⭐⭐ 一般（统计数据结构）
  * 依赖模块：gorm
  */
@@ -39,6 +41,9 @@ type DailyUsage struct {
 	CacheReadCost   float64 `gorm:"type:decimal(12,6);default:0" json:"cache_read_cost"`   // 缓存读取费用
 	TotalCost       float64 `gorm:"type:decimal(12,6);default:0" json:"total_cost"`        // 总费用
 
+	// 账户成本（原始 token 按模型单价，不含用户费率）
+	AccountCost float64 `gorm:"type:decimal(12,6);default:0" json:"account_cost"`
+
 	// 时间戳
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
@@ -55,6 +60,7 @@ type DailyUsageSummary struct {
 	RequestCount int64   `json:"request_count"`
 	TotalTokens  int64   `json:"total_tokens"`
 	TotalCost    float64 `json:"total_cost"`
+	AccountCost  float64 `json:"account_cost,omitempty"` // 账户成本（仅管理员汇总查询）
 }
 
 // ModelUsageSummary 模型使用汇总
@@ -63,6 +69,7 @@ type ModelUsageSummary struct {
 	RequestCount int64   `json:"request_count"`
 	TotalTokens  int64   `json:"total_tokens"`
 	TotalCost    float64 `json:"total_cost"`
+	AccountCost  float64 `json:"account_cost,omitempty"` // 账户成本（仅管理员汇总查询）
 }
 
 // UserUsageSummary 用户使用汇总
@@ -71,4 +78,79 @@ type UserUsageSummary struct {
 	TotalRequests int64   `json:"total_requests"`
 	TotalTokens   int64   `json:"total_tokens"`
 	TotalCost     float64 `json:"total_cost"`
+	AccountCost   float64 `json:"account_cost,omitempty"` // 账户成本（仅管理员汇总查询）
+}
+
+// DailyUsageDiff 每日汇总对账差异（daily_usage 与 request_logs 明细比对）
+type DailyUsageDiff struct {
+	UserID uint   `json:"user_id"`
+	Model  string `json:"model"`
+
+	// daily_usage 汇总
+	SummaryRequests int64   `json:"summary_requests"`
+	SummaryTokens   int64   `json:"summary_tokens"`
+	SummaryCost     float64 `json:"summary_cost"`
+
+	// request_logs 明细
+	LogRequests int64   `json:"log_requests"`
+	LogTokens   int64   `json:"log_tokens"`
+	LogCost     float64 `json:"log_cost"`
+}
+
+// HasDiff 是否存在差异（费用允许 0.000001 的舍入误差）
+func (d *DailyUsageDiff) HasDiff() bool {
+	costDiff := d.SummaryCost - d.LogCost
+	return d.SummaryRequests != d.LogRequests || d.SummaryTokens != d.LogTokens ||
+		costDiff > 0.000001 || costDiff < -0.000001
+}
+
+// 用量报表分组维度
+const (
+	UsageReportGroupByUser    = "user"    // 按用户
+	UsageReportGroupByModel   = "model"   // 按模型
+	UsageReportGroupByAccount = "account" // 按账户（daily_usage 无账户维度，从 request_logs 聚合）
+)
+
+// UsageReportFilter 用量报表导出条件
+type UsageReportFilter struct {
+	StartDate string // 开始日期 YYYY-MM-DD（含）
+	EndDate   string // 结束日期 YYYY-MM-DD（含）
+	GroupBy   string // user / model / account
+	UserID    uint   // 按用户过滤，0 不过滤
+	AccountID uint   // 按账户过滤，0 不过滤
+}
+
+// UsageReportRow 用量报表行（某分组某日的合计）
+type UsageReportRow struct {
+	GroupID  uint   // 分组为用户/账户时的ID
+	GroupKey string // 分组为模型时的模型名
+	Date     string
+
+	RequestCount             int64
+	InputTokens              int64
+	OutputTokens             int64
+	CacheCreationInputTokens int64
+	CacheReadInputTokens     int64
+	TotalTokens              int64
+
+	InputCost       float64
+	OutputCost      float64
+	CacheCreateCost float64
+	CacheReadCost   float64
+	TotalCost       float64
+}
+
+// Add 累加到小计/总计
+func (r *UsageReportRow) Add(o *UsageReportRow) {
+	r.RequestCount += o.RequestCount
+	r.InputTokens += o.InputTokens
+	r.OutputTokens += o.OutputTokens
+	r.CacheCreationInputTokens += o.CacheCreationInputTokens
+	r.CacheReadInputTokens += o.CacheReadInputTokens
+	r.TotalTokens += o.TotalTokens
+	r.InputCost += o.InputCost
+	r.OutputCost += o.OutputCost
+	r.CacheCreateCost += o.CacheCreateCost
+	r.CacheReadCost += o.CacheReadCost
+	r.TotalCost += o.TotalCost
 }
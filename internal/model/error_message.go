@@ -32,9 +32,10 @@ func (ErrorMessage) TableName() string {
 // 预定义的错误类型常量
 const (
 	// 400 Bad Request
-	ErrorTypeBadRequest     = "bad_request"
-	ErrorTypeInvalidModel   = "invalid_model"   // 无效的模型名称
-	ErrorTypeInvalidRequest = "invalid_request" // 请求格式错误
+	ErrorTypeBadRequest               = "bad_request"
+	ErrorTypeInvalidModel             = "invalid_model"              // 无效的模型名称
+	ErrorTypeInvalidRequest           = "invalid_request"            // 请求格式错误
+	ErrorTypeForcedAccountUnavailable = "forced_account_unavailable" // 强制指定的账户不存在或与请求不匹配
 
 	// 401 Unauthorized
 	ErrorTypeAuthFailed  = "auth_failed"
@@ -42,6 +43,9 @@ const (
 	ErrorTypeKeyExpired  = "key_expired"
 	ErrorTypeKeyInvalid  = "key_invalid"
 
+	// 402 Payment Required
+	ErrorTypeBudgetExceeded = "budget_exceeded" // 套餐预算已用尽
+
 	// 403 Forbidden
 	ErrorTypeForbidden        = "forbidden"
 	ErrorTypeClientNotAllowed = "client_not_allowed"
@@ -52,12 +56,24 @@ const (
 	ErrorTypeDailyLimit       = "daily_limit"       // 日限额超限
 	ErrorTypeMonthlyQuota     = "monthly_quota"     // 月配额超限
 	ErrorTypeIPBlocked        = "ip_blocked"        // IP 被封禁
+	ErrorTypeIPNotAllowed     = "ip_not_allowed"    // IP 不在 API Key 白名单内
+
+	// 409 Conflict
+	ErrorTypeSessionAccountUnavailable = "session_account_unavailable" // 严格粘性会话的绑定账户不可用
+	ErrorTypeIdempotencyConflict       = "idempotency_conflict"        // 相同 Idempotency-Key 的请求正在处理或已完成（流式）
+	ErrorTypeIdempotencyKeyReused      = "idempotency_key_reused"      // 相同 Idempotency-Key 的请求体不同
+
+	// 413 Request Entity Too Large
+	ErrorTypeRequestTooLarge = "request_too_large" // 请求体超过大小限制
 
 	// 429 Too Many Requests
 	ErrorTypeRateLimit            = "rate_limit"
 	ErrorTypeUserConcurrencyLimit = "user_concurrency_limit"
 	ErrorTypeAccountConcurrency   = "account_concurrency_limit"
 
+	// 499 Client Closed Request
+	ErrorTypeClientCanceled = "client_canceled" // 客户端主动断开
+
 	// 500 Internal Server Error
 	ErrorTypeInternalError = "internal_error"
 
@@ -75,6 +91,9 @@ const (
 	ErrorTypeNoAvailableAccount = "no_available_account"
 	ErrorTypeServiceUnavailable = "service_unavailable"
 	ErrorTypeMaintenanceMode    = "maintenance_mode" // 维护模式
+
+	// 504 Gateway Timeout
+	ErrorTypeRequestTimeout = "request_timeout" // 超过客户端指定的请求超时（X-Request-Timeout）
 )
 
 // DefaultErrorMessages 默认错误消息配置
@@ -83,6 +102,7 @@ var DefaultErrorMessages = []ErrorMessage{
 	{Code: 400, ErrorType: ErrorTypeBadRequest, CustomMessage: "请求参数错误", Enabled: true, Description: "通用请求参数错误"},
 	{Code: 400, ErrorType: ErrorTypeInvalidModel, CustomMessage: "无效的模型名称", Enabled: true, Description: "请求的模型名称无效"},
 	{Code: 400, ErrorType: ErrorTypeInvalidRequest, CustomMessage: "请求格式错误", Enabled: true, Description: "请求体格式不正确"},
+	{Code: 400, ErrorType: ErrorTypeForcedAccountUnavailable, CustomMessage: "指定的账户不存在或不支持该请求", Enabled: true, Description: "X-Force-Account-Id 指定的账户不存在或与请求平台不匹配"},
 
 	// 401 Unauthorized
 	{Code: 401, ErrorType: ErrorTypeAuthFailed, CustomMessage: "认证失败", Enabled: true, Description: "API Key 认证失败"},
@@ -90,6 +110,9 @@ var DefaultErrorMessages = []ErrorMessage{
 	{Code: 401, ErrorType: ErrorTypeKeyExpired, CustomMessage: "API Key 已过期", Enabled: true, Description: "API Key 已过期"},
 	{Code: 401, ErrorType: ErrorTypeKeyInvalid, CustomMessage: "无效的 API Key", Enabled: true, Description: "API Key 格式无效或不存在"},
 
+	// 402 Payment Required
+	{Code: 402, ErrorType: ErrorTypeBudgetExceeded, CustomMessage: "套餐额度已用尽，请续费或等待下个周期", Enabled: true, Description: "套餐本周期费用达到额度上限"},
+
 	// 403 Forbidden
 	{Code: 403, ErrorType: ErrorTypeForbidden, CustomMessage: "无权访问", Enabled: true, Description: "通用权限不足"},
 	{Code: 403, ErrorType: ErrorTypeClientNotAllowed, CustomMessage: "客户端未授权", Enabled: true, Description: "客户端类型不在允许列表"},
@@ -100,12 +123,24 @@ var DefaultErrorMessages = []ErrorMessage{
 	{Code: 403, ErrorType: ErrorTypeDailyLimit, CustomMessage: "今日额度已用完，请明天再试", Enabled: true, Description: "日请求限额超限"},
 	{Code: 403, ErrorType: ErrorTypeMonthlyQuota, CustomMessage: "本月额度已用完，请下月再试", Enabled: true, Description: "月配额超限"},
 	{Code: 403, ErrorType: ErrorTypeIPBlocked, CustomMessage: "访问受限", Enabled: true, Description: "IP 地址被封禁"},
+	{Code: 403, ErrorType: ErrorTypeIPNotAllowed, CustomMessage: "当前 IP 不允许使用此 API Key", Enabled: true, Description: "IP 不在 API Key 白名单内"},
+
+	// 409 Conflict
+	{Code: 409, ErrorType: ErrorTypeSessionAccountUnavailable, CustomMessage: "会话绑定的账户暂不可用，请重新开始对话", Enabled: true, Description: "严格粘性模式下会话绑定的账户不可用"},
+	{Code: 409, ErrorType: ErrorTypeIdempotencyConflict, CustomMessage: "重复请求，相同 Idempotency-Key 的请求已在处理", Enabled: true, Description: "流式请求的 Idempotency-Key 在去重窗口内重复"},
+	{Code: 409, ErrorType: ErrorTypeIdempotencyKeyReused, CustomMessage: "Idempotency-Key 已被其他请求使用", Enabled: true, Description: "相同 Idempotency-Key 的请求体与首个请求不同"},
+
+	// 413 Request Entity Too Large
+	{Code: 413, ErrorType: ErrorTypeRequestTooLarge, CustomMessage: "请求体过大，请压缩图片或拆分请求后重试", Enabled: true, Description: "请求体超过系统设置的大小上限"},
 
 	// 429 Too Many Requests
 	{Code: 429, ErrorType: ErrorTypeRateLimit, CustomMessage: "请求过于频繁，请稍后重试", Enabled: true, Description: "通用速率限制"},
 	{Code: 429, ErrorType: ErrorTypeUserConcurrencyLimit, CustomMessage: "并发请求过多，请稍后重试", Enabled: true, Description: "用户并发数超限"},
 	{Code: 429, ErrorType: ErrorTypeAccountConcurrency, CustomMessage: "系统繁忙，请稍后重试", Enabled: true, Description: "账户并发数超限"},
 
+	// 499 Client Closed Request
+	{Code: 499, ErrorType: ErrorTypeClientCanceled, CustomMessage: "请求已取消", Enabled: true, Description: "客户端在响应完成前主动断开连接"},
+
 	// 500 Internal Server Error
 	{Code: 500, ErrorType: ErrorTypeInternalError, CustomMessage: "服务器内部错误", Enabled: true, Description: "通用服务器错误"},
 
@@ -123,14 +158,18 @@ var DefaultErrorMessages = []ErrorMessage{
 	{Code: 503, ErrorType: ErrorTypeNoAvailableAccount, CustomMessage: "服务暂时不可用，请稍后重试", Enabled: true, Description: "没有可用的上游账户"},
 	{Code: 503, ErrorType: ErrorTypeMaintenanceMode, CustomMessage: "系统维护中，请稍后再试", Enabled: true, Description: "系统处于维护模式"},
 	{Code: 503, ErrorType: ErrorTypeServiceUnavailable, CustomMessage: "服务暂时不可用", Enabled: true, Description: "通用服务不可用"},
+
+	// 504 Gateway Timeout
+	{Code: 504, ErrorType: ErrorTypeRequestTimeout, CustomMessage: "请求超时", Enabled: true, Description: "请求未在 X-Request-Timeout 指定的时间内完成"},
 }
 
 // OriginalErrorMessages 原始英文错误消息示例（上游API典型返回）
 var OriginalErrorMessages = map[string]string{
 	// 400 Bad Request
-	ErrorTypeBadRequest:     "Bad request: invalid parameters",
-	ErrorTypeInvalidModel:   "The model 'xxx' does not exist",
-	ErrorTypeInvalidRequest: "Invalid request body",
+	ErrorTypeBadRequest:               "Bad request: invalid parameters",
+	ErrorTypeInvalidModel:             "The model 'xxx' does not exist",
+	ErrorTypeInvalidRequest:           "Invalid request body",
+	ErrorTypeForcedAccountUnavailable: "Forced account unavailable",
 
 	// 401 Unauthorized
 	ErrorTypeAuthFailed:  "Invalid API key provided",
@@ -138,6 +177,9 @@ var OriginalErrorMessages = map[string]string{
 	ErrorTypeKeyExpired:  "API key has expired",
 	ErrorTypeKeyInvalid:  "Incorrect API key provided",
 
+	// 402 Payment Required
+	ErrorTypeBudgetExceeded: "Budget exceeded for current billing period",
+
 	// 403 Forbidden
 	ErrorTypeForbidden:        "Permission denied",
 	ErrorTypeClientNotAllowed: "Client not allowed",
@@ -149,11 +191,22 @@ var OriginalErrorMessages = map[string]string{
 	ErrorTypeMonthlyQuota:     "Monthly quota exceeded",
 	ErrorTypeIPBlocked:        "IP address blocked",
 
+	// 409 Conflict
+	ErrorTypeSessionAccountUnavailable: "Session bound account unavailable",
+	ErrorTypeIdempotencyConflict:       "A request with the same Idempotency-Key is already in progress",
+	ErrorTypeIdempotencyKeyReused:      "Idempotency-Key was already used with a different request body",
+
+	// 413 Request Entity Too Large
+	ErrorTypeRequestTooLarge: "http: request body too large",
+
 	// 429 Too Many Requests
 	ErrorTypeRateLimit:            "Rate limit exceeded. Please retry after X seconds",
 	ErrorTypeUserConcurrencyLimit: "Too many concurrent requests",
 	ErrorTypeAccountConcurrency:   "Account concurrency limit reached",
 
+	// 499 Client Closed Request
+	ErrorTypeClientCanceled: "Client closed request",
+
 	// 500 Internal Server Error
 	ErrorTypeInternalError: "Internal server error",
 
@@ -171,6 +224,9 @@ var OriginalErrorMessages = map[string]string{
 	ErrorTypeNoAvailableAccount: "No available upstream account",
 	ErrorTypeMaintenanceMode:    "Service under maintenance",
 	ErrorTypeServiceUnavailable: "Service temporarily unavailable",
+
+	// 504 Gateway Timeout
+	ErrorTypeRequestTimeout: "Request exceeded client timeout",
 }
 
 // GetOriginalMessage 根据错误类型获取原始英文错误消息
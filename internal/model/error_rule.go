@@ -4,6 +4,7 @@
  *   - 错误匹配规则定义
  *   - HTTP状态码/关键词匹配
  *   - 账户状态转换配置
+ *   - 重试动作配置（同账户重试N次/换账户/标记账户/立即失败）
  *   - 默认错误规则模板
  * 重要程度：⭐⭐⭐ 一般（错误规则数据结构）
  * 依赖模块：无
@@ -18,6 +19,8 @@ type ErrorRule struct {
 	HTTPStatusCode int       `json:"http_status_code" gorm:"index;comment:HTTP状态码，0表示任意"`
 	Keyword        string    `json:"keyword" gorm:"size:255;comment:错误关键词，空表示任意"`
 	TargetStatus   string    `json:"target_status" gorm:"size:50;not null;comment:目标账户状态"`
+	RetryAction    string    `json:"retry_action" gorm:"size:20;default:'';comment:重试动作，空表示沿用内置判断"`
+	RetryCount     int       `json:"retry_count" gorm:"default:0;comment:同账户重试次数（retry动作）"`
 	Priority       int       `json:"priority" gorm:"default:0;comment:优先级，越大越先匹配"`
 	Enabled        bool      `json:"enabled" gorm:"default:true"`
 	Description    string    `json:"description" gorm:"size:500;comment:规则描述"`
@@ -33,6 +36,23 @@ const (
 	TargetStatusValid       = "valid"        // 正常（忽略错误）
 )
 
+// 重试动作常量（为空的规则只决定账户状态，是否重试沿用内置判断）
+const (
+	RetryActionRetry  = "retry"  // 同账户重试 RetryCount 次，仍失败再换账户
+	RetryActionSwitch = "switch" // 不在同账户重试，立即换账户
+	RetryActionMark   = "mark"   // 立即按目标状态标记账户，再换账户
+	RetryActionFail   = "fail"   // 不重试，立即返回错误
+)
+
+// IsValidRetryAction 判断重试动作是否合法（空表示未配置）
+func IsValidRetryAction(action string) bool {
+	switch action {
+	case "", RetryActionRetry, RetryActionSwitch, RetryActionMark, RetryActionFail:
+		return true
+	}
+	return false
+}
+
 // DefaultErrorRules 默认错误规则
 var DefaultErrorRules = []ErrorRule{
 	// HTTP 状态码规则（高优先级）
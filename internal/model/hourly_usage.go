@@ -0,0 +1,51 @@
+/*
+ * 文件作用：小时用量汇总数据模型，记录全站每小时的请求数、token 和费用
+ * 负责功能：
+ *   - 每天每小时一条记录，增量更新
+ *   - 只保留最近 7 天，供后台查看当日小时级趋势
+ * 重要程度：⭐⭐ 辅助（统计数据结构）
+ * 依赖模块：无
+ */
+package model
+
+import (
+	"time"
+)
+
+// HourlyUsageRetentionDays 小时用量保留天数（含当天），更早的记录定期清理
+const HourlyUsageRetentionDays = 7
+
+// HourlyUsage 小时用量汇总（全站，不分用户和模型）
+type HourlyUsage struct {
+	ID   uint   `gorm:"primarykey" json:"id"`
+	Date string `gorm:"size:10;uniqueIndex:idx_date_hour,priority:1" json:"date"` // 日期 YYYY-MM-DD
+	Hour int    `gorm:"uniqueIndex:idx_date_hour,priority:2" json:"hour"`         // 小时 0-23
+
+	RequestCount             int64   `gorm:"default:0" json:"request_count"`
+	InputTokens              int64   `gorm:"default:0" json:"input_tokens"`
+	OutputTokens             int64   `gorm:"default:0" json:"output_tokens"`
+	CacheCreationInputTokens int64   `gorm:"default:0" json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64   `gorm:"default:0" json:"cache_read_input_tokens"`
+	TotalTokens              int64   `gorm:"default:0" json:"total_tokens"`
+	TotalCost                float64 `gorm:"type:decimal(12,6);default:0" json:"total_cost"`   // 用户计费（含倍率）
+	AccountCost              float64 `gorm:"type:decimal(12,6);default:0" json:"account_cost"` // 账户成本
+
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 表名
+func (HourlyUsage) TableName() string {
+	return "hourly_usage"
+}
+
+// Add 累加另一条小时用量
+func (h *HourlyUsage) Add(other *HourlyUsage) {
+	h.RequestCount += other.RequestCount
+	h.InputTokens += other.InputTokens
+	h.OutputTokens += other.OutputTokens
+	h.CacheCreationInputTokens += other.CacheCreationInputTokens
+	h.CacheReadInputTokens += other.CacheReadInputTokens
+	h.TotalTokens += other.TotalTokens
+	h.TotalCost += other.TotalCost
+	h.AccountCost += other.AccountCost
+}
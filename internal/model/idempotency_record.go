@@ -0,0 +1,36 @@
+/*
+ * 文件作用：请求幂等记录模型，多实例共享的 Idempotency-Key 占用标记
+ * 负责功能：
+ *   - 按 API Key + 路径 + Idempotency-Key 的哈希唯一占用，首个请求插入成功才执行
+ *   - 进行中的记录带租约过期时间，实例异常退出后可被重新占用
+ *   - 成功完成后保留到去重窗口结束，失败时删除
+ * 重要程度：⭐⭐⭐ 一般（防重复调用与重复计费）
+ * 依赖模块：无
+ */
+package model
+
+import (
+	"time"
+)
+
+// 幂等记录状态
+const (
+	IdempotencyStatusInFlight  = "in_flight" // 首个请求执行中
+	IdempotencyStatusCompleted = "completed" // 首个请求已成功
+)
+
+// IdempotencyRecord Idempotency-Key 占用记录
+type IdempotencyRecord struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	KeyHash   string    `gorm:"size:64;uniqueIndex" json:"key_hash"` // API Key + 路径 + Idempotency-Key 的哈希
+	APIKeyID  uint      `gorm:"index" json:"api_key_id"`
+	Path      string    `gorm:"size:200" json:"path"`
+	Status    string    `gorm:"size:20" json:"status"`  // in_flight / completed
+	ExpireAt  time.Time `gorm:"index" json:"expire_at"` // 进行中为租约到期时间，完成后为去重窗口结束时间
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName 表名
+func (IdempotencyRecord) TableName() string {
+	return "idempotency_records"
+}
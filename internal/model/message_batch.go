@@ -0,0 +1,34 @@
+/*
+ * 文件作用：Claude 消息批次模型，记录批次与上游账户的绑定关系
+ * 负责功能：
+ *   - 批次创建时绑定账户（后续查询、拉结果必须落到同一账户）
+ *   - 记录批次归属用户和 API Key
+ *   - 标记批次用量是否已计费（防止重复拉结果重复扣费）
+ * 重要程度：⭐⭐⭐ 一般（离线批量任务）
+ * 依赖模块：无
+ */
+package model
+
+import (
+	"time"
+)
+
+// MessageBatch Claude 消息批次
+type MessageBatch struct {
+	ID               uint       `gorm:"primarykey" json:"id"`
+	BatchID          string     `gorm:"size:100;uniqueIndex" json:"batch_id"` // 上游批次 ID（msgbatch_xxx）
+	AccountID        uint       `gorm:"index" json:"account_id"`              // 创建批次的上游账户
+	UserID           uint       `gorm:"index" json:"user_id"`                 // 归属用户
+	APIKeyID         uint       `gorm:"index" json:"api_key_id"`              // 创建批次的 API Key
+	RequestCount     int        `json:"request_count"`                        // 批次内请求数
+	ProcessingStatus string     `gorm:"size:20" json:"processing_status"`     // 上游处理状态：in_progress / canceling / ended
+	UsageRecorded    bool       `gorm:"default:false" json:"usage_recorded"`  // 用量是否已计费
+	UsageRecordedAt  *time.Time `json:"usage_recorded_at,omitempty"`          // 计费时间
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// TableName 表名
+func (MessageBatch) TableName() string {
+	return "message_batches"
+}
@@ -0,0 +1,72 @@
+/*
+ * 文件作用：模型回退链解析，主模型无可用账户时按链路降级
+ * 负责功能：
+ *   - 解析回退链配置（每行一条，如 claude-3-opus->claude-3-5-sonnet->claude-3-5-haiku）
+ *   - 按原始模型展开回退顺序（防止循环）
+ * 重要程度：⭐⭐⭐ 一般（请求可用性）
+ * 依赖模块：无
+ */
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MaxModelFallbackDepth 单次请求最多回退的模型数量
+const MaxModelFallbackDepth = 5
+
+// ModelFallbackHeader 响应头，告知客户端本次请求发生了模型回退（如 opus->sonnet）
+const ModelFallbackHeader = "X-Model-Fallback"
+
+// CrossPlatformFallbackHeader 响应头，告知客户端本次请求发生了跨平台转换（如 claude->openai）
+const CrossPlatformFallbackHeader = "X-Cross-Platform-Fallback"
+
+// ParseModelFallbackChains 解析回退链配置，返回 模型 -> 下一个回退模型
+// 每行（或分号分隔）一条链路，链路内用 -> 连接
+func ParseModelFallbackChains(raw string) (map[string]string, error) {
+	next := make(map[string]string)
+	lines := strings.FieldsFunc(raw, func(r rune) bool {
+		return r == '\n' || r == '\r' || r == ';'
+	})
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		models := strings.Split(line, "->")
+		if len(models) < 2 {
+			return nil, fmt.Errorf("无效的模型回退链: %s", line)
+		}
+		for i := range models {
+			models[i] = strings.TrimSpace(models[i])
+			if models[i] == "" {
+				return nil, fmt.Errorf("无效的模型回退链: %s", line)
+			}
+		}
+		for i := 0; i < len(models)-1; i++ {
+			if models[i] == models[i+1] {
+				return nil, fmt.Errorf("模型回退链不能回退到自身: %s", line)
+			}
+			next[models[i]] = models[i+1]
+		}
+	}
+	return next, nil
+}
+
+// ResolveModelFallbacks 按回退链展开指定模型的回退顺序（不含模型本身）
+// 遇到已出现过的模型即停止，避免 a->b->a 之类的循环
+func ResolveModelFallbacks(next map[string]string, modelName string) []string {
+	visited := map[string]bool{modelName: true}
+	var fallbacks []string
+	for current := modelName; len(fallbacks) < MaxModelFallbackDepth; {
+		candidate, ok := next[current]
+		if !ok || visited[candidate] {
+			break
+		}
+		visited[candidate] = true
+		fallbacks = append(fallbacks, candidate)
+		current = candidate
+	}
+	return fallbacks
+}
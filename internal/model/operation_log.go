@@ -53,6 +53,7 @@ const (
 	ModulePackage  = "package"  // 套餐管理
 	ModuleGroup    = "group"    // 分组管理
 	ModuleSystem   = "system"   // 系统
+	ModuleUsage    = "usage"    // 用量统计
 )
 
 // 操作类型常量
@@ -0,0 +1,30 @@
+/*
+ * 文件作用：组织（租户）数据模型，定义多团队隔离的归属单位
+ * 负责功能：
+ *   - 组织基础信息（名称、描述）
+ *   - 用户、账户、API Key、套餐通过 OrgID 归属组织
+ * 重要程度：⭐⭐⭐ 一般（多租户隔离）
+ * 依赖模块：gorm
+ */
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Organization 组织（租户）
+// 用户、账户、API Key、套餐的 OrgID 为 0 表示不属于任何组织（由超级管理员直接管理）
+type Organization struct {
+	ID          uint           `gorm:"primarykey" json:"id"`
+	Name        string         `gorm:"size:100;not null;uniqueIndex" json:"name"`
+	Description string         `gorm:"size:500" json:"description,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (o *Organization) TableName() string {
+	return "organizations"
+}
@@ -5,6 +5,9 @@
  *   - 用户套餐分配
  *   - 额度限制配置
  *   - 模型访问权限
+ *   - 调度优先级（VIP 套餐排队优先）
+ *   - 可调度账户分组（不同档位套餐使用不同账户池）
+ *   - 所属组织
  * 重要程度：⭐⭐⭐ 一般（套餐数据结构）
  * 依赖模块：gorm
  */
@@ -12,6 +15,8 @@ package model
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -24,6 +29,7 @@ type Package struct {
 	Type        string         `gorm:"size:20;not null;index" json:"type"`                  // subscription(订阅包月) / quota(额度)
 	Price       float64        `gorm:"type:decimal(10,2);default:0" json:"price"`           // 价格（美元）
 	Duration    int            `gorm:"default:30" json:"duration"`                          // 有效期天数
+	OrgID       uint           `gorm:"default:0;index" json:"org_id"`                       // 所属组织（0=不属于任何组织）
 
 	// 订阅类型的额度限制（美元）
 	DailyQuota  float64        `gorm:"type:decimal(10,4);default:0" json:"daily_quota"`     // 每日额度（0=不限）
@@ -36,6 +42,12 @@ type Package struct {
 	// 模型限制
 	AllowedModels string       `gorm:"type:text" json:"allowed_models"`                     // 允许的模型（逗号分隔，空=全部）
 
+	// 调度优先级：账户并发全满排队时数值大的先拿到槽位（0=普通）
+	Priority    int            `gorm:"default:0" json:"priority"`
+
+	// 可调度的账户分组：使用该套餐的请求只调度这些分组内的账户（逗号分隔的分组 ID，空=全部账户）
+	AccountGroups string       `gorm:"size:500" json:"account_groups"`
+
 	Description string         `gorm:"size:500" json:"description"`                         // 套餐描述
 	Status      string         `gorm:"size:20;default:active" json:"status"`                // active/disabled
 	CreatedAt   time.Time      `json:"created_at"`
@@ -47,6 +59,18 @@ func (p *Package) TableName() string {
 	return "packages"
 }
 
+// AccountGroupIDs 解析可调度的账户分组 ID，未配置返回 nil（不限制）
+func (p *Package) AccountGroupIDs() []uint {
+	var ids []uint
+	for _, item := range strings.Split(p.AccountGroups, ",") {
+		id, err := strconv.ParseUint(strings.TrimSpace(item), 10, 64)
+		if err == nil && id > 0 {
+			ids = append(ids, uint(id))
+		}
+	}
+	return ids
+}
+
 // UserPackage 用户购买的套餐
 type UserPackage struct {
 	ID           uint           `gorm:"primarykey" json:"id"`
@@ -78,6 +102,10 @@ type UserPackage struct {
 	QuotaTotal   float64        `gorm:"type:decimal(10,4);default:0" json:"quota_total"`  // 总额度（美元）
 	QuotaUsed    float64        `gorm:"type:decimal(10,4);default:0" json:"quota_used"`   // 已用额度（美元）
 
+	// 预算预扣：进行中请求尚未扣费的预扣总额，多实例共享，只通过 UserPackageRepository 原子增减
+	BudgetReserved      float64    `gorm:"type:decimal(10,4);default:0" json:"budget_reserved"`
+	BudgetReservedSince *time.Time `json:"budget_reserved_since,omitempty"` // 预扣从 0 变为正数的时间，全部释放后清空；长时间未清零视为残留清零
+
 	// 模型限制
 	AllowedModels string        `gorm:"type:text" json:"allowed_models"`                  // 允许的模型（逗号分隔）
 
@@ -172,6 +200,35 @@ func (up *UserPackage) CanUse(amount float64) bool {
 	return false
 }
 
+// BudgetUsage 计算预算使用情况
+// 订阅类型取日/周/月中最紧张的一项，额度类型取总额度
+// ratio 为已用比例，remaining 为剩余金额，limited=false 表示不限额
+func (up *UserPackage) BudgetUsage() (ratio, remaining float64, limited bool) {
+	check := func(used, quota float64) {
+		if quota <= 0 {
+			return
+		}
+		r := used / quota
+		left := quota - used
+		if !limited || r > ratio {
+			ratio = r
+		}
+		if !limited || left < remaining {
+			remaining = left
+		}
+		limited = true
+	}
+
+	if up.Type == "subscription" {
+		check(up.DailyUsed, up.DailyQuota)
+		check(up.WeeklyUsed, up.WeeklyQuota)
+		check(up.MonthlyUsed, up.MonthlyQuota)
+	} else if up.Type == "quota" {
+		check(up.QuotaUsed, up.QuotaTotal)
+	}
+	return ratio, remaining, limited
+}
+
 // RecordUsage 记录使用量
 func (up *UserPackage) RecordUsage(amount float64) {
 	if up.Type == "subscription" {
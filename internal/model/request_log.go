@@ -3,9 +3,12 @@
  * 负责功能：
  *   - 请求基础信息（账户、用户、平台、模型）
  *   - Token使用统计
- *   - 费用记录
+ *   - 费用记录（用户计费与账户成本分开记录）
  *   - 请求/响应详情（可选）
  *   - 错误信息记录
+ *   - 流式截断标记
+ *   - 灰度染色分组
+ *   - 响应缓存命中标记
  * 重要程度：⭐⭐⭐ 一般（日志数据结构）
  * 依赖模块：gorm
  */
@@ -17,6 +20,12 @@ import (
 	"gorm.io/gorm"
 )
 
+// 灰度染色分组
+const (
+	TrafficGroupCanary  = "canary"  // 灰度组：只调度带灰度标签的账户
+	TrafficGroupControl = "control" // 对照组：不调度带灰度标签的账户
+)
+
 // RequestLog 请求日志
 type RequestLog struct {
 	ID        uint           `gorm:"primarykey" json:"id"`
@@ -26,12 +35,19 @@ type RequestLog struct {
 	Model     string         `gorm:"size:100;index" json:"model"`     // 模型名
 	Endpoint  string         `gorm:"size:100" json:"endpoint"`        // 请求端点
 
+	// 就近调度
+	Region string `gorm:"size:50;index" json:"region,omitempty"` // 命中账户的 region（便于统计各 region 延迟）
+
+	// 灰度染色：canary 灰度组 / control 对照组，未启用灰度时为空
+	TrafficGroup string `gorm:"size:20;index" json:"traffic_group,omitempty"`
+
 	// 请求信息
 	Method     string `gorm:"size:10" json:"method"`                    // HTTP方法
 	Path       string `gorm:"size:200" json:"path"`                     // 请求路径
 	RequestIP  string `gorm:"size:50" json:"request_ip"`                // 请求IP
 	UserAgent  string `gorm:"size:500" json:"user_agent,omitempty"`     // User-Agent
 	SessionID  string `gorm:"size:100;index" json:"session_id,omitempty"` // 会话ID
+	RequestID  string `gorm:"size:64;index" json:"request_id,omitempty"`  // 请求ID（X-Request-ID），关联同一请求的全部重试日志
 
 	// 完整请求/响应记录
 	RequestHeaders  string `gorm:"type:text" json:"request_headers,omitempty"`   // 请求头 JSON
@@ -44,14 +60,24 @@ type RequestLog struct {
 	OutputTokens             int `gorm:"default:0" json:"output_tokens"`               // 输出Token
 	CacheCreationInputTokens int `gorm:"default:0" json:"cache_creation_input_tokens"` // 缓存创建Token
 	CacheReadInputTokens     int `gorm:"default:0" json:"cache_read_input_tokens"`     // 缓存读取Token
+	ThinkingTokens           int `gorm:"default:0" json:"thinking_tokens"`             // 思考/推理Token（包含在输出Token中）
 	TotalTokens              int `gorm:"default:0" json:"total_tokens"`                // 总Token数
 
+	// 上游未返回 usage 时本地估算（计费可按配置不采信）
+	TokensEstimated bool `gorm:"default:false" json:"tokens_estimated"` // token 数为本地估算
+
+	// 响应缓存命中（未请求上游，按缓存命中系数计费，无账户成本）
+	ResponseCacheHit bool `gorm:"default:false" json:"response_cache_hit"`
+
 	// 费用信息（已计算倍率后的实际费用，用户可见）
 	InputCost       float64 `gorm:"type:decimal(10,6);default:0" json:"input_cost"`        // 输入费用
 	OutputCost      float64 `gorm:"type:decimal(10,6);default:0" json:"output_cost"`       // 输出费用
 	CacheCreateCost float64 `gorm:"type:decimal(10,6);default:0" json:"cache_create_cost"` // 缓存创建费用
 	CacheReadCost   float64 `gorm:"type:decimal(10,6);default:0" json:"cache_read_cost"`   // 缓存读取费用
+	ThinkingCost    float64 `gorm:"type:decimal(10,6);default:0" json:"thinking_cost"`     // 思考费用
 	TotalCost       float64 `gorm:"type:decimal(10,6);default:0" json:"total_cost"`        // 总费用
+	LongContext     bool    `gorm:"default:false" json:"long_context"`                     // 是否按长上下文档位计费
+	AccountCost     float64 `gorm:"type:decimal(10,6);default:0" json:"account_cost"`      // 账户成本（原始 token 按模型单价，付给上游的费用）
 
 	// API Key 信息（用于统计）
 	APIKeyID *uint `gorm:"index" json:"api_key_id,omitempty"` // API Key ID
@@ -59,6 +85,7 @@ type RequestLog struct {
 	// 响应信息
 	StatusCode int    `gorm:"default:200" json:"status_code"`      // HTTP状态码
 	Success    bool   `gorm:"default:true" json:"success"`         // 是否成功
+	Truncated  bool   `gorm:"default:false" json:"truncated"`      // 流式响应疑似截断（未收到正常终止事件）
 	Error      string `gorm:"size:1000" json:"error,omitempty"`    // 错误信息
 	Duration   int64  `gorm:"default:0" json:"duration"`           // 请求耗时(毫秒)
 
@@ -88,8 +115,20 @@ type RequestLogSummary struct {
 	TotalCacheCreationTokens int64   `json:"total_cache_creation_tokens"`
 	TotalCacheReadTokens     int64   `json:"total_cache_read_tokens"`
 	TotalTokens              int64   `json:"total_tokens"`
-	TotalCost                float64 `json:"total_cost"`    // 总费用
-	AvgDuration              float64 `json:"avg_duration"`  // 平均耗时(毫秒)
+	TotalCost                float64 `json:"total_cost"`         // 用户计费
+	TotalAccountCost         float64 `json:"total_account_cost"` // 账户成本
+	GrossProfit              float64 `json:"gross_profit"`       // 毛利（用户计费 - 账户成本）
+	AvgDuration              float64 `json:"avg_duration"`       // 平均耗时(毫秒)
+}
+
+// RegionLatencyStats 各 region 请求延迟统计
+type RegionLatencyStats struct {
+	Region       string  `json:"region"`
+	RequestCount int64   `json:"request_count"`
+	SuccessCount int64   `json:"success_count"`
+	AvgDuration  float64 `json:"avg_duration"`
+	MinDuration  int64   `json:"min_duration"`
+	MaxDuration  int64   `json:"max_duration"`
 }
 
 // AccountLoadStats 账户负载统计
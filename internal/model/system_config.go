@@ -38,6 +38,97 @@ const (
 	// 会话相关
 	ConfigSessionTTL = "session_ttl" // 会话粘性 TTL（分钟）
 
+	// 流式响应相关
+	ConfigStreamKeepAliveInterval = "stream_keepalive_interval" // 流式心跳间隔（秒），0 表示关闭
+
+	// 请求体大小限制
+	ConfigMaxRequestBodySize       = "max_request_body_size"        // 默认请求体上限（MB），0 表示不限制
+	ConfigMaxRequestBodySizeClaude = "max_request_body_size_claude" // Claude 端点请求体上限（MB，多模态图片较大）
+
+	// 请求超时
+	ConfigMaxRequestTimeout = "max_request_timeout" // 客户端 X-Request-Timeout 头允许的最大值（秒），0 表示忽略该头
+
+	// 请求幂等
+	ConfigIdempotencyWindow = "idempotency_window" // 相同 Idempotency-Key 的去重窗口（秒），0 表示忽略该头
+
+	// 错误透传
+	ConfigPassthroughUpstreamError = "passthrough_upstream_error" // 请求失败时原样返回上游错误体和状态码
+
+	// 输出 token 上限
+	ConfigMaxOutputTokensLimit  = "max_output_tokens_limit"  // 单请求 max_tokens 上限，0 表示不限制（API Key 可单独设置）
+	ConfigMaxOutputTokensAction = "max_output_tokens_action" // 超过上限时的处理方式: clamp / reject
+
+	// 图片 URL 内联（Claude 账户开启 InlineImageURLs 时生效）
+	ConfigImageInlineMaxSize   = "image_inline_max_size"   // 单张图片下载大小上限（MB）
+	ConfigImageInlineTimeout   = "image_inline_timeout"    // 单张图片下载超时（秒）
+	ConfigImageInlineOnFailure = "image_inline_on_failure" // 下载失败时的处理方式: error / skip
+	ConfigImageInlineMaxCount  = "image_inline_max_count"  // 单个请求最多下载的图片数
+
+	// Claude system prompt 注入（账户 / API Key 配置的前缀、后缀）
+	ConfigSystemPromptInjectEnabled = "system_prompt_inject_enabled" // 是否注入
+	ConfigSystemPromptInjectScope   = "system_prompt_inject_scope"   // 作用范围: all / account / api_key
+
+	// 降级响应（API Key 开启后账户全部不可用时返回的预设文本）
+	ConfigDegradeResponseText = "degrade_response_text"
+
+	// OpenAI Responses 非 Codex CLI 请求适配（账户可单独覆盖）
+	ConfigResponsesCompatStripFields  = "responses_compat_strip_fields" // 删除的字段（逗号分隔），none 表示不删除
+	ConfigResponsesCompatInstructions = "responses_compat_instructions" // instructions 处理方式: override / fill / keep
+
+	// 请求内容审查（敏感词 / PII）
+	ConfigContentFilterEnabled    = "content_filter_enabled"     // 是否启用内容审查
+	ConfigContentFilterAction     = "content_filter_action"      // 命中后的处理方式: reject / redact
+	ConfigContentFilterPatterns   = "content_filter_patterns"    // 敏感词正则词表（每行一条）
+	ConfigContentFilterPII        = "content_filter_pii"         // 是否检测 PII（邮箱、手机号、身份证号、银行卡号）
+	ConfigContentFilterMessage    = "content_filter_message"     // 拒绝时返回给客户端的消息
+	ConfigContentFilterAPIURL     = "content_filter_api_url"     // 外部审查 API 地址，为空不调用
+	ConfigContentFilterAPITimeout = "content_filter_api_timeout" // 外部审查 API 超时（毫秒）
+
+	// 套餐预算
+	ConfigBudgetWarningPercent = "budget_warning_percent" // 套餐用量达到该百分比时返回 X-Budget-Warning 响应头
+	ConfigBudgetReserveAmount  = "budget_reserve_amount"  // 每个进行中请求预扣的金额（美元），防止并发超卖
+
+	// 请求重试（修改后新请求立即生效）
+	ConfigRetryMaxRetries        = "retry_max_retries"          // 最大重试次数
+	ConfigRetryDelay             = "retry_delay"                // 首次重试延迟（毫秒）
+	ConfigRetryBackoff           = "retry_backoff"              // 退避系数
+	ConfigRetryRetryableErrors   = "retry_retryable_errors"     // 可重试错误关键词（逗号分隔）
+	ConfigRetrySwitchOnRateLimit = "retry_switch_on_rate_limit" // 限流时是否切换账户
+	ConfigRetryQueueMaxWait      = "retry_queue_max_wait"       // 账户并发全满时排队等待槽位的最长时间（秒）
+	ConfigRetrySameAccount       = "retry_same_account"         // 瞬时错误先在同一账户上重试的次数
+
+	// 批处理计费
+	ConfigBatchPriceDiscount = "batch_price_discount" // Message Batches 计费折扣系数（官方为半价）
+
+	// 截断计费
+	ConfigStreamTruncatedPriceRatio = "stream_truncated_price_ratio" // 疑似截断的流式请求计费系数（1 照常，0.5 半价，0 不计费）
+
+	// token 估算
+	ConfigTokenEstimateEnabled = "token_estimate_enabled" // 上游流式响应未返回 usage 时本地估算 token
+	ConfigTokenEstimateBilling = "token_estimate_billing" // 是否按估算的 token 计费（关闭时只记录不计费）
+
+	// 响应缓存（API Key 开启后生效）
+	ConfigResponseCacheTTL           = "response_cache_ttl"             // 默认缓存时间（秒），API Key 未设置时使用
+	ConfigResponseCacheHitPriceRatio = "response_cache_hit_price_ratio" // 缓存命中的计费系数（相对正常费用，0 不计费）
+	ConfigResponseCacheMaxEntries    = "response_cache_max_entries"     // 缓存条目数上限
+
+	// 模型回退
+	ConfigModelFallbackChains = "model_fallback_chains" // 全局模型回退链（每行一条，如 opus->sonnet->haiku）
+
+	// 跨平台兜底
+	ConfigCrossPlatformFallbackModel = "cross_platform_fallback_model" // Claude 请求兜底到 OpenAI 账户时使用的模型
+
+	// 灰度（修改后新请求立即生效，API Key 可单独配置）
+	ConfigCanaryEnabled = "canary_enabled" // 是否启用灰度
+	ConfigCanaryTag     = "canary_tag"     // 灰度账户池标签
+	ConfigCanaryPercent = "canary_percent" // 灰度流量比例（%）
+	ConfigCanaryBucket  = "canary_bucket"  // 分流方式: session / random
+
+	// 影子流量（复制真实请求给影子账户做对比，不影响主请求、不计费）
+	ConfigShadowTrafficEnabled     = "shadow_traffic_enabled"       // 是否启用影子流量
+	ConfigShadowTrafficPercent     = "shadow_traffic_percent"       // 复制比例（%）
+	ConfigShadowTrafficMaxInFlight = "shadow_traffic_max_in_flight" // 同时进行的影子请求上限，超过时丢弃
+
 	// 同步相关
 	ConfigSyncEnabled  = "sync_enabled"  // 是否启用同步
 	ConfigSyncInterval = "sync_interval" // 同步间隔（分钟）
@@ -71,6 +162,8 @@ const (
 	ConfigRateLimitedProbeInitInterval = "rate_limited_probe_init_interval" // 初始探测间隔（分钟）
 	ConfigRateLimitedProbeMaxInterval  = "rate_limited_probe_max_interval"  // 最大探测间隔（分钟）
 	ConfigRateLimitedProbeBackoff      = "rate_limited_probe_backoff"       // 间隔递增因子
+	ConfigRateLimitedDowngradeWindow   = "rate_limited_downgrade_window"    // 健康检查 429 观察窗口（分钟）
+	ConfigRateLimitedDowngradeRatio    = "rate_limited_downgrade_ratio"     // 窗口内 429 比例达到该值时标记为限流，0 表示关闭
 
 	// 健康检测策略 - 疑似封号
 	ConfigSuspendedProbeInterval   = "suspended_probe_interval"    // 探测间隔（分钟）
@@ -83,6 +176,37 @@ const (
 	// 健康检测策略 - Token 刷新
 	ConfigTokenRefreshCooldown   = "token_refresh_cooldown"    // 刷新失败冷却时间（分钟）
 	ConfigTokenRefreshMaxRetries = "token_refresh_max_retries" // 最大重试次数
+	ConfigTokenPreRefreshThreshold = "token_pre_refresh_threshold" // 距过期多久主动刷新（分钟），0 表示关闭
+
+	// 健康检测策略 - 凭证到期
+	ConfigCredentialExpiryWarnDays = "credential_expiry_warn_days" // 距过期多少天内视为即将过期
+	ConfigCredentialExpiryAlert    = "credential_expiry_alert"     // 凭证即将过期或失效时推送告警
+	ConfigSessionKeyCheckInterval  = "session_key_check_interval"  // SessionKey 轻量校验间隔（小时），0 表示关闭
+
+	// 健康检测策略 - 深度探测
+	ConfigDeepProbeEnabled  = "deep_probe_enabled"  // 启用真实推理探测
+	ConfigDeepProbeInterval = "deep_probe_interval" // 每个账号的最小探测间隔（分钟）
+
+	// 健康检测策略 - 并发与超时
+	ConfigHealthCheckConcurrency       = "health_check_concurrency"        // 检查并发数，0 表示按账号数自适应
+	ConfigHealthCheckAccountsPerWorker = "health_check_accounts_per_worker" // 自适应时每个并发负责的账号数
+	ConfigHealthCheckMaxConcurrency    = "health_check_max_concurrency"     // 自适应并发上限
+	ConfigHealthCheckProxyConcurrency  = "health_check_proxy_concurrency"   // 同一代理的并发上限，0 表示不限制
+	ConfigHealthCheckTimeout           = "health_check_timeout"             // 单个账号检测超时（秒）
+
+	// 健康检测策略 - 启动预热探测
+	ConfigStartupProbeEnabled = "startup_probe_enabled" // 服务启动后是否对正常账号做一轮快速就绪探测
+	ConfigStartupProbeTimeout = "startup_probe_timeout" // 启动探测单个账号超时（秒）
+
+	// 调度 - 恢复账户冷却期
+	ConfigAccountWarmupDuration       = "account_warmup_duration"        // 冷却时长（分钟），0 表示关闭
+	ConfigAccountWarmupInitialPercent = "account_warmup_initial_percent" // 刚恢复时的流量比例（%）
+	ConfigAccountWarmupSuccessRelease = "account_warmup_success_release" // 连续成功多少次后完全放开
+
+	// 调度 - 成功率熔断
+	ConfigAccountSuccessRateWindow    = "account_success_rate_window"    // 滑动窗口大小（最近多少次请求），0 表示关闭
+	ConfigAccountSuccessRateThreshold = "account_success_rate_threshold" // 成功率阈值（%）
+	ConfigAccountSuccessRateCooldown  = "account_success_rate_cooldown"  // 熔断时长（秒）
 )
 
 // 默认配置
@@ -91,6 +215,63 @@ var DefaultConfigs = []SystemConfig{
 	{Key: ConfigGlobalPriceRate, Value: "1", Type: "float", Desc: "全局价格倍率（1=原价，0=免费，2=2倍），用户倍率为1时使用此值", Category: "billing"},
 	// 会话配置
 	{Key: ConfigSessionTTL, Value: "30", Type: "int", Desc: "会话粘性过期时间（分钟）", Category: "session"},
+	// 流式响应配置
+	{Key: ConfigStreamKeepAliveInterval, Value: "15", Type: "int", Desc: "流式响应无数据时发送 SSE 心跳的间隔（秒），0 表示关闭", Category: "stream"},
+	// 请求体大小限制
+	{Key: ConfigMaxRequestBodySize, Value: "10", Type: "int", Desc: "请求体大小上限（MB），超限返回 413，0 表示不限制", Category: "request"},
+	{Key: ConfigMaxRequestBodySizeClaude, Value: "32", Type: "int", Desc: "Claude 端点（/claude/*）请求体大小上限（MB），多模态图片请求较大，0 表示不限制", Category: "request"},
+	{Key: ConfigMaxRequestTimeout, Value: "600", Type: "int", Desc: "客户端可通过 X-Request-Timeout 头（秒）指定请求整体超时，超时返回 504；超过该上限时按上限处理，0 表示忽略该头", Category: "request"},
+	{Key: ConfigIdempotencyWindow, Value: "300", Type: "int", Desc: "相同 Idempotency-Key 的请求去重窗口（秒）：进行中的重复请求等待首个请求结果（流式直接拒绝），窗口内返回首个成功响应，首个失败时放行；0 表示忽略该头", Category: "request"},
+	{Key: ConfigPassthroughUpstreamError, Value: "false", Type: "bool", Desc: "非流式请求失败时原样返回上游错误体和状态码（便于调试），关闭时返回统一的自定义错误消息", Category: "request"},
+	{Key: ConfigMaxOutputTokensLimit, Value: "0", Type: "int", Desc: "单请求输出 token 上限（max_tokens / max_completion_tokens / max_output_tokens / maxOutputTokens），防止单请求产生巨额费用，0 表示不限制；API Key 设置了上限时优先使用", Category: "request"},
+	{Key: ConfigMaxOutputTokensAction, Value: "clamp", Type: "string", Desc: "请求的输出上限超过限制时的处理方式：clamp 改写为上限值后转发，reject 拒绝请求（400）", Category: "request"},
+	{Key: ConfigImageInlineMaxSize, Value: "5", Type: "int", Desc: "图片 URL 内联时单张图片下载大小上限（MB），仅对开启图片内联的 Claude 账户生效", Category: "request"},
+	{Key: ConfigImageInlineTimeout, Value: "10", Type: "int", Desc: "图片 URL 内联时单张图片下载超时（秒）", Category: "request"},
+	{Key: ConfigImageInlineOnFailure, Value: "error", Type: "string", Desc: "图片下载失败时的处理方式：error 请求失败（400），skip 保留原图片 URL 继续转发", Category: "request"},
+	{Key: ConfigImageInlineMaxCount, Value: "20", Type: "int", Desc: "图片 URL 内联时单个请求最多下载的图片数，超出的图片按下载失败处理", Category: "request"},
+	{Key: ConfigSystemPromptInjectEnabled, Value: "true", Type: "bool", Desc: "是否把账户 / API Key 配置的 system prompt 前缀、后缀合并到 Claude 请求的 system 字段后再转发（注入内容计入输入 token）", Category: "request"},
+	{Key: ConfigSystemPromptInjectScope, Value: "all", Type: "string", Desc: "system prompt 注入的作用范围：all 账户和 API Key 配置都生效（账户配置在最外层），account 只用账户配置，api_key 只用 API Key 配置", Category: "request"},
+	{Key: ConfigDegradeResponseText, Value: "服务繁忙，请稍后再试。", Type: "string", Desc: "降级响应的默认文本：开启降级响应的 API Key 在账户全部不可用时以正常 assistant 回复返回该内容（200，usage 为 0），API Key 可单独设置", Category: "request"},
+	{Key: ConfigResponsesCompatStripFields, Value: "temperature,top_p,max_output_tokens,user,text_formatting,truncation,text,service_tier", Type: "string", Desc: "OpenAI Responses 非 Codex CLI 请求转发前删除的字段（逗号分隔），none 表示不删除；账户可单独配置", Category: "request"},
+	{Key: ConfigResponsesCompatInstructions, Value: "override", Type: "string", Desc: "OpenAI Responses 非 Codex CLI 请求的 instructions 处理方式：override 替换为 Codex 默认 instructions，fill 仅客户端未提供时填入，keep 保持客户端原样；账户可单独配置", Category: "request"},
+	// 请求内容审查
+	{Key: ConfigContentFilterEnabled, Value: "false", Type: "bool", Desc: "是否在转发前审查请求文本（敏感词 / PII / 外部审查 API），关闭时无额外开销", Category: "content_filter"},
+	{Key: ConfigContentFilterAction, Value: "reject", Type: "string", Desc: "命中后的处理方式：reject 拒绝请求（403），redact 将命中内容替换为 *** 后继续转发（外部审查 API 命中时始终拒绝）", Category: "content_filter"},
+	{Key: ConfigContentFilterPatterns, Value: "", Type: "string", Desc: "敏感词正则词表，每行一条（如 (?i)secret\\s*project）", Category: "content_filter"},
+	{Key: ConfigContentFilterPII, Value: "false", Type: "bool", Desc: "是否检测 PII：邮箱、手机号、身份证号、银行卡号", Category: "content_filter"},
+	{Key: ConfigContentFilterMessage, Value: "请求内容包含敏感信息，已被拒绝", Type: "string", Desc: "拒绝请求时返回给客户端的消息", Category: "content_filter"},
+	{Key: ConfigContentFilterAPIURL, Value: "", Type: "string", Desc: "外部审查 API 地址（POST {\"text\": ...}，返回 {\"flagged\": bool, \"reason\": string}），为空不调用", Category: "content_filter"},
+	{Key: ConfigContentFilterAPITimeout, Value: "3000", Type: "int", Desc: "外部审查 API 超时（毫秒），超时或出错时放行", Category: "content_filter"},
+	// 套餐预算
+	{Key: ConfigBudgetWarningPercent, Value: "80", Type: "int", Desc: "套餐额度使用达到该百分比时在响应头返回 X-Budget-Warning，0 表示关闭", Category: "billing"},
+	{Key: ConfigBudgetReserveAmount, Value: "0.05", Type: "float", Desc: "每个进行中请求预扣的套餐额度（美元），防止并发请求超卖，0 表示不预扣", Category: "billing"},
+	// 请求重试
+	{Key: ConfigRetryMaxRetries, Value: "5", Type: "int", Desc: "请求失败最大重试次数（不含首次请求），上游异常时可调小避免雪崩", Category: "retry"},
+	{Key: ConfigRetryDelay, Value: "1000", Type: "int", Desc: "首次重试延迟（毫秒）", Category: "retry"},
+	{Key: ConfigRetryBackoff, Value: "1.5", Type: "float", Desc: "重试延迟退避系数，每次重试延迟乘以该系数", Category: "retry"},
+	{Key: ConfigRetryRetryableErrors, Value: "timeout,connection,403,429,529,503,502", Type: "string", Desc: "可重试错误关键词（逗号分隔，错误信息包含任一关键词即重试；命中配置了重试动作的错误规则时以规则为准）", Category: "retry"},
+	{Key: ConfigRetrySwitchOnRateLimit, Value: "true", Type: "bool", Desc: "账户限流时是否切换到其他账户", Category: "retry"},
+	{Key: ConfigRetrySameAccount, Value: "0", Type: "int", Desc: "瞬时错误（上游 5xx、连接重置）先在同一账户上退避重试的次数，用完再换账户，保持会话粘性；限流错误立即换账户，0 表示立即换账户", Category: "retry"},
+	{Key: ConfigRetryQueueMaxWait, Value: "0", Type: "int", Desc: "账户并发全满时按 API Key/套餐优先级排队等待槽位的最长时间（秒），超时返回 503，0 表示不排队；队列和并发计数只在单个实例内，多实例部署时各实例分别排队，优先级只在同一实例内保证", Category: "retry"},
+	{Key: ConfigModelFallbackChains, Value: "", Type: "string", Desc: "模型回退链，每行一条（如 claude-3-opus->claude-3-5-sonnet->claude-3-5-haiku），主模型无可用账户时依次降级，按实际模型计费；API Key 可覆盖或禁用", Category: "retry"},
+	{Key: ConfigCrossPlatformFallbackModel, Value: "gpt-4o", Type: "string", Desc: "Claude 账户全部不可用时，开启跨平台兜底的 API Key 的请求转换为 OpenAI 格式使用的模型（OpenAI 账户 ModelMapping 映射了该 Claude 模型时优先使用映射）", Category: "retry"},
+	// 灰度配置
+	{Key: ConfigCanaryEnabled, Value: "false", Type: "bool", Desc: "是否启用灰度：按比例把请求只调度到带灰度标签的账户，其余请求不调度这些账户；请求日志和指标按灰度组/对照组染色", Category: "retry"},
+	{Key: ConfigCanaryTag, Value: "", Type: "string", Desc: "灰度账户池标签（账户标签之一），为空时不启用灰度", Category: "retry"},
+	{Key: ConfigCanaryPercent, Value: "5", Type: "int", Desc: "灰度流量比例（0-100%）", Category: "retry"},
+	{Key: ConfigCanaryBucket, Value: "session", Type: "string", Desc: "分流方式：session 按会话哈希（同一会话始终在同一组），random 每个请求随机", Category: "retry"},
+	// 影子流量
+	{Key: ConfigShadowTrafficEnabled, Value: "false", Type: "bool", Desc: "是否启用影子流量：主请求成功后按比例把相同请求异步发给同平台的影子账户，结果只记录日志和指标，不返回客户端、不计费", Category: "retry"},
+	{Key: ConfigShadowTrafficPercent, Value: "10", Type: "int", Desc: "影子流量复制比例（0-100%）", Category: "retry"},
+	{Key: ConfigShadowTrafficMaxInFlight, Value: "20", Type: "int", Desc: "同时进行的影子请求上限，超过时丢弃本次复制，避免影子账户变慢拖累服务", Category: "retry"},
+	// 批处理计费
+	{Key: ConfigBatchPriceDiscount, Value: "0.5", Type: "float", Desc: "Claude Message Batches 计费折扣系数（官方半价为 0.5），在用户倍率基础上再乘以该系数", Category: "billing"},
+	{Key: ConfigStreamTruncatedPriceRatio, Value: "1", Type: "float", Desc: "流式响应未收到正常终止事件（疑似截断）时的计费系数：1 照常计费，0.5 半价，0 不计费", Category: "billing"},
+	{Key: ConfigTokenEstimateEnabled, Value: "true", Type: "bool", Desc: "上游流式响应未返回 usage（token 为 0）时按请求内容和响应文本本地估算 token，请求日志标记为估算", Category: "billing"},
+	{Key: ConfigTokenEstimateBilling, Value: "true", Type: "bool", Desc: "是否按估算的 token 计费，关闭时估算值只记录到请求日志、不产生费用", Category: "billing"},
+	{Key: ConfigResponseCacheTTL, Value: "300", Type: "int", Desc: "响应缓存默认缓存时间（秒），API Key 开启响应缓存但未设置 TTL 时使用；缓存只保存在当前实例内存中，多实例部署时各实例分别缓存", Category: "billing"},
+	{Key: ConfigResponseCacheHitPriceRatio, Value: "0", Type: "float", Desc: "响应缓存命中的计费系数（相对正常费用）：0 不计费，0.1 按一折计费；命中不产生上游成本（缓存按实例独立，请求落到其他实例时不命中）", Category: "billing"},
+	{Key: ConfigResponseCacheMaxEntries, Value: "10000", Type: "int", Desc: "响应缓存条目数上限（每个实例单独计算），超过时淘汰旧条目", Category: "billing"},
 	{Key: ConfigSyncEnabled, Value: "true", Type: "bool", Desc: "是否启用使用记录同步", Category: "sync"},
 	{Key: ConfigSyncInterval, Value: "5", Type: "int", Desc: "使用记录同步间隔（分钟）", Category: "sync"},
 	{Key: ConfigRecordRetentionDays, Value: "30", Type: "int", Desc: "Redis 使用记录保留天数", Category: "record"},
@@ -116,6 +297,8 @@ var DefaultConfigs = []SystemConfig{
 	{Key: ConfigRateLimitedProbeInitInterval, Value: "10", Type: "int", Desc: "限流账号初始探测间隔（分钟）", Category: "health_check"},
 	{Key: ConfigRateLimitedProbeMaxInterval, Value: "30", Type: "int", Desc: "限流账号最大探测间隔（分钟）", Category: "health_check"},
 	{Key: ConfigRateLimitedProbeBackoff, Value: "1.5", Type: "float", Desc: "限流账号探测间隔递增因子", Category: "health_check"},
+	{Key: ConfigRateLimitedDowngradeWindow, Value: "30", Type: "int", Desc: "健康检查 429 观察窗口（分钟）", Category: "health_check"},
+	{Key: ConfigRateLimitedDowngradeRatio, Value: "0.6", Type: "float", Desc: "观察窗口内健康检查 429 比例达到该值时标记为限流（停止调度，探测成功后恢复），0 表示关闭", Category: "health_check"},
 	// 健康检测策略 - 疑似封号
 	{Key: ConfigSuspendedProbeInterval, Value: "5", Type: "int", Desc: "疑似封号账号探测间隔（分钟）", Category: "health_check"},
 	{Key: ConfigSuspendedConfirmThreshold, Value: "3", Type: "int", Desc: "确认封号阈值（连续检测失败次数）", Category: "health_check"},
@@ -125,4 +308,29 @@ var DefaultConfigs = []SystemConfig{
 	// 健康检测策略 - Token 刷新
 	{Key: ConfigTokenRefreshCooldown, Value: "30", Type: "int", Desc: "Token 刷新失败冷却时间（分钟）", Category: "health_check"},
 	{Key: ConfigTokenRefreshMaxRetries, Value: "3", Type: "int", Desc: "Token 刷新最大重试次数", Category: "health_check"},
+	{Key: ConfigTokenPreRefreshThreshold, Value: "10", Type: "int", Desc: "Token 距过期小于该时间（分钟）时主动用 SessionKey 刷新，0 表示关闭", Category: "health_check"},
+	// 健康检测策略 - 凭证到期
+	{Key: ConfigCredentialExpiryWarnDays, Value: "7", Type: "int", Desc: "凭证距过期小于该天数时在凭证看板高亮为即将过期", Category: "health_check"},
+	{Key: ConfigCredentialExpiryAlert, Value: "true", Type: "bool", Desc: "无法自动刷新的凭证即将过期、已过期或 SessionKey 校验失效时推送告警（需启用告警 webhook）", Category: "health_check"},
+	{Key: ConfigSessionKeyCheckInterval, Value: "24", Type: "int", Desc: "SessionKey 轻量校验间隔（小时），只查询组织信息不重新授权，0 表示关闭", Category: "health_check"},
+	// 健康检测策略 - 深度探测
+	{Key: ConfigDeepProbeEnabled, Value: "false", Type: "bool", Desc: "健康检查时发送 max_tokens=1 的真实推理请求验证账号（会产生少量费用）", Category: "health_check"},
+	{Key: ConfigDeepProbeInterval, Value: "60", Type: "int", Desc: "同一账号两次深度探测的最小间隔（分钟）", Category: "health_check"},
+	// 健康检测策略 - 并发与超时
+	{Key: ConfigHealthCheckConcurrency, Value: "0", Type: "int", Desc: "健康检查并发数，0 表示按账号数自适应（max(5, 账号数/每并发账号数)）", Category: "health_check"},
+	{Key: ConfigHealthCheckAccountsPerWorker, Value: "20", Type: "int", Desc: "自适应并发时每个并发负责的账号数", Category: "health_check"},
+	{Key: ConfigHealthCheckMaxConcurrency, Value: "50", Type: "int", Desc: "自适应并发的上限", Category: "health_check"},
+	{Key: ConfigHealthCheckProxyConcurrency, Value: "5", Type: "int", Desc: "同一代理同时进行的检测数上限，避免打爆共享代理，0 表示不限制", Category: "health_check"},
+	{Key: ConfigHealthCheckTimeout, Value: "30", Type: "int", Desc: "单个账号健康检测超时（秒），账号配置了请求超时时以账号为准", Category: "health_check"},
+	// 健康检测策略 - 启动预热探测
+	{Key: ConfigStartupProbeEnabled, Value: "true", Type: "bool", Desc: "服务启动后在后台对正常账号做一轮快速就绪探测（不含深度探测），失败的账号临时标记为不可用，只作兜底调度", Category: "health_check"},
+	{Key: ConfigStartupProbeTimeout, Value: "5", Type: "int", Desc: "启动预热探测单个账号超时（秒）", Category: "health_check"},
+	// 调度 - 恢复账户冷却期
+	{Key: ConfigAccountWarmupDuration, Value: "10", Type: "int", Desc: "账号从限流/封号恢复后的冷却时长（分钟），期间调度权重从初始比例线性升至 100%，0 表示关闭", Category: "scheduler"},
+	{Key: ConfigAccountWarmupInitialPercent, Value: "10", Type: "int", Desc: "账号刚恢复时的调度权重比例（%）", Category: "scheduler"},
+	{Key: ConfigAccountWarmupSuccessRelease, Value: "20", Type: "int", Desc: "冷却期内连续成功达到该次数后完全放开，0 表示只按时间放量", Category: "scheduler"},
+	// 调度 - 成功率熔断
+	{Key: ConfigAccountSuccessRateWindow, Value: "0", Type: "int", Desc: "成功率熔断的滑动窗口大小（账号最近多少次请求），默认 0 关闭，开启时建议 20；与连续错误阈值同时生效", Category: "scheduler"},
+	{Key: ConfigAccountSuccessRateThreshold, Value: "50", Type: "int", Desc: "账号最近请求的成功率低于该值（%）时暂时移出调度，只作兜底", Category: "scheduler"},
+	{Key: ConfigAccountSuccessRateCooldown, Value: "300", Type: "int", Desc: "成功率熔断时长（秒），到期后重新统计", Category: "scheduler"},
 }
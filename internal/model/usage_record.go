@@ -28,6 +28,7 @@ type UsageRecord struct {
 	CacheReadInputTokens     int       `gorm:"default:0" json:"cache_read_input_tokens"`
 	TotalTokens              int       `gorm:"default:0" json:"total_tokens"`
 	TotalCost                float64   `gorm:"type:decimal(10,6);default:0" json:"total_cost"`
+	LongContext              bool      `gorm:"default:false" json:"long_context"` // 是否按长上下文档位计费
 	RequestTime              time.Time `gorm:"index" json:"request_time"` // 请求时间
 	CreatedAt                time.Time `json:"created_at"`
 }
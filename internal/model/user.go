@@ -5,6 +5,7 @@
  *   - 密码加密存储
  *   - 余额和费率倍率
  *   - 并发限制
+ *   - 所属组织（组织管理员只能管理本组织数据）
  * 重要程度：⭐⭐⭐⭐ 重要（核心数据结构）
  * 依赖模块：bcrypt, gorm
  */
@@ -23,6 +24,7 @@ type User struct {
 	Password  string         `gorm:"size:100;not null" json:"-"`
 	Email     string         `gorm:"size:100;uniqueIndex" json:"email,omitempty"`
 	Role      string         `gorm:"size:20;default:user" json:"role"`     // admin, user
+	OrgID     uint           `gorm:"default:0;index" json:"org_id"`        // 所属组织（0=不属于任何组织；OrgID 为 0 的管理员为超级管理员）
 	Status    string         `gorm:"size:20;default:active" json:"status"` // active, disabled
 	Balance        float64        `gorm:"type:decimal(10,4);default:0" json:"balance"`      // 余额（美元）
 	PriceRate      float64        `gorm:"type:decimal(5,2);default:1.0" json:"price_rate"`  // 价格倍率，默认1.0（原价），0表示免费
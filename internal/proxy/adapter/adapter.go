@@ -4,7 +4,8 @@
  *   - Adapter 接口定义（Send/SendStream）
  *   - 适配器注册表管理
  *   - UpstreamError 上游错误类型
- *   - StreamResult 流式结果封装
+ *   - 客户端断开错误（流式写入失败）
+ *   - StreamResult 流式结果封装（含截断检测）
  *   - TailWriter 流式响应末尾捕获
  *   - 通用响应头处理
  * 重要程度：⭐⭐⭐⭐⭐ 核心（所有适配器的基础接口）
@@ -23,12 +24,20 @@ import (
 
 var (
 	ErrNoAdapter = errors.New("no adapter found for account type")
+	// ErrClientDisconnected 流式写入客户端失败（连接已断开），上游读取需立即中断
+	ErrClientDisconnected = errors.New("client disconnected")
 )
 
+// clientWriteError 包装写入客户端失败的错误，供重试层识别为客户端取消
+func clientWriteError(err error) error {
+	return fmt.Errorf("%w: %v", ErrClientDisconnected, err)
+}
+
 // UpstreamError 上游错误（包含状态码）
 type UpstreamError struct {
 	StatusCode int
 	Message    string
+	Body       []byte // 上游原始响应体（开启透传上游错误体时原样返回给客户端）
 }
 
 func (e *UpstreamError) Error() string {
@@ -43,6 +52,15 @@ func NewUpstreamError(statusCode int, message string) *UpstreamError {
 	}
 }
 
+// NewUpstreamErrorWithBody 创建上游错误并保留原始响应体
+func NewUpstreamErrorWithBody(statusCode int, body []byte) *UpstreamError {
+	return &UpstreamError{
+		StatusCode: statusCode,
+		Message:    string(body),
+		Body:       body,
+	}
+}
+
 // Request 统一请求结构
 type Request struct {
 	Model       string        `json:"model"`
@@ -54,6 +72,7 @@ type Request struct {
 	Stop        []string      `json:"stop,omitempty"`
 	System      string        `json:"system,omitempty"`
 	Tools       []interface{} `json:"tools,omitempty"`
+	ToolChoice  interface{}   `json:"tool_choice,omitempty"`
 
 	// 原始请求体（用于直接转发）
 	RawBody []byte `json:"-"`
@@ -61,12 +80,31 @@ type Request struct {
 	Headers map[string]string `json:"-"`
 	// 原始请求路径（用于 Codex 等透传场景）
 	Path string `json:"-"`
+	// 图片 URL 内联选项（仅对开启 InlineImageURLs 的 Claude 账户生效）
+	ImageInline *ImageInlineOptions `json:"-"`
+	// system prompt 注入选项（仅 Claude 账户生效，nil 表示不注入）
+	SystemPrompt *SystemPromptOptions `json:"-"`
 }
 
 // Message 消息结构
 type Message struct {
-	Role    string      `json:"role"`
-	Content interface{} `json:"content"` // string 或 []ContentBlock
+	Role       string      `json:"role"`
+	Content    interface{} `json:"content"`                // string 或 []ContentBlock
+	ToolCalls  []ToolCall  `json:"tool_calls,omitempty"`   // assistant 消息中的工具调用
+	ToolCallID string      `json:"tool_call_id,omitempty"` // tool 消息对应的工具调用 ID
+}
+
+// ToolCall 工具调用（OpenAI tool_calls 格式）
+type ToolCall struct {
+	ID       string           `json:"id"`
+	Type     string           `json:"type"`
+	Function ToolCallFunction `json:"function"`
+}
+
+// ToolCallFunction 工具调用的函数名和参数（参数为 JSON 字符串）
+type ToolCallFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
 }
 
 // ContentBlock 内容块
@@ -82,14 +120,23 @@ type ContentBlock struct {
 
 // Response 统一响应结构
 type Response struct {
-	ID           string            `json:"id"`
-	Model        string            `json:"model"`
-	Content      string            `json:"content"`
-	StopReason   string            `json:"stop_reason,omitempty"`
-	InputTokens  int               `json:"input_tokens"`
-	OutputTokens int               `json:"output_tokens"`
-	Error        *Error            `json:"error,omitempty"`
-	Headers      map[string]string `json:"-"` // 响应头（用于获取限流信息等）
+	ID             string            `json:"id"`
+	Model          string            `json:"model"`
+	Content        string            `json:"content"`
+	StopReason     string            `json:"stop_reason,omitempty"`
+	StopSequence   string            `json:"stop_sequence,omitempty"` // 触发停止的自定义停止序列（Claude stop_reason 为 stop_sequence 时）
+	InputTokens    int               `json:"input_tokens"`
+	OutputTokens   int               `json:"output_tokens"`
+	ThinkingTokens int               `json:"thinking_tokens,omitempty"` // 思考 token（包含在 OutputTokens 中）
+	ToolCalls      []ToolCall        `json:"tool_calls,omitempty"`      // 工具调用（Claude tool_use 块转换为 OpenAI 格式）
+	Error          *Error            `json:"error,omitempty"`
+	Headers        map[string]string `json:"-"` // 响应头（用于获取限流信息等）
+
+	CacheReadInputTokens int `json:"cache_read_input_tokens,omitempty"` // 缓存命中的输入 token（不包含在 InputTokens 中）
+
+	// Claude prompt caching 统计，只用于响应头透传，不参与计费
+	PromptCacheCreationTokens int `json:"-"`
+	PromptCacheReadTokens     int `json:"-"`
 }
 
 // Error 错误结构
@@ -114,7 +161,16 @@ type StreamResult struct {
 	OutputTokens             int               `json:"output_tokens"`
 	CacheCreationInputTokens int               `json:"cache_creation_input_tokens,omitempty"`
 	CacheReadInputTokens     int               `json:"cache_read_input_tokens,omitempty"`
-	Headers                  map[string]string `json:"-"` // 响应头（用于获取限流信息等）
+	ThinkingTokens           int               `json:"thinking_tokens,omitempty"` // 思考 token（包含在 OutputTokens 中）
+	Headers                  map[string]string `json:"-"`                         // 响应头（用于获取限流信息等）
+	Completed                bool              `json:"-"`                         // 是否收到正常终止事件（message_stop / finish_reason / response.completed）
+	Estimated                bool              `json:"-"`                         // token 数为本地估算（上游未返回 usage）
+	SystemPromptTokens       int               `json:"-"`                         // 注入的 system prompt 估算 token（上游未返回 usage 时计入输入 token）
+}
+
+// Truncated 流式响应是否疑似截断：上游提前结束，没有收到正常终止事件
+func (r *StreamResult) Truncated() bool {
+	return r != nil && !r.Completed
 }
 
 // Adapter 适配器接口
@@ -170,18 +226,19 @@ func GetByPlatform(platform string) []Adapter {
 }
 
 // TailWriter 包装 Writer，同时捕获末尾 N 字节
+// 使用固定大小的环形缓冲区，大量小 chunk 写入时不会反复移动数据
 type TailWriter struct {
-	w       io.Writer
-	tail    []byte
-	maxSize int
+	w    io.Writer
+	ring []byte // 环形缓冲区，长度固定为 maxSize
+	pos  int    // 下一次写入位置
+	full bool   // 缓冲区是否已写满过一轮
 }
 
 // NewTailWriter 创建 TailWriter，捕获末尾 maxSize 字节
 func NewTailWriter(w io.Writer, maxSize int) *TailWriter {
 	return &TailWriter{
-		w:       w,
-		tail:    make([]byte, 0, maxSize),
-		maxSize: maxSize,
+		w:    w,
+		ring: make([]byte, maxSize),
 	}
 }
 
@@ -192,21 +249,44 @@ func (t *TailWriter) Write(p []byte) (n int, err error) {
 	if err != nil {
 		return n, err
 	}
+	t.capture(p[:n])
+	return n, nil
+}
 
-	// 追加到 tail 缓冲区
-	t.tail = append(t.tail, p[:n]...)
+// capture 将数据写入环形缓冲区
+func (t *TailWriter) capture(p []byte) {
+	size := len(t.ring)
+	if size == 0 {
+		return
+	}
 
-	// 如果超过最大大小，只保留末尾部分
-	if len(t.tail) > t.maxSize {
-		t.tail = t.tail[len(t.tail)-t.maxSize:]
+	// 超过缓冲区大小时只需要最后 size 字节
+	if len(p) >= size {
+		copy(t.ring, p[len(p)-size:])
+		t.pos = 0
+		t.full = true
+		return
 	}
 
-	return n, nil
+	copied := copy(t.ring[t.pos:], p)
+	if copied < len(p) {
+		copy(t.ring, p[copied:])
+	}
+	t.pos += len(p)
+	if t.pos >= size {
+		t.pos -= size
+		t.full = true
+	}
 }
 
 // Tail 获取捕获的末尾内容
 func (t *TailWriter) Tail() []byte {
-	return t.tail
+	if !t.full {
+		return t.ring[:t.pos]
+	}
+	tail := make([]byte, 0, len(t.ring))
+	tail = append(tail, t.ring[t.pos:]...)
+	return append(tail, t.ring[:t.pos]...)
 }
 
 // Flush 实现 http.Flusher 接口（如果底层 writer 支持）
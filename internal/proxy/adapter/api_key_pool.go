@@ -0,0 +1,97 @@
+/*
+ * 文件作用：账户内多 API Key 轮换，分摊单个账户的上游限流
+ * 负责功能：
+ *   - 按账户轮询选择可用的 API Key
+ *   - 记录被限流的 Key 并在冷却期内跳过
+ *   - 限流时切换到账户内的下一个 Key
+ * 重要程度：⭐⭐⭐ 一般（Claude Console 多 Key 账户）
+ * 依赖模块：model
+ */
+package adapter
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-aiproxy/internal/model"
+)
+
+// defaultAPIKeyCooldown Key 被限流且上游未返回 retry-after 时的冷却时长
+const defaultAPIKeyCooldown = 60 * time.Second
+
+var (
+	apiKeyCursors   = make(map[uint]int) // accountID -> 下一次轮询的位置
+	apiKeyCooldowns = make(map[string]time.Time)
+	apiKeyPoolMu    sync.Mutex
+)
+
+func apiKeyCooldownKey(accountID uint, key string) string {
+	return strconv.FormatUint(uint64(accountID), 10) + ":" + key
+}
+
+// withPooledAPIKey 账户配置了多个 Key 时轮询选择一个未在冷却中的 Key
+// 返回 APIKey 替换为所选 Key 的账户副本；只有一个 Key 时原样返回
+func withPooledAPIKey(account *model.Account) *model.Account {
+	keys := account.AvailableAPIKeys()
+	if len(keys) <= 1 {
+		return account
+	}
+
+	apiKeyPoolMu.Lock()
+	defer apiKeyPoolMu.Unlock()
+
+	now := time.Now()
+	start := apiKeyCursors[account.ID]
+	for i := 0; i < len(keys); i++ {
+		idx := (start + i) % len(keys)
+		if until, ok := apiKeyCooldowns[apiKeyCooldownKey(account.ID, keys[idx])]; ok && now.Before(until) {
+			continue
+		}
+		apiKeyCursors[account.ID] = idx + 1
+		return accountWithAPIKey(account, keys[idx])
+	}
+
+	// 全部在冷却中，仍按轮询选一个，由上游返回真实的限流错误
+	idx := start % len(keys)
+	apiKeyCursors[account.ID] = idx + 1
+	return accountWithAPIKey(account, keys[idx])
+}
+
+// nextPooledAPIKey 当前 Key 被限流时标记冷却，并返回使用下一个可用 Key 的账户副本
+// 没有其他可用 Key 时返回 nil（由调度层切换账户）
+func nextPooledAPIKey(account *model.Account, header http.Header) *model.Account {
+	keys := account.AvailableAPIKeys()
+	if len(keys) <= 1 {
+		return nil
+	}
+
+	cooldown := defaultAPIKeyCooldown
+	if seconds, err := strconv.Atoi(header.Get("retry-after")); err == nil && seconds > 0 {
+		cooldown = time.Duration(seconds) * time.Second
+	}
+
+	apiKeyPoolMu.Lock()
+	defer apiKeyPoolMu.Unlock()
+
+	now := time.Now()
+	apiKeyCooldowns[apiKeyCooldownKey(account.ID, account.APIKey)] = now.Add(cooldown)
+	for _, key := range keys {
+		if key == account.APIKey {
+			continue
+		}
+		if until, ok := apiKeyCooldowns[apiKeyCooldownKey(account.ID, key)]; ok && now.Before(until) {
+			continue
+		}
+		return accountWithAPIKey(account, key)
+	}
+	return nil
+}
+
+// accountWithAPIKey 复制账户并替换 APIKey（不修改调度器缓存中的账户）
+func accountWithAPIKey(account *model.Account, key string) *model.Account {
+	copied := *account
+	copied.APIKey = key
+	return &copied
+}
@@ -43,7 +43,7 @@ func (a *AzureOpenAIAdapter) SupportedTypes() []string {
 }
 
 func (a *AzureOpenAIAdapter) Send(ctx context.Context, account *model.Account, req *Request) (*Response, error) {
-	log := logger.GetLogger("proxy")
+	log := logger.GetLogger("proxy").Ctx(ctx)
 
 	openAIReq := convertToOpenAIRequest(req)
 	openAIReq.Stream = false
@@ -105,9 +105,11 @@ func (a *AzureOpenAIAdapter) Send(ctx context.Context, account *model.Account, r
 
 	content := ""
 	stopReason := ""
+	var toolCalls []ToolCall
 	if len(openAIResp.Choices) > 0 {
 		content = openAIResp.Choices[0].Message.Content
 		stopReason = openAIResp.Choices[0].FinishReason
+		toolCalls = openAIResp.Choices[0].Message.ToolCalls
 	}
 
 	log.Info("Azure OpenAI 请求成功 - Model: %s, InputTokens: %d, OutputTokens: %d",
@@ -118,13 +120,14 @@ func (a *AzureOpenAIAdapter) Send(ctx context.Context, account *model.Account, r
 		Model:        openAIResp.Model,
 		Content:      content,
 		StopReason:   stopReason,
+		ToolCalls:    toolCalls,
 		InputTokens:  openAIResp.Usage.PromptTokens,
 		OutputTokens: openAIResp.Usage.CompletionTokens,
 	}, nil
 }
 
 func (a *AzureOpenAIAdapter) SendStream(ctx context.Context, account *model.Account, req *Request, writer io.Writer) (*StreamResult, error) {
-	log := logger.GetLogger("proxy")
+	log := logger.GetLogger("proxy").Ctx(ctx)
 
 	openAIReq := convertToOpenAIRequest(req)
 	openAIReq.Stream = true
@@ -156,11 +159,13 @@ func (a *AzureOpenAIAdapter) SendStream(ctx context.Context, account *model.Acco
 		return nil, err
 	}
 	defer resp.Body.Close()
+	// 上游压缩的流先解压，SSE 解析和转发只处理明文
+	DecodeResponseBody(resp)
 
 	if resp.StatusCode != http.StatusOK {
 		respBody, _ := ReadResponseBody(resp)
 		log.Error("Azure OpenAI Stream API 错误 - StatusCode: %d, Body: %s", resp.StatusCode, string(respBody))
-		return nil, NewUpstreamError(resp.StatusCode, string(respBody))
+		return nil, NewUpstreamErrorWithBody(resp.StatusCode, respBody)
 	}
 
 	log.Debug("Azure OpenAI Stream 响应状态码: %d, 开始接收流式数据", resp.StatusCode)
@@ -170,20 +175,30 @@ func (a *AzureOpenAIAdapter) SendStream(ctx context.Context, account *model.Acco
 	for scanner.Scan() {
 		line := scanner.Text()
 		if strings.HasPrefix(line, "data: ") {
-			writer.Write([]byte(line + "\n\n"))
+			if _, writeErr := writer.Write([]byte(line + "\n\n")); writeErr != nil {
+				log.Warn("Azure OpenAI Stream 写入客户端失败，中断上游读取: %v", writeErr)
+				return result, clientWriteError(writeErr)
+			}
 			data := strings.TrimPrefix(line, "data: ")
 			if data == "[DONE]" {
 				log.Debug("Azure OpenAI Stream 接收完成")
+				result.Completed = true
 				break
 			}
-			// 尝试解析 usage
+			// 尝试解析 usage 和 finish_reason
 			var chunk struct {
+				Choices []struct {
+					FinishReason string `json:"finish_reason"`
+				} `json:"choices"`
 				Usage struct {
 					PromptTokens     int `json:"prompt_tokens"`
 					CompletionTokens int `json:"completion_tokens"`
 				} `json:"usage"`
 			}
 			if err := json.Unmarshal([]byte(data), &chunk); err == nil {
+				if len(chunk.Choices) > 0 && chunk.Choices[0].FinishReason != "" {
+					result.Completed = true
+				}
 				if chunk.Usage.PromptTokens > 0 {
 					result.InputTokens = chunk.Usage.PromptTokens
 				}
@@ -195,6 +210,11 @@ func (a *AzureOpenAIAdapter) SendStream(ctx context.Context, account *model.Acco
 	}
 
 	if err := scanner.Err(); err != nil {
+		// 检查是否是因为 context 取消导致的错误
+		if ctx.Err() != nil {
+			log.Info("Azure OpenAI Stream 因 context 取消而结束: %v", ctx.Err())
+			return result, ctx.Err()
+		}
 		log.Error("Azure OpenAI Stream 读取错误: %v", err)
 		return result, err
 	}
@@ -242,8 +262,10 @@ func convertToOpenAIRequest(req *Request) *openAIRequest {
 			}
 		}
 		messages = append(messages, openAIMessage{
-			Role:    msg.Role,
-			Content: content,
+			Role:       msg.Role,
+			Content:    content,
+			ToolCalls:  msg.ToolCalls,
+			ToolCallID: msg.ToolCallID,
 		})
 	}
 
@@ -255,5 +277,7 @@ func convertToOpenAIRequest(req *Request) *openAIRequest {
 		TopP:        req.TopP,
 		Stream:      req.Stream,
 		Stop:        req.Stop,
+		Tools:       req.Tools,
+		ToolChoice:  req.ToolChoice,
 	}
 }
@@ -104,7 +104,7 @@ type bedrockStreamEvent struct {
 }
 
 func (a *BedrockAdapter) Send(ctx context.Context, account *model.Account, req *Request) (*Response, error) {
-	log := logger.GetLogger("proxy")
+	log := logger.GetLogger("proxy").Ctx(ctx)
 
 	bedrockReq := a.convertRequest(req)
 
@@ -183,7 +183,7 @@ func (a *BedrockAdapter) Send(ctx context.Context, account *model.Account, req *
 }
 
 func (a *BedrockAdapter) SendStream(ctx context.Context, account *model.Account, req *Request, writer io.Writer) (*StreamResult, error) {
-	log := logger.GetLogger("proxy")
+	log := logger.GetLogger("proxy").Ctx(ctx)
 
 	bedrockReq := a.convertRequest(req)
 
@@ -218,7 +218,7 @@ func (a *BedrockAdapter) SendStream(ctx context.Context, account *model.Account,
 	if resp.StatusCode != http.StatusOK {
 		respBody, _ := ReadResponseBody(resp)
 		log.Error("Bedrock Stream API 错误 - StatusCode: %d, Body: %s", resp.StatusCode, string(respBody))
-		return nil, NewUpstreamError(resp.StatusCode, string(respBody))
+		return nil, NewUpstreamErrorWithBody(resp.StatusCode, respBody)
 	}
 
 	log.Debug("Bedrock Stream 响应状态码: %d, 开始接收流式数据", resp.StatusCode)
@@ -255,6 +255,11 @@ func (a *BedrockAdapter) SendStream(ctx context.Context, account *model.Account,
 			if event.Usage != nil {
 				result.OutputTokens = event.Usage.OutputTokens
 			}
+			if event.Delta != nil && event.Delta.StopReason != "" {
+				result.Completed = true
+			}
+		case "message_stop":
+			result.Completed = true
 		}
 
 		// 转换为 OpenAI 流式格式
@@ -276,7 +281,10 @@ func (a *BedrockAdapter) SendStream(ctx context.Context, account *model.Account,
 					},
 				}
 				chunkData, _ := json.Marshal(openAIChunk)
-				writer.Write([]byte("data: " + string(chunkData) + "\n\n"))
+				if _, writeErr := writer.Write([]byte("data: " + string(chunkData) + "\n\n")); writeErr != nil {
+					log.Warn("Bedrock Stream 写入客户端失败，中断上游读取: %v", writeErr)
+					return result, clientWriteError(writeErr)
+				}
 			}
 		case "message_delta":
 			if event.Delta != nil && event.Delta.StopReason != "" {
@@ -293,7 +301,10 @@ func (a *BedrockAdapter) SendStream(ctx context.Context, account *model.Account,
 					},
 				}
 				chunkData, _ := json.Marshal(openAIChunk)
-				writer.Write([]byte("data: " + string(chunkData) + "\n\n"))
+				if _, writeErr := writer.Write([]byte("data: " + string(chunkData) + "\n\n")); writeErr != nil {
+					log.Warn("Bedrock Stream 写入客户端失败，中断上游读取: %v", writeErr)
+					return result, clientWriteError(writeErr)
+				}
 			}
 		}
 	}
@@ -301,6 +312,11 @@ func (a *BedrockAdapter) SendStream(ctx context.Context, account *model.Account,
 	writer.Write([]byte("data: [DONE]\n\n"))
 
 	if err := scanner.Err(); err != nil {
+		// 检查是否是因为 context 取消导致的错误
+		if ctx.Err() != nil {
+			log.Info("Bedrock Stream 因 context 取消而结束: %v", ctx.Err())
+			return result, ctx.Err()
+		}
 		log.Error("Bedrock Stream 读取错误: %v", err)
 		return result, err
 	}
@@ -7,6 +7,10 @@
  *   - Thinking Block Signature 错误自动重试
  *   - 限流响应头提取（5H/7D利用率）
  *   - 账户 ModelMapping 模型转换
+ *   - 缺失的 anthropic-version / x-app 头补全（账户可配置默认值）
+ *   - 1M 上下文 beta 头透传/补全（模型名 [1m] 后缀）
+ *   - 图片 URL 内联为 base64（账户开关，见 image_inline.go）
+ *   - system prompt 前缀/后缀注入（账户 / API Key 配置，见 system_prompt.go）
  * 重要程度：⭐⭐⭐⭐⭐ 核心（Claude平台核心适配器）
  * 依赖模块：model, logger, http_client
  */
@@ -26,6 +30,18 @@ import (
 	"go-aiproxy/pkg/logger"
 )
 
+// 请求头补全默认值（账户未配置时使用）
+const (
+	DefaultAnthropicVersion = "2023-06-01"
+	DefaultXApp             = "cli"
+)
+
+// 1M 上下文
+const (
+	Context1MBeta        = "context-1m-2025-08-07" // 开启 1M 上下文的 anthropic-beta 值
+	context1MModelSuffix = "[1m]"                  // 客户端以 model[1m] 请求 1M 上下文
+)
+
 type ClaudeAdapter struct{}
 
 func init() {
@@ -49,7 +65,7 @@ func (a *ClaudeAdapter) SupportedTypes() []string {
 
 // Send 发送非流式请求 - 透传模式
 func (a *ClaudeAdapter) Send(ctx context.Context, account *model.Account, req *Request) (*Response, error) {
-	log := logger.GetLogger("proxy")
+	log := logger.GetLogger("proxy").Ctx(ctx)
 
 	// 直接使用原始请求体，不做任何解析和转换
 	body := req.RawBody
@@ -60,13 +76,31 @@ func (a *ClaudeAdapter) Send(ctx context.Context, account *model.Account, req *R
 	// 调试：记录请求体长度和前 500 字符
 	log.Debug("Claude 请求体 | 长度: %d | 前500字符: %s", len(body), truncateBody(string(body), 500))
 
+	// 账户开启图片内联时，把图片 URL 下载后改写为 base64
+	if account.InlineImageURLs {
+		inlined, err := InlineImageURLs(ctx, body, req.ImageInline)
+		if err != nil {
+			return nil, err
+		}
+		body = inlined
+	}
+
+	// 按配置把账户 / API Key 的 system prompt 前缀、后缀合并到请求体
+	prefix, suffix := req.SystemPrompt.Resolve(account)
+	body = InjectSystemPrompt(body, prefix, suffix)
+
+	// Claude Console 多 Key 账户按轮询选用 Key
+	if account.Type == model.AccountTypeClaudeConsole {
+		account = withPooledAPIKey(account)
+	}
+
 	// 执行请求（支持 signature 错误自动重试）
 	return a.doSendWithRetry(ctx, account, req, body, false)
 }
 
 // doSendWithRetry 执行非流式请求，支持 signature 错误自动重试
 func (a *ClaudeAdapter) doSendWithRetry(ctx context.Context, account *model.Account, req *Request, body []byte, isRetry bool) (*Response, error) {
-	log := logger.GetLogger("proxy")
+	log := logger.GetLogger("proxy").Ctx(ctx)
 
 	baseURL := "https://api.anthropic.com"
 	if account.BaseURL != "" {
@@ -120,7 +154,15 @@ func (a *ClaudeAdapter) doSendWithRetry(ctx context.Context, account *model.Acco
 			}
 		}
 
-		return nil, NewUpstreamError(resp.StatusCode, errStr)
+		// 多 Key 账户：当前 Key 被限流时先在账户内切换 Key
+		if resp.StatusCode == http.StatusTooManyRequests && account.Type == model.AccountTypeClaudeConsole {
+			if next := nextPooledAPIKey(account, resp.Header); next != nil {
+				log.Warn("Claude API Key 限流，切换账户内下一个 Key | AccountID: %d | Key: %s", account.ID, maskKey(account.APIKey))
+				return a.doSendWithRetry(ctx, next, req, body, isRetry)
+			}
+		}
+
+		return nil, NewUpstreamErrorWithBody(resp.StatusCode, respBody)
 	}
 
 	// 解析响应提取 usage 信息
@@ -144,13 +186,35 @@ func (a *ClaudeAdapter) SendStream(ctx context.Context, account *model.Account,
 		return nil, fmt.Errorf("empty request body")
 	}
 
+	// 账户开启图片内联时，把图片 URL 下载后改写为 base64
+	if account.InlineImageURLs {
+		inlined, err := InlineImageURLs(ctx, body, req.ImageInline)
+		if err != nil {
+			return nil, err
+		}
+		body = inlined
+	}
+
+	// 按配置把账户 / API Key 的 system prompt 前缀、后缀合并到请求体
+	prefix, suffix := req.SystemPrompt.Resolve(account)
+	body = InjectSystemPrompt(body, prefix, suffix)
+
+	// Claude Console 多 Key 账户按轮询选用 Key
+	if account.Type == model.AccountTypeClaudeConsole {
+		account = withPooledAPIKey(account)
+	}
+
 	// 执行流式请求（支持 signature 错误自动重试）
-	return a.doSendStreamWithRetry(ctx, account, req, body, writer, false)
+	result, err := a.doSendStreamWithRetry(ctx, account, req, body, writer, false)
+	if result != nil {
+		result.SystemPromptTokens = SystemPromptTokens(req.Model, prefix, suffix)
+	}
+	return result, err
 }
 
 // doSendStreamWithRetry 执行流式请求，支持 signature 错误自动重试
 func (a *ClaudeAdapter) doSendStreamWithRetry(ctx context.Context, account *model.Account, req *Request, body []byte, writer io.Writer, isRetry bool) (*StreamResult, error) {
-	log := logger.GetLogger("proxy")
+	log := logger.GetLogger("proxy").Ctx(ctx)
 
 	baseURL := "https://api.anthropic.com"
 	if account.BaseURL != "" {
@@ -177,6 +241,8 @@ func (a *ClaudeAdapter) doSendStreamWithRetry(ctx context.Context, account *mode
 		return nil, err
 	}
 	defer resp.Body.Close()
+	// 上游压缩的流先解压，SSE 解析和转发只处理明文
+	DecodeResponseBody(resp)
 
 	log.Info("Claude Stream 上游响应 | StatusCode: %d | AccountID: %d", resp.StatusCode, account.ID)
 
@@ -195,9 +261,17 @@ func (a *ClaudeAdapter) doSendStreamWithRetry(ctx context.Context, account *mode
 			}
 		}
 
+		// 多 Key 账户：当前 Key 被限流时先在账户内切换 Key（此时尚未向客户端写入任何内容）
+		if resp.StatusCode == http.StatusTooManyRequests && account.Type == model.AccountTypeClaudeConsole {
+			if next := nextPooledAPIKey(account, resp.Header); next != nil {
+				log.Warn("Claude Stream API Key 限流，切换账户内下一个 Key | AccountID: %d | Key: %s", account.ID, maskKey(account.APIKey))
+				return a.doSendStreamWithRetry(ctx, next, req, body, writer, isRetry)
+			}
+		}
+
 		// 发送 SSE 错误事件给客户端
 		a.sendSSEError(writer, fmt.Sprintf("upstream_error_%d", resp.StatusCode), errStr)
-		return nil, NewUpstreamError(resp.StatusCode, errStr)
+		return nil, NewUpstreamErrorWithBody(resp.StatusCode, respBody)
 	}
 
 	// 透传 SSE 流并解析 usage
@@ -354,7 +428,10 @@ func (a *ClaudeAdapter) doSendStreamWithRetry(ctx context.Context, account *mode
 						a.parseStreamUsage(dataStr, result)
 
 						for _, pendingLine := range pendingLines {
-							writer.Write([]byte(pendingLine + "\n"))
+							if _, writeErr := writer.Write([]byte(pendingLine + "\n")); writeErr != nil {
+								log.Warn("Claude Stream 写入客户端失败: %v | 已传输行数: %d", writeErr, lineCount)
+								return result, clientWriteError(writeErr)
+							}
 						}
 						pendingLines = nil
 						if hasFlusher {
@@ -374,8 +451,8 @@ func (a *ClaudeAdapter) doSendStreamWithRetry(ctx context.Context, account *mode
 				// 立即转发到客户端
 				_, writeErr := writer.Write([]byte(line + "\n"))
 				if writeErr != nil {
-					log.Warn("Claude Stream 写入客户端失败: %v | 已传输行数: %d", writeErr, lineCount)
-					return result, writeErr
+					log.Warn("Claude Stream 写入客户端失败，中断上游读取: %v | 已传输行数: %d", writeErr, lineCount)
+					return result, clientWriteError(writeErr)
 				}
 			}
 
@@ -431,7 +508,9 @@ func (a *ClaudeAdapter) doSendStreamWithRetry(ctx context.Context, account *mode
 	if lineCount <= 10 {
 		log.Warn("Claude Stream 行数异常少 | 总行数: %d | 内容: %v", lineCount, debugLines)
 	}
-	log.Info("Claude Stream 传输完成 | 总行数: %d | InputTokens: %d | OutputTokens: %d", lineCount, result.InputTokens, result.OutputTokens)
+	result.ThinkingTokens = capThinkingTokens(result.ThinkingTokens, result.OutputTokens)
+	log.Info("Claude Stream 传输完成 | 总行数: %d | InputTokens: %d | OutputTokens: %d | ThinkingTokens: %d",
+		lineCount, result.InputTokens, result.OutputTokens, result.ThinkingTokens)
 
 	return result, nil
 }
@@ -453,9 +532,11 @@ func (a *ClaudeAdapter) sendSSEError(writer io.Writer, errorType, message string
 
 // parseStreamUsage 从流式数据中解析 usage 信息
 func (a *ClaudeAdapter) parseStreamUsage(data string, result *StreamResult) {
+	// 收到 message_stop 视为正常结束，否则为疑似截断
 	// Claude 流式响应中，usage 信息在以下事件中：
 	// message_start: 包含 input_tokens（Claude 标准格式）
 	// message_delta: 包含 output_tokens (在流结束时)
+	// content_block_delta: thinking_delta 为思考内容，usage 不单独返回思考 token，按文本估算
 	//
 	// 注意：GLM 等兼容 API 可能在 message_delta 中返回完整的 usage 信息
 	// 包括 input_tokens 和 cache_read_input_tokens
@@ -476,6 +557,10 @@ func (a *ClaudeAdapter) parseStreamUsage(data string, result *StreamResult) {
 			CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
 			CacheReadInputTokens     int `json:"cache_read_input_tokens"`
 		} `json:"usage"`
+		Delta struct {
+			Type     string `json:"type"`
+			Thinking string `json:"thinking"`
+		} `json:"delta"`
 	}
 
 	if err := json.Unmarshal([]byte(data), &event); err != nil {
@@ -483,6 +568,12 @@ func (a *ClaudeAdapter) parseStreamUsage(data string, result *StreamResult) {
 	}
 
 	switch event.Type {
+	case "message_stop":
+		result.Completed = true
+	case "content_block_delta":
+		if event.Delta.Type == "thinking_delta" {
+			result.ThinkingTokens += estimateTextTokens(event.Delta.Thinking)
+		}
 	case "message_start":
 		// message_start 事件包含 input_tokens（Claude 标准格式）
 		if event.Message.Usage.InputTokens > 0 {
@@ -540,8 +631,20 @@ func (a *ClaudeAdapter) setHeaders(httpReq *http.Request, account *model.Account
 	if httpReq.Header.Get("Content-Type") == "" {
 		httpReq.Header.Set("Content-Type", "application/json")
 	}
+	// 部分中转站强制要求这两个头，客户端未传时按账户配置补全，显式传入的值不覆盖
 	if httpReq.Header.Get("anthropic-version") == "" {
-		httpReq.Header.Set("anthropic-version", "2023-06-01")
+		version := DefaultAnthropicVersion
+		if account.AnthropicVersion != "" {
+			version = account.AnthropicVersion
+		}
+		httpReq.Header.Set("anthropic-version", version)
+	}
+	if httpReq.Header.Get("x-app") == "" {
+		xApp := DefaultXApp
+		if account.XApp != "" {
+			xApp = account.XApp
+		}
+		httpReq.Header.Set("x-app", xApp)
 	}
 
 	// 3. 设置认证头（强制覆盖）
@@ -569,18 +672,40 @@ func (a *ClaudeAdapter) setHeaders(httpReq *http.Request, account *model.Account
 
 // addOAuthBeta 为 OAuth 添加必需的 beta feature
 func (a *ClaudeAdapter) addOAuthBeta(httpReq *http.Request) {
-	existingBeta := httpReq.Header.Get("anthropic-beta")
-	oauthBeta := "oauth-2025-04-20"
+	httpReq.Header.Set("anthropic-beta", appendBeta(httpReq.Header.Get("anthropic-beta"), "oauth-2025-04-20"))
+}
 
-	if strings.Contains(existingBeta, oauthBeta) {
-		return
+// appendBeta 在 anthropic-beta 列表中追加 feature（已存在时不重复）
+func appendBeta(existingBeta, beta string) string {
+	if strings.Contains(existingBeta, beta) {
+		return existingBeta
 	}
-
 	if existingBeta != "" {
-		httpReq.Header.Set("anthropic-beta", existingBeta+","+oauthBeta)
-	} else {
-		httpReq.Header.Set("anthropic-beta", oauthBeta)
+		return existingBeta + "," + beta
+	}
+	return beta
+}
+
+// ApplyContext1M 处理 1M 上下文请求
+// 客户端已带 context-1m beta 头时原样透传；模型名带 [1m] 后缀时去掉后缀并补全 beta 头
+// 返回实际模型名和改写后的请求体
+func ApplyContext1M(modelName string, rawBody []byte, headers map[string]string) (string, []byte) {
+	if !strings.HasSuffix(strings.ToLower(modelName), context1MModelSuffix) {
+		return modelName, rawBody
 	}
+
+	betaKey := "anthropic-beta"
+	for key := range headers {
+		if strings.EqualFold(key, betaKey) {
+			betaKey = key
+			break
+		}
+	}
+
+	actualModel := modelName[:len(modelName)-len(context1MModelSuffix)]
+	rawBody = ReplaceBodyModel(rawBody, actualModel)
+	headers[betaKey] = appendBeta(headers[betaKey], Context1MBeta)
+	return actualModel, rawBody
 }
 
 // parseResponse 解析响应提取 usage
@@ -590,13 +715,20 @@ func (a *ClaudeAdapter) parseResponse(respBody []byte) (*Response, error) {
 		Type    string `json:"type"`
 		Model   string `json:"model"`
 		Content []struct {
-			Type string `json:"type"`
-			Text string `json:"text"`
+			Type     string          `json:"type"`
+			Text     string          `json:"text"`
+			Thinking string          `json:"thinking"`
+			ID       string          `json:"id"`    // tool_use
+			Name     string          `json:"name"`  // tool_use
+			Input    json.RawMessage `json:"input"` // tool_use
 		} `json:"content"`
-		StopReason string `json:"stop_reason"`
-		Usage      struct {
-			InputTokens  int `json:"input_tokens"`
-			OutputTokens int `json:"output_tokens"`
+		StopReason   string  `json:"stop_reason"`
+		StopSequence *string `json:"stop_sequence"`
+		Usage        struct {
+			InputTokens              int `json:"input_tokens"`
+			OutputTokens             int `json:"output_tokens"`
+			CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+			CacheReadInputTokens     int `json:"cache_read_input_tokens"`
 		} `json:"usage"`
 		Error *struct {
 			Type    string `json:"type"`
@@ -618,22 +750,74 @@ func (a *ClaudeAdapter) parseResponse(respBody []byte) (*Response, error) {
 	}
 
 	content := ""
+	thinkingTokens := 0
+	var toolCalls []ToolCall
 	for _, block := range resp.Content {
-		if block.Type == "text" {
+		switch block.Type {
+		case "text":
 			content += block.Text
+		case "thinking":
+			thinkingTokens += estimateTextTokens(block.Thinking)
+		case "tool_use":
+			// 转换为 OpenAI tool_calls 格式，arguments 为 JSON 字符串
+			arguments := "{}"
+			if len(block.Input) > 0 {
+				arguments = string(block.Input)
+			}
+			toolCalls = append(toolCalls, ToolCall{
+				ID:   block.ID,
+				Type: "function",
+				Function: ToolCallFunction{
+					Name:      block.Name,
+					Arguments: arguments,
+				},
+			})
 		}
 	}
 
+	stopSequence := ""
+	if resp.StopSequence != nil {
+		stopSequence = *resp.StopSequence
+	}
+
 	return &Response{
-		ID:           resp.ID,
-		Model:        resp.Model,
-		Content:      content,
-		StopReason:   resp.StopReason,
-		InputTokens:  resp.Usage.InputTokens,
-		OutputTokens: resp.Usage.OutputTokens,
+		ID:             resp.ID,
+		Model:          resp.Model,
+		Content:        content,
+		StopReason:     resp.StopReason,
+		StopSequence:   stopSequence,
+		ToolCalls:      toolCalls,
+		InputTokens:    resp.Usage.InputTokens,
+		OutputTokens:   resp.Usage.OutputTokens,
+		ThinkingTokens: capThinkingTokens(thinkingTokens, resp.Usage.OutputTokens),
+
+		PromptCacheCreationTokens: resp.Usage.CacheCreationInputTokens,
+		PromptCacheReadTokens:     resp.Usage.CacheReadInputTokens,
 	}, nil
 }
 
+// estimateTextTokens 估算文本 token 数（ASCII 约 4 字符 1 token，其他字符约 1 字符 1 token）
+// Claude 的 usage 不单独返回思考 token，只能按思考文本估算
+func estimateTextTokens(text string) int {
+	asciiChars, otherChars := 0, 0
+	for _, r := range text {
+		if r < 128 {
+			asciiChars++
+		} else {
+			otherChars++
+		}
+	}
+	return (asciiChars+3)/4 + otherChars
+}
+
+// capThinkingTokens 思考 token 是输出 token 的一部分，估算值不能超过实际输出
+func capThinkingTokens(thinkingTokens, outputTokens int) int {
+	if outputTokens > 0 && thinkingTokens > outputTokens {
+		return outputTokens
+	}
+	return thinkingTokens
+}
+
 // truncateBody 截断响应体用于日志
 func truncateBody(body string, maxLen int) string {
 	if len(body) <= maxLen {
@@ -0,0 +1,57 @@
+/*
+ * 文件作用：Claude Message Batches API 透传
+ * 负责功能：
+ *   - 创建批次、查询批次状态、拉取批次结果的请求转发
+ *   - 复用 Claude 适配器的请求头透传和认证逻辑
+ * 重要程度：⭐⭐⭐ 一般（离线批量任务）
+ * 依赖模块：model, logger, http_client
+ */
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+
+	"go-aiproxy/internal/model"
+	"go-aiproxy/pkg/logger"
+)
+
+// ClaudeBatchesPath Message Batches API 路径
+const ClaudeBatchesPath = "/v1/messages/batches"
+
+// SendClaudeBatchRequest 透传 Message Batches 请求
+// subPath 为批次路径后缀（如 "" / "/msgbatch_xxx" / "/msgbatch_xxx/results"）
+// 返回原始响应，调用方负责关闭 Body（结果可能很大，需要流式转发）
+func SendClaudeBatchRequest(ctx context.Context, account *model.Account, method, subPath string, body []byte, clientHeaders map[string]string) (*http.Response, error) {
+	log := logger.GetLogger("proxy").Ctx(ctx)
+
+	baseURL := "https://api.anthropic.com"
+	if account.BaseURL != "" {
+		baseURL = account.BaseURL
+	}
+
+	var reader io.Reader
+	if len(body) > 0 {
+		reader = bytes.NewReader(body)
+	}
+
+	fullURL := baseURL + ClaudeBatchesPath + subPath
+	httpReq, err := http.NewRequestWithContext(ctx, method, fullURL, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	adp := &ClaudeAdapter{}
+	adp.setHeaders(httpReq, account, clientHeaders)
+
+	log.Debug("Claude Batch 请求 | %s %s | AccountID: %d", method, fullURL, account.ID)
+
+	resp, err := GetHTTPClient(account).Do(httpReq)
+	if err != nil {
+		log.Error("Claude Batch 网络错误: %v", err)
+		return nil, err
+	}
+	return resp, nil
+}
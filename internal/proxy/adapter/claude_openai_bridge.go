@@ -0,0 +1,617 @@
+/*
+ * 文件作用：Claude → OpenAI 跨平台兜底，把 Claude Messages 请求转换后发给 OpenAI 账户
+ * 负责功能：
+ *   - Claude 请求体转换为 OpenAI Chat Completions 格式（system、tool_use/tool_result、图片）
+ *   - 非流式响应转换回 Claude 格式
+ *   - 流式 SSE 逐块转换为 Claude 事件（message_start、content_block、message_delta、message_stop）
+ *   - 按账户 ModelMapping 确定目标模型
+ * 重要程度：⭐⭐⭐ 一般（Claude 账户全部不可用时的兜底）
+ * 依赖模块：model, logger, http_client
+ */
+package adapter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"go-aiproxy/internal/model"
+	"go-aiproxy/pkg/logger"
+)
+
+// Claude 请求中需要转换的字段
+type bridgeClaudeRequest struct {
+	Model         string                `json:"model"`
+	MaxTokens     int                   `json:"max_tokens"`
+	System        json.RawMessage       `json:"system,omitempty"`
+	Messages      []bridgeClaudeMessage `json:"messages"`
+	Temperature   *float64              `json:"temperature,omitempty"`
+	TopP          *float64              `json:"top_p,omitempty"`
+	StopSequences []string              `json:"stop_sequences,omitempty"`
+	Tools         []struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description,omitempty"`
+		InputSchema json.RawMessage `json:"input_schema,omitempty"`
+	} `json:"tools,omitempty"`
+	ToolChoice *struct {
+		Type string `json:"type"`
+		Name string `json:"name,omitempty"`
+	} `json:"tool_choice,omitempty"`
+}
+
+type bridgeClaudeMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+type bridgeClaudeBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   json.RawMessage `json:"content,omitempty"`
+	Source    *struct {
+		Type      string `json:"type"`
+		MediaType string `json:"media_type,omitempty"`
+		Data      string `json:"data,omitempty"`
+		URL       string `json:"url,omitempty"`
+	} `json:"source,omitempty"`
+}
+
+// OpenAI 请求中的消息（content 为字符串或多模态数组）
+type bridgeOpenAIMessage struct {
+	Role       string      `json:"role"`
+	Content    interface{} `json:"content"`
+	ToolCalls  []ToolCall  `json:"tool_calls,omitempty"`
+	ToolCallID string      `json:"tool_call_id,omitempty"`
+}
+
+// OpenAI 响应（非流式和流式 chunk 共用）
+type bridgeOpenAIResponse struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Message struct {
+			Content   string     `json:"content"`
+			ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+		} `json:"message"`
+		Delta struct {
+			Content   string `json:"content"`
+			ToolCalls []struct {
+				Index    int    `json:"index"`
+				ID       string `json:"id"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls,omitempty"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage,omitempty"`
+}
+
+// ResolveCrossPlatformModel 确定兜底请求发给 OpenAI 账户的模型
+// 账户 ModelMapping 映射了该 Claude 模型时使用映射结果，否则使用 defaultModel
+func ResolveCrossPlatformModel(account *model.Account, claudeModel, defaultModel string) string {
+	if account.ModelMapping != "" {
+		var mapping map[string]string
+		if err := json.Unmarshal([]byte(account.ModelMapping), &mapping); err == nil {
+			if mapped := mapping[claudeModel]; mapped != "" {
+				return mapped
+			}
+		}
+	}
+	return defaultModel
+}
+
+// ConvertClaudeRequestToOpenAI 把 Claude Messages 请求体转换为 OpenAI Chat Completions 请求体
+func ConvertClaudeRequestToOpenAI(rawBody []byte, targetModel string, stream bool) ([]byte, error) {
+	var req bridgeClaudeRequest
+	if err := json.Unmarshal(rawBody, &req); err != nil {
+		return nil, fmt.Errorf("parse claude request: %w", err)
+	}
+
+	messages := make([]bridgeOpenAIMessage, 0, len(req.Messages)+1)
+	if system := bridgeTextContent(req.System); system != "" {
+		messages = append(messages, bridgeOpenAIMessage{Role: "system", Content: system})
+	}
+	for _, msg := range req.Messages {
+		messages = append(messages, convertBridgeMessage(msg)...)
+	}
+
+	out := map[string]interface{}{
+		"model":    targetModel,
+		"messages": messages,
+	}
+	if req.MaxTokens > 0 {
+		out["max_tokens"] = req.MaxTokens
+	}
+	if req.Temperature != nil {
+		out["temperature"] = *req.Temperature
+	}
+	if req.TopP != nil {
+		out["top_p"] = *req.TopP
+	}
+	if len(req.StopSequences) > 0 {
+		out["stop"] = req.StopSequences
+	}
+	if stream {
+		out["stream"] = true
+		out["stream_options"] = map[string]interface{}{"include_usage": true}
+	}
+
+	if len(req.Tools) > 0 {
+		tools := make([]map[string]interface{}, 0, len(req.Tools))
+		for _, tool := range req.Tools {
+			parameters := tool.InputSchema
+			if len(parameters) == 0 {
+				parameters = json.RawMessage(`{"type":"object","properties":{}}`)
+			}
+			tools = append(tools, map[string]interface{}{
+				"type": "function",
+				"function": map[string]interface{}{
+					"name":        tool.Name,
+					"description": tool.Description,
+					"parameters":  parameters,
+				},
+			})
+		}
+		out["tools"] = tools
+	}
+	if req.ToolChoice != nil {
+		switch req.ToolChoice.Type {
+		case "auto":
+			out["tool_choice"] = "auto"
+		case "any":
+			out["tool_choice"] = "required"
+		case "none":
+			out["tool_choice"] = "none"
+		case "tool":
+			out["tool_choice"] = map[string]interface{}{
+				"type":     "function",
+				"function": map[string]string{"name": req.ToolChoice.Name},
+			}
+		}
+	}
+
+	return json.Marshal(out)
+}
+
+// convertBridgeMessage 转换单条 Claude 消息
+// user 消息中的 tool_result 拆成独立的 tool 消息；assistant 消息中的 tool_use 转为 tool_calls
+func convertBridgeMessage(msg bridgeClaudeMessage) []bridgeOpenAIMessage {
+	var text string
+	if err := json.Unmarshal(msg.Content, &text); err == nil {
+		return []bridgeOpenAIMessage{{Role: msg.Role, Content: text}}
+	}
+
+	var blocks []bridgeClaudeBlock
+	if err := json.Unmarshal(msg.Content, &blocks); err != nil {
+		return []bridgeOpenAIMessage{{Role: msg.Role, Content: ""}}
+	}
+
+	if msg.Role == "assistant" {
+		var sb strings.Builder
+		var toolCalls []ToolCall
+		for _, block := range blocks {
+			switch block.Type {
+			case "text":
+				sb.WriteString(block.Text)
+			case "tool_use":
+				arguments := string(block.Input)
+				if arguments == "" {
+					arguments = "{}"
+				}
+				toolCalls = append(toolCalls, ToolCall{
+					ID:       block.ID,
+					Type:     "function",
+					Function: ToolCallFunction{Name: block.Name, Arguments: arguments},
+				})
+			}
+		}
+		out := bridgeOpenAIMessage{Role: "assistant", ToolCalls: toolCalls}
+		if sb.Len() > 0 || len(toolCalls) == 0 {
+			out.Content = sb.String()
+		}
+		return []bridgeOpenAIMessage{out}
+	}
+
+	var result []bridgeOpenAIMessage
+	var parts []map[string]interface{}
+	hasImage := false
+	for _, block := range blocks {
+		switch block.Type {
+		case "tool_result":
+			result = append(result, bridgeOpenAIMessage{
+				Role:       "tool",
+				ToolCallID: block.ToolUseID,
+				Content:    bridgeTextContent(block.Content),
+			})
+		case "text":
+			parts = append(parts, map[string]interface{}{"type": "text", "text": block.Text})
+		case "image":
+			if block.Source == nil {
+				continue
+			}
+			url := block.Source.URL
+			if block.Source.Type == "base64" {
+				url = "data:" + block.Source.MediaType + ";base64," + block.Source.Data
+			}
+			if url == "" {
+				continue
+			}
+			hasImage = true
+			parts = append(parts, map[string]interface{}{
+				"type":      "image_url",
+				"image_url": map[string]string{"url": url},
+			})
+		}
+	}
+
+	if len(parts) == 0 {
+		return result
+	}
+	if !hasImage {
+		// 纯文本时合并为字符串，兼容不支持多模态数组的上游
+		var sb strings.Builder
+		for _, part := range parts {
+			sb.WriteString(part["text"].(string))
+		}
+		return append(result, bridgeOpenAIMessage{Role: msg.Role, Content: sb.String()})
+	}
+	return append(result, bridgeOpenAIMessage{Role: msg.Role, Content: parts})
+}
+
+// bridgeTextContent 提取 Claude 内容（字符串或 text 块数组）中的文本
+func bridgeTextContent(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text
+	}
+	var blocks []bridgeClaudeBlock
+	if err := json.Unmarshal(raw, &blocks); err != nil {
+		return ""
+	}
+	var parts []string
+	for _, block := range blocks {
+		if block.Type == "text" && block.Text != "" {
+			parts = append(parts, block.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// bridgeStopReason OpenAI finish_reason 转换为 Claude stop_reason
+func bridgeStopReason(reason string) string {
+	switch reason {
+	case "tool_calls", "function_call":
+		return "tool_use"
+	case "length":
+		return "max_tokens"
+	case "", "stop", "content_filter":
+		return "end_turn"
+	default:
+		return reason
+	}
+}
+
+// ClaudeContentBlocks 把兜底响应转换为 Claude content 块（text + tool_use）
+func ClaudeContentBlocks(resp *Response) []map[string]interface{} {
+	blocks := make([]map[string]interface{}, 0, len(resp.ToolCalls)+1)
+	if resp.Content != "" || len(resp.ToolCalls) == 0 {
+		blocks = append(blocks, map[string]interface{}{"type": "text", "text": resp.Content})
+	}
+	for _, call := range resp.ToolCalls {
+		input := json.RawMessage(call.Function.Arguments)
+		if !json.Valid(input) {
+			input = json.RawMessage("{}")
+		}
+		blocks = append(blocks, map[string]interface{}{
+			"type":  "tool_use",
+			"id":    call.ID,
+			"name":  call.Function.Name,
+			"input": input,
+		})
+	}
+	return blocks
+}
+
+// newBridgeHTTPRequest 构建发往 OpenAI 账户的 Chat Completions 请求
+func newBridgeHTTPRequest(ctx context.Context, account *model.Account, body []byte, stream bool) (*http.Request, error) {
+	baseURL := "https://api.openai.com"
+	if account.BaseURL != "" {
+		baseURL = account.BaseURL
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", baseURL+"/v1/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+account.APIKey)
+	if stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+	return httpReq, nil
+}
+
+// SendClaudeViaOpenAI 非流式发送：Claude 请求转换后发给 OpenAI 账户，响应以 Claude 语义返回
+func SendClaudeViaOpenAI(ctx context.Context, account *model.Account, req *Request, targetModel string) (*Response, error) {
+	log := logger.GetLogger("proxy").Ctx(ctx)
+
+	body, err := ConvertClaudeRequestToOpenAI(req.RawBody, targetModel, false)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := newBridgeHTTPRequest(ctx, account, body, false)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("跨平台兜底请求 | AccountID: %d | ClaudeModel: %s | TargetModel: %s", account.ID, req.Model, targetModel)
+
+	resp, err := GetHTTPClient(account).Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ReadResponseBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		log.Error("跨平台兜底上游错误 | AccountID: %d | StatusCode: %d | Body: %s",
+			account.ID, resp.StatusCode, truncateBody(string(respBody), 500))
+		return nil, NewUpstreamErrorWithBody(resp.StatusCode, respBody)
+	}
+
+	var openAIResp bridgeOpenAIResponse
+	if err := json.Unmarshal(respBody, &openAIResp); err != nil {
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+
+	result := &Response{
+		ID:    "msg_" + openAIResp.ID,
+		Model: openAIResp.Model,
+	}
+	if len(openAIResp.Choices) > 0 {
+		choice := openAIResp.Choices[0]
+		result.Content = choice.Message.Content
+		result.ToolCalls = choice.Message.ToolCalls
+		result.StopReason = bridgeStopReason(choice.FinishReason)
+	}
+	if openAIResp.Usage != nil {
+		result.InputTokens = openAIResp.Usage.PromptTokens
+		result.OutputTokens = openAIResp.Usage.CompletionTokens
+	}
+	return result, nil
+}
+
+// SendClaudeStreamViaOpenAI 流式发送：OpenAI SSE 逐块转换为 Claude SSE 事件写给客户端
+func SendClaudeStreamViaOpenAI(ctx context.Context, account *model.Account, req *Request, targetModel string, writer io.Writer) (*StreamResult, error) {
+	log := logger.GetLogger("proxy").Ctx(ctx)
+
+	body, err := ConvertClaudeRequestToOpenAI(req.RawBody, targetModel, true)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := newBridgeHTTPRequest(ctx, account, body, true)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("跨平台兜底流式请求 | AccountID: %d | ClaudeModel: %s | TargetModel: %s", account.ID, req.Model, targetModel)
+
+	resp, err := GetHTTPClient(account).Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	// 上游压缩的流先解压，SSE 解析和转发只处理明文
+	DecodeResponseBody(resp)
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := ReadResponseBody(resp)
+		log.Error("跨平台兜底流式上游错误 | AccountID: %d | StatusCode: %d | Body: %s",
+			account.ID, resp.StatusCode, truncateBody(string(respBody), 500))
+		return nil, NewUpstreamErrorWithBody(resp.StatusCode, respBody)
+	}
+
+	sw := &bridgeStreamWriter{w: writer, model: targetModel, blockIndex: -1}
+	result := &StreamResult{}
+	stopReason := ""
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		data := strings.TrimPrefix(line, "data: ")
+		if data == "[DONE]" {
+			result.Completed = true
+			break
+		}
+
+		var chunk bridgeOpenAIResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			continue
+		}
+		if chunk.Usage != nil {
+			result.InputTokens = chunk.Usage.PromptTokens
+			result.OutputTokens = chunk.Usage.CompletionTokens
+		}
+		if err := sw.start(chunk.ID); err != nil {
+			return result, clientWriteError(err)
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content != "" {
+				if err := sw.text(choice.Delta.Content); err != nil {
+					return result, clientWriteError(err)
+				}
+			}
+			for _, call := range choice.Delta.ToolCalls {
+				if err := sw.toolCall(call.Index, call.ID, call.Function.Name, call.Function.Arguments); err != nil {
+					return result, clientWriteError(err)
+				}
+			}
+			if choice.FinishReason != "" {
+				stopReason = bridgeStopReason(choice.FinishReason)
+				result.Completed = true
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		return result, err
+	}
+
+	if err := sw.finish(stopReason, result.InputTokens, result.OutputTokens); err != nil {
+		return result, clientWriteError(err)
+	}
+
+	log.Info("跨平台兜底流式请求完成 | AccountID: %d | TargetModel: %s | InputTokens: %d | OutputTokens: %d",
+		account.ID, targetModel, result.InputTokens, result.OutputTokens)
+	return result, nil
+}
+
+// bridgeStreamWriter 把 OpenAI 流式增量写成 Claude SSE 事件
+type bridgeStreamWriter struct {
+	w          io.Writer
+	model      string
+	started    bool
+	blockIndex int    // 当前打开的 content block 序号，-1 表示没有
+	blockType  string // 当前 block 类型：text / tool_use
+	toolIndex  int    // 当前 tool_use block 对应的 OpenAI tool_calls 序号
+}
+
+func (s *bridgeStreamWriter) event(name string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = s.w.Write([]byte("event: " + name + "\ndata: " + string(payload) + "\n\n"))
+	if err == nil {
+		if f, ok := s.w.(interface{ Flush() }); ok {
+			f.Flush()
+		}
+	}
+	return err
+}
+
+func (s *bridgeStreamWriter) start(id string) error {
+	if s.started {
+		return nil
+	}
+	s.started = true
+	return s.event("message_start", map[string]interface{}{
+		"type": "message_start",
+		"message": map[string]interface{}{
+			"id":            "msg_" + id,
+			"type":          "message",
+			"role":          "assistant",
+			"model":         s.model,
+			"content":       []interface{}{},
+			"stop_reason":   nil,
+			"stop_sequence": nil,
+			"usage":         map[string]int{"input_tokens": 0, "output_tokens": 0},
+		},
+	})
+}
+
+func (s *bridgeStreamWriter) closeBlock() error {
+	if s.blockIndex < 0 || s.blockType == "" {
+		return nil
+	}
+	s.blockType = ""
+	return s.event("content_block_stop", map[string]interface{}{
+		"type":  "content_block_stop",
+		"index": s.blockIndex,
+	})
+}
+
+func (s *bridgeStreamWriter) openBlock(blockType string, block map[string]interface{}) error {
+	if err := s.closeBlock(); err != nil {
+		return err
+	}
+	s.blockIndex++
+	s.blockType = blockType
+	return s.event("content_block_start", map[string]interface{}{
+		"type":          "content_block_start",
+		"index":         s.blockIndex,
+		"content_block": block,
+	})
+}
+
+func (s *bridgeStreamWriter) text(delta string) error {
+	if s.blockType != "text" {
+		if err := s.openBlock("text", map[string]interface{}{"type": "text", "text": ""}); err != nil {
+			return err
+		}
+	}
+	return s.event("content_block_delta", map[string]interface{}{
+		"type":  "content_block_delta",
+		"index": s.blockIndex,
+		"delta": map[string]string{"type": "text_delta", "text": delta},
+	})
+}
+
+func (s *bridgeStreamWriter) toolCall(index int, id, name, arguments string) error {
+	if s.blockType != "tool_use" || s.toolIndex != index {
+		if err := s.openBlock("tool_use", map[string]interface{}{
+			"type":  "tool_use",
+			"id":    id,
+			"name":  name,
+			"input": map[string]interface{}{},
+		}); err != nil {
+			return err
+		}
+		s.toolIndex = index
+	}
+	if arguments == "" {
+		return nil
+	}
+	return s.event("content_block_delta", map[string]interface{}{
+		"type":  "content_block_delta",
+		"index": s.blockIndex,
+		"delta": map[string]string{"type": "input_json_delta", "partial_json": arguments},
+	})
+}
+
+func (s *bridgeStreamWriter) finish(stopReason string, inputTokens, outputTokens int) error {
+	if err := s.start(""); err != nil {
+		return err
+	}
+	if err := s.closeBlock(); err != nil {
+		return err
+	}
+	if stopReason == "" {
+		stopReason = "end_turn"
+	}
+	if err := s.event("message_delta", map[string]interface{}{
+		"type":  "message_delta",
+		"delta": map[string]interface{}{"stop_reason": stopReason, "stop_sequence": nil},
+		"usage": map[string]int{"input_tokens": inputTokens, "output_tokens": outputTokens},
+	}); err != nil {
+		return err
+	}
+	return s.event("message_stop", map[string]string{"type": "message_stop"})
+}
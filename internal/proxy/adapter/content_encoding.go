@@ -0,0 +1,77 @@
+/*
+ * 文件作用：上游响应 Content-Encoding 解码，保证 SSE 解析和转发拿到明文
+ * 负责功能：
+ *   - gzip / br 压缩响应按流解压（边收边解，不等完整响应，保持流式实时性）
+ *   - 解码后移除 Content-Encoding / Content-Length，避免透传响应头时客户端重复解压
+ * 重要程度：⭐⭐⭐ 一般（部分中转站无视请求头强制压缩响应）
+ * 依赖模块：logger, brotli
+ */
+package adapter
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+
+	"go-aiproxy/pkg/logger"
+
+	"github.com/andybalholm/brotli"
+)
+
+// DecodeResponseBody 按 Content-Encoding 把 resp.Body 替换为解压后的流，返回是否做了解压
+// 未压缩或已解码（Content-Encoding 已移除）时不做处理
+// 解压器在首次读取时才创建：读取 gzip 头不会阻塞调用方，空响应体也不会报错
+// 不支持的编码保持原样并记录警告
+func DecodeResponseBody(resp *http.Response) bool {
+	if resp == nil || resp.Body == nil {
+		return false
+	}
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	var newReader func(io.Reader) (io.Reader, error)
+	switch encoding {
+	case "", "identity":
+		return false
+	case "gzip", "x-gzip":
+		newReader = func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }
+	case "br":
+		newReader = func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil }
+	default:
+		logger.GetLogger("proxy").Warn("不支持的上游响应编码，按原样处理: %s", encoding)
+		return false
+	}
+
+	resp.Body = &decodedBody{body: resp.Body, newReader: newReader}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return true
+}
+
+// decodedBody 延迟创建解压器的响应体，Close 时关闭原始响应体
+type decodedBody struct {
+	body      io.ReadCloser
+	newReader func(io.Reader) (io.Reader, error)
+	reader    io.Reader
+	err       error
+}
+
+// Read 实现 io.Reader 接口，解压器每次只从上游读取已到达的数据
+func (d *decodedBody) Read(p []byte) (int, error) {
+	if d.reader == nil && d.err == nil {
+		d.reader, d.err = d.newReader(d.body)
+	}
+	if d.err != nil {
+		return 0, d.err
+	}
+	return d.reader.Read(p)
+}
+
+// Close 关闭原始响应体
+func (d *decodedBody) Close() error {
+	if closer, ok := d.reader.(io.Closer); ok {
+		closer.Close()
+	}
+	return d.body.Close()
+}
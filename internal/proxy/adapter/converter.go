@@ -116,11 +116,7 @@ type GeminiResponse struct {
 		} `json:"content"`
 		FinishReason string `json:"finishReason"`
 	} `json:"candidates"`
-	UsageMetadata struct {
-		PromptTokenCount     int `json:"promptTokenCount"`
-		CandidatesTokenCount int `json:"candidatesTokenCount"`
-		TotalTokenCount      int `json:"totalTokenCount"`
-	} `json:"usageMetadata"`
+	UsageMetadata GeminiUsageMetadata `json:"usageMetadata"`
 }
 
 // ======================== OpenAI -> Other ========================
@@ -445,7 +441,7 @@ func (c *FormatConverter) GeminiResponseToOpenAI(resp *GeminiResponse) *OpenAIRe
 			TotalTokens      int `json:"total_tokens"`
 		}{
 			PromptTokens:     resp.UsageMetadata.PromptTokenCount,
-			CompletionTokens: resp.UsageMetadata.CandidatesTokenCount,
+			CompletionTokens: resp.UsageMetadata.OutputTokens(),
 			TotalTokens:      resp.UsageMetadata.TotalTokenCount,
 		},
 	}
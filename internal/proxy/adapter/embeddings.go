@@ -0,0 +1,129 @@
+/*
+ * 文件作用：OpenAI 兼容 embeddings 接口透传
+ * 负责功能：
+ *   - 向 OpenAI / Gemini API Key 账户转发 /embeddings 请求
+ *   - 从响应中读取 usage（上游未返回时按输入文本估算）
+ * 重要程度：⭐⭐⭐ 一般（文本向量化）
+ * 依赖模块：model, logger, http_client
+ */
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"go-aiproxy/internal/model"
+	"go-aiproxy/pkg/logger"
+)
+
+// EmbeddingAccountTypes 支持 embeddings 的账户类型
+// Gemini 通过 AI Studio 的 OpenAI 兼容接口转发，只支持 API Key 账户
+var EmbeddingAccountTypes = []string{model.AccountTypeOpenAI, model.AccountTypeGeminiAPI}
+
+// EmbeddingResult embeddings 请求结果
+type EmbeddingResult struct {
+	Body        []byte // 上游原始响应体，原样返回给客户端
+	ContentType string
+	Model       string
+	InputTokens int
+}
+
+// SendEmbeddings 透传 embeddings 请求，非 2xx 响应返回 UpstreamError（由重试层决定是否换账户）
+func SendEmbeddings(ctx context.Context, account *model.Account, body []byte) (*EmbeddingResult, error) {
+	log := logger.GetLogger("proxy").Ctx(ctx)
+
+	fullURL := embeddingsURL(account)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, fullURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+account.APIKey)
+
+	log.Debug("Embeddings 请求 | URL: %s | AccountID: %d | BodyLen: %d", fullURL, account.ID, len(body))
+
+	resp, err := GetHTTPClient(account).Do(httpReq)
+	if err != nil {
+		log.Error("Embeddings 网络错误: %v", err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ReadResponseBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log.Error("Embeddings API 错误 | StatusCode: %d | Body: %s", resp.StatusCode, truncateBody(string(respBody), 500))
+		return nil, NewUpstreamErrorWithBody(resp.StatusCode, respBody)
+	}
+
+	// 只读取顶层 model / usage，不解析向量数据
+	result := &EmbeddingResult{
+		Body:        respBody,
+		ContentType: resp.Header.Get("Content-Type"),
+	}
+	json.Unmarshal(BodyField(respBody, "model"), &result.Model)
+	var usage struct {
+		PromptTokens int `json:"prompt_tokens"`
+	}
+	json.Unmarshal(BodyField(respBody, "usage"), &usage)
+	result.InputTokens = usage.PromptTokens
+	if result.InputTokens <= 0 {
+		result.InputTokens = estimateEmbeddingInputTokens(BodyField(body, "input"))
+	}
+	if result.ContentType == "" {
+		result.ContentType = "application/json"
+	}
+	return result, nil
+}
+
+// embeddingsURL 按账户类型拼接 embeddings 接口地址（BaseURL 约定与对话接口一致）
+func embeddingsURL(account *model.Account) string {
+	if account.Type == model.AccountTypeGeminiAPI {
+		baseURL := "https://generativelanguage.googleapis.com/v1beta"
+		if account.BaseURL != "" {
+			baseURL = account.BaseURL
+		}
+		return baseURL + "/openai/embeddings"
+	}
+
+	baseURL := "https://api.openai.com"
+	if account.BaseURL != "" {
+		baseURL = account.BaseURL
+	}
+	return baseURL + "/v1/embeddings"
+}
+
+// estimateEmbeddingInputTokens 上游未返回 usage 时按输入文本估算 token（input 为字符串或字符串数组）
+// 已分词的输入（整数数组）按元素个数计
+func estimateEmbeddingInputTokens(input []byte) int {
+	var text string
+	if json.Unmarshal(input, &text) == nil {
+		return estimateTextTokens(text)
+	}
+	var texts []string
+	if json.Unmarshal(input, &texts) == nil {
+		total := 0
+		for _, t := range texts {
+			total += estimateTextTokens(t)
+		}
+		return total
+	}
+	var tokens []json.RawMessage
+	if json.Unmarshal(input, &tokens) == nil {
+		total := 0
+		for _, item := range tokens {
+			var ids []int
+			if json.Unmarshal(item, &ids) == nil {
+				total += len(ids)
+			} else {
+				total++
+			}
+		}
+		return total
+	}
+	return 0
+}
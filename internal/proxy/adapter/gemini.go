@@ -4,7 +4,7 @@
  *   - Gemini API 请求转发
  *   - OpenAI 格式到 Gemini 格式转换
  *   - 流式SSE响应处理
- *   - Usage数据解析
+ *   - Usage数据解析（缓存命中、思考、工具调用 token 分别计入对应类别）
  * 重要程度：⭐⭐⭐⭐ 重要（Gemini平台适配器）
  * 依赖模块：model, logger, http_client
  */
@@ -77,20 +77,41 @@ type geminiResponse struct {
 		} `json:"content"`
 		FinishReason string `json:"finishReason"`
 	} `json:"candidates"`
-	UsageMetadata struct {
-		PromptTokenCount     int `json:"promptTokenCount"`
-		CandidatesTokenCount int `json:"candidatesTokenCount"`
-		TotalTokenCount      int `json:"totalTokenCount"`
-	} `json:"usageMetadata"`
-	Error *struct {
+	UsageMetadata GeminiUsageMetadata `json:"usageMetadata"`
+	Error         *struct {
 		Code    int    `json:"code"`
 		Message string `json:"message"`
 		Status  string `json:"status"`
 	} `json:"error,omitempty"`
 }
 
+// GeminiUsageMetadata Gemini 的 usageMetadata（Google 计费口径）
+// promptTokenCount 包含缓存命中的部分，candidatesTokenCount 不包含思考 token
+type GeminiUsageMetadata struct {
+	PromptTokenCount        int `json:"promptTokenCount"`
+	CandidatesTokenCount    int `json:"candidatesTokenCount"`
+	TotalTokenCount         int `json:"totalTokenCount"`
+	CachedContentTokenCount int `json:"cachedContentTokenCount,omitempty"` // 缓存命中的输入 token
+	ThoughtsTokenCount      int `json:"thoughtsTokenCount,omitempty"`      // 思考 token（按输出计费）
+	ToolUsePromptTokenCount int `json:"toolUsePromptTokenCount,omitempty"` // 工具调用结果的输入 token
+}
+
+// InputTokens 按输入价格计费的 token：提示词（扣除缓存命中）+ 工具调用结果
+func (u GeminiUsageMetadata) InputTokens() int {
+	input := u.PromptTokenCount - u.CachedContentTokenCount
+	if input < 0 {
+		input = 0
+	}
+	return input + u.ToolUsePromptTokenCount
+}
+
+// OutputTokens 输出 token（含思考 token，与 Response/StreamResult 的口径一致）
+func (u GeminiUsageMetadata) OutputTokens() int {
+	return u.CandidatesTokenCount + u.ThoughtsTokenCount
+}
+
 func (a *GeminiAdapter) Send(ctx context.Context, account *model.Account, req *Request) (*Response, error) {
-	log := logger.GetLogger("proxy")
+	log := logger.GetLogger("proxy").Ctx(ctx)
 
 	geminiReq := a.convertRequest(req)
 
@@ -108,6 +129,7 @@ func (a *GeminiAdapter) Send(ctx context.Context, account *model.Account, req *R
 	}
 
 	httpReq.Header.Set("Content-Type", "application/json")
+	setGeminiAuth(httpReq, account)
 
 	// 记录请求日志 (隐藏 API Key)
 	safeURL := strings.Split(url, "?")[0]
@@ -159,21 +181,24 @@ func (a *GeminiAdapter) Send(ctx context.Context, account *model.Account, req *R
 		stopReason = candidate.FinishReason
 	}
 
-	log.Info("Gemini 请求成功 - Model: %s, InputTokens: %d, OutputTokens: %d",
-		req.Model, geminiResp.UsageMetadata.PromptTokenCount, geminiResp.UsageMetadata.CandidatesTokenCount)
+	usage := geminiResp.UsageMetadata
+	log.Info("Gemini 请求成功 - Model: %s, InputTokens: %d, OutputTokens: %d, CachedTokens: %d, ThoughtsTokens: %d",
+		req.Model, usage.InputTokens(), usage.OutputTokens(), usage.CachedContentTokenCount, usage.ThoughtsTokenCount)
 
 	return &Response{
-		ID:           "", // Gemini 不返回 ID
-		Model:        req.Model,
-		Content:      content,
-		StopReason:   a.convertStopReason(stopReason),
-		InputTokens:  geminiResp.UsageMetadata.PromptTokenCount,
-		OutputTokens: geminiResp.UsageMetadata.CandidatesTokenCount,
+		ID:                   "", // Gemini 不返回 ID
+		Model:                req.Model,
+		Content:              content,
+		StopReason:           a.convertStopReason(stopReason),
+		InputTokens:          usage.InputTokens(),
+		OutputTokens:         usage.OutputTokens(),
+		CacheReadInputTokens: usage.CachedContentTokenCount,
+		ThinkingTokens:       usage.ThoughtsTokenCount,
 	}, nil
 }
 
 func (a *GeminiAdapter) SendStream(ctx context.Context, account *model.Account, req *Request, writer io.Writer) (*StreamResult, error) {
-	log := logger.GetLogger("proxy")
+	log := logger.GetLogger("proxy").Ctx(ctx)
 
 	geminiReq := a.convertRequest(req)
 
@@ -192,6 +217,7 @@ func (a *GeminiAdapter) SendStream(ctx context.Context, account *model.Account,
 
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("Accept", "text/event-stream")
+	setGeminiAuth(httpReq, account)
 
 	safeURL := strings.Split(url, "?")[0]
 	log.Info("Gemini Stream 请求开始 | URL: %s | AccountID: %d | Model: %s",
@@ -207,13 +233,15 @@ func (a *GeminiAdapter) SendStream(ctx context.Context, account *model.Account,
 		return nil, err
 	}
 	defer resp.Body.Close()
+	// 上游压缩的流先解压，SSE 解析和转发只处理明文
+	DecodeResponseBody(resp)
 
 	if resp.StatusCode != http.StatusOK {
 		respBody, _ := ReadResponseBody(resp)
 		log.Error("Gemini Stream API 错误 - StatusCode: %d, Body: %s", resp.StatusCode, string(respBody))
 		// 发送 SSE 错误事件给客户端
 		a.sendSSEError(writer, fmt.Sprintf("upstream_error_%d", resp.StatusCode), string(respBody))
-		return nil, NewUpstreamError(resp.StatusCode, string(respBody))
+		return nil, NewUpstreamErrorWithBody(resp.StatusCode, respBody)
 	}
 
 	log.Info("Gemini Stream 开始传输 | StatusCode: %d | AccountID: %d", resp.StatusCode, account.ID)
@@ -303,12 +331,17 @@ func (a *GeminiAdapter) SendStream(ctx context.Context, account *model.Account,
 			continue
 		}
 
-		// 解析 usage
-		if chunk.UsageMetadata.PromptTokenCount > 0 {
-			result.InputTokens = chunk.UsageMetadata.PromptTokenCount
+		// 解析 usage（每个 chunk 的 usageMetadata 是累计值，取最后一次）
+		if usage := chunk.UsageMetadata; usage.TotalTokenCount > 0 {
+			result.InputTokens = usage.InputTokens()
+			result.OutputTokens = usage.OutputTokens()
+			result.CacheReadInputTokens = usage.CachedContentTokenCount
+			result.ThinkingTokens = usage.ThoughtsTokenCount
 		}
-		if chunk.UsageMetadata.CandidatesTokenCount > 0 {
-			result.OutputTokens = chunk.UsageMetadata.CandidatesTokenCount
+
+		// 收到 finishReason 视为正常结束
+		if len(chunk.Candidates) > 0 && chunk.Candidates[0].FinishReason != "" {
+			result.Completed = true
 		}
 
 		// 转换为 OpenAI 流式格式
@@ -335,8 +368,8 @@ func (a *GeminiAdapter) SendStream(ctx context.Context, account *model.Account,
 			chunkData, _ := json.Marshal(openAIChunk)
 			_, writeErr := writer.Write([]byte("data: " + string(chunkData) + "\n\n"))
 			if writeErr != nil {
-				log.Warn("Gemini Stream 写入客户端失败: %v", writeErr)
-				return result, writeErr
+				log.Warn("Gemini Stream 写入客户端失败，中断上游读取: %v", writeErr)
+				return result, clientWriteError(writeErr)
 			}
 
 			// 立即刷新，确保客户端及时收到数据
@@ -357,8 +390,8 @@ func (a *GeminiAdapter) SendStream(ctx context.Context, account *model.Account,
 		return result, err
 	}
 
-	log.Info("Gemini Stream 传输完成 | Model: %s | AccountID: %d | InputTokens: %d | OutputTokens: %d",
-		req.Model, account.ID, result.InputTokens, result.OutputTokens)
+	log.Info("Gemini Stream 传输完成 | Model: %s | AccountID: %d | InputTokens: %d | OutputTokens: %d | CachedTokens: %d | ThoughtsTokens: %d",
+		req.Model, account.ID, result.InputTokens, result.OutputTokens, result.CacheReadInputTokens, result.ThinkingTokens)
 	return result, nil
 }
 
@@ -393,14 +426,26 @@ func (a *GeminiAdapter) buildURL(account *model.Account, modelName string, strea
 		action = "streamGenerateContent"
 	}
 
-	url := fmt.Sprintf("%s/%s:%s", baseURL, modelName, action)
+	return fmt.Sprintf("%s/%s:%s", baseURL, modelName, action)
+}
 
-	// API Key 认证
+// GeminiAuthHeaders 获取 Gemini 请求的认证头
+// 优先使用 AccessToken（OAuth），为空时使用 AI Studio API Key（x-goog-api-key 头，避免 Key 出现在 URL 和日志中）
+func GeminiAuthHeaders(account *model.Account) map[string]string {
+	if account.AccessToken != "" {
+		return map[string]string{"Authorization": "Bearer " + account.AccessToken}
+	}
 	if account.APIKey != "" {
-		url += "?key=" + account.APIKey
+		return map[string]string{"x-goog-api-key": account.APIKey}
 	}
+	return map[string]string{}
+}
 
-	return url
+// setGeminiAuth 为请求设置 Gemini 认证头
+func setGeminiAuth(httpReq *http.Request, account *model.Account) {
+	for k, v := range GeminiAuthHeaders(account) {
+		httpReq.Header.Set(k, v)
+	}
 }
 
 func (a *GeminiAdapter) convertRequest(req *Request) *geminiRequest {
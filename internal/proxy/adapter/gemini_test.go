@@ -0,0 +1,138 @@
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"go-aiproxy/internal/model"
+	"go-aiproxy/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	// adapter 发送请求时会写日志，日志写到临时目录
+	dir, err := os.MkdirTemp("", "adapter-test-logs")
+	if err != nil {
+		panic(err)
+	}
+	if err := logger.Init(dir, logger.LevelError); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// capturedGeminiRequest 测试上游收到的认证信息
+type capturedGeminiRequest struct {
+	authorization string
+	apiKeyHeader  string
+	query         string
+}
+
+// newGeminiTestServer 启动模拟 Gemini 上游，记录每个请求的认证头和查询参数
+func newGeminiTestServer(t *testing.T, captured *capturedGeminiRequest) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		captured.authorization = r.Header.Get("Authorization")
+		captured.apiKeyHeader = r.Header.Get("x-goog-api-key")
+		captured.query = r.URL.RawQuery
+
+		chunk := `{"candidates":[{"content":{"parts":[{"text":"ok"}],"role":"model"},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":1,"candidatesTokenCount":1,"totalTokenCount":2}}`
+		if r.Header.Get("Accept") == "text/event-stream" {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("data: " + chunk + "\n\n"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(chunk))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGeminiAuthHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		account *model.Account
+		want    map[string]string
+	}{
+		{"OAuth", &model.Account{AccessToken: "ya29.token"}, map[string]string{"Authorization": "Bearer ya29.token"}},
+		{"API Key", &model.Account{APIKey: "AIza-key"}, map[string]string{"x-goog-api-key": "AIza-key"}},
+		{"两者都有时优先 OAuth", &model.Account{AccessToken: "ya29.token", APIKey: "AIza-key"}, map[string]string{"Authorization": "Bearer ya29.token"}},
+		{"都没有", &model.Account{}, map[string]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := GeminiAuthHeaders(tt.account)
+			if len(got) != len(tt.want) {
+				t.Fatalf("GeminiAuthHeaders() = %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Fatalf("GeminiAuthHeaders()[%q] = %q, want %q", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestGeminiAdapterAuth(t *testing.T) {
+	tests := []struct {
+		name          string
+		account       model.Account
+		authorization string
+		apiKeyHeader  string
+	}{
+		{"API Key 使用 x-goog-api-key 头", model.Account{APIKey: "AIza-key"}, "", "AIza-key"},
+		{"OAuth 使用 Bearer 头", model.Account{AccessToken: "ya29.token"}, "Bearer ya29.token", ""},
+	}
+
+	adapter := &GeminiAdapter{}
+	for _, tt := range tests {
+		for _, stream := range []bool{false, true} {
+			name := tt.name + "/非流式"
+			if stream {
+				name = tt.name + "/流式"
+			}
+			t.Run(name, func(t *testing.T) {
+				var captured capturedGeminiRequest
+				server := newGeminiTestServer(t, &captured)
+
+				account := tt.account
+				account.ID = 1
+				account.Platform = model.PlatformGemini
+				account.BaseURL = server.URL
+				req := &Request{
+					Model:    "gemini-2.5-flash",
+					Messages: []Message{{Role: "user", Content: "hi"}},
+					Stream:   stream,
+				}
+
+				var err error
+				if stream {
+					_, err = adapter.SendStream(context.Background(), &account, req, &bytes.Buffer{})
+				} else {
+					_, err = adapter.Send(context.Background(), &account, req)
+				}
+				if err != nil {
+					t.Fatalf("请求失败: %v", err)
+				}
+
+				if captured.authorization != tt.authorization {
+					t.Fatalf("Authorization = %q, want %q", captured.authorization, tt.authorization)
+				}
+				if captured.apiKeyHeader != tt.apiKeyHeader {
+					t.Fatalf("x-goog-api-key = %q, want %q", captured.apiKeyHeader, tt.apiKeyHeader)
+				}
+				// 凭据只放在请求头，不出现在 URL 查询参数中
+				if captured.query != "" && bytes.Contains([]byte(captured.query), []byte("key=")) {
+					t.Fatalf("凭据出现在查询参数中: %q", captured.query)
+				}
+			})
+		}
+	}
+}
@@ -4,9 +4,10 @@
  *   - 全局HTTP客户端（普通/流式）
  *   - 代理客户端缓存（避免重复创建）
  *   - Chrome TLS指纹支持（绕过TLS检测）
- *   - SOCKS5/HTTP代理支持
- *   - gzip响应自动解压
+ *   - SOCKS5/HTTP代理支持（支持备用代理故障转移）
+ *   - gzip/br响应自动解压（解码见 content_encoding.go）
  *   - 连接池参数配置
+ *   - 账户级超时配置（建连/响应头/整体）
  * 重要程度：⭐⭐⭐⭐⭐ 核心（所有上游请求的基础）
  * 依赖模块：model, logger
  */
@@ -75,38 +76,165 @@ var (
 	proxyClientCacheLock sync.RWMutex
 )
 
+// 默认超时（账户未配置时使用）
+const (
+	defaultConnectTimeout = 30 * time.Second
+	defaultRequestTimeout = 120 * time.Second
+	defaultStreamTimeout  = 600 * time.Second
+)
+
+// directClientKey 直连客户端（自定义超时）的缓存键
+const directClientKey = "direct"
+
+// clientTimeouts 客户端超时配置，零值表示全部使用默认值
+type clientTimeouts struct {
+	connect        time.Duration // 建连超时，0 使用默认 30 秒
+	responseHeader time.Duration // 等待响应头超时，0 不限制
+	total          time.Duration // 整体超时，0 使用默认值（普通 120 秒，流式 600 秒）
+}
+
+// getAccountTimeouts 读取账户的超时配置（秒），<= 0 的项使用默认值
+func getAccountTimeouts(account *model.Account, streaming bool) clientTimeouts {
+	var t clientTimeouts
+	if account == nil {
+		return t
+	}
+	seconds := func(v int) time.Duration {
+		if v <= 0 {
+			return 0
+		}
+		return time.Duration(v) * time.Second
+	}
+	t.connect = seconds(account.ConnectTimeout)
+	t.responseHeader = seconds(account.ReadTimeout)
+	if streaming {
+		t.total = seconds(account.StreamTimeout)
+	} else {
+		t.total = seconds(account.RequestTimeout)
+	}
+	return t
+}
+
+// isDefault 是否全部使用默认值
+func (t clientTimeouts) isDefault() bool {
+	return t == clientTimeouts{}
+}
+
+// connectTimeout 建连超时
+func (t clientTimeouts) connectTimeout() time.Duration {
+	if t.connect > 0 {
+		return t.connect
+	}
+	return defaultConnectTimeout
+}
+
+// totalTimeout 整体超时
+func (t clientTimeouts) totalTimeout(streaming bool) time.Duration {
+	if t.total > 0 {
+		return t.total
+	}
+	if streaming {
+		return defaultStreamTimeout
+	}
+	return defaultRequestTimeout
+}
+
+// cacheKey 在缓存键后追加超时配置，默认配置保持原缓存键
+func (t clientTimeouts) cacheKey(key string) string {
+	if t.isDefault() {
+		return key
+	}
+	return fmt.Sprintf("%s#timeout=%d/%d/%d", key, t.connect/time.Second, t.responseHeader/time.Second, t.total/time.Second)
+}
+
+// GetRequestTimeout 获取账户非流式请求的整体超时（未配置时为默认 120 秒）
+func GetRequestTimeout(account *model.Account) time.Duration {
+	return getAccountTimeouts(account, false).totalTimeout(false)
+}
+
 // GetHTTPClient 获取代理感知的 HTTP 客户端
 // 如果账户关联了代理，则使用该代理，否则直连
+// 代理配置了备用代理时，建连失败自动切换到备用代理
+// 账户配置了超时时按账户超时创建客户端
 // 使用客户端缓存，避免重复创建
 func GetHTTPClient(account *model.Account) *http.Client {
-	proxyURL := GetEffectiveProxy(account)
-	if proxyURL == "" {
-		return defaultHTTPClient
-	}
-	return getOrCreateProxyClient(proxyURL, false)
+	return getAccountClient(account, false)
 }
 
 // GetStreamHTTPClient 获取用于流式请求的 HTTP 客户端
-// 使用更长的超时时间（10分钟），适用于 SSE 流式响应
+// 默认使用更长的超时时间（10分钟），适用于 SSE 流式响应
 // 使用客户端缓存，避免重复创建
 func GetStreamHTTPClient(account *model.Account) *http.Client {
-	proxyURL := GetEffectiveProxy(account)
-	if proxyURL == "" {
-		return defaultStreamClient
+	return getAccountClient(account, true)
+}
+
+// getAccountClient 按账户代理链路和超时配置获取客户端
+func getAccountClient(account *model.Account, streaming bool) *http.Client {
+	timeouts := getAccountTimeouts(account, streaming)
+	chain := getProxyChain(account)
+	switch len(chain) {
+	case 0:
+		if !timeouts.isDefault() {
+			return getOrCreateDirectClient(streaming, timeouts)
+		}
+		if streaming {
+			return defaultStreamClient
+		}
+		return defaultHTTPClient
+	case 1:
+		return getOrCreateProxyClient(chain[0], streaming, timeouts)
+	default:
+		return getOrCreateFailoverClient(chain, streaming, timeouts)
 	}
-	return getOrCreateProxyClient(proxyURL, true)
+}
+
+// getOrCreateDirectClient 获取或创建自定义超时的直连客户端（带缓存）
+func getOrCreateDirectClient(streaming bool, timeouts clientTimeouts) *http.Client {
+	cacheKey := directClientKey
+	if streaming {
+		cacheKey = "stream:" + cacheKey
+	}
+	cacheKey = timeouts.cacheKey(cacheKey)
+
+	proxyClientCacheLock.RLock()
+	if client, ok := proxyClientCache[cacheKey]; ok {
+		proxyClientCacheLock.RUnlock()
+		return client
+	}
+	proxyClientCacheLock.RUnlock()
+
+	transport := defaultHTTPClient.Transport.(*http.Transport).Clone()
+	if streaming {
+		transport = defaultStreamClient.Transport.(*http.Transport).Clone()
+	}
+	transport.ResponseHeaderTimeout = timeouts.responseHeader
+	transport.DialContext = (&net.Dialer{
+		Timeout:   timeouts.connectTimeout(),
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   timeouts.totalTimeout(streaming),
+	}
+
+	proxyClientCacheLock.Lock()
+	proxyClientCache[cacheKey] = client
+	proxyClientCacheLock.Unlock()
+	return client
 }
 
 // getOrCreateProxyClient 获取或创建代理客户端（带缓存）
 // streaming: true 表示流式客户端，false 表示普通客户端
-func getOrCreateProxyClient(proxyURLStr string, streaming bool) *http.Client {
+func getOrCreateProxyClient(proxyURLStr string, streaming bool, timeouts clientTimeouts) *http.Client {
 	log := logger.GetLogger("proxy")
 
-	// 缓存键：区分流式和普通客户端
+	// 缓存键：区分流式和普通客户端，以及自定义超时
 	cacheKey := proxyURLStr
 	if streaming {
 		cacheKey = "stream:" + proxyURLStr
 	}
+	cacheKey = timeouts.cacheKey(cacheKey)
 
 	// 先尝试从缓存读取
 	proxyClientCacheLock.RLock()
@@ -140,21 +268,22 @@ func getOrCreateProxyClient(proxyURLStr string, streaming bool) *http.Client {
 				IdleConnTimeout:       120 * time.Second,
 				DisableCompression:    true,
 				ForceAttemptHTTP2:     false,
-				ResponseHeaderTimeout: 0,
+				ResponseHeaderTimeout: timeouts.responseHeader,
 				DialContext: (&net.Dialer{
-					Timeout:   30 * time.Second,
+					Timeout:   timeouts.connectTimeout(),
 					KeepAlive: 30 * time.Second,
 				}).DialContext,
 			}
 		} else {
 			transport = &http.Transport{
-				Proxy:               http.ProxyURL(proxyURL),
-				MaxIdleConns:        50,
-				MaxIdleConnsPerHost: 10,
-				IdleConnTimeout:     90 * time.Second,
-				DisableCompression:  false,
+				Proxy:                 http.ProxyURL(proxyURL),
+				MaxIdleConns:          50,
+				MaxIdleConnsPerHost:   10,
+				IdleConnTimeout:       90 * time.Second,
+				DisableCompression:    false,
+				ResponseHeaderTimeout: timeouts.responseHeader,
 				DialContext: (&net.Dialer{
-					Timeout:   30 * time.Second,
+					Timeout:   timeouts.connectTimeout(),
 					KeepAlive: 30 * time.Second,
 				}).DialContext,
 			}
@@ -172,7 +301,12 @@ func getOrCreateProxyClient(proxyURLStr string, streaming bool) *http.Client {
 			}
 		}
 
-		dialer, err := proxy.SOCKS5("tcp", proxyURL.Host, auth, proxy.Direct)
+		// 配置了建连超时时限制到 SOCKS5 代理的建连时间
+		var forward proxy.Dialer = proxy.Direct
+		if timeouts.connect > 0 {
+			forward = &net.Dialer{Timeout: timeouts.connect, KeepAlive: 30 * time.Second}
+		}
+		dialer, err := proxy.SOCKS5("tcp", proxyURL.Host, auth, forward)
 		if err != nil {
 			log.Error("创建 SOCKS5 dialer 失败: %v", err)
 			if streaming {
@@ -189,15 +323,16 @@ func getOrCreateProxyClient(proxyURLStr string, streaming bool) *http.Client {
 				IdleConnTimeout:       120 * time.Second,
 				DisableCompression:    true,
 				ForceAttemptHTTP2:     false,
-				ResponseHeaderTimeout: 0,
+				ResponseHeaderTimeout: timeouts.responseHeader,
 			}
 		} else {
 			transport = &http.Transport{
-				Dial:                dialer.Dial,
-				MaxIdleConns:        50,
-				MaxIdleConnsPerHost: 10,
-				IdleConnTimeout:     90 * time.Second,
-				DisableCompression:  false,
+				Dial:                  dialer.Dial,
+				MaxIdleConns:          50,
+				MaxIdleConnsPerHost:   10,
+				IdleConnTimeout:       90 * time.Second,
+				DisableCompression:    false,
+				ResponseHeaderTimeout: timeouts.responseHeader,
 			}
 		}
 
@@ -210,14 +345,9 @@ func getOrCreateProxyClient(proxyURLStr string, streaming bool) *http.Client {
 	}
 
 	// 创建客户端
-	timeout := 120 * time.Second
-	if streaming {
-		timeout = 600 * time.Second
-	}
-
 	client := &http.Client{
 		Transport: transport,
-		Timeout:   timeout,
+		Timeout:   timeouts.totalTimeout(streaming),
 	}
 
 	// 存入缓存
@@ -312,42 +442,28 @@ func createProxyClient(proxyURLStr string) *http.Client {
 	}
 }
 
-// ReadResponseBody 读取响应体，自动处理 gzip 解压
-// 如果响应头包含 Content-Encoding: gzip，则自动解压
+// ReadResponseBody 读取响应体，自动处理 gzip / br 解压
+// 如果响应头包含 Content-Encoding: gzip 或 br，则自动解压
 func ReadResponseBody(resp *http.Response) ([]byte, error) {
 	if resp.Body == nil {
 		return nil, nil
 	}
 
-	var reader io.Reader = resp.Body
-
-	// 检查是否为 gzip 编码
-	contentEncoding := resp.Header.Get("Content-Encoding")
-	if strings.EqualFold(contentEncoding, "gzip") {
-		gzReader, err := gzip.NewReader(resp.Body)
-		if err != nil {
-			// gzip 解压失败，可能数据不是真正的 gzip，尝试原始读取
-			log := logger.GetLogger("proxy")
-			log.Warn("gzip 解压失败，尝试原始读取: %v", err)
-			// 重置 body 已经被消费，这里无法恢复，返回错误
-			return nil, err
-		}
-		defer gzReader.Close()
-		reader = gzReader
-	}
+	// 按 Content-Encoding 解压（已解码的响应不会重复处理）
+	decoded := DecodeResponseBody(resp)
 
 	// 也检查内容是否以 gzip magic bytes 开头（有时服务端不设置 Content-Encoding）
 	// 先读取到 buffer，检查前两个字节
 	var buf bytes.Buffer
-	_, err := io.Copy(&buf, reader)
+	_, err := io.Copy(&buf, resp.Body)
 	if err != nil {
 		return nil, err
 	}
 
 	data := buf.Bytes()
 
-	// 如果没有通过 Content-Encoding 检测到 gzip，但内容以 gzip magic bytes 开头
-	if !strings.EqualFold(contentEncoding, "gzip") && len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+	// 如果没有通过 Content-Encoding 解压，但内容以 gzip magic bytes 开头
+	if !decoded && len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
 		log := logger.GetLogger("proxy")
 		log.Debug("检测到 gzip magic bytes，进行解压")
 		gzReader, err := gzip.NewReader(bytes.NewReader(data))
@@ -372,11 +488,14 @@ func ReadResponseBody(resp *http.Response) ([]byte, error) {
 
 // ProxyConfig 代理配置（用于 Chrome TLS 客户端）
 type ProxyConfig struct {
-	Type     string // socks5, http, https
-	Host     string
-	Port     int
-	Username string
-	Password string
+	Type      string // socks5, http, https
+	Host      string
+	Port      int
+	Username  string
+	Password  string
+	Fallbacks []*ProxyConfig // 备用代理，建连失败时按顺序切换
+
+	rawURL string // 原始代理 URL（备用代理解析自 URL 时保留，用于共享切换状态）
 }
 
 // GetChromeTLSClient 获取带 Chrome TLS 指纹的 HTTP 客户端
@@ -384,13 +503,7 @@ type ProxyConfig struct {
 func GetChromeTLSClient(account *model.Account) *http.Client {
 	var proxyConfig *ProxyConfig
 	if account != nil && account.Proxy != nil && account.Proxy.Enabled {
-		proxyConfig = &ProxyConfig{
-			Type:     account.Proxy.Type,
-			Host:     account.Proxy.Host,
-			Port:     account.Proxy.Port,
-			Username: account.Proxy.Username,
-			Password: account.Proxy.Password,
-		}
+		proxyConfig = NewProxyConfig(account.Proxy)
 	}
 	return createChromeTLSClient(proxyConfig)
 }
@@ -427,8 +540,16 @@ func createChromeTLSClient(proxyConfig *ProxyConfig) *http.Client {
 	}
 }
 
-// dialWithProxy 通过代理建立普通连接
+// dialWithProxy 通过代理建立普通连接（配置了备用代理时自动故障转移）
 func dialWithProxy(network, addr string, proxyConfig *ProxyConfig) (net.Conn, error) {
+	if proxyConfig != nil && proxyConfig.Host != "" && len(proxyConfig.Fallbacks) > 0 {
+		return dialWithFailover(network, addr, proxyConfig)
+	}
+	return dialSingleProxy(network, addr, proxyConfig)
+}
+
+// dialSingleProxy 通过单个代理建立普通连接
+func dialSingleProxy(network, addr string, proxyConfig *ProxyConfig) (net.Conn, error) {
 	if proxyConfig == nil || proxyConfig.Host == "" {
 		return net.DialTimeout(network, addr, 30*time.Second)
 	}
@@ -582,9 +703,13 @@ func ClearProxyClientCache(proxyURL string) {
 	proxyClientCacheLock.Lock()
 	defer proxyClientCacheLock.Unlock()
 
-	// 删除普通和流式客户端
-	delete(proxyClientCache, proxyURL)
-	delete(proxyClientCache, "stream:"+proxyURL)
+	// 删除普通和流式客户端（含自定义超时的客户端）
+	for key := range proxyClientCache {
+		base, _, _ := strings.Cut(strings.TrimPrefix(key, "stream:"), "#timeout=")
+		if base == proxyURL {
+			delete(proxyClientCache, key)
+		}
+	}
 
 	log := logger.GetLogger("proxy")
 	log.Debug("清理代理客户端缓存: %s", proxyURL)
@@ -631,8 +756,11 @@ func getProxyCacheKeys() []string {
 	seen := make(map[string]bool)
 
 	for key := range proxyClientCache {
-		// 去掉 stream: 前缀
-		proxyURL := strings.TrimPrefix(key, "stream:")
+		// 去掉 stream: 前缀和超时后缀
+		proxyURL, _, _ := strings.Cut(strings.TrimPrefix(key, "stream:"), "#timeout=")
+		if proxyURL == directClientKey {
+			continue
+		}
 		if !seen[proxyURL] {
 			// 只显示代理类型和主机，不显示密码
 			if u, err := url.Parse(proxyURL); err == nil {
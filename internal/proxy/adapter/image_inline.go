@@ -0,0 +1,348 @@
+/*
+ * 文件作用：Claude 请求体中图片 URL 的下载和 base64 内联（部分中转站只接受 base64 图片）
+ * 负责功能：
+ *   - 识别 image（source.type=url）和 OpenAI 风格 image_url 内容块（含 tool_result 内嵌内容）
+ *   - 下载图片并改写为 base64 source 块，data: URL 直接解码
+ *   - 限制单张大小、下载超时和单个请求的图片数，校验图片类型
+ *   - 拒绝连接非公网地址（内网、CGNAT、保留段、IPv4 映射的 IPv6 等）
+ *   - 下载失败按配置跳过（保留原内容块）或报错
+ * 重要程度：⭐⭐⭐ 一般（多模态兼容）
+ * 依赖模块：无
+ */
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ImageInlineOptions 图片 URL 内联选项（由全局配置生成，每个请求一份）
+type ImageInlineOptions struct {
+	MaxSize       int64         // 单张图片大小上限（字节）
+	Timeout       time.Duration // 单张图片下载超时
+	SkipOnFailure bool          // 下载失败时保留原内容块继续转发（否则请求失败）
+	MaxImages     int           // 单个请求最多下载的图片数（不同 URL 计数，0 表示不限制）
+
+	// 下载结果缓存：同一请求重试或换账户时不重复下载（请求副本共享同一份选项）
+	// 锁只保护缓存，下载在锁外进行；同一 URL 并发获取时等待首个下载完成
+	mu        sync.Mutex
+	images    map[string]*inlinedImage
+	downloads int // 已发起的网络下载数
+}
+
+// inlinedImage 单张图片的下载结果，done 关闭后结果可读
+type inlinedImage struct {
+	done      chan struct{}
+	mediaType string
+	data      string
+	err       error
+}
+
+// supportedImageTypes Claude 支持的图片类型
+var supportedImageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// imageDownloadClient 图片下载客户端（不走账户代理，禁止连接内网地址）
+var imageDownloadClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy: nil,
+		DialContext: (&net.Dialer{
+			Timeout: 10 * time.Second,
+			Control: denyPrivateAddress,
+		}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 3 {
+			return fmt.Errorf("too many redirects")
+		}
+		return nil
+	},
+}
+
+// disallowedImagePrefixes 非公网地址段，图片下载禁止连接
+var disallowedImagePrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // 本网络
+	netip.MustParsePrefix("10.0.0.0/8"),      // 内网
+	netip.MustParsePrefix("100.64.0.0/10"),   // 运营商级 NAT（CGNAT）
+	netip.MustParsePrefix("127.0.0.0/8"),     // 回环
+	netip.MustParsePrefix("169.254.0.0/16"),  // 链路本地（含云厂商元数据地址）
+	netip.MustParsePrefix("172.16.0.0/12"),   // 内网
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF 协议分配
+	netip.MustParsePrefix("192.0.2.0/24"),    // 文档示例
+	netip.MustParsePrefix("192.88.99.0/24"),  // 6to4 中继
+	netip.MustParsePrefix("192.168.0.0/16"),  // 内网
+	netip.MustParsePrefix("198.18.0.0/15"),   // 网络设备测试
+	netip.MustParsePrefix("198.51.100.0/24"), // 文档示例
+	netip.MustParsePrefix("203.0.113.0/24"),  // 文档示例
+	netip.MustParsePrefix("224.0.0.0/4"),     // 组播
+	netip.MustParsePrefix("240.0.0.0/4"),     // 保留（含广播）
+	netip.MustParsePrefix("::/128"),          // 未指定
+	netip.MustParsePrefix("::1/128"),         // 回环
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64（可映射到内网 IPv4）
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // 本地 NAT64
+	netip.MustParsePrefix("100::/64"),        // 丢弃
+	netip.MustParsePrefix("2001::/23"),       // IETF 协议分配（含 Teredo）
+	netip.MustParsePrefix("2001:db8::/32"),   // 文档示例
+	netip.MustParsePrefix("2002::/16"),       // 6to4（可内嵌内网 IPv4）
+	netip.MustParsePrefix("fc00::/7"),        // 唯一本地地址
+	netip.MustParsePrefix("fe80::/10"),       // 链路本地
+	netip.MustParsePrefix("ff00::/8"),        // 组播
+}
+
+// denyPrivateAddress 拒绝连接非公网地址，防止借图片 URL 探测内网
+// 在建立连接前按实际解析出的地址检查，DNS 重绑定也无法绕过
+func denyPrivateAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || isDisallowedImageAddr(addr) {
+		return fmt.Errorf("image url resolves to a disallowed address: %s", host)
+	}
+	return nil
+}
+
+// isDisallowedImageAddr 判断地址是否不是公网单播地址（IPv4 映射的 IPv6 按 IPv4 判断）
+func isDisallowedImageAddr(addr netip.Addr) bool {
+	addr = addr.Unmap().WithZone("")
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return true
+	}
+	for _, prefix := range disallowedImagePrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// InlineImageURLs 把请求体中的图片 URL 内容块改写为 base64 内联图片，没有图片 URL 时原样返回
+func InlineImageURLs(ctx context.Context, body []byte, opts *ImageInlineOptions) ([]byte, error) {
+	if opts == nil || !bytes.Contains(body, []byte(`"url"`)) && !bytes.Contains(body, []byte(`"image_url"`)) {
+		return body, nil
+	}
+
+	var req map[string]interface{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return body, nil
+	}
+	messages, ok := req["messages"].([]interface{})
+	if !ok {
+		return body, nil
+	}
+
+	changed := false
+	for _, msg := range messages {
+		msgMap, ok := msg.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		content, ok := msgMap["content"].([]interface{})
+		if !ok {
+			continue
+		}
+		blockChanged, err := inlineContentBlocks(ctx, content, opts)
+		if err != nil {
+			return nil, err
+		}
+		changed = changed || blockChanged
+	}
+
+	if !changed {
+		return body, nil
+	}
+	return json.Marshal(req)
+}
+
+// inlineContentBlocks 原地改写内容块数组中的图片 URL 块（tool_result 的 content 递归处理一层）
+func inlineContentBlocks(ctx context.Context, content []interface{}, opts *ImageInlineOptions) (bool, error) {
+	changed := false
+	for i, block := range content {
+		blockMap, ok := block.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if blockMap["type"] == "tool_result" {
+			if nested, ok := blockMap["content"].([]interface{}); ok {
+				nestedChanged, err := inlineContentBlocks(ctx, nested, opts)
+				if err != nil {
+					return false, err
+				}
+				changed = changed || nestedChanged
+			}
+			continue
+		}
+
+		url := imageBlockURL(blockMap)
+		if url == "" {
+			continue
+		}
+
+		mediaType, data, err := opts.fetch(ctx, url)
+		if err != nil {
+			if opts.SkipOnFailure {
+				continue
+			}
+			return false, NewUpstreamError(http.StatusBadRequest, fmt.Sprintf("image download failed: %v", err))
+		}
+
+		content[i] = map[string]interface{}{
+			"type": "image",
+			"source": map[string]interface{}{
+				"type":       "base64",
+				"media_type": mediaType,
+				"data":       data,
+			},
+		}
+		if cacheControl, ok := blockMap["cache_control"]; ok {
+			content[i].(map[string]interface{})["cache_control"] = cacheControl
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+// fetch 获取图片（带缓存），返回媒体类型和 base64 数据
+func (o *ImageInlineOptions) fetch(ctx context.Context, url string) (string, string, error) {
+	o.mu.Lock()
+	if image, ok := o.images[url]; ok {
+		o.mu.Unlock()
+		select {
+		case <-image.done:
+			return image.mediaType, image.data, image.err
+		case <-ctx.Done():
+			return "", "", ctx.Err()
+		}
+	}
+	if o.images == nil {
+		o.images = make(map[string]*inlinedImage)
+	}
+	image := &inlinedImage{done: make(chan struct{})}
+	o.images[url] = image
+	// data: URL 不发起网络请求，不计入下载数
+	overLimit := false
+	if !strings.HasPrefix(url, "data:") {
+		overLimit = o.MaxImages > 0 && o.downloads >= o.MaxImages
+		if !overLimit {
+			o.downloads++
+		}
+	}
+	o.mu.Unlock()
+
+	if overLimit {
+		image.err = fmt.Errorf("too many image urls: at most %d per request", o.MaxImages)
+	} else {
+		image.mediaType, image.data, image.err = fetchImage(ctx, url, o)
+	}
+	close(image.done)
+	return image.mediaType, image.data, image.err
+}
+
+// imageBlockURL 返回图片 URL 内容块的地址，不是图片 URL 块返回空
+// 支持 {"type":"image","source":{"type":"url","url":...}} 和 {"type":"image_url","image_url":{"url":...}}
+func imageBlockURL(block map[string]interface{}) string {
+	switch block["type"] {
+	case "image":
+		source, ok := block["source"].(map[string]interface{})
+		if !ok || source["type"] != "url" {
+			return ""
+		}
+		url, _ := source["url"].(string)
+		return url
+	case "image_url":
+		switch v := block["image_url"].(type) {
+		case string:
+			return v
+		case map[string]interface{}:
+			url, _ := v["url"].(string)
+			return url
+		}
+	}
+	return ""
+}
+
+// fetchImage 获取图片并返回媒体类型和 base64 数据
+func fetchImage(ctx context.Context, url string, opts *ImageInlineOptions) (string, string, error) {
+	if strings.HasPrefix(url, "data:") {
+		return decodeDataURL(url)
+	}
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return "", "", fmt.Errorf("unsupported image url scheme")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", "", err
+	}
+	resp, err := imageDownloadClient.Do(httpReq)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	if resp.ContentLength > opts.MaxSize {
+		return "", "", fmt.Errorf("image too large: %d bytes", resp.ContentLength)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, opts.MaxSize+1))
+	if err != nil {
+		return "", "", err
+	}
+	if int64(len(data)) > opts.MaxSize {
+		return "", "", fmt.Errorf("image too large: exceeds %d bytes", opts.MaxSize)
+	}
+
+	mediaType := imageMediaType(resp.Header.Get("Content-Type"), data)
+	if !supportedImageTypes[mediaType] {
+		return "", "", fmt.Errorf("unsupported content type: %s", mediaType)
+	}
+	return mediaType, base64.StdEncoding.EncodeToString(data), nil
+}
+
+// imageMediaType 优先使用响应头的类型，缺失或为通用二进制类型时按内容嗅探
+func imageMediaType(contentType string, data []byte) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == "" || mediaType == "application/octet-stream" {
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(data))
+	}
+	return strings.ToLower(mediaType)
+}
+
+// decodeDataURL 解析 data:image/png;base64,xxx 形式的内联图片
+func decodeDataURL(url string) (string, string, error) {
+	meta, data, ok := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+	if !ok || !strings.HasSuffix(meta, ";base64") {
+		return "", "", fmt.Errorf("invalid data url")
+	}
+	mediaType := strings.ToLower(strings.TrimSuffix(meta, ";base64"))
+	if !supportedImageTypes[mediaType] {
+		return "", "", fmt.Errorf("unsupported content type: %s", mediaType)
+	}
+	return mediaType, data, nil
+}
@@ -0,0 +1,53 @@
+package adapter
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsDisallowedImageAddr(t *testing.T) {
+	disallowed := []string{
+		"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254",
+		"100.64.0.1", "100.127.255.254", // CGNAT
+		"198.18.0.1", "198.19.255.255", // 网络设备测试
+		"0.0.0.0", "0.1.2.3", "224.0.0.1", "255.255.255.255",
+		"::", "::1", "fc00::1", "fd12:3456::1", "fe80::1", "ff02::1",
+		"::ffff:127.0.0.1", "::ffff:10.0.0.1", "::ffff:100.64.0.1", // IPv4 映射的 IPv6
+		"64:ff9b::a00:1", "2002:a00:1::1",
+	}
+	for _, s := range disallowed {
+		if !isDisallowedImageAddr(netip.MustParseAddr(s)) {
+			t.Errorf("isDisallowedImageAddr(%s) = false, want true", s)
+		}
+	}
+
+	allowed := []string{"8.8.8.8", "1.1.1.1", "100.128.0.1", "198.20.0.1", "2606:4700:4700::1111", "::ffff:8.8.8.8"}
+	for _, s := range allowed {
+		if isDisallowedImageAddr(netip.MustParseAddr(s)) {
+			t.Errorf("isDisallowedImageAddr(%s) = true, want false", s)
+		}
+	}
+}
+
+func TestInlineImageURLsMaxImages(t *testing.T) {
+	body := []byte(`{"messages":[{"role":"user","content":[` +
+		`{"type":"image","source":{"type":"url","url":"http://127.0.0.1:1/a.png"}},` +
+		`{"type":"image","source":{"type":"url","url":"http://127.0.0.1:1/b.png"}}]}]}`)
+	opts := &ImageInlineOptions{MaxSize: 1 << 20, Timeout: time.Second, SkipOnFailure: true, MaxImages: 1}
+
+	if _, err := InlineImageURLs(context.Background(), body, opts); err != nil {
+		t.Fatalf("InlineImageURLs() error = %v", err)
+	}
+	if err := opts.images["http://127.0.0.1:1/a.png"].err; err == nil || !strings.Contains(err.Error(), "disallowed address") {
+		t.Fatalf("第一张图片错误 = %v, want 内网地址被拒绝", err)
+	}
+	if err := opts.images["http://127.0.0.1:1/b.png"].err; err == nil || !strings.Contains(err.Error(), "too many image urls") {
+		t.Fatalf("第二张图片错误 = %v, want 超出单请求图片数", err)
+	}
+	if opts.downloads != 1 {
+		t.Fatalf("downloads = %d, want 1", opts.downloads)
+	}
+}
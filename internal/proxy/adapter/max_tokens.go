@@ -0,0 +1,102 @@
+/*
+ * 文件作用：请求输出 token 上限的识别和改写，防止单请求 max_tokens 过大产生巨额费用
+ * 负责功能：
+ *   - 识别 Claude / OpenAI Chat / OpenAI Responses / Gemini 请求体中的输出上限字段
+ *   - 超过上限时定点改写为上限值（其余内容和字段顺序不变）
+ * 重要程度：⭐⭐⭐ 一般（费用保护）
+ * 依赖模块：无
+ */
+package adapter
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// 请求体格式（输出上限字段位置不同）
+const (
+	RequestFormatClaude    = "claude"    // Claude Messages: max_tokens
+	RequestFormatOpenAI    = "openai"    // OpenAI Chat Completions: max_tokens / max_completion_tokens
+	RequestFormatResponses = "responses" // OpenAI Responses: max_output_tokens
+	RequestFormatGemini    = "gemini"    // Gemini: generationConfig.maxOutputTokens，/gemini/v1/chat 的 max_tokens
+)
+
+// maxTokensFields 各格式顶层的输出上限字段
+var maxTokensFields = map[string][]string{
+	RequestFormatClaude:    {"max_tokens"},
+	RequestFormatOpenAI:    {"max_tokens", "max_completion_tokens"},
+	RequestFormatResponses: {"max_output_tokens"},
+	RequestFormatGemini:    {"max_tokens"},
+}
+
+// Gemini 的输出上限在 generationConfig 内（兼容 snake_case 写法）
+var (
+	geminiGenerationConfigKeys = []string{"generationConfig", "generation_config"}
+	geminiMaxTokensKeys        = []string{"maxOutputTokens", "max_output_tokens"}
+)
+
+// RequestedMaxTokens 返回请求体中的输出上限（多个字段取最大值），未设置返回 0
+func RequestedMaxTokens(body []byte, format string) int {
+	requested := maxTokensValue(body, maxTokensFields[format])
+	if format == RequestFormatGemini {
+		for _, configKey := range geminiGenerationConfigKeys {
+			if config := BodyField(body, configKey); config != nil {
+				if v := maxTokensValue(config, geminiMaxTokensKeys); v > requested {
+					requested = v
+				}
+			}
+		}
+	}
+	return requested
+}
+
+// ClampMaxTokens 把超过 limit 的输出上限字段改写为 limit，没有超限字段时原样返回
+func ClampMaxTokens(body []byte, format string, limit int) []byte {
+	if format == RequestFormatGemini {
+		for _, configKey := range geminiGenerationConfigKeys {
+			config := BodyField(body, configKey)
+			if config != nil && maxTokensValue(config, geminiMaxTokensKeys) > limit {
+				body = ReplaceBodyField(body, configKey, clampFields(config, geminiMaxTokensKeys, limit))
+			}
+		}
+	}
+	return clampFields(body, maxTokensFields[format], limit)
+}
+
+// maxTokensValue 返回 keys 中最大的数值字段值
+func maxTokensValue(obj []byte, keys []string) int {
+	requested := 0
+	for _, key := range keys {
+		if v := intField(obj, key); v > requested {
+			requested = v
+		}
+	}
+	return requested
+}
+
+// clampFields 把 keys 中超过 limit 的字段改写为 limit
+func clampFields(obj []byte, keys []string, limit int) []byte {
+	value := []byte(strconv.Itoa(limit))
+	for _, key := range keys {
+		if intField(obj, key) > limit {
+			obj = ReplaceBodyField(obj, key, value)
+		}
+	}
+	return obj
+}
+
+// intField 读取顶层数值字段，不存在或不是数值返回 0
+func intField(obj []byte, key string) int {
+	raw := BodyField(obj, key)
+	if raw == nil {
+		return 0
+	}
+	var v float64
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return 0
+	}
+	if v > float64(1<<31-1) {
+		return 1<<31 - 1
+	}
+	return int(v)
+}
@@ -4,6 +4,7 @@
  *   - OpenAI Chat Completions API 转发
  *   - 流式SSE响应处理
  *   - Usage数据解析（输入/输出Token）
+ *   - 限流响应头提取（x-ratelimit-*）
  *   - 错误响应处理
  * 重要程度：⭐⭐⭐⭐⭐ 核心（OpenAI平台核心适配器）
  * 依赖模块：model, logger, http_client
@@ -51,11 +52,15 @@ type openAIRequest struct {
 	TopP        float64         `json:"top_p,omitempty"`
 	Stream      bool            `json:"stream,omitempty"`
 	Stop        []string        `json:"stop,omitempty"`
+	Tools       []interface{}   `json:"tools,omitempty"`
+	ToolChoice  interface{}     `json:"tool_choice,omitempty"`
 }
 
 type openAIMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
 }
 
 // OpenAI 响应格式
@@ -81,7 +86,7 @@ type openAIResponse struct {
 }
 
 func (a *OpenAIAdapter) Send(ctx context.Context, account *model.Account, req *Request) (*Response, error) {
-	log := logger.GetLogger("proxy")
+	log := logger.GetLogger("proxy").Ctx(ctx)
 
 	// 构建 OpenAI 请求
 	openAIReq := a.convertRequest(req)
@@ -150,9 +155,11 @@ func (a *OpenAIAdapter) Send(ctx context.Context, account *model.Account, req *R
 
 	content := ""
 	stopReason := ""
+	var toolCalls []ToolCall
 	if len(openAIResp.Choices) > 0 {
 		content = openAIResp.Choices[0].Message.Content
 		stopReason = openAIResp.Choices[0].FinishReason
+		toolCalls = openAIResp.Choices[0].Message.ToolCalls
 	}
 
 	log.Info("OpenAI 请求成功 - Model: %s, InputTokens: %d, OutputTokens: %d",
@@ -163,13 +170,15 @@ func (a *OpenAIAdapter) Send(ctx context.Context, account *model.Account, req *R
 		Model:        openAIResp.Model,
 		Content:      content,
 		StopReason:   stopReason,
+		ToolCalls:    toolCalls,
 		InputTokens:  openAIResp.Usage.PromptTokens,
 		OutputTokens: openAIResp.Usage.CompletionTokens,
+		Headers:      ExtractRateLimitHeaders(resp.Header),
 	}, nil
 }
 
 func (a *OpenAIAdapter) SendStream(ctx context.Context, account *model.Account, req *Request, writer io.Writer) (*StreamResult, error) {
-	log := logger.GetLogger("proxy")
+	log := logger.GetLogger("proxy").Ctx(ctx)
 
 	// 构建 OpenAI 请求
 	openAIReq := a.convertRequest(req)
@@ -207,16 +216,18 @@ func (a *OpenAIAdapter) SendStream(ctx context.Context, account *model.Account,
 		return nil, err
 	}
 	defer resp.Body.Close()
+	// 上游压缩的流先解压，SSE 解析和转发只处理明文
+	DecodeResponseBody(resp)
 
 	if resp.StatusCode != http.StatusOK {
 		respBody, _ := ReadResponseBody(resp)
 		log.Error("OpenAI Stream API 错误 - StatusCode: %d, Body: %s", resp.StatusCode, string(respBody))
-		return nil, NewUpstreamError(resp.StatusCode, string(respBody))
+		return nil, NewUpstreamErrorWithBody(resp.StatusCode, respBody)
 	}
 
 	log.Debug("OpenAI Stream 响应状态码: %d, 开始接收流式数据", resp.StatusCode)
 
-	result := &StreamResult{}
+	result := &StreamResult{Headers: ExtractRateLimitHeaders(resp.Header)}
 	scanner := bufio.NewScanner(resp.Body)
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -227,6 +238,7 @@ func (a *OpenAIAdapter) SendStream(ctx context.Context, account *model.Account,
 		data := strings.TrimPrefix(line, "data: ")
 		if data == "[DONE]" {
 			log.Debug("OpenAI Stream 接收完成")
+			result.Completed = true
 			break
 		}
 
@@ -235,6 +247,11 @@ func (a *OpenAIAdapter) SendStream(ctx context.Context, account *model.Account,
 			continue
 		}
 
+		// 收到 finish_reason 视为正常结束（部分兼容上游不发送 [DONE]）
+		if len(chunk.Choices) > 0 && chunk.Choices[0].FinishReason != "" {
+			result.Completed = true
+		}
+
 		// 解析 usage（OpenAI 流式响应最后一个 chunk 可能包含 usage）
 		if chunk.Usage.PromptTokens > 0 {
 			result.InputTokens = chunk.Usage.PromptTokens
@@ -243,11 +260,19 @@ func (a *OpenAIAdapter) SendStream(ctx context.Context, account *model.Account,
 			result.OutputTokens = chunk.Usage.CompletionTokens
 		}
 
-		// 直接转发 OpenAI 格式
-		writer.Write([]byte(line + "\n\n"))
+		// 直接转发 OpenAI 格式，客户端断开时立即中断（defer 关闭上游连接）
+		if _, writeErr := writer.Write([]byte(line + "\n\n")); writeErr != nil {
+			log.Warn("OpenAI Stream 写入客户端失败，中断上游读取: %v", writeErr)
+			return result, clientWriteError(writeErr)
+		}
 	}
 
 	if err := scanner.Err(); err != nil {
+		// 检查是否是因为 context 取消导致的错误
+		if ctx.Err() != nil {
+			log.Info("OpenAI Stream 因 context 取消而结束: %v", ctx.Err())
+			return result, ctx.Err()
+		}
 		log.Error("OpenAI Stream 读取错误: %v", err)
 		return result, err
 	}
@@ -283,8 +308,10 @@ func (a *OpenAIAdapter) convertRequest(req *Request) *openAIRequest {
 			}
 		}
 		messages = append(messages, openAIMessage{
-			Role:    msg.Role,
-			Content: content,
+			Role:       msg.Role,
+			Content:    content,
+			ToolCalls:  msg.ToolCalls,
+			ToolCallID: msg.ToolCallID,
 		})
 	}
 
@@ -296,5 +323,7 @@ func (a *OpenAIAdapter) convertRequest(req *Request) *openAIRequest {
 		TopP:        req.TopP,
 		Stream:      req.Stream,
 		Stop:        req.Stop,
+		Tools:       req.Tools,
+		ToolChoice:  req.ToolChoice,
 	}
 }
@@ -76,7 +76,7 @@ type OpenAIResponsesUsageHeaders struct {
 
 // Send 非流式请求（OpenAI Responses API 强制使用流式，这里收集完整响应）
 func (a *OpenAIResponsesAdapter) Send(ctx context.Context, account *model.Account, req *Request) (*Response, error) {
-	log := logger.GetLogger("openai-responses")
+	log := logger.GetLogger("openai-responses").Ctx(ctx)
 
 	// OpenAI Responses API 强制使用流式，我们需要收集完整响应
 	var buf bytes.Buffer
@@ -105,7 +105,7 @@ func (a *OpenAIResponsesAdapter) Send(ctx context.Context, account *model.Accoun
 // SendStream 流式请求
 // 参考 claude-relay 的 openaiResponsesRelayService.js 实现
 func (a *OpenAIResponsesAdapter) SendStream(ctx context.Context, account *model.Account, req *Request, writer io.Writer) (*StreamResult, error) {
-	log := logger.GetLogger("openai-responses")
+	log := logger.GetLogger("openai-responses").Ctx(ctx)
 
 	// 构建目标 URL: baseURL + path
 	// 类似 claude-relay: const targetUrl = `${fullAccount.baseApi}${req.path}`
@@ -178,10 +178,10 @@ func (a *OpenAIResponsesAdapter) SendStream(ctx context.Context, account *model.
 	}
 
 	// 设置请求头
-	a.setRequestHeaders(httpReq, account, req)
+	authKind := a.setRequestHeaders(httpReq, account, req)
 
-	log.Debug("OpenAI Responses 请求开始 - URL: %s, AccountID: %d, Model: %s",
-		targetURL, account.ID, req.Model)
+	log.Debug("OpenAI Responses 请求开始 - URL: %s, AccountID: %d, Model: %s, AuthKind: %s",
+		targetURL, account.ID, req.Model, authKind)
 
 	// 发送请求 - 使用流式 HTTP 客户端（10分钟超时）
 	// chatgpt.com 使用 Chrome TLS
@@ -199,6 +199,8 @@ func (a *OpenAIResponsesAdapter) SendStream(ctx context.Context, account *model.
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
+	// 上游压缩的流先解压，SSE 解析和转发只处理明文
+	DecodeResponseBody(resp)
 
 	// 处理错误响应
 	if resp.StatusCode != http.StatusOK {
@@ -223,25 +225,15 @@ func (a *OpenAIResponsesAdapter) SendStream(ctx context.Context, account *model.
 	return a.processStreamResponse(ctx, resp, writer, log)
 }
 
-// setRequestHeaders 设置请求头
+// setRequestHeaders 设置请求头，返回本次使用的认证凭证类型
 // 参考 claude-relay 的 openaiResponsesRelayService.js
-func (a *OpenAIResponsesAdapter) setRequestHeaders(httpReq *http.Request, account *model.Account, req *Request) {
+func (a *OpenAIResponsesAdapter) setRequestHeaders(httpReq *http.Request, account *model.Account, req *Request) string {
 	// 基本头部
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("Accept", "text/event-stream")
 
-	// 认证头：按优先级选择 token
-	// 1. SessionKey - 用于 Cookie/Session 认证
-	// 2. AccessToken - OAuth 流程获取的 access_token
-	// 3. APIKey - 长期有效的 API 密钥
-	authToken := ""
-	if account.SessionKey != "" {
-		authToken = account.SessionKey
-	} else if account.AccessToken != "" {
-		authToken = account.AccessToken
-	} else if account.APIKey != "" {
-		authToken = account.APIKey
-	}
+	// 认证头：SessionKey > AccessToken > APIKey，与健康检查共用 GetAuthToken
+	authToken, authKind := account.GetAuthToken()
 	httpReq.Header.Set("Authorization", "Bearer "+authToken)
 
 	// 透传客户端头部
@@ -285,13 +277,14 @@ func (a *OpenAIResponsesAdapter) setRequestHeaders(httpReq *http.Request, accoun
 			httpReq.Header.Set("chatgpt-account-id", account.OrganizationID)
 		}
 	}
+	return authKind
 }
 
 // handleErrorResponse 处理错误响应
 func (a *OpenAIResponsesAdapter) handleErrorResponse(resp *http.Response, account *model.Account, log *logger.Logger) (*StreamResult, error) {
 	respBody, _ := ReadResponseBody(resp)
 	log.Error("OpenAI Responses API 错误 - StatusCode: %d, Body: %s", resp.StatusCode, string(respBody))
-	return nil, NewUpstreamError(resp.StatusCode, string(respBody))
+	return nil, NewUpstreamErrorWithBody(resp.StatusCode, respBody)
 }
 
 // extractUsageHeaders 提取使用量头部
@@ -386,6 +379,7 @@ func (a *OpenAIResponsesAdapter) processStreamResponse(ctx context.Context, resp
 
 			if data == "[DONE]" {
 				log.Debug("OpenAI Responses Stream 接收完成")
+				result.Completed = true
 				// 转发 [DONE] 给客户端
 				_, writeErr := writer.Write([]byte(line + "\n\n"))
 				if writeErr != nil {
@@ -400,8 +394,13 @@ func (a *OpenAIResponsesAdapter) processStreamResponse(ctx context.Context, resp
 			// 尝试解析 usage 信息
 			var event map[string]interface{}
 			if err := json.Unmarshal([]byte(data), &event); err == nil {
+				// response.completed / response.incomplete 为上游正常终止事件
+				eventType, _ := event["type"].(string)
+				if eventType == "response.completed" || eventType == "response.incomplete" {
+					result.Completed = true
+				}
 				// 检查是否是 response.completed 事件（包含 usage）
-				if eventType, ok := event["type"].(string); ok && eventType == "response.completed" {
+				if eventType == "response.completed" {
 					if response, ok := event["response"].(map[string]interface{}); ok {
 						if usage, ok := response["usage"].(map[string]interface{}); ok {
 							if inputTokens, ok := usage["input_tokens"].(float64); ok {
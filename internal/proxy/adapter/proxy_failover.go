@@ -0,0 +1,319 @@
+/*
+ * 文件作用：代理故障转移，主代理建连失败时自动切换到备用代理
+ * 负责功能：
+ *   - 记录每组主备代理当前生效的节点
+ *   - 建连失败时切换到下一个备用代理并重试请求
+ *   - 请求阶段网络错误时由重试层主动切换代理（确认是代理还是账户问题）
+ *   - Chrome TLS 客户端的主备代理拨号
+ *   - 主代理恢复探测与切回（由健康检查调用）
+ * 重要程度：⭐⭐⭐⭐ 重要（代理高可用）
+ * 依赖模块：model, logger
+ */
+package adapter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-aiproxy/internal/model"
+	"go-aiproxy/pkg/logger"
+)
+
+// proxyFailoverState 一组主备代理的切换状态
+type proxyFailoverState struct {
+	mu         sync.Mutex
+	urls       []string  // [0] 为主代理，其余为备用代理
+	active     int       // 当前生效的代理下标
+	switchedAt time.Time // 最近一次由重试层主动切换的时间
+}
+
+// proxySwitchDebounce 重试层主动切换代理的最小间隔，同一节点抖动时并发失败的请求只切换一次
+const proxySwitchDebounce = 5 * time.Second
+
+var (
+	// 主备代理状态（key: 代理链路 URL 拼接）
+	failoverStates sync.Map
+	// 故障转移客户端缓存（key: [stream:]代理链路）
+	failoverClientCache sync.Map
+)
+
+// getFailoverState 获取代理链路的切换状态（不存在则创建）
+func getFailoverState(urls []string) *proxyFailoverState {
+	key := strings.Join(urls, "|")
+	if value, ok := failoverStates.Load(key); ok {
+		return value.(*proxyFailoverState)
+	}
+	value, _ := failoverStates.LoadOrStore(key, &proxyFailoverState{urls: urls})
+	return value.(*proxyFailoverState)
+}
+
+// current 当前生效的代理
+func (s *proxyFailoverState) current() (int, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.active, s.urls[s.active]
+}
+
+// markFailed 标记代理建连失败，切换到下一个节点
+// 只有失败的正是当前生效代理时才切换，避免并发请求重复跳过节点
+func (s *proxyFailoverState) markFailed(idx int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if idx != s.active {
+		return
+	}
+	s.active = (s.active + 1) % len(s.urls)
+
+	log := logger.GetLogger("proxy")
+	log.Warn("代理建连失败，切换代理: %s -> %s", maskProxyURL(s.urls[idx]), maskProxyURL(s.urls[s.active]))
+}
+
+// isProxyDialError 判断是否为建连阶段的错误（此时请求尚未发出，可安全换代理重试）
+func isProxyDialError(err error) bool {
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) {
+		return false
+	}
+	return opErr.Op == "dial" || opErr.Op == "proxyconnect" || strings.HasPrefix(opErr.Op, "socks")
+}
+
+// IsProxyNetworkError 判断是否为可能由代理引起的网络错误（没有收到上游 HTTP 响应：建连、TLS 握手、连接重置、读超时等）
+// 上游返回了 HTTP 状态码的错误不算，说明代理链路是通的
+func IsProxyNetworkError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var upstreamErr *UpstreamError
+	if errors.As(err, &upstreamErr) {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	errStr := strings.ToLower(err.Error())
+	for _, keyword := range []string{
+		"proxyconnect",
+		"connection reset",
+		"connection refused",
+		"broken pipe",
+		"tls handshake",
+		"unexpected eof",
+		"i/o timeout",
+		"socks",
+	} {
+		if strings.Contains(errStr, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// SwitchAccountProxy 把账户的代理链路切换到下一个节点，返回切换前后的代理（已隐藏认证信息）
+// 账户没有配置备用代理时返回 false；其他请求刚切换过时不再切换，直接使用当前节点
+func SwitchAccountProxy(account *model.Account) (from, to string, ok bool) {
+	chain := getProxyChain(account)
+	if len(chain) < 2 {
+		return "", "", false
+	}
+	state := getFailoverState(chain)
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	prev := state.active
+	if time.Since(state.switchedAt) >= proxySwitchDebounce {
+		state.active = (state.active + 1) % len(state.urls)
+		state.switchedAt = time.Now()
+	}
+	return maskProxyURL(state.urls[prev]), maskProxyURL(state.urls[state.active]), true
+}
+
+// getProxyChain 获取账户代理链路（主代理 + 备用代理），无代理返回 nil
+func getProxyChain(account *model.Account) []string {
+	proxyURL := GetEffectiveProxy(account)
+	if proxyURL == "" {
+		return nil
+	}
+	return append([]string{proxyURL}, account.Proxy.GetFallbackURLs()...)
+}
+
+// failoverTransport 主备代理 RoundTripper
+type failoverTransport struct {
+	state     *proxyFailoverState
+	streaming bool
+	timeouts  clientTimeouts
+}
+
+// RoundTrip 使用当前生效代理发送请求，建连失败时依次尝试其余代理
+func (t *failoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// 请求体无法重放时只尝试一次
+	canRetry := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	var lastErr error
+	for attempt := 0; attempt < len(t.state.urls); attempt++ {
+		idx, proxyURL := t.state.current()
+
+		outReq := req
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			outReq = req.Clone(req.Context())
+			outReq.Body = body
+		}
+
+		resp, err := getOrCreateProxyClient(proxyURL, t.streaming, t.timeouts).Transport.RoundTrip(outReq)
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = err
+		if !canRetry || !isProxyDialError(err) || req.Context().Err() != nil {
+			return nil, err
+		}
+		t.state.markFailed(idx)
+	}
+	return nil, lastErr
+}
+
+// getOrCreateFailoverClient 获取或创建主备代理客户端（带缓存）
+func getOrCreateFailoverClient(urls []string, streaming bool, timeouts clientTimeouts) *http.Client {
+	cacheKey := strings.Join(urls, "|")
+	if streaming {
+		cacheKey = "stream:" + cacheKey
+	}
+	cacheKey = timeouts.cacheKey(cacheKey)
+	if client, ok := failoverClientCache.Load(cacheKey); ok {
+		return client.(*http.Client)
+	}
+
+	client := &http.Client{
+		Transport: &failoverTransport{state: getFailoverState(urls), streaming: streaming, timeouts: timeouts},
+		Timeout:   timeouts.totalTimeout(streaming),
+	}
+	actual, _ := failoverClientCache.LoadOrStore(cacheKey, client)
+	return actual.(*http.Client)
+}
+
+// ========== Chrome TLS 主备代理 ==========
+
+// NewProxyConfig 由代理配置构建 ProxyConfig（包含备用代理）
+func NewProxyConfig(p *model.Proxy) *ProxyConfig {
+	if p == nil {
+		return nil
+	}
+	cfg := &ProxyConfig{
+		Type:     p.Type,
+		Host:     p.Host,
+		Port:     p.Port,
+		Username: p.Username,
+		Password: p.Password,
+	}
+	for _, fallbackURL := range p.GetFallbackURLs() {
+		if fallback := parseProxyConfig(fallbackURL); fallback != nil {
+			cfg.Fallbacks = append(cfg.Fallbacks, fallback)
+		}
+	}
+	return cfg
+}
+
+// parseProxyConfig 解析代理 URL 为 ProxyConfig
+func parseProxyConfig(proxyURLStr string) *ProxyConfig {
+	u, err := url.Parse(proxyURLStr)
+	if err != nil || u.Hostname() == "" {
+		return nil
+	}
+	port, _ := strconv.Atoi(u.Port())
+	cfg := &ProxyConfig{
+		Type:   u.Scheme,
+		Host:   u.Hostname(),
+		Port:   port,
+		rawURL: proxyURLStr,
+	}
+	if u.User != nil {
+		cfg.Username = u.User.Username()
+		cfg.Password, _ = u.User.Password()
+	}
+	return cfg
+}
+
+// url 代理 URL（与 model.Proxy.GetURL 格式一致，便于与 HTTP 客户端共享切换状态）
+func (p *ProxyConfig) url() string {
+	if p.rawURL != "" {
+		return p.rawURL
+	}
+	if p.Username != "" && p.Password != "" {
+		return fmt.Sprintf("%s://%s:%s@%s:%d", p.Type, p.Username, p.Password, p.Host, p.Port)
+	}
+	return fmt.Sprintf("%s://%s:%d", p.Type, p.Host, p.Port)
+}
+
+// dialWithFailover 按主备顺序通过代理建立连接
+func dialWithFailover(network, addr string, proxyConfig *ProxyConfig) (net.Conn, error) {
+	chain := append([]*ProxyConfig{proxyConfig}, proxyConfig.Fallbacks...)
+	urls := make([]string, len(chain))
+	for i, cfg := range chain {
+		urls[i] = cfg.url()
+	}
+	state := getFailoverState(urls)
+
+	var lastErr error
+	for attempt := 0; attempt < len(chain); attempt++ {
+		idx, _ := state.current()
+		conn, err := dialSingleProxy(network, addr, chain[idx])
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+		state.markFailed(idx)
+	}
+	return nil, lastErr
+}
+
+// ========== 主代理恢复探测 ==========
+
+// ProbeProxyFailover 检查代理当前生效节点，主代理恢复后切回
+// 返回当前生效的代理下标：0 为主代理，n 为第 n 个备用代理
+func ProbeProxyFailover(p *model.Proxy) int {
+	fallbacks := p.GetFallbackURLs()
+	if len(fallbacks) == 0 {
+		return 0
+	}
+	state := getFailoverState(append([]string{p.GetURL()}, fallbacks...))
+
+	idx, _ := state.current()
+	if idx == 0 {
+		return 0
+	}
+
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", p.Host, p.Port), 5*time.Second)
+	if err != nil {
+		return idx
+	}
+	conn.Close()
+
+	state.mu.Lock()
+	state.active = 0
+	state.mu.Unlock()
+
+	log := logger.GetLogger("proxy")
+	log.Info("主代理已恢复，切回主代理: %s", maskProxyURL(p.GetURL()))
+	return 0
+}
+
+// maskProxyURL 隐藏代理 URL 中的认证信息
+func maskProxyURL(proxyURL string) string {
+	if u, err := url.Parse(proxyURL); err == nil {
+		return fmt.Sprintf("%s://%s", u.Scheme, u.Host)
+	}
+	return proxyURL
+}
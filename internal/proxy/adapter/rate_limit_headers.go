@@ -0,0 +1,101 @@
+/*
+ * 文件作用：OpenAI 兼容上游的限流响应头解析
+ * 负责功能：
+ *   - 提取 x-ratelimit-limit/remaining/reset-requests|tokens 响应头
+ *   - 解析为限流窗口快照（重置时间由相对时长换算为绝对时间）
+ * 重要程度：⭐⭐⭐ 一般（调度避开快耗尽的账户）
+ * 依赖模块：model
+ */
+package adapter
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-aiproxy/internal/model"
+)
+
+// OpenAI 限流响应头
+const (
+	headerRateLimitLimitRequests     = "x-ratelimit-limit-requests"
+	headerRateLimitRemainingRequests = "x-ratelimit-remaining-requests"
+	headerRateLimitResetRequests     = "x-ratelimit-reset-requests" // 距重置的时长，如 1s、6m0s、20ms
+	headerRateLimitLimitTokens       = "x-ratelimit-limit-tokens"
+	headerRateLimitRemainingTokens   = "x-ratelimit-remaining-tokens"
+	headerRateLimitResetTokens       = "x-ratelimit-reset-tokens"
+)
+
+var openAIRateLimitHeaders = []string{
+	headerRateLimitLimitRequests,
+	headerRateLimitRemainingRequests,
+	headerRateLimitResetRequests,
+	headerRateLimitLimitTokens,
+	headerRateLimitRemainingTokens,
+	headerRateLimitResetTokens,
+}
+
+// ExtractRateLimitHeaders 提取 OpenAI 限流响应头（键为小写）
+func ExtractRateLimitHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string)
+	for _, h := range openAIRateLimitHeaders {
+		if value := header.Get(h); value != "" {
+			headers[h] = value
+		}
+	}
+	return headers
+}
+
+// ParseRateLimitHeaders 从响应头解析限流窗口，没有任何限流头时返回 nil
+// headers 的键不区分大小写（兼容 ExtractRateLimitHeaders 的小写键和原始响应头的规范键）
+func ParseRateLimitHeaders(headers map[string]string, now time.Time) *model.RateLimitWindow {
+	header := make(http.Header, len(headers))
+	for k, v := range headers {
+		header.Set(k, v)
+	}
+
+	window := &model.RateLimitWindow{
+		LimitRequests:     parseRateLimitInt(header.Get(headerRateLimitLimitRequests)),
+		RemainingRequests: parseRateLimitInt(header.Get(headerRateLimitRemainingRequests)),
+		ResetRequestsAt:   parseRateLimitReset(header.Get(headerRateLimitResetRequests), now),
+		LimitTokens:       parseRateLimitInt(header.Get(headerRateLimitLimitTokens)),
+		RemainingTokens:   parseRateLimitInt(header.Get(headerRateLimitRemainingTokens)),
+		ResetTokensAt:     parseRateLimitReset(header.Get(headerRateLimitResetTokens), now),
+		UpdatedAt:         now,
+	}
+	if window.RemainingRequests == nil && window.RemainingTokens == nil {
+		return nil
+	}
+	return window
+}
+
+// parseRateLimitInt 解析整数头，缺失或格式错误返回 nil
+func parseRateLimitInt(value string) *int {
+	if value == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return nil
+	}
+	return &n
+}
+
+// parseRateLimitReset 把距重置的时长（Go duration 格式或秒数）换算为绝对时间，缺失或格式错误返回 nil
+func parseRateLimitReset(value string, now time.Time) *time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil
+		}
+		d = time.Duration(seconds * float64(time.Second))
+	}
+	resetAt := now.Add(d)
+	return &resetAt
+}
@@ -0,0 +1,133 @@
+/*
+ * 文件作用：请求重放，把请求日志中保存的原始请求体用指定账户重新发往上游
+ * 负责功能：
+ *   - 按账户类型确定上游端点
+ *   - 复用各适配器的认证头逻辑（使用账户当前凭证，不使用日志里的头）
+ *   - 返回上游原始响应（状态码、响应头、响应体）
+ * 重要程度：⭐⭐ 辅助（排查调试）
+ * 依赖模块：model
+ */
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-aiproxy/internal/model"
+)
+
+// ErrReplayUnsupported 账户类型不支持重放
+var ErrReplayUnsupported = errors.New("account type does not support replay")
+
+// ReplayRequest 重放请求
+type ReplayRequest struct {
+	Body    []byte            // 原始请求体（客户端格式，原样发送）
+	Model   string            // 模型名（Gemini 等需要拼到 URL 中）
+	Headers map[string]string // 日志中的客户端请求头（已脱敏，只用于透传非认证头）
+}
+
+// ReplayResponse 上游原始响应
+type ReplayResponse struct {
+	StatusCode int               `json:"status_code"`
+	Headers    map[string]string `json:"headers"`
+	Body       string            `json:"body"`
+	Duration   int64             `json:"duration"` // 耗时(毫秒)
+}
+
+// Replay 用账户当前凭证重新发送请求并返回上游原始响应
+// 请求体原样发送，stream=true 时返回完整的 SSE 文本
+func Replay(ctx context.Context, account *model.Account, req *ReplayRequest) (*ReplayResponse, error) {
+	httpReq, client, err := newReplayHTTPRequest(ctx, account, req)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ReadResponseBody(resp)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	headers := make(map[string]string, len(resp.Header))
+	for key, values := range resp.Header {
+		headers[key] = strings.Join(values, ",")
+	}
+
+	return &ReplayResponse{
+		StatusCode: resp.StatusCode,
+		Headers:    headers,
+		Body:       string(body),
+		Duration:   time.Since(start).Milliseconds(),
+	}, nil
+}
+
+// newReplayHTTPRequest 按账户类型构建上游请求
+func newReplayHTTPRequest(ctx context.Context, account *model.Account, req *ReplayRequest) (*http.Request, *http.Client, error) {
+	var targetURL string
+	switch account.Type {
+	case model.AccountTypeClaudeOfficial, model.AccountTypeClaudeConsole:
+		baseURL := "https://api.anthropic.com"
+		if account.BaseURL != "" {
+			baseURL = account.BaseURL
+		}
+		targetURL = baseURL + "/v1/messages"
+	case model.AccountTypeOpenAI:
+		baseURL := "https://api.openai.com"
+		if account.BaseURL != "" {
+			baseURL = account.BaseURL
+		}
+		targetURL = baseURL + "/v1/chat/completions"
+	case model.AccountTypeAzureOpenAI:
+		targetURL = (&AzureOpenAIAdapter{}).buildURL(account)
+	case model.AccountTypeOpenAIResponses:
+		baseURL := DefaultOpenAIResponsesBaseURL
+		if account.BaseURL != "" {
+			baseURL = strings.TrimSuffix(account.BaseURL, "/")
+		}
+		targetURL = baseURL + "/responses"
+	case model.AccountTypeGemini, model.AccountTypeGeminiAPI:
+		targetURL = (&GeminiAdapter{}).buildURL(account, req.Model, false)
+	default:
+		return nil, nil, fmt.Errorf("%w: %s", ErrReplayUnsupported, account.Type)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", targetURL, bytes.NewReader(req.Body))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client := GetHTTPClient(account)
+	switch account.Type {
+	case model.AccountTypeClaudeOfficial, model.AccountTypeClaudeConsole:
+		(&ClaudeAdapter{}).setHeaders(httpReq, account, req.Headers)
+	case model.AccountTypeOpenAI:
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("Authorization", "Bearer "+account.APIKey)
+	case model.AccountTypeAzureOpenAI:
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("api-key", account.APIKey)
+	case model.AccountTypeOpenAIResponses:
+		(&OpenAIResponsesAdapter{}).setRequestHeaders(httpReq, account, &Request{Headers: req.Headers})
+		if NeedsChromeTLS(targetURL) {
+			client = GetChromeTLSClient(account)
+		} else {
+			client = GetStreamHTTPClient(account)
+		}
+	case model.AccountTypeGemini, model.AccountTypeGeminiAPI:
+		httpReq.Header.Set("Content-Type", "application/json")
+		setGeminiAuth(httpReq, account)
+	}
+
+	return httpReq, client, nil
+}
@@ -0,0 +1,196 @@
+/*
+ * 文件作用：透传请求体/响应体工具，定点读取和修改原始 JSON
+ * 负责功能：
+ *   - 定位顶层字段的值（只扫描顶层，不整体解析请求体）
+ *   - 改写顶层 model 字段（账户模型映射、模型回退）
+ *   - 读取/替换/新增任意顶层字段（embeddings 响应的 usage、Claude system 注入等）
+ * 重要程度：⭐⭐⭐ 一般（透传模式下模型映射生效）
+ * 依赖模块：无
+ */
+package adapter
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// ReplaceBodyModel 将请求体顶层 model 字段改写为 modelName
+// 只扫描顶层定位 model 的值后拼接，messages 很大时也无需整体解析再序列化，其余内容和字段顺序保持不变
+// 请求体不是 JSON 对象或没有顶层 model 字段时原样返回
+func ReplaceBodyModel(body []byte, modelName string) []byte {
+	value, err := json.Marshal(modelName)
+	if err != nil {
+		return body
+	}
+	return ReplaceBodyField(body, "model", value)
+}
+
+// ReplaceBodyField 将顶层字段的值替换为 value（合法的 JSON 值），字段不存在时原样返回
+func ReplaceBodyField(body []byte, key string, value []byte) []byte {
+	start, end, ok := findTopLevelField(body, key)
+	if !ok {
+		return body
+	}
+
+	out := make([]byte, 0, len(body)-(end-start)+len(value))
+	out = append(out, body[:start]...)
+	out = append(out, value...)
+	return append(out, body[end:]...)
+}
+
+// SetBodyField 设置顶层字段：字段存在时替换值，不存在时插入到对象开头
+// 请求体不是 JSON 对象时原样返回
+func SetBodyField(body []byte, key string, value []byte) []byte {
+	if _, _, ok := findTopLevelField(body, key); ok {
+		return ReplaceBodyField(body, key, value)
+	}
+
+	i := skipJSONSpace(body, 0)
+	if i >= len(body) || body[i] != '{' {
+		return body
+	}
+	name, err := json.Marshal(key)
+	if err != nil {
+		return body
+	}
+
+	rest := skipJSONSpace(body, i+1)
+	out := make([]byte, 0, len(body)+len(name)+len(value)+2)
+	out = append(out, body[:i+1]...)
+	out = append(out, name...)
+	out = append(out, ':')
+	out = append(out, value...)
+	if rest < len(body) && body[rest] != '}' {
+		out = append(out, ',')
+	}
+	return append(out, body[i+1:]...)
+}
+
+// BodyField 返回顶层字段的原始 JSON 值，字段不存在返回 nil
+func BodyField(body []byte, key string) []byte {
+	start, end, ok := findTopLevelField(body, key)
+	if !ok {
+		return nil
+	}
+	return body[start:end]
+}
+
+// findTopLevelField 返回顶层字段值在 body 中的起止位置 [start, end)
+// 重复的键取最后一个（与 encoding/json 解析结果一致）
+func findTopLevelField(body []byte, key string) (start, end int, found bool) {
+	i := skipJSONSpace(body, 0)
+	if i >= len(body) || body[i] != '{' {
+		return 0, 0, false
+	}
+	i++
+	for {
+		i = skipJSONSpace(body, i)
+		if i >= len(body) || body[i] != '"' {
+			return start, end, found
+		}
+		keyEnd := skipJSONString(body, i)
+		if keyEnd < 0 {
+			return 0, 0, false
+		}
+		rawKey := body[i:keyEnd]
+
+		i = skipJSONSpace(body, keyEnd)
+		if i >= len(body) || body[i] != ':' {
+			return 0, 0, false
+		}
+		i = skipJSONSpace(body, i+1)
+		valueEnd := skipJSONValue(body, i)
+		if valueEnd < 0 {
+			return 0, 0, false
+		}
+		if jsonKeyEquals(rawKey, key) {
+			start, end, found = i, valueEnd, true
+		}
+
+		i = skipJSONSpace(body, valueEnd)
+		if i >= len(body) || body[i] != ',' {
+			return start, end, found
+		}
+		i++
+	}
+}
+
+// jsonKeyEquals 比较带引号的原始键名，含转义字符时解码后比较
+func jsonKeyEquals(rawKey []byte, key string) bool {
+	if bytes.IndexByte(rawKey, '\\') < 0 {
+		return string(rawKey[1:len(rawKey)-1]) == key
+	}
+	var decoded string
+	if err := json.Unmarshal(rawKey, &decoded); err != nil {
+		return false
+	}
+	return decoded == key
+}
+
+// skipJSONSpace 跳过空白字符
+func skipJSONSpace(body []byte, i int) int {
+	for i < len(body) {
+		switch body[i] {
+		case ' ', '\t', '\n', '\r':
+			i++
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// skipJSONString 跳过从 i 开始的字符串（body[i] 为引号），返回结束引号之后的位置，格式错误返回 -1
+func skipJSONString(body []byte, i int) int {
+	for j := i + 1; j < len(body); j++ {
+		switch body[j] {
+		case '\\':
+			j++
+		case '"':
+			return j + 1
+		}
+	}
+	return -1
+}
+
+// skipJSONValue 跳过从 i 开始的任意 JSON 值，返回值之后的位置，格式错误返回 -1
+func skipJSONValue(body []byte, i int) int {
+	if i >= len(body) {
+		return -1
+	}
+	switch body[i] {
+	case '"':
+		return skipJSONString(body, i)
+	case '{', '[':
+		depth := 0
+		for j := i; j < len(body); j++ {
+			switch body[j] {
+			case '"':
+				next := skipJSONString(body, j)
+				if next < 0 {
+					return -1
+				}
+				j = next - 1
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return j + 1
+				}
+			}
+		}
+		return -1
+	default:
+		// 数字、true/false/null
+		j := i
+		for j < len(body) {
+			switch body[j] {
+			case ',', '}', ']', ' ', '\t', '\n', '\r':
+				return j
+			}
+			j++
+		}
+		return j
+	}
+}
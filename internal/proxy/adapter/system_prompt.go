@@ -0,0 +1,107 @@
+/*
+ * 文件作用：Claude 请求的 system prompt 注入，把账户 / API Key 配置的前缀、后缀合并到 system 字段
+ * 负责功能：
+ *   - 按作用范围合并账户级和 API Key 级配置（账户配置在最外层）
+ *   - 兼容 system 缺失、字符串、text 内容块数组三种形式
+ *   - 估算注入内容的 token，上游未返回 usage 时计入输入 token
+ * 重要程度：⭐⭐⭐ 一般（请求定制）
+ * 依赖模块：model
+ */
+package adapter
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"go-aiproxy/internal/model"
+)
+
+// SystemPromptOptions system prompt 注入选项（由全局开关、作用范围和 API Key 配置生成，每个请求一份，nil 表示不注入）
+type SystemPromptOptions struct {
+	Prefix         string // API Key 级前缀
+	Suffix         string // API Key 级后缀
+	IncludeAccount bool   // 是否叠加账户级前缀/后缀
+}
+
+// Resolve 合并账户级和 API Key 级配置：账户前缀在最前、账户后缀在最后
+func (o *SystemPromptOptions) Resolve(account *model.Account) (prefix, suffix string) {
+	if o == nil {
+		return "", ""
+	}
+	prefix, suffix = o.Prefix, o.Suffix
+	if o.IncludeAccount && account != nil {
+		prefix = joinSystemText(strings.TrimSpace(account.SystemPromptPrefix), prefix)
+		suffix = joinSystemText(suffix, strings.TrimSpace(account.SystemPromptSuffix))
+	}
+	return prefix, suffix
+}
+
+// systemTextBlock system 内容块数组中的 text 块
+type systemTextBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// InjectSystemPrompt 把前缀/后缀合并到 Claude 请求体的 system 字段
+// system 缺失时新建字符串；为字符串时首尾拼接；为内容块数组时在首尾插入 text 块（原有块及 cache_control 保持不变）
+// 前缀后缀都为空、system 为其他类型或无法解析时原样返回
+func InjectSystemPrompt(body []byte, prefix, suffix string) []byte {
+	prefix, suffix = strings.TrimSpace(prefix), strings.TrimSpace(suffix)
+	if prefix == "" && suffix == "" {
+		return body
+	}
+
+	var value interface{}
+	raw := bytes.TrimSpace(BodyField(body, "system"))
+	switch {
+	case len(raw) == 0 || bytes.Equal(raw, []byte("null")):
+		value = joinSystemText(prefix, suffix)
+	case raw[0] == '"':
+		var text string
+		if err := json.Unmarshal(raw, &text); err != nil {
+			return body
+		}
+		value = joinSystemText(prefix, text, suffix)
+	case raw[0] == '[':
+		var blocks []json.RawMessage
+		if err := json.Unmarshal(raw, &blocks); err != nil {
+			return body
+		}
+		merged := make([]interface{}, 0, len(blocks)+2)
+		if prefix != "" {
+			merged = append(merged, systemTextBlock{Type: "text", Text: prefix})
+		}
+		for _, block := range blocks {
+			merged = append(merged, block)
+		}
+		if suffix != "" {
+			merged = append(merged, systemTextBlock{Type: "text", Text: suffix})
+		}
+		value = merged
+	default:
+		return body
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return body
+	}
+	return SetBodyField(body, "system", encoded)
+}
+
+// SystemPromptTokens 估算注入内容的 token 数
+func SystemPromptTokens(modelName, prefix, suffix string) int {
+	return EstimateTextTokens(modelName, strings.TrimSpace(prefix)) + EstimateTextTokens(modelName, strings.TrimSpace(suffix))
+}
+
+// joinSystemText 用空行连接非空片段
+func joinSystemText(parts ...string) string {
+	nonEmpty := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part != "" {
+			nonEmpty = append(nonEmpty, part)
+		}
+	}
+	return strings.Join(nonEmpty, "\n\n")
+}
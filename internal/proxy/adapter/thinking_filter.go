@@ -0,0 +1,359 @@
+/*
+ * 文件作用：流式响应思考内容过滤，客户端只需要最终答案时隐藏 thinking / reasoning
+ * 负责功能：
+ *   - 按 SSE 事件边界缓冲，不完整的事件等边界到齐再处理
+ *   - Claude：过滤 thinking / redacted_thinking 内容块，重排后续内容块索引
+ *   - OpenAI Chat：移除 delta 中的 reasoning_content / reasoning
+ *   - OpenAI Responses：过滤 reasoning 输出项和 response.reasoning_* 事件，重排输出项索引
+ * 重要程度：⭐⭐⭐ 一般（下游客户端兼容）
+ * 依赖模块：无
+ */
+package adapter
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// 流式响应格式，决定如何识别思考内容
+const (
+	StreamFormatClaude    = "claude"    // Claude Messages
+	StreamFormatOpenAI    = "openai"    // OpenAI Chat Completions（含转换为 OpenAI 格式的 Gemini 流）
+	StreamFormatResponses = "responses" // OpenAI Responses
+)
+
+// openAIReasoningFields OpenAI 兼容流 delta 中的推理内容字段
+var openAIReasoningFields = []string{"reasoning_content", "reasoning"}
+
+// ThinkingFilterWriter 过滤流式响应中的思考内容，只把最终答案转发给客户端
+// 只改变发给客户端的数据，adapter 仍从上游原始数据解析 token 用于计费
+type ThinkingFilterWriter struct {
+	w      io.Writer
+	format string
+	buf    []byte // 尚未到达事件边界的数据
+
+	dropped map[int]bool // 已过滤的内容块 / 输出项原始索引
+	shifted map[int]int  // 保留的内容块 / 输出项原始索引 -> 新索引
+	removed int          // 已过滤的内容块 / 输出项数量
+}
+
+// NewThinkingFilterWriter 创建思考内容过滤写入器
+func NewThinkingFilterWriter(w io.Writer, format string) *ThinkingFilterWriter {
+	f := &ThinkingFilterWriter{w: w, format: format}
+	f.reset()
+	return f
+}
+
+// reset 新消息开始时重置索引状态
+func (f *ThinkingFilterWriter) reset() {
+	f.dropped = make(map[int]bool)
+	f.shifted = make(map[int]int)
+	f.removed = 0
+}
+
+// Write 实现 io.Writer 接口，只转发完整的事件
+func (f *ThinkingFilterWriter) Write(p []byte) (int, error) {
+	f.buf = append(f.buf, p...)
+	for {
+		end := sseEventEnd(f.buf)
+		if end < 0 {
+			break
+		}
+		out := f.filterEvent(f.buf[:end])
+		if len(out) > 0 {
+			if _, err := f.w.Write(out); err != nil {
+				f.buf = f.buf[end:]
+				return len(p), err
+			}
+		}
+		f.buf = f.buf[end:]
+	}
+	// 返回原始长度，避免调用者认为写入不完整
+	return len(p), nil
+}
+
+// Flush 实现 http.Flusher 接口（如果底层 writer 支持）
+func (f *ThinkingFilterWriter) Flush() {
+	if fl, ok := f.w.(interface{ Flush() }); ok {
+		fl.Flush()
+	}
+}
+
+// Close 流结束时原样写出剩余的不完整数据
+func (f *ThinkingFilterWriter) Close() {
+	if len(f.buf) > 0 {
+		f.w.Write(f.buf)
+		f.buf = nil
+		f.Flush()
+	}
+}
+
+// sseEventEnd 返回第一个完整事件（含结尾空行）的长度，未到达边界返回 -1
+func sseEventEnd(buf []byte) int {
+	lf := bytes.Index(buf, []byte("\n\n"))
+	crlf := bytes.Index(buf, []byte("\r\n\r\n"))
+	switch {
+	case lf < 0 && crlf < 0:
+		return -1
+	case crlf < 0 || (lf >= 0 && lf < crlf):
+		return lf + 2
+	default:
+		return crlf + 4
+	}
+}
+
+// filterEvent 过滤单个事件，返回 nil 表示丢弃；无法识别的事件原样返回
+func (f *ThinkingFilterWriter) filterEvent(event []byte) []byte {
+	data, ok := sseEventData(event)
+	if !ok || data == "[DONE]" {
+		return event
+	}
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(data), &payload); err != nil {
+		return event
+	}
+
+	var keep, changed bool
+	switch f.format {
+	case StreamFormatClaude:
+		keep, changed = f.filterClaudeEvent(payload)
+	case StreamFormatOpenAI:
+		keep, changed = filterOpenAIChunk(payload)
+	case StreamFormatResponses:
+		keep, changed = f.filterResponsesEvent(payload)
+	default:
+		return event
+	}
+	if !keep {
+		return nil
+	}
+	if !changed {
+		return event
+	}
+	newData, err := json.Marshal(payload)
+	if err != nil {
+		return event
+	}
+	return rebuildSSEEvent(event, newData)
+}
+
+// filterClaudeEvent 过滤 Claude thinking 内容块
+func (f *ThinkingFilterWriter) filterClaudeEvent(payload map[string]json.RawMessage) (keep, changed bool) {
+	switch rawString(payload["type"]) {
+	case "message_start":
+		f.reset()
+	case "content_block_start":
+		index, ok := rawInt(payload["index"])
+		if !ok {
+			return true, false
+		}
+		var block struct {
+			Type string `json:"type"`
+		}
+		json.Unmarshal(payload["content_block"], &block)
+		if block.Type == "thinking" || block.Type == "redacted_thinking" {
+			f.drop(index)
+			return false, false
+		}
+		return true, f.reindex(payload, "index", index)
+	case "content_block_delta", "content_block_stop":
+		index, ok := rawInt(payload["index"])
+		if !ok {
+			return true, false
+		}
+		if f.dropped[index] {
+			return false, false
+		}
+		return true, f.reindex(payload, "index", index)
+	}
+	return true, false
+}
+
+// filterResponsesEvent 过滤 OpenAI Responses 的 reasoning 输出项
+func (f *ThinkingFilterWriter) filterResponsesEvent(payload map[string]json.RawMessage) (keep, changed bool) {
+	eventType := rawString(payload["type"])
+	if strings.HasPrefix(eventType, "response.reasoning") {
+		return false, false
+	}
+
+	switch eventType {
+	case "response.created":
+		f.reset()
+		return true, stripReasoningOutput(payload)
+	case "response.in_progress", "response.completed", "response.incomplete", "response.failed":
+		return true, stripReasoningOutput(payload)
+	}
+
+	index, ok := rawInt(payload["output_index"])
+	if !ok {
+		return true, false
+	}
+	if eventType == "response.output_item.added" || eventType == "response.output_item.done" {
+		var item struct {
+			Type string `json:"type"`
+		}
+		json.Unmarshal(payload["item"], &item)
+		if item.Type == "reasoning" {
+			if !f.dropped[index] {
+				f.drop(index)
+			}
+			return false, false
+		}
+	}
+	if f.dropped[index] {
+		return false, false
+	}
+	return true, f.reindex(payload, "output_index", index)
+}
+
+// filterOpenAIChunk 移除 OpenAI 兼容流 delta 中的推理内容，只剩推理内容的 chunk 整个丢弃
+func filterOpenAIChunk(payload map[string]json.RawMessage) (keep, changed bool) {
+	var choices []map[string]json.RawMessage
+	if err := json.Unmarshal(payload["choices"], &choices); err != nil || len(choices) == 0 {
+		return true, false
+	}
+
+	hasContent := !rawIsEmpty(payload["usage"])
+	for _, choice := range choices {
+		if !rawIsEmpty(choice["finish_reason"]) {
+			hasContent = true
+		}
+		var delta map[string]json.RawMessage
+		if err := json.Unmarshal(choice["delta"], &delta); err != nil {
+			hasContent = true
+			continue
+		}
+		removed := false
+		for _, field := range openAIReasoningFields {
+			if _, ok := delta[field]; ok {
+				delete(delta, field)
+				removed = true
+			}
+		}
+		if removed {
+			choice["delta"], _ = json.Marshal(delta)
+			changed = true
+		}
+		for _, value := range delta {
+			if !rawIsEmpty(value) {
+				hasContent = true
+			}
+		}
+	}
+	if !changed {
+		return true, false
+	}
+	if !hasContent {
+		return false, false
+	}
+	payload["choices"], _ = json.Marshal(choices)
+	return true, true
+}
+
+// stripReasoningOutput 移除 response.output 中的 reasoning 输出项
+func stripReasoningOutput(payload map[string]json.RawMessage) bool {
+	var resp map[string]json.RawMessage
+	if err := json.Unmarshal(payload["response"], &resp); err != nil {
+		return false
+	}
+	var output []json.RawMessage
+	if err := json.Unmarshal(resp["output"], &output); err != nil || len(output) == 0 {
+		return false
+	}
+	kept := make([]json.RawMessage, 0, len(output))
+	for _, item := range output {
+		var meta struct {
+			Type string `json:"type"`
+		}
+		json.Unmarshal(item, &meta)
+		if meta.Type != "reasoning" {
+			kept = append(kept, item)
+		}
+	}
+	if len(kept) == len(output) {
+		return false
+	}
+	resp["output"], _ = json.Marshal(kept)
+	payload["response"], _ = json.Marshal(resp)
+	return true
+}
+
+// drop 记录被过滤的索引
+func (f *ThinkingFilterWriter) drop(index int) {
+	f.dropped[index] = true
+	f.removed++
+}
+
+// reindex 将索引字段改为过滤后的新索引，返回是否有修改
+func (f *ThinkingFilterWriter) reindex(payload map[string]json.RawMessage, key string, index int) bool {
+	newIndex, ok := f.shifted[index]
+	if !ok {
+		newIndex = index - f.removed
+		f.shifted[index] = newIndex
+	}
+	if newIndex == index {
+		return false
+	}
+	payload[key] = json.RawMessage(strconv.Itoa(newIndex))
+	return true
+}
+
+// sseEventData 提取事件的 data 内容（多行 data 按换行拼接）
+func sseEventData(event []byte) (string, bool) {
+	var lines []string
+	for _, line := range strings.Split(string(event), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.HasPrefix(line, "data:") {
+			lines = append(lines, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if len(lines) == 0 {
+		return "", false
+	}
+	return strings.Join(lines, "\n"), true
+}
+
+// rebuildSSEEvent 用新的 data 替换事件中的 data 行，保留 event / id 等其他字段
+func rebuildSSEEvent(event []byte, data []byte) []byte {
+	var out bytes.Buffer
+	for _, line := range strings.Split(string(event), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if line == "" || strings.HasPrefix(line, "data:") {
+			continue
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	out.WriteString("data: ")
+	out.Write(data)
+	out.WriteString("\n\n")
+	return out.Bytes()
+}
+
+// rawString 解析 JSON 字符串，失败返回空
+func rawString(raw json.RawMessage) string {
+	var s string
+	json.Unmarshal(raw, &s)
+	return s
+}
+
+// rawInt 解析 JSON 整数
+func rawInt(raw json.RawMessage) (int, bool) {
+	if len(raw) == 0 {
+		return 0, false
+	}
+	var n int
+	if err := json.Unmarshal(raw, &n); err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// rawIsEmpty JSON 值是否为空（缺失、null 或空字符串）
+func rawIsEmpty(raw json.RawMessage) bool {
+	s := strings.TrimSpace(string(raw))
+	return s == "" || s == "null" || s == `""`
+}
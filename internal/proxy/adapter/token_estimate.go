@@ -0,0 +1,207 @@
+/*
+ * 文件作用：本地 token 估算，上游流式响应不返回 usage 时作为计费兜底
+ * 负责功能：
+ *   - 内置轻量近似分词器（单词按字符数折算，中日韩字符按字计，标点按个计）
+ *   - 按模型家族选择近似编码（o200k / cl100k / Claude / Gemini）
+ *   - 请求体输入 token 估算（消息文本、工具定义，图片按固定值近似）
+ * 重要程度：⭐⭐⭐ 一般（计费兜底）
+ * 依赖模块：无
+ */
+package adapter
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// TokenEncoding 近似编码参数（不同模型家族的词表对英文和中日韩文字的压缩率不同）
+type TokenEncoding struct {
+	Name             string
+	CharsPerToken    float64 // 单词（字母/数字串）平均每 token 字符数
+	CJKTokensPerChar float64 // 中日韩字符平均每字 token 数
+}
+
+// 内置近似编码（按公开分词器对中英文语料的平均压缩率取值）
+var (
+	EncodingO200k  = TokenEncoding{Name: "o200k_base", CharsPerToken: 4.2, CJKTokensPerChar: 0.8}  // gpt-4o / o 系列 / gpt-4.1 / gpt-5
+	EncodingCL100k = TokenEncoding{Name: "cl100k_base", CharsPerToken: 4.0, CJKTokensPerChar: 1.3} // gpt-4 / gpt-3.5 / embedding
+	EncodingClaude = TokenEncoding{Name: "claude", CharsPerToken: 3.5, CJKTokensPerChar: 1.2}
+	EncodingGemini = TokenEncoding{Name: "gemini", CharsPerToken: 4.0, CJKTokensPerChar: 0.9}
+)
+
+const (
+	// messageTokenOverhead 每条消息的格式开销（角色、分隔符）
+	messageTokenOverhead = 4
+	// imageTokenEstimate 图片无法在本地计算，按每张约 800 token 近似（约 768x768 的图片）
+	imageTokenEstimate = 800
+)
+
+// EncodingForModel 按模型名选择近似编码，无法识别时使用 o200k
+func EncodingForModel(modelName string) TokenEncoding {
+	name := strings.ToLower(modelName)
+	switch {
+	case strings.Contains(name, "claude"):
+		return EncodingClaude
+	case strings.Contains(name, "gemini"):
+		return EncodingGemini
+	case strings.Contains(name, "gpt-4o"), strings.Contains(name, "gpt-4.1"), strings.Contains(name, "gpt-5"),
+		strings.HasPrefix(name, "o1"), strings.HasPrefix(name, "o3"), strings.HasPrefix(name, "o4"),
+		strings.HasPrefix(name, "chatgpt-"), strings.HasPrefix(name, "codex"):
+		return EncodingO200k
+	case strings.Contains(name, "gpt-4"), strings.Contains(name, "gpt-3.5"), strings.Contains(name, "embedding"):
+		return EncodingCL100k
+	}
+	return EncodingO200k
+}
+
+// TokenCounter 增量 token 计数器，文本可以分多次追加（流式 delta 跨块的单词会合并计算）
+type TokenCounter struct {
+	enc    TokenEncoding
+	tokens float64
+	word   int // 尚未结束的单词长度
+}
+
+// NewTokenCounter 创建指定编码的计数器
+func NewTokenCounter(enc TokenEncoding) *TokenCounter {
+	return &TokenCounter{enc: enc}
+}
+
+// Add 追加文本
+func (c *TokenCounter) Add(text string) {
+	for _, r := range text {
+		if isWordRune(r) {
+			c.word++
+			continue
+		}
+		c.flushWord()
+		switch {
+		case unicode.IsSpace(r):
+			// 空白通常并入后一个单词的 token
+		case isCJKRune(r):
+			c.tokens += c.enc.CJKTokensPerChar
+		default:
+			c.tokens++
+		}
+	}
+}
+
+// AddTokens 直接累加 token（图片、消息开销等无法按文本计算的部分）
+func (c *TokenCounter) AddTokens(n int) {
+	c.tokens += float64(n)
+}
+
+// Count 返回当前估算的 token 数
+func (c *TokenCounter) Count() int {
+	return int(math.Ceil(c.tokens + c.wordTokens()))
+}
+
+// flushWord 结束当前单词并计入 token
+func (c *TokenCounter) flushWord() {
+	c.tokens += c.wordTokens()
+	c.word = 0
+}
+
+// wordTokens 当前单词折算的 token 数（常见短词通常是 1 个 token，按四舍五入折算，至少 1 个）
+func (c *TokenCounter) wordTokens() float64 {
+	if c.word == 0 {
+		return 0
+	}
+	return math.Max(1, math.Round(float64(c.word)/c.enc.CharsPerToken))
+}
+
+// isWordRune 字母和数字（不含中日韩文字）组成单词
+func isWordRune(r rune) bool {
+	return (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_') && !isCJKRune(r)
+}
+
+// isCJKRune 中日韩文字按字计算
+func isCJKRune(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+// EstimateTextTokens 按模型估算一段文本的 token 数
+func EstimateTextTokens(modelName, text string) int {
+	counter := NewTokenCounter(EncodingForModel(modelName))
+	counter.Add(text)
+	return counter.Count()
+}
+
+// requestSkipKeys 估算输入 token 时跳过的字段（不送入模型的元数据、base64 数据和签名）
+var requestSkipKeys = map[string]bool{
+	"model": true, "stream": true, "stream_options": true, "metadata": true,
+	"type": true, "role": true, "id": true, "tool_use_id": true, "tool_call_id": true, "call_id": true,
+	"media_type": true, "mime_type": true, "mimeType": true, "data": true, "url": true, "file_id": true,
+	"fileUri": true, "cache_control": true, "signature": true, "encrypted_content": true,
+	"generationConfig": true, "safetySettings": true,
+}
+
+// requestMessageKeys 消息数组字段（Claude/OpenAI messages、Gemini contents、Responses input）
+var requestMessageKeys = map[string]bool{"messages": true, "contents": true, "input": true}
+
+// EstimateRequestTokens 按请求体估算输入 token（Claude / OpenAI / Gemini / Responses 格式通用）
+// 统计所有送入模型的字符串内容和工具定义，图片按固定值近似，每条消息加格式开销
+func EstimateRequestTokens(modelName string, body []byte) int {
+	var req interface{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return 0
+	}
+	counter := NewTokenCounter(EncodingForModel(modelName))
+	countRequestValue(counter, "", req)
+	return counter.Count()
+}
+
+// countRequestValue 递归统计请求体中的文本内容
+func countRequestValue(counter *TokenCounter, key string, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		counter.Add(v)
+		counter.flushWord() // 不同字段的文本不连成一个单词
+	case []interface{}:
+		for _, item := range v {
+			if requestMessageKeys[key] {
+				counter.AddTokens(messageTokenOverhead)
+			}
+			countRequestValue(counter, "", item)
+		}
+	case map[string]interface{}:
+		if isImageBlock(v) {
+			counter.AddTokens(imageTokenEstimate)
+			return
+		}
+		for k, item := range v {
+			if requestSkipKeys[k] {
+				continue
+			}
+			// 工具参数的属性名同样送入模型
+			if k == "properties" {
+				if props, ok := item.(map[string]interface{}); ok {
+					for name := range props {
+						counter.Add(name)
+						counter.flushWord()
+					}
+				}
+			}
+			countRequestValue(counter, k, item)
+		}
+	}
+}
+
+// isImageBlock 是否为图片内容块（Claude image、OpenAI image_url / input_image、Gemini inlineData / fileData）
+func isImageBlock(block map[string]interface{}) bool {
+	switch block["type"] {
+	case "image", "image_url", "input_image":
+		return true
+	}
+	if inline, ok := block["inlineData"].(map[string]interface{}); ok {
+		mimeType, _ := inline["mimeType"].(string)
+		return strings.HasPrefix(mimeType, "image/")
+	}
+	if file, ok := block["fileData"].(map[string]interface{}); ok {
+		mimeType, _ := file["mimeType"].(string)
+		return strings.HasPrefix(mimeType, "image/")
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+/*
+ * 文件作用：流式响应输出 token 估算，透传数据的同时累计生成内容的 token
+ * 负责功能：
+ *   - 按 SSE 事件边界解析（不缓冲转发，只缓冲解析用的不完整事件）
+ *   - Claude：text / thinking / tool input 增量
+ *   - OpenAI Chat（含 Gemini 转换流）：content / reasoning / tool_calls 增量
+ *   - OpenAI Responses：response.*.delta 文本增量
+ * 重要程度：⭐⭐⭐ 一般（计费兜底）
+ * 依赖模块：无
+ */
+package adapter
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// UsageEstimateWriter 透传流式响应并估算生成内容的 token 数，上游不返回 usage 时用于兜底计费
+type UsageEstimateWriter struct {
+	w       io.Writer
+	format  string
+	buf     []byte // 尚未到达事件边界的数据（仅用于解析）
+	counter *TokenCounter
+}
+
+// NewUsageEstimateWriter 创建输出 token 估算写入器，format 为 StreamFormat* 常量
+func NewUsageEstimateWriter(w io.Writer, format, modelName string) *UsageEstimateWriter {
+	return &UsageEstimateWriter{
+		w:       w,
+		format:  format,
+		counter: NewTokenCounter(EncodingForModel(modelName)),
+	}
+}
+
+// Write 实现 io.Writer 接口，原样转发并解析完整事件
+func (u *UsageEstimateWriter) Write(p []byte) (int, error) {
+	n, err := u.w.Write(p)
+
+	u.buf = append(u.buf, p...)
+	for {
+		end := sseEventEnd(u.buf)
+		if end < 0 {
+			break
+		}
+		u.countEvent(u.buf[:end])
+		u.buf = u.buf[end:]
+	}
+	return n, err
+}
+
+// Flush 实现 http.Flusher 接口（如果底层 writer 支持）
+func (u *UsageEstimateWriter) Flush() {
+	if fl, ok := u.w.(interface{ Flush() }); ok {
+		fl.Flush()
+	}
+}
+
+// OutputTokens 返回已转发内容估算的输出 token 数
+func (u *UsageEstimateWriter) OutputTokens() int {
+	return u.counter.Count()
+}
+
+// countEvent 累计单个事件中的生成内容
+func (u *UsageEstimateWriter) countEvent(event []byte) {
+	data, ok := sseEventData(event)
+	if !ok || data == "[DONE]" {
+		return
+	}
+
+	switch u.format {
+	case StreamFormatClaude:
+		var payload struct {
+			Type  string `json:"type"`
+			Delta struct {
+				Text        string `json:"text"`
+				Thinking    string `json:"thinking"`
+				PartialJSON string `json:"partial_json"`
+			} `json:"delta"`
+		}
+		if json.Unmarshal([]byte(data), &payload) != nil || payload.Type != "content_block_delta" {
+			return
+		}
+		u.counter.Add(payload.Delta.Text)
+		u.counter.Add(payload.Delta.Thinking)
+		u.counter.Add(payload.Delta.PartialJSON)
+	case StreamFormatOpenAI:
+		var payload struct {
+			Choices []struct {
+				Delta struct {
+					Content          string `json:"content"`
+					ReasoningContent string `json:"reasoning_content"`
+					Reasoning        string `json:"reasoning"`
+					ToolCalls        []struct {
+						Function struct {
+							Name      string `json:"name"`
+							Arguments string `json:"arguments"`
+						} `json:"function"`
+					} `json:"tool_calls"`
+				} `json:"delta"`
+			} `json:"choices"`
+		}
+		if json.Unmarshal([]byte(data), &payload) != nil {
+			return
+		}
+		for _, choice := range payload.Choices {
+			u.counter.Add(choice.Delta.Content)
+			u.counter.Add(choice.Delta.ReasoningContent)
+			u.counter.Add(choice.Delta.Reasoning)
+			for _, call := range choice.Delta.ToolCalls {
+				u.counter.Add(call.Function.Name)
+				u.counter.Add(call.Function.Arguments)
+			}
+		}
+	case StreamFormatResponses:
+		var payload struct {
+			Type  string          `json:"type"`
+			Delta json.RawMessage `json:"delta"`
+		}
+		if json.Unmarshal([]byte(data), &payload) != nil || !strings.HasSuffix(payload.Type, ".delta") {
+			return
+		}
+		u.counter.Add(rawString(payload.Delta))
+	}
+}
@@ -0,0 +1,101 @@
+/*
+ * 文件作用：账户池调度，把池内多个同质账户当作一个逻辑账户参与调度
+ * 负责功能：
+ *   - 账户池缓存（随全量刷新重建）
+ *   - 池内账户套用池配置（可用模型、模型映射、Base URL、代理、并发上限），停用池内的账户不参与调度
+ *   - 池按自身优先级和权重参与两级调度，选中后按池策略在池内选账户
+ * 重要程度：⭐⭐⭐ 一般（大规模账户管理）
+ * 依赖模块：model
+ */
+package scheduler
+
+import (
+	"time"
+
+	"go-aiproxy/internal/model"
+	"go-aiproxy/pkg/logger"
+)
+
+// loadPools 从数据库重建账户池缓存（refreshAll 中调用，调用方持有 s.mu）
+// 加载失败时保留旧缓存
+func (s *Scheduler) loadPools() {
+	pools, err := s.poolRepo.GetAll()
+	if err != nil {
+		logger.GetLogger("scheduler").Warn("加载账户池失败: %v", err)
+		return
+	}
+
+	byID := make(map[uint]*model.AccountPool, len(pools))
+	for i := range pools {
+		byID[pools[i].ID] = &pools[i]
+	}
+	s.pools = byID
+
+	// 清理已删除的池的轮询计数
+	s.roundRobinMu.Lock()
+	for id := range s.poolRoundRobinWeights {
+		if byID[id] == nil {
+			delete(s.poolRoundRobinWeights, id)
+		}
+	}
+	s.roundRobinMu.Unlock()
+}
+
+// applyPools 为账户套用所在池的配置，并去掉停用池内的账户
+// 账户所在的池不在缓存中（刚创建、尚未刷新）时按账户自身配置调度
+func applyPools(accounts []model.Account, pools map[uint]*model.AccountPool) []model.Account {
+	if len(pools) == 0 {
+		return accounts
+	}
+
+	kept := accounts[:0]
+	for _, acc := range accounts {
+		if acc.PoolID != nil {
+			if pool := pools[*acc.PoolID]; pool != nil {
+				if !pool.Enabled {
+					continue
+				}
+				acc.ApplyPool(pool)
+			}
+		}
+		kept = append(kept, acc)
+	}
+	return kept
+}
+
+// withPools 为直接查库得到的账户套用账户池配置（按类型调度等不走平台缓存的路径）
+func (s *Scheduler) withPools(accounts []model.Account, err error) ([]model.Account, error) {
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	pools := s.pools
+	s.mu.RUnlock()
+	return applyPools(accounts, pools), nil
+}
+
+// poolWeight 池作为一个逻辑账户的调度权重：优先级 * 权重，负数按 0 处理
+// 池内账户的有效权重全为 0（临时不可用标记等）时池的权重也为 0，只作兜底
+func poolWeight(pool *model.AccountPool, accounts []*model.Account, now time.Time) int {
+	if pool.Priority <= 0 || pool.Weight <= 0 {
+		return 0
+	}
+	for _, acc := range accounts {
+		if effectiveWeight(acc, now) > 0 {
+			return pool.Priority * pool.Weight
+		}
+	}
+	return 0
+}
+
+// selectInPool 按池策略在池内选择账户（策略取值同账户分组）
+func (s *Scheduler) selectInPool(pool *model.AccountPool, accounts []*model.Account) *model.Account {
+	switch pool.Strategy {
+	case model.AccountGroupStrategyRoundRobin:
+		return s.selectRoundRobin(s.poolRoundRobinWeights, pool.ID, accounts)
+	case model.AccountGroupStrategyFailover:
+		return selectFailover(accounts)
+	default:
+		return s.selectByWeight(accounts)
+	}
+}
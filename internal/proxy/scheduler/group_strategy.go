@@ -0,0 +1,234 @@
+/*
+ * 文件作用：账户分组调度策略，命中设置了策略的分组时按分组策略在组内选账户
+ * 负责功能：
+ *   - 分组策略缓存（随全量刷新重建，只缓存非随机策略的分组）
+ *   - 两级调度：先按各组可用账户的权重之和选分组（账户池按池权重，见 account_pool.go），再按分组策略在组内选账户
+ *   - 加权轮询：平滑加权轮询，组内严格按权重比例分流（计数在实例内存中）
+ *   - 故障转移：组内按优先级从高到低，前面的账户不可用或已尝试过才用后面的
+ * 重要程度：⭐⭐⭐⭐ 重要（调度策略）
+ * 依赖模块：model
+ */
+package scheduler
+
+import (
+	"math/rand"
+	"time"
+
+	"go-aiproxy/internal/model"
+	"go-aiproxy/pkg/logger"
+)
+
+// groupPolicy 设置了调度策略的分组
+type groupPolicy struct {
+	id       uint
+	strategy string
+	members  map[uint]bool
+}
+
+// loadGroupPolicies 从数据库重建分组策略缓存（refreshAll 中调用，调用方持有 s.mu）
+// 加载失败时保留旧缓存
+func (s *Scheduler) loadGroupPolicies() {
+	groups, err := s.groupRepo.GetWithStrategy()
+	if err != nil {
+		logger.GetLogger("scheduler").Warn("加载账户分组策略失败: %v", err)
+		return
+	}
+
+	policies := make([]*groupPolicy, 0, len(groups))
+	alive := make(map[uint]bool, len(groups))
+	for _, g := range groups {
+		members := make(map[uint]bool, len(g.Accounts))
+		for _, acc := range g.Accounts {
+			members[acc.ID] = true
+		}
+		policies = append(policies, &groupPolicy{id: g.ID, strategy: g.Strategy, members: members})
+		alive[g.ID] = true
+	}
+	s.groupPolicies = policies
+
+	// 清理已删除或改为其他策略的分组的轮询计数
+	s.roundRobinMu.Lock()
+	for id := range s.roundRobinWeights {
+		if !alive[id] {
+			delete(s.roundRobinWeights, id)
+		}
+	}
+	s.roundRobinMu.Unlock()
+}
+
+// scheduleBucket 两级调度的第一级候选：账户池、策略分组或默认组（pool 和 policy 都为空）
+type scheduleBucket struct {
+	pool     *model.AccountPool
+	policy   *groupPolicy
+	accounts []*model.Account
+}
+
+// selectAccount 两级调度选择账户，没有账户池和设置策略的分组时等同 selectByWeight
+// 池内账户归入所在的池（见 account_pool.go）；其余账户归入第一个包含它的策略分组（按分组 ID），
+// 不在任何策略分组内的账户归入默认组（加权随机）
+// 分组按组内账户有效权重之和被选中，默认组与全局加权随机的概率一致；池按池自身的有效权重被选中
+func (s *Scheduler) selectAccount(accounts []*model.Account) *model.Account {
+	s.mu.RLock()
+	policies := s.groupPolicies
+	pools := s.pools
+	s.mu.RUnlock()
+
+	if (len(policies) == 0 && len(pools) == 0) || len(accounts) <= 1 {
+		return s.selectByWeight(accounts)
+	}
+
+	// 按池、分组归类，分组和默认组排在池之后
+	buckets := make([]*scheduleBucket, 0, len(policies)+1)
+	poolBuckets := make(map[uint]*scheduleBucket)
+	groupBuckets := make([]*scheduleBucket, len(policies)+1)
+	for i, p := range policies {
+		groupBuckets[i] = &scheduleBucket{policy: p}
+	}
+	groupBuckets[len(policies)] = &scheduleBucket{}
+
+	for _, acc := range accounts {
+		if acc.PoolID != nil {
+			if pool := pools[*acc.PoolID]; pool != nil {
+				b := poolBuckets[pool.ID]
+				if b == nil {
+					b = &scheduleBucket{pool: pool}
+					poolBuckets[pool.ID] = b
+					buckets = append(buckets, b)
+				}
+				b.accounts = append(b.accounts, acc)
+				continue
+			}
+		}
+		idx := len(policies)
+		for i, p := range policies {
+			if p.members[acc.ID] {
+				idx = i
+				break
+			}
+		}
+		groupBuckets[idx].accounts = append(groupBuckets[idx].accounts, acc)
+	}
+	for _, b := range groupBuckets {
+		if len(b.accounts) > 0 {
+			buckets = append(buckets, b)
+		}
+	}
+
+	b := pickBucket(buckets)
+	switch {
+	case b.pool != nil:
+		return s.selectInPool(b.pool, b.accounts)
+	case b.policy != nil:
+		return s.selectInGroup(b.policy, b.accounts)
+	default:
+		return s.selectByWeight(b.accounts)
+	}
+}
+
+// pickBucket 按有效权重随机选一个候选（调用方保证非空且每个候选都有账户）
+// 分组的权重为组内账户有效权重之和，池的权重见 poolWeight；权重全为 0 时分组按账户数、池按一个账户均匀选择
+func pickBucket(buckets []*scheduleBucket) *scheduleBucket {
+	now := time.Now()
+	weights := make([]int, len(buckets))
+	counts := make([]int, len(buckets))
+	totalWeight, totalCount := 0, 0
+	for i, b := range buckets {
+		if b.pool != nil {
+			weights[i] = poolWeight(b.pool, b.accounts, now)
+			counts[i] = 1
+		} else {
+			for _, acc := range b.accounts {
+				weights[i] += effectiveWeight(acc, now)
+			}
+			counts[i] = len(b.accounts)
+		}
+		totalWeight += weights[i]
+		totalCount += counts[i]
+	}
+
+	if totalWeight <= 0 {
+		r := rand.Intn(totalCount)
+		for i, b := range buckets {
+			r -= counts[i]
+			if r < 0 {
+				return b
+			}
+		}
+		return buckets[len(buckets)-1]
+	}
+
+	r := rand.Intn(totalWeight)
+	for i, b := range buckets {
+		r -= weights[i]
+		if r < 0 {
+			return b
+		}
+	}
+	return buckets[len(buckets)-1]
+}
+
+// selectInGroup 按分组策略在组内选择账户
+func (s *Scheduler) selectInGroup(policy *groupPolicy, accounts []*model.Account) *model.Account {
+	switch policy.strategy {
+	case model.AccountGroupStrategyRoundRobin:
+		return s.selectRoundRobin(s.roundRobinWeights, policy.id, accounts)
+	case model.AccountGroupStrategyFailover:
+		return selectFailover(accounts)
+	default:
+		return s.selectByWeight(accounts)
+	}
+}
+
+// selectRoundRobin 平滑加权轮询（与 nginx 一致）：每轮各账户累加自身权重，选累计值最大的账户并减去总权重
+// 权重 70/30 的两个账户每 10 次请求严格分到 7/3 次；权重为 0 的账户只在组内全为 0 时参与
+// counters 为分组或账户池的轮询计数表（调用方不持有 roundRobinMu），id 为分组或池 ID
+func (s *Scheduler) selectRoundRobin(counters map[uint]map[uint]int, id uint, accounts []*model.Account) *model.Account {
+	if len(accounts) == 1 {
+		return accounts[0]
+	}
+
+	now := time.Now()
+	weights := make([]int, len(accounts))
+	totalWeight := 0
+	for i, acc := range accounts {
+		weights[i] = effectiveWeight(acc, now)
+		totalWeight += weights[i]
+	}
+	if totalWeight <= 0 {
+		for i := range weights {
+			weights[i] = 1
+		}
+		totalWeight = len(weights)
+	}
+
+	s.roundRobinMu.Lock()
+	defer s.roundRobinMu.Unlock()
+
+	current := counters[id]
+	if current == nil {
+		current = make(map[uint]int)
+		counters[id] = current
+	}
+
+	var selected *model.Account
+	for i, acc := range accounts {
+		current[acc.ID] += weights[i]
+		if selected == nil || current[acc.ID] > current[selected.ID] {
+			selected = acc
+		}
+	}
+	current[selected.ID] -= totalWeight
+	return selected
+}
+
+// selectFailover 故障转移：选优先级最高的账户，优先级相同时选 ID 最小的
+// 调用方传入的是未尝试过的可用账户，前面的账户失败后重试自然落到下一个
+func selectFailover(accounts []*model.Account) *model.Account {
+	selected := accounts[0]
+	for _, acc := range accounts[1:] {
+		if acc.Priority > selected.Priority || (acc.Priority == selected.Priority && acc.ID < selected.ID) {
+			selected = acc
+		}
+	}
+	return selected
+}
@@ -0,0 +1,42 @@
+/*
+ * 文件作用：网络错误时切换代理重试，区分是代理节点故障还是账户本身不可用
+ * 负责功能：
+ *   - 请求因网络/连接错误失败且账户配置了备用代理时，切换到下一个代理节点
+ *   - 切换后在同一账户上重试一次，重试仍失败才按原有流程判定账户错误
+ *   - 每个请求每个账户最多切换一次，避免在代理链路上反复轮转
+ * 重要程度：⭐⭐⭐ 一般（代理高可用）
+ * 依赖模块：model, adapter, logger
+ */
+package scheduler
+
+import (
+	"context"
+
+	"go-aiproxy/internal/model"
+	"go-aiproxy/internal/proxy/adapter"
+	"go-aiproxy/pkg/logger"
+)
+
+// switchProxyForRetry 网络错误时切换账户代理，返回 true 表示已切换、应在同一账户上重试
+func (r *RetryableRequest) switchProxyForRetry(ctx context.Context, account *model.Account, err error) bool {
+	if r.proxySwitched[account.ID] || !adapter.IsProxyNetworkError(err) {
+		return false
+	}
+	from, to, ok := adapter.SwitchAccountProxy(account)
+	if !ok {
+		return false
+	}
+	if r.proxySwitched == nil {
+		r.proxySwitched = make(map[uint]bool)
+	}
+	r.proxySwitched[account.ID] = true
+
+	logger.GetLogger("scheduler").Ctx(ctx).WarnZ("网络错误，切换代理后同账户重试",
+		logger.Uint("account_id", account.ID),
+		logger.String("account_name", account.Name),
+		logger.String("from_proxy", from),
+		logger.String("to_proxy", to),
+		logger.Err(err),
+	)
+	return true
+}
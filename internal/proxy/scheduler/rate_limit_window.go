@@ -0,0 +1,87 @@
+/*
+ * 文件作用：上游限流窗口避让，账户的剩余请求数/token 快耗尽时降低调度权重
+ * 负责功能：
+ *   - 内存记录各账户最新的限流窗口（响应头解析结果），持久化节流写库
+ *   - 剩余比例低于阈值时按比例降低权重，窗口重置后自动恢复
+ *   - 重启后内存为空时使用账户表中持久化的窗口
+ * 重要程度：⭐⭐⭐ 一般（调度平滑）
+ * 依赖模块：model, repository, logger
+ */
+package scheduler
+
+import (
+	"sync"
+	"time"
+
+	"go-aiproxy/internal/model"
+	"go-aiproxy/pkg/logger"
+)
+
+const (
+	// rateLimitHeadroomThreshold 剩余比例低于该值时开始降低权重（剩余 0 时降到最低）
+	rateLimitHeadroomThreshold = 0.1
+	// rateLimitPersistInterval 同一账户限流窗口写库的最小间隔（内存每次都更新）
+	rateLimitPersistInterval = 10 * time.Second
+)
+
+// rateLimitState 账户最新的限流窗口和上次写库时间
+type rateLimitState struct {
+	window      *model.RateLimitWindow
+	persistedAt time.Time
+}
+
+var (
+	rateLimitStates   = make(map[uint]*rateLimitState) // accountID -> 限流窗口
+	rateLimitStatesMu sync.Mutex
+)
+
+// UpdateRateLimitWindow 记录账户最新的上游限流窗口（成功响应后调用），写库按账户节流
+func (s *Scheduler) UpdateRateLimitWindow(accountID uint, window *model.RateLimitWindow) {
+	if accountID == 0 || window == nil {
+		return
+	}
+
+	rateLimitStatesMu.Lock()
+	state := rateLimitStates[accountID]
+	if state == nil {
+		state = &rateLimitState{}
+		rateLimitStates[accountID] = state
+	}
+	state.window = window
+	persist := window.UpdatedAt.Sub(state.persistedAt) >= rateLimitPersistInterval
+	if persist {
+		state.persistedAt = window.UpdatedAt
+	}
+	rateLimitStatesMu.Unlock()
+
+	if !persist {
+		return
+	}
+	if err := s.repo.UpdateRateLimitWindow(accountID, window); err != nil {
+		logger.GetLogger("scheduler").Warn("保存账户限流窗口失败 | AccountID: %d | 原因: %v", accountID, err)
+	}
+}
+
+// rateLimitMultiplier 计算限流窗口带来的权重乘数，剩余充足或窗口未知时返回 1
+func rateLimitMultiplier(acc *model.Account, now time.Time) float64 {
+	rateLimitStatesMu.Lock()
+	state := rateLimitStates[acc.ID]
+	var window *model.RateLimitWindow
+	if state != nil {
+		window = state.window
+	}
+	rateLimitStatesMu.Unlock()
+
+	if window == nil {
+		window = acc.StoredRateLimitWindow()
+		if window == nil {
+			return 1
+		}
+	}
+
+	headroom := window.Headroom(now)
+	if headroom >= rateLimitHeadroomThreshold {
+		return 1
+	}
+	return headroom / rateLimitHeadroomThreshold
+}
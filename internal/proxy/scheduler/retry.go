@@ -1,24 +1,38 @@
 /*
  * 文件作用：请求重试机制，处理失败请求的自动重试和账户切换
  * 负责功能：
- *   - 请求重试配置（次数、延迟、退避系数）
+ *   - 请求重试配置（次数、延迟、退避系数，支持运行时热更新）
  *   - 账户切换重试（失败后尝试其他账户）
+ *   - 粘性降级（瞬时错误先在同一账户上退避重试，次数用完再换账户）
  *   - 并发控制（账户并发限制）
- *   - 可重试错误判断（连接错误、限流等）
+ *   - 可重试错误判断（连接错误、限流等，错误规则配置的重试动作优先）
  *   - 流式/非流式请求重试
+ *   - 客户端主动取消识别（不计入账户错误）
+ *   - 模型回退链（无可用账户时降级到下一个模型）
+ *   - 就近调度（优先选择偏好 region 的账户）
+ *   - 灰度分流（灰度组只调度带灰度标签的账户，对照组排除这些账户，指标按分组染色）
+ *   - 优先级排队（账户并发全满时按 API Key/套餐优先级等待槽位）
+ *   - 强制指定账户（调试/灰度，绕过调度且失败不切换账户）
+ *   - 单次选择账户（自行转发上游的接口复用同一套过滤，不走重试）
+ *   - 重试事件推送到管理后台实时请求流
  * 重要程度：⭐⭐⭐⭐⭐ 核心（保证请求可靠性）
- * 依赖模块：cache, model, adapter
+ * 依赖模块：cache, errormatch, livefeed, metrics, model, adapter
  */
 package scheduler
 
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"strings"
+	"sync"
 	"time"
 
 	"go-aiproxy/internal/cache"
+	"go-aiproxy/internal/errormatch"
+	"go-aiproxy/internal/livefeed"
+	"go-aiproxy/internal/metrics"
 	"go-aiproxy/internal/model"
 	"go-aiproxy/internal/proxy/adapter"
 	"go-aiproxy/pkg/logger"
@@ -27,7 +41,11 @@ import (
 var (
 	ErrAllAccountsFailed    = errors.New("all accounts failed")
 	ErrMaxRetriesExceeded   = errors.New("max retries exceeded")
+	ErrClientCanceled       = errors.New("client canceled")
+	ErrRequestTimeout       = errors.New("request timeout")
 	ErrAccountConcurrencyFull = errors.New("account concurrency limit reached")
+	ErrSessionAccountUnavailable = errors.New("session bound account unavailable")
+	ErrForcedAccountUnavailable  = errors.New("forced account unavailable")
 )
 
 // RetryConfig 重试配置
@@ -38,6 +56,9 @@ type RetryConfig struct {
 	RetryableErrors   []string      // 可重试的错误类型
 	SwitchOnRateLimit bool          // 限流时是否切换账户
 	SwitchOnError     bool          // 错误时是否切换账户
+	QueueMaxWait      time.Duration // 账户并发全满时排队等待槽位的最长时间，0 表示不排队
+	// 瞬时错误（5xx、连接重置）先在当前账户上重试的次数，用完才换账户；0 表示立即换账户
+	SameAccountRetries int
 }
 
 // DefaultRetryConfig 默认重试配置
@@ -50,6 +71,25 @@ var DefaultRetryConfig = RetryConfig{
 	SwitchOnError:     true,
 }
 
+var (
+	currentRetryConfig   = DefaultRetryConfig
+	currentRetryConfigMu sync.RWMutex
+)
+
+// SetRetryConfig 更新运行时重试配置（启动和配置变更时调用，之后创建的请求立即生效）
+func SetRetryConfig(cfg RetryConfig) {
+	currentRetryConfigMu.Lock()
+	defer currentRetryConfigMu.Unlock()
+	currentRetryConfig = cfg
+}
+
+// CurrentRetryConfig 获取当前运行时重试配置
+func CurrentRetryConfig() RetryConfig {
+	currentRetryConfigMu.RLock()
+	defer currentRetryConfigMu.RUnlock()
+	return currentRetryConfig
+}
+
 // RetryableRequest 可重试的请求
 type RetryableRequest struct {
 	Scheduler     *Scheduler
@@ -61,13 +101,54 @@ type RetryableRequest struct {
 	UserAgent     string // 客户端User-Agent
 	OriginalModel string // 原始模型名（映射前），用于 AllowedModels 检查
 
+	// 偏好 region（匹配账户 Region 或标签），无匹配账户时退回全局
+	PreferredRegion string
+
+	// 灰度账户池标签，为空时不灰度；Canary 为本请求是否分到灰度组
+	// 灰度组只调度带该标签的账户，对照组不调度带该标签的账户，对应候选为空时退回全部候选
+	CanaryTag string
+	Canary    bool
+
+	// 模型回退链（不含原始模型），无可用账户时依次降级
+	FallbackModels []string
+	// 已回退经过的模型（首项为原始模型），未回退时为空
+	fallbackPath []string
+
+	// 严格粘性：会话绑定账户不可用时返回 ErrSessionAccountUnavailable 而不是换账户
+	StrictSession bool
+	// 本次请求命中的会话绑定账户（严格粘性重试时只使用该账户）
+	boundAccountID uint
+
+	// 调度优先级（来自 API Key/套餐），账户并发全满排队时高优先级先拿到槽位
+	Priority int
+	// 因并发已满被跳过的账户（按调度顺序），全部候选都满时在首个账户上排队
+	fullAccounts []*model.Account
+	// 本次请求是否已排过队（每个请求最多排队一次，总等待不超过 QueueMaxWait）
+	queued bool
+
+	// 限定可调度的账户类型（如 embeddings 只有部分账户类型支持），为空时不限制
+	AccountTypes map[string]bool
+
+	// 限定可调度的账户分组（来自套餐），为空时不限制；只调度属于任一分组的账户
+	AccountGroupIDs []uint
+	// 分组内的账户 ID（首次过滤时从数据库加载）
+	groupAccounts map[uint]bool
+
+	// API Key 所属组织，只调度同一组织的账户（0 表示不属于任何组织，只调度同样不属于组织的账户）
+	OrgID uint
+
+	// 强制指定的账户 ID（X-Force-Account-Id），非 0 时绕过调度只使用该账户，失败不切换账户也不做模型回退
+	ForcedAccountID uint
+
 	// 已尝试的账户 ID，避免重复使用
 	triedAccounts map[uint]bool
+	// 已因网络错误切换过代理的账户 ID（每个账户最多切换一次）
+	proxySwitched map[uint]bool
 }
 
-// NewRetryableRequest 创建可重试请求
+// NewRetryableRequest 创建可重试请求，config 为 nil 时使用当前运行时配置
 func NewRetryableRequest(scheduler *Scheduler, config *RetryConfig) *RetryableRequest {
-	cfg := DefaultRetryConfig
+	cfg := CurrentRetryConfig()
 	if config != nil {
 		cfg = *config
 	}
@@ -85,6 +166,12 @@ func (r *RetryableRequest) WithSessionID(sessionID string) *RetryableRequest {
 	return r
 }
 
+// WithStrictSession 设置是否严格会话粘性
+func (r *RetryableRequest) WithStrictSession(strict bool) *RetryableRequest {
+	r.StrictSession = strict
+	return r
+}
+
 // WithUserInfo 设置用户信息
 func (r *RetryableRequest) WithUserInfo(userID, apiKeyID uint, clientIP, userAgent string) *RetryableRequest {
 	r.UserID = userID
@@ -100,10 +187,146 @@ func (r *RetryableRequest) WithOriginalModel(originalModel string) *RetryableReq
 	return r
 }
 
+// WithPreferredRegion 设置偏好 region
+func (r *RetryableRequest) WithPreferredRegion(region string) *RetryableRequest {
+	r.PreferredRegion = region
+	return r
+}
+
+// WithCanary 设置灰度账户池标签和本请求是否分到灰度组
+func (r *RetryableRequest) WithCanary(tag string, canary bool) *RetryableRequest {
+	r.CanaryTag = tag
+	r.Canary = canary
+	return r
+}
+
+// TrafficGroup 本请求的灰度染色分组，未灰度时为空
+func (r *RetryableRequest) TrafficGroup() string {
+	if r.CanaryTag == "" {
+		return ""
+	}
+	if r.Canary {
+		return model.TrafficGroupCanary
+	}
+	return model.TrafficGroupControl
+}
+
+// WithPriority 设置调度优先级
+func (r *RetryableRequest) WithPriority(priority int) *RetryableRequest {
+	r.Priority = priority
+	return r
+}
+
+// WithAccountTypes 限定可调度的账户类型
+func (r *RetryableRequest) WithAccountTypes(types ...string) *RetryableRequest {
+	r.AccountTypes = make(map[string]bool, len(types))
+	for _, t := range types {
+		r.AccountTypes[t] = true
+	}
+	return r
+}
+
+// WithAccountGroups 限定可调度的账户分组
+func (r *RetryableRequest) WithAccountGroups(groupIDs []uint) *RetryableRequest {
+	r.AccountGroupIDs = groupIDs
+	r.groupAccounts = nil
+	return r
+}
+
+// WithOrg 限定只调度指定组织的账户
+func (r *RetryableRequest) WithOrg(orgID uint) *RetryableRequest {
+	r.OrgID = orgID
+	return r
+}
+
+// WithForcedAccount 强制使用指定账户（0 表示正常调度）
+func (r *RetryableRequest) WithForcedAccount(accountID uint) *RetryableRequest {
+	r.ForcedAccountID = accountID
+	return r
+}
+
+// WithFallbackModels 设置模型回退链（不含原始模型）
+func (r *RetryableRequest) WithFallbackModels(models []string) *RetryableRequest {
+	r.FallbackModels = models
+	return r
+}
+
+// FallbackPath 模型回退路径（如 opus->sonnet），未发生回退返回空字符串
+func (r *RetryableRequest) FallbackPath() string {
+	return strings.Join(r.fallbackPath, "->")
+}
+
+// FallbackModel 回退后实际使用的模型，未发生回退返回空字符串
+func (r *RetryableRequest) FallbackModel() string {
+	if len(r.fallbackPath) == 0 {
+		return ""
+	}
+	return r.fallbackPath[len(r.fallbackPath)-1]
+}
+
+// ApplyModel 返回发给指定账户的请求副本：发生回退时使用回退模型，账户 ModelMapping 命中时使用映射后的模型
+// 请求体透传（RawBody）时同步改写请求体中的 model 字段，保证上游收到实际模型名；模型不变时原样返回
+func (r *RetryableRequest) ApplyModel(account *model.Account, req *adapter.Request) *adapter.Request {
+	targetModel := r.FallbackModel()
+	sourceModel := targetModel
+	if sourceModel == "" {
+		sourceModel = r.OriginalModel
+	}
+	if sourceModel == "" {
+		sourceModel = GetActualModel(req.Model)
+	}
+	if account != nil {
+		if mappedModel := getAccountMappedModel(account, sourceModel); mappedModel != "" {
+			targetModel = mappedModel
+		}
+	}
+	if targetModel == "" || targetModel == req.Model {
+		return req
+	}
+
+	accountReq := *req
+	accountReq.Model = targetModel
+	if len(req.RawBody) > 0 {
+		accountReq.RawBody = adapter.ReplaceBodyModel(req.RawBody, targetModel)
+	}
+	return &accountReq
+}
+
+// nextFallbackModel 切换到回退链的下一个模型，返回新的调度模型名（保留账户类型前缀）
+// 回退链耗尽时返回 false
+func (r *RetryableRequest) nextFallbackModel(modelName string) (string, bool) {
+	if len(r.FallbackModels) == 0 || r.ForcedAccountID != 0 {
+		return "", false
+	}
+	actualModel := GetActualModel(modelName)
+	if len(r.fallbackPath) == 0 {
+		originalModel := r.OriginalModel
+		if originalModel == "" {
+			originalModel = actualModel
+		}
+		r.fallbackPath = []string{originalModel}
+	}
+
+	fallbackModel := r.FallbackModels[0]
+	r.FallbackModels = r.FallbackModels[1:]
+	r.fallbackPath = append(r.fallbackPath, fallbackModel)
+
+	// 新模型重新走一遍账户选择
+	r.OriginalModel = fallbackModel
+	r.triedAccounts = make(map[uint]bool)
+	r.fullAccounts = nil
+
+	if accountType := DetectAccountType(modelName); accountType != "" {
+		return accountType + "," + fallbackModel, true
+	}
+	return fallbackModel, true
+}
+
 // ExecuteResult 执行结果
 type ExecuteResult struct {
 	Response  *adapter.Response
 	AccountID uint
+	Region    string // 命中账户的 region
 }
 
 // ExecuteWithRetry 带重试的执行
@@ -112,16 +335,28 @@ func (r *RetryableRequest) ExecuteWithRetry(
 	modelName string,
 	execFunc func(ctx context.Context, account *model.Account) (*adapter.Response, error),
 ) (*ExecuteResult, error) {
-	log := logger.GetLogger("scheduler")
+	log := logger.GetLogger("scheduler").Ctx(ctx)
 	startTime := time.Now()
 	var lastErr error
 	var lastAccount *model.Account
+	var lastMarked bool // 最后一次失败已按规则标记过账户，结束时不再重复标记
 	var lastResp *adapter.Response
 	delay := r.Config.RetryDelay
 
 	// 记录每个账户的失败次数（用于最终标记状态）
 	accountFailures := make(map[uint]int)
 
+	// 指标：请求最终结果、上游调用次数
+	var metricPlatform string
+	var metricSuccess bool
+	var metricAttempts int
+	defer func() {
+		metrics.ObserveProxyRequest(metricPlatform, metricSuccess, metricAttempts)
+		if group := r.TrafficGroup(); group != "" {
+			metrics.ObserveTrafficGroup(group, metricSuccess, time.Since(startTime))
+		}
+	}()
+
 	// 记录请求开始
 	log.InfoZ("代理请求开始",
 		logger.String("model", modelName),
@@ -130,11 +365,31 @@ func (r *RetryableRequest) ExecuteWithRetry(
 		logger.Uint("api_key_id", r.APIKeyID),
 		logger.String("client_ip", r.ClientIP),
 		logger.Int("max_retries", r.Config.MaxRetries),
+		logger.Uint("forced_account_id", r.ForcedAccountID),
+		logger.String("traffic_group", r.TrafficGroup()),
 	)
 
+	// 瞬时错误后需要在同一账户上重试的账户
+	var retryAccount *model.Account
+
 	for attempt := 0; attempt <= r.Config.MaxRetries; attempt++ {
-		// 选择账户（允许重试同一账户）
-		account, err := r.selectNextAccountAllowRetry(ctx, modelName, accountFailures)
+		// 请求超时（X-Request-Timeout）已到期：不再选择账户
+		if timeoutErr := requestTimeoutError(ctx, nil); timeoutErr != nil {
+			return nil, timeoutErr
+		}
+
+		// 选择账户（瞬时错误先重试当前账户，否则允许重试同一账户）
+		account, err := retryAccount, error(nil)
+		retryAccount = nil
+		if account == nil {
+			account, err = r.selectNextAccountAllowRetry(ctx, modelName, accountFailures)
+		}
+		// 候选账户并发全满：按优先级排队等待槽位
+		queuedSlot := false
+		if errors.Is(err, ErrNoAvailableAccount) && r.shouldQueue() {
+			account, err = r.waitForQueuedSlot(ctx)
+			queuedSlot = err == nil
+		}
 		if err != nil {
 			if errors.Is(err, ErrNoAvailableAccount) {
 				// 如果没有可用账户，检查是否还有重试机会
@@ -144,9 +399,22 @@ func (r *RetryableRequest) ExecuteWithRetry(
 					delay = time.Duration(float64(delay) * r.Config.RetryBackoff)
 					continue
 				}
+				// 重试耗尽，尝试回退链的下一个模型
+				if fallbackName, ok := r.nextFallbackModel(modelName); ok {
+					log.WarnZ("无可用账户，模型回退",
+						logger.String("from_model", GetActualModel(modelName)),
+						logger.String("to_model", GetActualModel(fallbackName)),
+						logger.Uint("api_key_id", r.APIKeyID),
+					)
+					modelName = fallbackName
+					accountFailures = make(map[uint]int)
+					delay = r.Config.RetryDelay
+					attempt = -1
+					continue
+				}
 				// 所有重试都失败，标记最后使用的账户错误
-				if lastAccount != nil && lastErr != nil {
-					r.Scheduler.MarkAccountError(lastAccount.ID, lastAccount.Type, lastErr)
+				if lastAccount != nil && lastErr != nil && !lastMarked {
+					r.Scheduler.MarkAccountModelError(lastAccount.ID, lastAccount.Type, modelName, lastErr)
 				}
 				log.ErrorZ("代理请求失败-无可用账户",
 					logger.String("model", modelName),
@@ -161,15 +429,15 @@ func (r *RetryableRequest) ExecuteWithRetry(
 			return nil, err
 		}
 
-		// 尝试获取并发槽位
+		// 尝试获取并发槽位（排队拿到的槽位已占用）
 		sessionCache := r.Scheduler.GetSessionCache()
-		var acquired bool
-		if sessionCache != nil {
+		acquired := queuedSlot
+		if sessionCache != nil && !queuedSlot {
 			concurrencyLimit := account.MaxConcurrency
 			if concurrencyLimit <= 0 {
 				concurrencyLimit = 5 // 默认值
 			}
-			acquired, _, err = sessionCache.AcquireConcurrencyWithLimit(ctx, account.ID, concurrencyLimit)
+			acquired, _, err = sessionCache.AcquireConcurrencyWithPriority(ctx, account.ID, concurrencyLimit, r.Priority, 0)
 			if err != nil {
 				log.WarnZ("获取并发槽位失败",
 					logger.Uint("account_id", account.ID),
@@ -187,15 +455,36 @@ func (r *RetryableRequest) ExecuteWithRetry(
 				)
 				// 标记该账户已尝试，选择下一个
 				r.triedAccounts[account.ID] = true
+				r.fullAccounts = append(r.fullAccounts, account)
 				continue
 			}
 		}
 
+		// 按模型并发限制：该模型槽位已满时释放账户槽位，换下一个账户
+		modelSlot, modelAcquired := r.acquireModelSlot(ctx, account, modelName)
+		if !modelAcquired {
+			if sessionCache != nil && acquired {
+				sessionCache.ReleaseConcurrency(ctx, account.ID)
+			}
+			r.triedAccounts[account.ID] = true
+			continue
+		}
+
 		// 确保释放并发槽位
 		releaseConcurrency := func() {
 			if sessionCache != nil && acquired {
 				sessionCache.ReleaseConcurrency(ctx, account.ID)
 			}
+			if modelSlot != "" {
+				sessionCache.ReleaseModelConcurrency(ctx, account.ID, modelSlot)
+			}
+		}
+
+		// 每日请求上限：发起请求前占用当日请求数，已达上限时释放槽位，换下一个账户
+		if !r.Scheduler.ReserveDailyRequest(account) {
+			releaseConcurrency()
+			r.triedAccounts[account.ID] = true
+			continue
 		}
 
 		// 记录开始执行
@@ -212,11 +501,15 @@ func (r *RetryableRequest) ExecuteWithRetry(
 		)
 
 		// 执行请求
+		metricPlatform = account.Platform
+		metricAttempts++
 		resp, err := execFunc(ctx, account)
+		metrics.ObserveUpstreamLatency(account.Platform, false, time.Since(execStart))
 
 		if err == nil && resp.Error == nil {
 			// 成功
 			releaseConcurrency()
+			metricSuccess = true
 			r.Scheduler.MarkAccountSuccess(account.ID)
 			log.InfoZ("代理请求成功",
 				logger.String("model", modelName),
@@ -235,11 +528,25 @@ func (r *RetryableRequest) ExecuteWithRetry(
 			return &ExecuteResult{
 				Response:  resp,
 				AccountID: account.ID,
+				Region:    account.Region,
 			}, nil
 		}
 
-		// 释放并发槽位
+		// 释放并发槽位，退回占用的当日请求数
 		releaseConcurrency()
+		r.Scheduler.ReleaseDailyRequest(account)
+
+		// 客户端主动断开：不是账户的问题，不标记错误也不再重试
+		if canceledErr := clientCanceledError(ctx, err); canceledErr != nil {
+			r.logClientCanceled(ctx, modelName, account, canceledErr, startTime, attempt)
+			return nil, canceledErr
+		}
+
+		// 请求超时到期：同样不标记账户错误，直接返回
+		if timeoutErr := requestTimeoutError(ctx, err); timeoutErr != nil {
+			r.logRequestTimeout(ctx, modelName, account, timeoutErr, startTime, attempt)
+			return nil, timeoutErr
+		}
 
 		// 记录错误（但不立即标记账户状态）
 		actualErr := err
@@ -265,11 +572,19 @@ func (r *RetryableRequest) ExecuteWithRetry(
 			logger.String("error", actualErr.Error()),
 			logger.Duration("exec_duration", time.Since(execStart)),
 		)
+		r.publishRetry(ctx, modelName, account, actualErr, execStart, attempt)
 
-		// 判断是否可以重试
-		if !r.isRetryable(actualErr) {
+		// 网络错误且有备用代理：先切换代理在同一账户上重试，仍失败再判定账户错误
+		if attempt < r.Config.MaxRetries && r.switchProxyForRetry(ctx, account, actualErr) {
+			lastMarked = true // 尚未确认是账户问题，切换后的重试未执行时不标记
+			retryAccount = account
+			continue
+		}
+
+		// 判断是否可以重试（强制指定账户时不切换账户）
+		if r.ForcedAccountID != 0 || !r.isRetryable(actualErr) {
 			// 不可重试的错误，立即标记并返回
-			r.Scheduler.MarkAccountError(account.ID, account.Type, actualErr)
+			r.Scheduler.MarkAccountModelError(account.ID, account.Type, modelName, actualErr)
 			log.ErrorZ("代理请求失败-不可重试错误",
 				logger.String("model", modelName),
 				logger.Uint("account_id", account.ID),
@@ -287,13 +602,32 @@ func (r *RetryableRequest) ExecuteWithRetry(
 			}, actualErr
 		}
 
-		// 如果有多个账户，标记当前账户已尝试，下次优先选其他账户
-		r.triedAccounts[account.ID] = true
+		// 错误规则要求标记账户时立即标记，再换账户
+		lastMarked = r.markByRetryRule(actualErr, account, modelName)
+
+		// 瞬时错误先在当前账户上退避重试；否则标记当前账户已尝试，下次优先选其他账户
+		if r.retrySameAccount(actualErr, accountFailures[account.ID]) {
+			retryAccount = account
+			log.InfoZ("瞬时错误，同账户重试",
+				logger.Uint("account_id", account.ID),
+				logger.String("account_name", account.Name),
+				logger.Int("account_failures", accountFailures[account.ID]),
+				logger.Int("same_account_retries", r.Config.SameAccountRetries),
+			)
+		} else {
+			r.triedAccounts[account.ID] = true
+		}
 
 		// 如果不是最后一次尝试，等待后重试
 		if attempt < r.Config.MaxRetries {
 			select {
 			case <-ctx.Done():
+				if canceledErr := clientCanceledError(ctx, ctx.Err()); canceledErr != nil {
+					return nil, canceledErr
+				}
+				if timeoutErr := requestTimeoutError(ctx, ctx.Err()); timeoutErr != nil {
+					return nil, timeoutErr
+				}
 				return nil, ctx.Err()
 			case <-time.After(delay):
 				delay = time.Duration(float64(delay) * r.Config.RetryBackoff)
@@ -302,8 +636,8 @@ func (r *RetryableRequest) ExecuteWithRetry(
 	}
 
 	// 所有重试都失败，标记最后使用的账户错误
-	if lastAccount != nil && lastErr != nil {
-		r.Scheduler.MarkAccountError(lastAccount.ID, lastAccount.Type, lastErr)
+	if lastAccount != nil && lastErr != nil && !lastMarked {
+		r.Scheduler.MarkAccountModelError(lastAccount.ID, lastAccount.Type, modelName, lastErr)
 	}
 
 	log.ErrorZ("代理请求失败-重试耗尽",
@@ -328,6 +662,7 @@ func (r *RetryableRequest) ExecuteWithRetry(
 type StreamExecuteResult struct {
 	Result    *adapter.StreamResult
 	AccountID uint
+	Region    string // 命中账户的 region
 }
 
 // ExecuteStreamWithRetry 带重试的流式执行
@@ -337,15 +672,27 @@ func (r *RetryableRequest) ExecuteStreamWithRetry(
 	execFunc func(ctx context.Context, account *model.Account, writer io.Writer) (*adapter.StreamResult, error),
 	writer io.Writer,
 ) (*StreamExecuteResult, error) {
-	log := logger.GetLogger("scheduler")
+	log := logger.GetLogger("scheduler").Ctx(ctx)
 	startTime := time.Now()
 	var lastErr error
 	var lastAccount *model.Account
+	var lastMarked bool // 最后一次失败已按规则标记过账户，结束时不再重复标记
 	delay := r.Config.RetryDelay
 
 	// 记录每个账户的失败次数
 	accountFailures := make(map[uint]int)
 
+	// 指标：请求最终结果、上游调用次数
+	var metricPlatform string
+	var metricSuccess bool
+	var metricAttempts int
+	defer func() {
+		metrics.ObserveProxyRequest(metricPlatform, metricSuccess, metricAttempts)
+		if group := r.TrafficGroup(); group != "" {
+			metrics.ObserveTrafficGroup(group, metricSuccess, time.Since(startTime))
+		}
+	}()
+
 	// 记录流式请求开始
 	log.InfoZ("流式代理请求开始",
 		logger.String("model", modelName),
@@ -354,11 +701,31 @@ func (r *RetryableRequest) ExecuteStreamWithRetry(
 		logger.Uint("api_key_id", r.APIKeyID),
 		logger.String("client_ip", r.ClientIP),
 		logger.Int("max_retries", r.Config.MaxRetries),
+		logger.Uint("forced_account_id", r.ForcedAccountID),
+		logger.String("traffic_group", r.TrafficGroup()),
 	)
 
+	// 瞬时错误后需要在同一账户上重试的账户
+	var retryAccount *model.Account
+
 	for attempt := 0; attempt <= r.Config.MaxRetries; attempt++ {
-		// 选择账户（允许重试同一账户）
-		account, err := r.selectNextAccountAllowRetry(ctx, modelName, accountFailures)
+		// 请求超时（X-Request-Timeout）已到期：不再选择账户
+		if timeoutErr := requestTimeoutError(ctx, nil); timeoutErr != nil {
+			return nil, timeoutErr
+		}
+
+		// 选择账户（瞬时错误先重试当前账户，否则允许重试同一账户）
+		account, err := retryAccount, error(nil)
+		retryAccount = nil
+		if account == nil {
+			account, err = r.selectNextAccountAllowRetry(ctx, modelName, accountFailures)
+		}
+		// 候选账户并发全满：按优先级排队等待槽位
+		queuedSlot := false
+		if errors.Is(err, ErrNoAvailableAccount) && r.shouldQueue() {
+			account, err = r.waitForQueuedSlot(ctx)
+			queuedSlot = err == nil
+		}
 		if err != nil {
 			if errors.Is(err, ErrNoAvailableAccount) {
 				if attempt < r.Config.MaxRetries {
@@ -366,9 +733,22 @@ func (r *RetryableRequest) ExecuteStreamWithRetry(
 					delay = time.Duration(float64(delay) * r.Config.RetryBackoff)
 					continue
 				}
+				// 重试耗尽，尝试回退链的下一个模型
+				if fallbackName, ok := r.nextFallbackModel(modelName); ok {
+					log.WarnZ("无可用账户，模型回退",
+						logger.String("from_model", GetActualModel(modelName)),
+						logger.String("to_model", GetActualModel(fallbackName)),
+						logger.Uint("api_key_id", r.APIKeyID),
+					)
+					modelName = fallbackName
+					accountFailures = make(map[uint]int)
+					delay = r.Config.RetryDelay
+					attempt = -1
+					continue
+				}
 				// 所有重试都失败，标记最后使用的账户错误
-				if lastAccount != nil && lastErr != nil {
-					r.Scheduler.MarkAccountError(lastAccount.ID, lastAccount.Type, lastErr)
+				if lastAccount != nil && lastErr != nil && !lastMarked {
+					r.Scheduler.MarkAccountModelError(lastAccount.ID, lastAccount.Type, modelName, lastErr)
 				}
 				log.ErrorZ("流式代理请求失败-无可用账户",
 					logger.String("model", modelName),
@@ -383,15 +763,15 @@ func (r *RetryableRequest) ExecuteStreamWithRetry(
 			return nil, err
 		}
 
-		// 尝试获取并发槽位
+		// 尝试获取并发槽位（排队拿到的槽位已占用）
 		sessionCache := r.Scheduler.GetSessionCache()
-		var acquired bool
-		if sessionCache != nil {
+		acquired := queuedSlot
+		if sessionCache != nil && !queuedSlot {
 			concurrencyLimit := account.MaxConcurrency
 			if concurrencyLimit <= 0 {
 				concurrencyLimit = 5 // 默认值
 			}
-			acquired, _, err = sessionCache.AcquireConcurrencyWithLimit(ctx, account.ID, concurrencyLimit)
+			acquired, _, err = sessionCache.AcquireConcurrencyWithPriority(ctx, account.ID, concurrencyLimit, r.Priority, 0)
 			if err != nil {
 				log.WarnZ("获取并发槽位失败",
 					logger.Uint("account_id", account.ID),
@@ -409,15 +789,36 @@ func (r *RetryableRequest) ExecuteStreamWithRetry(
 				)
 				// 标记该账户已尝试，选择下一个
 				r.triedAccounts[account.ID] = true
+				r.fullAccounts = append(r.fullAccounts, account)
 				continue
 			}
 		}
 
+		// 按模型并发限制：该模型槽位已满时释放账户槽位，换下一个账户
+		modelSlot, modelAcquired := r.acquireModelSlot(ctx, account, modelName)
+		if !modelAcquired {
+			if sessionCache != nil && acquired {
+				sessionCache.ReleaseConcurrency(ctx, account.ID)
+			}
+			r.triedAccounts[account.ID] = true
+			continue
+		}
+
 		// 确保释放并发槽位
 		releaseConcurrency := func() {
 			if sessionCache != nil && acquired {
 				sessionCache.ReleaseConcurrency(ctx, account.ID)
 			}
+			if modelSlot != "" {
+				sessionCache.ReleaseModelConcurrency(ctx, account.ID, modelSlot)
+			}
+		}
+
+		// 每日请求上限：发起请求前占用当日请求数，已达上限时释放槽位，换下一个账户
+		if !r.Scheduler.ReserveDailyRequest(account) {
+			releaseConcurrency()
+			r.triedAccounts[account.ID] = true
+			continue
 		}
 
 		// 记录开始执行
@@ -434,11 +835,25 @@ func (r *RetryableRequest) ExecuteStreamWithRetry(
 		)
 
 		// 执行流式请求
+		metricPlatform = account.Platform
+		metricAttempts++
 		result, err := execFunc(ctx, account, writer)
+		metrics.ObserveUpstreamLatency(account.Platform, true, time.Since(execStart))
 
 		if err == nil {
 			releaseConcurrency()
-			r.Scheduler.MarkAccountSuccess(account.ID)
+			metricSuccess = true
+			// 疑似截断（未收到正常终止事件）不标记账户成功，避免掩盖账户问题
+			if result.Truncated() {
+				log.WarnZ("流式响应疑似截断，未收到正常终止事件",
+					logger.String("model", modelName),
+					logger.Uint("account_id", account.ID),
+					logger.String("account_name", account.Name),
+					logger.String("account_type", account.Type),
+				)
+			} else {
+				r.Scheduler.MarkAccountSuccess(account.ID)
+			}
 			log.InfoZ("流式代理请求成功",
 				logger.String("model", modelName),
 				logger.Uint("account_id", account.ID),
@@ -458,11 +873,25 @@ func (r *RetryableRequest) ExecuteStreamWithRetry(
 			return &StreamExecuteResult{
 				Result:    result,
 				AccountID: account.ID,
+				Region:    account.Region,
 			}, nil
 		}
 
-		// 释放并发槽位
+		// 释放并发槽位，退回占用的当日请求数
 		releaseConcurrency()
+		r.Scheduler.ReleaseDailyRequest(account)
+
+		// 客户端主动断开：不是账户的问题，不标记错误也不再重试
+		if canceledErr := clientCanceledError(ctx, err); canceledErr != nil {
+			r.logClientCanceled(ctx, modelName, account, canceledErr, startTime, attempt)
+			return nil, canceledErr
+		}
+
+		// 请求超时到期：同样不标记账户错误，直接返回
+		if timeoutErr := requestTimeoutError(ctx, err); timeoutErr != nil {
+			r.logRequestTimeout(ctx, modelName, account, timeoutErr, startTime, attempt)
+			return nil, timeoutErr
+		}
 
 		// 记录错误（但不立即标记账户状态）
 		lastErr = err
@@ -482,12 +911,20 @@ func (r *RetryableRequest) ExecuteStreamWithRetry(
 			logger.String("error", err.Error()),
 			logger.Duration("exec_duration", time.Since(execStart)),
 		)
+		r.publishRetry(ctx, modelName, account, err, execStart, attempt)
+
+		// 连接阶段的网络错误且有备用代理：先切换代理在同一账户上重试
+		if attempt < r.Config.MaxRetries && r.isConnectionError(err) && r.switchProxyForRetry(ctx, account, err) {
+			lastMarked = true
+			retryAccount = account
+			continue
+		}
 
 		// 流式请求一旦开始就不应该重试（因为可能已经写入部分数据）
-		// 除非是在连接阶段就失败了
-		if !r.isConnectionError(err) {
+		// 除非是在连接阶段就失败了（强制指定账户时不切换账户）
+		if r.ForcedAccountID != 0 || !r.isConnectionError(err) {
 			// 不可重试的错误，立即标记并返回
-			r.Scheduler.MarkAccountError(account.ID, account.Type, err)
+			r.Scheduler.MarkAccountModelError(account.ID, account.Type, modelName, err)
 			log.ErrorZ("流式代理请求失败-不可重试错误",
 				logger.String("model", modelName),
 				logger.Uint("account_id", account.ID),
@@ -502,11 +939,29 @@ func (r *RetryableRequest) ExecuteStreamWithRetry(
 			return nil, err
 		}
 
-		r.triedAccounts[account.ID] = true
+		lastMarked = r.markByRetryRule(err, account, modelName)
+
+		if r.retrySameAccount(err, accountFailures[account.ID]) {
+			retryAccount = account
+			log.InfoZ("瞬时错误，同账户重试",
+				logger.Uint("account_id", account.ID),
+				logger.String("account_name", account.Name),
+				logger.Int("account_failures", accountFailures[account.ID]),
+				logger.Int("same_account_retries", r.Config.SameAccountRetries),
+			)
+		} else {
+			r.triedAccounts[account.ID] = true
+		}
 
 		if attempt < r.Config.MaxRetries {
 			select {
 			case <-ctx.Done():
+				if canceledErr := clientCanceledError(ctx, ctx.Err()); canceledErr != nil {
+					return nil, canceledErr
+				}
+				if timeoutErr := requestTimeoutError(ctx, ctx.Err()); timeoutErr != nil {
+					return nil, timeoutErr
+				}
 				return nil, ctx.Err()
 			case <-time.After(delay):
 				delay = time.Duration(float64(delay) * r.Config.RetryBackoff)
@@ -515,8 +970,8 @@ func (r *RetryableRequest) ExecuteStreamWithRetry(
 	}
 
 	// 所有重试都失败，标记最后使用的账户错误
-	if lastAccount != nil && lastErr != nil {
-		r.Scheduler.MarkAccountError(lastAccount.ID, lastAccount.Type, lastErr)
+	if lastAccount != nil && lastErr != nil && !lastMarked {
+		r.Scheduler.MarkAccountModelError(lastAccount.ID, lastAccount.Type, modelName, lastErr)
 	}
 
 	log.ErrorZ("流式代理请求失败-重试耗尽",
@@ -534,9 +989,20 @@ func (r *RetryableRequest) ExecuteStreamWithRetry(
 	return nil, lastErr
 }
 
+// SelectAccount 只选择账户不执行请求（自行转发上游的接口使用，如 Responses、Message Batches）
+// 与 ExecuteWithRetry 使用同一套选择逻辑：强制指定账户、会话粘性、账户类型、套餐分组、组织、灰度和偏好 region
+func (r *RetryableRequest) SelectAccount(ctx context.Context, modelName string) (*model.Account, error) {
+	account, err := r.selectNextAccountAllowRetry(ctx, modelName, nil)
+	if err != nil {
+		return nil, err
+	}
+	r.triedAccounts[account.ID] = true
+	return account, nil
+}
+
 // selectNextAccount 选择下一个可用账户
 func (r *RetryableRequest) selectNextAccount(ctx context.Context, modelName string) (*model.Account, error) {
-	log := logger.GetLogger("scheduler")
+	log := logger.GetLogger("scheduler").Ctx(ctx)
 
 	// 检测是否指定了账户类型
 	accountType := DetectAccountType(modelName)
@@ -551,6 +1017,16 @@ func (r *RetryableRequest) selectNextAccount(ctx context.Context, modelName stri
 
 	log.Debug("选择账户 - 模型: %s, 账户类型: %s, 实际模型: %s, 原始模型: %s, SessionID: %s", modelName, accountType, actualModel, originalModel, r.SessionID)
 
+	// 【强制指定账户】绕过调度，只使用指定账户
+	if r.ForcedAccountID != 0 {
+		return r.forcedAccount(ctx, platform, accountType)
+	}
+
+	// 【严格粘性】会话已命中绑定账户，重试时不切换到其他账户
+	if r.StrictSession && r.boundAccountID != 0 && len(r.triedAccounts) > 0 {
+		return r.strictSessionRetryAccount(ctx)
+	}
+
 	// 【会话粘性】首次尝试时检查会话绑定（从 Redis）
 	if r.SessionID != "" && len(r.triedAccounts) == 0 {
 		sessionCache := r.Scheduler.GetSessionCache()
@@ -563,7 +1039,7 @@ func (r *RetryableRequest) selectNextAccount(ctx context.Context, modelName stri
 			if err == nil && binding != nil && binding.Platform == targetPlatform {
 				// 尝试获取绑定的账户
 				acc, err := r.Scheduler.repo.GetByID(binding.AccountID)
-				if err == nil && acc != nil && acc.Enabled && acc.Status == model.AccountStatusValid {
+				if err == nil && acc != nil && acc.IsSchedulable() {
 					// 检查账户是否允许当前模型
 					// 如果账户有 ModelMapping，需要用映射后的模型来检查 AllowedModels
 					checkModel := actualModel
@@ -577,7 +1053,7 @@ func (r *RetryableRequest) selectNextAccount(ctx context.Context, modelName stri
 							// 账户有 ModelMapping 但不包含原始模型，移除绑定
 							log.Info("会话粘性账户 ModelMapping 不包含原始模型，移除绑定 - SessionID: %s, 账户ID: %d, 原始模型: %s, ModelMapping: %s",
 								r.SessionID, acc.ID, originalModel, acc.ModelMapping)
-							sessionCache.RemoveSessionBinding(ctx, r.SessionID)
+							r.removeSessionBinding(ctx, sessionCache)
 							sessionValid = false
 						}
 					}
@@ -585,11 +1061,33 @@ func (r *RetryableRequest) selectNextAccount(ctx context.Context, modelName stri
 					if sessionValid && !r.Scheduler.isModelAllowed(acc, checkModel) {
 						log.Info("会话粘性账户不允许该模型，移除绑定 - SessionID: %s, 账户ID: %d, 检查模型: %s, AllowedModels: %s",
 							r.SessionID, acc.ID, checkModel, acc.AllowedModels)
-						sessionCache.RemoveSessionBinding(ctx, r.SessionID)
+						r.removeSessionBinding(ctx, sessionCache)
+						sessionValid = false
+					}
+
+					if sessionValid && !r.inAccountGroups(ctx, acc.ID) {
+						log.Info("会话粘性账户不在套餐可用分组内，移除绑定 - SessionID: %s, 账户ID: %d, 分组: %v",
+							r.SessionID, acc.ID, r.AccountGroupIDs)
+						r.removeSessionBinding(ctx, sessionCache)
+						sessionValid = false
+					}
+
+					if sessionValid && acc.OrgID != r.OrgID {
+						log.Info("会话粘性账户不属于 API Key 所在组织，移除绑定 - SessionID: %s, 账户ID: %d, 账户组织: %d, 请求组织: %d",
+							r.SessionID, acc.ID, acc.OrgID, r.OrgID)
+						r.removeSessionBinding(ctx, sessionCache)
+						sessionValid = false
+					}
+
+					if window, _ := exhaustedUsageWindow(acc, checkModel, time.Now()); sessionValid && window != "" {
+						log.Info("会话粘性账户用量窗口已满，移除绑定 - SessionID: %s, 账户ID: %d, 窗口: %s, 检查模型: %s",
+							r.SessionID, acc.ID, window, checkModel)
+						r.removeSessionBinding(ctx, sessionCache)
 						sessionValid = false
 					}
 
 					if sessionValid {
+						r.boundAccountID = acc.ID
 						sessionCache.UpdateSessionLastUsed(ctx, r.SessionID)
 						log.Info("会话粘性命中 - SessionID: %s, 账户ID: %d, 名称: %s", r.SessionID, acc.ID, acc.Name)
 						return acc, nil
@@ -597,9 +1095,15 @@ func (r *RetryableRequest) selectNextAccount(ctx context.Context, modelName stri
 				} else {
 					// 账户不可用，移除会话绑定并刷新调度器缓存
 					log.Info("会话粘性账户不可用，移除绑定并刷新缓存 - SessionID: %s, 账户ID: %d", r.SessionID, binding.AccountID)
-					sessionCache.RemoveSessionBinding(ctx, r.SessionID)
+					r.removeSessionBinding(ctx, sessionCache)
 					r.Scheduler.Refresh()
 				}
+
+				// 【严格粘性】绑定账户不可用时不换账户，由客户端决定是否重开对话
+				if r.StrictSession {
+					log.Warn("严格粘性会话绑定账户不可用 - SessionID: %s, 账户ID: %d", r.SessionID, binding.AccountID)
+					return nil, ErrSessionAccountUnavailable
+				}
 			}
 		}
 	}
@@ -614,11 +1118,11 @@ func (r *RetryableRequest) selectNextAccount(ctx context.Context, modelName stri
 		var e error
 		if strings.Contains(accountType, "-") {
 			// 具体类型，精确匹配
-			accList, e = r.Scheduler.repo.GetEnabledByType(accountType)
+			accList, e = r.Scheduler.withPools(r.Scheduler.repo.GetEnabledByType(accountType))
 			log.Debug("按类型精确匹配 - 类型: %s", accountType)
 		} else {
 			// 平台前缀，前缀匹配
-			accList, e = r.Scheduler.repo.GetEnabledByTypePrefix(accountType)
+			accList, e = r.Scheduler.withPools(r.Scheduler.repo.GetEnabledByTypePrefix(accountType))
 			log.Debug("按类型前缀匹配 - 前缀: %s", accountType)
 		}
 		if e != nil {
@@ -657,7 +1161,7 @@ func (r *RetryableRequest) selectNextAccount(ctx context.Context, modelName stri
 	}
 
 	// 根据 AllowedModels 和 账户 ModelMapping 过滤账户
-	accounts = r.Scheduler.filterByAllowedModelsWithOriginal(accounts, actualModel, originalModel)
+	accounts = r.filterOrg(r.filterAccountGroups(ctx, r.filterAccountTypes(r.Scheduler.filterByAllowedModelsWithOriginal(accounts, actualModel, originalModel))))
 	if len(accounts) == 0 {
 		log.Warn("无可用账户(AllowedModels过滤后) - 模型: %s, 原始模型: %s", actualModel, originalModel)
 		return nil, ErrNoAvailableAccount
@@ -703,7 +1207,7 @@ func (r *RetryableRequest) selectNextAccount(ctx context.Context, modelName stri
 		return nil, ErrNoAvailableAccount
 	}
 
-	selected := r.Scheduler.selectByWeight(available)
+	selected := r.Scheduler.selectAccount(available)
 
 	// 【会话粘性】绑定新选中的账户（到 Redis）
 	if r.SessionID != "" {
@@ -731,7 +1235,7 @@ func (r *RetryableRequest) selectNextAccount(ctx context.Context, modelName stri
 // selectNextAccountAllowRetry 选择下一个可用账户（允许重试同一账户）
 // accountFailures 记录每个账户在本次请求中的失败次数
 func (r *RetryableRequest) selectNextAccountAllowRetry(ctx context.Context, modelName string, accountFailures map[uint]int) (*model.Account, error) {
-	log := logger.GetLogger("scheduler")
+	log := logger.GetLogger("scheduler").Ctx(ctx)
 
 	// 检测是否指定了账户类型
 	accountType := DetectAccountType(modelName)
@@ -746,6 +1250,16 @@ func (r *RetryableRequest) selectNextAccountAllowRetry(ctx context.Context, mode
 
 	log.Debug("选择账户(允许重试) - 模型: %s, 账户类型: %s, 实际模型: %s, 原始模型: %s, SessionID: %s", modelName, accountType, actualModel, originalModel, r.SessionID)
 
+	// 【强制指定账户】绕过调度，只使用指定账户
+	if r.ForcedAccountID != 0 {
+		return r.forcedAccount(ctx, platform, accountType)
+	}
+
+	// 【严格粘性】会话已命中绑定账户，重试时不切换到其他账户
+	if r.StrictSession && r.boundAccountID != 0 && len(r.triedAccounts) > 0 {
+		return r.strictSessionRetryAccount(ctx)
+	}
+
 	// 【会话粘性】首次尝试时检查会话绑定（从 Redis）
 	if r.SessionID != "" && len(r.triedAccounts) == 0 {
 		sessionCache := r.Scheduler.GetSessionCache()
@@ -758,7 +1272,7 @@ func (r *RetryableRequest) selectNextAccountAllowRetry(ctx context.Context, mode
 			if err == nil && binding != nil && binding.Platform == targetPlatform {
 				// 尝试获取绑定的账户
 				acc, err := r.Scheduler.repo.GetByID(binding.AccountID)
-				if err == nil && acc != nil && acc.Enabled && acc.Status == model.AccountStatusValid {
+				if err == nil && acc != nil && acc.IsSchedulable() {
 					// 检查账户是否允许当前模型
 					// 如果账户有 ModelMapping，需要用映射后的模型来检查 AllowedModels
 					checkModel := actualModel
@@ -772,7 +1286,7 @@ func (r *RetryableRequest) selectNextAccountAllowRetry(ctx context.Context, mode
 							// 账户有 ModelMapping 但不包含原始模型，移除绑定
 							log.Info("会话粘性账户 ModelMapping 不包含原始模型，移除绑定 - SessionID: %s, 账户ID: %d, 原始模型: %s, ModelMapping: %s",
 								r.SessionID, acc.ID, originalModel, acc.ModelMapping)
-							sessionCache.RemoveSessionBinding(ctx, r.SessionID)
+							r.removeSessionBinding(ctx, sessionCache)
 							sessionValid = false
 						}
 					}
@@ -780,11 +1294,33 @@ func (r *RetryableRequest) selectNextAccountAllowRetry(ctx context.Context, mode
 					if sessionValid && !r.Scheduler.isModelAllowed(acc, checkModel) {
 						log.Info("会话粘性账户不允许该模型，移除绑定 - SessionID: %s, 账户ID: %d, 检查模型: %s, AllowedModels: %s",
 							r.SessionID, acc.ID, checkModel, acc.AllowedModels)
-						sessionCache.RemoveSessionBinding(ctx, r.SessionID)
+						r.removeSessionBinding(ctx, sessionCache)
+						sessionValid = false
+					}
+
+					if sessionValid && !r.inAccountGroups(ctx, acc.ID) {
+						log.Info("会话粘性账户不在套餐可用分组内，移除绑定 - SessionID: %s, 账户ID: %d, 分组: %v",
+							r.SessionID, acc.ID, r.AccountGroupIDs)
+						r.removeSessionBinding(ctx, sessionCache)
+						sessionValid = false
+					}
+
+					if sessionValid && acc.OrgID != r.OrgID {
+						log.Info("会话粘性账户不属于 API Key 所在组织，移除绑定 - SessionID: %s, 账户ID: %d, 账户组织: %d, 请求组织: %d",
+							r.SessionID, acc.ID, acc.OrgID, r.OrgID)
+						r.removeSessionBinding(ctx, sessionCache)
+						sessionValid = false
+					}
+
+					if window, _ := exhaustedUsageWindow(acc, checkModel, time.Now()); sessionValid && window != "" {
+						log.Info("会话粘性账户用量窗口已满，移除绑定 - SessionID: %s, 账户ID: %d, 窗口: %s, 检查模型: %s",
+							r.SessionID, acc.ID, window, checkModel)
+						r.removeSessionBinding(ctx, sessionCache)
 						sessionValid = false
 					}
 
 					if sessionValid {
+						r.boundAccountID = acc.ID
 						sessionCache.UpdateSessionLastUsed(ctx, r.SessionID)
 						log.Info("会话粘性命中 - SessionID: %s, 账户ID: %d, 名称: %s", r.SessionID, acc.ID, acc.Name)
 						return acc, nil
@@ -792,9 +1328,15 @@ func (r *RetryableRequest) selectNextAccountAllowRetry(ctx context.Context, mode
 				} else {
 					// 账户不可用，移除会话绑定并刷新调度器缓存
 					log.Info("会话粘性账户不可用，移除绑定并刷新缓存 - SessionID: %s, 账户ID: %d", r.SessionID, binding.AccountID)
-					sessionCache.RemoveSessionBinding(ctx, r.SessionID)
+					r.removeSessionBinding(ctx, sessionCache)
 					r.Scheduler.Refresh()
 				}
+
+				// 【严格粘性】绑定账户不可用时不换账户，由客户端决定是否重开对话
+				if r.StrictSession {
+					log.Warn("严格粘性会话绑定账户不可用 - SessionID: %s, 账户ID: %d", r.SessionID, binding.AccountID)
+					return nil, ErrSessionAccountUnavailable
+				}
 			}
 		}
 	}
@@ -809,10 +1351,10 @@ func (r *RetryableRequest) selectNextAccountAllowRetry(ctx context.Context, mode
 		var e error
 		if strings.Contains(accountType, "-") {
 			// 具体类型，精确匹配
-			accList, e = r.Scheduler.repo.GetEnabledByType(accountType)
+			accList, e = r.Scheduler.withPools(r.Scheduler.repo.GetEnabledByType(accountType))
 		} else {
 			// 平台前缀，前缀匹配
-			accList, e = r.Scheduler.repo.GetEnabledByTypePrefix(accountType)
+			accList, e = r.Scheduler.withPools(r.Scheduler.repo.GetEnabledByTypePrefix(accountType))
 		}
 		if e != nil {
 			log.Error("获取账户失败 - 类型: %s, 错误: %v", accountType, e)
@@ -849,7 +1391,7 @@ func (r *RetryableRequest) selectNextAccountAllowRetry(ctx context.Context, mode
 	for _, acc := range accounts {
 		log.Debug("  账户: ID=%d, Name=%s, AllowedModels='%s', ModelMapping='%s'", acc.ID, acc.Name, acc.AllowedModels, acc.ModelMapping)
 	}
-	accounts = r.Scheduler.filterByAllowedModelsWithOriginal(accounts, actualModel, originalModel)
+	accounts = r.filterOrg(r.filterAccountGroups(ctx, r.filterAccountTypes(r.Scheduler.filterByAllowedModelsWithOriginal(accounts, actualModel, originalModel))))
 	log.Debug("AllowedModels过滤后 - 账户数: %d", len(accounts))
 	if len(accounts) == 0 {
 		log.Warn("无可用账户(AllowedModels过滤后) - 模型: %s", actualModel)
@@ -882,9 +1424,9 @@ func (r *RetryableRequest) selectNextAccountAllowRetry(ctx context.Context, mode
 		}
 	}
 
-	// 如果有未尝试的账户，优先选择
+	// 如果有未尝试的账户，优先选择（按灰度分组过滤，有偏好 region 时优先选匹配的账户）
 	if len(available) > 0 {
-		selected := r.Scheduler.selectByWeight(available)
+		selected := r.Scheduler.selectAccount(r.preferRegion(r.filterCanary(available)))
 
 		// 【会话粘性】绑定新选中的账户（到 Redis）
 		if r.SessionID != "" {
@@ -931,11 +1473,339 @@ func (r *RetryableRequest) selectNextAccountAllowRetry(ctx context.Context, mode
 	return nil, ErrNoAvailableAccount
 }
 
-// isRetryable 判断错误是否可重试
+// removeSessionBinding 移除会话绑定，严格粘性模式下保留（账户恢复后会话可继续）
+func (r *RetryableRequest) removeSessionBinding(ctx context.Context, sessionCache *cache.SessionCache) {
+	if r.StrictSession {
+		return
+	}
+	sessionCache.RemoveSessionBinding(ctx, r.SessionID)
+}
+
+// strictSessionRetryAccount 严格粘性重试时只使用会话绑定账户，账户已不可调度时返回 ErrSessionAccountUnavailable
+func (r *RetryableRequest) strictSessionRetryAccount(ctx context.Context) (*model.Account, error) {
+	log := logger.GetLogger("scheduler").Ctx(ctx)
+	acc, err := r.Scheduler.repo.GetByID(r.boundAccountID)
+	if err != nil || acc == nil || !acc.IsSchedulable() {
+		log.Warn("严格粘性会话绑定账户不可用，停止重试 - SessionID: %s, 账户ID: %d", r.SessionID, r.boundAccountID)
+		return nil, ErrSessionAccountUnavailable
+	}
+	log.Info("严格粘性重试绑定账户 - SessionID: %s, 账户ID: %d, 名称: %s", r.SessionID, acc.ID, acc.Name)
+	return acc, nil
+}
+
+// forcedAccount 返回强制指定的账户，不检查账户状态、AllowedModels 和会话绑定
+// 账户不存在或平台/账户类型与请求不符时返回 ErrForcedAccountUnavailable，并发已满时返回 ErrAccountConcurrencyFull
+func (r *RetryableRequest) forcedAccount(ctx context.Context, platform, accountType string) (*model.Account, error) {
+	log := logger.GetLogger("scheduler").Ctx(ctx)
+	if r.triedAccounts[r.ForcedAccountID] {
+		log.Warn("强制指定账户并发已满 - 账户ID: %d, API Key ID: %d", r.ForcedAccountID, r.APIKeyID)
+		return nil, ErrAccountConcurrencyFull
+	}
+
+	acc, err := r.Scheduler.repo.GetByID(r.ForcedAccountID)
+	if err != nil || acc == nil {
+		log.Warn("强制指定账户不存在 - 账户ID: %d, API Key ID: %d", r.ForcedAccountID, r.APIKeyID)
+		return nil, fmt.Errorf("%w: account %d not found", ErrForcedAccountUnavailable, r.ForcedAccountID)
+	}
+	// 与正常调度一致：带 "-" 的账户类型精确匹配，否则按前缀匹配；未指定类型时按平台匹配
+	matched := acc.Platform == platform
+	if strings.Contains(accountType, "-") {
+		matched = acc.Type == accountType
+	} else if accountType != "" {
+		matched = strings.HasPrefix(acc.Type, accountType)
+	}
+	if len(r.AccountTypes) > 0 && !r.AccountTypes[acc.Type] {
+		matched = false
+	}
+	if acc.OrgID != r.OrgID {
+		matched = false
+	}
+	if !matched {
+		log.Warn("强制指定账户与请求不匹配 - 账户ID: %d, 账户类型: %s, 请求平台: %s, 指定类型: %s", acc.ID, acc.Type, platform, accountType)
+		return nil, fmt.Errorf("%w: account %d (%s) does not serve this request", ErrForcedAccountUnavailable, acc.ID, acc.Type)
+	}
+
+	log.Warn("强制指定账户 - 账户ID: %d, 名称: %s, 状态: %s, API Key ID: %d", acc.ID, acc.Name, acc.Status, r.APIKeyID)
+	return acc, nil
+}
+
+// preferRegion 优先保留匹配偏好 region 的账户，没有匹配时退回全部候选
+func (r *RetryableRequest) preferRegion(accounts []*model.Account) []*model.Account {
+	if r.PreferredRegion == "" {
+		return accounts
+	}
+	matched := make([]*model.Account, 0, len(accounts))
+	for _, acc := range accounts {
+		if acc.MatchesRegion(r.PreferredRegion) {
+			matched = append(matched, acc)
+		}
+	}
+	if len(matched) == 0 {
+		logger.GetLogger("scheduler").Debug("无匹配偏好 region 的账户，退回全局调度 - Region: %s", r.PreferredRegion)
+		return accounts
+	}
+	return matched
+}
+
+// filterCanary 按灰度分组过滤：灰度组只保留带灰度标签的账户，对照组排除这些账户，过滤后为空时退回全部候选
+func (r *RetryableRequest) filterCanary(accounts []*model.Account) []*model.Account {
+	if r.CanaryTag == "" {
+		return accounts
+	}
+	filtered := make([]*model.Account, 0, len(accounts))
+	for _, acc := range accounts {
+		if acc.HasTag(r.CanaryTag) == r.Canary {
+			filtered = append(filtered, acc)
+		}
+	}
+	if len(filtered) == 0 {
+		logger.GetLogger("scheduler").Debug("灰度分组无可用账户，退回全部候选 - 标签: %s, 分组: %s", r.CanaryTag, r.TrafficGroup())
+		return accounts
+	}
+	return filtered
+}
+
+// filterAccountTypes 按 AccountTypes 过滤账户，未限定类型时原样返回
+func (r *RetryableRequest) filterAccountTypes(accounts []*model.Account) []*model.Account {
+	if len(r.AccountTypes) == 0 {
+		return accounts
+	}
+	filtered := make([]*model.Account, 0, len(accounts))
+	for _, acc := range accounts {
+		if r.AccountTypes[acc.Type] {
+			filtered = append(filtered, acc)
+		}
+	}
+	return filtered
+}
+
+// filterOrg 只保留与 API Key 同一组织的账户
+func (r *RetryableRequest) filterOrg(accounts []*model.Account) []*model.Account {
+	filtered := make([]*model.Account, 0, len(accounts))
+	for _, acc := range accounts {
+		if acc.OrgID == r.OrgID {
+			filtered = append(filtered, acc)
+		}
+	}
+	return filtered
+}
+
+// filterAccountGroups 按 AccountGroupIDs 过滤账户，未限定分组时原样返回
+func (r *RetryableRequest) filterAccountGroups(ctx context.Context, accounts []*model.Account) []*model.Account {
+	if len(r.AccountGroupIDs) == 0 {
+		return accounts
+	}
+	filtered := make([]*model.Account, 0, len(accounts))
+	for _, acc := range accounts {
+		if r.inAccountGroups(ctx, acc.ID) {
+			filtered = append(filtered, acc)
+		}
+	}
+	return filtered
+}
+
+// inAccountGroups 账户是否在限定的分组内，未限定分组时返回 true
+// 分组成员加载失败时按不在分组内处理，不让受限请求调度到分组外的账户
+func (r *RetryableRequest) inAccountGroups(ctx context.Context, accountID uint) bool {
+	if len(r.AccountGroupIDs) == 0 {
+		return true
+	}
+	if r.groupAccounts == nil {
+		r.groupAccounts = make(map[uint]bool)
+		ids, err := r.Scheduler.repo.GetAccountIDsByGroups(r.AccountGroupIDs)
+		if err != nil {
+			logger.GetLogger("scheduler").Ctx(ctx).Error("加载账户分组成员失败 - 分组: %v, 错误: %v", r.AccountGroupIDs, err)
+		}
+		for _, id := range ids {
+			r.groupAccounts[id] = true
+		}
+	}
+	return r.groupAccounts[accountID]
+}
+
+// clientCanceledError 判断失败是否由客户端主动断开导致
+// 请求上下文被取消、或流式写入客户端失败（adapter.ErrClientDisconnected）才算客户端取消，
+// 上游返回的 "context canceled" 文本不算
+// 返回包装了 ErrClientCanceled 的错误，非客户端取消时返回 nil
+func clientCanceledError(ctx context.Context, err error) error {
+	if errors.Is(err, adapter.ErrClientDisconnected) {
+		return fmt.Errorf("%w: %v", ErrClientCanceled, err)
+	}
+	if !errors.Is(ctx.Err(), context.Canceled) {
+		return nil
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	return fmt.Errorf("%w: %v", ErrClientCanceled, err)
+}
+
+// requestTimeoutError 判断失败是否由请求超时（X-Request-Timeout 设置的截止时间）到期导致
+// 返回包装了 ErrRequestTimeout 的错误，未到期时返回 nil
+func requestTimeoutError(ctx context.Context, err error) error {
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	return fmt.Errorf("%w: %v", ErrRequestTimeout, err)
+}
+
+// acquireModelSlot 获取账户在当前模型上的并发槽位
+// 返回占用的模型键（未单独配置上限时为空，无需释放）和是否获取成功
+func (r *RetryableRequest) acquireModelSlot(ctx context.Context, account *model.Account, modelName string) (string, bool) {
+	sessionCache := r.Scheduler.GetSessionCache()
+	actualModel := GetActualModel(modelName)
+	limit := account.GetModelConcurrencyLimit(actualModel)
+	if sessionCache == nil || limit <= 0 {
+		return "", true
+	}
+	acquired, _, err := sessionCache.AcquireModelConcurrency(ctx, account.ID, actualModel, limit)
+	if err != nil {
+		// 计数出错不阻止请求
+		return "", true
+	}
+	if !acquired {
+		logger.GetLogger("scheduler").Ctx(ctx).WarnZ("账户模型并发已满",
+			logger.Uint("account_id", account.ID),
+			logger.String("account_name", account.Name),
+			logger.String("model", actualModel),
+			logger.Int("limit", limit),
+		)
+		return "", false
+	}
+	return actualModel, true
+}
+
+// shouldQueue 是否排队等待槽位：开启了排队、有因并发已满被跳过的账户且本次请求还没排过队
+func (r *RetryableRequest) shouldQueue() bool {
+	return r.Config.QueueMaxWait > 0 && len(r.fullAccounts) > 0 && !r.queued
+}
+
+// waitForQueuedSlot 在首个并发已满的账户上按优先级排队
+// 成功时返回该账户（槽位已占用）；超时返回 ErrAccountConcurrencyFull，客户端断开返回 ErrClientCanceled，
+// 请求超时到期返回 ErrRequestTimeout
+// 队列在本实例内，只和同一实例上的等待者比较优先级
+func (r *RetryableRequest) waitForQueuedSlot(ctx context.Context) (*model.Account, error) {
+	log := logger.GetLogger("scheduler").Ctx(ctx)
+	r.queued = true
+	account := r.fullAccounts[0]
+	concurrencyLimit := account.MaxConcurrency
+	if concurrencyLimit <= 0 {
+		concurrencyLimit = 5 // 默认值
+	}
+
+	sessionCache := r.Scheduler.GetSessionCache()
+	log.InfoZ("账户并发全满，排队等待槽位",
+		logger.Uint("account_id", account.ID),
+		logger.Int("priority", r.Priority),
+		logger.Int("queue_length", sessionCache.GetAccountQueueLength(account.ID)),
+		logger.Duration("max_wait", r.Config.QueueMaxWait),
+	)
+	waitStart := time.Now()
+	acquired, _, _ := sessionCache.AcquireConcurrencyWithPriority(ctx, account.ID, concurrencyLimit, r.Priority, r.Config.QueueMaxWait)
+	if acquired {
+		log.InfoZ("排队获得槽位",
+			logger.Uint("account_id", account.ID),
+			logger.Int("priority", r.Priority),
+			logger.Duration("waited", time.Since(waitStart)),
+		)
+		return account, nil
+	}
+	if timeoutErr := requestTimeoutError(ctx, ctx.Err()); timeoutErr != nil {
+		return nil, timeoutErr
+	}
+	if ctx.Err() != nil {
+		return nil, ErrClientCanceled
+	}
+	log.WarnZ("排队等待槽位超时",
+		logger.Uint("account_id", account.ID),
+		logger.Int("priority", r.Priority),
+		logger.Duration("waited", time.Since(waitStart)),
+	)
+	return nil, ErrAccountConcurrencyFull
+}
+
+// logClientCanceled 记录客户端取消日志
+func (r *RetryableRequest) logClientCanceled(ctx context.Context, modelName string, account *model.Account, err error, startTime time.Time, attempt int) {
+	logger.GetLogger("scheduler").Ctx(ctx).InfoZ("代理请求被客户端取消",
+		logger.String("model", modelName),
+		logger.Uint("account_id", account.ID),
+		logger.String("account_name", account.Name),
+		logger.Uint("user_id", r.UserID),
+		logger.Uint("api_key_id", r.APIKeyID),
+		logger.String("client_ip", r.ClientIP),
+		logger.String("error", err.Error()),
+		logger.Duration("duration", time.Since(startTime)),
+		logger.Int("attempts", attempt+1),
+	)
+}
+
+// logRequestTimeout 记录请求超时日志
+func (r *RetryableRequest) logRequestTimeout(ctx context.Context, modelName string, account *model.Account, err error, startTime time.Time, attempt int) {
+	logger.GetLogger("scheduler").Ctx(ctx).WarnZ("代理请求超时",
+		logger.String("model", modelName),
+		logger.Uint("account_id", account.ID),
+		logger.String("account_name", account.Name),
+		logger.Uint("user_id", r.UserID),
+		logger.Uint("api_key_id", r.APIKeyID),
+		logger.String("client_ip", r.ClientIP),
+		logger.String("error", err.Error()),
+		logger.Duration("duration", time.Since(startTime)),
+		logger.Int("attempts", attempt+1),
+	)
+}
+
+// publishRetry 推送重试事件到实时请求流
+func (r *RetryableRequest) publishRetry(ctx context.Context, modelName string, account *model.Account, err error, execStart time.Time, attempt int) {
+	livefeed.GetBus().Publish(&livefeed.Event{
+		Type:        livefeed.EventRetry,
+		RequestID:   logger.GetRequestID(ctx),
+		UserID:      r.UserID,
+		APIKeyID:    r.APIKeyID,
+		AccountID:   account.ID,
+		AccountName: account.Name,
+		Platform:    account.Platform,
+		Model:       modelName,
+		LatencyMs:   time.Since(execStart).Milliseconds(),
+		Attempt:     attempt + 1,
+		Message:     err.Error(),
+	})
+}
+
+// matchRetryRule 匹配错误规则中配置的重试动作，未配置时返回 nil（沿用内置判断）
+func matchRetryRule(err error) *model.ErrorRule {
+	if err == nil {
+		return nil
+	}
+
+	var httpStatusCode int
+	var upstreamErr *adapter.UpstreamError
+	if errors.As(err, &upstreamErr) {
+		httpStatusCode = upstreamErr.StatusCode
+	}
+	return errormatch.GetErrorRuleMatcher().MatchRetryAction(httpStatusCode, err.Error())
+}
+
+// markByRetryRule 错误命中"标记账户"动作时立即按规则标记账户，返回是否已标记
+func (r *RetryableRequest) markByRetryRule(err error, account *model.Account, modelName string) bool {
+	rule := matchRetryRule(err)
+	if rule == nil || rule.RetryAction != model.RetryActionMark {
+		return false
+	}
+	r.Scheduler.MarkAccountModelError(account.ID, account.Type, modelName, err)
+	return true
+}
+
+// isRetryable 判断错误是否可重试（命中重试动作规则时按规则，否则按 RetryableErrors 关键词）
 func (r *RetryableRequest) isRetryable(err error) bool {
 	if err == nil {
 		return false
 	}
+	if rule := matchRetryRule(err); rule != nil {
+		return rule.RetryAction != model.RetryActionFail
+	}
 
 	errStr := strings.ToLower(err.Error())
 
@@ -948,12 +1818,53 @@ func (r *RetryableRequest) isRetryable(err error) bool {
 	return false
 }
 
+// retrySameAccount 是否在当前账户上再试一次：瞬时错误且该账户失败次数未超过 SameAccountRetries
+// 限流（429/529）等账户本身的问题不在此列，立即换账户
+// 命中重试动作规则时只有 retry 动作在同账户重试（次数取规则的 RetryCount）
+func (r *RetryableRequest) retrySameAccount(err error, failures int) bool {
+	if rule := matchRetryRule(err); rule != nil {
+		return rule.RetryAction == model.RetryActionRetry && failures <= rule.RetryCount
+	}
+	return r.Config.SameAccountRetries > 0 && failures <= r.Config.SameAccountRetries && isTransientError(err)
+}
+
+// isTransientError 判断是否是瞬时错误（上游 5xx、连接被重置），同一账户立即重试通常就能成功
+func isTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var upstreamErr *adapter.UpstreamError
+	if errors.As(err, &upstreamErr) {
+		// 529 为上游过载，和限流一样换账户
+		return upstreamErr.StatusCode >= 500 && upstreamErr.StatusCode != 529
+	}
+
+	errStr := strings.ToLower(err.Error())
+	transientErrors := []string{
+		"connection reset",
+		"broken pipe",
+		"unexpected eof",
+		"api_error",
+	}
+	for _, transientErr := range transientErrors {
+		if strings.Contains(errStr, transientErr) {
+			return true
+		}
+	}
+	return false
+}
+
 // isConnectionError 判断是否是连接错误（流式请求开始前的错误）
 // 也包括 SSE 首个事件就是错误的情况（此时尚未向客户端写入数据）
+// 命中重试动作规则时按规则判断，fail 动作不重试
 func (r *RetryableRequest) isConnectionError(err error) bool {
 	if err == nil {
 		return false
 	}
+	if rule := matchRetryRule(err); rule != nil {
+		return rule.RetryAction != model.RetryActionFail
+	}
 
 	errStr := strings.ToLower(err.Error())
 
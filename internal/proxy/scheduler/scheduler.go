@@ -1,14 +1,20 @@
 /*
  * 文件作用：账户调度器，负责从多个AI平台账户中选择合适的账户处理请求
  * 负责功能：
- *   - 账户选择（按模型、按类型、按权重）
+ *   - 账户选择（按模型、按类型、按权重，分组策略见 group_strategy.go，账户池见 account_pool.go）
  *   - 会话粘性（同一会话路由到同一账户）
  *   - AllowedModels 过滤（账户可用模型限制）
  *   - ModelMapping 映射处理（模型名转换）
  *   - 账户状态管理（错误标记、限流恢复）
- *   - 定时恢复限流账户
+ *   - 定时恢复限流账户、跨天恢复费用/请求数超限账户
+ *   - 每日请求数上限（达到后当日移出调度）
+ *   - 恢复账户冷却期内降低调度权重（见 warmup.go）
+ *   - 上游限流窗口快耗尽时降低调度权重（见 rate_limit_window.go）
+ *   - 临时不可用标记的账户只作兜底（启动预热探测失败等）
+ *   - 多实例缓存同步（见 sync.go）
+ *   - 刷新节流（短时间内多次 Refresh / RefreshPlatform 合并为一次）
  * 重要程度：⭐⭐⭐⭐⭐ 核心（代理转发的核心调度逻辑）
- * 依赖模块：cache, model, repository, adapter
+ * 依赖模块：alert, cache, metrics, model, repository, adapter
  */
 package scheduler
 
@@ -21,8 +27,10 @@ import (
 	"sync"
 	"time"
 
+	"go-aiproxy/internal/alert"
 	"go-aiproxy/internal/cache"
 	"go-aiproxy/internal/errormatch"
+	"go-aiproxy/internal/metrics"
 	"go-aiproxy/internal/model"
 	"go-aiproxy/internal/proxy/adapter"
 	"go-aiproxy/internal/repository"
@@ -43,8 +51,36 @@ type Scheduler struct {
 	// 内存中的账户缓存
 	accounts map[string][]*model.Account // platform -> accounts
 	lastSync time.Time
+
+	// 分组调度策略（见 group_strategy.go）
+	groupRepo         *repository.AccountGroupRepository
+	groupPolicies     []*groupPolicy
+	roundRobinMu      sync.Mutex
+	roundRobinWeights map[uint]map[uint]int // groupID -> accountID -> 平滑加权轮询的当前权重
+
+	// 账户池（见 account_pool.go）
+	poolRepo              *repository.AccountPoolRepository
+	pools                 map[uint]*model.AccountPool
+	poolRoundRobinWeights map[uint]map[uint]int // poolID -> accountID -> 平滑加权轮询的当前权重
+
+	// 多实例缓存同步
+	eventRepo   *repository.AccountChangeEventRepository
+	instanceID  string
+	lastEventID uint
+
+	// Refresh 节流：最小间隔内的多次调用合并为一次延迟刷新
+	refreshMu      sync.Mutex
+	lastRefresh    time.Time // 上次实际刷新的开始时间
+	refreshPending bool      // 是否已安排延迟刷新
+
+	// RefreshPlatform 节流：按平台合并，语义同上
+	lastPlatformRefresh    map[string]time.Time // 各平台上次实际刷新的开始时间
+	platformRefreshPending map[string]bool      // 各平台是否已安排延迟刷新
 }
 
+// refreshMinInterval 两次实际刷新（全量或同一平台）的最小间隔
+const refreshMinInterval = time.Second
+
 var defaultScheduler *Scheduler
 var once sync.Once
 
@@ -55,12 +91,26 @@ func GetScheduler() *Scheduler {
 			repo:         repository.NewAccountRepository(),
 			sessionCache: cache.GetSessionCache(),
 			accounts:     make(map[string][]*model.Account),
+			eventRepo:    repository.NewAccountChangeEventRepository(),
+			instanceID:   newInstanceID(),
+
+			groupRepo:         repository.NewAccountGroupRepository(),
+			roundRobinWeights: make(map[uint]map[uint]int),
+
+			poolRepo:              repository.NewAccountPoolRepository(),
+			poolRoundRobinWeights: make(map[uint]map[uint]int),
+
+			lastPlatformRefresh:    make(map[string]time.Time),
+			platformRefreshPending: make(map[string]bool),
 		}
 		// 初始加载
 		defaultScheduler.Refresh()
 
 		// 启动定时恢复限流账号的任务
 		go defaultScheduler.startRateLimitRecoveryTask()
+
+		// 启动多实例缓存同步任务
+		go defaultScheduler.startCacheSyncTask()
 	})
 	return defaultScheduler
 }
@@ -71,39 +121,85 @@ func (s *Scheduler) GetSessionCache() *cache.SessionCache {
 }
 
 // startRateLimitRecoveryTask 启动定时恢复限流账号的任务
+// 同时恢复跨天的费用超限、请求数超限账号（午夜后一分钟内恢复）
 func (s *Scheduler) startRateLimitRecoveryTask() {
 	ticker := time.NewTicker(1 * time.Minute) // 每分钟检查一次
 	defer ticker.Stop()
 
+	log := logger.GetLogger("scheduler")
 	for range ticker.C {
 		recovered, err := s.repo.RecoverRateLimitedAccounts()
 		if err != nil {
 			continue
 		}
+		costRecovered, err := s.repo.RecoverCostLimitedAccounts()
+		if err == nil && costRecovered > 0 {
+			log.Info("恢复费用超限账号 %d 个", costRecovered)
+			recovered += costRecovered
+		}
+		requestRecovered, err := s.repo.RecoverRequestLimitedAccounts()
+		if err == nil && requestRecovered > 0 {
+			log.Info("恢复请求数超限账号 %d 个", requestRecovered)
+			recovered += requestRecovered
+		}
 		if recovered > 0 {
-			// 刷新缓存以更新内存中的账号状态
-			s.Refresh()
+			// 刷新缓存以更新内存中的账号状态，并通知其他实例
+			s.BroadcastRefresh(0, "", model.AccountStatusValid)
 		}
 	}
 }
 
-// Refresh 刷新账户缓存
+// Refresh 刷新账户缓存（节流）
+// 距上次实际刷新不足 refreshMinInterval 时不立即查库，而是安排一次延迟刷新，期间的调用都合并到这一次；
+// 任何一次调用之后都至少还会有一次从该时刻之后开始的刷新，保证最终一致
 func (s *Scheduler) Refresh() error {
+	s.refreshMu.Lock()
+	wait := refreshMinInterval - time.Since(s.lastRefresh)
+	if wait > 0 {
+		if !s.refreshPending {
+			s.refreshPending = true
+			time.AfterFunc(wait, s.runPendingRefresh)
+		}
+		s.refreshMu.Unlock()
+		return nil
+	}
+	s.lastRefresh = time.Now()
+	s.refreshMu.Unlock()
+
+	return s.refreshAll()
+}
+
+// runPendingRefresh 执行合并后的延迟刷新
+func (s *Scheduler) runPendingRefresh() {
+	s.refreshMu.Lock()
+	s.refreshPending = false
+	s.lastRefresh = time.Now()
+	s.refreshMu.Unlock()
+
+	s.refreshAll()
+}
+
+// refreshAll 从数据库全量重建账户缓存
+func (s *Scheduler) refreshAll() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	platforms := []string{model.PlatformClaude, model.PlatformOpenAI, model.PlatformGemini, model.PlatformOther}
 
+	// 先加载账户池，池内账户入缓存前套用池配置
+	s.loadPools()
 	for _, platform := range platforms {
 		accounts, err := s.repo.GetByPlatform(platform)
 		if err != nil {
 			continue
 		}
+		accounts = applyPools(accounts, s.pools)
 		s.accounts[platform] = make([]*model.Account, len(accounts))
 		for i := range accounts {
 			s.accounts[platform][i] = &accounts[i]
 		}
 	}
+	s.loadGroupPolicies()
 
 	s.lastSync = time.Now()
 	return nil
@@ -132,10 +228,10 @@ func (s *Scheduler) SelectAccountWithSession(ctx context.Context, modelName stri
 			s.mu.RUnlock()
 
 			for _, acc := range accounts {
-				if acc.ID == binding.AccountID && acc.Enabled && acc.Status == model.AccountStatusValid {
+				if acc.ID == binding.AccountID && acc.IsSchedulable() {
 					// 检查账户是否允许当前模型
-					if !s.isModelAllowed(acc, modelName) {
-						// 模型不被允许，移除会话绑定，重新选择
+					if window, _ := exhaustedUsageWindow(acc, modelName, time.Now()); !s.isModelAllowed(acc, modelName) || window != "" {
+						// 模型不被允许或用量窗口已满，移除会话绑定，重新选择
 						s.sessionCache.RemoveSessionBinding(ctx, sessionID)
 						break
 					}
@@ -173,7 +269,7 @@ func (s *Scheduler) SelectAccountWithSession(ctx context.Context, modelName stri
 	}
 
 	// 根据优先级和权重选择
-	account := s.selectByWeight(accounts)
+	account := s.selectAccount(accounts)
 
 	// 绑定会话到 Redis
 	if sessionID != "" && s.sessionCache != nil && account != nil {
@@ -193,8 +289,9 @@ func (s *Scheduler) SelectAccountWithSession(ctx context.Context, modelName stri
 
 // SelectAccountByType 根据账户类型选择
 // modelName 用于根据账户的 AllowedModels 进行过滤（可选，传空字符串表示不过滤）
-func (s *Scheduler) SelectAccountByType(ctx context.Context, accountType string, modelName string) (*model.Account, error) {
-	accounts, err := s.repo.GetEnabledByType(accountType)
+// orgID 为 API Key 所属组织，只调度同一组织的账户
+func (s *Scheduler) SelectAccountByType(ctx context.Context, accountType string, modelName string, orgID uint) (*model.Account, error) {
+	accounts, err := s.withPools(s.repo.GetEnabledByType(accountType))
 	if err != nil {
 		return nil, err
 	}
@@ -208,24 +305,26 @@ func (s *Scheduler) SelectAccountByType(ctx context.Context, accountType string,
 		accountPtrs[i] = &accounts[i]
 	}
 
-	// 根据 AllowedModels 过滤账户
-	accountPtrs = s.filterByAllowedModels(accountPtrs, modelName)
+	// 根据 AllowedModels 和组织过滤账户
+	accountPtrs = filterByOrg(s.filterByAllowedModels(accountPtrs, modelName), orgID)
 	if len(accountPtrs) == 0 {
 		return nil, ErrNoAvailableAccount
 	}
 
-	return s.selectByWeight(accountPtrs), nil
+	return s.selectAccount(accountPtrs), nil
 }
 
 // SelectAccountByTypesWithSession 根据多个账户类型选择（支持会话粘性）
 // modelName 用于根据账户的 AllowedModels 进行过滤
-func (s *Scheduler) SelectAccountByTypesWithSession(ctx context.Context, accountTypes []string, modelName string, sessionID string, userID uint, apiKeyID uint) (*model.Account, error) {
+// orgID 为 API Key 所属组织，只调度同一组织的账户（会话绑定到其他组织账户时视为不可用）
+// strict 为 true 时绑定账户不可用直接返回 ErrSessionAccountUnavailable，不换账户
+func (s *Scheduler) SelectAccountByTypesWithSession(ctx context.Context, accountTypes []string, modelName string, sessionID string, userID uint, apiKeyID uint, orgID uint, strict bool) (*model.Account, error) {
 	log := logger.GetLogger("scheduler")
 
 	// 获取所有类型的账户
 	var allAccounts []model.Account
 	for _, accountType := range accountTypes {
-		accounts, err := s.repo.GetEnabledByType(accountType)
+		accounts, err := s.withPools(s.repo.GetEnabledByType(accountType))
 		if err == nil {
 			allAccounts = append(allAccounts, accounts...)
 		}
@@ -241,8 +340,8 @@ func (s *Scheduler) SelectAccountByTypesWithSession(ctx context.Context, account
 		accountPtrs[i] = &allAccounts[i]
 	}
 
-	// 根据 AllowedModels 过滤账户
-	accountPtrs = s.filterByAllowedModels(accountPtrs, modelName)
+	// 根据 AllowedModels 和组织过滤账户
+	accountPtrs = filterByOrg(s.filterByAllowedModels(accountPtrs, modelName), orgID)
 	if len(accountPtrs) == 0 {
 		return nil, ErrNoAvailableAccount
 	}
@@ -252,19 +351,24 @@ func (s *Scheduler) SelectAccountByTypesWithSession(ctx context.Context, account
 		binding, err := s.sessionCache.GetSessionBinding(ctx, sessionID)
 		if err == nil && binding != nil {
 			for _, acc := range accountPtrs {
-				if acc.ID == binding.AccountID && acc.Enabled && acc.Status == model.AccountStatusValid {
+				if acc.ID == binding.AccountID && acc.IsSchedulable() {
 					log.Info("会话粘性命中 - SessionID: %s, 账户ID: %d, 名称: %s", sessionID, acc.ID, acc.Name)
 					s.sessionCache.UpdateSessionLastUsed(ctx, sessionID)
 					return acc, nil
 				}
 			}
+			// 严格粘性：保留绑定，由客户端决定是否重开对话
+			if strict {
+				log.Warn("严格粘性会话绑定账户不可用 - SessionID: %s, 账户ID: %d", sessionID, binding.AccountID)
+				return nil, ErrSessionAccountUnavailable
+			}
 			// 账户不可用，移除会话绑定
 			s.sessionCache.RemoveSessionBinding(ctx, sessionID)
 		}
 	}
 
 	// 根据权重选择
-	account := s.selectByWeight(accountPtrs)
+	account := s.selectAccount(accountPtrs)
 
 	// 绑定会话到 Redis
 	if sessionID != "" && s.sessionCache != nil && account != nil {
@@ -289,7 +393,7 @@ func (s *Scheduler) SelectAccountByTypeWithSession(ctx context.Context, accountT
 	log := logger.GetLogger("scheduler")
 
 	// 获取该类型的所有账户
-	accounts, err := s.repo.GetEnabledByType(accountType)
+	accounts, err := s.withPools(s.repo.GetEnabledByType(accountType))
 	if err != nil {
 		return nil, err
 	}
@@ -315,7 +419,7 @@ func (s *Scheduler) SelectAccountByTypeWithSession(ctx context.Context, accountT
 		binding, err := s.sessionCache.GetSessionBinding(ctx, sessionID)
 		if err == nil && binding != nil {
 			for _, acc := range accountPtrs {
-				if acc.ID == binding.AccountID && acc.Enabled && acc.Status == model.AccountStatusValid {
+				if acc.ID == binding.AccountID && acc.IsSchedulable() {
 					log.Info("会话粘性命中 - SessionID: %s, 账户ID: %d, 名称: %s", sessionID, acc.ID, acc.Name)
 					s.sessionCache.UpdateSessionLastUsed(ctx, sessionID)
 					return acc, nil
@@ -327,7 +431,7 @@ func (s *Scheduler) SelectAccountByTypeWithSession(ctx context.Context, accountT
 	}
 
 	// 根据权重选择
-	account := s.selectByWeight(accountPtrs)
+	account := s.selectAccount(accountPtrs)
 
 	// 绑定会话到 Redis
 	if sessionID != "" && s.sessionCache != nil && account != nil {
@@ -346,6 +450,17 @@ func (s *Scheduler) SelectAccountByTypeWithSession(ctx context.Context, accountT
 	return account, nil
 }
 
+// filterByOrg 只保留指定组织的账户
+func filterByOrg(accounts []*model.Account, orgID uint) []*model.Account {
+	filtered := make([]*model.Account, 0, len(accounts))
+	for _, acc := range accounts {
+		if acc.OrgID == orgID {
+			filtered = append(filtered, acc)
+		}
+	}
+	return filtered
+}
+
 // filterByAllowedModels 根据 AllowedModels 过滤账户
 // 如果账户设置了 AllowedModels，则只有请求的模型在列表中才返回该账户
 // 如果账户没有设置 AllowedModels（空），则该账户可用于所有模型
@@ -361,6 +476,7 @@ func (s *Scheduler) filterByAllowedModels(accounts []*model.Account, modelName s
 // 1. 如果账户配置了 ModelMapping 且包含原始模型，使用映射后的模型检查 AllowedModels
 // 2. 否则直接用请求模型检查 AllowedModels
 // 3. 如果账户没有设置 AllowedModels，则允许所有模型
+// 4. Claude 账户用量窗口对该模型已用满时跳过（见 usage_window.go）
 func (s *Scheduler) filterByAllowedModelsWithOriginal(accounts []*model.Account, mappedModel string, originalModel string) []*model.Account {
 	log := logger.GetLogger("scheduler")
 
@@ -369,6 +485,7 @@ func (s *Scheduler) filterByAllowedModelsWithOriginal(accounts []*model.Account,
 	}
 
 	var filtered []*model.Account
+	now := time.Now()
 
 	for _, acc := range accounts {
 		// 确定用于 AllowedModels 检查的模型名
@@ -418,6 +535,13 @@ func (s *Scheduler) filterByAllowedModelsWithOriginal(accounts []*model.Account,
 			continue
 		}
 
+		// Claude 用量窗口：请求模型对应的窗口已用满时跳过（其他模型族仍可使用该账户）
+		if window, _ := exhaustedUsageWindow(acc, checkModel, now); window != "" {
+			log.Debug("账户用量窗口已满 - ID: %d, Name: %s, 窗口: %s, CheckModel: %s",
+				acc.ID, acc.Name, window, checkModel)
+			continue
+		}
+
 		filtered = append(filtered, acc)
 	}
 
@@ -513,26 +637,33 @@ func getAccountMappedModel(acc *model.Account, originalModel string) string {
 }
 
 // selectByWeight 根据权重选择账户
+// 刚恢复的账户在冷却期内按 warmupMultiplier 降低权重，逐步放量
+// 权重为 0（负数按 0 处理）的账户只作为兜底：有正权重账户时不会被选中；全部为 0 时均匀随机
 func (s *Scheduler) selectByWeight(accounts []*model.Account) *model.Account {
+	if len(accounts) == 0 {
+		return nil
+	}
 	if len(accounts) == 1 {
 		return accounts[0]
 	}
 
 	// 计算总权重
+	now := time.Now()
+	weights := make([]int, len(accounts))
 	totalWeight := 0
-	for _, acc := range accounts {
-		// 优先级 * 权重
-		totalWeight += acc.Priority * acc.Weight
+	for i, acc := range accounts {
+		weights[i] = effectiveWeight(acc, now)
+		totalWeight += weights[i]
 	}
 
-	if totalWeight == 0 {
+	if totalWeight <= 0 {
 		return accounts[rand.Intn(len(accounts))]
 	}
 
-	// 随机选择
+	// 随机选择（权重为 0 的账户占用区间为空，不会被选中）
 	r := rand.Intn(totalWeight)
-	for _, acc := range accounts {
-		r -= acc.Priority * acc.Weight
+	for i, acc := range accounts {
+		r -= weights[i]
 		if r < 0 {
 			return acc
 		}
@@ -541,6 +672,28 @@ func (s *Scheduler) selectByWeight(accounts []*model.Account) *model.Account {
 	return accounts[0]
 }
 
+// effectiveWeight 账户的调度权重：优先级 * 权重 * 冷却期乘数 * 限流窗口乘数，负数按 0 处理
+// 带临时不可用标记（启动预热探测失败、管理员手动标记）或处于成功率熔断期的账户权重为 0，只作兜底
+func effectiveWeight(acc *model.Account, now time.Time) int {
+	if acc.Priority <= 0 || acc.Weight <= 0 {
+		return 0
+	}
+	if successRateTripped(acc, now) {
+		return 0
+	}
+	if unavailable, _ := cache.GetUnavailableMarker().IsUnavailable(acc.ID); unavailable {
+		return 0
+	}
+	weight := acc.Priority * acc.Weight
+	if multiplier := warmupMultiplier(acc, now) * rateLimitMultiplier(acc, now); multiplier < 1 {
+		weight = int(float64(weight) * multiplier)
+		if weight < 1 {
+			weight = 1
+		}
+	}
+	return weight
+}
+
 // MarkAccountError 标记账户错误
 func (s *Scheduler) MarkAccountError(accountID uint, accountType string, err error) {
 	s.MarkAccountErrorWithReset(accountID, accountType, err, nil)
@@ -554,6 +707,10 @@ func (s *Scheduler) MarkAccountErrorWithReset(accountID uint, accountType string
 		errMsg = err.Error()
 	}
 
+	// 冷却期内出错，清零连续成功计数
+	recordWarmupResult(s.cachedAccount(accountID), false)
+	recordSuccessRate(accountID, false)
+
 	// 根据错误类型决定状态
 	status := model.AccountStatusValid
 
@@ -562,6 +719,7 @@ func (s *Scheduler) MarkAccountErrorWithReset(accountID uint, accountType string
 		// API Key 模式只记录错误，不改变状态，但保存错误信息
 		s.repo.IncrementErrorCount(accountID)
 		s.repo.UpdateLastError(accountID, errMsg)
+		metrics.IncAccountStatusMark(false, "unchanged")
 		return
 	}
 
@@ -593,6 +751,12 @@ func (s *Scheduler) MarkAccountErrorWithReset(accountID uint, accountType string
 		}
 	}
 
+	// 告警需要变更前的账户信息（名称、旧状态），仅在需要告警时查询
+	var before *model.Account
+	if status != model.AccountStatusValid && alert.Enabled() {
+		before, _ = s.repo.GetByID(accountID)
+	}
+
 	if resetAt != nil && status == model.AccountStatusRateLimited {
 		s.repo.UpdateStatusWithRateLimit(accountID, status, errMsg, resetAt)
 	} else {
@@ -606,13 +770,61 @@ func (s *Scheduler) MarkAccountErrorWithReset(accountID uint, accountType string
 	}
 
 	s.repo.IncrementErrorCount(accountID)
+	metrics.IncAccountStatusMark(false, status)
+
+	// 账户不再可用，刷新本地缓存并通知其他实例
+	if status != model.AccountStatusValid {
+		s.BroadcastRefresh(accountID, s.cachedPlatformOf(accountID), status)
+		alert.NotifyAccountStatus(before, status, errMsg)
+	}
 }
 
 // MarkAccountSuccess 标记账户成功
 func (s *Scheduler) MarkAccountSuccess(accountID uint) {
 	s.repo.IncrementRequestCount(accountID)
-	// 如果之前是错误状态，恢复正常
-	s.repo.UpdateStatus(accountID, model.AccountStatusValid, "")
+	recordWarmupResult(s.cachedAccount(accountID), true)
+	recordSuccessRate(accountID, true)
+	// 如果之前是错误状态，恢复正常（费用/请求数超限除外）
+	s.repo.RestoreValidStatus(accountID)
+	metrics.IncAccountStatusMark(true, model.AccountStatusValid)
+
+	s.markRequestLimitedIfOverLimit(accountID)
+}
+
+// ReserveDailyRequest 发起上游请求前占用一次账户的当日请求数，已达到每日请求上限时返回 false 并移出调度
+// 在拿到并发槽位时占用，进行中的请求不会叠加超出上限；请求失败时调用 ReleaseDailyRequest 退回
+func (s *Scheduler) ReserveDailyRequest(account *model.Account) bool {
+	if account.DailyRequestLimit <= 0 {
+		return true
+	}
+	reserved, err := s.repo.ReserveDailyRequest(account.ID)
+	if err != nil {
+		// 数据库错误不阻止请求
+		logger.GetLogger("scheduler").Warn("占用当日请求数失败 | AccountID: %d | Error: %v", account.ID, err)
+		return true
+	}
+	if !reserved {
+		s.markRequestLimitedIfOverLimit(account.ID)
+	}
+	return reserved
+}
+
+// ReleaseDailyRequest 请求失败时退回占用的当日请求数
+func (s *Scheduler) ReleaseDailyRequest(account *model.Account) {
+	if account.DailyRequestLimit <= 0 {
+		return
+	}
+	if err := s.repo.ReleaseDailyRequest(account.ID); err != nil {
+		logger.GetLogger("scheduler").Warn("退回当日请求数失败 | AccountID: %d | Error: %v", account.ID, err)
+	}
+}
+
+// markRequestLimitedIfOverLimit 当日请求数达到每日请求上限：移出调度至次日
+func (s *Scheduler) markRequestLimitedIfOverLimit(accountID uint) {
+	if limited, err := s.repo.MarkRequestLimitedIfOverLimit(accountID); err == nil && limited {
+		logger.GetLogger("scheduler").Warn("账户当日请求数达到每日请求上限，暂停调度至次日 | AccountID: %d", accountID)
+		s.BroadcastRefresh(accountID, s.cachedPlatformOf(accountID), model.AccountStatusRequestLimited)
+	}
 }
 
 // DetectPlatform 根据模型名检测平台
@@ -664,24 +876,41 @@ func detectPlatformFallback(modelName string) string {
 	return ""
 }
 
+// knownAccountTypePrefixes "type,model" 格式中允许的前缀（平台名或具体账户类型）
+var knownAccountTypePrefixes = map[string]bool{
+	model.PlatformClaude:             true,
+	model.PlatformOpenAI:             true,
+	model.PlatformGemini:             true,
+	model.AccountTypeClaudeOfficial:  true,
+	model.AccountTypeClaudeConsole:   true,
+	model.AccountTypeBedrock:         true,
+	model.AccountTypeOpenAIResponses: true,
+	model.AccountTypeAzureOpenAI:     true,
+	model.AccountTypeGeminiAPI:       true,
+	model.AccountTypeDroid:           true,
+}
+
+// splitAccountTypePrefix 拆分 "type,model" 格式
+// 只有逗号前缀是已知账户类型时才拆分，否则整体视为模型名（模型名本身可能含逗号）
+func splitAccountTypePrefix(modelName string) (accountType string, actualModel string) {
+	prefix, rest, found := strings.Cut(modelName, ",")
+	if !found || !knownAccountTypePrefixes[prefix] {
+		return "", modelName
+	}
+	return prefix, rest
+}
+
 // DetectAccountType 根据模型名检测账户类型（用于更精确的路由）
 func DetectAccountType(modelName string) string {
 	// 支持 "type,model" 格式，如 "bedrock,claude-3-5-sonnet"
-	if strings.Contains(modelName, ",") {
-		parts := strings.SplitN(modelName, ",", 2)
-		return parts[0]
-	}
-
-	return ""
+	accountType, _ := splitAccountTypePrefix(modelName)
+	return accountType
 }
 
-// GetActualModel 获取实际模型名（去掉前缀）
+// GetActualModel 获取实际模型名（去掉已知的账户类型前缀）
 func GetActualModel(modelName string) string {
-	if strings.Contains(modelName, ",") {
-		parts := strings.SplitN(modelName, ",", 2)
-		return parts[1]
-	}
-	return modelName
+	_, actualModel := splitAccountTypePrefix(modelName)
+	return actualModel
 }
 
 // truncateString 截断字符串
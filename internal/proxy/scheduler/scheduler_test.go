@@ -0,0 +1,147 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"go-aiproxy/internal/model"
+)
+
+func TestSplitAccountTypePrefix(t *testing.T) {
+	tests := []struct {
+		name        string
+		modelName   string
+		accountType string
+		actualModel string
+	}{
+		{"无前缀", "claude-3-5-sonnet", "", "claude-3-5-sonnet"},
+		{"平台前缀", "claude,claude-3-5-sonnet", "claude", "claude-3-5-sonnet"},
+		{"账户类型前缀", "bedrock,claude-3-5-sonnet", "bedrock", "claude-3-5-sonnet"},
+		{"账户类型前缀且模型名含逗号", "gemini,gemini-1.5-pro,latest", "gemini", "gemini-1.5-pro,latest"},
+		{"模型名含逗号", "gemini-1.5-pro,latest", "", "gemini-1.5-pro,latest"},
+		{"未知前缀", "foo,claude-3-5-sonnet", "", "foo,claude-3-5-sonnet"},
+		{"前缀大小写不同视为未知", "Bedrock,claude-3-5-sonnet", "", "Bedrock,claude-3-5-sonnet"},
+		{"空前缀", ",claude-3-5-sonnet", "", ",claude-3-5-sonnet"},
+		{"只有前缀", "openai,", "openai", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accountType, actualModel := splitAccountTypePrefix(tt.modelName)
+			if accountType != tt.accountType || actualModel != tt.actualModel {
+				t.Fatalf("splitAccountTypePrefix(%q) = (%q, %q), want (%q, %q)",
+					tt.modelName, accountType, actualModel, tt.accountType, tt.actualModel)
+			}
+			if got := DetectAccountType(tt.modelName); got != tt.accountType {
+				t.Fatalf("DetectAccountType(%q) = %q, want %q", tt.modelName, got, tt.accountType)
+			}
+			if got := GetActualModel(tt.modelName); got != tt.actualModel {
+				t.Fatalf("GetActualModel(%q) = %q, want %q", tt.modelName, got, tt.actualModel)
+			}
+		})
+	}
+}
+
+// newWeightedAccounts 按 (优先级, 权重) 构造账户，ID 从 1000 开始
+func newWeightedAccounts(pairs ...[2]int) []*model.Account {
+	accounts := make([]*model.Account, 0, len(pairs))
+	for i, pair := range pairs {
+		accounts = append(accounts, &model.Account{ID: uint(1000 + i), Priority: pair[0], Weight: pair[1]})
+	}
+	return accounts
+}
+
+// countSelections 多次选择并统计每个账户被选中的次数
+func countSelections(s *Scheduler, accounts []*model.Account, rounds int) map[uint]int {
+	counts := make(map[uint]int)
+	for i := 0; i < rounds; i++ {
+		if acc := s.selectByWeight(accounts); acc != nil {
+			counts[acc.ID]++
+		}
+	}
+	return counts
+}
+
+func TestSelectByWeightEmptyAndSingle(t *testing.T) {
+	s := &Scheduler{}
+	if acc := s.selectByWeight(nil); acc != nil {
+		t.Fatalf("selectByWeight(nil) = %v, want nil", acc)
+	}
+
+	// 单个账户无论权重如何都直接返回（包括权重为 0 和负数）
+	for _, pair := range [][2]int{{1, 1}, {0, 0}, {-1, 5}, {3, -2}} {
+		accounts := newWeightedAccounts(pair)
+		if acc := s.selectByWeight(accounts); acc != accounts[0] {
+			t.Fatalf("selectByWeight(single %v) = %v, want the only account", pair, acc)
+		}
+	}
+}
+
+func TestSelectByWeightAllZeroFallsBackToUniform(t *testing.T) {
+	s := &Scheduler{}
+	tests := []struct {
+		name     string
+		accounts []*model.Account
+	}{
+		{"全部为 0", newWeightedAccounts([2]int{0, 1}, [2]int{1, 0}, [2]int{0, 0})},
+		{"全部为负数", newWeightedAccounts([2]int{-1, 1}, [2]int{1, -1}, [2]int{-3, -3})},
+	}
+
+	const rounds = 3000
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counts := countSelections(s, tt.accounts, rounds)
+			// 均匀随机时每个账户期望 1000 次，只要求明显不是总选第一个
+			for _, acc := range tt.accounts {
+				if counts[acc.ID] < rounds/len(tt.accounts)/2 {
+					t.Fatalf("账户 %d 被选中 %d 次，未均匀随机: %v", acc.ID, counts[acc.ID], counts)
+				}
+			}
+		})
+	}
+}
+
+func TestSelectByWeightNegativeTreatedAsZero(t *testing.T) {
+	s := &Scheduler{}
+	// 有正权重账户时，权重为 0 或负数的账户只作兜底，不会被选中
+	accounts := newWeightedAccounts([2]int{-5, 10}, [2]int{1, 1}, [2]int{2, -3}, [2]int{0, 4})
+	counts := countSelections(s, accounts, 1000)
+	if counts[accounts[1].ID] != 1000 {
+		t.Fatalf("只有正权重账户应被选中: %v", counts)
+	}
+}
+
+func TestSelectByWeightProportional(t *testing.T) {
+	s := &Scheduler{}
+	// 有效权重 1:3
+	accounts := newWeightedAccounts([2]int{1, 1}, [2]int{1, 3})
+	const rounds = 4000
+	counts := countSelections(s, accounts, rounds)
+	if heavy := counts[accounts[1].ID]; heavy < rounds/2 || heavy > rounds*9/10 {
+		t.Fatalf("权重 3 的账户被选中 %d/%d 次，不符合 1:3 的比例", heavy, rounds)
+	}
+}
+
+func TestEffectiveWeight(t *testing.T) {
+	tests := []struct {
+		name     string
+		priority int
+		weight   int
+		want     int
+	}{
+		{"正常", 2, 3, 6},
+		{"优先级为 0", 0, 3, 0},
+		{"权重为 0", 2, 0, 0},
+		{"优先级为负数", -1, 3, 0},
+		{"权重为负数", 2, -3, 0},
+		{"都为负数", -2, -3, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acc := &model.Account{ID: 2000, Priority: tt.priority, Weight: tt.weight}
+			if got := effectiveWeight(acc, time.Now()); got != tt.want {
+				t.Fatalf("effectiveWeight(priority=%d, weight=%d) = %d, want %d", tt.priority, tt.weight, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"go-aiproxy/internal/model"
+	"go-aiproxy/internal/repository"
+	"go-aiproxy/pkg/logger"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestMain(m *testing.M) {
+	// 选账户时会写日志，日志写到临时目录
+	dir, err := os.MkdirTemp("", "scheduler-test-logs")
+	if err != nil {
+		panic(err)
+	}
+	if err := logger.Init(dir, logger.LevelError); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// fakeAccountDB 内存版账户表和分组成员表，只实现调度选账户用到的查询
+type fakeAccountDB struct {
+	accounts     []model.Account
+	groupMembers []uint // 属于套餐分组的账户 ID
+}
+
+func (db *fakeAccountDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{db: db}, nil }
+func (db *fakeAccountDB) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ db *fakeAccountDB }
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return nil, errors.New("tx not supported") }
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	switch {
+	case strings.Contains(query, "`account_group_members`"):
+		if !strings.Contains(query, "DISTINCT") {
+			return &fakeRows{}, nil
+		}
+		rows := &fakeRows{columns: []string{"account_id"}}
+		for _, id := range c.db.groupMembers {
+			rows.values = append(rows.values, []driver.Value{int64(id)})
+		}
+		return rows, nil
+	case strings.Contains(query, "`accounts`"):
+		return c.queryAccounts(query, args), nil
+	}
+	return &fakeRows{}, nil
+}
+
+// queryAccounts 支持按 ID、精确类型、类型前缀查询
+func (c *fakeConn) queryAccounts(query string, args []driver.NamedValue) driver.Rows {
+	rows := &fakeRows{columns: []string{"id", "name", "type", "platform", "status", "enabled", "priority", "weight", "org_id", "region"}}
+	for _, acc := range c.db.accounts {
+		var match bool
+		switch {
+		case strings.Contains(query, "`accounts`.`id` ="):
+			match = uint64(acc.ID) == toUint64(args[0].Value)
+		case strings.Contains(query, "type LIKE"):
+			match = strings.HasPrefix(acc.Type, strings.TrimSuffix(args[0].Value.(string), "%"))
+		case strings.Contains(query, "type ="):
+			match = acc.Type == args[0].Value.(string)
+		}
+		if match {
+			rows.values = append(rows.values, []driver.Value{
+				int64(acc.ID), acc.Name, acc.Type, acc.Platform, acc.Status, acc.Enabled,
+				int64(acc.Priority), int64(acc.Weight), int64(acc.OrgID), acc.Region,
+			})
+		}
+	}
+	return rows
+}
+
+func toUint64(v driver.Value) uint64 {
+	switch n := v.(type) {
+	case int64:
+		return uint64(n)
+	case uint64:
+		return n
+	}
+	return 0
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// newFakeDBScheduler 用内存账户表构造调度器（替换全局 DB，测试结束恢复）
+func newFakeDBScheduler(t *testing.T, db *fakeAccountDB) *Scheduler {
+	t.Helper()
+	gdb, err := gorm.Open(mysql.New(mysql.Config{Conn: sql.OpenDB(db), SkipInitializeWithVersion: true}),
+		&gorm.Config{DisableAutomaticPing: true, Logger: gormlogger.Discard})
+	if err != nil {
+		t.Fatalf("open fake db: %v", err)
+	}
+	prev := repository.DB
+	repository.DB = gdb
+	t.Cleanup(func() { repository.DB = prev })
+
+	return &Scheduler{
+		repo:     repository.NewAccountRepository(),
+		accounts: make(map[string][]*model.Account),
+	}
+}
+
+// selectedAccountIDs 多次选择并记录选中过的账户
+func selectedAccountIDs(t *testing.T, newRequest func() *RetryableRequest, modelName string, rounds int) map[uint]bool {
+	t.Helper()
+	selected := make(map[uint]bool)
+	for i := 0; i < rounds; i++ {
+		acc, err := newRequest().SelectAccount(context.Background(), modelName)
+		if err != nil {
+			t.Fatalf("SelectAccount(%q) error: %v", modelName, err)
+		}
+		selected[acc.ID] = true
+	}
+	return selected
+}
+
+func newSchedulableAccount(id uint, accountType, platform string) model.Account {
+	return model.Account{
+		ID: id, Name: accountType, Type: accountType, Platform: platform,
+		Status: model.AccountStatusValid, Enabled: true, Priority: 50, Weight: 100,
+	}
+}
+
+// Responses 接口：只调度 openai-responses / openai 类型中属于套餐分组的账户
+func TestSelectAccountResponsesPathHonoursPackageGroups(t *testing.T) {
+	db := &fakeAccountDB{
+		accounts: []model.Account{
+			newSchedulableAccount(1, model.AccountTypeOpenAIResponses, model.PlatformOpenAI),
+			newSchedulableAccount(2, model.AccountTypeOpenAIResponses, model.PlatformOpenAI),
+			newSchedulableAccount(3, model.AccountTypeOpenAI, model.PlatformOpenAI),
+		},
+		groupMembers: []uint{2, 3},
+	}
+	s := newFakeDBScheduler(t, db)
+	modelName := model.PlatformOpenAI + ",gpt-5-codex"
+
+	// 未限定分组时三个账户都会被调度到
+	all := selectedAccountIDs(t, func() *RetryableRequest {
+		return NewRetryableRequest(s, nil).WithAccountTypes(model.AccountTypeOpenAIResponses, model.AccountTypeOpenAI)
+	}, modelName, 200)
+	if len(all) != 3 {
+		t.Fatalf("selected %v without groups, want all 3 accounts", all)
+	}
+
+	grouped := selectedAccountIDs(t, func() *RetryableRequest {
+		return NewRetryableRequest(s, nil).
+			WithAccountTypes(model.AccountTypeOpenAIResponses, model.AccountTypeOpenAI).
+			WithAccountGroups([]uint{7})
+	}, modelName, 200)
+	if grouped[1] || !grouped[2] || !grouped[3] {
+		t.Fatalf("selected %v with package groups, want only accounts 2 and 3", grouped)
+	}
+
+	// 分组内没有对应类型的账户时不退回分组外账户
+	db.groupMembers = []uint{99}
+	_, err := NewRetryableRequest(s, nil).
+		WithAccountTypes(model.AccountTypeOpenAIResponses, model.AccountTypeOpenAI).
+		WithAccountGroups([]uint{7}).
+		SelectAccount(context.Background(), modelName)
+	if !errors.Is(err, ErrNoAvailableAccount) {
+		t.Fatalf("SelectAccount with empty group = %v, want ErrNoAvailableAccount", err)
+	}
+}
+
+// Message Batches 接口：只调度 claude-console 类型中属于套餐分组的账户
+func TestSelectAccountBatchPathHonoursPackageGroups(t *testing.T) {
+	db := &fakeAccountDB{
+		accounts: []model.Account{
+			newSchedulableAccount(1, model.AccountTypeClaudeConsole, model.PlatformClaude),
+			newSchedulableAccount(2, model.AccountTypeClaudeConsole, model.PlatformClaude),
+			newSchedulableAccount(3, model.AccountTypeClaudeOfficial, model.PlatformClaude),
+		},
+		groupMembers: []uint{1, 3},
+	}
+	s := newFakeDBScheduler(t, db)
+	modelName := model.AccountTypeClaudeConsole + ",claude-sonnet-4-5"
+
+	grouped := selectedAccountIDs(t, func() *RetryableRequest {
+		return NewRetryableRequest(s, nil).
+			WithAccountTypes(model.AccountTypeClaudeConsole).
+			WithAccountGroups([]uint{7})
+	}, modelName, 200)
+	if len(grouped) != 1 || !grouped[1] {
+		t.Fatalf("selected %v with package groups, want only account 1", grouped)
+	}
+}
+
+// Message Batches 接口：强制指定账户和偏好 region 与其他代理接口一致
+func TestSelectAccountBatchPathHonoursForcedAccountAndRegion(t *testing.T) {
+	db := &fakeAccountDB{
+		accounts: []model.Account{
+			newSchedulableAccount(1, model.AccountTypeClaudeConsole, model.PlatformClaude),
+			newSchedulableAccount(2, model.AccountTypeClaudeConsole, model.PlatformClaude),
+			newSchedulableAccount(3, model.AccountTypeClaudeOfficial, model.PlatformClaude),
+		},
+	}
+	db.accounts[1].Region = "us-east"
+	s := newFakeDBScheduler(t, db)
+	modelName := model.AccountTypeClaudeConsole + ",claude-sonnet-4-5"
+	newBatchRequest := func() *RetryableRequest {
+		return NewRetryableRequest(s, nil).WithAccountTypes(model.AccountTypeClaudeConsole)
+	}
+
+	forced := selectedAccountIDs(t, func() *RetryableRequest { return newBatchRequest().WithForcedAccount(1) }, modelName, 50)
+	if len(forced) != 1 || !forced[1] {
+		t.Fatalf("selected %v with forced account 1, want only account 1", forced)
+	}
+
+	// 强制指定的账户类型不支持批次时直接报错，不退回正常调度
+	_, err := newBatchRequest().WithForcedAccount(3).SelectAccount(context.Background(), modelName)
+	if !errors.Is(err, ErrForcedAccountUnavailable) {
+		t.Fatalf("SelectAccount with forced claude-official account = %v, want ErrForcedAccountUnavailable", err)
+	}
+
+	regional := selectedAccountIDs(t, func() *RetryableRequest { return newBatchRequest().WithPreferredRegion("us-east") }, modelName, 50)
+	if len(regional) != 1 || !regional[2] {
+		t.Fatalf("selected %v with preferred region, want only account 2", regional)
+	}
+}
@@ -0,0 +1,68 @@
+/*
+ * 文件作用：影子账户选择，为复制流量（dark traffic）挑选与主请求同平台的影子账户
+ * 负责功能：
+ *   - 定期从数据库加载影子账户（不进入正式调度缓存）
+ *   - 按主请求账户的平台和请求模型过滤可用的影子账户
+ * 重要程度：⭐⭐ 辅助（新上游质量评估）
+ * 依赖模块：model, repository
+ */
+package scheduler
+
+import (
+	"sync"
+	"time"
+
+	"go-aiproxy/internal/model"
+	"go-aiproxy/pkg/logger"
+)
+
+// shadowAccountsTTL 影子账户列表的缓存时间，修改账户后最多延迟该时间生效
+const shadowAccountsTTL = 30 * time.Second
+
+var (
+	shadowAccounts       []model.Account
+	shadowAccountsLoaded time.Time
+	shadowAccountsMu     sync.Mutex
+)
+
+// ShadowAccounts 返回与主请求账户同平台、允许请求模型的影子账户
+func (s *Scheduler) ShadowAccounts(primaryAccountID uint, modelName string) []*model.Account {
+	primary := s.cachedAccount(primaryAccountID)
+	if primary == nil {
+		acc, err := s.repo.GetByID(primaryAccountID)
+		if err != nil || acc == nil {
+			return nil
+		}
+		primary = acc
+	}
+
+	var result []*model.Account
+	for _, acc := range s.loadShadowAccounts() {
+		if acc.ID != primary.ID && acc.Platform == primary.Platform && s.isModelAllowed(acc, GetActualModel(modelName)) {
+			result = append(result, acc)
+		}
+	}
+	return result
+}
+
+// loadShadowAccounts 获取影子账户列表（带缓存），加载失败时沿用上次结果
+func (s *Scheduler) loadShadowAccounts() []*model.Account {
+	shadowAccountsMu.Lock()
+	defer shadowAccountsMu.Unlock()
+
+	if time.Since(shadowAccountsLoaded) >= shadowAccountsTTL {
+		accounts, err := s.withPools(s.repo.GetShadowAccounts())
+		if err != nil {
+			logger.GetLogger("scheduler").Warn("加载影子账户失败: %v", err)
+		} else {
+			shadowAccounts = accounts
+		}
+		shadowAccountsLoaded = time.Now()
+	}
+
+	result := make([]*model.Account, len(shadowAccounts))
+	for i := range shadowAccounts {
+		result[i] = &shadowAccounts[i]
+	}
+	return result
+}
@@ -0,0 +1,125 @@
+/*
+ * 文件作用：账户成功率熔断，间歇性失败（成功/失败交替）的账户攒不够连续错误时也能移出调度
+ * 负责功能：
+ *   - 熔断配置（滑动窗口大小、成功率阈值、熔断时长）
+ *   - 内存记录各账户最近 N 次请求的成功/失败
+ *   - 窗口填满且成功率低于阈值时熔断：熔断期内调度权重为 0，只作兜底
+ *   - 熔断到期后清空窗口重新统计，与连续错误阈值互不影响（任一触发都处理）
+ * 重要程度：⭐⭐⭐ 一般（调度稳定性）
+ * 依赖模块：model, logger
+ */
+package scheduler
+
+import (
+	"sync"
+	"time"
+
+	"go-aiproxy/internal/model"
+	"go-aiproxy/pkg/logger"
+)
+
+// SuccessRateConfig 账户成功率熔断配置
+type SuccessRateConfig struct {
+	WindowSize int           // 滑动窗口大小（最近多少次请求），0 表示关闭
+	Threshold  float64       // 成功率阈值（0-1），窗口填满后低于该值熔断
+	Cooldown   time.Duration // 熔断时长，到期后重新统计
+}
+
+// DefaultSuccessRateConfig 默认成功率熔断配置（默认关闭，与系统配置默认值一致；阈值和熔断时长在开启窗口后生效）
+var DefaultSuccessRateConfig = SuccessRateConfig{
+	WindowSize: 0,
+	Threshold:  0.5,
+	Cooldown:   5 * time.Minute,
+}
+
+var (
+	currentSuccessRateConfig   = DefaultSuccessRateConfig
+	currentSuccessRateConfigMu sync.RWMutex
+)
+
+// SetSuccessRateConfig 更新运行时成功率熔断配置（启动和配置变更时调用，已有的统计窗口清空重来）
+func SetSuccessRateConfig(cfg SuccessRateConfig) {
+	currentSuccessRateConfigMu.Lock()
+	currentSuccessRateConfig = cfg
+	currentSuccessRateConfigMu.Unlock()
+
+	successRateWindowsMu.Lock()
+	successRateWindows = make(map[uint]*successRateWindow)
+	successRateWindowsMu.Unlock()
+}
+
+// CurrentSuccessRateConfig 获取当前运行时成功率熔断配置
+func CurrentSuccessRateConfig() SuccessRateConfig {
+	currentSuccessRateConfigMu.RLock()
+	defer currentSuccessRateConfigMu.RUnlock()
+	return currentSuccessRateConfig
+}
+
+// successRateWindow 账户最近 N 次请求结果（环形缓冲）和熔断截止时间
+type successRateWindow struct {
+	results   []bool
+	next      int
+	filled    bool
+	successes int
+	openUntil time.Time
+}
+
+var (
+	successRateWindows   = make(map[uint]*successRateWindow) // accountID -> 统计窗口
+	successRateWindowsMu sync.Mutex
+)
+
+// recordSuccessRate 记录一次请求结果，窗口填满且成功率低于阈值时熔断
+func recordSuccessRate(accountID uint, success bool) {
+	cfg := CurrentSuccessRateConfig()
+	if accountID == 0 || cfg.WindowSize <= 0 || cfg.Threshold <= 0 {
+		return
+	}
+
+	now := time.Now()
+	successRateWindowsMu.Lock()
+	w := successRateWindows[accountID]
+	if w == nil || len(w.results) != cfg.WindowSize {
+		w = &successRateWindow{results: make([]bool, cfg.WindowSize)}
+		successRateWindows[accountID] = w
+	}
+	if now.Before(w.openUntil) {
+		// 熔断期内（兜底调度）的结果不计入，到期后重新统计
+		successRateWindowsMu.Unlock()
+		return
+	}
+
+	if w.filled && w.results[w.next] {
+		w.successes--
+	}
+	w.results[w.next] = success
+	if success {
+		w.successes++
+	}
+	w.next = (w.next + 1) % len(w.results)
+	if w.next == 0 {
+		w.filled = true
+	}
+
+	rate := float64(w.successes) / float64(len(w.results))
+	tripped := w.filled && rate < cfg.Threshold
+	if tripped {
+		w.openUntil = now.Add(cfg.Cooldown)
+		w.results = make([]bool, cfg.WindowSize)
+		w.next, w.filled, w.successes = 0, false, 0
+	}
+	successRateWindowsMu.Unlock()
+
+	if tripped {
+		logger.GetLogger("scheduler").Warn("账户成功率低于阈值，暂时移出调度 | AccountID: %d | 最近 %d 次成功率: %.0f%% | 阈值: %.0f%% | 熔断: %v",
+			accountID, cfg.WindowSize, rate*100, cfg.Threshold*100, cfg.Cooldown)
+	}
+}
+
+// successRateTripped 账户是否处于成功率熔断期
+func successRateTripped(acc *model.Account, now time.Time) bool {
+	successRateWindowsMu.Lock()
+	defer successRateWindowsMu.Unlock()
+	w := successRateWindows[acc.ID]
+	return w != nil && now.Before(w.openUntil)
+}
@@ -0,0 +1,187 @@
+/*
+ * 文件作用：调度器缓存多实例同步，保证各实例的账户缓存一致
+ * 负责功能：
+ *   - 账户状态变更后发布变更事件（数据库事件表）
+ *   - 定时增量拉取其他实例的事件并刷新对应平台缓存
+ *   - 缓存最大存活时间兜底（事件丢失时强制全量刷新）
+ *   - 过期事件清理
+ * 重要程度：⭐⭐⭐⭐ 重要（多实例部署的调度正确性）
+ * 依赖模块：model, repository
+ */
+package scheduler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+
+	"go-aiproxy/internal/model"
+	"go-aiproxy/pkg/logger"
+)
+
+const (
+	cacheSyncInterval    = 2 * time.Second  // 拉取变更事件的间隔
+	cacheMaxAge          = 60 * time.Second // 缓存最大存活时间，超过后强制全量刷新（事件丢失兜底）
+	eventBatchSize       = 200              // 单次拉取事件上限
+	eventRetention       = time.Hour        // 事件保留时长
+	eventCleanupInterval = 10 * time.Minute // 事件清理间隔
+)
+
+// newInstanceID 生成实例标识（主机名 + 进程号 + 随机串）
+func newInstanceID() string {
+	hostname, _ := os.Hostname()
+	b := make([]byte, 4)
+	rand.Read(b)
+	return fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), hex.EncodeToString(b))
+}
+
+// RefreshPlatform 只刷新指定平台的账户缓存（节流），platform 为空时全量刷新
+// 同一平台距上次实际刷新不足 refreshMinInterval 时安排一次延迟刷新，期间的调用都合并到这一次；
+// 已安排全量刷新时直接交给全量刷新
+func (s *Scheduler) RefreshPlatform(platform string) error {
+	if platform == "" {
+		return s.Refresh()
+	}
+
+	s.refreshMu.Lock()
+	if s.refreshPending {
+		s.refreshMu.Unlock()
+		return nil
+	}
+	wait := refreshMinInterval - time.Since(s.lastPlatformRefresh[platform])
+	if wait > 0 {
+		if !s.platformRefreshPending[platform] {
+			s.platformRefreshPending[platform] = true
+			time.AfterFunc(wait, func() { s.runPendingPlatformRefresh(platform) })
+		}
+		s.refreshMu.Unlock()
+		return nil
+	}
+	s.lastPlatformRefresh[platform] = time.Now()
+	s.refreshMu.Unlock()
+
+	return s.refreshPlatform(platform)
+}
+
+// runPendingPlatformRefresh 执行合并后的单平台延迟刷新
+func (s *Scheduler) runPendingPlatformRefresh(platform string) {
+	s.refreshMu.Lock()
+	s.platformRefreshPending[platform] = false
+	s.lastPlatformRefresh[platform] = time.Now()
+	s.refreshMu.Unlock()
+
+	s.refreshPlatform(platform)
+}
+
+// refreshPlatform 从数据库重建指定平台的账户缓存
+func (s *Scheduler) refreshPlatform(platform string) error {
+	accounts, err := s.withPools(s.repo.GetByPlatform(platform))
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accounts[platform] = make([]*model.Account, len(accounts))
+	for i := range accounts {
+		s.accounts[platform][i] = &accounts[i]
+	}
+	return nil
+}
+
+// BroadcastRefresh 本实例修改了账户后调用：刷新本地缓存，并通知其他实例刷新
+// platform 为空时刷新所有平台（批量变更或不确定平台时使用）
+func (s *Scheduler) BroadcastRefresh(accountID uint, platform string, status string) {
+	s.RefreshPlatform(platform)
+
+	event := &model.AccountChangeEvent{
+		AccountID:  accountID,
+		Platform:   platform,
+		Status:     status,
+		InstanceID: s.instanceID,
+	}
+	if err := s.eventRepo.Create(event); err != nil {
+		logger.GetLogger("scheduler").Warn("发布账户变更事件失败 - AccountID: %d, Error: %v", accountID, err)
+	}
+}
+
+// cachedPlatformOf 从缓存中查找账户所属平台
+func (s *Scheduler) cachedPlatformOf(accountID uint) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for platform, accounts := range s.accounts {
+		for _, acc := range accounts {
+			if acc.ID == accountID {
+				return platform
+			}
+		}
+	}
+	return ""
+}
+
+// startCacheSyncTask 启动缓存同步任务
+func (s *Scheduler) startCacheSyncTask() {
+	log := logger.GetLogger("scheduler")
+
+	// 从当前最新事件开始，启动时的全量加载已包含之前的变更
+	latestID, err := s.eventRepo.GetLatestID()
+	if err != nil {
+		log.Warn("获取账户变更事件起点失败: %v", err)
+	}
+	s.lastEventID = latestID
+
+	ticker := time.NewTicker(cacheSyncInterval)
+	defer ticker.Stop()
+	lastCleanup := time.Now()
+
+	for range ticker.C {
+		s.pollChangeEvents()
+
+		// 兜底：缓存超过最大存活时间强制全量刷新
+		s.mu.RLock()
+		age := time.Since(s.lastSync)
+		s.mu.RUnlock()
+		if age > cacheMaxAge {
+			s.Refresh()
+		}
+
+		if time.Since(lastCleanup) > eventCleanupInterval {
+			lastCleanup = time.Now()
+			if _, err := s.eventRepo.DeleteBefore(time.Now().Add(-eventRetention)); err != nil {
+				log.Warn("清理账户变更事件失败: %v", err)
+			}
+		}
+	}
+}
+
+// pollChangeEvents 拉取其他实例发布的事件并刷新对应平台缓存
+func (s *Scheduler) pollChangeEvents() {
+	events, err := s.eventRepo.ListAfter(s.lastEventID, eventBatchSize)
+	if err != nil || len(events) == 0 {
+		return
+	}
+	s.lastEventID = events[len(events)-1].ID
+
+	platforms := make(map[string]bool)
+	for _, event := range events {
+		if event.InstanceID == s.instanceID {
+			continue // 本实例发布时已刷新
+		}
+		platforms[event.Platform] = true
+	}
+	if len(platforms) == 0 {
+		return
+	}
+
+	// 任一事件未指定平台则全量刷新
+	if platforms[""] {
+		s.Refresh()
+		return
+	}
+	for platform := range platforms {
+		s.RefreshPlatform(platform)
+	}
+}
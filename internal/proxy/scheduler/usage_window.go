@@ -0,0 +1,78 @@
+/*
+ * 文件作用：Claude 用量窗口按模型族调度，某个模型族的 7 天窗口用满时账户仍可接其他模型族的请求
+ * 负责功能：
+ *   - 内存记录各账户最新的用量窗口（OAuth Usage API 查询结果）
+ *   - 调度过滤时按请求模型检查对应窗口（5H/7D 共享窗口 + 7D Opus/Sonnet 模型族窗口）
+ *   - 只有模型族窗口用满时，429 不把整个账户标记为限流
+ *   - 重启后内存为空时使用账户表中持久化的窗口
+ * 重要程度：⭐⭐⭐ 一般（Claude 账户利用率）
+ * 依赖模块：model, adapter, metrics, logger
+ */
+package scheduler
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"go-aiproxy/internal/metrics"
+	"go-aiproxy/internal/model"
+	"go-aiproxy/internal/proxy/adapter"
+	"go-aiproxy/pkg/logger"
+)
+
+var (
+	claudeUsageWindows   = make(map[uint]*model.ClaudeUsageWindows) // accountID -> 用量窗口
+	claudeUsageWindowsMu sync.Mutex
+)
+
+// UpdateClaudeUsageWindows 记录账户最新的 Claude 用量窗口（Usage API 查询成功后调用，写库由调用方负责）
+func (s *Scheduler) UpdateClaudeUsageWindows(accountID uint, windows *model.ClaudeUsageWindows) {
+	if accountID == 0 || windows == nil {
+		return
+	}
+	claudeUsageWindowsMu.Lock()
+	claudeUsageWindows[accountID] = windows
+	claudeUsageWindowsMu.Unlock()
+}
+
+// exhaustedUsageWindow 返回限制账户接收该模型请求的已用满窗口名，可用时返回空
+// 第二个返回值表示该窗口只限制请求模型所属的模型族；非 Claude 账户或没有用量数据时总是可用
+func exhaustedUsageWindow(acc *model.Account, modelName string, now time.Time) (string, bool) {
+	if acc.Platform != model.PlatformClaude {
+		return "", false
+	}
+
+	claudeUsageWindowsMu.Lock()
+	windows := claudeUsageWindows[acc.ID]
+	claudeUsageWindowsMu.Unlock()
+
+	if windows == nil {
+		windows = acc.StoredClaudeUsageWindows()
+		if windows == nil {
+			return "", false
+		}
+	}
+	return windows.ExhaustedWindow(modelName, now)
+}
+
+// MarkAccountModelError 标记账户在请求指定模型时出错
+// Claude 账户只是请求模型所属模型族的 7 天窗口用满（共享窗口未满）时，429 不把整个账户标记为限流：
+// 调度按模型族过滤该账户即可，其他模型族的请求仍可使用，窗口重置后自动恢复
+func (s *Scheduler) MarkAccountModelError(accountID uint, accountType string, modelName string, err error) {
+	var upstreamErr *adapter.UpstreamError
+	if errors.As(err, &upstreamErr) && upstreamErr.StatusCode == http.StatusTooManyRequests {
+		if acc := s.cachedAccount(accountID); acc != nil {
+			if window, familyScoped := exhaustedUsageWindow(acc, GetActualModel(modelName), time.Now()); familyScoped {
+				logger.GetLogger("scheduler").Info("模型族用量窗口已满，账户仅对该模型族不可用 | AccountID: %d | 模型: %s | 窗口: %s",
+					accountID, GetActualModel(modelName), window)
+				s.repo.IncrementErrorCount(accountID)
+				s.repo.UpdateLastError(accountID, err.Error())
+				metrics.IncAccountStatusMark(false, "unchanged")
+				return
+			}
+		}
+	}
+	s.MarkAccountError(accountID, accountType, err)
+}
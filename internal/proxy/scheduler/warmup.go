@@ -0,0 +1,131 @@
+/*
+ * 文件作用：账户恢复冷却期（探测性放量），避免刚恢复的账户被积压请求立即打回限流
+ * 负责功能：
+ *   - 冷却期配置（时长、初始流量比例、提前放开所需的连续成功次数）
+ *   - 按恢复后经过的时间线性提升调度权重
+ *   - 冷却期内连续成功计数，达到阈值后完全放开
+ * 重要程度：⭐⭐⭐ 一般（调度平滑）
+ * 依赖模块：model
+ */
+package scheduler
+
+import (
+	"sync"
+	"time"
+
+	"go-aiproxy/internal/model"
+)
+
+// WarmupConfig 账户恢复冷却期配置
+type WarmupConfig struct {
+	Duration       time.Duration // 冷却时长，0 表示关闭
+	InitialRatio   float64       // 刚恢复时的权重比例（0-1），之后随时间线性升至 1
+	SuccessRelease int           // 冷却期内连续成功多少次后完全放开，0 表示不提前放开
+}
+
+// DefaultWarmupConfig 默认冷却期配置
+var DefaultWarmupConfig = WarmupConfig{
+	Duration:       10 * time.Minute,
+	InitialRatio:   0.1,
+	SuccessRelease: 20,
+}
+
+var (
+	currentWarmupConfig   = DefaultWarmupConfig
+	currentWarmupConfigMu sync.RWMutex
+)
+
+// SetWarmupConfig 更新运行时冷却期配置（启动和配置变更时调用）
+func SetWarmupConfig(cfg WarmupConfig) {
+	currentWarmupConfigMu.Lock()
+	defer currentWarmupConfigMu.Unlock()
+	currentWarmupConfig = cfg
+}
+
+// CurrentWarmupConfig 获取当前运行时冷却期配置
+func CurrentWarmupConfig() WarmupConfig {
+	currentWarmupConfigMu.RLock()
+	defer currentWarmupConfigMu.RUnlock()
+	return currentWarmupConfig
+}
+
+// warmupProgress 冷却期内的连续成功计数
+type warmupProgress struct {
+	recoveredAt time.Time // 对应的恢复时间（账户再次恢复时重新计数）
+	successes   int
+}
+
+var (
+	warmupProgresses   = make(map[uint]*warmupProgress) // accountID -> 进度
+	warmupProgressesMu sync.Mutex
+)
+
+// warmupMultiplier 计算账户当前的权重乘数，不在冷却期内返回 1
+func warmupMultiplier(acc *model.Account, now time.Time) float64 {
+	cfg := CurrentWarmupConfig()
+	if cfg.Duration <= 0 || acc.RecoveredAt == nil {
+		return 1
+	}
+	elapsed := now.Sub(*acc.RecoveredAt)
+	if elapsed < 0 || elapsed >= cfg.Duration {
+		return 1
+	}
+
+	if cfg.SuccessRelease > 0 {
+		warmupProgressesMu.Lock()
+		progress := warmupProgresses[acc.ID]
+		released := progress != nil && progress.recoveredAt.Equal(*acc.RecoveredAt) && progress.successes >= cfg.SuccessRelease
+		warmupProgressesMu.Unlock()
+		if released {
+			return 1
+		}
+	}
+
+	ratio := cfg.InitialRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = DefaultWarmupConfig.InitialRatio
+	}
+	return ratio + (1-ratio)*float64(elapsed)/float64(cfg.Duration)
+}
+
+// recordWarmupResult 记录冷却期内账户的请求结果，失败时清零连续成功计数
+func recordWarmupResult(acc *model.Account, success bool) {
+	if acc == nil || acc.RecoveredAt == nil {
+		return
+	}
+
+	warmupProgressesMu.Lock()
+	defer warmupProgressesMu.Unlock()
+
+	cfg := CurrentWarmupConfig()
+	if cfg.Duration <= 0 || time.Since(*acc.RecoveredAt) >= cfg.Duration {
+		delete(warmupProgresses, acc.ID)
+		return
+	}
+
+	progress := warmupProgresses[acc.ID]
+	if progress == nil || !progress.recoveredAt.Equal(*acc.RecoveredAt) {
+		progress = &warmupProgress{recoveredAt: *acc.RecoveredAt}
+		warmupProgresses[acc.ID] = progress
+	}
+	if success {
+		progress.successes++
+	} else {
+		progress.successes = 0
+	}
+}
+
+// cachedAccount 从缓存中查找账户
+func (s *Scheduler) cachedAccount(accountID uint) *model.Account {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, accounts := range s.accounts {
+		for _, acc := range accounts {
+			if acc.ID == accountID {
+				return acc
+			}
+		}
+	}
+	return nil
+}
@@ -1,20 +1,24 @@
 /*
  * 文件作用：账户数据仓库，提供上游账户的数据库操作
  * 负责功能：
- *   - 账户CRUD操作
+ *   - 账户CRUD操作（含批量创建）
  *   - 按平台/类型/状态查询
- *   - 账户状态管理（限流/恢复/封号）
+ *   - 账户状态管理（限流/恢复/封号/每日预算与请求数超限）
+ *   - 上游限流窗口（x-ratelimit-* 响应头快照）
  *   - 健康检查调度
  *   - 账户分组管理
+ *   - 健康检查查询套用账户池配置
  * 重要程度：⭐⭐⭐⭐⭐ 核心（账户核心仓库）
- * 依赖模块：model, gorm
+ * 依赖模块：model, utils, gorm
  */
 package repository
 
 import (
+	"context"
 	"time"
 
 	"go-aiproxy/internal/model"
+	"go-aiproxy/pkg/utils"
 
 	"gorm.io/gorm"
 )
@@ -27,6 +31,11 @@ func NewAccountRepository() *AccountRepository {
 	return &AccountRepository{db: DB}
 }
 
+// WithOrg 返回限定组织的仓库（见 ScopeOrg），orgID 为 0 时不限制
+func (r *AccountRepository) WithOrg(orgID uint) *AccountRepository {
+	return &AccountRepository{db: ScopeOrg(r.db, orgID)}
+}
+
 // Account CRUD
 
 func (r *AccountRepository) Create(account *model.Account) error {
@@ -52,6 +61,44 @@ func (r *AccountRepository) GetByIDs(ids []uint) ([]model.Account, error) {
 	return accounts, err
 }
 
+// GetAccountIDsByGroups 获取属于任一指定分组的账户 ID（用于套餐限定可调度账户池）
+func (r *AccountRepository) GetAccountIDsByGroups(groupIDs []uint) ([]uint, error) {
+	var ids []uint
+	if len(groupIDs) == 0 {
+		return ids, nil
+	}
+	err := r.db.Table("account_group_members").
+		Where("account_group_id IN ?", groupIDs).
+		Distinct("account_id").
+		Pluck("account_id", &ids).Error
+	return ids, err
+}
+
+// CreateBatch 在同一事务中批量创建账户，任一失败则全部回滚
+func (r *AccountRepository) CreateBatch(accounts []*model.Account) error {
+	if len(accounts) == 0 {
+		return nil
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, account := range accounts {
+			if err := tx.Create(account).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetExistingNames 返回给定名称中已存在的账户名
+func (r *AccountRepository) GetExistingNames(names []string) ([]string, error) {
+	var existing []string
+	if len(names) == 0 {
+		return existing, nil
+	}
+	err := r.db.Model(&model.Account{}).Where("name IN ?", names).Pluck("name", &existing).Error
+	return existing, err
+}
+
 func (r *AccountRepository) Update(account *model.Account) error {
 	// 使用 Select 指定所有字段，确保 nil 值也能被更新
 	return r.db.Model(account).Select("*").Updates(account).Error
@@ -93,8 +140,8 @@ func (r *AccountRepository) List(page, pageSize int, platform, status string) ([
 
 func (r *AccountRepository) GetByPlatform(platform string) ([]model.Account, error) {
 	var accounts []model.Account
-	err := r.db.Where("platform = ? AND enabled = ? AND status = ?",
-		platform, true, model.AccountStatusValid).
+	err := r.db.Where("platform = ? AND enabled = ? AND status = ? AND maintenance_mode = ? AND shadow_mode = ?",
+		platform, true, model.AccountStatusValid, false, false).
 		Order("priority DESC, weight DESC").
 		Find(&accounts).Error
 	return accounts, err
@@ -102,8 +149,8 @@ func (r *AccountRepository) GetByPlatform(platform string) ([]model.Account, err
 
 func (r *AccountRepository) GetEnabledByType(accountType string) ([]model.Account, error) {
 	var accounts []model.Account
-	err := r.db.Where("type = ? AND enabled = ? AND status = ?",
-		accountType, true, model.AccountStatusValid).
+	err := r.db.Where("type = ? AND enabled = ? AND status = ? AND maintenance_mode = ? AND shadow_mode = ?",
+		accountType, true, model.AccountStatusValid, false, false).
 		Order("priority DESC, weight DESC").
 		Find(&accounts).Error
 	return accounts, err
@@ -113,13 +160,22 @@ func (r *AccountRepository) GetEnabledByType(accountType string) ([]model.Accoun
 // 例如传入 "claude" 会匹配 "claude-official", "claude-console", "claude-bedrock" 等
 func (r *AccountRepository) GetEnabledByTypePrefix(typePrefix string) ([]model.Account, error) {
 	var accounts []model.Account
-	err := r.db.Where("type LIKE ? AND enabled = ? AND status = ?",
-		typePrefix+"%", true, model.AccountStatusValid).
+	err := r.db.Where("type LIKE ? AND enabled = ? AND status = ? AND maintenance_mode = ? AND shadow_mode = ?",
+		typePrefix+"%", true, model.AccountStatusValid, false, false).
 		Order("priority DESC, weight DESC").
 		Find(&accounts).Error
 	return accounts, err
 }
 
+// GetShadowAccounts 获取启用且状态正常的影子账户（不参与正式调度，只接收复制流量）
+func (r *AccountRepository) GetShadowAccounts() ([]model.Account, error) {
+	var accounts []model.Account
+	err := r.db.Preload("Proxy").Where("shadow_mode = ? AND enabled = ? AND status = ? AND maintenance_mode = ?",
+		true, true, model.AccountStatusValid, false).
+		Find(&accounts).Error
+	return accounts, err
+}
+
 func (r *AccountRepository) UpdateStatus(id uint, status string, lastError string) error {
 	updates := map[string]interface{}{
 		"status": status,
@@ -135,6 +191,18 @@ func (r *AccountRepository) UpdateStatus(id uint, status string, lastError strin
 	return r.db.Model(&model.Account{}).Where("id = ?", id).Updates(updates).Error
 }
 
+// SetMaintenanceMode 设置账户维护模式，进入时记录开始时间
+func (r *AccountRepository) SetMaintenanceMode(id uint, enabled bool) error {
+	updates := map[string]interface{}{
+		"maintenance_mode":  enabled,
+		"maintenance_since": nil,
+	}
+	if enabled {
+		updates["maintenance_since"] = time.Now()
+	}
+	return r.db.Model(&model.Account{}).Where("id = ?", id).Updates(updates).Error
+}
+
 // SetEnabled 设置账户启用状态
 func (r *AccountRepository) SetEnabled(id uint, enabled bool) error {
 	return r.db.Model(&model.Account{}).Where("id = ?", id).Update("enabled", enabled).Error
@@ -164,6 +232,7 @@ func (r *AccountRepository) RecoverRateLimitedAccounts() (int64, error) {
 		Updates(map[string]interface{}{
 			"status":              model.AccountStatusValid,
 			"rate_limit_reset_at": nil,
+			"recovered_at":        time.Now(),
 		})
 	return result.RowsAffected, result.Error
 }
@@ -194,12 +263,33 @@ type ClaudeUsageData struct {
 		Utilization *float64 `json:"utilization"`
 		ResetsAt    string   `json:"resets_at"`
 	} `json:"seven_day"`
+	SevenDayOpus struct {
+		Utilization *float64 `json:"utilization"`
+		ResetsAt    string   `json:"resets_at"`
+	} `json:"seven_day_opus"`
 	SevenDaySonnet struct {
 		Utilization *float64 `json:"utilization"`
 		ResetsAt    string   `json:"resets_at"`
 	} `json:"seven_day_sonnet"`
 }
 
+// Windows 转换为调度使用的用量窗口（重置时间解析失败时视为未知）
+func (u *ClaudeUsageData) Windows() *model.ClaudeUsageWindows {
+	window := func(utilization *float64, resetsAt string) model.ClaudeUsageWindow {
+		w := model.ClaudeUsageWindow{Utilization: utilization}
+		if t, err := time.Parse(time.RFC3339, resetsAt); err == nil {
+			w.ResetsAt = &t
+		}
+		return w
+	}
+	return &model.ClaudeUsageWindows{
+		FiveHour:       window(u.FiveHour.Utilization, u.FiveHour.ResetsAt),
+		SevenDay:       window(u.SevenDay.Utilization, u.SevenDay.ResetsAt),
+		SevenDayOpus:   window(u.SevenDayOpus.Utilization, u.SevenDayOpus.ResetsAt),
+		SevenDaySonnet: window(u.SevenDaySonnet.Utilization, u.SevenDaySonnet.ResetsAt),
+	}
+}
+
 // UpdateClaudeUsage 更新 Claude 账号用量数据
 func (r *AccountRepository) UpdateClaudeUsage(id uint, usage *ClaudeUsageData) error {
 	now := time.Now()
@@ -227,6 +317,16 @@ func (r *AccountRepository) UpdateClaudeUsage(id uint, usage *ClaudeUsageData) e
 		}
 	}
 
+	// 7天Opus窗口
+	if usage.SevenDayOpus.Utilization != nil {
+		updates["seven_day_opus_utilization"] = *usage.SevenDayOpus.Utilization
+	}
+	if usage.SevenDayOpus.ResetsAt != "" {
+		if t, err := time.Parse(time.RFC3339, usage.SevenDayOpus.ResetsAt); err == nil {
+			updates["seven_day_opus_resets_at"] = t
+		}
+	}
+
 	// 7天Sonnet窗口
 	if usage.SevenDaySonnet.Utilization != nil {
 		updates["seven_day_sonnet_utilization"] = *usage.SevenDaySonnet.Utilization
@@ -240,14 +340,57 @@ func (r *AccountRepository) UpdateClaudeUsage(id uint, usage *ClaudeUsageData) e
 	return r.db.Model(&model.Account{}).Where("id = ?", id).Updates(updates).Error
 }
 
+// UpdateRateLimitWindow 保存上游限流窗口快照（整体覆盖，上游未返回的字段置空）
+func (r *AccountRepository) UpdateRateLimitWindow(id uint, window *model.RateLimitWindow) error {
+	return r.db.Model(&model.Account{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"rate_limit_limit_requests":     window.LimitRequests,
+		"rate_limit_remaining_requests": window.RemainingRequests,
+		"rate_limit_reset_requests_at":  window.ResetRequestsAt,
+		"rate_limit_limit_tokens":       window.LimitTokens,
+		"rate_limit_remaining_tokens":   window.RemainingTokens,
+		"rate_limit_reset_tokens_at":    window.ResetTokensAt,
+		"rate_limit_updated_at":         window.UpdatedAt,
+	}).Error
+}
+
+// IncrementRequestCount 累计请求次数，同时累计当日请求数（跨天时从 1 重新计数）
+// 设置了每日请求上限的账户在发起请求前已由 ReserveDailyRequest 计入当日请求数，这里不再重复累计
 func (r *AccountRepository) IncrementRequestCount(id uint) error {
+	today := time.Now().Format("2006-01-02")
+	// 注意：MySQL 按顺序赋值，daily_request_count 必须在 daily_request_date 之前更新（GORM 按列名排序）
 	return r.db.Model(&model.Account{}).Where("id = ?", id).
 		Updates(map[string]interface{}{
 			"request_count": gorm.Expr("request_count + 1"),
-			"last_used_at":  gorm.Expr("NOW()"),
+			"daily_request_count": gorm.Expr("IF(daily_request_limit > 0, daily_request_count, IF(daily_request_date = ?, daily_request_count + 1, 1))",
+				today),
+			"daily_request_date": gorm.Expr("IF(daily_request_limit > 0, daily_request_date, ?)", today),
+			"last_used_at":       gorm.Expr("NOW()"),
 		}).Error
 }
 
+// ReserveDailyRequest 发起请求前占用一次当日请求数（跨天时从 1 重新计数）
+// 条件更新保证多个进行中的请求（包括其他实例）合计不超过每日请求上限，已达上限时返回 false
+func (r *AccountRepository) ReserveDailyRequest(id uint) (bool, error) {
+	today := time.Now().Format("2006-01-02")
+	// 注意：MySQL 按顺序赋值，daily_request_count 必须在 daily_request_date 之前更新（GORM 按列名排序）
+	result := r.db.Model(&model.Account{}).
+		Where("id = ? AND (daily_request_limit <= 0 OR daily_request_date IS NULL OR daily_request_date <> ? OR daily_request_count < daily_request_limit)",
+			id, today).
+		Updates(map[string]interface{}{
+			"daily_request_count": gorm.Expr("IF(daily_request_date = ?, daily_request_count + 1, 1)", today),
+			"daily_request_date":  today,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ReleaseDailyRequest 请求失败时退回 ReserveDailyRequest 占用的当日请求数（已跨天时不处理）
+func (r *AccountRepository) ReleaseDailyRequest(id uint) error {
+	today := time.Now().Format("2006-01-02")
+	return r.db.Model(&model.Account{}).
+		Where("id = ? AND daily_request_date = ? AND daily_request_count > 0", id, today).
+		Update("daily_request_count", gorm.Expr("daily_request_count - 1")).Error
+}
+
 func (r *AccountRepository) IncrementErrorCount(id uint) error {
 	return r.db.Model(&model.Account{}).Where("id = ?", id).
 		Update("error_count", gorm.Expr("error_count + 1")).Error
@@ -269,6 +412,12 @@ func (r *AccountRepository) UpdateLastError(id uint, lastError string) error {
 		}).Error
 }
 
+// UpdateInvalidAPIKeys 更新健康检查标记为失效的 API Key 列表（JSON 数组，空字符串表示全部有效）
+func (r *AccountRepository) UpdateInvalidAPIKeys(id uint, invalidKeys string) error {
+	return r.db.Model(&model.Account{}).Where("id = ?", id).
+		Update("invalid_api_keys", invalidKeys).Error
+}
+
 // UpdateTotalCost 更新账户总费用
 func (r *AccountRepository) UpdateTotalCost(id uint, totalCost float64) error {
 	return r.db.Model(&model.Account{}).Where("id = ?", id).
@@ -277,8 +426,76 @@ func (r *AccountRepository) UpdateTotalCost(id uint, totalCost float64) error {
 
 // IncrementTotalCost 增量更新账户总费用（原子操作）
 func (r *AccountRepository) IncrementTotalCost(id uint, cost float64) error {
+	today := time.Now().Format("2006-01-02")
+	// 同时累计当日费用，跨天时从本次费用重新累计
+	// 注意：MySQL 按顺序赋值，daily_cost 必须在 daily_cost_date 之前更新（GORM 按列名排序）
 	return r.db.Model(&model.Account{}).Where("id = ?", id).
-		Update("total_cost", gorm.Expr("total_cost + ?", cost)).Error
+		Updates(map[string]interface{}{
+			"total_cost":      gorm.Expr("total_cost + ?", cost),
+			"daily_cost":      gorm.Expr("IF(daily_cost_date = ?, daily_cost + ?, ?)", today, cost, cost),
+			"daily_cost_date": today,
+		}).Error
+}
+
+// MarkCostLimitedIfOverBudget 当日费用达到每日预算时标记为费用超限，返回本次是否标记
+func (r *AccountRepository) MarkCostLimitedIfOverBudget(id uint) (bool, error) {
+	today := time.Now().Format("2006-01-02")
+	result := r.db.Model(&model.Account{}).
+		Where("id = ? AND status = ? AND daily_budget > 0 AND daily_cost_date = ? AND daily_cost >= daily_budget",
+			id, model.AccountStatusValid, today).
+		Updates(map[string]interface{}{
+			"status":        model.AccountStatusCostLimited,
+			"last_error":    "当日费用已达到每日预算",
+			"last_error_at": gorm.Expr("NOW()"),
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// RecoverCostLimitedAccounts 恢复费用超限的账号
+// 跨天（本地时间）、预算被取消或调高到当日费用之上时恢复为 valid
+func (r *AccountRepository) RecoverCostLimitedAccounts() (int64, error) {
+	today := time.Now().Format("2006-01-02")
+	result := r.db.Model(&model.Account{}).
+		Where("status = ? AND (daily_cost_date IS NULL OR daily_cost_date <> ? OR daily_budget <= 0 OR daily_cost < daily_budget)",
+			model.AccountStatusCostLimited, today).
+		Update("status", model.AccountStatusValid)
+	return result.RowsAffected, result.Error
+}
+
+// MarkRequestLimitedIfOverLimit 当日请求数达到每日请求上限时标记为请求数超限，返回本次是否标记
+func (r *AccountRepository) MarkRequestLimitedIfOverLimit(id uint) (bool, error) {
+	today := time.Now().Format("2006-01-02")
+	result := r.db.Model(&model.Account{}).
+		Where("id = ? AND status = ? AND daily_request_limit > 0 AND daily_request_date = ? AND daily_request_count >= daily_request_limit",
+			id, model.AccountStatusValid, today).
+		Updates(map[string]interface{}{
+			"status":        model.AccountStatusRequestLimited,
+			"last_error":    "当日请求数已达到每日请求上限",
+			"last_error_at": gorm.Expr("NOW()"),
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// RecoverRequestLimitedAccounts 恢复请求数超限的账号
+// 跨天（本地时间）、上限被取消或调高到当日请求数之上时恢复为 valid
+func (r *AccountRepository) RecoverRequestLimitedAccounts() (int64, error) {
+	today := time.Now().Format("2006-01-02")
+	result := r.db.Model(&model.Account{}).
+		Where("status = ? AND (daily_request_date IS NULL OR daily_request_date <> ? OR daily_request_limit <= 0 OR daily_request_count < daily_request_limit)",
+			model.AccountStatusRequestLimited, today).
+		Update("status", model.AccountStatusValid)
+	return result.RowsAffected, result.Error
+}
+
+// RestoreValidStatus 请求成功后恢复为正常状态
+// 费用/请求数超限状态不在此恢复，只由跨天重置解除（避免进行中的请求完成后把账号放回调度）
+func (r *AccountRepository) RestoreValidStatus(id uint) error {
+	return r.db.Model(&model.Account{}).
+		Where("id = ? AND status NOT IN ?", id, []string{model.AccountStatusCostLimited, model.AccountStatusRequestLimited}).
+		Updates(map[string]interface{}{
+			"status":              model.AccountStatusValid,
+			"rate_limit_reset_at": nil,
+		}).Error
 }
 
 // GetTotalCostByIDs 批量获取账户费用
@@ -299,6 +516,58 @@ func (r *AccountRepository) GetTotalCostByIDs(ids []uint) (map[uint]float64, err
 	return result, nil
 }
 
+// GetAll 获取所有账户（不区分启用状态，用于汇总统计）
+func (r *AccountRepository) GetAll() ([]model.Account, error) {
+	var accounts []model.Account
+	err := r.db.Find(&accounts).Error
+	return accounts, err
+}
+
+// AccountStatusCount 按平台和状态分组的账户数量
+type AccountStatusCount struct {
+	Platform string
+	Status   string
+	Enabled  bool
+	Count    int64
+}
+
+// CountByPlatformAndStatus 按平台、状态、启用状态统计账户数量（用于监控指标）
+func (r *AccountRepository) CountByPlatformAndStatus() ([]AccountStatusCount, error) {
+	var counts []AccountStatusCount
+	err := r.db.Model(&model.Account{}).
+		Select("platform, status, enabled, COUNT(*) AS count").
+		Group("platform, status, enabled").
+		Scan(&counts).Error
+	return counts, err
+}
+
+// ListEnabledForHealthSummary 获取所有启用账户的用量和错误计数（不限状态，包括限流中、不可调度的账户）
+// 只查询健康汇总用到的列
+func (r *AccountRepository) ListEnabledForHealthSummary() ([]model.Account, error) {
+	var accounts []model.Account
+	err := r.db.Select("id, name, type, platform, status, enabled, max_concurrency, request_count, error_count, "+
+		"five_hour_utilization, seven_day_utilization, seven_day_opus_utilization, seven_day_sonnet_utilization").
+		Where("enabled = ?", true).Find(&accounts).Error
+	return accounts, err
+}
+
+// CountSchedulable 统计可参与调度的账户数量（启用、状态正常、非维护模式、非影子账户），用于就绪探针
+// ctx 用于控制查询超时，数据库慢时不阻塞探针
+func (r *AccountRepository) CountSchedulable(ctx context.Context) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&model.Account{}).
+		Where("enabled = ? AND status = ? AND maintenance_mode = ? AND shadow_mode = ?", true, model.AccountStatusValid, false, false).
+		Count(&count).Error
+	return count, err
+}
+
+// GetEnabledPlatforms 获取存在启用账户的平台列表（用于 /v1/models 聚合）
+func (r *AccountRepository) GetEnabledPlatforms() ([]string, error) {
+	var platforms []string
+	err := r.db.Model(&model.Account{}).Where("enabled = ?", true).Distinct("platform").Pluck("platform", &platforms).Error
+	return platforms, err
+}
+
 func (r *AccountRepository) GetAllEnabled() ([]model.Account, error) {
 	var accounts []model.Account
 	err := r.db.Where("enabled = ?", true).Find(&accounts).Error
@@ -306,11 +575,21 @@ func (r *AccountRepository) GetAllEnabled() ([]model.Account, error) {
 }
 
 func (r *AccountRepository) UpdateToken(id uint, accessToken, refreshToken string, expiry *time.Time) error {
+	// 按 map 更新不会触发模型钩子，凭证需自行加密
+	encryptedAccess, err := utils.EncryptField(accessToken)
+	if err != nil {
+		return err
+	}
 	updates := map[string]interface{}{
-		"access_token": accessToken,
+		"access_token":       encryptedAccess,
+		"token_refreshed_at": time.Now(),
 	}
 	if refreshToken != "" {
-		updates["refresh_token"] = refreshToken
+		encryptedRefresh, err := utils.EncryptField(refreshToken)
+		if err != nil {
+			return err
+		}
+		updates["refresh_token"] = encryptedRefresh
 	}
 	if expiry != nil {
 		updates["token_expiry"] = expiry
@@ -331,9 +610,84 @@ func (r *AccountRepository) GetAccountsForHealthCheck() ([]model.Account, error)
 		model.AccountStatusValid, model.AccountStatusRateLimited).
 		Preload("Proxy").
 		Find(&accounts).Error
+	if err == nil {
+		// 池内账户继承池配置（代理、Base URL 等），健康检查与实际请求使用相同配置
+		err = r.ApplyPools(accounts)
+	}
+	return accounts, err
+}
+
+// GetAccountsForCheckAll 获取一键检测的账号（含代理）：ids 非空时按 ID，否则按平台，平台为空时取全部
+func (r *AccountRepository) GetAccountsForCheckAll(ids []uint, platform string) ([]model.Account, error) {
+	var accounts []model.Account
+	query := r.db.Preload("Proxy")
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	} else if platform != "" {
+		query = query.Where("platform = ?", platform)
+	}
+	err := query.Order("id ASC").Find(&accounts).Error
+	if err == nil {
+		// 池内账户继承池配置（代理、Base URL 等），健康检查与实际请求使用相同配置
+		err = r.ApplyPools(accounts)
+	}
+	return accounts, err
+}
+
+// GetAccountsWithExpiringToken 获取 Token 将在 before 之前过期、且可用 SessionKey 刷新的账号
+func (r *AccountRepository) GetAccountsWithExpiringToken(before time.Time) ([]model.Account, error) {
+	var accounts []model.Account
+	err := r.db.Where("enabled = ? AND type = ? AND status IN (?, ?) AND session_key <> '' AND token_expiry IS NOT NULL AND token_expiry < ?",
+		true, model.AccountTypeClaudeOfficial,
+		model.AccountStatusValid, model.AccountStatusRateLimited, before).
+		Preload("Proxy").
+		Find(&accounts).Error
+	return accounts, err
+}
+
+// TryLockTokenRefresh 抢占 Token 刷新锁（条件更新，多实例部署时只有一个实例能抢到）
+func (r *AccountRepository) TryLockTokenRefresh(id uint, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	result := r.db.Model(&model.Account{}).
+		Where("id = ? AND (token_refresh_lock_until IS NULL OR token_refresh_lock_until < ?)", id, now).
+		UpdateColumn("token_refresh_lock_until", now.Add(ttl))
+	return result.RowsAffected == 1, result.Error
+}
+
+// SetTokenRefreshLockUntil 设置 Token 刷新锁到期时间，nil 表示释放
+func (r *AccountRepository) SetTokenRefreshLockUntil(id uint, until *time.Time) error {
+	return r.db.Model(&model.Account{}).Where("id = ?", id).
+		UpdateColumn("token_refresh_lock_until", until).Error
+}
+
+// GetAccountsWithCredentials 获取持有可过期凭证（Token 过期时间、SessionKey、RefreshToken）的账号
+func (r *AccountRepository) GetAccountsWithCredentials() ([]model.Account, error) {
+	var accounts []model.Account
+	err := r.db.Where("token_expiry IS NOT NULL OR session_key <> '' OR refresh_token <> ''").
+		Order("token_expiry ASC").
+		Find(&accounts).Error
+	return accounts, err
+}
+
+// GetAccountsForSessionKeyCheck 获取需要校验 SessionKey 的账号（上次校验早于 before 或从未校验）
+func (r *AccountRepository) GetAccountsForSessionKeyCheck(before time.Time) ([]model.Account, error) {
+	var accounts []model.Account
+	err := r.db.Where("enabled = ? AND type = ? AND session_key <> '' AND (session_key_checked_at IS NULL OR session_key_checked_at < ?)",
+		true, model.AccountTypeClaudeOfficial, before).
+		Preload("Proxy").
+		Find(&accounts).Error
 	return accounts, err
 }
 
+// UpdateSessionKeyCheck 记录 SessionKey 校验结果
+func (r *AccountRepository) UpdateSessionKeyCheck(id uint, valid bool) error {
+	return r.db.Model(&model.Account{}).Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{
+			"session_key_valid":      valid,
+			"session_key_checked_at": time.Now(),
+		}).Error
+}
+
 // IncrementConsecutiveErrorCount 增加连续错误计数
 func (r *AccountRepository) IncrementConsecutiveErrorCount(id uint) (int, error) {
 	var account model.Account
@@ -422,6 +776,16 @@ func (r *AccountGroupRepository) GetAll() ([]model.AccountGroup, error) {
 	return groups, err
 }
 
+// GetWithStrategy 获取设置了非随机调度策略的分组及其成员 ID（调度器按分组策略选账户）
+func (r *AccountGroupRepository) GetWithStrategy() ([]model.AccountGroup, error) {
+	var groups []model.AccountGroup
+	err := r.db.Where("strategy IN ?", []string{model.AccountGroupStrategyRoundRobin, model.AccountGroupStrategyFailover}).
+		Preload("Accounts", func(db *gorm.DB) *gorm.DB { return db.Select("accounts.id") }).
+		Order("id ASC").
+		Find(&groups).Error
+	return groups, err
+}
+
 func (r *AccountGroupRepository) AddAccount(groupID, accountID uint) error {
 	return r.db.Exec("INSERT IGNORE INTO account_group_members (account_group_id, account_id) VALUES (?, ?)",
 		groupID, accountID).Error
@@ -453,6 +817,10 @@ func (r *AccountRepository) GetProblemAccounts() ([]model.Account, error) {
 		model.AccountStatusSuspended, model.AccountStatusBanned, model.AccountStatusOverloaded).
 		Preload("Proxy").
 		Find(&accounts).Error
+	if err == nil {
+		// 池内账户继承池配置（代理、Base URL 等），健康检查与实际请求使用相同配置
+		err = r.ApplyPools(accounts)
+	}
 	return accounts, err
 }
 
@@ -467,6 +835,10 @@ func (r *AccountRepository) GetAccountsNeedingProbe() ([]model.Account, error) {
 		now).
 		Preload("Proxy").
 		Find(&accounts).Error
+	if err == nil {
+		// 池内账户继承池配置（代理、Base URL 等），健康检查与实际请求使用相同配置
+		err = r.ApplyPools(accounts)
+	}
 	return accounts, err
 }
 
@@ -578,6 +950,7 @@ func (r *AccountRepository) RecoverAccount(id uint) error {
 			"rate_limit_reset_at":      nil,
 			"next_health_check_at":     nil,
 			"health_check_interval":    0,
+			"recovered_at":             time.Now(),
 		}).Error
 }
 
@@ -0,0 +1,56 @@
+/*
+ * 文件作用：账户变更事件数据仓库
+ * 负责功能：
+ *   - 发布账户变更事件
+ *   - 按自增 ID 增量拉取事件
+ *   - 过期事件清理
+ * 重要程度：⭐⭐⭐ 一般（多实例缓存一致性）
+ * 依赖模块：model, gorm
+ */
+package repository
+
+import (
+	"time"
+
+	"go-aiproxy/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type AccountChangeEventRepository struct {
+	db *gorm.DB
+}
+
+func NewAccountChangeEventRepository() *AccountChangeEventRepository {
+	return &AccountChangeEventRepository{db: DB}
+}
+
+// Create 发布事件
+func (r *AccountChangeEventRepository) Create(event *model.AccountChangeEvent) error {
+	return r.db.Create(event).Error
+}
+
+// GetLatestID 获取当前最大事件 ID（实例启动时作为起点，不回放历史事件）
+func (r *AccountChangeEventRepository) GetLatestID() (uint, error) {
+	var latestID uint
+	err := r.db.Model(&model.AccountChangeEvent{}).
+		Select("COALESCE(MAX(id), 0)").
+		Scan(&latestID).Error
+	return latestID, err
+}
+
+// ListAfter 获取 ID 大于 afterID 的事件（按 ID 升序）
+func (r *AccountChangeEventRepository) ListAfter(afterID uint, limit int) ([]model.AccountChangeEvent, error) {
+	var events []model.AccountChangeEvent
+	err := r.db.Where("id > ?", afterID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&events).Error
+	return events, err
+}
+
+// DeleteBefore 删除指定时间之前的事件
+func (r *AccountChangeEventRepository) DeleteBefore(before time.Time) (int64, error) {
+	result := r.db.Where("created_at < ?", before).Delete(&model.AccountChangeEvent{})
+	return result.RowsAffected, result.Error
+}
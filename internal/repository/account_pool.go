@@ -0,0 +1,155 @@
+/*
+ * 文件作用：账户池数据仓库
+ * 负责功能：
+ *   - 账户池 CRUD（列表带池内账户数）
+ *   - 批量加入/移出池内账户
+ *   - 为账户列表套用池配置（健康检查等需要继承池配置的查询）
+ * 重要程度：⭐⭐⭐ 一般（大规模账户管理）
+ * 依赖模块：model, gorm
+ */
+package repository
+
+import (
+	"go-aiproxy/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type AccountPoolRepository struct {
+	db *gorm.DB
+}
+
+func NewAccountPoolRepository() *AccountPoolRepository {
+	return &AccountPoolRepository{db: DB}
+}
+
+func (r *AccountPoolRepository) Create(pool *model.AccountPool) error {
+	return r.db.Create(pool).Error
+}
+
+func (r *AccountPoolRepository) GetByID(id uint) (*model.AccountPool, error) {
+	var pool model.AccountPool
+	if err := r.db.Preload("Proxy").First(&pool, id).Error; err != nil {
+		return nil, err
+	}
+	return &pool, nil
+}
+
+func (r *AccountPoolRepository) Update(pool *model.AccountPool) error {
+	// 使用 Select 指定所有字段，确保清空的字段和 nil 代理也能被更新
+	return r.db.Model(pool).Omit("Proxy").Select("*").Updates(pool).Error
+}
+
+// Delete 删除账户池，池内账户移出后保留（恢复使用账户自身配置）
+func (r *AccountPoolRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Account{}).Where("pool_id = ?", id).Update("pool_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.AccountPool{}, id).Error
+	})
+}
+
+// List 分页获取账户池，填充池内账户数
+func (r *AccountPoolRepository) List(page, pageSize int) ([]model.AccountPool, int64, error) {
+	var pools []model.AccountPool
+	var total int64
+
+	r.db.Model(&model.AccountPool{}).Count(&total)
+
+	offset := (page - 1) * pageSize
+	if err := r.db.Preload("Proxy").Order("id ASC").Offset(offset).Limit(pageSize).Find(&pools).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := r.fillAccountCount(pools); err != nil {
+		return nil, 0, err
+	}
+	return pools, total, nil
+}
+
+// GetAll 获取全部账户池（含代理，调度器缓存用）
+func (r *AccountPoolRepository) GetAll() ([]model.AccountPool, error) {
+	var pools []model.AccountPool
+	err := r.db.Preload("Proxy").Order("id ASC").Find(&pools).Error
+	return pools, err
+}
+
+// AddAccounts 把账户加入池（账户原来所在的池会被替换），池指定了平台时只加入同平台账户
+func (r *AccountPoolRepository) AddAccounts(pool *model.AccountPool, accountIDs []uint) (int64, error) {
+	query := r.db.Model(&model.Account{}).Where("id IN ?", accountIDs)
+	if pool.Platform != "" {
+		query = query.Where("platform = ?", pool.Platform)
+	}
+	result := query.Update("pool_id", pool.ID)
+	return result.RowsAffected, result.Error
+}
+
+// RemoveAccount 把账户移出池
+func (r *AccountPoolRepository) RemoveAccount(poolID, accountID uint) error {
+	return r.db.Model(&model.Account{}).Where("id = ? AND pool_id = ?", accountID, poolID).Update("pool_id", nil).Error
+}
+
+// GetAccounts 获取池内账户
+func (r *AccountPoolRepository) GetAccounts(poolID uint) ([]model.Account, error) {
+	var accounts []model.Account
+	err := r.db.Where("pool_id = ?", poolID).Order("id ASC").Find(&accounts).Error
+	return accounts, err
+}
+
+// fillAccountCount 填充池内账户数
+func (r *AccountPoolRepository) fillAccountCount(pools []model.AccountPool) error {
+	if len(pools) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(pools))
+	for i := range pools {
+		ids[i] = pools[i].ID
+	}
+
+	var rows []struct {
+		PoolID uint
+		Count  int64
+	}
+	if err := r.db.Model(&model.Account{}).Select("pool_id, COUNT(*) AS count").
+		Where("pool_id IN ?", ids).Group("pool_id").Scan(&rows).Error; err != nil {
+		return err
+	}
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.PoolID] = row.Count
+	}
+	for i := range pools {
+		pools[i].AccountCount = counts[pools[i].ID]
+	}
+	return nil
+}
+
+// ApplyPools 为账户套用所在池的配置（只修改内存副本，不写回数据库）
+func (r *AccountRepository) ApplyPools(accounts []model.Account) error {
+	poolIDs := make([]uint, 0)
+	seen := make(map[uint]bool)
+	for i := range accounts {
+		if id := accounts[i].PoolID; id != nil && !seen[*id] {
+			seen[*id] = true
+			poolIDs = append(poolIDs, *id)
+		}
+	}
+	if len(poolIDs) == 0 {
+		return nil
+	}
+
+	var pools []model.AccountPool
+	if err := r.db.Preload("Proxy").Where("id IN ?", poolIDs).Find(&pools).Error; err != nil {
+		return err
+	}
+	byID := make(map[uint]*model.AccountPool, len(pools))
+	for i := range pools {
+		byID[pools[i].ID] = &pools[i]
+	}
+	for i := range accounts {
+		if id := accounts[i].PoolID; id != nil {
+			accounts[i].ApplyPool(byID[*id])
+		}
+	}
+	return nil
+}
@@ -24,6 +24,11 @@ func NewAPIKeyRepository() *APIKeyRepository {
 	return &APIKeyRepository{db: DB}
 }
 
+// WithOrg 返回限定组织的仓库（见 ScopeOrg），orgID 为 0 时不限制
+func (r *APIKeyRepository) WithOrg(orgID uint) *APIKeyRepository {
+	return &APIKeyRepository{db: ScopeOrg(r.db, orgID)}
+}
+
 // Create 创建 API Key
 func (r *APIKeyRepository) Create(key *model.APIKey) error {
 	return r.db.Create(key).Error
@@ -113,6 +118,11 @@ func (r *APIKeyRepository) GetByUserIDAndBillingType(userID uint, billingType st
 	return &key, nil
 }
 
+// UpdateOrgByUserID 将用户的所有 API Key 调整到指定组织（用户调整组织时调用）
+func (r *APIKeyRepository) UpdateOrgByUserID(userID, orgID uint) error {
+	return r.db.Model(&model.APIKey{}).Where("user_id = ?", userID).Update("org_id", orgID).Error
+}
+
 // ListAllWithUser 获取所有 API Key 并带用户信息和套餐信息（管理员用）
 func (r *APIKeyRepository) ListAllWithUser(page, pageSize int) ([]model.APIKey, int64, error) {
 	var keys []model.APIKey
@@ -0,0 +1,72 @@
+/*
+ * 文件作用：API Key 令牌桶数据仓库
+ * 负责功能：
+ *   - 首次请求插入满桶（唯一主键，并发插入只有一个生效）
+ *   - 条件更新原子地补充并取走一个令牌，多实例共享同一个桶
+ *   - 令牌不足时计算需要等待的时间
+ *   - 桶参数变更后删除桶
+ * 重要程度：⭐⭐⭐ 一般（API Key 限速）
+ * 依赖模块：model, gorm
+ */
+package repository
+
+import (
+	"math"
+	"time"
+
+	"go-aiproxy/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// refilledTokensExpr 按经过的时间补充后的令牌数（不超过容量），参数依次为容量、当前时间、每秒补充数
+const refilledTokensExpr = "LEAST(?, tokens + GREATEST(TIMESTAMPDIFF(MICROSECOND, refilled_at, ?), 0) / 1000000 * ?)"
+
+type APIKeyTokenBucketRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyTokenBucketRepository() *APIKeyTokenBucketRepository {
+	return &APIKeyTokenBucketRepository{db: DB}
+}
+
+// Take 从 API Key 的桶中取一个令牌，拒绝时返回需要等待的时间
+// capacity 为桶容量，refillRate 为每秒补充的令牌数，调用方保证两者大于 0
+func (r *APIKeyTokenBucketRepository) Take(apiKeyID uint, capacity int, refillRate float64, now time.Time) (bool, time.Duration, error) {
+	limit := float64(capacity)
+	if err := r.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.APIKeyTokenBucket{APIKeyID: apiKeyID, Tokens: limit, RefilledAt: now}).Error; err != nil {
+		return false, 0, err
+	}
+
+	// MySQL 按顺序执行 SET，tokens 必须在 refilled_at 之前计算；
+	// 其他实例时钟略快时 refilled_at 不回退，避免重复补充
+	result := r.db.Exec("UPDATE api_key_token_buckets SET "+
+		"tokens = "+refilledTokensExpr+" - 1, refilled_at = GREATEST(refilled_at, ?) "+
+		"WHERE api_key_id = ? AND "+refilledTokensExpr+" >= 1",
+		limit, now, refillRate, now, apiKeyID, limit, now, refillRate)
+	if result.Error != nil {
+		return false, 0, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return true, 0, nil
+	}
+
+	var bucket model.APIKeyTokenBucket
+	if err := r.db.Where("api_key_id = ?", apiKeyID).First(&bucket).Error; err != nil {
+		return false, 0, err
+	}
+	tokens := bucket.Tokens
+	if elapsed := now.Sub(bucket.RefilledAt).Seconds(); elapsed > 0 {
+		tokens += elapsed * refillRate
+	}
+	tokens = math.Min(tokens, limit)
+	wait := time.Duration((1 - tokens) / refillRate * float64(time.Second))
+	return false, wait, nil
+}
+
+// Delete 删除 API Key 的桶（参数变更后按满桶重新计算）
+func (r *APIKeyTokenBucketRepository) Delete(apiKeyID uint) error {
+	return r.db.Where("api_key_id = ?", apiKeyID).Delete(&model.APIKeyTokenBucket{}).Error
+}
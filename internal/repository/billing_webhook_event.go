@@ -0,0 +1,70 @@
+/*
+ * 文件作用：计费 webhook 事件数据仓库
+ * 负责功能：
+ *   - 写入待推送事件
+ *   - 拉取到期的待重试事件，条件更新抢占（多实例不重复发送）
+ *   - 推送成功删除、失败记录原因和下次重试时间
+ * 重要程度：⭐⭐⭐ 一般（计费系统对接）
+ * 依赖模块：model, gorm
+ */
+package repository
+
+import (
+	"time"
+
+	"go-aiproxy/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type BillingWebhookEventRepository struct {
+	db *gorm.DB
+}
+
+func NewBillingWebhookEventRepository() *BillingWebhookEventRepository {
+	return &BillingWebhookEventRepository{db: DB}
+}
+
+// Create 写入待推送事件
+func (r *BillingWebhookEventRepository) Create(event *model.BillingWebhookEvent) error {
+	return r.db.Create(event).Error
+}
+
+// ListDue 获取下次重试时间已到的事件（按 ID 升序，先产生的先推送）
+func (r *BillingWebhookEventRepository) ListDue(now time.Time, limit int) ([]model.BillingWebhookEvent, error) {
+	var events []model.BillingWebhookEvent
+	err := r.db.Where("next_retry_at <= ?", now).
+		Order("id ASC").
+		Limit(limit).
+		Find(&events).Error
+	return events, err
+}
+
+// Claim 抢占事件：下次重试时间仍为 expected 时改为 leaseUntil，返回是否抢占成功
+func (r *BillingWebhookEventRepository) Claim(id uint, expected, leaseUntil time.Time) (bool, error) {
+	result := r.db.Model(&model.BillingWebhookEvent{}).
+		Where("id = ? AND next_retry_at = ?", id, expected).
+		Update("next_retry_at", leaseUntil)
+	return result.RowsAffected == 1, result.Error
+}
+
+// Delete 推送成功后删除
+func (r *BillingWebhookEventRepository) Delete(id uint) error {
+	return r.db.Delete(&model.BillingWebhookEvent{}, id).Error
+}
+
+// MarkFailed 记录失败原因和下次重试时间
+func (r *BillingWebhookEventRepository) MarkFailed(id uint, attempts int, nextRetryAt time.Time, lastError string) error {
+	return r.db.Model(&model.BillingWebhookEvent{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"attempts":      attempts,
+		"next_retry_at": nextRetryAt,
+		"last_error":    lastError,
+	}).Error
+}
+
+// CountPending 未送达的事件数
+func (r *BillingWebhookEventRepository) CountPending() (int64, error) {
+	var count int64
+	err := r.db.Model(&model.BillingWebhookEvent{}).Count(&count).Error
+	return count, err
+}
@@ -3,6 +3,7 @@
  * 负责功能：
  *   - 客户端类型CRUD操作
  *   - 过滤规则CRUD操作
+ *   - 访问规则CRUD操作
  *   - 全局过滤配置管理
  *   - 默认数据初始化
  * 重要程度：⭐⭐⭐⭐ 重要（安全过滤仓库）
@@ -155,6 +156,48 @@ func (r *ClientFilterRepository) DeleteRulesByClientTypeID(clientTypeID uint) er
 	return r.db.Where("client_type_id = ?", clientTypeID).Delete(&model.ClientFilterRule{}).Error
 }
 
+// ==================== ClientAccessRule ====================
+
+// CreateAccessRule 创建访问规则
+func (r *ClientFilterRepository) CreateAccessRule(rule *model.ClientAccessRule) error {
+	return r.db.Create(rule).Error
+}
+
+// GetAccessRuleByID 根据 ID 获取访问规则
+func (r *ClientFilterRepository) GetAccessRuleByID(id uint) (*model.ClientAccessRule, error) {
+	var rule model.ClientAccessRule
+	err := r.db.First(&rule, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// ListAccessRules 获取所有访问规则（按匹配顺序）
+func (r *ClientFilterRepository) ListAccessRules() ([]model.ClientAccessRule, error) {
+	var rules []model.ClientAccessRule
+	err := r.db.Order("priority DESC, id ASC").Find(&rules).Error
+	return rules, err
+}
+
+// UpdateAccessRule 更新访问规则
+func (r *ClientFilterRepository) UpdateAccessRule(rule *model.ClientAccessRule) error {
+	return r.db.Model(&model.ClientAccessRule{}).Where("id = ?", rule.ID).Updates(map[string]interface{}{
+		"name":               rule.Name,
+		"user_agent_pattern": rule.UserAgentPattern,
+		"path_prefix":        rule.PathPrefix,
+		"action":             rule.Action,
+		"message":            rule.Message,
+		"enabled":            rule.Enabled,
+		"priority":           rule.Priority,
+	}).Error
+}
+
+// DeleteAccessRule 删除访问规则
+func (r *ClientFilterRepository) DeleteAccessRule(id uint) error {
+	return r.db.Delete(&model.ClientAccessRule{}, id).Error
+}
+
 // ==================== ClientFilterConfig ====================
 
 // GetConfig 获取全局配置（只有一条记录）
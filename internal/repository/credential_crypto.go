@@ -0,0 +1,121 @@
+/*
+ * 文件作用：账户凭证加密存储的密钥初始化与存量数据迁移
+ * 负责功能：
+ *   - 按配置（或环境变量）设置当前密钥和旧密钥
+ *   - 把明文凭证加密、把旧密钥加密的凭证用当前密钥重新加密（密钥轮换）
+ * 重要程度：⭐⭐⭐⭐ 重要（凭证安全）
+ * 依赖模块：config, model, utils, gorm
+ */
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"go-aiproxy/internal/config"
+	"go-aiproxy/internal/model"
+	"go-aiproxy/pkg/utils"
+)
+
+// credentialMigrateBatchSize 迁移时每批读取的账户数
+const credentialMigrateBatchSize = 200
+
+// initCredentialKeys 设置账户凭证加密密钥（InitMySQL 中调用）
+func initCredentialKeys() error {
+	if config.Cfg == nil {
+		return nil
+	}
+	crypto := config.Cfg.Crypto
+	if err := utils.SetFieldKeys(crypto.GetCredentialKey(), crypto.GetOldCredentialKeys()); err != nil {
+		return fmt.Errorf("init credential keys: %w", err)
+	}
+	return nil
+}
+
+// CredentialMigrationResult 凭证加密迁移结果
+type CredentialMigrationResult struct {
+	Total   int    // 检查的账户数（含已删除）
+	Updated int    // 需要（dryRun 时）或已经重新加密的账户数
+	Failed  []uint // 无法解密的账户（密文使用的密钥未配置或数据损坏）
+}
+
+// credentialRow 迁移时按原始列读取的凭证（Scan 到非模型结构体，不经过 AfterFind 解密）
+type credentialRow struct {
+	ID           uint
+	APIKey       string `gorm:"column:api_key"`
+	APIKeys      string `gorm:"column:api_keys"`
+	AccessToken  string `gorm:"column:access_token"`
+	RefreshToken string `gorm:"column:refresh_token"`
+	SessionKey   string `gorm:"column:session_key"`
+}
+
+func (r *credentialRow) values() []string {
+	return []string{r.APIKey, r.APIKeys, r.AccessToken, r.RefreshToken, r.SessionKey}
+}
+
+// EncryptAccountCredentials 用当前密钥加密所有账户的凭证：明文加密，旧密钥加密的数据重新加密
+// dryRun 为 true 时只统计不写库；无法解密的账户跳过并记入 Failed
+func EncryptAccountCredentials(dryRun bool) (*CredentialMigrationResult, error) {
+	if !utils.FieldEncryptionEnabled() {
+		return nil, errors.New("credential key is not configured")
+	}
+
+	result := &CredentialMigrationResult{}
+	var lastID uint
+	for {
+		var rows []credentialRow
+		err := DB.Model(&model.Account{}).Unscoped().
+			Select(append([]string{"id"}, model.CredentialColumns...)).
+			Where("id > ?", lastID).Order("id ASC").Limit(credentialMigrateBatchSize).
+			Scan(&rows).Error
+		if err != nil {
+			return result, err
+		}
+		if len(rows) == 0 {
+			return result, nil
+		}
+
+		for i := range rows {
+			row := &rows[i]
+			lastID = row.ID
+			result.Total++
+
+			updates, err := reencryptCredentials(row)
+			if err != nil {
+				result.Failed = append(result.Failed, row.ID)
+				continue
+			}
+			if len(updates) == 0 {
+				continue
+			}
+			result.Updated++
+			if dryRun {
+				continue
+			}
+			// UpdateColumns：不触发钩子、不修改 updated_at
+			if err := DB.Model(&model.Account{}).Unscoped().Where("id = ?", row.ID).UpdateColumns(updates).Error; err != nil {
+				return result, err
+			}
+		}
+	}
+}
+
+// reencryptCredentials 计算一行需要更新的凭证列
+func reencryptCredentials(row *credentialRow) (map[string]interface{}, error) {
+	updates := make(map[string]interface{})
+	for i, value := range row.values() {
+		if !utils.NeedsFieldReencrypt(value) {
+			continue
+		}
+		plaintext, err := utils.DecryptField(value)
+		if err != nil {
+			return nil, err
+		}
+		encrypted, err := utils.EncryptField(plaintext)
+		if err != nil {
+			return nil, err
+		}
+		updates[model.CredentialColumns[i]] = encrypted
+	}
+	return updates, nil
+}
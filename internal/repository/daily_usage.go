@@ -1,16 +1,21 @@
 /*
  * 文件作用：每日使用汇总数据仓库，提供使用统计的数据库操作
  * 负责功能：
- *   - 增量更新每日使用统计（UPSERT）
+ *   - 增量更新每日使用统计（原子 UPSERT，锁冲突重试）
+ *   - 与请求日志明细对账
  *   - 用户使用统计查询（按日/月/总计）
  *   - 模型使用统计汇总
  *   - 管理员全局统计查询
+ *   - 用量报表逐行流式查询
  * 重要程度：⭐⭐⭐⭐ 重要（使用统计核心仓库）
  * 依赖模块：model, gorm
  */
 package repository
 
 import (
+	"errors"
+	"sort"
+	"strings"
 	"time"
 
 	"go-aiproxy/internal/model"
@@ -29,10 +34,40 @@ func NewDailyUsageRepository() *DailyUsageRepository {
 	return &DailyUsageRepository{db: GetDB()}
 }
 
+// dailyUsageMaxRetries 死锁/锁等待超时时的最大重试次数
+const dailyUsageMaxRetries = 3
+
 // IncrementUsage 增量更新每日使用统计（使用 UPSERT）
+// 所有字段都以 column = column + ? 原子自增，并发写同一行不会丢更新；
+// 同一行高并发 UPSERT 可能触发 InnoDB 死锁，此时重试，避免整条统计被丢弃
+// usage.Date 为空时使用当天日期
 func (r *DailyUsageRepository) IncrementUsage(userID uint, modelName string, usage *model.DailyUsage) error {
-	today := time.Now().Format("2006-01-02")
+	date := usage.Date
+	if date == "" {
+		date = time.Now().Format("2006-01-02")
+	}
 
+	var err error
+	for attempt := 0; attempt <= dailyUsageMaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt*20) * time.Millisecond)
+		}
+		if err = r.upsertUsage(userID, date, modelName, usage); err == nil || !isLockConflictError(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// isLockConflictError 判断是否为可重试的锁冲突（MySQL 1213 死锁 / 1205 锁等待超时）
+func isLockConflictError(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "Error 1213") || strings.Contains(msg, "Error 1205") ||
+		strings.Contains(msg, "Deadlock found") || strings.Contains(msg, "Lock wait timeout")
+}
+
+// upsertUsage 执行一次 UPSERT
+func (r *DailyUsageRepository) upsertUsage(userID uint, date, modelName string, usage *model.DailyUsage) error {
 	// 使用 ON DUPLICATE KEY UPDATE 实现增量更新
 	return r.db.Clauses(clause.OnConflict{
 		Columns: []clause.Column{
@@ -52,11 +87,12 @@ func (r *DailyUsageRepository) IncrementUsage(userID uint, modelName string, usa
 			"cache_create_cost":           gorm.Expr("cache_create_cost + ?", usage.CacheCreateCost),
 			"cache_read_cost":             gorm.Expr("cache_read_cost + ?", usage.CacheReadCost),
 			"total_cost":                  gorm.Expr("total_cost + ?", usage.TotalCost),
+			"account_cost":                gorm.Expr("account_cost + ?", usage.AccountCost),
 			"updated_at":                  time.Now(),
 		}),
 	}).Create(&model.DailyUsage{
 		UserID:                   userID,
-		Date:                     today,
+		Date:                     date,
 		Model:                    modelName,
 		RequestCount:             usage.RequestCount,
 		InputTokens:              usage.InputTokens,
@@ -69,11 +105,83 @@ func (r *DailyUsageRepository) IncrementUsage(userID uint, modelName string, usa
 		CacheCreateCost:          usage.CacheCreateCost,
 		CacheReadCost:            usage.CacheReadCost,
 		TotalCost:                usage.TotalCost,
+		AccountCost:              usage.AccountCost,
 		CreatedAt:                time.Now(),
 		UpdatedAt:                time.Now(),
 	}).Error
 }
 
+// Reconcile 对账：比对某日 daily_usage 汇总与 request_logs 成功请求明细
+// 按用户+模型返回所有行（调用方用 HasDiff 过滤），只在一侧出现的行另一侧为 0
+func (r *DailyUsageRepository) Reconcile(date string) ([]model.DailyUsageDiff, error) {
+	day, err := time.ParseInLocation("2006-01-02", date, time.Local)
+	if err != nil {
+		return nil, err
+	}
+
+	type usageRow struct {
+		UserID   uint
+		Model    string
+		Requests int64
+		Tokens   int64
+		Cost     float64
+	}
+
+	var summaries []usageRow
+	if err := r.db.Model(&model.DailyUsage{}).
+		Select("user_id, model, SUM(request_count) as requests, SUM(total_tokens) as tokens, SUM(total_cost) as cost").
+		Where("date = ?", date).
+		Group("user_id, model").
+		Scan(&summaries).Error; err != nil {
+		return nil, err
+	}
+
+	var logs []usageRow
+	if err := r.db.Model(&model.RequestLog{}).
+		Select("user_id, model, COUNT(*) as requests, COALESCE(SUM(total_tokens), 0) as tokens, COALESCE(SUM(total_cost), 0) as cost").
+		Where("created_at >= ? AND created_at < ?", day, day.AddDate(0, 0, 1)).
+		Where("success = ? AND user_id IS NOT NULL", true).
+		Group("user_id, model").
+		Scan(&logs).Error; err != nil {
+		return nil, err
+	}
+
+	type diffKey struct {
+		userID uint
+		model  string
+	}
+	diffs := make(map[diffKey]*model.DailyUsageDiff)
+	get := func(userID uint, modelName string) *model.DailyUsageDiff {
+		k := diffKey{userID, modelName}
+		if d, ok := diffs[k]; ok {
+			return d
+		}
+		d := &model.DailyUsageDiff{UserID: userID, Model: modelName}
+		diffs[k] = d
+		return d
+	}
+	for _, s := range summaries {
+		d := get(s.UserID, s.Model)
+		d.SummaryRequests, d.SummaryTokens, d.SummaryCost = s.Requests, s.Tokens, s.Cost
+	}
+	for _, l := range logs {
+		d := get(l.UserID, l.Model)
+		d.LogRequests, d.LogTokens, d.LogCost = l.Requests, l.Tokens, l.Cost
+	}
+
+	result := make([]model.DailyUsageDiff, 0, len(diffs))
+	for _, d := range diffs {
+		result = append(result, *d)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].UserID != result[j].UserID {
+			return result[i].UserID < result[j].UserID
+		}
+		return result[i].Model < result[j].Model
+	})
+	return result, nil
+}
+
 // GetUserDailyUsage 获取用户某日的使用统计
 func (r *DailyUsageRepository) GetUserDailyUsage(userID uint, date string) ([]model.DailyUsage, error) {
 	var usages []model.DailyUsage
@@ -214,7 +322,7 @@ func (r *DailyUsageRepository) GetTotalSummary(startDate, endDate string) (*mode
 		query = query.Where("date <= ?", endDate)
 	}
 
-	err := query.Select("SUM(request_count) as total_requests, SUM(total_tokens) as total_tokens, SUM(total_cost) as total_cost").
+	err := query.Select("SUM(request_count) as total_requests, SUM(total_tokens) as total_tokens, SUM(total_cost) as total_cost, SUM(account_cost) as account_cost").
 		Scan(&summary).Error
 
 	return &summary, err
@@ -232,7 +340,7 @@ func (r *DailyUsageRepository) GetModelSummary(startDate, endDate string) ([]mod
 		query = query.Where("date <= ?", endDate)
 	}
 
-	err := query.Select("model, SUM(request_count) as request_count, SUM(total_tokens) as total_tokens, SUM(total_cost) as total_cost").
+	err := query.Select("model, SUM(request_count) as request_count, SUM(total_tokens) as total_tokens, SUM(total_cost) as total_cost, SUM(account_cost) as account_cost").
 		Group("model").
 		Order("total_cost DESC").
 		Scan(&summaries).Error
@@ -252,10 +360,143 @@ func (r *DailyUsageRepository) GetDailySummaryAll(startDate, endDate string) ([]
 		query = query.Where("date <= ?", endDate)
 	}
 
-	err := query.Select("date, SUM(request_count) as request_count, SUM(total_tokens) as total_tokens, SUM(total_cost) as total_cost").
+	err := query.Select("date, SUM(request_count) as request_count, SUM(total_tokens) as total_tokens, SUM(total_cost) as total_cost, SUM(account_cost) as account_cost").
 		Group("date").
 		Order("date DESC").
 		Scan(&summaries).Error
 
 	return summaries, err
 }
+
+// StreamUsageReport 按分组维度+日期逐行读取用量报表，结果按分组、日期排序，每行回调一次
+// 使用游标逐行扫描，大时间范围也不会一次性加载到内存；fn 返回错误时停止读取
+// daily_usage 没有账户维度，按账户分组或按账户过滤时改从 request_logs 成功请求聚合
+func (r *DailyUsageRepository) StreamUsageReport(filter *model.UsageReportFilter, fn func(row *model.UsageReportRow) error) error {
+	var query *gorm.DB
+	if filter.GroupBy == model.UsageReportGroupByAccount || filter.AccountID > 0 {
+		query = r.requestLogReportQuery(filter)
+	} else {
+		query = r.dailyUsageReportQuery(filter)
+	}
+	if query == nil {
+		return errors.New("invalid group_by")
+	}
+
+	rows, err := query.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row model.UsageReportRow
+		if err := r.db.ScanRows(rows, &row); err != nil {
+			return err
+		}
+		if err := fn(&row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// usageReportSumColumns 报表 token/费用汇总列，daily_usage 与 request_logs 列名相同
+const usageReportSumColumns = "SUM(input_tokens) as input_tokens, SUM(output_tokens) as output_tokens, " +
+	"SUM(cache_creation_input_tokens) as cache_creation_input_tokens, SUM(cache_read_input_tokens) as cache_read_input_tokens, " +
+	"SUM(total_tokens) as total_tokens, SUM(input_cost) as input_cost, SUM(output_cost) as output_cost, " +
+	"SUM(cache_create_cost) as cache_create_cost, SUM(cache_read_cost) as cache_read_cost, SUM(total_cost) as total_cost"
+
+// dailyUsageReportQuery 从 daily_usage 按用户/模型+日期聚合
+func (r *DailyUsageRepository) dailyUsageReportQuery(filter *model.UsageReportFilter) *gorm.DB {
+	var groupCol string
+	switch filter.GroupBy {
+	case model.UsageReportGroupByUser:
+		groupCol = "user_id"
+	case model.UsageReportGroupByModel:
+		groupCol = "model"
+	default:
+		return nil
+	}
+
+	query := r.db.Model(&model.DailyUsage{}).
+		Select(groupSelect(groupCol)+", date, SUM(request_count) as request_count, "+usageReportSumColumns).
+		Where("date >= ? AND date <= ?", filter.StartDate, filter.EndDate)
+	if filter.UserID > 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	return query.Group(groupCol + ", date").Order(groupCol + ", date")
+}
+
+// requestLogReportQuery 从 request_logs 成功请求按用户/模型/账户+日期聚合
+func (r *DailyUsageRepository) requestLogReportQuery(filter *model.UsageReportFilter) *gorm.DB {
+	var groupCol string
+	switch filter.GroupBy {
+	case model.UsageReportGroupByUser:
+		groupCol = "user_id"
+	case model.UsageReportGroupByModel:
+		groupCol = "model"
+	case model.UsageReportGroupByAccount:
+		groupCol = "account_id"
+	default:
+		return nil
+	}
+
+	start, err := time.ParseInLocation("2006-01-02", filter.StartDate, time.Local)
+	if err != nil {
+		return nil
+	}
+	end, err := time.ParseInLocation("2006-01-02", filter.EndDate, time.Local)
+	if err != nil {
+		return nil
+	}
+
+	const dateExpr = "DATE_FORMAT(created_at, '%Y-%m-%d')"
+	query := r.db.Model(&model.RequestLog{}).
+		Select(groupSelect(groupCol)+", "+dateExpr+" as date, COUNT(*) as request_count, "+usageReportSumColumns).
+		Where("created_at >= ? AND created_at < ?", start, end.AddDate(0, 0, 1)).
+		Where("success = ?", true)
+	if groupCol == "user_id" {
+		query = query.Where("user_id IS NOT NULL")
+	}
+	if filter.UserID > 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.AccountID > 0 {
+		query = query.Where("account_id = ?", filter.AccountID)
+	}
+	return query.Group(groupCol + ", " + dateExpr).Order(groupCol + ", date")
+}
+
+// groupSelect 分组列映射到 UsageReportRow：ID 类维度写 group_id，模型写 group_key
+func groupSelect(groupCol string) string {
+	if groupCol == "model" {
+		return "model as group_key"
+	}
+	return groupCol + " as group_id"
+}
+
+// GetReportGroupNames 获取报表分组显示名（用户名/账户名，含已删除记录）
+func (r *DailyUsageRepository) GetReportGroupNames(groupBy string) (map[uint]string, error) {
+	type nameRow struct {
+		ID   uint
+		Name string
+	}
+	var rows []nameRow
+	var err error
+	switch groupBy {
+	case model.UsageReportGroupByUser:
+		err = r.db.Unscoped().Model(&model.User{}).Select("id, username as name").Scan(&rows).Error
+	case model.UsageReportGroupByAccount:
+		err = r.db.Unscoped().Model(&model.Account{}).Select("id, name").Scan(&rows).Error
+	default:
+		return map[uint]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[uint]string, len(rows))
+	for _, row := range rows {
+		names[row.ID] = row.Name
+	}
+	return names, nil
+}
@@ -0,0 +1,74 @@
+/*
+ * 文件作用：小时用量汇总数据仓库
+ * 负责功能：
+ *   - 增量更新小时用量（原子 UPSERT，锁冲突重试）
+ *   - 按日期查询 24 小时用量
+ *   - 清理超过保留期的记录
+ * 重要程度：⭐⭐ 辅助（统计数据仓库）
+ * 依赖模块：model, gorm
+ */
+package repository
+
+import (
+	"time"
+
+	"go-aiproxy/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type HourlyUsageRepository struct {
+	db *gorm.DB
+}
+
+func NewHourlyUsageRepository() *HourlyUsageRepository {
+	return &HourlyUsageRepository{db: DB}
+}
+
+// IncrementUsage 增量更新一个小时的用量（所有字段 column = column + ? 原子自增，多实例并发写不丢更新）
+func (r *HourlyUsageRepository) IncrementUsage(usage *model.HourlyUsage) error {
+	var err error
+	for attempt := 0; attempt <= dailyUsageMaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt*20) * time.Millisecond)
+		}
+		if err = r.upsertUsage(usage); err == nil || !isLockConflictError(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// upsertUsage 执行一次 UPSERT
+func (r *HourlyUsageRepository) upsertUsage(usage *model.HourlyUsage) error {
+	row := *usage
+	row.ID = 0
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "date"}, {Name: "hour"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"request_count":               gorm.Expr("request_count + ?", usage.RequestCount),
+			"input_tokens":                gorm.Expr("input_tokens + ?", usage.InputTokens),
+			"output_tokens":               gorm.Expr("output_tokens + ?", usage.OutputTokens),
+			"cache_creation_input_tokens": gorm.Expr("cache_creation_input_tokens + ?", usage.CacheCreationInputTokens),
+			"cache_read_input_tokens":     gorm.Expr("cache_read_input_tokens + ?", usage.CacheReadInputTokens),
+			"total_tokens":                gorm.Expr("total_tokens + ?", usage.TotalTokens),
+			"total_cost":                  gorm.Expr("total_cost + ?", usage.TotalCost),
+			"account_cost":                gorm.Expr("account_cost + ?", usage.AccountCost),
+			"updated_at":                  time.Now(),
+		}),
+	}).Create(&row).Error
+}
+
+// ListByDate 获取某天各小时的用量（没有请求的小时没有记录）
+func (r *HourlyUsageRepository) ListByDate(date string) ([]model.HourlyUsage, error) {
+	var rows []model.HourlyUsage
+	err := r.db.Where("date = ?", date).Order("hour ASC").Find(&rows).Error
+	return rows, err
+}
+
+// DeleteBefore 删除早于指定日期（YYYY-MM-DD）的记录，返回删除条数
+func (r *HourlyUsageRepository) DeleteBefore(date string) (int64, error) {
+	result := r.db.Where("date < ?", date).Delete(&model.HourlyUsage{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,57 @@
+/*
+ * 文件作用：请求幂等记录数据仓库
+ * 负责功能：
+ *   - 唯一索引插入占用 Idempotency-Key（多实例只有一个请求能占用）
+ *   - 过期记录（租约到期或去重窗口结束）占用前清除
+ *   - 首个请求成功后标记完成、失败后释放
+ *   - 过期记录定期清理
+ * 重要程度：⭐⭐⭐ 一般（防重复调用与重复计费）
+ * 依赖模块：model, gorm
+ */
+package repository
+
+import (
+	"time"
+
+	"go-aiproxy/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdempotencyRecordRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRecordRepository() *IdempotencyRecordRepository {
+	return &IdempotencyRecordRepository{db: DB}
+}
+
+// Claim 占用幂等键：清除该键已过期的记录后插入，返回是否占用成功（已被其他请求占用时返回 false）
+func (r *IdempotencyRecordRepository) Claim(record *model.IdempotencyRecord) (bool, error) {
+	if err := r.db.Where("key_hash = ? AND expire_at < ?", record.KeyHash, time.Now()).
+		Delete(&model.IdempotencyRecord{}).Error; err != nil {
+		return false, err
+	}
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	return result.RowsAffected == 1, result.Error
+}
+
+// Complete 首个请求成功，记录保留到去重窗口结束
+func (r *IdempotencyRecordRepository) Complete(id uint, expireAt time.Time) error {
+	return r.db.Model(&model.IdempotencyRecord{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":    model.IdempotencyStatusCompleted,
+		"expire_at": expireAt,
+	}).Error
+}
+
+// Release 首个请求失败，删除记录让重复请求重新执行
+func (r *IdempotencyRecordRepository) Release(id uint) error {
+	return r.db.Delete(&model.IdempotencyRecord{}, id).Error
+}
+
+// DeleteExpired 删除已过期的记录
+func (r *IdempotencyRecordRepository) DeleteExpired(now time.Time) (int64, error) {
+	result := r.db.Where("expire_at < ?", now).Delete(&model.IdempotencyRecord{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,64 @@
+/*
+ * 文件作用：Claude 消息批次数据仓库
+ * 负责功能：
+ *   - 批次记录创建和查询
+ *   - 更新批次处理状态
+ *   - 原子标记用量已计费（多实例、重复拉取只计费一次）
+ * 重要程度：⭐⭐⭐ 一般（离线批量任务）
+ * 依赖模块：model, gorm
+ */
+package repository
+
+import (
+	"time"
+
+	"go-aiproxy/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type MessageBatchRepository struct {
+	db *gorm.DB
+}
+
+func NewMessageBatchRepository() *MessageBatchRepository {
+	return &MessageBatchRepository{db: DB}
+}
+
+// Create 创建批次记录
+func (r *MessageBatchRepository) Create(batch *model.MessageBatch) error {
+	return r.db.Create(batch).Error
+}
+
+// GetByBatchID 根据上游批次 ID 获取
+func (r *MessageBatchRepository) GetByBatchID(batchID string) (*model.MessageBatch, error) {
+	var batch model.MessageBatch
+	err := r.db.Where("batch_id = ?", batchID).First(&batch).Error
+	if err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// UpdateStatus 更新上游处理状态
+func (r *MessageBatchRepository) UpdateStatus(batchID, status string) error {
+	return r.db.Model(&model.MessageBatch{}).
+		Where("batch_id = ?", batchID).
+		Update("processing_status", status).Error
+}
+
+// MarkUsageRecorded 原子标记用量已计费
+// 返回 true 表示本次标记成功（调用方负责计费），false 表示已被计费过
+func (r *MessageBatchRepository) MarkUsageRecorded(batchID string) (bool, error) {
+	now := time.Now()
+	result := r.db.Model(&model.MessageBatch{}).
+		Where("batch_id = ? AND usage_recorded = ?", batchID, false).
+		Updates(map[string]interface{}{
+			"usage_recorded":    true,
+			"usage_recorded_at": now,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
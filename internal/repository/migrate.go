@@ -17,14 +17,22 @@ import (
 
 func AutoMigrate() error {
 	return DB.AutoMigrate(
+		&model.Organization{},
 		&model.User{},
 		&model.Proxy{},
 		&model.Account{},
 		&model.AccountGroup{},
+		&model.AccountPool{},
+		&model.AccountChangeEvent{},
+		&model.BillingWebhookEvent{},
+		&model.IdempotencyRecord{},
+		&model.MessageBatch{},
 		&model.RequestLog{},
 		&model.AIModel{},
 		&model.APIKey{},
+		&model.APIKeyTokenBucket{},
 		&model.DailyUsage{},
+		&model.HourlyUsage{},
 		&model.SystemConfig{},
 		&model.UsageRecord{},
 		&model.Package{},
@@ -34,6 +42,7 @@ func AutoMigrate() error {
 		&model.ClientType{},
 		&model.ClientFilterRule{},
 		&model.ClientFilterConfig{},
+		&model.ClientAccessRule{},
 		// 错误消息配置
 		&model.ErrorMessage{},
 		// 错误规则配置
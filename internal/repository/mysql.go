@@ -5,6 +5,8 @@
  *   - 连接池配置
  *   - 全局DB实例管理
  *   - 连接关闭
+ *   - 注册组织隔离回调
+ *   - 初始化账户凭证加密密钥（见 credential_crypto.go）
  * 重要程度：⭐⭐⭐⭐ 重要（数据库连接核心）
  * 依赖模块：config, gorm
  */
@@ -37,6 +39,13 @@ func InitMySQL() error {
 	sqlDB.SetMaxIdleConns(config.Cfg.MySQL.MaxIdleConns)
 	sqlDB.SetMaxOpenConns(config.Cfg.MySQL.MaxOpenConns)
 
+	if err := registerOrgScope(db); err != nil {
+		return err
+	}
+	if err := initCredentialKeys(); err != nil {
+		return err
+	}
+
 	DB = db
 	return nil
 }
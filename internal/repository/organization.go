@@ -0,0 +1,157 @@
+/*
+ * 文件作用：组织（租户）数据仓库，以及按组织隔离数据的统一过滤
+ * 负责功能：
+ *   - 组织CRUD操作
+ *   - 查询记录所属组织（OrgIDOf）
+ *   - 限定组织的 DB（ScopeOrg）：带 OrgID 字段的模型查询/更新/删除自动追加 org_id 条件，创建时写入当前组织
+ * 重要程度：⭐⭐⭐⭐ 重要（多租户数据隔离）
+ * 依赖模块：model, gorm
+ */
+package repository
+
+import (
+	"reflect"
+
+	"go-aiproxy/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+type OrganizationRepository struct {
+	db *gorm.DB
+}
+
+func NewOrganizationRepository() *OrganizationRepository {
+	return &OrganizationRepository{db: DB}
+}
+
+func (r *OrganizationRepository) Create(org *model.Organization) error {
+	return r.db.Create(org).Error
+}
+
+func (r *OrganizationRepository) GetByID(id uint) (*model.Organization, error) {
+	var org model.Organization
+	err := r.db.First(&org, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+func (r *OrganizationRepository) Update(org *model.Organization) error {
+	return r.db.Save(org).Error
+}
+
+func (r *OrganizationRepository) Delete(id uint) error {
+	return r.db.Delete(&model.Organization{}, id).Error
+}
+
+// List 获取所有组织
+func (r *OrganizationRepository) List() ([]model.Organization, error) {
+	var orgs []model.Organization
+	err := r.db.Order("id ASC").Find(&orgs).Error
+	return orgs, err
+}
+
+// CountMembers 统计归属组织的用户、账户、API Key、套餐数量（删除组织前检查）
+func (r *OrganizationRepository) CountMembers(id uint) (int64, error) {
+	var total int64
+	for _, m := range []interface{}{&model.User{}, &model.Account{}, &model.APIKey{}, &model.Package{}} {
+		var count int64
+		if err := r.db.Model(m).Where("org_id = ?", id).Count(&count).Error; err != nil {
+			return 0, err
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// ========== 组织数据隔离 ==========
+
+// OrgIDOf 查询记录所属组织（路由层校验组织管理员的访问范围），m 为带 OrgID 字段的模型
+func OrgIDOf(m interface{}, id uint) (uint, error) {
+	var orgIDs []uint
+	if err := DB.Model(m).Where("id = ?", id).Limit(1).Pluck("org_id", &orgIDs).Error; err != nil {
+		return 0, err
+	}
+	if len(orgIDs) == 0 {
+		return 0, gorm.ErrRecordNotFound
+	}
+	return orgIDs[0], nil
+}
+
+// orgScopeKey 组织条件在 gorm Settings 中的键
+const orgScopeKey = "org_scope:org_id"
+
+// ScopeOrg 返回只能读写指定组织数据的 DB，orgID 为 0 时原样返回（超级管理员不限制）
+// 返回的 DB 可重复使用，每条语句都会带上组织条件
+func ScopeOrg(db *gorm.DB, orgID uint) *gorm.DB {
+	if orgID == 0 {
+		return db
+	}
+	return db.Set(orgScopeKey, orgID).Session(&gorm.Session{})
+}
+
+// registerOrgScope 注册组织隔离回调（InitMySQL 时调用）
+// 原生 SQL（Raw/Exec）和没有 OrgID 字段的模型不受影响
+func registerOrgScope(db *gorm.DB) error {
+	callback := db.Callback()
+	if err := callback.Query().Before("gorm:query").Register("org_scope:query", applyOrgCondition); err != nil {
+		return err
+	}
+	if err := callback.Row().Before("gorm:row").Register("org_scope:row", applyOrgCondition); err != nil {
+		return err
+	}
+	if err := callback.Update().Before("gorm:update").Register("org_scope:update", applyOrgCondition); err != nil {
+		return err
+	}
+	if err := callback.Delete().Before("gorm:delete").Register("org_scope:delete", applyOrgCondition); err != nil {
+		return err
+	}
+	return callback.Create().Before("gorm:create").Register("org_scope:create", assignOrgID)
+}
+
+// scopedOrgField 返回语句限定的组织和模型的 OrgID 字段，未限定组织或模型没有 OrgID 字段时 ok 为 false
+func scopedOrgField(db *gorm.DB) (orgID uint, field *schema.Field, ok bool) {
+	value, exists := db.Get(orgScopeKey)
+	if !exists || db.Statement.Schema == nil {
+		return 0, nil, false
+	}
+	field = db.Statement.Schema.LookUpField("OrgID")
+	if field == nil {
+		return 0, nil, false
+	}
+	orgID, ok = value.(uint)
+	return orgID, field, ok
+}
+
+// applyOrgCondition 查询/更新/删除时追加 org_id 条件
+func applyOrgCondition(db *gorm.DB) {
+	orgID, field, ok := scopedOrgField(db)
+	if !ok {
+		return
+	}
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: db.Statement.Table, Name: field.DBName}, Value: orgID},
+	}})
+}
+
+// assignOrgID 创建时写入当前组织（覆盖请求中的 OrgID，组织管理员不能把数据建到其他组织）
+func assignOrgID(db *gorm.DB) {
+	orgID, field, ok := scopedOrgField(db)
+	if !ok {
+		return
+	}
+	ctx := db.Statement.Context
+	value := db.Statement.ReflectValue
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			field.Set(ctx, value.Index(i), orgID)
+		}
+	case reflect.Struct:
+		field.Set(ctx, value, orgID)
+	}
+}
@@ -4,6 +4,7 @@
  *   - 套餐模板CRUD操作
  *   - 用户套餐分配和管理
  *   - 额度使用量更新（原子操作）
+ *   - 套餐预算预扣/释放（原子操作，多实例共享）
  *   - 套餐状态自动更新（过期/耗尽）
  * 重要程度：⭐⭐⭐ 一般（套餐管理仓库）
  * 依赖模块：model, gorm
@@ -11,6 +12,8 @@
 package repository
 
 import (
+	"time"
+
 	"go-aiproxy/internal/model"
 
 	"gorm.io/gorm"
@@ -24,6 +27,11 @@ func NewPackageRepository() *PackageRepository {
 	return &PackageRepository{db: DB}
 }
 
+// WithOrg 返回限定组织的仓库（见 ScopeOrg），orgID 为 0 时不限制
+func (r *PackageRepository) WithOrg(orgID uint) *PackageRepository {
+	return &PackageRepository{db: ScopeOrg(r.db, orgID)}
+}
+
 // ========== Package (套餐模板) ==========
 
 // Create 创建套餐
@@ -55,6 +63,13 @@ func (r *PackageRepository) GetActive() ([]model.Package, error) {
 	return packages, err
 }
 
+// GetActiveByOrg 获取指定组织启用的套餐（用户侧只能看到本组织的套餐）
+func (r *PackageRepository) GetActiveByOrg(orgID uint) ([]model.Package, error) {
+	var packages []model.Package
+	err := r.db.Where("status = ? AND org_id = ?", "active", orgID).Order("type, price").Find(&packages).Error
+	return packages, err
+}
+
 // Update 更新套餐
 func (r *PackageRepository) Update(pkg *model.Package) error {
 	return r.db.Save(pkg).Error
@@ -105,8 +120,55 @@ func (r *UserPackageRepository) GetActiveByUserID(userID uint) ([]model.UserPack
 }
 
 // Update 更新用户套餐
+// 预扣列由 ReserveBudget / ReleaseBudget 原子维护，整行保存时不覆盖
 func (r *UserPackageRepository) Update(up *model.UserPackage) error {
-	return r.db.Save(up).Error
+	return r.db.Omit("budget_reserved", "budget_reserved_since").Save(up).Error
+}
+
+// ReserveBudget 原子预扣套餐预算，返回是否预扣成功
+// 没有进行中的预扣时总是允许（保证最后一点余额可用），否则已用 + 预扣总额 + 本次预扣不能超过任一限额
+// 预扣从 0 变为正数后到 staleBefore 仍未清零的视为残留（进程退出未释放）先清零
+// 起始时间只在预扣从 0 变为正数时记录，后续预扣不会刷新，残留不会被持续的新请求一直延后
+func (r *UserPackageRepository) ReserveBudget(up *model.UserPackage, amount float64, staleBefore time.Time) (bool, error) {
+	if err := r.db.Model(&model.UserPackage{}).
+		Where("id = ? AND budget_reserved > 0 AND (budget_reserved_since IS NULL OR budget_reserved_since < ?)", up.ID, staleBefore).
+		Updates(map[string]interface{}{
+			"budget_reserved":       0,
+			"budget_reserved_since": nil,
+		}).Error; err != nil {
+		return false, err
+	}
+
+	query := r.db.Model(&model.UserPackage{}).Where("id = ?", up.ID)
+	switch up.Type {
+	case "subscription":
+		query = query.Where("budget_reserved <= 0 OR ("+
+			"(daily_quota <= 0 OR daily_used + budget_reserved + ? <= daily_quota) AND "+
+			"(weekly_quota <= 0 OR weekly_used + budget_reserved + ? <= weekly_quota) AND "+
+			"(monthly_quota <= 0 OR monthly_used + budget_reserved + ? <= monthly_quota))",
+			amount, amount, amount)
+	case "quota":
+		query = query.Where("budget_reserved <= 0 OR quota_used + budget_reserved + ? <= quota_total", amount)
+	default:
+		return true, nil
+	}
+
+	// 全部释放时起始时间已清空，只有从 0 变为正数的这次预扣会写入
+	result := query.Updates(map[string]interface{}{
+		"budget_reserved":       gorm.Expr("budget_reserved + ?", amount),
+		"budget_reserved_since": gorm.Expr("COALESCE(budget_reserved_since, ?)", time.Now()),
+	})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ReleaseBudget 释放预扣（请求结束后调用），不会减到负数；全部释放后清空预扣起始时间
+func (r *UserPackageRepository) ReleaseBudget(id uint, amount float64) error {
+	// 注意：MySQL 按顺序赋值，budget_reserved 在 budget_reserved_since 之前更新（GORM 按列名排序），后者判断的是释放后的值
+	return r.db.Model(&model.UserPackage{}).Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"budget_reserved":       gorm.Expr("GREATEST(budget_reserved - ?, 0)", amount),
+			"budget_reserved_since": gorm.Expr("IF(budget_reserved > 0, budget_reserved_since, NULL)"),
+		}).Error
 }
 
 // UpdateQuotaUsed 更新已用额度（原子操作）
@@ -2,9 +2,10 @@
  * 文件作用：请求日志数据仓库，提供代理请求记录的数据库操作
  * 负责功能：
  *   - 请求日志创建和查询
- *   - 多条件过滤（账户/平台/模型/时间）
+ *   - 多条件过滤（账户/平台/模型/时间/灰度分组）
  *   - 请求统计汇总
  *   - 账户负载分析
+ *   - region 延迟分布统计
  * 重要程度：⭐⭐⭐ 一般（请求日志仓库）
  * 依赖模块：model, gorm
  */
@@ -30,6 +31,15 @@ func (r *RequestLogRepository) Create(log *model.RequestLog) error {
 	return r.db.Create(log).Error
 }
 
+// GetByID 根据 ID 获取请求日志
+func (r *RequestLogRepository) GetByID(id uint) (*model.RequestLog, error) {
+	var log model.RequestLog
+	if err := r.db.First(&log, id).Error; err != nil {
+		return nil, err
+	}
+	return &log, nil
+}
+
 func (r *RequestLogRepository) List(page, pageSize int, filters map[string]interface{}) ([]model.RequestLog, int64, error) {
 	var logs []model.RequestLog
 	var total int64
@@ -48,6 +58,12 @@ func (r *RequestLogRepository) List(page, pageSize int, filters map[string]inter
 	if success, ok := filters["success"].(bool); ok {
 		query = query.Where("success = ?", success)
 	}
+	if requestID, ok := filters["request_id"].(string); ok && requestID != "" {
+		query = query.Where("request_id = ?", requestID)
+	}
+	if group, ok := filters["traffic_group"].(string); ok && group != "" {
+		query = query.Where("traffic_group = ?", group)
+	}
 	if startTime, ok := filters["start_time"].(time.Time); ok {
 		query = query.Where("created_at >= ?", startTime)
 	}
@@ -78,8 +94,11 @@ func (r *RequestLogRepository) GetSummary(startTime, endTime time.Time) (*model.
 		SUM(CASE WHEN success = false THEN 1 ELSE 0 END) as failed_requests,
 		COALESCE(SUM(input_tokens), 0) as total_input_tokens,
 		COALESCE(SUM(output_tokens), 0) as total_output_tokens,
+		COALESCE(SUM(total_cost), 0) as total_cost,
+		COALESCE(SUM(account_cost), 0) as total_account_cost,
 		COALESCE(AVG(duration), 0) as avg_duration
 	`).Scan(&summary).Error
+	summary.GrossProfit = summary.TotalCost - summary.TotalAccountCost
 
 	return &summary, err
 }
@@ -108,6 +127,28 @@ func (r *RequestLogRepository) GetAccountLoadStats(startTime, endTime time.Time)
 	return stats, err
 }
 
+// GetRegionLatencyStats 按命中账户的 region 统计请求延迟（未标记 region 的请求不计入）
+func (r *RequestLogRepository) GetRegionLatencyStats(startTime, endTime time.Time) ([]model.RegionLatencyStats, error) {
+	var stats []model.RegionLatencyStats
+
+	err := r.db.Model(&model.RequestLog{}).
+		Select(`
+			region,
+			COUNT(*) as request_count,
+			SUM(CASE WHEN success = true THEN 1 ELSE 0 END) as success_count,
+			COALESCE(AVG(duration), 0) as avg_duration,
+			COALESCE(MIN(duration), 0) as min_duration,
+			COALESCE(MAX(duration), 0) as max_duration
+		`).
+		Where("created_at BETWEEN ? AND ?", startTime, endTime).
+		Where("region <> ''").
+		Group("region").
+		Order("request_count DESC").
+		Scan(&stats).Error
+
+	return stats, err
+}
+
 func (r *RequestLogRepository) CleanOldLogs(before time.Time) (int64, error) {
 	result := r.db.Where("created_at < ?", before).Delete(&model.RequestLog{})
 	return result.RowsAffected, result.Error
@@ -162,7 +203,7 @@ type AccountTotalCost struct {
 	TotalCost float64 `json:"total_cost"`
 }
 
-// GetAccountsTotalCost 获取多个账户的总费用
+// GetAccountsTotalCost 获取多个账户的总费用（账户成本）
 func (r *RequestLogRepository) GetAccountsTotalCost(accountIDs []uint) (map[uint]float64, error) {
 	if len(accountIDs) == 0 {
 		return make(map[uint]float64), nil
@@ -172,7 +213,7 @@ func (r *RequestLogRepository) GetAccountsTotalCost(accountIDs []uint) (map[uint
 	err := r.db.Model(&model.RequestLog{}).
 		Select(`
 			account_id,
-			COALESCE(SUM(total_cost), 0) as total_cost
+			COALESCE(SUM(account_cost), 0) as total_cost
 		`).
 		Where("account_id IN ?", accountIDs).
 		Group("account_id").
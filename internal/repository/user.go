@@ -24,6 +24,11 @@ func NewUserRepository() *UserRepository {
 	return &UserRepository{db: DB}
 }
 
+// WithOrg 返回限定组织的仓库（见 ScopeOrg），orgID 为 0 时不限制
+func (r *UserRepository) WithOrg(orgID uint) *UserRepository {
+	return &UserRepository{db: ScopeOrg(r.db, orgID)}
+}
+
 func (r *UserRepository) Create(user *model.User) error {
 	return r.db.Create(user).Error
 }
@@ -69,15 +74,17 @@ func (r *UserRepository) List(page, pageSize int) ([]model.User, int64, error) {
 	return users, total, nil
 }
 
+// ExistsByUsername 用户名全局唯一，不按组织过滤
 func (r *UserRepository) ExistsByUsername(username string) bool {
 	var count int64
-	r.db.Model(&model.User{}).Where("username = ?", username).Count(&count)
+	DB.Model(&model.User{}).Where("username = ?", username).Count(&count)
 	return count > 0
 }
 
+// ExistsByEmail 邮箱全局唯一，不按组织过滤
 func (r *UserRepository) ExistsByEmail(email string) bool {
 	var count int64
-	r.db.Model(&model.User{}).Where("email = ?", email).Count(&count)
+	DB.Model(&model.User{}).Where("email = ?", email).Count(&count)
 	return count > 0
 }
 
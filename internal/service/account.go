@@ -5,15 +5,22 @@
  *   - 账户分组管理
  *   - 账户状态更新
  *   - 调度器缓存刷新通知
+ *   - 账户健康汇总（按平台状态计数、临近限流、高错误率）
  * 重要程度：⭐⭐⭐⭐ 重要（账户管理核心）
  * 依赖模块：repository, scheduler, model
  */
 package service
 
 import (
+	"encoding/json"
 	"errors"
+	"net/url"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
+	"go-aiproxy/internal/cache"
 	"go-aiproxy/internal/model"
 	"go-aiproxy/internal/proxy/scheduler"
 	"go-aiproxy/internal/repository"
@@ -44,6 +51,13 @@ func NewAccountService() *AccountService {
 	}
 }
 
+// WithOrg 返回只能操作指定组织账户的服务，orgID 为 0 时不限制
+func (s *AccountService) WithOrg(orgID uint) *AccountService {
+	scoped := *s
+	scoped.repo = s.repo.WithOrg(orgID)
+	return &scoped
+}
+
 // Account requests
 
 type CreateAccountRequest struct {
@@ -53,7 +67,13 @@ type CreateAccountRequest struct {
 	Priority           int    `json:"priority"`
 	Weight             int    `json:"weight"`
 	MaxConcurrency     int    `json:"max_concurrency"`
+	ModelConcurrency   string `json:"model_concurrency"` // 按模型并发上限 JSON（模型名 -> 上限）
+	DailyBudget        float64 `json:"daily_budget"`        // 每日预算（美元），0 表示不限制
+	DailyRequestLimit  int    `json:"daily_request_limit"` // 每日请求数上限，0 表示不限制
+	ShadowMode         bool   `json:"shadow_mode"`         // 影子账户：不参与正式调度，只接收复制的影子流量
+	OrgID              uint   `json:"org_id"`              // 所属组织（仅超级管理员可指定，组织管理员创建的账户归属本组织）
 	APIKey             string `json:"api_key"`
+	APIKeys            string `json:"api_keys"` // 额外的 API Key 列表（JSON 数组），与 api_key 一起轮换
 	APISecret          string `json:"api_secret"`
 	AccessToken        string `json:"access_token"`
 	RefreshToken       string `json:"refresh_token"`
@@ -61,6 +81,11 @@ type CreateAccountRequest struct {
 	OrganizationID     string `json:"organization_id"`
 	SubscriptionLevel  string `json:"subscription_level"`
 	OpusAccess         bool   `json:"opus_access"`
+	AnthropicVersion   string `json:"anthropic_version"`
+	XApp               string `json:"x_app"`
+	InlineImageURLs    bool   `json:"inline_image_urls"` // 图片 URL 下载后改写为 base64 再发上游
+	SystemPromptPrefix string `json:"system_prompt_prefix"` // 合并到请求 system 开头的内容
+	SystemPromptSuffix string `json:"system_prompt_suffix"` // 合并到请求 system 末尾的内容
 	AWSAccessKey       string `json:"aws_access_key"`
 	AWSSecretKey       string `json:"aws_secret_key"`
 	AWSRegion          string `json:"aws_region"`
@@ -68,10 +93,21 @@ type CreateAccountRequest struct {
 	AzureEndpoint      string `json:"azure_endpoint"`
 	AzureDeploymentName string `json:"azure_deployment_name"`
 	AzureAPIVersion    string `json:"azure_api_version"`
+	ResponsesStripFields  string `json:"responses_strip_fields"` // 非 Codex CLI 请求删除的字段，为空使用全局配置
+	ResponsesInstructions string `json:"responses_instructions"` // 非 Codex CLI 请求的 instructions 处理方式，为空使用全局配置
 	BaseURL            string `json:"base_url"`
 	ModelMapping       string `json:"model_mapping"`
 	AllowedModels      string `json:"allowed_models"`
 	ProxyID            *uint  `json:"proxy_id"`
+	PoolID             *uint  `json:"pool_id"` // 所属账户池，未填写的配置从池继承
+	Region             string `json:"region"`
+	Tags               string `json:"tags"`
+	ConnectTimeout     int    `json:"connect_timeout"` // 上游超时（秒），0 使用默认值
+	ReadTimeout        int    `json:"read_timeout"`
+	RequestTimeout     int    `json:"request_timeout"`
+	StreamTimeout      int    `json:"stream_timeout"`
+	HealthCheckURL     string `json:"health_check_url"`    // 健康检查探测 URL，为空使用默认检查
+	HealthCheckExpect  int    `json:"health_check_expect"` // 探测期望状态码，0 表示任意 2xx
 }
 
 type UpdateAccountRequest struct {
@@ -80,8 +116,14 @@ type UpdateAccountRequest struct {
 	Priority           *int   `json:"priority"`
 	Weight             *int   `json:"weight"`
 	MaxConcurrency     *int   `json:"max_concurrency"`
+	ModelConcurrency   *string `json:"model_concurrency"` // 为空字符串时清除
+	DailyBudget        *float64 `json:"daily_budget"`        // 0 表示不限制
+	DailyRequestLimit  *int    `json:"daily_request_limit"` // 0 表示不限制
+	ShadowMode         *bool   `json:"shadow_mode"`
+	OrgID              *uint   `json:"org_id"`              // 所属组织（仅超级管理员可修改）
 	Status             string `json:"status"`
 	APIKey             string `json:"api_key"`
+	APIKeys            *string `json:"api_keys"` // 为空字符串时清除
 	APISecret          string `json:"api_secret"`
 	AccessToken        string `json:"access_token"`
 	RefreshToken       string `json:"refresh_token"`
@@ -89,6 +131,11 @@ type UpdateAccountRequest struct {
 	OrganizationID     string `json:"organization_id"`
 	SubscriptionLevel  string `json:"subscription_level"`
 	OpusAccess         *bool  `json:"opus_access"`
+	AnthropicVersion   *string `json:"anthropic_version"` // 为空字符串时恢复默认值
+	XApp               *string `json:"x_app"`             // 为空字符串时恢复默认值
+	InlineImageURLs    *bool   `json:"inline_image_urls"`
+	SystemPromptPrefix *string `json:"system_prompt_prefix"` // 为空字符串时清除
+	SystemPromptSuffix *string `json:"system_prompt_suffix"` // 为空字符串时清除
 	AWSAccessKey       string `json:"aws_access_key"`
 	AWSSecretKey       string `json:"aws_secret_key"`
 	AWSRegion          string `json:"aws_region"`
@@ -96,27 +143,106 @@ type UpdateAccountRequest struct {
 	AzureEndpoint      string `json:"azure_endpoint"`
 	AzureDeploymentName string `json:"azure_deployment_name"`
 	AzureAPIVersion    string `json:"azure_api_version"`
+	ResponsesStripFields  *string `json:"responses_strip_fields"` // 为空字符串时使用全局配置
+	ResponsesInstructions *string `json:"responses_instructions"` // 为空字符串时使用全局配置
 	BaseURL            string `json:"base_url"`
 	ModelMapping       string `json:"model_mapping"`
 	AllowedModels      string `json:"allowed_models"`
 	ProxyID            *uint  `json:"proxy_id"`
+	PoolID             *uint  `json:"pool_id"`
+	Region             *string `json:"region"` // 为空字符串时清除
+	Tags               *string `json:"tags"`   // 为空字符串时清除
+	ConnectTimeout     *int    `json:"connect_timeout"` // 上游超时（秒），0 恢复默认值
+	ReadTimeout        *int    `json:"read_timeout"`
+	RequestTimeout     *int    `json:"request_timeout"`
+	StreamTimeout      *int    `json:"stream_timeout"`
+	HealthCheckURL     *string `json:"health_check_url"` // 为空字符串时恢复默认检查
+	HealthCheckExpect  *int    `json:"health_check_expect"`
 	ClearProxy         bool   `json:"clear_proxy"`         // 是否清除代理（设置为 true 时清空 proxy_id）
 	ClearModelMapping  bool   `json:"clear_model_mapping"` // 是否清除模型映射
 	ClearAllowedModels bool   `json:"clear_allowed_models"` // 是否清除允许的模型列表
+	ClearPool          bool   `json:"clear_pool"`           // 是否移出账户池
 }
 
-// Account operations
+// normalizeAPIKeys 校验并规范化 JSON 数组格式的 API Key 列表
+func normalizeAPIKeys(raw string) (string, error) {
+	keys, err := model.ParseAPIKeys(raw)
+	if err != nil {
+		return "", errors.New("api_keys must be a JSON array of strings")
+	}
+	if len(keys) == 0 {
+		return "", nil
+	}
+	data, _ := json.Marshal(keys)
+	return string(data), nil
+}
 
-func (s *AccountService) Create(req *CreateAccountRequest) (*model.Account, error) {
-	getAccountLog().Info("[account] 创建账户请求 | Name: %s | Type: %s | Platform: %s", req.Name, req.Type, model.GetPlatformByType(req.Type))
+// normalizeModelConcurrency 校验并规范化按模型并发上限 JSON
+func normalizeModelConcurrency(raw string) (string, error) {
+	limits, err := model.ParseModelConcurrency(raw)
+	if err != nil {
+		return "", errors.New("model_concurrency must be a JSON object of model name to limit")
+	}
+	for name, limit := range limits {
+		if strings.TrimSpace(name) == "" || limit < 0 {
+			return "", errors.New("model_concurrency limits must be non-negative with non-empty model names")
+		}
+	}
+	if len(limits) == 0 {
+		return "", nil
+	}
+	data, _ := json.Marshal(limits)
+	return string(data), nil
+}
+
+// normalizeHealthCheckURL 校验健康检查探测 URL：完整的 http(s) URL 或以 / 开头的路径
+func normalizeHealthCheckURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || strings.HasPrefix(raw, "/") {
+		return raw, nil
+	}
+	if u, err := url.Parse(raw); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+		return raw, nil
+	}
+	return "", errors.New("health_check_url must be an http(s) URL or a path starting with /")
+}
 
+// normalizeResponsesInstructions 校验 instructions 处理方式，为空表示使用全局配置
+func normalizeResponsesInstructions(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || IsValidResponsesInstructionsMode(raw) {
+		return raw, nil
+	}
+	return "", errors.New("responses_instructions must be override, fill or keep")
+}
+
+// Account operations
+
+// newAccountFromRequest 校验创建请求并构建账户（填充默认值，不写库）
+func newAccountFromRequest(req *CreateAccountRequest) (*model.Account, error) {
 	// 验证账户类型
 	platform := model.GetPlatformByType(req.Type)
 	if platform == "" {
-		getAccountLog().Info("[account] 创建账户失败 | Name: %s | 原因: 无效的账户类型", req.Name)
 		return nil, errors.New("invalid account type")
 	}
 
+	apiKeys, err := normalizeAPIKeys(req.APIKeys)
+	if err != nil {
+		return nil, err
+	}
+	modelConcurrency, err := normalizeModelConcurrency(req.ModelConcurrency)
+	if err != nil {
+		return nil, err
+	}
+	healthCheckURL, err := normalizeHealthCheckURL(req.HealthCheckURL)
+	if err != nil {
+		return nil, err
+	}
+	responsesInstructions, err := normalizeResponsesInstructions(req.ResponsesInstructions)
+	if err != nil {
+		return nil, err
+	}
+
 	account := &model.Account{
 		Name:               req.Name,
 		Type:               req.Type,
@@ -126,7 +252,13 @@ func (s *AccountService) Create(req *CreateAccountRequest) (*model.Account, erro
 		Priority:           req.Priority,
 		Weight:             req.Weight,
 		MaxConcurrency:     req.MaxConcurrency,
+		ModelConcurrency:   modelConcurrency,
+		DailyBudget:        req.DailyBudget,
+		DailyRequestLimit:  req.DailyRequestLimit,
+		ShadowMode:         req.ShadowMode,
+		OrgID:              req.OrgID,
 		APIKey:             req.APIKey,
+		APIKeys:            apiKeys,
 		APISecret:          req.APISecret,
 		AccessToken:        req.AccessToken,
 		RefreshToken:       req.RefreshToken,
@@ -134,6 +266,11 @@ func (s *AccountService) Create(req *CreateAccountRequest) (*model.Account, erro
 		OrganizationID:     req.OrganizationID,
 		SubscriptionLevel:  req.SubscriptionLevel,
 		OpusAccess:         req.OpusAccess,
+		AnthropicVersion:   strings.TrimSpace(req.AnthropicVersion),
+		XApp:               strings.TrimSpace(req.XApp),
+		InlineImageURLs:    req.InlineImageURLs,
+		SystemPromptPrefix: req.SystemPromptPrefix,
+		SystemPromptSuffix: req.SystemPromptSuffix,
 		AWSAccessKey:     req.AWSAccessKey,
 		AWSSecretKey:     req.AWSSecretKey,
 		AWSRegion:          req.AWSRegion,
@@ -141,10 +278,21 @@ func (s *AccountService) Create(req *CreateAccountRequest) (*model.Account, erro
 		AzureEndpoint:      req.AzureEndpoint,
 		AzureDeploymentName: req.AzureDeploymentName,
 		AzureAPIVersion:    req.AzureAPIVersion,
+		ResponsesStripFields:  strings.TrimSpace(req.ResponsesStripFields),
+		ResponsesInstructions: responsesInstructions,
 		BaseURL:            req.BaseURL,
 		ModelMapping:       req.ModelMapping,
 		AllowedModels:      req.AllowedModels,
 		ProxyID:            req.ProxyID,
+		PoolID:             req.PoolID,
+		Region:             strings.TrimSpace(req.Region),
+		Tags:               strings.TrimSpace(req.Tags),
+		ConnectTimeout:     req.ConnectTimeout,
+		ReadTimeout:        req.ReadTimeout,
+		RequestTimeout:     req.RequestTimeout,
+		StreamTimeout:      req.StreamTimeout,
+		HealthCheckURL:     healthCheckURL,
+		HealthCheckExpect:  req.HealthCheckExpect,
 	}
 
 	if account.Priority == 0 {
@@ -157,13 +305,25 @@ func (s *AccountService) Create(req *CreateAccountRequest) (*model.Account, erro
 		account.MaxConcurrency = 5 // 默认并发限制
 	}
 
+	return account, nil
+}
+
+func (s *AccountService) Create(req *CreateAccountRequest) (*model.Account, error) {
+	getAccountLog().Info("[account] 创建账户请求 | Name: %s | Type: %s | Platform: %s", req.Name, req.Type, model.GetPlatformByType(req.Type))
+
+	account, err := newAccountFromRequest(req)
+	if err != nil {
+		getAccountLog().Info("[account] 创建账户失败 | Name: %s | 原因: %v", req.Name, err)
+		return nil, err
+	}
+
 	if err := s.repo.Create(account); err != nil {
 		getAccountLog().Error("[account] 创建账户失败 | Name: %s | 原因: %v", req.Name, err)
 		return nil, err
 	}
 
-	// 刷新调度器缓存
-	scheduler.GetScheduler().Refresh()
+	// 刷新调度器缓存并通知其他实例
+	scheduler.GetScheduler().BroadcastRefresh(account.ID, account.Platform, account.Status)
 
 	getAccountLog().Info("[account] 创建账户成功 | AccountID: %d | Name: %s | Type: %s", account.ID, account.Name, account.Type)
 	return account, nil
@@ -191,15 +351,42 @@ func (s *AccountService) Update(id uint, req *UpdateAccountRequest) (*model.Acco
 	if req.Weight != nil {
 		account.Weight = *req.Weight
 	}
+	if req.OrgID != nil {
+		account.OrgID = *req.OrgID
+	}
 	if req.MaxConcurrency != nil {
 		account.MaxConcurrency = *req.MaxConcurrency
 	}
+	if req.ModelConcurrency != nil {
+		modelConcurrency, err := normalizeModelConcurrency(*req.ModelConcurrency)
+		if err != nil {
+			return nil, err
+		}
+		account.ModelConcurrency = modelConcurrency
+	}
+	if req.DailyBudget != nil {
+		account.DailyBudget = *req.DailyBudget
+	}
+	if req.DailyRequestLimit != nil {
+		account.DailyRequestLimit = *req.DailyRequestLimit
+	}
+	if req.ShadowMode != nil {
+		account.ShadowMode = *req.ShadowMode
+	}
 	if req.Status != "" {
 		account.Status = req.Status
 	}
 	if req.APIKey != "" {
 		account.APIKey = req.APIKey
 	}
+	if req.APIKeys != nil {
+		apiKeys, err := normalizeAPIKeys(*req.APIKeys)
+		if err != nil {
+			return nil, err
+		}
+		account.APIKeys = apiKeys
+		account.InvalidAPIKeys = "" // Key 列表变更后由健康检查重新标记
+	}
 	if req.APISecret != "" {
 		account.APISecret = req.APISecret
 	}
@@ -209,8 +396,11 @@ func (s *AccountService) Update(id uint, req *UpdateAccountRequest) (*model.Acco
 	if req.RefreshToken != "" {
 		account.RefreshToken = req.RefreshToken
 	}
-	if req.SessionKey != "" {
+	if req.SessionKey != "" && req.SessionKey != account.SessionKey {
 		account.SessionKey = req.SessionKey
+		// 换了新的 SessionKey，旧的校验结果作废
+		account.SessionKeyValid = nil
+		account.SessionKeyCheckedAt = nil
 	}
 	if req.OrganizationID != "" {
 		account.OrganizationID = req.OrganizationID
@@ -221,6 +411,21 @@ func (s *AccountService) Update(id uint, req *UpdateAccountRequest) (*model.Acco
 	if req.OpusAccess != nil {
 		account.OpusAccess = *req.OpusAccess
 	}
+	if req.AnthropicVersion != nil {
+		account.AnthropicVersion = strings.TrimSpace(*req.AnthropicVersion)
+	}
+	if req.XApp != nil {
+		account.XApp = strings.TrimSpace(*req.XApp)
+	}
+	if req.InlineImageURLs != nil {
+		account.InlineImageURLs = *req.InlineImageURLs
+	}
+	if req.SystemPromptPrefix != nil {
+		account.SystemPromptPrefix = *req.SystemPromptPrefix
+	}
+	if req.SystemPromptSuffix != nil {
+		account.SystemPromptSuffix = *req.SystemPromptSuffix
+	}
 	if req.AWSAccessKey != "" {
 		account.AWSAccessKey = req.AWSAccessKey
 	}
@@ -255,6 +460,49 @@ func (s *AccountService) Update(id uint, req *UpdateAccountRequest) (*model.Acco
 	} else if req.ClearAllowedModels {
 		account.AllowedModels = ""
 	}
+	if req.Region != nil {
+		account.Region = strings.TrimSpace(*req.Region)
+	}
+	if req.Tags != nil {
+		account.Tags = strings.TrimSpace(*req.Tags)
+	}
+	if req.ConnectTimeout != nil {
+		account.ConnectTimeout = *req.ConnectTimeout
+	}
+	if req.ReadTimeout != nil {
+		account.ReadTimeout = *req.ReadTimeout
+	}
+	if req.RequestTimeout != nil {
+		account.RequestTimeout = *req.RequestTimeout
+	}
+	if req.StreamTimeout != nil {
+		account.StreamTimeout = *req.StreamTimeout
+	}
+	if req.HealthCheckURL != nil {
+		healthCheckURL, err := normalizeHealthCheckURL(*req.HealthCheckURL)
+		if err != nil {
+			return nil, err
+		}
+		account.HealthCheckURL = healthCheckURL
+	}
+	if req.HealthCheckExpect != nil {
+		account.HealthCheckExpect = *req.HealthCheckExpect
+	}
+	if req.ResponsesStripFields != nil {
+		account.ResponsesStripFields = strings.TrimSpace(*req.ResponsesStripFields)
+	}
+	if req.ResponsesInstructions != nil {
+		responsesInstructions, err := normalizeResponsesInstructions(*req.ResponsesInstructions)
+		if err != nil {
+			return nil, err
+		}
+		account.ResponsesInstructions = responsesInstructions
+	}
+	if req.ClearPool {
+		account.PoolID = nil
+	} else if req.PoolID != nil {
+		account.PoolID = req.PoolID
+	}
 	// 处理代理：ClearProxy 优先级高于 ProxyID
 	clearProxyAfterUpdate := false
 	if req.ClearProxy {
@@ -276,8 +524,8 @@ func (s *AccountService) Update(id uint, req *UpdateAccountRequest) (*model.Acco
 		account.ProxyID = nil
 	}
 
-	// 刷新调度器缓存
-	scheduler.GetScheduler().Refresh()
+	// 刷新调度器缓存并通知其他实例（平台可能被修改，全量刷新）
+	scheduler.GetScheduler().BroadcastRefresh(account.ID, "", account.Status)
 
 	return account, nil
 }
@@ -289,8 +537,8 @@ func (s *AccountService) Delete(id uint) error {
 		return err
 	}
 
-	// 刷新调度器缓存
-	scheduler.GetScheduler().Refresh()
+	// 刷新调度器缓存并通知其他实例
+	scheduler.GetScheduler().BroadcastRefresh(id, "", "deleted")
 
 	getAccountLog().Info("[account] 删除账户成功 | AccountID: %d", id)
 	return nil
@@ -313,13 +561,175 @@ func (s *AccountService) UpdateStatus(id uint, status, lastError string) error {
 		return err
 	}
 	getAccountLog().Info("[account] 更新账户状态成功 | AccountID: %d | Status: %s", id, status)
+
+	// 刷新调度器缓存并通知其他实例
+	scheduler.GetScheduler().BroadcastRefresh(id, "", status)
 	return nil
 }
 
+// SetMaintenanceMode 切换账户维护模式（维护中不接流量，健康检查继续）
+func (s *AccountService) SetMaintenanceMode(id uint, enabled bool) (*model.Account, error) {
+	getAccountLog().Info("[account] 切换维护模式 | AccountID: %d | Enabled: %v", id, enabled)
+	if err := s.repo.SetMaintenanceMode(id, enabled); err != nil {
+		getAccountLog().Error("[account] 切换维护模式失败 | AccountID: %d | 原因: %v", id, err)
+		return nil, err
+	}
+
+	account, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// 刷新调度器缓存并通知其他实例
+	scheduler.GetScheduler().BroadcastRefresh(id, account.Platform, account.Status)
+	return account, nil
+}
+
 func (s *AccountService) GetByPlatform(platform string) ([]model.Account, error) {
 	return s.repo.GetByPlatform(platform)
 }
 
+// ========== 账户健康汇总 ==========
+
+// AccountHealthItem 账户健康汇总中的单个账户信息
+type AccountHealthItem struct {
+	ID          uint    `json:"id"`
+	Name        string  `json:"name"`
+	Type        string  `json:"type"`
+	Platform    string  `json:"platform"`
+	Status      string  `json:"status"`
+	Utilization float64 `json:"utilization"`  // 用量百分比 (0-100)，取 5H/7D 窗口和并发占用的最大值
+	SuccessRate float64 `json:"success_rate"` // 成功率百分比 (0-100)
+	Requests    int64   `json:"requests"`
+	Errors      int64   `json:"errors"`
+}
+
+// AccountHealthSummary 账户健康汇总（用于仪表盘）
+type AccountHealthSummary struct {
+	Total       int                       `json:"total"`
+	ByPlatform  map[string]map[string]int `json:"by_platform"` // platform -> status -> count
+	NearLimit   []AccountHealthItem       `json:"near_limit"`  // 最接近限流的账户
+	Flakiest    []AccountHealthItem       `json:"flakiest"`    // 成功率最低的账户
+	GeneratedAt time.Time                 `json:"generated_at"`
+}
+
+// GetHealthSummary 获取账户健康汇总
+// topN 为临近限流和高错误率列表的长度
+// 状态计数使用分组聚合查询；临近限流和高错误率列表从数据库读取所有启用账户（包括限流中、不可调度的账户），只查需要的列
+func (s *AccountService) GetHealthSummary(topN int) (*AccountHealthSummary, error) {
+	counts, err := s.repo.CountByPlatformAndStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	accounts, err := s.repo.ListEnabledForHealthSummary()
+	if err != nil {
+		return nil, err
+	}
+	concurrency := cache.GetSessionCache().GetAllAccountConcurrency()
+	return buildAccountHealthSummary(counts, accounts, concurrency, topN), nil
+}
+
+// buildAccountHealthSummary 根据状态计数、启用账户和当前并发数计算健康汇总
+func buildAccountHealthSummary(counts []repository.AccountStatusCount, accounts []model.Account, concurrency map[uint]int64, topN int) *AccountHealthSummary {
+	if topN <= 0 {
+		topN = 5
+	}
+
+	summary := &AccountHealthSummary{
+		ByPlatform:  make(map[string]map[string]int),
+		NearLimit:   []AccountHealthItem{},
+		Flakiest:    []AccountHealthItem{},
+		GeneratedAt: time.Now(),
+	}
+
+	for _, count := range counts {
+		// 手动禁用的账户统一计入 disabled
+		status := count.Status
+		if !count.Enabled {
+			status = model.AccountStatusDisabled
+		}
+		if summary.ByPlatform[count.Platform] == nil {
+			summary.ByPlatform[count.Platform] = make(map[string]int)
+		}
+		summary.ByPlatform[count.Platform][status] += int(count.Count)
+		summary.Total += int(count.Count)
+	}
+
+	var withUsage, withRequests []AccountHealthItem
+	for _, acc := range accounts {
+		if !acc.Enabled {
+			continue
+		}
+
+		item := AccountHealthItem{
+			ID:       acc.ID,
+			Name:     acc.Name,
+			Type:     acc.Type,
+			Platform: acc.Platform,
+			Status:   acc.Status,
+			Requests: acc.RequestCount,
+			Errors:   acc.ErrorCount,
+		}
+		if acc.RequestCount > 0 {
+			errCount := acc.ErrorCount
+			if errCount > acc.RequestCount {
+				errCount = acc.RequestCount
+			}
+			item.SuccessRate = float64(acc.RequestCount-errCount) / float64(acc.RequestCount) * 100
+		}
+
+		utilization, hasUsage := accountUtilization(&acc, concurrency[acc.ID])
+		item.Utilization = utilization
+		if hasUsage {
+			withUsage = append(withUsage, item)
+		}
+		if acc.RequestCount > 0 {
+			withRequests = append(withRequests, item)
+		}
+	}
+
+	sort.SliceStable(withUsage, func(i, j int) bool {
+		return withUsage[i].Utilization > withUsage[j].Utilization
+	})
+	sort.SliceStable(withRequests, func(i, j int) bool {
+		return withRequests[i].SuccessRate < withRequests[j].SuccessRate
+	})
+
+	if len(withUsage) > topN {
+		withUsage = withUsage[:topN]
+	}
+	if len(withRequests) > topN {
+		withRequests = withRequests[:topN]
+	}
+	summary.NearLimit = append(summary.NearLimit, withUsage...)
+	summary.Flakiest = append(summary.Flakiest, withRequests...)
+
+	return summary
+}
+
+// accountUtilization 计算账户用量百分比，取 5H/7D 窗口与并发占用中的最大值
+// 第二个返回值表示账户是否有任何用量数据
+func accountUtilization(acc *model.Account, currentConcurrency int64) (float64, bool) {
+	var utilization float64
+	hasUsage := false
+	for _, u := range []*float64{acc.FiveHourUtilization, acc.SevenDayUtilization, acc.SevenDayOpusUtilization, acc.SevenDaySonnetUtilization} {
+		if u != nil {
+			hasUsage = true
+			if *u > utilization {
+				utilization = *u
+			}
+		}
+	}
+	if acc.MaxConcurrency > 0 && currentConcurrency > 0 {
+		hasUsage = true
+		if c := float64(currentConcurrency) / float64(acc.MaxConcurrency) * 100; c > utilization {
+			utilization = c
+		}
+	}
+	return utilization, hasUsage
+}
+
 // AccountGroup operations
 
 type CreateGroupRequest struct {
@@ -327,6 +737,7 @@ type CreateGroupRequest struct {
 	Description string `json:"description"`
 	Platform    string `json:"platform"`
 	IsDefault   bool   `json:"is_default"`
+	Strategy    string `json:"strategy"` // 组内调度策略：random / round_robin / failover，默认 random
 }
 
 type UpdateGroupRequest struct {
@@ -334,20 +745,35 @@ type UpdateGroupRequest struct {
 	Description string `json:"description"`
 	Platform    string `json:"platform"`
 	IsDefault   *bool  `json:"is_default"`
+	Strategy    string `json:"strategy"`
 }
 
+// ErrInvalidGroupStrategy 不支持的分组调度策略
+var ErrInvalidGroupStrategy = errors.New("invalid group strategy")
+
 func (s *AccountService) CreateGroup(req *CreateGroupRequest) (*model.AccountGroup, error) {
+	strategy := req.Strategy
+	if strategy == "" {
+		strategy = model.AccountGroupStrategyRandom
+	}
+	if !model.IsValidAccountGroupStrategy(strategy) {
+		return nil, ErrInvalidGroupStrategy
+	}
+
 	group := &model.AccountGroup{
 		Name:        req.Name,
 		Description: req.Description,
 		Platform:    req.Platform,
 		IsDefault:   req.IsDefault,
+		Strategy:    strategy,
 	}
 
 	if err := s.groupRepo.Create(group); err != nil {
 		return nil, err
 	}
 
+	// 分组策略变更，刷新调度器缓存（含其他实例）
+	scheduler.GetScheduler().BroadcastRefresh(0, "", "group")
 	return group, nil
 }
 
@@ -373,16 +799,27 @@ func (s *AccountService) UpdateGroup(id uint, req *UpdateGroupRequest) (*model.A
 	if req.IsDefault != nil {
 		group.IsDefault = *req.IsDefault
 	}
+	if req.Strategy != "" {
+		if !model.IsValidAccountGroupStrategy(req.Strategy) {
+			return nil, ErrInvalidGroupStrategy
+		}
+		group.Strategy = req.Strategy
+	}
 
 	if err := s.groupRepo.Update(group); err != nil {
 		return nil, err
 	}
 
+	scheduler.GetScheduler().BroadcastRefresh(0, "", "group")
 	return group, nil
 }
 
 func (s *AccountService) DeleteGroup(id uint) error {
-	return s.groupRepo.Delete(id)
+	if err := s.groupRepo.Delete(id); err != nil {
+		return err
+	}
+	scheduler.GetScheduler().BroadcastRefresh(0, "", "group")
+	return nil
 }
 
 func (s *AccountService) ListGroups(page, pageSize int) ([]model.AccountGroup, int64, error) {
@@ -400,9 +837,17 @@ func (s *AccountService) GetAllGroups() ([]model.AccountGroup, error) {
 }
 
 func (s *AccountService) AddAccountToGroup(groupID, accountID uint) error {
-	return s.groupRepo.AddAccount(groupID, accountID)
+	if err := s.groupRepo.AddAccount(groupID, accountID); err != nil {
+		return err
+	}
+	scheduler.GetScheduler().BroadcastRefresh(accountID, "", "group")
+	return nil
 }
 
 func (s *AccountService) RemoveAccountFromGroup(groupID, accountID uint) error {
-	return s.groupRepo.RemoveAccount(groupID, accountID)
+	if err := s.groupRepo.RemoveAccount(groupID, accountID); err != nil {
+		return err
+	}
+	scheduler.GetScheduler().BroadcastRefresh(accountID, "", "group")
+	return nil
 }
@@ -0,0 +1,261 @@
+/*
+ * 文件作用：账户池业务服务，管理账户池及池内账户
+ * 负责功能：
+ *   - 账户池 CRUD（策略、模型映射、按模型并发校验）
+ *   - 批量加入/移出池内账户
+ *   - 变更后刷新调度器缓存（含其他实例）
+ * 重要程度：⭐⭐⭐ 一般（大规模账户管理）
+ * 依赖模块：model, repository, scheduler
+ */
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"go-aiproxy/internal/model"
+	"go-aiproxy/internal/proxy/scheduler"
+	"go-aiproxy/internal/repository"
+)
+
+// ErrInvalidPoolModelMapping 账户池模型映射不是合法的 JSON 对象
+var ErrInvalidPoolModelMapping = errors.New("model_mapping must be a JSON object of model names")
+
+type AccountPoolService struct {
+	repo *repository.AccountPoolRepository
+}
+
+func NewAccountPoolService() *AccountPoolService {
+	return &AccountPoolService{repo: repository.NewAccountPoolRepository()}
+}
+
+type CreatePoolRequest struct {
+	Name             string `json:"name" binding:"required"`
+	Description      string `json:"description"`
+	Platform         string `json:"platform"`
+	Enabled          *bool  `json:"enabled"`  // 默认启用
+	Priority         int    `json:"priority"` // 池作为一个逻辑账户的优先级，默认 50
+	Weight           int    `json:"weight"`   // 池作为一个逻辑账户的权重，默认 100
+	Strategy         string `json:"strategy"` // 池内调度策略：random / round_robin / failover，默认 random
+	BaseURL          string `json:"base_url"`
+	ProxyID          *uint  `json:"proxy_id"`
+	AllowedModels    string `json:"allowed_models"`
+	ModelMapping     string `json:"model_mapping"`
+	MaxConcurrency   int    `json:"max_concurrency"`   // 每个账户的并发上限，0 使用账户自身配置
+	ModelConcurrency string `json:"model_concurrency"` // 按模型并发上限 JSON（模型名 -> 上限）
+}
+
+type UpdatePoolRequest struct {
+	Name             string  `json:"name"`
+	Description      *string `json:"description"`
+	Platform         *string `json:"platform"`
+	Enabled          *bool   `json:"enabled"`
+	Priority         *int    `json:"priority"`
+	Weight           *int    `json:"weight"`
+	Strategy         string  `json:"strategy"`
+	BaseURL          *string `json:"base_url"` // 为空字符串时清除
+	ProxyID          *uint   `json:"proxy_id"`
+	ClearProxy       bool    `json:"clear_proxy"`    // 是否清除代理
+	AllowedModels    *string `json:"allowed_models"` // 为空字符串时清除
+	ModelMapping     *string `json:"model_mapping"`  // 为空字符串时清除
+	MaxConcurrency   *int    `json:"max_concurrency"`
+	ModelConcurrency *string `json:"model_concurrency"` // 为空字符串时清除
+}
+
+// normalizePoolModelMapping 校验模型映射 JSON（源模型 -> 目标模型）
+func normalizePoolModelMapping(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", nil
+	}
+	var mapping map[string]string
+	if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+		return "", ErrInvalidPoolModelMapping
+	}
+	if len(mapping) == 0 {
+		return "", nil
+	}
+	return raw, nil
+}
+
+func (s *AccountPoolService) Create(req *CreatePoolRequest) (*model.AccountPool, error) {
+	strategy := req.Strategy
+	if strategy == "" {
+		strategy = model.AccountGroupStrategyRandom
+	}
+	if !model.IsValidAccountGroupStrategy(strategy) {
+		return nil, ErrInvalidGroupStrategy
+	}
+	modelMapping, err := normalizePoolModelMapping(req.ModelMapping)
+	if err != nil {
+		return nil, err
+	}
+	modelConcurrency, err := normalizeModelConcurrency(req.ModelConcurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := &model.AccountPool{
+		Name:             req.Name,
+		Description:      req.Description,
+		Platform:         req.Platform,
+		Enabled:          true,
+		Priority:         req.Priority,
+		Weight:           req.Weight,
+		Strategy:         strategy,
+		BaseURL:          strings.TrimSpace(req.BaseURL),
+		ProxyID:          req.ProxyID,
+		AllowedModels:    strings.TrimSpace(req.AllowedModels),
+		ModelMapping:     modelMapping,
+		MaxConcurrency:   req.MaxConcurrency,
+		ModelConcurrency: modelConcurrency,
+	}
+	if req.Enabled != nil {
+		pool.Enabled = *req.Enabled
+	}
+	if pool.Priority == 0 {
+		pool.Priority = 50
+	}
+	if pool.Weight == 0 {
+		pool.Weight = 100
+	}
+
+	if err := s.repo.Create(pool); err != nil {
+		return nil, err
+	}
+	// gorm 对零值使用默认值，创建为停用时需要单独写入
+	if !pool.Enabled {
+		if err := s.repo.Update(pool); err != nil {
+			return nil, err
+		}
+	}
+
+	scheduler.GetScheduler().BroadcastRefresh(0, "", "pool")
+	return pool, nil
+}
+
+func (s *AccountPoolService) GetByID(id uint) (*model.AccountPool, error) {
+	return s.repo.GetByID(id)
+}
+
+func (s *AccountPoolService) Update(id uint, req *UpdatePoolRequest) (*model.AccountPool, error) {
+	pool, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != "" {
+		pool.Name = req.Name
+	}
+	if req.Description != nil {
+		pool.Description = *req.Description
+	}
+	if req.Platform != nil {
+		pool.Platform = *req.Platform
+	}
+	if req.Enabled != nil {
+		pool.Enabled = *req.Enabled
+	}
+	if req.Priority != nil {
+		pool.Priority = *req.Priority
+	}
+	if req.Weight != nil {
+		pool.Weight = *req.Weight
+	}
+	if req.Strategy != "" {
+		if !model.IsValidAccountGroupStrategy(req.Strategy) {
+			return nil, ErrInvalidGroupStrategy
+		}
+		pool.Strategy = req.Strategy
+	}
+	if req.BaseURL != nil {
+		pool.BaseURL = strings.TrimSpace(*req.BaseURL)
+	}
+	if req.ClearProxy {
+		pool.ProxyID = nil
+	} else if req.ProxyID != nil {
+		pool.ProxyID = req.ProxyID
+	}
+	if req.AllowedModels != nil {
+		pool.AllowedModels = strings.TrimSpace(*req.AllowedModels)
+	}
+	if req.ModelMapping != nil {
+		modelMapping, err := normalizePoolModelMapping(*req.ModelMapping)
+		if err != nil {
+			return nil, err
+		}
+		pool.ModelMapping = modelMapping
+	}
+	if req.MaxConcurrency != nil {
+		pool.MaxConcurrency = *req.MaxConcurrency
+	}
+	if req.ModelConcurrency != nil {
+		modelConcurrency, err := normalizeModelConcurrency(*req.ModelConcurrency)
+		if err != nil {
+			return nil, err
+		}
+		pool.ModelConcurrency = modelConcurrency
+	}
+
+	if err := s.repo.Update(pool); err != nil {
+		return nil, err
+	}
+
+	scheduler.GetScheduler().BroadcastRefresh(0, "", "pool")
+	return pool, nil
+}
+
+func (s *AccountPoolService) Delete(id uint) error {
+	if err := s.repo.Delete(id); err != nil {
+		return err
+	}
+	scheduler.GetScheduler().BroadcastRefresh(0, "", "pool")
+	return nil
+}
+
+func (s *AccountPoolService) List(page, pageSize int) ([]model.AccountPool, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	return s.repo.List(page, pageSize)
+}
+
+func (s *AccountPoolService) GetAll() ([]model.AccountPool, error) {
+	return s.repo.GetAll()
+}
+
+// GetAccounts 获取池内账户（账户自身配置，未套用池配置）
+func (s *AccountPoolService) GetAccounts(poolID uint) ([]model.Account, error) {
+	if _, err := s.repo.GetByID(poolID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetAccounts(poolID)
+}
+
+// AddAccounts 批量把账户加入池，返回实际加入的账户数（池指定了平台时跳过其他平台的账户）
+func (s *AccountPoolService) AddAccounts(poolID uint, accountIDs []uint) (int64, error) {
+	pool, err := s.repo.GetByID(poolID)
+	if err != nil {
+		return 0, err
+	}
+	added, err := s.repo.AddAccounts(pool, accountIDs)
+	if err != nil {
+		return 0, err
+	}
+	if added > 0 {
+		scheduler.GetScheduler().BroadcastRefresh(0, "", "pool")
+	}
+	return added, nil
+}
+
+func (s *AccountPoolService) RemoveAccount(poolID, accountID uint) error {
+	if err := s.repo.RemoveAccount(poolID, accountID); err != nil {
+		return err
+	}
+	scheduler.GetScheduler().BroadcastRefresh(accountID, "", "pool")
+	return nil
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"testing"
+
+	"go-aiproxy/internal/model"
+	"go-aiproxy/internal/repository"
+)
+
+func floatPtr(v float64) *float64 { return &v }
+
+func TestBuildAccountHealthSummaryBucketsByStatus(t *testing.T) {
+	counts := []repository.AccountStatusCount{
+		{Platform: model.PlatformClaude, Status: model.AccountStatusValid, Enabled: true, Count: 3},
+		{Platform: model.PlatformClaude, Status: model.AccountStatusRateLimited, Enabled: true, Count: 2},
+		// 手动禁用的账户不论原状态都计入 disabled
+		{Platform: model.PlatformClaude, Status: model.AccountStatusValid, Enabled: false, Count: 1},
+		{Platform: model.PlatformClaude, Status: model.AccountStatusRateLimited, Enabled: false, Count: 1},
+		{Platform: model.PlatformOpenAI, Status: model.AccountStatusSuspended, Enabled: true, Count: 4},
+	}
+
+	summary := buildAccountHealthSummary(counts, nil, nil, 5)
+
+	if summary.Total != 11 {
+		t.Fatalf("Total = %d, want 11", summary.Total)
+	}
+	want := map[string]map[string]int{
+		model.PlatformClaude: {
+			model.AccountStatusValid:       3,
+			model.AccountStatusRateLimited: 2,
+			model.AccountStatusDisabled:    2,
+		},
+		model.PlatformOpenAI: {
+			model.AccountStatusSuspended: 4,
+		},
+	}
+	if len(summary.ByPlatform) != len(want) {
+		t.Fatalf("ByPlatform = %v, want %v", summary.ByPlatform, want)
+	}
+	for platform, statuses := range want {
+		got := summary.ByPlatform[platform]
+		if len(got) != len(statuses) {
+			t.Fatalf("ByPlatform[%s] = %v, want %v", platform, got, statuses)
+		}
+		for status, n := range statuses {
+			if got[status] != n {
+				t.Fatalf("ByPlatform[%s][%s] = %d, want %d", platform, status, got[status], n)
+			}
+		}
+	}
+	if len(summary.NearLimit) != 0 || len(summary.Flakiest) != 0 {
+		t.Fatalf("没有账户数据时列表应为空: near=%v flaky=%v", summary.NearLimit, summary.Flakiest)
+	}
+}
+
+func TestBuildAccountHealthSummaryNearLimitAndFlakiest(t *testing.T) {
+	accounts := []model.Account{
+		{ID: 1, Platform: model.PlatformClaude, Status: model.AccountStatusValid, Enabled: true,
+			FiveHourUtilization: floatPtr(95), RequestCount: 100, ErrorCount: 1},
+		{ID: 2, Platform: model.PlatformClaude, Status: model.AccountStatusValid, Enabled: true,
+			SevenDayUtilization: floatPtr(40), RequestCount: 100, ErrorCount: 50},
+		// 并发占用 8/10 = 80%
+		{ID: 3, Platform: model.PlatformOpenAI, Status: model.AccountStatusValid, Enabled: true,
+			MaxConcurrency: 10, RequestCount: 10, ErrorCount: 9},
+		// 没有用量数据，不进入临近限流列表
+		{ID: 4, Platform: model.PlatformOpenAI, Status: model.AccountStatusValid, Enabled: true,
+			RequestCount: 100, ErrorCount: 0},
+		// 没有请求，不进入高错误率列表
+		{ID: 5, Platform: model.PlatformGemini, Status: model.AccountStatusValid, Enabled: true,
+			FiveHourUtilization: floatPtr(10)},
+		// 限流中的账户不在调度缓存中，同样进入列表
+		{ID: 7, Platform: model.PlatformClaude, Status: model.AccountStatusRateLimited, Enabled: true,
+			FiveHourUtilization: floatPtr(100), RequestCount: 20, ErrorCount: 4},
+		// 禁用账户不进入列表
+		{ID: 6, Platform: model.PlatformClaude, Status: model.AccountStatusValid, Enabled: false,
+			FiveHourUtilization: floatPtr(100), RequestCount: 10, ErrorCount: 10},
+	}
+	concurrency := map[uint]int64{3: 8}
+
+	summary := buildAccountHealthSummary(nil, accounts, concurrency, 3)
+
+	assertIDs := func(name string, items []AccountHealthItem, want ...uint) {
+		t.Helper()
+		if len(items) != len(want) {
+			t.Fatalf("%s = %v, want IDs %v", name, items, want)
+		}
+		for i, id := range want {
+			if items[i].ID != id {
+				t.Fatalf("%s[%d].ID = %d, want %d (items: %v)", name, i, items[i].ID, id, items)
+			}
+		}
+	}
+	assertIDs("NearLimit", summary.NearLimit, 7, 1, 3)
+	assertIDs("Flakiest", summary.Flakiest, 3, 2, 7)
+
+	if got := summary.NearLimit[2].Utilization; got != 80 {
+		t.Fatalf("账户 3 的用量 = %v, want 80（并发占用）", got)
+	}
+	if got := summary.Flakiest[0].SuccessRate; got != 10 {
+		t.Fatalf("账户 3 的成功率 = %v, want 10", got)
+	}
+}
@@ -0,0 +1,627 @@
+/*
+ * 文件作用：账户批量导入/导出服务
+ * 负责功能：
+ *   - JSON/CSV 格式批量导入账户（逐条校验，返回每条结果）
+ *   - 重名检测（文件内及数据库已有账户）
+ *   - 原子模式：任一条失败则整批不创建
+ *   - 导出账户配置，凭证可脱敏或使用口令加密
+ * 重要程度：⭐⭐⭐ 一般（账户运维）
+ * 依赖模块：repository, scheduler, utils
+ */
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"go-aiproxy/internal/model"
+	"go-aiproxy/internal/proxy/scheduler"
+	"go-aiproxy/pkg/utils"
+)
+
+// 导入/导出格式
+const (
+	TransferFormatJSON = "json"
+	TransferFormatCSV  = "csv"
+)
+
+// 导出时凭证处理方式
+const (
+	CredentialModeMask    = "mask"    // 脱敏，仅用于查看，不能再导入
+	CredentialModeEncrypt = "encrypt" // 口令加密，可导入
+)
+
+// 导入遇到重名账户时的处理方式
+const (
+	DuplicateSkip  = "skip"  // 跳过该条
+	DuplicateError = "error" // 视为失败
+)
+
+// 导入单条结果状态
+const (
+	ImportStatusCreated = "created"
+	ImportStatusSkipped = "skipped"
+	ImportStatusFailed  = "failed"
+)
+
+// maskedMarker 脱敏凭证中的标记，导入时据此拒绝脱敏数据
+const maskedMarker = "****"
+
+// maxImportAccounts 单次导入上限
+const maxImportAccounts = 1000
+
+// AccountImportRequest 批量导入请求
+type AccountImportRequest struct {
+	Format      string `json:"format"`       // json / csv，默认 json
+	Content     string `json:"content"`      // 文件内容
+	Atomic      bool   `json:"atomic"`       // 原子模式：任一条失败则全部不创建
+	OnDuplicate string `json:"on_duplicate"` // skip / error，默认 skip
+	Passphrase  string `json:"passphrase"`   // 解密导出时加密的凭证
+}
+
+// AccountImportItem 单条导入结果
+type AccountImportItem struct {
+	Index     int    `json:"index"` // 从 1 开始，CSV 不含表头
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	AccountID uint   `json:"account_id,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// AccountImportResult 批量导入结果
+type AccountImportResult struct {
+	Total   int                 `json:"total"`
+	Created int                 `json:"created"`
+	Skipped int                 `json:"skipped"`
+	Failed  int                 `json:"failed"`
+	Items   []AccountImportItem `json:"items"`
+}
+
+// AccountExportRequest 批量导出请求
+type AccountExportRequest struct {
+	IDs            []uint `json:"ids"`             // 为空时按 platform 导出
+	Platform       string `json:"platform"`        // 为空时导出全部
+	Format         string `json:"format"`          // json / csv，默认 json
+	CredentialMode string `json:"credential_mode"` // mask / encrypt，默认 mask
+	Passphrase     string `json:"passphrase"`      // encrypt 模式必填
+}
+
+// AccountExportResult 批量导出结果
+type AccountExportResult struct {
+	Format  string `json:"format"`
+	Count   int    `json:"count"`
+	Content string `json:"content"`
+}
+
+// ImportAccounts 批量导入账户
+func (s *AccountService) ImportAccounts(req *AccountImportRequest) (*AccountImportResult, error) {
+	if req.OnDuplicate == "" {
+		req.OnDuplicate = DuplicateSkip
+	}
+	if req.OnDuplicate != DuplicateSkip && req.OnDuplicate != DuplicateError {
+		return nil, errors.New("on_duplicate must be skip or error")
+	}
+
+	var (
+		items []CreateAccountRequest
+		err   error
+	)
+	switch strings.ToLower(req.Format) {
+	case "", TransferFormatJSON:
+		items, err = parseAccountsJSON(req.Content)
+	case TransferFormatCSV:
+		items, err = parseAccountsCSV(req.Content)
+	default:
+		return nil, errors.New("format must be json or csv")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, errors.New("no accounts to import")
+	}
+	if len(items) > maxImportAccounts {
+		return nil, fmt.Errorf("too many accounts, at most %d per import", maxImportAccounts)
+	}
+
+	names := make([]string, 0, len(items))
+	for i := range items {
+		items[i].Name = strings.TrimSpace(items[i].Name)
+		items[i].Type = strings.TrimSpace(items[i].Type)
+		names = append(names, items[i].Name)
+	}
+	existingNames, err := s.repo.GetExistingNames(names)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]bool, len(existingNames))
+	for _, name := range existingNames {
+		existing[name] = true
+	}
+
+	result := &AccountImportResult{Total: len(items), Items: make([]AccountImportItem, len(items))}
+	var (
+		toCreate []*model.Account
+		indexes  []int // toCreate 对应的 Items 下标
+	)
+	// 同一文件的密文共用一个盐，解密器按盐缓存派生密钥，整批只派生一次
+	var decrypter *utils.PassphraseCipher
+	if req.Passphrase != "" {
+		if decrypter, err = utils.NewPassphraseCipher(req.Passphrase); err != nil {
+			return nil, err
+		}
+	}
+	seen := make(map[string]bool, len(items))
+	for i := range items {
+		item := &items[i]
+		result.Items[i] = AccountImportItem{Index: i + 1, Name: item.Name}
+
+		if item.Name != "" && (existing[item.Name] || seen[item.Name]) {
+			if req.OnDuplicate == DuplicateSkip {
+				result.Items[i].Status = ImportStatusSkipped
+				result.Items[i].Error = "duplicate name"
+			} else {
+				result.Items[i].Status = ImportStatusFailed
+				result.Items[i].Error = "duplicate name"
+			}
+			continue
+		}
+		seen[item.Name] = true
+
+		account, err := s.prepareImportAccount(item, decrypter)
+		if err != nil {
+			result.Items[i].Status = ImportStatusFailed
+			result.Items[i].Error = err.Error()
+			continue
+		}
+		toCreate = append(toCreate, account)
+		indexes = append(indexes, i)
+	}
+
+	hasFailure := false
+	for _, item := range result.Items {
+		if item.Status == ImportStatusFailed {
+			hasFailure = true
+			break
+		}
+	}
+
+	switch {
+	case req.Atomic && hasFailure:
+		// 原子模式下有失败：整批不创建
+		for _, i := range indexes {
+			result.Items[i].Status = ImportStatusSkipped
+			result.Items[i].Error = "not created: batch has failures (atomic)"
+		}
+		toCreate = nil
+	case req.Atomic:
+		if err := s.repo.CreateBatch(toCreate); err != nil {
+			getAccountLog().Error("[account] 批量导入失败，已回滚 | 数量: %d | 原因: %v", len(toCreate), err)
+			for _, i := range indexes {
+				result.Items[i].Status = ImportStatusFailed
+				result.Items[i].Error = "transaction rolled back: " + err.Error()
+			}
+			toCreate = nil
+			break
+		}
+		for n, i := range indexes {
+			result.Items[i].Status = ImportStatusCreated
+			result.Items[i].AccountID = toCreate[n].ID
+		}
+	default:
+		created := toCreate[:0:0]
+		for n, i := range indexes {
+			if err := s.repo.Create(toCreate[n]); err != nil {
+				result.Items[i].Status = ImportStatusFailed
+				result.Items[i].Error = err.Error()
+				continue
+			}
+			result.Items[i].Status = ImportStatusCreated
+			result.Items[i].AccountID = toCreate[n].ID
+			created = append(created, toCreate[n])
+		}
+		toCreate = created
+	}
+
+	for _, item := range result.Items {
+		switch item.Status {
+		case ImportStatusCreated:
+			result.Created++
+		case ImportStatusSkipped:
+			result.Skipped++
+		case ImportStatusFailed:
+			result.Failed++
+		}
+	}
+
+	if len(toCreate) > 0 {
+		// 批量变更，刷新所有平台并通知其他实例
+		scheduler.GetScheduler().BroadcastRefresh(0, "", model.AccountStatusValid)
+	}
+
+	getAccountLog().Info("[account] 批量导入账户 | 总数: %d | 成功: %d | 跳过: %d | 失败: %d | 原子模式: %v",
+		result.Total, result.Created, result.Skipped, result.Failed, req.Atomic)
+	return result, nil
+}
+
+// prepareImportAccount 解密凭证并校验单条导入数据，返回待创建的账户
+// decrypter 为 nil 表示导入时未提供口令
+func (s *AccountService) prepareImportAccount(req *CreateAccountRequest, decrypter *utils.PassphraseCipher) (*model.Account, error) {
+	if req.Name == "" {
+		return nil, errors.New("name is required")
+	}
+	if model.GetPlatformByType(req.Type) == model.PlatformOther {
+		return nil, fmt.Errorf("invalid account type: %s", req.Type)
+	}
+
+	for _, field := range credentialFields(req) {
+		v := strings.TrimSpace(*field.value)
+		if strings.Contains(v, maskedMarker) {
+			return nil, fmt.Errorf("%s is masked, export with credential_mode=encrypt to re-import", field.name)
+		}
+		if utils.IsEncrypted(v) {
+			if decrypter == nil {
+				return nil, fmt.Errorf("%s is encrypted, passphrase is required", field.name)
+			}
+			plain, err := decrypter.Decrypt(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", field.name, err)
+			}
+			v = plain
+		}
+		*field.value = v
+	}
+
+	if err := validateImportCredentials(req); err != nil {
+		return nil, err
+	}
+	if req.ProxyID != nil && *req.ProxyID > 0 {
+		if _, err := GetProxyService().GetByID(*req.ProxyID); err != nil {
+			return nil, fmt.Errorf("proxy %d not found", *req.ProxyID)
+		}
+	}
+	if req.ModelMapping != "" && !json.Valid([]byte(req.ModelMapping)) {
+		return nil, errors.New("model_mapping must be valid JSON")
+	}
+
+	return newAccountFromRequest(req)
+}
+
+// validateImportCredentials 按账户类型校验必需的凭证字段
+func validateImportCredentials(req *CreateAccountRequest) error {
+	switch req.Type {
+	case model.AccountTypeClaudeOfficial:
+		if req.AccessToken == "" && req.RefreshToken == "" && req.SessionKey == "" {
+			return errors.New("access_token, refresh_token or session_key is required")
+		}
+	case model.AccountTypeClaudeConsole, model.AccountTypeOpenAI, model.AccountTypeGeminiAPI, model.AccountTypeDroid:
+		if req.APIKey == "" && req.APIKeys == "" {
+			return errors.New("api_key or api_keys is required")
+		}
+	case model.AccountTypeOpenAIResponses:
+		if req.SessionKey == "" && req.AccessToken == "" && req.APIKey == "" {
+			return errors.New("session_key, access_token or api_key is required")
+		}
+	case model.AccountTypeBedrock:
+		if req.AWSAccessKey == "" || req.AWSSecretKey == "" {
+			return errors.New("aws_access_key and aws_secret_key are required")
+		}
+	case model.AccountTypeAzureOpenAI:
+		if req.APIKey == "" || req.AzureEndpoint == "" || req.AzureDeploymentName == "" {
+			return errors.New("api_key, azure_endpoint and azure_deployment_name are required")
+		}
+	case model.AccountTypeGemini:
+		if req.AccessToken == "" && req.RefreshToken == "" {
+			return errors.New("access_token or refresh_token is required")
+		}
+	}
+	return nil
+}
+
+// ExportAccounts 导出账户配置，输出格式可直接用于导入
+func (s *AccountService) ExportAccounts(req *AccountExportRequest) (*AccountExportResult, error) {
+	if req.CredentialMode == "" {
+		req.CredentialMode = CredentialModeMask
+	}
+	if req.CredentialMode != CredentialModeMask && req.CredentialMode != CredentialModeEncrypt {
+		return nil, errors.New("credential_mode must be mask or encrypt")
+	}
+	if req.CredentialMode == CredentialModeEncrypt && len(req.Passphrase) < 8 {
+		return nil, errors.New("passphrase must be at least 8 characters")
+	}
+	format := strings.ToLower(req.Format)
+	if format == "" {
+		format = TransferFormatJSON
+	}
+	if format != TransferFormatJSON && format != TransferFormatCSV {
+		return nil, errors.New("format must be json or csv")
+	}
+
+	var (
+		accounts []model.Account
+		err      error
+	)
+	switch {
+	case len(req.IDs) > 0:
+		accounts, err = s.repo.GetByIDs(req.IDs)
+	case req.Platform != "":
+		accounts, err = s.repo.GetByPlatform(req.Platform)
+	default:
+		accounts, err = s.repo.GetAll()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 一次导出使用同一个随机盐，密钥只派生一次
+	var encrypter *utils.PassphraseCipher
+	if req.CredentialMode == CredentialModeEncrypt {
+		if encrypter, err = utils.NewPassphraseCipher(req.Passphrase); err != nil {
+			return nil, err
+		}
+	}
+	items := make([]CreateAccountRequest, 0, len(accounts))
+	for i := range accounts {
+		item := accountToCreateRequest(&accounts[i])
+		for _, field := range credentialFields(&item) {
+			if *field.value == "" {
+				continue
+			}
+			if req.CredentialMode == CredentialModeEncrypt {
+				enc, err := encrypter.Encrypt(*field.value)
+				if err != nil {
+					return nil, err
+				}
+				*field.value = enc
+			} else {
+				*field.value = maskCredential(*field.value)
+			}
+		}
+		items = append(items, item)
+	}
+
+	var content string
+	if format == TransferFormatCSV {
+		content, err = encodeAccountsCSV(items)
+	} else {
+		var data []byte
+		data, err = json.MarshalIndent(items, "", "  ")
+		content = string(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	getAccountLog().Info("[account] 导出账户 | 数量: %d | 格式: %s | 凭证: %s", len(items), format, req.CredentialMode)
+	return &AccountExportResult{Format: format, Count: len(items), Content: content}, nil
+}
+
+// accountToCreateRequest 账户转换为创建请求结构（导出格式与导入格式一致）
+func accountToCreateRequest(a *model.Account) CreateAccountRequest {
+	return CreateAccountRequest{
+		Name:                  a.Name,
+		Type:                  a.Type,
+		Enabled:               a.Enabled,
+		Priority:              a.Priority,
+		Weight:                a.Weight,
+		MaxConcurrency:        a.MaxConcurrency,
+		APIKey:                a.APIKey,
+		APIKeys:               a.APIKeys,
+		APISecret:             a.APISecret,
+		AccessToken:           a.AccessToken,
+		RefreshToken:          a.RefreshToken,
+		SessionKey:            a.SessionKey,
+		OrganizationID:        a.OrganizationID,
+		SubscriptionLevel:     a.SubscriptionLevel,
+		OpusAccess:            a.OpusAccess,
+		AnthropicVersion:      a.AnthropicVersion,
+		XApp:                  a.XApp,
+		InlineImageURLs:       a.InlineImageURLs,
+		SystemPromptPrefix:    a.SystemPromptPrefix,
+		SystemPromptSuffix:    a.SystemPromptSuffix,
+		AWSAccessKey:          a.AWSAccessKey,
+		AWSSecretKey:          a.AWSSecretKey,
+		AWSRegion:             a.AWSRegion,
+		AWSSessionToken:       a.AWSSessionToken,
+		AzureEndpoint:         a.AzureEndpoint,
+		AzureDeploymentName:   a.AzureDeploymentName,
+		AzureAPIVersion:       a.AzureAPIVersion,
+		BaseURL:               a.BaseURL,
+		ModelMapping:          a.ModelMapping,
+		AllowedModels:         a.AllowedModels,
+		ProxyID:               a.ProxyID,
+		Region:                a.Region,
+		Tags:                  a.Tags,
+		ShadowMode:            a.ShadowMode,
+		ConnectTimeout:        a.ConnectTimeout,
+		ReadTimeout:           a.ReadTimeout,
+		RequestTimeout:        a.RequestTimeout,
+		StreamTimeout:         a.StreamTimeout,
+		HealthCheckURL:        a.HealthCheckURL,
+		HealthCheckExpect:     a.HealthCheckExpect,
+		ResponsesStripFields:  a.ResponsesStripFields,
+		ResponsesInstructions: a.ResponsesInstructions,
+	}
+}
+
+// credentialField 凭证字段（名称 + 指向请求中对应字段的指针）
+type credentialField struct {
+	name  string
+	value *string
+}
+
+// credentialFields 返回需要脱敏/加密的凭证字段
+func credentialFields(req *CreateAccountRequest) []credentialField {
+	return []credentialField{
+		{"api_key", &req.APIKey},
+		{"api_keys", &req.APIKeys},
+		{"api_secret", &req.APISecret},
+		{"access_token", &req.AccessToken},
+		{"refresh_token", &req.RefreshToken},
+		{"session_key", &req.SessionKey},
+		{"aws_access_key", &req.AWSAccessKey},
+		{"aws_secret_key", &req.AWSSecretKey},
+		{"aws_session_token", &req.AWSSessionToken},
+	}
+}
+
+// maskCredential 凭证脱敏，只保留首尾少量字符
+func maskCredential(v string) string {
+	if len(v) <= 12 {
+		return maskedMarker
+	}
+	return v[:4] + maskedMarker + v[len(v)-4:]
+}
+
+// parseAccountsJSON 解析 JSON 数组，未指定 enabled 的条目默认启用
+func parseAccountsJSON(content string) ([]CreateAccountRequest, error) {
+	var raws []json.RawMessage
+	if err := json.Unmarshal([]byte(content), &raws); err != nil {
+		return nil, errors.New("content must be a JSON array of accounts")
+	}
+	items := make([]CreateAccountRequest, len(raws))
+	for i, raw := range raws {
+		items[i].Enabled = true
+		if err := json.Unmarshal(raw, &items[i]); err != nil {
+			return nil, fmt.Errorf("item %d: %v", i+1, err)
+		}
+	}
+	return items, nil
+}
+
+// csvColumns 返回 CreateAccountRequest 的 json 标签到字段下标的映射
+func csvColumns() ([]string, map[string]int) {
+	t := reflect.TypeOf(CreateAccountRequest{})
+	names := make([]string, 0, t.NumField())
+	index := make(map[string]int, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+		names = append(names, tag)
+		index[tag] = i
+	}
+	return names, index
+}
+
+// parseAccountsCSV 解析 CSV，首行为表头（列名同 JSON 字段名），未知列忽略
+func parseAccountsCSV(content string) ([]CreateAccountRequest, error) {
+	reader := csv.NewReader(strings.NewReader(content))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("csv header is required")
+	}
+	_, index := csvColumns()
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff")))
+	}
+	hasColumn := make(map[string]bool, len(header))
+	for _, h := range header {
+		hasColumn[h] = true
+	}
+	if !hasColumn["name"] || !hasColumn["type"] {
+		return nil, errors.New("csv header must include name and type")
+	}
+
+	var items []CreateAccountRequest
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("csv row %d: %v", row, err)
+		}
+		item := CreateAccountRequest{Enabled: true}
+		v := reflect.ValueOf(&item).Elem()
+		for col, value := range record {
+			if col >= len(header) {
+				break
+			}
+			fieldIndex, ok := index[header[col]]
+			value = strings.TrimSpace(value)
+			if !ok || value == "" {
+				continue
+			}
+			if err := setCSVField(v.Field(fieldIndex), value); err != nil {
+				return nil, fmt.Errorf("csv row %d, column %s: %v", row, header[col], err)
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// setCSVField 将 CSV 单元格写入请求字段
+func setCSVField(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		field.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("must be true or false")
+		}
+		field.SetBool(b)
+	case reflect.Ptr:
+		if field.Type().Elem().Kind() != reflect.Uint {
+			return errors.New("unsupported column")
+		}
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return errors.New("must be a positive integer")
+		}
+		u := uint(n)
+		field.Set(reflect.ValueOf(&u))
+	default:
+		return errors.New("unsupported column")
+	}
+	return nil
+}
+
+// encodeAccountsCSV 导出为 CSV，列名同 JSON 字段名
+func encodeAccountsCSV(items []CreateAccountRequest) (string, error) {
+	names, index := csvColumns()
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(names); err != nil {
+		return "", err
+	}
+	for i := range items {
+		v := reflect.ValueOf(items[i])
+		record := make([]string, len(names))
+		for col, name := range names {
+			field := v.Field(index[name])
+			switch field.Kind() {
+			case reflect.Ptr:
+				if !field.IsNil() {
+					record[col] = fmt.Sprint(field.Elem().Interface())
+				}
+			default:
+				record[col] = fmt.Sprint(field.Interface())
+			}
+		}
+		if err := w.Write(record); err != nil {
+			return "", err
+		}
+	}
+	w.Flush()
+	return buf.String(), w.Error()
+}
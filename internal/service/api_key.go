@@ -6,6 +6,7 @@
  *   - API Key 状态管理
  *   - 使用量统计
  *   - 管理员批量操作
+ *   - IP 白名单校验与最近访问 IP 记录
  * 重要程度：⭐⭐⭐⭐ 重要（API Key管理核心）
  * 依赖模块：repository, model
  */
@@ -13,9 +14,12 @@ package service
 
 import (
 	"errors"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"go-aiproxy/internal/cache"
 	"go-aiproxy/internal/model"
 	"go-aiproxy/internal/repository"
 	"go-aiproxy/pkg/logger"
@@ -36,25 +40,44 @@ func getAPIKeyLog() *logger.Logger {
 type APIKeyService struct {
 	repo            *repository.APIKeyRepository
 	userPackageRepo *repository.UserPackageRepository
+	userRepo        *repository.UserRepository
+	tokenBucketRepo *repository.APIKeyTokenBucketRepository
 }
 
 func NewAPIKeyService() *APIKeyService {
 	return &APIKeyService{
 		repo:            repository.NewAPIKeyRepository(),
 		userPackageRepo: repository.NewUserPackageRepository(),
+		userRepo:        repository.NewUserRepository(),
+		tokenBucketRepo: repository.NewAPIKeyTokenBucketRepository(),
 	}
 }
 
+// WithOrg 返回只能操作指定组织 API Key 的服务，orgID 为 0 时不限制
+func (s *APIKeyService) WithOrg(orgID uint) *APIKeyService {
+	scoped := *s
+	scoped.repo = s.repo.WithOrg(orgID)
+	return &scoped
+}
+
 // CreateAPIKeyRequest 创建 API Key 请求
 type CreateAPIKeyRequest struct {
-	Name             string     `json:"name" binding:"required"`
-	UserPackageID    uint       `json:"user_package_id" binding:"required"` // 必须绑定用户套餐
-	AllowedPlatforms string     `json:"allowed_platforms"`
-	AllowedModels    string     `json:"allowed_models"`
-	RateLimit        int        `json:"rate_limit"`
-	DailyLimit       int        `json:"daily_limit"`
-	MonthlyQuota     float64    `json:"monthly_quota"`
-	ExpiresAt        *time.Time `json:"expires_at"`
+	Name                 string     `json:"name" binding:"required"`
+	UserPackageID        uint       `json:"user_package_id" binding:"required"` // 必须绑定用户套餐
+	AllowedPlatforms     string     `json:"allowed_platforms"`
+	AllowedModels        string     `json:"allowed_models"`
+	AllowedIPs           string     `json:"allowed_ips"`
+	RateLimit            int        `json:"rate_limit"`
+	DailyLimit           int        `json:"daily_limit"`
+	MonthlyQuota         float64    `json:"monthly_quota"`
+	ExpiresAt            *time.Time `json:"expires_at"`
+	PreferredRegion      string     `json:"preferred_region"`       // 偏好 region（就近调度）
+	SessionStickiness    string     `json:"session_stickiness"`     // 会话粘性策略: best_effort / strict，空为默认
+	StripThinking        bool       `json:"strip_thinking"`         // 流式响应隐藏思考内容
+	ResponseCacheEnabled bool       `json:"response_cache_enabled"` // 响应缓存
+	ResponseCacheTTL     int        `json:"response_cache_ttl"`     // 响应缓存时间（秒），0 使用全局配置
+	TokenBucketCapacity  int        `json:"token_bucket_capacity"`  // 令牌桶容量（0=不限速）
+	TokenBucketRate      float64    `json:"token_bucket_rate"`      // 令牌桶每秒填充速率（0=不限速）
 }
 
 // CreateAPIKeyResponse 创建 API Key 响应 (只在创建时返回完整 key)
@@ -84,6 +107,23 @@ func (s *APIKeyService) Create(userID uint, req *CreateAPIKeyRequest) (*CreateAP
 		return nil, errors.New("套餐未激活，无法创建 API Key")
 	}
 
+	if _, err := model.ParseAllowedIPs(req.AllowedIPs); err != nil {
+		return nil, err
+	}
+	if !model.IsValidSessionStickiness(req.SessionStickiness) {
+		return nil, errors.New("无效的会话粘性策略")
+	}
+	if req.ResponseCacheTTL < 0 {
+		return nil, errors.New("响应缓存时间不能为负数")
+	}
+
+	// API Key 归属与用户相同的组织
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		getAPIKeyLog().Info("[apikey] 创建 API Key 失败 | UserID: %d | 原因: 用户不存在", userID)
+		return nil, errors.New("用户不存在")
+	}
+
 	// 从套餐获取计费类型
 	billingType := userPackage.Type
 
@@ -107,20 +147,29 @@ func (s *APIKeyService) Create(userID uint, req *CreateAPIKeyRequest) (*CreateAP
 
 	packageID := req.UserPackageID
 	apiKey := &model.APIKey{
-		UserID:           userID,
-		Name:             req.Name,
-		KeyHash:          hash,
-		KeyFull:          key,
-		KeyPrefix:        prefix,
-		Status:           "active",
-		BillingType:      billingType,
-		UserPackageID:    &packageID,
-		AllowedPlatforms: allowedPlatforms,
-		AllowedModels:    req.AllowedModels,
-		RateLimit:        rateLimit,
-		DailyLimit:       req.DailyLimit,
-		MonthlyQuota:     req.MonthlyQuota,
-		ExpiresAt:        req.ExpiresAt,
+		UserID:               userID,
+		OrgID:                user.OrgID,
+		Name:                 req.Name,
+		KeyHash:              hash,
+		KeyFull:              key,
+		KeyPrefix:            prefix,
+		Status:               "active",
+		BillingType:          billingType,
+		UserPackageID:        &packageID,
+		AllowedPlatforms:     allowedPlatforms,
+		AllowedModels:        req.AllowedModels,
+		AllowedIPs:           req.AllowedIPs,
+		RateLimit:            rateLimit,
+		DailyLimit:           req.DailyLimit,
+		MonthlyQuota:         req.MonthlyQuota,
+		ExpiresAt:            req.ExpiresAt,
+		PreferredRegion:      strings.TrimSpace(req.PreferredRegion),
+		SessionStickiness:    req.SessionStickiness,
+		StripThinking:        req.StripThinking,
+		ResponseCacheEnabled: req.ResponseCacheEnabled,
+		ResponseCacheTTL:     req.ResponseCacheTTL,
+		TokenBucketCapacity:  req.TokenBucketCapacity,
+		TokenBucketRate:      req.TokenBucketRate,
 	}
 
 	if err := s.repo.Create(apiKey); err != nil {
@@ -219,14 +268,23 @@ func (s *APIKeyService) IncrementUsage(id uint, tokens int64, cost float64) erro
 
 // UpdateAPIKeyRequest 更新 API Key 请求
 type UpdateAPIKeyRequest struct {
-	Name             string     `json:"name"`
-	AllowedPlatforms string     `json:"allowed_platforms"`
-	AllowedModels    string     `json:"allowed_models"`
-	RateLimit        int        `json:"rate_limit"`
-	DailyLimit       int        `json:"daily_limit"`
-	MonthlyQuota     float64    `json:"monthly_quota"`
-	ExpiresAt        *time.Time `json:"expires_at"`
-	Status           string     `json:"status"`
+	Name                 string     `json:"name"`
+	AllowedPlatforms     string     `json:"allowed_platforms"`
+	AllowedModels        string     `json:"allowed_models"`
+	AllowedIPs           string     `json:"allowed_ips"`
+	RateLimit            int        `json:"rate_limit"`
+	DailyLimit           int        `json:"daily_limit"`
+	MonthlyQuota         float64    `json:"monthly_quota"`
+	ExpiresAt            *time.Time `json:"expires_at"`
+	Status               string     `json:"status"`
+	PreferredRegion      *string    `json:"preferred_region"`       // 偏好 region，为空字符串时清除
+	SessionStickiness    *string    `json:"session_stickiness"`     // 会话粘性策略，为空字符串时恢复默认
+	StripThinking        *bool      `json:"strip_thinking"`         // 流式响应隐藏思考内容
+	ResponseCacheEnabled *bool      `json:"response_cache_enabled"` // 响应缓存
+	ResponseCacheTTL     *int       `json:"response_cache_ttl"`     // 响应缓存时间（秒），0 使用全局配置
+	TokenBucketCapacity  *int       `json:"token_bucket_capacity"`  // 令牌桶容量，为 0 时不限速
+	TokenBucketRate      *float64   `json:"token_bucket_rate"`      // 令牌桶每秒填充速率，为 0 时不限速
+	ClearAllowedIPs      bool       `json:"clear_allowed_ips"`      // 是否清除 IP 白名单
 }
 
 // Update 更新 API Key
@@ -250,6 +308,14 @@ func (s *APIKeyService) Update(id uint, userID uint, req *UpdateAPIKeyRequest) (
 	if req.AllowedModels != "" {
 		key.AllowedModels = req.AllowedModels
 	}
+	if req.AllowedIPs != "" {
+		if _, err := model.ParseAllowedIPs(req.AllowedIPs); err != nil {
+			return nil, err
+		}
+		key.AllowedIPs = req.AllowedIPs
+	} else if req.ClearAllowedIPs {
+		key.AllowedIPs = ""
+	}
 	if req.RateLimit > 0 {
 		key.RateLimit = req.RateLimit
 	}
@@ -265,10 +331,47 @@ func (s *APIKeyService) Update(id uint, userID uint, req *UpdateAPIKeyRequest) (
 	if req.Status != "" {
 		key.Status = req.Status
 	}
+	if req.PreferredRegion != nil {
+		key.PreferredRegion = strings.TrimSpace(*req.PreferredRegion)
+	}
+	if req.SessionStickiness != nil {
+		if !model.IsValidSessionStickiness(*req.SessionStickiness) {
+			return nil, errors.New("无效的会话粘性策略")
+		}
+		key.SessionStickiness = *req.SessionStickiness
+	}
+	if req.StripThinking != nil {
+		key.StripThinking = *req.StripThinking
+	}
+	if req.ResponseCacheEnabled != nil {
+		key.ResponseCacheEnabled = *req.ResponseCacheEnabled
+	}
+	if req.ResponseCacheTTL != nil {
+		if *req.ResponseCacheTTL < 0 {
+			return nil, errors.New("响应缓存时间不能为负数")
+		}
+		key.ResponseCacheTTL = *req.ResponseCacheTTL
+	}
+	resetTokenBucket := req.TokenBucketCapacity != nil || req.TokenBucketRate != nil
+	if resetTokenBucket {
+		if req.TokenBucketCapacity != nil {
+			key.TokenBucketCapacity = *req.TokenBucketCapacity
+		}
+		if req.TokenBucketRate != nil {
+			key.TokenBucketRate = *req.TokenBucketRate
+		}
+	}
 
 	if err := s.repo.Update(key); err != nil {
 		return nil, err
 	}
+	if resetTokenBucket {
+		// 桶参数变更后按新参数重新计算
+		if err := s.tokenBucketRepo.Delete(key.ID); err != nil {
+			getAPIKeyLog().Warn("重置 API Key 令牌桶失败 | KeyID: %d | Error: %v", key.ID, err)
+		}
+		cache.GetTokenBucketLimiter().Reset(key.ID)
+	}
 
 	return key, nil
 }
@@ -295,6 +398,17 @@ func (s *APIKeyService) AdminCreate(userID uint, req *CreateAPIKeyRequest) (*Cre
 		return nil, errors.New("该套餐不属于指定用户")
 	}
 
+	if _, err := model.ParseAllowedIPs(req.AllowedIPs); err != nil {
+		return nil, err
+	}
+
+	// API Key 归属与用户相同的组织
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		getAPIKeyLog().Info("[apikey] 管理员创建 API Key 失败 | UserID: %d | 原因: 用户不存在", userID)
+		return nil, errors.New("用户不存在")
+	}
+
 	// 从套餐获取计费类型
 	billingType := userPackage.Type
 
@@ -319,6 +433,7 @@ func (s *APIKeyService) AdminCreate(userID uint, req *CreateAPIKeyRequest) (*Cre
 	packageID := req.UserPackageID
 	apiKey := &model.APIKey{
 		UserID:           userID,
+		OrgID:            user.OrgID,
 		Name:             req.Name,
 		KeyHash:          hash,
 		KeyFull:          key,
@@ -328,6 +443,7 @@ func (s *APIKeyService) AdminCreate(userID uint, req *CreateAPIKeyRequest) (*Cre
 		UserPackageID:    &packageID,
 		AllowedPlatforms: allowedPlatforms,
 		AllowedModels:    req.AllowedModels,
+		AllowedIPs:       req.AllowedIPs,
 		RateLimit:        rateLimit,
 		DailyLimit:       req.DailyLimit,
 		MonthlyQuota:     req.MonthlyQuota,
@@ -385,6 +501,172 @@ func (s *APIKeyService) AdminToggleStatus(id uint) (*model.APIKey, error) {
 	return key, nil
 }
 
+// AdminUpdateAllowedIPs 管理员更新 API Key 的 IP 白名单（空字符串表示不限制）
+func (s *APIKeyService) AdminUpdateAllowedIPs(id uint, allowedIPs string) (*model.APIKey, error) {
+	if _, err := model.ParseAllowedIPs(allowedIPs); err != nil {
+		return nil, err
+	}
+
+	key, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	key.AllowedIPs = allowedIPs
+	if err := s.repo.Update(key); err != nil {
+		getAPIKeyLog().Error("[apikey] 管理员更新 IP 白名单失败 | KeyID: %d | 原因: %v", id, err)
+		return nil, err
+	}
+
+	getAPIKeyLog().Info("[apikey] 管理员更新 IP 白名单成功 | KeyID: %d | AllowedIPs: %s", id, allowedIPs)
+	return key, nil
+}
+
+// AdminUpdateModelFallback 管理员更新 API Key 的模型回退设置（回退链为空时使用全局配置）
+// crossPlatform 为 nil 时不修改跨平台兜底开关
+func (s *APIKeyService) AdminUpdateModelFallback(id uint, modelFallback string, disabled bool, crossPlatform *bool) (*model.APIKey, error) {
+	if _, err := model.ParseModelFallbackChains(modelFallback); err != nil {
+		return nil, err
+	}
+
+	key, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	key.ModelFallback = modelFallback
+	key.DisableModelFallback = disabled
+	if crossPlatform != nil {
+		key.CrossPlatformFallback = *crossPlatform
+	}
+	if err := s.repo.Update(key); err != nil {
+		getAPIKeyLog().Error("[apikey] 管理员更新模型回退失败 | KeyID: %d | 原因: %v", id, err)
+		return nil, err
+	}
+
+	getAPIKeyLog().Info("[apikey] 管理员更新模型回退成功 | KeyID: %d | Disabled: %v | CrossPlatform: %v | Chains: %s",
+		id, disabled, key.CrossPlatformFallback, modelFallback)
+	return key, nil
+}
+
+// AdminUpdatePriority 管理员更新 API Key 的调度优先级
+func (s *APIKeyService) AdminUpdatePriority(id uint, priority int) (*model.APIKey, error) {
+	if priority < 0 {
+		return nil, errors.New("优先级不能为负数")
+	}
+
+	key, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	key.Priority = priority
+	if err := s.repo.Update(key); err != nil {
+		getAPIKeyLog().Error("[apikey] 管理员更新优先级失败 | KeyID: %d | 原因: %v", id, err)
+		return nil, err
+	}
+
+	getAPIKeyLog().Info("[apikey] 管理员更新优先级成功 | KeyID: %d | Priority: %d", id, priority)
+	return key, nil
+}
+
+// AdminUpdateMaxOutputTokens 管理员更新 API Key 的单请求输出 token 上限（0 表示使用全局配置）
+func (s *APIKeyService) AdminUpdateMaxOutputTokens(id uint, limit int) (*model.APIKey, error) {
+	if limit < 0 {
+		return nil, errors.New("输出 token 上限不能为负数")
+	}
+
+	key, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	key.MaxOutputTokensLimit = limit
+	if err := s.repo.Update(key); err != nil {
+		getAPIKeyLog().Error("[apikey] 管理员更新输出 token 上限失败 | KeyID: %d | 原因: %v", id, err)
+		return nil, err
+	}
+
+	getAPIKeyLog().Info("[apikey] 管理员更新输出 token 上限成功 | KeyID: %d | Limit: %d", id, limit)
+	return key, nil
+}
+
+// AdminUpdateDegradeResponse 管理员更新 API Key 的降级响应设置（文本为空时使用全局配置）
+func (s *APIKeyService) AdminUpdateDegradeResponse(id uint, enabled bool, text string) (*model.APIKey, error) {
+	key, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	key.DegradeResponseEnabled = enabled
+	key.DegradeResponseText = strings.TrimSpace(text)
+	if err := s.repo.Update(key); err != nil {
+		getAPIKeyLog().Error("[apikey] 管理员更新降级响应失败 | KeyID: %d | 原因: %v", id, err)
+		return nil, err
+	}
+
+	getAPIKeyLog().Info("[apikey] 管理员更新降级响应成功 | KeyID: %d | Enabled: %v", id, enabled)
+	return key, nil
+}
+
+// AdminUpdateSystemPrompt 管理员更新 API Key 的 Claude system prompt 前缀、后缀（为空表示不注入）
+func (s *APIKeyService) AdminUpdateSystemPrompt(id uint, prefix, suffix string) (*model.APIKey, error) {
+	key, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	key.SystemPromptPrefix = strings.TrimSpace(prefix)
+	key.SystemPromptSuffix = strings.TrimSpace(suffix)
+	if err := s.repo.Update(key); err != nil {
+		getAPIKeyLog().Error("[apikey] 管理员更新 system prompt 失败 | KeyID: %d | 原因: %v", id, err)
+		return nil, err
+	}
+
+	getAPIKeyLog().Info("[apikey] 管理员更新 system prompt 成功 | KeyID: %d | 前缀长度: %d | 后缀长度: %d",
+		id, len(key.SystemPromptPrefix), len(key.SystemPromptSuffix))
+	return key, nil
+}
+
+// AdminUpdateCanary 管理员设置 API Key 的灰度规则，标签为空时使用全局灰度配置
+func (s *APIKeyService) AdminUpdateCanary(id uint, tag string, percent int) (*model.APIKey, error) {
+	if percent < 0 || percent > 100 {
+		return nil, errors.New("灰度比例必须在 0-100 之间")
+	}
+
+	key, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	key.CanaryTag = strings.TrimSpace(tag)
+	key.CanaryPercent = percent
+	if err := s.repo.Update(key); err != nil {
+		getAPIKeyLog().Error("[apikey] 管理员更新灰度规则失败 | KeyID: %d | 原因: %v", id, err)
+		return nil, err
+	}
+
+	getAPIKeyLog().Info("[apikey] 管理员更新灰度规则成功 | KeyID: %d | Tag: %s | Percent: %d", id, key.CanaryTag, percent)
+	return key, nil
+}
+
+// AdminUpdateForceAccount 管理员设置 API Key 是否允许通过请求头强制指定账户
+func (s *APIKeyService) AdminUpdateForceAccount(id uint, allow bool) (*model.APIKey, error) {
+	key, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	key.AllowForceAccount = allow
+	if err := s.repo.Update(key); err != nil {
+		getAPIKeyLog().Error("[apikey] 管理员更新强制指定账户权限失败 | KeyID: %d | 原因: %v", id, err)
+		return nil, err
+	}
+
+	getAPIKeyLog().Info("[apikey] 管理员更新强制指定账户权限成功 | KeyID: %d | Allow: %v", id, allow)
+	return key, nil
+}
+
 // AdminListAll 管理员获取所有 API Key（带用户信息）
 func (s *APIKeyService) AdminListAll(page, pageSize int) ([]model.APIKey, int64, error) {
 	return s.repo.ListAllWithUser(page, pageSize)
@@ -399,3 +681,75 @@ func (s *APIKeyService) AdminLookup(ids []uint) ([]model.APIKey, error) {
 func (s *APIKeyService) GetAPIKeyLogs(keyID uint, page, pageSize int) ([]map[string]interface{}, int64, error) {
 	return s.repo.GetAPIKeyLogs(keyID, page, pageSize)
 }
+
+// ========== IP 访问记录 ==========
+
+// maxIPAccessRecords 每个 API Key 保留的最近访问 IP 数量
+const maxIPAccessRecords = 20
+
+// IPAccessRecord API Key 的 IP 访问记录
+type IPAccessRecord struct {
+	IP       string    `json:"ip"`
+	Allowed  bool      `json:"allowed"`
+	Count    int64     `json:"count"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// ipAccessRecorder 记录每个 API Key 最近命中/拒绝的 IP（仅内存，重启后清空）
+type ipAccessRecorder struct {
+	mu      sync.Mutex
+	records map[uint]map[string]*IPAccessRecord // keyID -> ip -> record
+}
+
+var defaultIPAccessRecorder = &ipAccessRecorder{
+	records: make(map[uint]map[string]*IPAccessRecord),
+}
+
+// RecordIPAccess 记录 API Key 的一次 IP 访问
+func (s *APIKeyService) RecordIPAccess(keyID uint, ip string, allowed bool) {
+	r := defaultIPAccessRecorder
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	byIP, ok := r.records[keyID]
+	if !ok {
+		byIP = make(map[string]*IPAccessRecord)
+		r.records[keyID] = byIP
+	}
+
+	if rec, ok := byIP[ip]; ok {
+		rec.Allowed = allowed
+		rec.Count++
+		rec.LastSeen = time.Now()
+		return
+	}
+
+	// 超过上限时淘汰最久未访问的 IP
+	if len(byIP) >= maxIPAccessRecords {
+		var oldestIP string
+		var oldest time.Time
+		for k, rec := range byIP {
+			if oldestIP == "" || rec.LastSeen.Before(oldest) {
+				oldestIP, oldest = k, rec.LastSeen
+			}
+		}
+		delete(byIP, oldestIP)
+	}
+	byIP[ip] = &IPAccessRecord{IP: ip, Allowed: allowed, Count: 1, LastSeen: time.Now()}
+}
+
+// GetIPAccessRecords 获取 API Key 最近的 IP 访问记录（按最后访问时间倒序）
+func (s *APIKeyService) GetIPAccessRecords(keyID uint) []IPAccessRecord {
+	r := defaultIPAccessRecorder
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make([]IPAccessRecord, 0, len(r.records[keyID]))
+	for _, rec := range r.records[keyID] {
+		result = append(result, *rec)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].LastSeen.After(result[j].LastSeen)
+	})
+	return result
+}
@@ -0,0 +1,271 @@
+/*
+ * 文件作用：计费 webhook 推送服务，把每笔消费实时推送到外部计费/钱包系统
+ * 负责功能：
+ *   - 记账完成后先持久化事件再异步推送，进程重启不丢失
+ *   - HMAC-SHA256 签名（时间戳 + 请求体），接收方校验防篡改
+ *   - 推送失败按指数退避重试直到成功，多实例通过条件更新抢占避免重复发送
+ *   - 接收方按 event_id 去重（极端情况下同一事件可能送达多次）
+ * 重要程度：⭐⭐⭐ 一般（计费系统对接）
+ * 依赖模块：config, model, repository, logger
+ */
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-aiproxy/internal/config"
+	"go-aiproxy/internal/model"
+	"go-aiproxy/internal/repository"
+	"go-aiproxy/pkg/logger"
+)
+
+// 计费 webhook 请求头
+const (
+	BillingEventIDHeader   = "X-Billing-Event-Id"
+	BillingTimestampHeader = "X-Billing-Timestamp"
+	BillingSignatureHeader = "X-Billing-Signature" // sha256=<hex(HMAC-SHA256(secret, timestamp + "." + body))>
+)
+
+// billingRetryBatchSize 每次扫描拉取的待重试事件数
+const billingRetryBatchSize = 100
+
+// BillingEvent 推送给计费系统的消费明细
+type BillingEvent struct {
+	EventID                  string    `json:"event_id"`
+	RequestID                string    `json:"request_id,omitempty"`
+	UserID                   uint      `json:"user_id"`
+	APIKeyID                 uint      `json:"api_key_id"`
+	Platform                 string    `json:"platform"`
+	Model                    string    `json:"model"`
+	InputTokens              int       `json:"input_tokens"`
+	OutputTokens             int       `json:"output_tokens"`
+	CacheCreationInputTokens int       `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int       `json:"cache_read_input_tokens"`
+	TotalTokens              int       `json:"total_tokens"`
+	TotalCost                float64   `json:"total_cost"`
+	Timestamp                time.Time `json:"timestamp"`
+}
+
+// NewBillingEvent 从请求日志构建计费事件（token 和费用均为倍率后的计费值）
+func NewBillingEvent(userID, apiKeyID uint, log *model.RequestLog) *BillingEvent {
+	timestamp := log.CreatedAt
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	return &BillingEvent{
+		EventID:                  newBillingEventID(),
+		RequestID:                log.RequestID,
+		UserID:                   userID,
+		APIKeyID:                 apiKeyID,
+		Platform:                 log.Platform,
+		Model:                    log.Model,
+		InputTokens:              log.InputTokens,
+		OutputTokens:             log.OutputTokens,
+		CacheCreationInputTokens: log.CacheCreationInputTokens,
+		CacheReadInputTokens:     log.CacheReadInputTokens,
+		TotalTokens:              log.TotalTokens,
+		TotalCost:                log.TotalCost,
+		Timestamp:                timestamp,
+	}
+}
+
+// newBillingEventID 生成事件唯一 ID
+func newBillingEventID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return "bill_" + hex.EncodeToString(b)
+}
+
+// BillingWebhook 计费 webhook 推送器
+type BillingWebhook struct {
+	cfg    config.BillingWebhookConfig
+	repo   *repository.BillingWebhookEventRepository
+	queue  chan *model.BillingWebhookEvent
+	client *http.Client
+	log    *logger.Logger
+}
+
+var (
+	billingWebhook     *BillingWebhook
+	billingWebhookOnce sync.Once
+)
+
+// GetBillingWebhook 获取计费 webhook 推送器单例，未启用时返回 nil
+// 首次获取时启动发送协程和重试扫描（服务启动时调用，重启后继续推送积压事件）
+func GetBillingWebhook() *BillingWebhook {
+	billingWebhookOnce.Do(func() {
+		if config.Cfg == nil || !config.Cfg.BillingWebhook.Enabled || config.Cfg.BillingWebhook.URL == "" {
+			return
+		}
+		cfg := config.Cfg.BillingWebhook
+		billingWebhook = &BillingWebhook{
+			cfg:    cfg,
+			repo:   repository.NewBillingWebhookEventRepository(),
+			queue:  make(chan *model.BillingWebhookEvent, 1000),
+			client: &http.Client{Timeout: time.Duration(cfg.GetTimeout()) * time.Second},
+			log:    logger.GetLogger("billing_webhook"),
+		}
+		for i := 0; i < cfg.GetWorkers(); i++ {
+			go billingWebhook.worker()
+		}
+		go billingWebhook.retryLoop()
+	})
+	return billingWebhook
+}
+
+// Publish 持久化计费事件并提交立即推送（不阻塞记账流程），推送器未启用时直接忽略
+// 持久化失败时仍尝试推送一次，推送也失败则在日志中保留完整内容以便人工补推
+func (w *BillingWebhook) Publish(event *BillingEvent) {
+	if w == nil {
+		return
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		w.log.Error("计费事件序列化失败 | EventID: %s | 原因: %v", event.EventID, err)
+		return
+	}
+
+	record := &model.BillingWebhookEvent{
+		EventID:     event.EventID,
+		Payload:     string(payload),
+		NextRetryAt: time.Now().Add(w.lease()),
+	}
+	if err := w.repo.Create(record); err != nil {
+		w.log.Error("计费事件持久化失败 | EventID: %s | 原因: %v", event.EventID, err)
+		record.ID = 0
+	}
+
+	select {
+	case w.queue <- record:
+	default:
+		// 队列满：已持久化的事件由重试扫描推送
+		if record.ID == 0 {
+			w.log.Error("计费事件丢失（持久化失败且发送队列已满）| 内容: %s", record.Payload)
+		}
+	}
+}
+
+// lease 发送中的租约时长，租约内重试扫描不会重复推送
+func (w *BillingWebhook) lease() time.Duration {
+	return w.cfg.GetRetryInterval() + 2*time.Duration(w.cfg.GetTimeout())*time.Second
+}
+
+// worker 从队列取事件并推送
+func (w *BillingWebhook) worker() {
+	for record := range w.queue {
+		w.deliver(record)
+	}
+}
+
+// deliver 推送单个事件，成功后删除，失败记录原因并按退避安排下次重试
+func (w *BillingWebhook) deliver(record *model.BillingWebhookEvent) {
+	err := w.post(record.EventID, []byte(record.Payload))
+	if err == nil {
+		if record.ID != 0 {
+			if err := w.repo.Delete(record.ID); err != nil {
+				w.log.Warn("删除已推送的计费事件失败 | EventID: %s | 原因: %v", record.EventID, err)
+			}
+		}
+		return
+	}
+
+	if record.ID == 0 {
+		w.log.Error("计费事件推送失败且未持久化 | 原因: %v | 内容: %s", err, record.Payload)
+		return
+	}
+
+	attempts := record.Attempts + 1
+	nextRetryAt := time.Now().Add(w.backoff(attempts))
+	if markErr := w.repo.MarkFailed(record.ID, attempts, nextRetryAt, truncateString(err.Error(), 500)); markErr != nil {
+		w.log.Error("记录计费事件推送失败状态出错 | EventID: %s | 原因: %v", record.EventID, markErr)
+	}
+	w.log.Warn("计费事件推送失败，等待重试 | EventID: %s | 次数: %d | 下次: %s | 原因: %v",
+		record.EventID, attempts, nextRetryAt.Format(time.RFC3339), err)
+}
+
+// backoff 第 attempts 次失败后的重试间隔：扫描间隔按 2 的幂递增，不超过上限
+func (w *BillingWebhook) backoff(attempts int) time.Duration {
+	delay := w.cfg.GetRetryInterval()
+	maxBackoff := w.cfg.GetMaxBackoff()
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		return maxBackoff
+	}
+	return delay
+}
+
+// retryLoop 定期扫描到期的待重试事件（含进程重启前未送达的事件）
+func (w *BillingWebhook) retryLoop() {
+	ticker := time.NewTicker(w.cfg.GetRetryInterval())
+	defer ticker.Stop()
+	for range ticker.C {
+		w.retryDue()
+	}
+}
+
+// retryDue 抢占到期事件并提交推送，抢占失败说明其他实例或发送协程正在处理
+func (w *BillingWebhook) retryDue() {
+	now := time.Now()
+	events, err := w.repo.ListDue(now, billingRetryBatchSize)
+	if err != nil {
+		w.log.Error("查询待重试计费事件失败: %v", err)
+		return
+	}
+	for i := range events {
+		event := &events[i]
+		claimed, err := w.repo.Claim(event.ID, event.NextRetryAt, now.Add(w.lease()))
+		if err != nil || !claimed {
+			continue
+		}
+		w.queue <- event
+	}
+}
+
+// post 执行一次推送，2xx 视为成功
+func (w *BillingWebhook) post(eventID string, body []byte) error {
+	req, err := http.NewRequest("POST", w.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(BillingEventIDHeader, eventID)
+	req.Header.Set(BillingTimestampHeader, timestamp)
+	if w.cfg.Secret != "" {
+		req.Header.Set(BillingSignatureHeader, "sha256="+SignBillingPayload(w.cfg.Secret, timestamp, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SignBillingPayload 计算签名：hex(HMAC-SHA256(secret, timestamp + "." + body))
+func SignBillingPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
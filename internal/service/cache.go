@@ -6,6 +6,7 @@
  *   - 账户/用户缓存管理
  *   - 并发计数管理
  *   - 不可用账户标记管理
+ *   - 响应缓存统计和清理
  * 重要程度：⭐⭐⭐⭐ 重要（缓存管理核心）
  * 依赖模块：cache, repository, model
  */
@@ -58,6 +59,9 @@ var (
 // SessionBinding 会话绑定信息（复用 cache 包的定义）
 type SessionBinding = cache.SessionBinding
 
+// SessionFilter 会话列表过滤条件（复用 cache 包的定义）
+type SessionFilter = cache.SessionFilter
+
 // GetSessionBinding 获取会话绑定
 func (s *CacheService) GetSessionBinding(ctx context.Context, sessionID string) (*SessionBinding, error) {
 	return s.sessionCache.GetSessionBinding(ctx, sessionID)
@@ -137,6 +141,11 @@ func (s *CacheService) GetAccountConcurrency(ctx context.Context, accountID uint
 	return s.sessionCache.GetAccountConcurrency(ctx, accountID)
 }
 
+// GetAccountModelConcurrency 获取账户各模型当前并发数
+func (s *CacheService) GetAccountModelConcurrency(accountID uint) map[string]int64 {
+	return s.sessionCache.GetAccountModelConcurrency(accountID)
+}
+
 // ResetAccountConcurrency 重置账户并发计数
 func (s *CacheService) ResetAccountConcurrency(ctx context.Context, accountID uint) error {
 	return s.sessionCache.ResetAccountConcurrency(ctx, accountID)
@@ -165,6 +174,11 @@ type CacheStats struct {
 	TotalKeyCount     int64  `json:"total_key_count"`    // 内存缓存项数
 	MemoryUsed        int64  `json:"memory_used"`        // 不再使用 Redis
 	MemoryUsedHuman   string `json:"memory_used_human"`  // 不再使用 Redis
+
+	// 响应缓存（命中/未命中为进程启动以来的累计值）
+	ResponseCacheCount  int64 `json:"response_cache_count"`
+	ResponseCacheHits   int64 `json:"response_cache_hits"`
+	ResponseCacheMisses int64 `json:"response_cache_misses"`
 }
 
 // GetCacheStats 获取缓存统计
@@ -180,11 +194,16 @@ func (s *CacheService) GetCacheStats(ctx context.Context) (*CacheStats, error) {
 		unavailableCount = int64(v)
 	}
 
+	responseStats := cache.GetResponseCache().Stats()
+
 	return &CacheStats{
-		SessionCount:     sessionCount,
-		UnavailableCount: unavailableCount,
-		TotalKeyCount:    sessionCount + unavailableCount,
-		MemoryUsedHuman:  "N/A (内存缓存)",
+		SessionCount:        sessionCount,
+		UnavailableCount:    unavailableCount,
+		TotalKeyCount:       sessionCount + unavailableCount + int64(responseStats.Entries),
+		MemoryUsedHuman:     "N/A (内存缓存)",
+		ResponseCacheCount:  int64(responseStats.Entries),
+		ResponseCacheHits:   responseStats.Hits,
+		ResponseCacheMisses: responseStats.Misses,
 	}, nil
 }
 
@@ -200,6 +219,7 @@ const (
 	ClearCacheUsage       ClearCacheType = "usage"       // 不再使用，数据在 MySQL
 	ClearCacheCost        ClearCacheType = "cost"        // 不再使用，数据在 MySQL
 	ClearCacheConcurrency ClearCacheType = "concurrency"
+	ClearCacheResponses   ClearCacheType = "responses" // 响应缓存
 )
 
 // ClearCacheResult 清理结果
@@ -216,7 +236,8 @@ func (s *CacheService) ClearCache(ctx context.Context, cacheType ClearCacheType)
 	switch cacheType {
 	case ClearCacheAll:
 		cleared := s.memoryCache.ClearAll()
-		result.DeletedCount = int64(cleared["sessions"] + cleared["unavailable"])
+		responses := cache.GetResponseCache().Clear()
+		result.DeletedCount = int64(cleared["sessions"] + cleared["unavailable"] + responses)
 		return result, nil
 
 	case ClearCacheSessions:
@@ -229,6 +250,11 @@ func (s *CacheService) ClearCache(ctx context.Context, cacheType ClearCacheType)
 		result.DeletedCount = int64(count)
 		return result, nil
 
+	case ClearCacheResponses:
+		count := cache.GetResponseCache().Clear()
+		result.DeletedCount = int64(count)
+		return result, nil
+
 	case ClearCacheUsage, ClearCacheCost:
 		// 使用量和费用数据已经在 MySQL 中，这里不需要清理
 		result.DeletedCount = 0
@@ -497,3 +523,13 @@ func (s *CacheService) ListUsersWithCache(ctx context.Context) ([]UserCacheInfo,
 func (s *CacheService) ListAllSessions(ctx context.Context, offset, limit int64) ([]SessionBinding, int64, error) {
 	return s.sessionCache.ListAllSessions(ctx, offset, limit)
 }
+
+// ListSessions 按账户/用户/API Key 过滤会话绑定
+func (s *CacheService) ListSessions(ctx context.Context, filter SessionFilter, offset, limit int64) ([]SessionBinding, int64, error) {
+	return s.sessionCache.ListSessions(ctx, filter, offset, limit)
+}
+
+// ClearSessionsByAccounts 批量解绑多个账户上的所有会话（账户下线/切换时使用），返回各账户解绑的会话数
+func (s *CacheService) ClearSessionsByAccounts(ctx context.Context, accountIDs []uint) map[uint]int64 {
+	return s.sessionCache.ClearSessionsByAccounts(ctx, accountIDs)
+}
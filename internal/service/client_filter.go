@@ -3,6 +3,7 @@
  * 负责功能：
  *   - 客户端类型定义管理
  *   - 过滤规则管理
+ *   - 访问规则匹配（UA 正则 + 路径前缀，按序命中）
  *   - 请求验证（Header/Body匹配）
  *   - 正则表达式缓存
  *   - 验证结果生成
@@ -36,6 +37,7 @@ type clientFilterCache struct {
 	config      *model.ClientFilterConfig
 	clientTypes map[string]*model.ClientType      // key: client_id
 	rules       map[uint][]model.ClientFilterRule // key: client_type_id
+	accessRules []model.ClientAccessRule          // 已启用的访问规则（按匹配顺序）
 	regexCache  map[string]*regexp.Regexp         // 正则表达式缓存
 	regexMu     sync.RWMutex                      // 正则缓存专用锁
 }
@@ -130,11 +132,24 @@ func (s *ClientFilterService) ReloadCache() error {
 		s.cache.rules[ct.ID] = rules
 	}
 
+	// 加载访问规则
+	accessRules, err := s.repo.ListAccessRules()
+	if err != nil {
+		s.logger.Error("加载访问规则失败: %v", err)
+		return err
+	}
+	s.cache.accessRules = make([]model.ClientAccessRule, 0, len(accessRules))
+	for _, rule := range accessRules {
+		if rule.Enabled {
+			s.cache.accessRules = append(s.cache.accessRules, rule)
+		}
+	}
+
 	// 清空正则缓存（会重新编译）
 	s.cache.regexCache = make(map[string]*regexp.Regexp)
 
-	s.logger.Info("客户端过滤缓存已重新加载 | 客户端类型: %d | 规则总数: %d",
-		len(s.cache.clientTypes), s.countTotalRules())
+	s.logger.Info("客户端过滤缓存已重新加载 | 客户端类型: %d | 规则总数: %d | 访问规则: %d",
+		len(s.cache.clientTypes), s.countTotalRules(), len(s.cache.accessRules))
 
 	return nil
 }
@@ -161,6 +176,26 @@ func (s *ClientFilterService) GetConfig() *model.ClientFilterConfig {
 	return s.cache.config
 }
 
+// MatchAccessRule 按序匹配访问规则，返回第一条命中的规则，没有命中返回 nil
+// 规则的 UA 正则和路径前缀都为空时匹配所有请求
+func (s *ClientFilterService) MatchAccessRule(userAgent, path string) *model.ClientAccessRule {
+	s.cache.RLock()
+	rules := s.cache.accessRules
+	s.cache.RUnlock()
+
+	for i := range rules {
+		rule := &rules[i]
+		if rule.PathPrefix != "" && !strings.HasPrefix(path, rule.PathPrefix) {
+			continue
+		}
+		if !s.matchPattern(rule.UserAgentPattern, userAgent) {
+			continue
+		}
+		return rule
+	}
+	return nil
+}
+
 // ValidateRequest 验证请求
 func (s *ClientFilterService) ValidateRequest(ctx *RequestContext) *ValidationResult {
 	result := &ValidationResult{
@@ -815,6 +850,59 @@ func (s *ClientFilterService) DeleteRule(id uint) error {
 	return s.ReloadCache()
 }
 
+// GetAllAccessRules 获取所有访问规则
+func (s *ClientFilterService) GetAllAccessRules() ([]model.ClientAccessRule, error) {
+	return s.repo.ListAccessRules()
+}
+
+// GetAccessRule 获取访问规则
+func (s *ClientFilterService) GetAccessRule(id uint) (*model.ClientAccessRule, error) {
+	return s.repo.GetAccessRuleByID(id)
+}
+
+// CreateAccessRule 创建访问规则
+func (s *ClientFilterService) CreateAccessRule(rule *model.ClientAccessRule) error {
+	if err := validateAccessRule(rule); err != nil {
+		return err
+	}
+	if err := s.repo.CreateAccessRule(rule); err != nil {
+		return err
+	}
+	return s.ReloadCache()
+}
+
+// UpdateAccessRule 更新访问规则
+func (s *ClientFilterService) UpdateAccessRule(rule *model.ClientAccessRule) error {
+	if err := validateAccessRule(rule); err != nil {
+		return err
+	}
+	if err := s.repo.UpdateAccessRule(rule); err != nil {
+		return err
+	}
+	return s.ReloadCache()
+}
+
+// DeleteAccessRule 删除访问规则
+func (s *ClientFilterService) DeleteAccessRule(id uint) error {
+	if err := s.repo.DeleteAccessRule(id); err != nil {
+		return err
+	}
+	return s.ReloadCache()
+}
+
+// validateAccessRule 校验访问规则的动作和 UA 正则
+func validateAccessRule(rule *model.ClientAccessRule) error {
+	if rule.Action != model.AccessRuleActionAllow && rule.Action != model.AccessRuleActionDeny {
+		return fmt.Errorf("action 必须是 %s 或 %s", model.AccessRuleActionAllow, model.AccessRuleActionDeny)
+	}
+	if rule.UserAgentPattern != "" {
+		if _, err := regexp.Compile(rule.UserAgentPattern); err != nil {
+			return fmt.Errorf("无效的 User-Agent 正则: %v", err)
+		}
+	}
+	return nil
+}
+
 // SaveConfig 保存配置
 func (s *ClientFilterService) SaveConfig(config *model.ClientFilterConfig) error {
 	if err := s.repo.SaveConfig(config); err != nil {
@@ -7,14 +7,16 @@
  *   - 预定义配置项获取方法
  *   - 配置变更通知
  * 重要程度：⭐⭐⭐⭐ 重要（配置管理核心）
- * 依赖模块：repository, model
+ * 依赖模块：repository, model, scheduler
  */
 package service
 
 import (
 	"go-aiproxy/internal/model"
+	"go-aiproxy/internal/proxy/scheduler"
 	"go-aiproxy/internal/repository"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -151,6 +153,423 @@ func (s *ConfigService) GetSessionTTL() time.Duration {
 	return s.GetDuration(model.ConfigSessionTTL)
 }
 
+// GetStreamKeepAliveInterval 获取流式响应心跳间隔（未配置时默认 15 秒，0 表示关闭）
+func (s *ConfigService) GetStreamKeepAliveInterval() time.Duration {
+	if s.GetString(model.ConfigStreamKeepAliveInterval) == "" {
+		return 15 * time.Second
+	}
+	val := s.GetInt(model.ConfigStreamKeepAliveInterval)
+	if val <= 0 {
+		return 0
+	}
+	return time.Duration(val) * time.Second
+}
+
+// GetMaxRequestBodySize 获取默认请求体上限（字节，未配置时默认 10MB，0 表示不限制）
+func (s *ConfigService) GetMaxRequestBodySize() int64 {
+	return s.getBodySizeLimit(model.ConfigMaxRequestBodySize, 10)
+}
+
+// GetMaxRequestBodySizeClaude 获取 Claude 端点请求体上限（字节，未配置时默认 32MB，0 表示不限制）
+func (s *ConfigService) GetMaxRequestBodySizeClaude() int64 {
+	return s.getBodySizeLimit(model.ConfigMaxRequestBodySizeClaude, 32)
+}
+
+// GetMaxRequestTimeout 获取 X-Request-Timeout 允许的最大值（未配置时默认 600 秒，0 表示忽略该头）
+func (s *ConfigService) GetMaxRequestTimeout() time.Duration {
+	if s.GetString(model.ConfigMaxRequestTimeout) == "" {
+		return 600 * time.Second
+	}
+	val := s.GetInt(model.ConfigMaxRequestTimeout)
+	if val <= 0 {
+		return 0
+	}
+	return time.Duration(val) * time.Second
+}
+
+// GetIdempotencyWindow 获取 Idempotency-Key 去重窗口（未配置时默认 300 秒，0 表示忽略该头）
+func (s *ConfigService) GetIdempotencyWindow() time.Duration {
+	if s.GetString(model.ConfigIdempotencyWindow) == "" {
+		return 300 * time.Second
+	}
+	val := s.GetInt(model.ConfigIdempotencyWindow)
+	if val <= 0 {
+		return 0
+	}
+	return time.Duration(val) * time.Second
+}
+
+// GetPassthroughUpstreamError 是否透传上游错误体（默认关闭）
+func (s *ConfigService) GetPassthroughUpstreamError() bool {
+	return s.GetBool(model.ConfigPassthroughUpstreamError)
+}
+
+// MaxOutputTokensActionReject 输出上限超限时拒绝请求（默认改写为上限值）
+const MaxOutputTokensActionReject = "reject"
+
+// GetMaxOutputTokensLimit 获取全局单请求输出 token 上限（0 表示不限制）
+func (s *ConfigService) GetMaxOutputTokensLimit() int {
+	if val := s.GetInt(model.ConfigMaxOutputTokensLimit); val > 0 {
+		return val
+	}
+	return 0
+}
+
+// GetMaxOutputTokensReject 输出上限超限时是否拒绝请求（否则改写为上限值）
+func (s *ConfigService) GetMaxOutputTokensReject() bool {
+	return s.GetString(model.ConfigMaxOutputTokensAction) == MaxOutputTokensActionReject
+}
+
+// ImageInlineOnFailureSkip 图片下载失败时保留原图片 URL 继续转发（默认请求失败）
+const ImageInlineOnFailureSkip = "skip"
+
+// GetImageInlineMaxSize 获取图片 URL 内联的单张图片大小上限（字节，默认 5MB）
+func (s *ConfigService) GetImageInlineMaxSize() int64 {
+	if val := s.GetInt(model.ConfigImageInlineMaxSize); val > 0 {
+		return int64(val) << 20
+	}
+	return 5 << 20
+}
+
+// GetImageInlineTimeout 获取图片 URL 内联的单张图片下载超时（默认 10 秒）
+func (s *ConfigService) GetImageInlineTimeout() time.Duration {
+	if val := s.GetInt(model.ConfigImageInlineTimeout); val > 0 {
+		return time.Duration(val) * time.Second
+	}
+	return 10 * time.Second
+}
+
+// GetImageInlineMaxCount 获取图片 URL 内联时单个请求最多下载的图片数（默认 20）
+func (s *ConfigService) GetImageInlineMaxCount() int {
+	if val := s.GetInt(model.ConfigImageInlineMaxCount); val > 0 {
+		return val
+	}
+	return 20
+}
+
+// GetImageInlineSkipOnFailure 图片下载失败时是否跳过（否则请求失败）
+func (s *ConfigService) GetImageInlineSkipOnFailure() bool {
+	return s.GetString(model.ConfigImageInlineOnFailure) == ImageInlineOnFailureSkip
+}
+
+// system prompt 注入的作用范围
+const (
+	SystemPromptScopeAll     = "all"     // 账户和 API Key 配置都生效
+	SystemPromptScopeAccount = "account" // 只用账户配置
+	SystemPromptScopeAPIKey  = "api_key" // 只用 API Key 配置
+)
+
+// defaultDegradeResponseText 未配置降级文本时的默认值
+const defaultDegradeResponseText = "服务繁忙，请稍后再试。"
+
+// GetDegradeResponseText 获取降级响应的默认文本
+func (s *ConfigService) GetDegradeResponseText() string {
+	if val := strings.TrimSpace(s.GetString(model.ConfigDegradeResponseText)); val != "" {
+		return val
+	}
+	return defaultDegradeResponseText
+}
+
+// GetSystemPromptInjectEnabled 是否注入账户 / API Key 配置的 system prompt 前缀、后缀
+func (s *ConfigService) GetSystemPromptInjectEnabled() bool {
+	return s.GetBool(model.ConfigSystemPromptInjectEnabled)
+}
+
+// GetSystemPromptInjectScope 获取 system prompt 注入的作用范围（无法识别时为 all）
+func (s *ConfigService) GetSystemPromptInjectScope() string {
+	switch scope := s.GetString(model.ConfigSystemPromptInjectScope); scope {
+	case SystemPromptScopeAccount, SystemPromptScopeAPIKey:
+		return scope
+	}
+	return SystemPromptScopeAll
+}
+
+// OpenAI Responses 非 Codex CLI 请求的适配取值
+const (
+	ResponsesStripFieldsNone          = "none"     // 不删除任何字段
+	ResponsesInstructionsOverride     = "override" // 替换为 Codex 默认 instructions（默认）
+	ResponsesInstructionsFill         = "fill"     // 仅客户端未提供 instructions 时填入默认值
+	ResponsesInstructionsKeep         = "keep"     // 保持客户端原样
+	defaultResponsesCompatStripFields = "temperature,top_p,max_output_tokens,user,text_formatting,truncation,text,service_tier"
+)
+
+// GetResponsesCompatStripFields 获取非 Codex CLI 请求删除的字段（原始配置值，未配置时使用默认列表）
+func (s *ConfigService) GetResponsesCompatStripFields() string {
+	if val := s.GetString(model.ConfigResponsesCompatStripFields); val != "" {
+		return val
+	}
+	return defaultResponsesCompatStripFields
+}
+
+// GetResponsesCompatInstructions 获取非 Codex CLI 请求的 instructions 处理方式（默认 override）
+func (s *ConfigService) GetResponsesCompatInstructions() string {
+	if val := s.GetString(model.ConfigResponsesCompatInstructions); IsValidResponsesInstructionsMode(val) {
+		return val
+	}
+	return ResponsesInstructionsOverride
+}
+
+// IsValidResponsesInstructionsMode instructions 处理方式是否合法
+func IsValidResponsesInstructionsMode(mode string) bool {
+	switch mode {
+	case ResponsesInstructionsOverride, ResponsesInstructionsFill, ResponsesInstructionsKeep:
+		return true
+	}
+	return false
+}
+
+func (s *ConfigService) getBodySizeLimit(key string, defaultMB int64) int64 {
+	if s.GetString(key) == "" {
+		return defaultMB << 20
+	}
+	val := s.GetInt(key)
+	if val <= 0 {
+		return 0
+	}
+	return int64(val) << 20
+}
+
+// GetBudgetWarningPercent 获取套餐预算告警百分比（未配置时默认 80，0 表示关闭）
+func (s *ConfigService) GetBudgetWarningPercent() float64 {
+	if s.GetString(model.ConfigBudgetWarningPercent) == "" {
+		return 80
+	}
+	return s.GetFloat(model.ConfigBudgetWarningPercent)
+}
+
+// GetBudgetReserveAmount 获取每个进行中请求的预扣金额（美元，未配置时默认 0.05）
+func (s *ConfigService) GetBudgetReserveAmount() float64 {
+	if s.GetString(model.ConfigBudgetReserveAmount) == "" {
+		return 0.05
+	}
+	return s.GetFloat(model.ConfigBudgetReserveAmount)
+}
+
+// GetRetryConfig 获取请求重试配置（未配置的项使用 scheduler.DefaultRetryConfig）
+func (s *ConfigService) GetRetryConfig() scheduler.RetryConfig {
+	cfg := scheduler.DefaultRetryConfig
+
+	if s.GetString(model.ConfigRetryMaxRetries) != "" {
+		if maxRetries := s.GetInt(model.ConfigRetryMaxRetries); maxRetries >= 0 {
+			cfg.MaxRetries = maxRetries
+		}
+	}
+	if delay := s.GetInt(model.ConfigRetryDelay); delay > 0 {
+		cfg.RetryDelay = time.Duration(delay) * time.Millisecond
+	}
+	if backoff := s.GetFloat(model.ConfigRetryBackoff); backoff >= 1 {
+		cfg.RetryBackoff = backoff
+	}
+	if raw := s.GetString(model.ConfigRetryRetryableErrors); raw != "" {
+		var keywords []string
+		for _, keyword := range strings.Split(raw, ",") {
+			if keyword = strings.TrimSpace(keyword); keyword != "" {
+				keywords = append(keywords, keyword)
+			}
+		}
+		cfg.RetryableErrors = keywords
+	}
+	if s.GetString(model.ConfigRetrySwitchOnRateLimit) != "" {
+		cfg.SwitchOnRateLimit = s.GetBool(model.ConfigRetrySwitchOnRateLimit)
+	}
+	if wait := s.GetInt(model.ConfigRetryQueueMaxWait); wait > 0 {
+		cfg.QueueMaxWait = time.Duration(wait) * time.Second
+	}
+	if retries := s.GetInt(model.ConfigRetrySameAccount); retries > 0 {
+		cfg.SameAccountRetries = retries
+	}
+	return cfg
+}
+
+// GetWarmupConfig 获取恢复账户冷却期配置（未配置的项使用 scheduler.DefaultWarmupConfig）
+func (s *ConfigService) GetWarmupConfig() scheduler.WarmupConfig {
+	cfg := scheduler.DefaultWarmupConfig
+
+	if s.GetString(model.ConfigAccountWarmupDuration) != "" {
+		if minutes := s.GetInt(model.ConfigAccountWarmupDuration); minutes >= 0 {
+			cfg.Duration = time.Duration(minutes) * time.Minute
+		}
+	}
+	if percent := s.GetInt(model.ConfigAccountWarmupInitialPercent); percent > 0 && percent <= 100 {
+		cfg.InitialRatio = float64(percent) / 100
+	}
+	if s.GetString(model.ConfigAccountWarmupSuccessRelease) != "" {
+		if successes := s.GetInt(model.ConfigAccountWarmupSuccessRelease); successes >= 0 {
+			cfg.SuccessRelease = successes
+		}
+	}
+	return cfg
+}
+
+// IsWarmupConfigKey 判断配置项是否属于恢复账户冷却期配置
+func IsWarmupConfigKey(key string) bool {
+	switch key {
+	case model.ConfigAccountWarmupDuration, model.ConfigAccountWarmupInitialPercent, model.ConfigAccountWarmupSuccessRelease:
+		return true
+	}
+	return false
+}
+
+// GetSuccessRateConfig 获取账户成功率熔断配置（未配置的项使用 scheduler.DefaultSuccessRateConfig）
+func (s *ConfigService) GetSuccessRateConfig() scheduler.SuccessRateConfig {
+	cfg := scheduler.DefaultSuccessRateConfig
+
+	if s.GetString(model.ConfigAccountSuccessRateWindow) != "" {
+		if size := s.GetInt(model.ConfigAccountSuccessRateWindow); size >= 0 {
+			cfg.WindowSize = size
+		}
+	}
+	if percent := s.GetInt(model.ConfigAccountSuccessRateThreshold); percent > 0 && percent <= 100 {
+		cfg.Threshold = float64(percent) / 100
+	}
+	if seconds := s.GetInt(model.ConfigAccountSuccessRateCooldown); seconds > 0 {
+		cfg.Cooldown = time.Duration(seconds) * time.Second
+	}
+	return cfg
+}
+
+// IsSuccessRateConfigKey 判断配置项是否属于账户成功率熔断配置
+func IsSuccessRateConfigKey(key string) bool {
+	switch key {
+	case model.ConfigAccountSuccessRateWindow, model.ConfigAccountSuccessRateThreshold, model.ConfigAccountSuccessRateCooldown:
+		return true
+	}
+	return false
+}
+
+// IsRetryConfigKey 判断配置项是否属于请求重试配置
+func IsRetryConfigKey(key string) bool {
+	switch key {
+	case model.ConfigRetryMaxRetries, model.ConfigRetryDelay, model.ConfigRetryBackoff,
+		model.ConfigRetryRetryableErrors, model.ConfigRetrySwitchOnRateLimit, model.ConfigRetryQueueMaxWait,
+		model.ConfigRetrySameAccount:
+		return true
+	}
+	return false
+}
+
+// GetModelFallbackChains 获取全局模型回退链（模型 -> 下一个回退模型），配置有误时视为未配置
+func (s *ConfigService) GetModelFallbackChains() map[string]string {
+	chains, err := model.ParseModelFallbackChains(s.GetString(model.ConfigModelFallbackChains))
+	if err != nil {
+		return nil
+	}
+	return chains
+}
+
+// GetCrossPlatformFallbackModel 获取跨平台兜底使用的 OpenAI 模型（未配置时默认 gpt-4o）
+func (s *ConfigService) GetCrossPlatformFallbackModel() string {
+	if m := strings.TrimSpace(s.GetString(model.ConfigCrossPlatformFallbackModel)); m != "" {
+		return m
+	}
+	return "gpt-4o"
+}
+
+// CanaryBucketRandom 灰度按请求随机分流（默认按会话哈希）
+const CanaryBucketRandom = "random"
+
+// CanaryRule 灰度规则：Percent% 的请求只调度带 Tag 标签的账户
+type CanaryRule struct {
+	Tag       string
+	Percent   int
+	BySession bool // 按会话哈希分流，同一会话始终落在同一组
+}
+
+// GetCanaryRule 获取全局灰度规则，未启用或未配置标签时返回 nil
+func (s *ConfigService) GetCanaryRule() *CanaryRule {
+	tag := strings.TrimSpace(s.GetString(model.ConfigCanaryTag))
+	if !s.GetBool(model.ConfigCanaryEnabled) || tag == "" {
+		return nil
+	}
+	return &CanaryRule{
+		Tag:       tag,
+		Percent:   clampPercent(s.GetInt(model.ConfigCanaryPercent)),
+		BySession: s.GetString(model.ConfigCanaryBucket) != CanaryBucketRandom,
+	}
+}
+
+// GetShadowTrafficPercent 获取影子流量复制比例（0-100），未启用时返回 0
+func (s *ConfigService) GetShadowTrafficPercent() int {
+	if !s.GetBool(model.ConfigShadowTrafficEnabled) {
+		return 0
+	}
+	return clampPercent(s.GetInt(model.ConfigShadowTrafficPercent))
+}
+
+// GetShadowTrafficMaxInFlight 获取同时进行的影子请求上限（未配置时 20）
+func (s *ConfigService) GetShadowTrafficMaxInFlight() int {
+	if val := s.GetInt(model.ConfigShadowTrafficMaxInFlight); val > 0 {
+		return val
+	}
+	return 20
+}
+
+// clampPercent 限制百分比在 0-100
+func clampPercent(v int) int {
+	if v < 0 {
+		return 0
+	}
+	if v > 100 {
+		return 100
+	}
+	return v
+}
+
+// GetBatchPriceDiscount 获取批处理计费折扣系数（未配置时默认 0.5，即半价）
+func (s *ConfigService) GetBatchPriceDiscount() float64 {
+	if s.GetString(model.ConfigBatchPriceDiscount) == "" {
+		return 0.5
+	}
+	return s.GetFloat(model.ConfigBatchPriceDiscount)
+}
+
+// GetStreamTruncatedPriceRatio 获取疑似截断流式请求的计费系数（未配置或超出 0~1 时照常计费）
+func (s *ConfigService) GetStreamTruncatedPriceRatio() float64 {
+	if s.GetString(model.ConfigStreamTruncatedPriceRatio) == "" {
+		return 1
+	}
+	ratio := s.GetFloat(model.ConfigStreamTruncatedPriceRatio)
+	if ratio < 0 || ratio > 1 {
+		return 1
+	}
+	return ratio
+}
+
+// GetTokenEstimateEnabled 上游未返回 usage 时是否本地估算 token
+func (s *ConfigService) GetTokenEstimateEnabled() bool {
+	return s.GetBool(model.ConfigTokenEstimateEnabled)
+}
+
+// GetTokenEstimateBilling 是否按估算的 token 计费
+func (s *ConfigService) GetTokenEstimateBilling() bool {
+	return s.GetBool(model.ConfigTokenEstimateBilling)
+}
+
+// GetResponseCacheTTL 获取响应缓存默认缓存时间（未配置时 5 分钟）
+func (s *ConfigService) GetResponseCacheTTL() time.Duration {
+	if s.GetString(model.ConfigResponseCacheTTL) == "" {
+		return 5 * time.Minute
+	}
+	return time.Duration(s.GetInt(model.ConfigResponseCacheTTL)) * time.Second
+}
+
+// GetResponseCacheHitPriceRatio 获取响应缓存命中的计费系数（超出 0~1 时不计费）
+func (s *ConfigService) GetResponseCacheHitPriceRatio() float64 {
+	ratio := s.GetFloat(model.ConfigResponseCacheHitPriceRatio)
+	if ratio < 0 || ratio > 1 {
+		return 0
+	}
+	return ratio
+}
+
+// GetResponseCacheMaxEntries 获取响应缓存条目数上限（未配置时 10000）
+func (s *ConfigService) GetResponseCacheMaxEntries() int {
+	if s.GetString(model.ConfigResponseCacheMaxEntries) == "" {
+		return 10000
+	}
+	return s.GetInt(model.ConfigResponseCacheMaxEntries)
+}
+
 // GetSyncEnabled 获取是否启用同步
 func (s *ConfigService) GetSyncEnabled() bool {
 	return s.GetBool(model.ConfigSyncEnabled)
@@ -297,6 +716,27 @@ func (s *ConfigService) GetRateLimitedProbeBackoff() float64 {
 	return val
 }
 
+// GetRateLimitedDowngradeWindow 获取健康检查 429 观察窗口
+func (s *ConfigService) GetRateLimitedDowngradeWindow() time.Duration {
+	duration := s.GetDuration(model.ConfigRateLimitedDowngradeWindow)
+	if duration < time.Minute {
+		return 30 * time.Minute // 默认 30 分钟
+	}
+	return duration
+}
+
+// GetRateLimitedDowngradeRatio 获取触发限流标记的 429 比例，0 表示关闭
+func (s *ConfigService) GetRateLimitedDowngradeRatio() float64 {
+	if s.GetString(model.ConfigRateLimitedDowngradeRatio) == "" {
+		return 0.6 // 默认 60%
+	}
+	val := s.GetFloat(model.ConfigRateLimitedDowngradeRatio)
+	if val < 0 || val > 1 {
+		return 0.6
+	}
+	return val
+}
+
 // ========== 疑似封号检测配置 ==========
 
 // GetSuspendedProbeInterval 获取疑似封号账号探测间隔
@@ -344,6 +784,39 @@ func (s *ConfigService) GetTokenRefreshCooldown() time.Duration {
 	return duration
 }
 
+// GetTokenPreRefreshThreshold 获取 Token 提前刷新阈值（未配置时默认 10 分钟，0 表示关闭）
+func (s *ConfigService) GetTokenPreRefreshThreshold() time.Duration {
+	if s.GetString(model.ConfigTokenPreRefreshThreshold) == "" {
+		return 10 * time.Minute
+	}
+	return s.GetDuration(model.ConfigTokenPreRefreshThreshold)
+}
+
+// GetCredentialExpiryWarnWindow 获取凭证即将过期的提醒窗口（默认 7 天）
+func (s *ConfigService) GetCredentialExpiryWarnWindow() time.Duration {
+	days := s.GetInt(model.ConfigCredentialExpiryWarnDays)
+	if days <= 0 {
+		days = 7
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// GetCredentialExpiryAlert 凭证即将过期或失效时是否推送告警（未配置时默认开启）
+func (s *ConfigService) GetCredentialExpiryAlert() bool {
+	if s.GetString(model.ConfigCredentialExpiryAlert) == "" {
+		return true
+	}
+	return s.GetBool(model.ConfigCredentialExpiryAlert)
+}
+
+// GetSessionKeyCheckInterval 获取 SessionKey 轻量校验间隔，0 表示关闭
+func (s *ConfigService) GetSessionKeyCheckInterval() time.Duration {
+	if s.GetString(model.ConfigSessionKeyCheckInterval) == "" {
+		return 24 * time.Hour
+	}
+	return time.Duration(s.GetInt(model.ConfigSessionKeyCheckInterval)) * time.Hour
+}
+
 // GetTokenRefreshMaxRetries 获取 Token 刷新最大重试次数
 func (s *ConfigService) GetTokenRefreshMaxRetries() int {
 	val := s.GetInt(model.ConfigTokenRefreshMaxRetries)
@@ -352,3 +825,79 @@ func (s *ConfigService) GetTokenRefreshMaxRetries() int {
 	}
 	return val
 }
+
+// ========== 深度探测配置 ==========
+
+// GetDeepProbeEnabled 获取是否启用真实推理探测
+func (s *ConfigService) GetDeepProbeEnabled() bool {
+	return s.GetBool(model.ConfigDeepProbeEnabled)
+}
+
+// GetDeepProbeInterval 获取同一账号深度探测的最小间隔
+func (s *ConfigService) GetDeepProbeInterval() time.Duration {
+	duration := s.GetDuration(model.ConfigDeepProbeInterval)
+	if duration < time.Minute {
+		return time.Hour // 默认 1 小时
+	}
+	return duration
+}
+
+// ========== 健康检查并发与超时配置 ==========
+
+// GetHealthCheckConcurrency 获取健康检查并发数
+// 配置为 0 时按账号数自适应：max(minimum, accountCount/每并发账号数)，不超过上限
+func (s *ConfigService) GetHealthCheckConcurrency(accountCount, minimum int) int {
+	if n := s.GetInt(model.ConfigHealthCheckConcurrency); n > 0 {
+		return n
+	}
+
+	perWorker := s.GetInt(model.ConfigHealthCheckAccountsPerWorker)
+	if perWorker <= 0 {
+		perWorker = 20 // 默认每 20 个账号一个并发
+	}
+	maxConcurrency := s.GetInt(model.ConfigHealthCheckMaxConcurrency)
+	if maxConcurrency <= 0 {
+		maxConcurrency = 50 // 默认上限 50
+	}
+
+	n := accountCount / perWorker
+	if n < minimum {
+		n = minimum
+	}
+	if n > maxConcurrency {
+		n = maxConcurrency
+	}
+	return n
+}
+
+// GetHealthCheckProxyConcurrency 获取同一代理的检测并发上限，0 表示不限制
+func (s *ConfigService) GetHealthCheckProxyConcurrency() int {
+	n := s.GetInt(model.ConfigHealthCheckProxyConcurrency)
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// GetStartupProbeEnabled 服务启动后是否做一轮账号就绪探测
+func (s *ConfigService) GetStartupProbeEnabled() bool {
+	return s.GetBool(model.ConfigStartupProbeEnabled)
+}
+
+// GetStartupProbeTimeout 获取启动探测单个账号超时
+func (s *ConfigService) GetStartupProbeTimeout() time.Duration {
+	seconds := s.GetInt(model.ConfigStartupProbeTimeout)
+	if seconds <= 0 {
+		return 5 * time.Second // 默认 5 秒
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// GetHealthCheckTimeout 获取单个账号检测超时
+func (s *ConfigService) GetHealthCheckTimeout() time.Duration {
+	seconds := s.GetInt(model.ConfigHealthCheckTimeout)
+	if seconds <= 0 {
+		return 30 * time.Second // 默认 30 秒
+	}
+	return time.Duration(seconds) * time.Second
+}
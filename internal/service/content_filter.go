@@ -0,0 +1,373 @@
+/*
+ * 文件作用：请求内容审查服务，转发前检查请求文本中的敏感词和 PII
+ * 负责功能：
+ *   - 可插拔的内容过滤器接口（内置正则词表 / PII / 外部审查 API）
+ *   - Claude / OpenAI / Gemini 请求体的文本提取
+ *   - 命中时拒绝或脱敏，并记录审计日志
+ *   - 正则按配置预编译，配置变化时自动重建
+ * 重要程度：⭐⭐⭐ 一般（合规审查）
+ * 依赖模块：model, logger
+ */
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"go-aiproxy/internal/model"
+	"go-aiproxy/pkg/logger"
+)
+
+// 请求体格式
+const (
+	ContentFormatClaude = "claude"
+	ContentFormatOpenAI = "openai"
+	ContentFormatGemini = "gemini"
+)
+
+// 命中后的处理方式
+const (
+	ContentFilterActionReject = "reject"
+	ContentFilterActionRedact = "redact"
+)
+
+// contentRedactMask 脱敏替换文本
+const contentRedactMask = "***"
+
+// ContentFilter 可插拔的内容过滤器
+type ContentFilter interface {
+	// Name 过滤器名称（用于审计日志）
+	Name() string
+	// Check 检查请求文本，命中时返回 true 和命中的规则描述
+	Check(ctx context.Context, texts []string) (hit bool, rule string, err error)
+}
+
+// ContentRedactor 支持脱敏的过滤器，redact 模式下用于替换命中内容
+type ContentRedactor interface {
+	Redact(text string) string
+}
+
+// ContentFilterRequest 待审查的请求
+type ContentFilterRequest struct {
+	Format   string // 请求体格式: claude / openai / gemini
+	Body     []byte
+	UserID   uint
+	APIKeyID uint
+	ClientIP string
+	Path     string
+}
+
+// ContentFilterResult 审查结果，未命中时为 nil
+type ContentFilterResult struct {
+	Blocked bool   // 是否拒绝请求
+	Body    []byte // 脱敏后的请求体（未拒绝时使用）
+	Filter  string // 命中的过滤器
+	Rule    string // 命中的规则
+	Message string // 拒绝时返回给客户端的消息
+}
+
+// 各格式中包含用户文本的顶层字段
+var contentFilterRoots = map[string][]string{
+	ContentFormatClaude: {"system", "messages"},
+	ContentFormatOpenAI: {"messages", "input", "prompt"},
+	ContentFormatGemini: {"contents", "systemInstruction", "system_instruction"},
+}
+
+// contentTextKeys 嵌套结构中承载文本的字段
+var contentTextKeys = map[string]bool{"text": true, "content": true}
+
+// 内置 PII 规则（身份证号放在银行卡号之前，避免 18 位身份证号被识别为银行卡号）
+var piiRules = []contentRule{
+	{name: "pii:email", re: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)},
+	{name: "pii:id_card", re: regexp.MustCompile(`\b\d{17}[\dXx]\b`)},
+	{name: "pii:phone", re: regexp.MustCompile(`\b1[3-9]\d{9}\b`)},
+	{name: "pii:bank_card", re: regexp.MustCompile(`\b\d{16,19}\b`)},
+}
+
+// ContentFilterService 内容审查服务
+type ContentFilterService struct {
+	configService *ConfigService
+	log           *logger.Logger
+	client        *http.Client
+
+	mu          sync.Mutex
+	compiledKey string              // 当前正则过滤器对应的配置（变化时重新编译）
+	regexFilter *regexContentFilter // 内置正则过滤器（词表 + PII）
+	extra       []ContentFilter     // 通过 RegisterContentFilter 注册的过滤器
+}
+
+var (
+	contentFilterService     *ContentFilterService
+	contentFilterServiceOnce sync.Once
+)
+
+// GetContentFilterService 获取内容审查服务单例
+func GetContentFilterService() *ContentFilterService {
+	contentFilterServiceOnce.Do(func() {
+		contentFilterService = &ContentFilterService{
+			configService: GetConfigService(),
+			log:           logger.GetLogger("content_filter"),
+			client:        &http.Client{},
+		}
+	})
+	return contentFilterService
+}
+
+// RegisterContentFilter 注册自定义过滤器，在内置过滤器之后执行
+func (s *ContentFilterService) RegisterContentFilter(filter ContentFilter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.extra = append(s.extra, filter)
+}
+
+// IsEnabled 内容审查是否开启
+func (s *ContentFilterService) IsEnabled() bool {
+	return s.configService.GetBool(model.ConfigContentFilterEnabled)
+}
+
+// Inspect 审查请求内容，未开启或未命中时返回 nil
+// 脱敏模式下命中可脱敏的过滤器时返回脱敏后的请求体，其余命中一律拒绝
+func (s *ContentFilterService) Inspect(ctx context.Context, req *ContentFilterRequest) *ContentFilterResult {
+	if !s.IsEnabled() {
+		return nil
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(req.Body, &body); err != nil {
+		return nil
+	}
+	texts := ExtractRequestTexts(req.Format, body)
+	if len(texts) == 0 {
+		return nil
+	}
+
+	redact := s.configService.GetString(model.ConfigContentFilterAction) == ContentFilterActionRedact
+	var redacted *ContentFilterResult
+	for _, filter := range s.filters() {
+		hit, rule, err := filter.Check(ctx, texts)
+		if err != nil {
+			// 审查出错时放行，避免外部服务故障影响正常请求
+			s.log.Warn("内容审查出错，放行 | Filter: %s | APIKeyID: %d | Error: %v", filter.Name(), req.APIKeyID, err)
+			continue
+		}
+		if !hit {
+			continue
+		}
+
+		result := &ContentFilterResult{Filter: filter.Name(), Rule: rule}
+		if redactor, ok := filter.(ContentRedactor); ok && redact {
+			if data, err := redactRequestBody(req.Format, body, redactor); err == nil {
+				result.Body = data
+				redacted = result
+				s.log.Warn("内容审查命中，已脱敏 | Filter: %s | Rule: %s | UserID: %d | APIKeyID: %d | IP: %s | Path: %s",
+					result.Filter, result.Rule, req.UserID, req.APIKeyID, req.ClientIP, req.Path)
+				// 后续过滤器审查脱敏后的文本
+				texts = ExtractRequestTexts(req.Format, body)
+				continue
+			}
+		}
+
+		result.Blocked = true
+		result.Message = s.configService.GetString(model.ConfigContentFilterMessage)
+		if result.Message == "" {
+			result.Message = "request content rejected by content filter"
+		}
+		s.log.Warn("内容审查命中，已拒绝 | Filter: %s | Rule: %s | UserID: %d | APIKeyID: %d | IP: %s | Path: %s",
+			result.Filter, result.Rule, req.UserID, req.APIKeyID, req.ClientIP, req.Path)
+		return result
+	}
+	return redacted
+}
+
+// filters 按当前配置返回启用的过滤器
+func (s *ContentFilterService) filters() []ContentFilter {
+	patterns := s.configService.GetString(model.ConfigContentFilterPatterns)
+	pii := s.configService.GetBool(model.ConfigContentFilterPII)
+	apiURL := strings.TrimSpace(s.configService.GetString(model.ConfigContentFilterAPIURL))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	compiledKey := fmt.Sprintf("%v|%s", pii, patterns)
+	if compiledKey != s.compiledKey {
+		s.regexFilter = newRegexContentFilter(patterns, pii, s.log)
+		s.compiledKey = compiledKey
+	}
+
+	filters := make([]ContentFilter, 0, 2+len(s.extra))
+	if s.regexFilter != nil {
+		filters = append(filters, s.regexFilter)
+	}
+	if apiURL != "" {
+		timeout := time.Duration(s.configService.GetInt(model.ConfigContentFilterAPITimeout)) * time.Millisecond
+		if timeout <= 0 {
+			timeout = 3 * time.Second
+		}
+		filters = append(filters, &apiContentFilter{url: apiURL, timeout: timeout, client: s.client})
+	}
+	return append(filters, s.extra...)
+}
+
+// ========== 内置过滤器 ==========
+
+type contentRule struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// regexContentFilter 正则词表 + PII 过滤器
+type regexContentFilter struct {
+	rules []contentRule
+}
+
+// newRegexContentFilter 预编译词表，无效的正则跳过并记录日志；没有任何规则时返回 nil
+func newRegexContentFilter(patterns string, pii bool, log *logger.Logger) *regexContentFilter {
+	var rules []contentRule
+	for _, line := range strings.Split(patterns, "\n") {
+		pattern := strings.TrimSpace(line)
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			log.Error("敏感词正则无效，已跳过 | Pattern: %s | Error: %v", pattern, err)
+			continue
+		}
+		rules = append(rules, contentRule{name: "pattern:" + pattern, re: re})
+	}
+	if pii {
+		rules = append(rules, piiRules...)
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+	return &regexContentFilter{rules: rules}
+}
+
+func (f *regexContentFilter) Name() string {
+	return "regex"
+}
+
+func (f *regexContentFilter) Check(ctx context.Context, texts []string) (bool, string, error) {
+	for _, text := range texts {
+		for _, rule := range f.rules {
+			if rule.re.MatchString(text) {
+				return true, rule.name, nil
+			}
+		}
+	}
+	return false, "", nil
+}
+
+func (f *regexContentFilter) Redact(text string) string {
+	for _, rule := range f.rules {
+		text = rule.re.ReplaceAllString(text, contentRedactMask)
+	}
+	return text
+}
+
+// apiContentFilter 外部审查 API 过滤器
+// 请求: POST {"text": "..."}，响应: {"flagged": bool, "reason": "..."}
+type apiContentFilter struct {
+	url     string
+	timeout time.Duration
+	client  *http.Client
+}
+
+func (f *apiContentFilter) Name() string {
+	return "api"
+}
+
+func (f *apiContentFilter) Check(ctx context.Context, texts []string) (bool, string, error) {
+	payload, err := json.Marshal(map[string]string{"text": strings.Join(texts, "\n")})
+	if err != nil {
+		return false, "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", f.url, bytes.NewReader(payload))
+	if err != nil {
+		return false, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return false, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, "", fmt.Errorf("moderation api returned HTTP %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Flagged bool   `json:"flagged"`
+		Reason  string `json:"reason"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, "", err
+	}
+	return result.Flagged, result.Reason, nil
+}
+
+// ========== 文本提取 ==========
+
+// ExtractRequestTexts 从请求体中提取用户文本（system / 消息内容 / 工具结果等）
+func ExtractRequestTexts(format string, body map[string]interface{}) []string {
+	var texts []string
+	for _, key := range contentFilterRoots[format] {
+		if value, ok := body[key]; ok {
+			walkContentText(value, true, func(text string) string {
+				if text != "" {
+					texts = append(texts, text)
+				}
+				return text
+			})
+		}
+	}
+	return texts
+}
+
+// redactRequestBody 对请求体中的文本做脱敏并重新序列化（不转义 HTML 字符）
+func redactRequestBody(format string, body map[string]interface{}, redactor ContentRedactor) ([]byte, error) {
+	for _, key := range contentFilterRoots[format] {
+		if value, ok := body[key]; ok {
+			body[key] = walkContentText(value, true, redactor.Redact)
+		}
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(body); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// walkContentText 遍历 JSON 值，对文本字段调用 fn 并用返回值替换
+// isText 表示当前值本身是否为文本（顶层字段或 text/content 字段）
+func walkContentText(value interface{}, isText bool, fn func(string) string) interface{} {
+	switch v := value.(type) {
+	case string:
+		if isText {
+			return fn(v)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = walkContentText(v[i], isText, fn)
+		}
+	case map[string]interface{}:
+		for key, child := range v {
+			v[key] = walkContentText(child, contentTextKeys[key], fn)
+		}
+	}
+	return value
+}
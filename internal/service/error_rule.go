@@ -5,12 +5,15 @@
  *   - 规则缓存刷新
  *   - 默认规则初始化
  *   - 规则启用/禁用
+ *   - 重试动作校验
  * 重要程度：⭐⭐⭐ 一般（错误处理增强）
  * 依赖模块：repository, errormatch, model
  */
 package service
 
 import (
+	"fmt"
+
 	"go-aiproxy/internal/errormatch"
 	"go-aiproxy/internal/model"
 	"go-aiproxy/internal/repository"
@@ -31,6 +34,8 @@ type CreateRuleRequest struct {
 	HTTPStatusCode int    `json:"http_status_code"`
 	Keyword        string `json:"keyword"`
 	TargetStatus   string `json:"target_status" binding:"required"`
+	RetryAction    string `json:"retry_action"`
+	RetryCount     int    `json:"retry_count"`
 	Priority       int    `json:"priority"`
 	Enabled        bool   `json:"enabled"`
 	Description    string `json:"description"`
@@ -41,6 +46,8 @@ type UpdateRuleRequest struct {
 	HTTPStatusCode *int    `json:"http_status_code"`
 	Keyword        *string `json:"keyword"`
 	TargetStatus   string  `json:"target_status"`
+	RetryAction    *string `json:"retry_action"`
+	RetryCount     *int    `json:"retry_count"`
 	Priority       *int    `json:"priority"`
 	Enabled        *bool   `json:"enabled"`
 	Description    string  `json:"description"`
@@ -48,10 +55,16 @@ type UpdateRuleRequest struct {
 
 // Create 创建规则
 func (s *ErrorRuleService) Create(req *CreateRuleRequest) (*model.ErrorRule, error) {
+	if err := validateRetryAction(req.RetryAction, req.RetryCount); err != nil {
+		return nil, err
+	}
+
 	rule := &model.ErrorRule{
 		HTTPStatusCode: req.HTTPStatusCode,
 		Keyword:        req.Keyword,
 		TargetStatus:   req.TargetStatus,
+		RetryAction:    req.RetryAction,
+		RetryCount:     req.RetryCount,
 		Priority:       req.Priority,
 		Enabled:        req.Enabled,
 		Description:    req.Description,
@@ -67,6 +80,20 @@ func (s *ErrorRuleService) Create(req *CreateRuleRequest) (*model.ErrorRule, err
 	return rule, nil
 }
 
+// validateRetryAction 校验重试动作和同账户重试次数
+func validateRetryAction(action string, count int) error {
+	if !model.IsValidRetryAction(action) {
+		return fmt.Errorf("无效的重试动作: %s", action)
+	}
+	if count < 0 {
+		return fmt.Errorf("重试次数不能为负数")
+	}
+	if action == model.RetryActionRetry && count == 0 {
+		return fmt.Errorf("重试动作需要设置重试次数")
+	}
+	return nil
+}
+
 // GetByID 根据ID获取规则
 func (s *ErrorRuleService) GetByID(id uint) (*model.ErrorRule, error) {
 	return s.repo.GetByID(id)
@@ -88,6 +115,15 @@ func (s *ErrorRuleService) Update(id uint, req *UpdateRuleRequest) (*model.Error
 	if req.TargetStatus != "" {
 		rule.TargetStatus = req.TargetStatus
 	}
+	if req.RetryAction != nil {
+		rule.RetryAction = *req.RetryAction
+	}
+	if req.RetryCount != nil {
+		rule.RetryCount = *req.RetryCount
+	}
+	if err := validateRetryAction(rule.RetryAction, rule.RetryCount); err != nil {
+		return nil, err
+	}
 	if req.Priority != nil {
 		rule.Priority = *req.Priority
 	}
@@ -4,10 +4,18 @@
  *   - 定时健康检查调度
  *   - 单个账号健康检测
  *   - 账号状态自动恢复
- *   - Token刷新
+ *   - Token刷新（含过期前主动刷新）
  *   - OAuth重新授权冷却控制
+ *   - 可选的真实推理深度探测（见 health_check_probe.go）
+ *   - Claude Console 多 Key 账户逐个检测并标记失效 Key
+ *   - 检查并发按账号数自适应，同一代理单独限流
+ *   - 区分"健康"和"健康但限流中"，持续 429 的账号标记为限流（见 health_check_ratelimit.go）
+ *   - 凭证到期看板、SessionKey 轻量校验和到期告警（见 health_check_credential.go）
+ *   - 服务启动后的账号就绪预热探测（见 health_check_startup.go）
+ *   - 账户自定义探测端点和期望状态码（见 health_check_endpoint.go）
+ *   - 一键检测所有账户并返回明细报告（见 health_check_report.go）
  * 重要程度：⭐⭐⭐⭐ 重要（账号可用性保障）
- * 依赖模块：repository, adapter, scheduler, logger
+ * 依赖模块：repository, adapter, scheduler, alert, logger
  */
 package service
 
@@ -19,8 +27,10 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"go-aiproxy/internal/alert"
 	"go-aiproxy/internal/model"
 	"go-aiproxy/internal/proxy/adapter"
 	"go-aiproxy/internal/proxy/scheduler"
@@ -45,6 +55,18 @@ type AccountHealthCheckService struct {
 	// OAuth 重新授权冷却记录
 	reauthorizeCooldown map[uint]time.Time
 	cooldownMu          sync.RWMutex
+
+	// 深度探测限频记录
+	lastDeepProbe map[uint]time.Time
+	deepProbeMu   sync.Mutex
+
+	// 观察窗口内的健康检查结果（是否 429），用于识别持续限流的账号
+	rateLimitSamples map[uint][]rateLimitSample
+	rateLimitMu      sync.Mutex
+
+	// 已告警的凭证问题（等级 + 过期时间），同一问题只告警一次
+	credentialAlerted map[uint]string
+	credentialAlertMu sync.Mutex
 }
 
 var healthCheckService *AccountHealthCheckService
@@ -59,6 +81,9 @@ func GetAccountHealthCheckService() *AccountHealthCheckService {
 			log:                 logger.GetLogger("health_check"),
 			stopChan:            make(chan struct{}),
 			reauthorizeCooldown: make(map[uint]time.Time),
+			lastDeepProbe:       make(map[uint]time.Time),
+			rateLimitSamples:    make(map[uint][]rateLimitSample),
+			credentialAlerted:   make(map[uint]string),
 		}
 	})
 	return healthCheckService
@@ -119,7 +144,9 @@ func (s *AccountHealthCheckService) normalAccountLoop() {
 
 	// 执行第一次检查
 	if s.configService.GetAccountHealthCheckEnabled() {
+		s.refreshExpiringTokens(interval)
 		s.doNormalCheck()
+		s.checkCredentials()
 	}
 
 	for {
@@ -131,7 +158,9 @@ func (s *AccountHealthCheckService) normalAccountLoop() {
 		select {
 		case <-time.After(interval):
 			if s.configService.GetAccountHealthCheckEnabled() {
+				s.refreshExpiringTokens(interval)
 				s.doNormalCheck()
+				s.checkCredentials()
 			}
 		case <-s.stopChan:
 			return
@@ -139,6 +168,91 @@ func (s *AccountHealthCheckService) normalAccountLoop() {
 	}
 }
 
+// tokenRefreshLockTTL 刷新进行中的锁时长（覆盖一次重新授权的最长耗时）
+const tokenRefreshLockTTL = 2 * time.Minute
+
+// refreshExpiringTokens 在 Token 过期前主动用 SessionKey 刷新，避免先有一批请求 401 失败
+// interval 为本循环的检测间隔，阈值小于间隔时按间隔放宽，防止 Token 在两次检测之间过期
+func (s *AccountHealthCheckService) refreshExpiringTokens(interval time.Duration) {
+	if !s.configService.GetHealthCheckAutoTokenRefresh() {
+		return
+	}
+	threshold := s.configService.GetTokenPreRefreshThreshold()
+	if threshold <= 0 {
+		return
+	}
+	window := threshold
+	if window <= interval {
+		window = interval + time.Minute
+	}
+
+	accounts, err := s.accountRepo.GetAccountsWithExpiringToken(time.Now().Add(window))
+	if err != nil {
+		s.log.Error("获取即将过期的 Token 账号失败: %v", err)
+		return
+	}
+
+	for i := range accounts {
+		account := &accounts[i]
+		if s.isInCooldown(account.ID) {
+			s.log.Debug("[%s] Token 提前刷新在冷却中", account.Name)
+			continue
+		}
+
+		// 多实例部署时通过数据库条件更新互斥，抢不到说明其他实例正在刷新或处于冷却
+		locked, err := s.accountRepo.TryLockTokenRefresh(account.ID, tokenRefreshLockTTL)
+		if err != nil {
+			s.log.Error("[%s] 获取 Token 刷新锁失败: %v", account.Name, err)
+			continue
+		}
+		if !locked {
+			s.log.Debug("[%s] Token 刷新锁被占用，跳过", account.Name)
+			continue
+		}
+
+		s.preRefreshToken(account.ID, window)
+	}
+}
+
+// preRefreshToken 持有刷新锁时执行一次提前刷新
+func (s *AccountHealthCheckService) preRefreshToken(accountID uint, window time.Duration) {
+	// 加锁后重新读取，其他实例可能刚刚刷新过
+	account, err := s.accountRepo.GetByID(accountID)
+	if err != nil {
+		s.log.Error("获取账号 %d 失败: %v", accountID, err)
+		s.accountRepo.SetTokenRefreshLockUntil(accountID, nil)
+		return
+	}
+	if account.TokenExpiry == nil || time.Until(*account.TokenExpiry) >= window {
+		s.accountRepo.SetTokenRefreshLockUntil(accountID, nil)
+		return
+	}
+	if account.Proxy == nil {
+		if defaultProxy, err := GetProxyService().GetDefaultProxy(); err == nil && defaultProxy != nil {
+			account.Proxy = defaultProxy
+		}
+	}
+
+	s.log.Info("[%s] Token 将于 %s 过期，提前刷新", account.Name, account.TokenExpiry.Format(time.RFC3339))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	reauthorized, err := s.tryReauthorizeWithSessionKey(ctx, account)
+	if reauthorized {
+		s.clearCooldown(account.ID)
+		s.accountRepo.SetTokenRefreshLockUntil(account.ID, nil)
+		return
+	}
+
+	// 刷新失败进入冷却，锁保持到冷却结束，其他实例同样不会重试
+	s.setCooldown(account.ID)
+	cooldownUntil := time.Now().Add(s.configService.GetTokenRefreshCooldown())
+	s.accountRepo.SetTokenRefreshLockUntil(account.ID, &cooldownUntil)
+	s.log.Warn("[%s] Token 提前刷新失败，冷却至 %s: %v",
+		account.Name, cooldownUntil.Format(time.RFC3339), err)
+}
+
 // problemAccountLoop 问题账号检测循环（每分钟检查一次是否有账号需要探测）
 func (s *AccountHealthCheckService) problemAccountLoop() {
 	// 等待服务稳定后开始
@@ -181,8 +295,8 @@ func (s *AccountHealthCheckService) doProblemAccountCheck() {
 	// 获取全局默认代理
 	defaultProxy, _ := GetProxyService().GetDefaultProxy()
 
-	// 并发检查，限制并发数
-	sem := make(chan struct{}, 3)
+	// 并发检查，限制全局并发和同一代理的并发
+	limiter := s.newHealthCheckLimiter(len(accounts), 3)
 	var wg sync.WaitGroup
 
 	for _, account := range accounts {
@@ -193,8 +307,8 @@ func (s *AccountHealthCheckService) doProblemAccountCheck() {
 		wg.Add(1)
 		go func(acc model.Account) {
 			defer wg.Done()
-			sem <- struct{}{}
-			defer func() { <-sem }()
+			release := limiter.acquire(&acc)
+			defer release()
 
 			s.checkProblemAccount(&acc)
 		}(account)
@@ -205,7 +319,7 @@ func (s *AccountHealthCheckService) doProblemAccountCheck() {
 
 // checkProblemAccount 检测单个问题账号，根据状态采取不同策略
 func (s *AccountHealthCheckService) checkProblemAccount(account *model.Account) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), s.healthCheckTimeout(account))
 	defer cancel()
 
 	switch account.Status {
@@ -229,16 +343,17 @@ func (s *AccountHealthCheckService) handleRateLimitedAccount(ctx context.Context
 		return
 	}
 
-	healthy, errMsg := s.checkAccount(account)
+	result, errMsg := s.checkAccount(account)
 
-	if healthy {
+	// 仍返回 429 不算恢复，直到观察到成功
+	if result == healthCheckHealthy {
 		// 恢复成功
 		if s.configService.GetHealthCheckAutoRecovery() {
 			if err := s.accountRepo.RecoverAccount(account.ID); err != nil {
 				s.log.Error("[%s] 恢复账号失败: %v", account.Name, err)
 			} else {
 				s.log.Info("[%s] 限流账号已恢复正常", account.Name)
-				scheduler.GetScheduler().Refresh()
+				scheduler.GetScheduler().BroadcastRefresh(account.ID, account.Platform, model.AccountStatusValid)
 			}
 		}
 	} else {
@@ -288,7 +403,7 @@ func (s *AccountHealthCheckService) handleTokenExpiredAccount(ctx context.Contex
 			if err := s.accountRepo.RecoverAccount(account.ID); err != nil {
 				s.log.Error("[%s] 恢复账号失败: %v", account.Name, err)
 			}
-			scheduler.GetScheduler().Refresh()
+			scheduler.GetScheduler().BroadcastRefresh(account.ID, account.Platform, model.AccountStatusValid)
 			return
 		}
 
@@ -298,7 +413,9 @@ func (s *AccountHealthCheckService) handleTokenExpiredAccount(ctx context.Contex
 		// 检查是否账号被封
 		if IsAccountBannedError(err) {
 			s.log.Warn("[%s] Token 刷新失败，账号疑似被封: %v", account.Name, err)
-			s.accountRepo.MarkAsSuspended(account.ID, err.Error())
+			if s.accountRepo.MarkAsSuspended(account.ID, err.Error()) == nil {
+				alert.NotifyAccountStatus(account, model.AccountStatusSuspended, err.Error())
+			}
 			return
 		}
 
@@ -313,16 +430,16 @@ func (s *AccountHealthCheckService) handleTokenExpiredAccount(ctx context.Contex
 
 // handleSuspendedAccount 处理疑似封号账号
 func (s *AccountHealthCheckService) handleSuspendedAccount(ctx context.Context, account *model.Account) {
-	healthy, errMsg := s.checkAccount(account)
+	result, errMsg := s.checkAccount(account)
 
-	if healthy {
+	if result.Healthy() {
 		// 恢复成功
 		if s.configService.GetHealthCheckAutoRecovery() {
 			if err := s.accountRepo.RecoverAccount(account.ID); err != nil {
 				s.log.Error("[%s] 恢复账号失败: %v", account.Name, err)
 			} else {
 				s.log.Info("[%s] 疑似封号账号已恢复正常", account.Name)
-				scheduler.GetScheduler().Refresh()
+				scheduler.GetScheduler().BroadcastRefresh(account.ID, account.Platform, model.AccountStatusValid)
 			}
 		}
 	} else {
@@ -342,7 +459,8 @@ func (s *AccountHealthCheckService) handleSuspendedAccount(ctx context.Context,
 				s.log.Error("[%s] 标记封号失败: %v", account.Name, err)
 			} else {
 				s.log.Warn("[%s] 连续 %d 次检测失败，确认封号", account.Name, count)
-				scheduler.GetScheduler().Refresh()
+				alert.NotifyAccountStatus(account, model.AccountStatusBanned, errMsg)
+				scheduler.GetScheduler().BroadcastRefresh(account.ID, account.Platform, model.AccountStatusBanned)
 			}
 		} else {
 			// 安排下次检测
@@ -359,16 +477,16 @@ func (s *AccountHealthCheckService) handleBannedAccount(ctx context.Context, acc
 		return
 	}
 
-	healthy, errMsg := s.checkAccount(account)
+	result, errMsg := s.checkAccount(account)
 
-	if healthy {
+	if result.Healthy() {
 		// 意外恢复！
 		if s.configService.GetHealthCheckAutoRecovery() {
 			if err := s.accountRepo.RecoverAccount(account.ID); err != nil {
 				s.log.Error("[%s] 恢复账号失败: %v", account.Name, err)
 			} else {
 				s.log.Info("[%s] 封号账号意外恢复正常！", account.Name)
-				scheduler.GetScheduler().Refresh()
+				scheduler.GetScheduler().BroadcastRefresh(account.ID, account.Platform, model.AccountStatusValid)
 			}
 		}
 	} else {
@@ -401,27 +519,35 @@ func (s *AccountHealthCheckService) TriggerSingleCheck(accountID uint) (bool, st
 		}
 	}
 
-	healthy, errMsg := s.checkAccount(account)
+	result, errMsg := s.checkAccount(account)
+	s.applyManualCheckResult(account, result, errMsg)
 
-	if healthy {
-		// 自动恢复
-		if s.configService.GetHealthCheckAutoRecovery() {
-			if err := s.accountRepo.RecoverAccount(accountID); err != nil {
-				s.log.Error("[%s] 恢复账号失败: %v", account.Name, err)
-			} else {
-				s.log.Info("[%s] 手动检测通过，账号已恢复", account.Name)
-				scheduler.GetScheduler().Refresh()
-			}
+	if result.Healthy() {
+		if result == healthCheckRateLimited {
+			return true, "检测通过，账号有效但限流中"
 		}
 		return true, "检测通过，账号正常"
 	}
-
-	// 检测失败，根据错误类型更新账号状态
-	s.updateAccountStatusByError(account, errMsg)
-
 	return false, errMsg
 }
 
+// applyManualCheckResult 按手动检测结果更新账号状态：通过时自动恢复（按配置），失败时按错误类型标记
+func (s *AccountHealthCheckService) applyManualCheckResult(account *model.Account, result healthCheckResult, errMsg string) {
+	if !result.Healthy() {
+		s.updateAccountStatusByError(account, errMsg)
+		return
+	}
+	if !s.configService.GetHealthCheckAutoRecovery() {
+		return
+	}
+	if err := s.accountRepo.RecoverAccount(account.ID); err != nil {
+		s.log.Error("[%s] 恢复账号失败: %v", account.Name, err)
+	} else {
+		s.log.Info("[%s] 手动检测通过，账号已恢复", account.Name)
+		scheduler.GetScheduler().BroadcastRefresh(account.ID, account.Platform, model.AccountStatusValid)
+	}
+}
+
 // updateAccountStatusByError 根据错误信息更新账号状态
 func (s *AccountHealthCheckService) updateAccountStatusByError(account *model.Account, errMsg string) {
 	errLower := strings.ToLower(errMsg)
@@ -436,7 +562,7 @@ func (s *AccountHealthCheckService) updateAccountStatusByError(account *model.Ac
 				s.log.Error("[%s] 标记 Token 过期失败: %v", account.Name, err)
 			} else {
 				s.log.Warn("[%s] 检测失败，标记为 Token 过期: %s", account.Name, truncateMsg(errMsg, 100))
-				scheduler.GetScheduler().Refresh()
+				scheduler.GetScheduler().BroadcastRefresh(account.ID, account.Platform, model.AccountStatusTokenExpired)
 			}
 		}
 	} else if strings.Contains(errLower, "403") || strings.Contains(errLower, "封") ||
@@ -448,7 +574,8 @@ func (s *AccountHealthCheckService) updateAccountStatusByError(account *model.Ac
 				s.log.Error("[%s] 标记疑似封号失败: %v", account.Name, err)
 			} else {
 				s.log.Warn("[%s] 检测失败，标记为疑似封号: %s", account.Name, truncateMsg(errMsg, 100))
-				scheduler.GetScheduler().Refresh()
+				alert.NotifyAccountStatus(account, model.AccountStatusSuspended, errMsg)
+				scheduler.GetScheduler().BroadcastRefresh(account.ID, account.Platform, model.AccountStatusSuspended)
 			}
 		}
 	} else if strings.Contains(errLower, "429") || strings.Contains(errLower, "rate") ||
@@ -460,7 +587,8 @@ func (s *AccountHealthCheckService) updateAccountStatusByError(account *model.Ac
 				s.log.Error("[%s] 标记限流失败: %v", account.Name, err)
 			} else {
 				s.log.Warn("[%s] 检测失败，标记为限流: %s", account.Name, truncateMsg(errMsg, 100))
-				scheduler.GetScheduler().Refresh()
+				alert.NotifyAccountStatus(account, model.AccountStatusRateLimited, errMsg)
+				scheduler.GetScheduler().BroadcastRefresh(account.ID, account.Platform, model.AccountStatusRateLimited)
 			}
 		}
 	} else {
@@ -470,7 +598,7 @@ func (s *AccountHealthCheckService) updateAccountStatusByError(account *model.Ac
 				s.log.Error("[%s] 标记无效失败: %v", account.Name, err)
 			} else {
 				s.log.Warn("[%s] 检测失败，标记为无效: %s", account.Name, truncateMsg(errMsg, 100))
-				scheduler.GetScheduler().Refresh()
+				scheduler.GetScheduler().BroadcastRefresh(account.ID, account.Platform, model.AccountStatusInvalid)
 			}
 		}
 	}
@@ -488,7 +616,7 @@ func (s *AccountHealthCheckService) ForceRecover(accountID uint) error {
 	}
 
 	s.log.Info("[%s] 账号已强制恢复", account.Name)
-	scheduler.GetScheduler().Refresh()
+	scheduler.GetScheduler().BroadcastRefresh(account.ID, account.Platform, model.AccountStatusValid)
 	return nil
 }
 
@@ -516,7 +644,7 @@ func (s *AccountHealthCheckService) RefreshToken(accountID uint) error {
 			s.accountRepo.RecoverAccount(accountID)
 		}
 
-		scheduler.GetScheduler().Refresh()
+		scheduler.GetScheduler().BroadcastRefresh(account.ID, account.Platform, model.AccountStatusValid)
 		return nil
 	}
 
@@ -528,6 +656,9 @@ func (s *AccountHealthCheckService) doNormalCheck() {
 	s.log.Info("开始正常账号健康检查...")
 	startTime := time.Now()
 
+	// 先检查代理主备状态，主代理恢复后切回
+	s.checkProxyFailover()
+
 	// 获取需要健康检查的账号（只检查 valid 状态的）
 	accounts, err := s.accountRepo.GetAccountsForHealthCheck()
 	if err != nil {
@@ -547,11 +678,11 @@ func (s *AccountHealthCheckService) doNormalCheck() {
 		s.log.Warn("获取默认代理失败: %v", err)
 	}
 
-	checkedCount := 0
-	failedCount := 0
+	var checkedCount, failedCount int64
 	threshold := s.configService.GetAccountErrorThreshold()
 
-	sem := make(chan struct{}, 5)
+	limiter := s.newHealthCheckLimiter(len(accounts), 5)
+	s.log.Info("本轮检查 %d 个账号，并发数 %d", len(accounts), cap(limiter.global))
 	var wg sync.WaitGroup
 
 	for _, account := range accounts {
@@ -561,20 +692,21 @@ func (s *AccountHealthCheckService) doNormalCheck() {
 		wg.Add(1)
 		go func(acc model.Account) {
 			defer wg.Done()
-			sem <- struct{}{}
-			defer func() { <-sem }()
+			release := limiter.acquire(&acc)
+			defer release()
 
-			healthy, errMsg := s.checkAccount(&acc)
-			checkedCount++
+			result, errMsg := s.checkAccount(&acc)
+			atomic.AddInt64(&checkedCount, 1)
 
-			if healthy {
+			if result.Healthy() {
 				if acc.ConsecutiveErrorCount > 0 {
 					if err := s.accountRepo.ResetConsecutiveErrorCount(acc.ID); err != nil {
 						s.log.Error("[%s] 重置错误计数失败: %v", acc.Name, err)
 					}
 				}
+				s.recordRateLimitSample(&acc, result == healthCheckRateLimited)
 			} else {
-				failedCount++
+				atomic.AddInt64(&failedCount, 1)
 				newCount, err := s.accountRepo.IncrementConsecutiveErrorCount(acc.ID)
 				if err != nil {
 					s.log.Error("[%s] 增加错误计数失败: %v", acc.Name, err)
@@ -590,7 +722,8 @@ func (s *AccountHealthCheckService) doNormalCheck() {
 						s.log.Error("[%s] 标记疑似封号失败: %v", acc.Name, err)
 					} else {
 						s.log.Warn("[%s] 连续错误达到阈值 %d，标记为疑似封号", acc.Name, threshold)
-						scheduler.GetScheduler().Refresh()
+						alert.NotifyAccountStatus(&acc, model.AccountStatusSuspended, errMsg)
+						scheduler.GetScheduler().BroadcastRefresh(acc.ID, acc.Platform, model.AccountStatusSuspended)
 					}
 				}
 			}
@@ -600,8 +733,8 @@ func (s *AccountHealthCheckService) doNormalCheck() {
 	wg.Wait()
 
 	s.lastCheck = time.Now()
-	s.checkedCount = checkedCount
-	s.failedCount = failedCount
+	s.checkedCount = int(checkedCount)
+	s.failedCount = int(failedCount)
 	s.lastError = nil
 
 	duration := time.Since(startTime)
@@ -609,23 +742,156 @@ func (s *AccountHealthCheckService) doNormalCheck() {
 		checkedCount, failedCount, duration)
 }
 
+// checkProxyFailover 记录各代理当前生效的是主代理还是备用代理，主代理恢复后切回
+func (s *AccountHealthCheckService) checkProxyFailover() {
+	proxies, err := GetProxyService().GetEnabledProxies()
+	if err != nil {
+		s.log.Warn("获取代理列表失败: %v", err)
+		return
+	}
+
+	for i := range proxies {
+		p := &proxies[i]
+		active := adapter.ProbeProxyFailover(p)
+		if active == p.ActiveFallback {
+			continue
+		}
+		if active == 0 {
+			s.log.Info("[代理 %s] 已切回主代理", p.Name)
+		} else {
+			s.log.Warn("[代理 %s] 当前使用第 %d 个备用代理", p.Name, active)
+		}
+		if err := GetProxyService().UpdateActiveFallback(p.ID, active); err != nil {
+			s.log.Error("[代理 %s] 更新生效节点失败: %v", p.Name, err)
+		}
+	}
+}
+
+// healthCheckTimeout 单次检测的超时时间
+// 默认取系统配置（30 秒）；账户配置了请求超时时与上游请求保持一致
+func (s *AccountHealthCheckService) healthCheckTimeout(account *model.Account) time.Duration {
+	if account.RequestTimeout > 0 {
+		return adapter.GetRequestTimeout(account)
+	}
+	return s.configService.GetHealthCheckTimeout()
+}
+
+// healthCheckLimiter 一轮健康检查的并发控制：全局并发 + 同一代理并发
+type healthCheckLimiter struct {
+	global   chan struct{}
+	perProxy int
+
+	mu      sync.Mutex
+	proxies map[uint]chan struct{}
+}
+
+// newHealthCheckLimiter 按本轮账号数创建并发控制，minimum 为自适应时的最小并发
+func (s *AccountHealthCheckService) newHealthCheckLimiter(accountCount, minimum int) *healthCheckLimiter {
+	return &healthCheckLimiter{
+		global:   make(chan struct{}, s.configService.GetHealthCheckConcurrency(accountCount, minimum)),
+		perProxy: s.configService.GetHealthCheckProxyConcurrency(),
+		proxies:  make(map[uint]chan struct{}),
+	}
+}
+
+// acquire 获取检测名额，先占代理名额再占全局名额，避免等待代理时占用全局并发
+// 返回的函数用于释放名额
+func (l *healthCheckLimiter) acquire(account *model.Account) func() {
+	var proxySem chan struct{}
+	if l.perProxy > 0 && account.Proxy != nil {
+		l.mu.Lock()
+		proxySem = l.proxies[account.Proxy.ID]
+		if proxySem == nil {
+			proxySem = make(chan struct{}, l.perProxy)
+			l.proxies[account.Proxy.ID] = proxySem
+		}
+		l.mu.Unlock()
+		proxySem <- struct{}{}
+	}
+	l.global <- struct{}{}
+
+	return func() {
+		<-l.global
+		if proxySem != nil {
+			<-proxySem
+		}
+	}
+}
+
+// healthCheckRateLimitedMsg 各 check 函数遇到 429 时的返回信息：账号有效但限流中
+const healthCheckRateLimitedMsg = "限流中 (HTTP 429)"
+
+// healthCheckResult 健康检查结果
+type healthCheckResult int
+
+const (
+	healthCheckUnhealthy   healthCheckResult = iota // 不健康
+	healthCheckHealthy                              // 健康
+	healthCheckRateLimited                          // 健康但限流中（429）
+)
+
+// Healthy 账号是否有效（限流中也视为有效）
+func (r healthCheckResult) Healthy() bool {
+	return r != healthCheckUnhealthy
+}
+
+// toHealthCheckResult 将 check 函数的 (是否健康, 错误信息) 转换为检查结果
+func toHealthCheckResult(healthy bool, errMsg string) healthCheckResult {
+	switch {
+	case !healthy:
+		return healthCheckUnhealthy
+	case errMsg == healthCheckRateLimitedMsg:
+		return healthCheckRateLimited
+	default:
+		return healthCheckHealthy
+	}
+}
+
 // checkAccount 检查单个账号的健康状态
-// 返回: (是否健康, 错误信息)
-func (s *AccountHealthCheckService) checkAccount(account *model.Account) (bool, string) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+// 返回: (检查结果, 错误信息)，429 返回 healthCheckRateLimited
+func (s *AccountHealthCheckService) checkAccount(account *model.Account) (healthCheckResult, string) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.healthCheckTimeout(account))
 	defer cancel()
 
+	result, errMsg := s.shallowCheck(ctx, account)
+
+	// 浅层检查通过后，按限频做一次真实推理探测（限流中的账号探测也只会拿到 429，跳过）
+	if result == healthCheckHealthy && s.shouldDeepProbe(account.ID) {
+		healthy, probeMsg := s.deepProbe(ctx, account)
+		result, errMsg = toHealthCheckResult(healthy, probeMsg), probeMsg
+	}
+	return result, errMsg
+}
+
+// shallowCheck 浅层检查（凭证/用量接口，不产生推理费用），不支持的账号类型视为健康
+// 账户配置了探测端点时只按该端点检查，不走默认逻辑
+func (s *AccountHealthCheckService) shallowCheck(ctx context.Context, account *model.Account) (healthCheckResult, string) {
+	var healthy bool
+	var errMsg string
+	if account.HealthCheckURL != "" {
+		healthy, errMsg = s.checkCustomEndpoint(ctx, account)
+		return toHealthCheckResult(healthy, errMsg), errMsg
+	}
+
 	switch account.Type {
 	case model.AccountTypeClaudeOfficial:
-		return s.checkClaudeOfficial(ctx, account)
+		healthy, errMsg = s.checkClaudeOfficial(ctx, account)
+	case model.AccountTypeClaudeConsole:
+		if account.APIKeys == "" {
+			// 单 Key 账户保持原有行为，不做检查
+			return healthCheckHealthy, ""
+		}
+		healthy, errMsg = s.checkClaudeConsoleKeys(ctx, account)
 	case model.AccountTypeOpenAIResponses:
-		return s.checkOpenAIResponses(ctx, account)
-	case model.AccountTypeGemini:
-		return s.checkGemini(ctx, account)
+		healthy, errMsg = s.checkOpenAIResponses(ctx, account)
+	case model.AccountTypeGemini, model.AccountTypeGeminiAPI:
+		healthy, errMsg = s.checkGemini(ctx, account)
 	default:
 		// 不支持的账号类型，跳过检查
-		return true, ""
+		return healthCheckHealthy, ""
 	}
+
+	return toHealthCheckResult(healthy, errMsg), errMsg
 }
 
 // checkClaudeOfficial 检查 Claude Official 账号
@@ -638,7 +904,7 @@ func (s *AccountHealthCheckService) checkClaudeOfficial(ctx context.Context, acc
 	if account.AccessToken != "" {
 		healthy, errMsg := s.checkClaudeOAuth(ctx, account)
 		if healthy {
-			return true, ""
+			return true, errMsg
 		}
 
 		// OAuth 失败，如果有 SessionKey 则尝试重新授权
@@ -692,6 +958,75 @@ func (s *AccountHealthCheckService) checkClaudeOfficial(ctx context.Context, acc
 	return false, "AccessToken 和 SessionKey 都为空"
 }
 
+// checkClaudeConsoleKeys 逐个检查 Claude Console 多 Key 账户的 API Key
+// 通过 /v1/models 验证，401/403 的 Key 标记为失效（不参与轮换），恢复的 Key 自动取消标记
+// 至少有一个 Key 有效即视为账户健康
+func (s *AccountHealthCheckService) checkClaudeConsoleKeys(ctx context.Context, account *model.Account) (bool, string) {
+	keys := account.APIKeyPool()
+	if len(keys) == 0 {
+		return false, "APIKey 为空"
+	}
+
+	baseURL := "https://api.anthropic.com"
+	if account.BaseURL != "" {
+		baseURL = account.BaseURL
+	}
+	client := adapter.GetHTTPClient(account)
+
+	var invalid []string
+	var lastErr string
+	for _, key := range keys {
+		req, err := http.NewRequestWithContext(ctx, "GET", baseURL+"/v1/models", nil)
+		if err != nil {
+			return false, fmt.Sprintf("创建请求失败: %v", err)
+		}
+		req.Header.Set("x-api-key", key)
+		req.Header.Set("anthropic-version", adapter.DefaultAnthropicVersion)
+		req.Header.Set("Accept", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			// 网络错误无法判断 Key 是否有效，保持原标记
+			return false, fmt.Sprintf("请求失败: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode == 401 || resp.StatusCode == 403 {
+			invalid = append(invalid, key)
+			lastErr = fmt.Sprintf("HTTP %d: %s", resp.StatusCode, truncateMsg(string(body), 200))
+			s.log.Warn("[%s] API Key 失效 | Key: %s | %s", account.Name, maskAPIKey(key), lastErr)
+		}
+	}
+
+	invalidJSON := ""
+	if len(invalid) > 0 {
+		data, _ := json.Marshal(invalid)
+		invalidJSON = string(data)
+	}
+	if invalidJSON != account.InvalidAPIKeys {
+		if err := s.accountRepo.UpdateInvalidAPIKeys(account.ID, invalidJSON); err != nil {
+			s.log.Error("[%s] 更新失效 API Key 失败: %v", account.Name, err)
+		} else {
+			account.InvalidAPIKeys = invalidJSON
+			scheduler.GetScheduler().BroadcastRefresh(account.ID, account.Platform, account.Status)
+		}
+	}
+
+	if len(invalid) == len(keys) {
+		return false, fmt.Sprintf("全部 %d 个 API Key 均失效，最后错误: %s", len(keys), lastErr)
+	}
+	return true, ""
+}
+
+// maskAPIKey 日志中隐藏 Key 中间部分
+func maskAPIKey(key string) string {
+	if len(key) <= 8 {
+		return "***"
+	}
+	return key[:4] + "..." + key[len(key)-4:]
+}
+
 // isInCooldown 检查账号是否在重新授权冷却时间内
 func (s *AccountHealthCheckService) isInCooldown(accountID uint) bool {
 	s.cooldownMu.RLock()
@@ -754,7 +1089,7 @@ func (s *AccountHealthCheckService) tryReauthorizeWithSessionKey(ctx context.Con
 		account.Name, expiry.Format(time.RFC3339), tokenResult.Scope)
 
 	// 刷新调度器缓存
-	scheduler.GetScheduler().Refresh()
+	scheduler.GetScheduler().BroadcastRefresh(account.ID, account.Platform, account.Status)
 
 	return true, nil
 }
@@ -814,7 +1149,7 @@ func (s *AccountHealthCheckService) checkClaudeOAuth(ctx context.Context, accoun
 	// 429 表示限流，账号仍然有效
 	if resp.StatusCode == 429 {
 		s.log.Debug("[%s] OAuth 验证: 限流中但账号有效", account.Name)
-		return true, ""
+		return true, healthCheckRateLimitedMsg
 	}
 
 	// 其他错误
@@ -859,7 +1194,7 @@ func (s *AccountHealthCheckService) checkClaudeSessionKey(ctx context.Context, a
 	// 429 表示限流，账号仍然有效
 	if resp.StatusCode == 429 {
 		s.log.Debug("[%s] SessionKey 验证: 限流中但账号有效", account.Name)
-		return true, ""
+		return true, healthCheckRateLimitedMsg
 	}
 
 	// 其他错误
@@ -867,12 +1202,19 @@ func (s *AccountHealthCheckService) checkClaudeSessionKey(ctx context.Context, a
 }
 
 // checkOpenAIResponses 检查 OpenAI Responses 账号
-// 支持两种认证方式：
-// 1. API Key: 通过 /v1/models 验证
-// 2. OAuth Token (access_token/session_key): 通过 ChatGPT backend-api 验证
+// 凭证选择与实际转发一致（account.GetAuthToken）：
+// 1. OAuth Token (session_key/access_token): 通过 ChatGPT backend-api 验证
+// 2. API Key: 通过 /v1/models 验证
 func (s *AccountHealthCheckService) checkOpenAIResponses(ctx context.Context, account *model.Account) (bool, string) {
+	token, kind := account.GetAuthToken()
+	if token == "" {
+		// 没有任何认证信息
+		return false, "APIKey、AccessToken 和 SessionKey 都为空"
+	}
+	s.log.Debug("[%s] 健康检查认证凭证: %s", account.Name, kind)
+
 	// 如果使用 API Key，通过 /v1/models 验证
-	if account.APIKey != "" {
+	if kind == model.AuthTokenKindAPIKey {
 		client := adapter.GetSmartHTTPClient(account, "https://api.openai.com")
 
 		baseURL := "https://api.openai.com"
@@ -885,7 +1227,7 @@ func (s *AccountHealthCheckService) checkOpenAIResponses(ctx context.Context, ac
 			return false, fmt.Sprintf("创建请求失败: %v", err)
 		}
 
-		req.Header.Set("Authorization", "Bearer "+account.APIKey)
+		req.Header.Set("Authorization", "Bearer "+token)
 		req.Header.Set("Accept", "application/json")
 
 		resp, err := client.Do(req)
@@ -916,23 +1258,18 @@ func (s *AccountHealthCheckService) checkOpenAIResponses(ctx context.Context, ac
 
 		// 429 表示限流，账号仍然有效
 		if resp.StatusCode == 429 {
-			return true, ""
+			return true, healthCheckRateLimitedMsg
 		}
 
 		return false, fmt.Sprintf("HTTP %d: %s", resp.StatusCode, string(body))
 	}
 
-	// 如果使用 OAuth Token（access_token 或 session_key），通过 ChatGPT backend-api 验证
-	token := account.AccessToken
-	if token == "" {
-		token = account.SessionKey
-	}
-	if token != "" {
-		return s.checkChatGPTOAuth(ctx, account, token)
+	// OAuth Token（session_key 或 access_token），通过 ChatGPT backend-api 验证
+	ok, msg := s.checkChatGPTOAuth(ctx, account, token)
+	if !ok {
+		msg = fmt.Sprintf("[%s] %s", kind, msg)
 	}
-
-	// 没有任何认证信息
-	return false, "APIKey、AccessToken 和 SessionKey 都为空"
+	return ok, msg
 }
 
 // checkChatGPTOAuth 检查 ChatGPT OAuth Token
@@ -1007,7 +1344,7 @@ func (s *AccountHealthCheckService) checkChatGPTOAuth(ctx context.Context, accou
 	// 429 表示限流，账号仍然有效
 	if resp.StatusCode == 429 {
 		s.log.Debug("[%s] ChatGPT OAuth 验证: 限流中但账号有效", account.Name)
-		return true, ""
+		return true, healthCheckRateLimitedMsg
 	}
 
 	// 其他错误
@@ -1018,12 +1355,12 @@ func (s *AccountHealthCheckService) checkChatGPTOAuth(ctx context.Context, accou
 	return false, fmt.Sprintf("HTTP %d: %s", resp.StatusCode, errMsg)
 }
 
-// checkGemini 检查 Gemini OAuth 账号
+// checkGemini 检查 Gemini 账号
 // 通过调用模型列表接口来验证账号有效性
+// 支持两种认证方式：优先 AccessToken（OAuth），为空时使用 AI Studio API Key
 func (s *AccountHealthCheckService) checkGemini(ctx context.Context, account *model.Account) (bool, string) {
-	// 使用 AccessToken 认证
-	if account.AccessToken == "" {
-		return false, "AccessToken 为空"
+	if account.AccessToken == "" && account.APIKey == "" {
+		return false, "AccessToken 和 APIKey 均为空"
 	}
 
 	client := adapter.GetHTTPClient(account)
@@ -1039,7 +1376,9 @@ func (s *AccountHealthCheckService) checkGemini(ctx context.Context, account *mo
 		return false, fmt.Sprintf("创建请求失败: %v", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+account.AccessToken)
+	for k, v := range adapter.GeminiAuthHeaders(account) {
+		req.Header.Set(k, v)
+	}
 	req.Header.Set("Accept", "application/json")
 
 	resp, err := client.Do(req)
@@ -1054,8 +1393,8 @@ func (s *AccountHealthCheckService) checkGemini(ctx context.Context, account *mo
 		return true, ""
 	}
 
-	// 401/403 表示认证失败
-	if resp.StatusCode == 401 || resp.StatusCode == 403 {
+	// 401/403 表示认证失败（API Key 无效时 Google 返回 400 API_KEY_INVALID，一并视为认证失败）
+	if resp.StatusCode == 401 || resp.StatusCode == 403 || (resp.StatusCode == 400 && strings.Contains(string(body), "API_KEY_INVALID")) {
 		// 尝试解析 Google API 错误格式
 		var errResp struct {
 			Error struct {
@@ -1071,7 +1410,7 @@ func (s *AccountHealthCheckService) checkGemini(ctx context.Context, account *mo
 
 	// 429 表示限流，账号仍然有效
 	if resp.StatusCode == 429 {
-		return true, ""
+		return true, healthCheckRateLimitedMsg
 	}
 
 	return false, fmt.Sprintf("HTTP %d: %s", resp.StatusCode, string(body))
@@ -1110,6 +1449,10 @@ func (s *AccountHealthCheckService) GetStatus() map[string]interface{} {
 			"banned_probe_interval":      s.configService.GetBannedProbeInterval().Hours(),
 			"token_refresh_cooldown":     s.configService.GetTokenRefreshCooldown().Minutes(),
 			"token_refresh_max_retries":  s.configService.GetTokenRefreshMaxRetries(),
+			"deep_probe":                 s.configService.GetDeepProbeEnabled(),
+			"deep_probe_interval":        s.configService.GetDeepProbeInterval().Minutes(),
+			"proxy_concurrency":          s.configService.GetHealthCheckProxyConcurrency(),
+			"check_timeout":              s.configService.GetHealthCheckTimeout().Seconds(),
 		},
 	}
 
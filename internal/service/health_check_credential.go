@@ -0,0 +1,245 @@
+/*
+ * 文件作用：账号凭证健康度，集中展示 Token / SessionKey 的到期和有效性
+ * 负责功能：
+ *   - 汇总所有账号的 Token 剩余时间、最近刷新成功时间、SessionKey 校验结果
+ *   - 定期轻量校验 SessionKey（只查询组织信息，不重新授权）
+ *   - 无法自动刷新的凭证即将过期、已过期或 SessionKey 失效时推送告警
+ * 重要程度：⭐⭐⭐ 一般（凭证到期前发现问题）
+ * 依赖模块：repository, alert, model
+ */
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"go-aiproxy/internal/alert"
+	"go-aiproxy/internal/model"
+)
+
+// 凭证健康等级
+const (
+	CredentialLevelOK       = "ok"       // 正常
+	CredentialLevelExpiring = "expiring" // 即将过期（提醒窗口内）
+	CredentialLevelExpired  = "expired"  // 已过期
+	CredentialLevelInvalid  = "invalid"  // SessionKey 校验失效或账号 Token 过期状态
+)
+
+// CredentialHealthItem 单个账号的凭证健康度
+type CredentialHealthItem struct {
+	ID                  uint       `json:"id"`
+	Name                string     `json:"name"`
+	Type                string     `json:"type"`
+	Platform            string     `json:"platform"`
+	Status              string     `json:"status"`
+	Enabled             bool       `json:"enabled"`
+	TokenExpiry         *time.Time `json:"token_expiry,omitempty"`
+	ExpiresIn           *int64     `json:"expires_in,omitempty"` // Token 剩余秒数，已过期为负数
+	TokenRefreshedAt    *time.Time `json:"token_refreshed_at,omitempty"`
+	HasSessionKey       bool       `json:"has_session_key"`
+	HasRefreshToken     bool       `json:"has_refresh_token"`
+	AutoRefresh         bool       `json:"auto_refresh"` // 是否能自动续期（有 SessionKey 或 RefreshToken）
+	SessionKeyValid     *bool      `json:"session_key_valid,omitempty"`
+	SessionKeyCheckedAt *time.Time `json:"session_key_checked_at,omitempty"`
+	Level               string     `json:"level"`
+	Reason              string     `json:"reason,omitempty"`
+}
+
+// CredentialHealthReport 凭证健康看板
+type CredentialHealthReport struct {
+	Items       []CredentialHealthItem `json:"items"`
+	Counts      map[string]int         `json:"counts"`    // level -> 数量
+	WarnDays    int                    `json:"warn_days"` // 即将过期的提醒窗口（天）
+	GeneratedAt time.Time              `json:"generated_at"`
+}
+
+// GetCredentialHealth 获取所有持有可过期凭证的账号的健康度，问题最严重的排在前面
+func (s *AccountHealthCheckService) GetCredentialHealth() (*CredentialHealthReport, error) {
+	accounts, err := s.accountRepo.GetAccountsWithCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	window := s.configService.GetCredentialExpiryWarnWindow()
+	now := time.Now()
+	report := &CredentialHealthReport{
+		Items:       make([]CredentialHealthItem, 0, len(accounts)),
+		Counts:      make(map[string]int),
+		WarnDays:    int(window / (24 * time.Hour)),
+		GeneratedAt: now,
+	}
+	for i := range accounts {
+		item := buildCredentialHealthItem(&accounts[i], window, now)
+		report.Items = append(report.Items, item)
+		report.Counts[item.Level]++
+	}
+
+	// 按严重程度稳定排序（仓库层已按过期时间升序）
+	ordered := make([]CredentialHealthItem, 0, len(report.Items))
+	for _, level := range []string{CredentialLevelInvalid, CredentialLevelExpired, CredentialLevelExpiring, CredentialLevelOK} {
+		for _, item := range report.Items {
+			if item.Level == level {
+				ordered = append(ordered, item)
+			}
+		}
+	}
+	report.Items = ordered
+	return report, nil
+}
+
+// buildCredentialHealthItem 计算单个账号的凭证健康度
+func buildCredentialHealthItem(account *model.Account, window time.Duration, now time.Time) CredentialHealthItem {
+	item := CredentialHealthItem{
+		ID:                  account.ID,
+		Name:                account.Name,
+		Type:                account.Type,
+		Platform:            account.Platform,
+		Status:              account.Status,
+		Enabled:             account.Enabled,
+		TokenExpiry:         account.TokenExpiry,
+		TokenRefreshedAt:    account.TokenRefreshedAt,
+		HasSessionKey:       account.SessionKey != "",
+		HasRefreshToken:     account.RefreshToken != "",
+		SessionKeyValid:     account.SessionKeyValid,
+		SessionKeyCheckedAt: account.SessionKeyCheckedAt,
+		Level:               CredentialLevelOK,
+	}
+	item.AutoRefresh = item.HasSessionKey || item.HasRefreshToken
+	if account.TokenExpiry != nil {
+		remaining := int64(account.TokenExpiry.Sub(now).Seconds())
+		item.ExpiresIn = &remaining
+	}
+
+	switch {
+	case account.SessionKeyValid != nil && !*account.SessionKeyValid:
+		item.Level = CredentialLevelInvalid
+		item.Reason = "SessionKey 校验失效"
+	case account.Status == model.AccountStatusTokenExpired:
+		item.Level = CredentialLevelInvalid
+		item.Reason = "Token 过期且自动刷新失败"
+	case account.TokenExpiry != nil && !account.TokenExpiry.After(now):
+		item.Level = CredentialLevelExpired
+		item.Reason = "Token 已过期"
+	case account.TokenExpiry != nil && account.TokenExpiry.Sub(now) < window:
+		item.Level = CredentialLevelExpiring
+		item.Reason = fmt.Sprintf("Token 将于 %s 过期", account.TokenExpiry.Format("2006-01-02 15:04"))
+	}
+	return item
+}
+
+// checkCredentials 正常检测循环中执行：到期轻量校验 SessionKey，并对有问题的凭证告警
+func (s *AccountHealthCheckService) checkCredentials() {
+	s.verifySessionKeys()
+
+	if !s.configService.GetCredentialExpiryAlert() || !alert.Enabled() {
+		return
+	}
+	accounts, err := s.accountRepo.GetAccountsWithCredentials()
+	if err != nil {
+		s.log.Error("获取凭证账号失败: %v", err)
+		return
+	}
+	window := s.configService.GetCredentialExpiryWarnWindow()
+	now := time.Now()
+	for i := range accounts {
+		account := &accounts[i]
+		if !account.Enabled {
+			continue
+		}
+		item := buildCredentialHealthItem(account, window, now)
+		s.alertCredential(account, &item)
+	}
+}
+
+// verifySessionKeys 轻量校验到期的 SessionKey
+func (s *AccountHealthCheckService) verifySessionKeys() {
+	interval := s.configService.GetSessionKeyCheckInterval()
+	if interval <= 0 {
+		return
+	}
+	accounts, err := s.accountRepo.GetAccountsForSessionKeyCheck(time.Now().Add(-interval))
+	if err != nil {
+		s.log.Error("获取待校验 SessionKey 的账号失败: %v", err)
+		return
+	}
+
+	oauthService := GetOAuthAuthService()
+	for i := range accounts {
+		account := &accounts[i]
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := oauthService.VerifySessionKey(ctx, account)
+		cancel()
+
+		// 网络/代理错误无法说明 SessionKey 状态，等下次再校验
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			s.log.Warn("[%s] SessionKey 校验请求失败，跳过: %v", account.Name, err)
+			continue
+		}
+		valid := err == nil
+		if err := s.accountRepo.UpdateSessionKeyCheck(account.ID, valid); err != nil {
+			s.log.Error("[%s] 保存 SessionKey 校验结果失败: %v", account.Name, err)
+			continue
+		}
+		if !valid {
+			s.log.Warn("[%s] SessionKey 校验失效: %v", account.Name, err)
+		}
+	}
+}
+
+// alertCredential 凭证有问题时告警，同一问题（等级 + 过期时间）只告警一次
+// 能自动续期的账号 Token 过期后会在使用时刷新，不告警；续期本身失败（失效）才告警
+func (s *AccountHealthCheckService) alertCredential(account *model.Account, item *CredentialHealthItem) {
+	var kind string
+	switch item.Level {
+	case CredentialLevelInvalid:
+		kind = alert.CredentialSessionKeyInvalid
+		if account.SessionKeyValid == nil || *account.SessionKeyValid {
+			kind = alert.CredentialExpired
+		}
+	case CredentialLevelExpired:
+		if item.AutoRefresh {
+			return
+		}
+		kind = alert.CredentialExpired
+	case CredentialLevelExpiring:
+		if item.AutoRefresh {
+			return
+		}
+		kind = alert.CredentialExpiring
+	default:
+		s.clearCredentialAlert(account.ID)
+		return
+	}
+
+	key := kind
+	if account.TokenExpiry != nil {
+		key += "@" + account.TokenExpiry.Format(time.RFC3339)
+	}
+	if !s.markCredentialAlert(account.ID, key) {
+		return
+	}
+	s.log.Warn("[%s] 凭证告警: %s", account.Name, item.Reason)
+	alert.NotifyCredential(account, kind, item.Reason)
+}
+
+// markCredentialAlert 记录已告警的问题，已告警过返回 false
+func (s *AccountHealthCheckService) markCredentialAlert(accountID uint, key string) bool {
+	s.credentialAlertMu.Lock()
+	defer s.credentialAlertMu.Unlock()
+	if s.credentialAlerted[accountID] == key {
+		return false
+	}
+	s.credentialAlerted[accountID] = key
+	return true
+}
+
+// clearCredentialAlert 凭证恢复正常后清除告警记录，下次出问题重新告警
+func (s *AccountHealthCheckService) clearCredentialAlert(accountID uint) {
+	s.credentialAlertMu.Lock()
+	defer s.credentialAlertMu.Unlock()
+	delete(s.credentialAlerted, accountID)
+}
@@ -0,0 +1,107 @@
+/*
+ * 文件作用：账户自定义健康检查探测端点，替代写死的 /v1/models、/api/oauth/usage 等默认检查
+ * 负责功能：
+ *   - 按账户配置的探测 URL 发送 GET 请求（路径拼接在 BaseURL 后）
+ *   - 按平台带上与转发相同的认证头
+ *   - 按期望状态码判断健康（未配置时任意 2xx 视为健康，429 视为限流）
+ * 重要程度：⭐⭐⭐ 一般（避免中转站默认端点异常导致误杀）
+ * 依赖模块：adapter, model
+ */
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"go-aiproxy/internal/model"
+	"go-aiproxy/internal/proxy/adapter"
+)
+
+// checkCustomEndpoint 按账户配置的探测端点检查账号
+func (s *AccountHealthCheckService) checkCustomEndpoint(ctx context.Context, account *model.Account) (bool, string) {
+	probeURL := account.HealthCheckURL
+	if strings.HasPrefix(probeURL, "/") {
+		probeURL = strings.TrimRight(healthCheckBaseURL(account), "/") + probeURL
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", probeURL, nil)
+	if err != nil {
+		return false, fmt.Sprintf("创建请求失败: %v", err)
+	}
+	for k, v := range healthCheckAuthHeaders(account) {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := adapter.GetHTTPClient(account).Do(req)
+	if err != nil {
+		return false, fmt.Sprintf("请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+
+	if account.HealthCheckExpect > 0 {
+		if resp.StatusCode == account.HealthCheckExpect {
+			return true, ""
+		}
+	} else if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return true, ""
+	}
+
+	// 429 表示限流，账号仍然有效
+	if resp.StatusCode == 429 {
+		return true, healthCheckRateLimitedMsg
+	}
+
+	return false, fmt.Sprintf("HTTP %d: %s", resp.StatusCode, truncateMsg(string(body), 200))
+}
+
+// healthCheckBaseURL 探测路径拼接的基础地址：优先 BaseURL，未配置时使用平台官方地址
+func healthCheckBaseURL(account *model.Account) string {
+	switch {
+	case account.BaseURL != "":
+		return account.BaseURL
+	case account.Type == model.AccountTypeAzureOpenAI:
+		return account.AzureEndpoint
+	case account.Platform == model.PlatformClaude:
+		return "https://api.anthropic.com"
+	case account.Platform == model.PlatformGemini:
+		return "https://generativelanguage.googleapis.com"
+	default:
+		return "https://api.openai.com"
+	}
+}
+
+// healthCheckAuthHeaders 探测请求的认证头（与转发一致；Bedrock 需要签名，不带认证头）
+func healthCheckAuthHeaders(account *model.Account) map[string]string {
+	switch {
+	case account.Type == model.AccountTypeBedrock:
+		return nil
+	case account.Type == model.AccountTypeAzureOpenAI:
+		return map[string]string{"api-key": account.APIKey}
+	case account.Platform == model.PlatformGemini:
+		return adapter.GeminiAuthHeaders(account)
+	case account.Platform == model.PlatformClaude:
+		if account.AccessToken != "" {
+			return map[string]string{
+				"Authorization":     "Bearer " + account.AccessToken,
+				"anthropic-version": adapter.DefaultAnthropicVersion,
+				"anthropic-beta":    "oauth-2025-04-20",
+			}
+		}
+		return map[string]string{
+			"x-api-key":         account.APIKey,
+			"anthropic-version": adapter.DefaultAnthropicVersion,
+		}
+	}
+
+	token, _ := account.GetAuthToken()
+	if token == "" {
+		return nil
+	}
+	return map[string]string{"Authorization": "Bearer " + token}
+}
@@ -0,0 +1,191 @@
+/*
+ * 文件作用：健康检查深度探测，发送极小的真实推理请求验证账号
+ * 负责功能：
+ *   - 按账号限频（同一账号间隔内只探测一次）
+ *   - Claude OAuth / OpenAI API Key / Gemini 的 max_tokens=1 推理探测
+ *   - 根据是否拿到合法响应判断账号是否真正可用
+ * 重要程度：⭐⭐⭐ 一般（发现 usage 正常但推理被风控的账号）
+ * 依赖模块：adapter, model
+ */
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go-aiproxy/internal/model"
+	"go-aiproxy/internal/proxy/adapter"
+)
+
+// 深度探测使用的模型（各平台最便宜的模型）
+const (
+	deepProbeClaudeModel = "claude-haiku-4-5-20251001"
+	deepProbeOpenAIModel = "gpt-4.1-nano"
+	deepProbeGeminiModel = "gemini-2.0-flash"
+)
+
+// shouldDeepProbe 判断本次检查是否需要深度探测（开关 + 每账号限频）
+// 返回 true 时同时记录探测时间，探测失败也计入限频，避免反复消耗
+func (s *AccountHealthCheckService) shouldDeepProbe(accountID uint) bool {
+	if !s.configService.GetDeepProbeEnabled() {
+		return false
+	}
+
+	s.deepProbeMu.Lock()
+	defer s.deepProbeMu.Unlock()
+
+	if last, ok := s.lastDeepProbe[accountID]; ok && time.Since(last) < s.configService.GetDeepProbeInterval() {
+		return false
+	}
+	s.lastDeepProbe[accountID] = time.Now()
+	return true
+}
+
+// deepProbe 发送 max_tokens=1 的真实推理请求
+// 返回: (是否健康, 错误信息)；不支持深度探测的账号直接视为健康
+func (s *AccountHealthCheckService) deepProbe(ctx context.Context, account *model.Account) (bool, string) {
+	switch {
+	case account.Type == model.AccountTypeClaudeOfficial && account.AccessToken != "":
+		return s.deepProbeClaudeOAuth(ctx, account)
+	case account.Type == model.AccountTypeOpenAIResponses && account.APIKey != "":
+		return s.deepProbeOpenAI(ctx, account)
+	case (account.Type == model.AccountTypeGemini || account.Type == model.AccountTypeGeminiAPI) &&
+		(account.AccessToken != "" || account.APIKey != ""):
+		return s.deepProbeGemini(ctx, account)
+	default:
+		s.log.Debug("[%s] 账号类型 %s 不支持深度探测，跳过", account.Name, account.Type)
+		return true, ""
+	}
+}
+
+// deepProbeClaudeOAuth Claude OAuth 账号深度探测（/v1/messages）
+func (s *AccountHealthCheckService) deepProbeClaudeOAuth(ctx context.Context, account *model.Account) (bool, string) {
+	payload := map[string]interface{}{
+		"model":      deepProbeClaudeModel,
+		"max_tokens": 1,
+		// OAuth Token 只允许 Claude Code 客户端使用，需要带上对应的 system prompt
+		"system": "You are Claude Code, Anthropic's official CLI for Claude.",
+		"messages": []map[string]string{
+			{"role": "user", "content": "hi"},
+		},
+	}
+
+	headers := map[string]string{
+		"Authorization":     "Bearer " + account.AccessToken,
+		"anthropic-version": "2023-06-01",
+		"anthropic-beta":    "oauth-2025-04-20",
+		"User-Agent":        "claude-cli/2.0.53 (external, cli)",
+	}
+
+	client := adapter.GetSmartHTTPClient(account, "https://api.anthropic.com")
+	return s.doDeepProbe(ctx, account, client, "https://api.anthropic.com/v1/messages", headers, payload, func(body []byte) bool {
+		var resp struct {
+			Type string `json:"type"`
+		}
+		return json.Unmarshal(body, &resp) == nil && resp.Type == "message"
+	})
+}
+
+// deepProbeOpenAI OpenAI API Key 账号深度探测（/v1/chat/completions）
+func (s *AccountHealthCheckService) deepProbeOpenAI(ctx context.Context, account *model.Account) (bool, string) {
+	baseURL := "https://api.openai.com"
+	if account.BaseURL != "" {
+		baseURL = account.BaseURL
+	}
+
+	payload := map[string]interface{}{
+		"model":      deepProbeOpenAIModel,
+		"max_tokens": 1,
+		"messages": []map[string]string{
+			{"role": "user", "content": "hi"},
+		},
+	}
+
+	headers := map[string]string{
+		"Authorization": "Bearer " + account.APIKey,
+	}
+
+	client := adapter.GetSmartHTTPClient(account, "https://api.openai.com")
+	return s.doDeepProbe(ctx, account, client, baseURL+"/v1/chat/completions", headers, payload, func(body []byte) bool {
+		var resp struct {
+			Choices []json.RawMessage `json:"choices"`
+		}
+		return json.Unmarshal(body, &resp) == nil && len(resp.Choices) > 0
+	})
+}
+
+// deepProbeGemini Gemini 账号深度探测（generateContent）
+func (s *AccountHealthCheckService) deepProbeGemini(ctx context.Context, account *model.Account) (bool, string) {
+	baseURL := "https://generativelanguage.googleapis.com"
+	if account.BaseURL != "" {
+		baseURL = account.BaseURL
+	}
+
+	payload := map[string]interface{}{
+		"contents": []map[string]interface{}{
+			{"role": "user", "parts": []map[string]string{{"text": "hi"}}},
+		},
+		"generationConfig": map[string]interface{}{
+			"maxOutputTokens": 1,
+		},
+	}
+
+	headers := adapter.GeminiAuthHeaders(account)
+
+	url := fmt.Sprintf("%s/v1beta/models/%s:generateContent", baseURL, deepProbeGeminiModel)
+	return s.doDeepProbe(ctx, account, adapter.GetHTTPClient(account), url, headers, payload, func(body []byte) bool {
+		var resp struct {
+			Candidates []json.RawMessage `json:"candidates"`
+		}
+		return json.Unmarshal(body, &resp) == nil && len(resp.Candidates) > 0
+	})
+}
+
+// doDeepProbe 发送探测请求并判断结果
+// validate 用于校验 200 响应体是否为合法的推理结果
+func (s *AccountHealthCheckService) doDeepProbe(ctx context.Context, account *model.Account, client *http.Client, url string,
+	headers map[string]string, payload interface{}, validate func(body []byte) bool) (bool, string) {
+	reqBody, err := json.Marshal(payload)
+	if err != nil {
+		return false, fmt.Sprintf("构建探测请求失败: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(reqBody))
+	if err != nil {
+		return false, fmt.Sprintf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, fmt.Sprintf("深度探测请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode == 200 {
+		if validate(body) {
+			s.log.Debug("[%s] 深度探测成功", account.Name)
+			return true, ""
+		}
+		return false, fmt.Sprintf("深度探测返回异常响应: %s", truncateMsg(string(body), 200))
+	}
+
+	// 429 表示限流，账号仍然有效
+	if resp.StatusCode == 429 {
+		s.log.Debug("[%s] 深度探测: 限流中但账号有效", account.Name)
+		return true, healthCheckRateLimitedMsg
+	}
+
+	return false, fmt.Sprintf("深度探测失败 (HTTP %d): %s", resp.StatusCode, truncateMsg(string(body), 200))
+}
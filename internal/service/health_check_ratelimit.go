@@ -0,0 +1,94 @@
+/*
+ * 文件作用：健康检查持续限流识别，429 比例过高的账号标记为限流
+ * 负责功能：
+ *   - 记录观察窗口内每次健康检查是否遇到 429
+ *   - 窗口内 429 比例达到阈值时标记为限流状态，停止调度
+ *   - 限流账号由问题账号探测恢复（探测成功才恢复，仍 429 不恢复）
+ * 重要程度：⭐⭐⭐ 一般（避免持续限流的账号继续接流量）
+ * 依赖模块：model, scheduler
+ */
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"go-aiproxy/internal/model"
+	"go-aiproxy/internal/proxy/scheduler"
+)
+
+// rateLimitMinSamples 触发限流标记所需的最少检查次数，避免一两次偶发 429 就停止调度
+const rateLimitMinSamples = 3
+
+// rateLimitSample 一次健康检查的限流结果
+type rateLimitSample struct {
+	at      time.Time
+	limited bool
+}
+
+// rateLimitDowngradeTypes 支持限流标记的账号类型
+// 只有问题账号探测会覆盖的类型才能标记，否则标记后无法自动恢复（见 GetAccountsNeedingProbe）
+var rateLimitDowngradeTypes = map[string]bool{
+	model.AccountTypeClaudeOfficial:  true,
+	model.AccountTypeOpenAIResponses: true,
+	model.AccountTypeGemini:          true,
+}
+
+// recordRateLimitSample 记录一次健康检查结果，窗口内 429 比例过高时将账号标记为限流
+func (s *AccountHealthCheckService) recordRateLimitSample(account *model.Account, limited bool) {
+	ratio := s.configService.GetRateLimitedDowngradeRatio()
+	if ratio <= 0 || !rateLimitDowngradeTypes[account.Type] {
+		return
+	}
+
+	limitedCount, total := s.appendRateLimitSample(account.ID, limited, s.configService.GetRateLimitedDowngradeWindow())
+	if !limited || total < rateLimitMinSamples || float64(limitedCount) < ratio*float64(total) {
+		return
+	}
+
+	errMsg := fmt.Sprintf("健康检查持续限流 (%d/%d 次返回 429)", limitedCount, total)
+	if err := s.accountRepo.UpdateStatusWithRateLimit(account.ID, model.AccountStatusRateLimited, errMsg, nil); err != nil {
+		s.log.Error("[%s] 标记限流失败: %v", account.Name, err)
+		return
+	}
+
+	// 交给问题账号探测，探测成功后恢复
+	interval := s.configService.GetRateLimitedProbeInitInterval()
+	if err := s.accountRepo.UpdateHealthCheckSchedule(account.ID, time.Now().Add(interval), int(interval.Seconds())); err != nil {
+		s.log.Error("[%s] 更新检测计划失败: %v", account.Name, err)
+	}
+	s.clearRateLimitSamples(account.ID)
+
+	s.log.Warn("[%s] %s，标记为限流", account.Name, errMsg)
+	scheduler.GetScheduler().BroadcastRefresh(account.ID, account.Platform, model.AccountStatusRateLimited)
+}
+
+// appendRateLimitSample 追加样本并丢弃窗口外的旧样本，返回窗口内 429 次数和总次数
+func (s *AccountHealthCheckService) appendRateLimitSample(accountID uint, limited bool, window time.Duration) (int, int) {
+	s.rateLimitMu.Lock()
+	defer s.rateLimitMu.Unlock()
+
+	now := time.Now()
+	samples := append(s.rateLimitSamples[accountID], rateLimitSample{at: now, limited: limited})
+	start := 0
+	for start < len(samples) && now.Sub(samples[start].at) > window {
+		start++
+	}
+	samples = samples[start:]
+	s.rateLimitSamples[accountID] = samples
+
+	limitedCount := 0
+	for _, sample := range samples {
+		if sample.limited {
+			limitedCount++
+		}
+	}
+	return limitedCount, len(samples)
+}
+
+// clearRateLimitSamples 清除账号的限流样本（标记限流后重新统计）
+func (s *AccountHealthCheckService) clearRateLimitSamples(accountID uint) {
+	s.rateLimitMu.Lock()
+	defer s.rateLimitMu.Unlock()
+	delete(s.rateLimitSamples, accountID)
+}
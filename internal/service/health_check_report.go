@@ -0,0 +1,192 @@
+/*
+ * 文件作用：一键检测账户可用性，批量加入新账户后一次性验证哪些能用
+ * 负责功能：
+ *   - 并发对指定（或全部）账户执行一轮健康检查，可选深度探测
+ *   - 返回每个账户的结果明细（是否可用、错误原因、延迟、使用的代理）
+ *   - 默认只读，不改变账户状态；显式要求时按检测结果更新状态
+ * 重要程度：⭐⭐⭐ 一般（管理工具，与后台定时检查相互独立）
+ * 依赖模块：repository, model
+ */
+package service
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go-aiproxy/internal/model"
+)
+
+// checkAllMinConcurrency 一键检测的最小并发（管理员在等待结果，比定时检查更激进）
+const checkAllMinConcurrency = 10
+
+// AccountCheckAllRequest 一键检测请求
+type AccountCheckAllRequest struct {
+	IDs         []uint `json:"ids"`          // 为空时按 platform 检测
+	Platform    string `json:"platform"`     // 为空时检测全部
+	DeepProbe   bool   `json:"deep_probe"`   // 浅层检查通过后追加真实推理探测（不受探测间隔限制，会产生少量费用）
+	ApplyStatus bool   `json:"apply_status"` // 按检测结果更新账户状态，默认只读
+}
+
+// AccountCheckItem 单个账户的检测结果
+type AccountCheckItem struct {
+	ID          uint   `json:"id"`
+	Name        string `json:"name"`
+	Platform    string `json:"platform"`
+	Type        string `json:"type"`
+	Status      string `json:"status"` // 检测前的账户状态
+	Enabled     bool   `json:"enabled"`
+	Checked     bool   `json:"checked"` // 该账户类型没有可用的检查方式时为 false
+	Healthy     bool   `json:"healthy"`
+	RateLimited bool   `json:"rate_limited"`
+	DeepProbed  bool   `json:"deep_probed"`
+	Error       string `json:"error,omitempty"`
+	LatencyMs   int64  `json:"latency_ms"`
+	ProxyID     uint   `json:"proxy_id,omitempty"`
+	ProxyName   string `json:"proxy_name,omitempty"` // 为空表示直连
+}
+
+// AccountCheckReport 一键检测报告
+type AccountCheckReport struct {
+	Items       []AccountCheckItem `json:"items"`
+	Total       int                `json:"total"`
+	Healthy     int                `json:"healthy"`
+	Unhealthy   int                `json:"unhealthy"`
+	Skipped     int                `json:"skipped"` // 没有可用检查方式的账户数
+	DeepProbe   bool               `json:"deep_probe"`
+	ApplyStatus bool               `json:"apply_status"`
+	DurationMs  int64              `json:"duration_ms"`
+	GeneratedAt time.Time          `json:"generated_at"`
+}
+
+// CheckAllAccounts 并发检测账户并返回明细报告，不受后台健康检查开关影响
+func (s *AccountHealthCheckService) CheckAllAccounts(req *AccountCheckAllRequest) (*AccountCheckReport, error) {
+	startTime := time.Now()
+
+	accounts, err := s.accountRepo.GetAccountsForCheckAll(req.IDs, req.Platform)
+	if err != nil {
+		return nil, err
+	}
+
+	defaultProxy, err := GetProxyService().GetDefaultProxy()
+	if err != nil {
+		s.log.Warn("获取默认代理失败: %v", err)
+	}
+
+	items := make([]AccountCheckItem, len(accounts))
+	limiter := s.newHealthCheckLimiter(len(accounts), checkAllMinConcurrency)
+	var wg sync.WaitGroup
+	for i := range accounts {
+		if accounts[i].Proxy == nil && defaultProxy != nil {
+			accounts[i].Proxy = defaultProxy
+		}
+		wg.Add(1)
+		go func(i int, acc *model.Account) {
+			defer wg.Done()
+			release := limiter.acquire(acc)
+			defer release()
+			items[i] = s.checkAccountForReport(acc, req)
+		}(i, &accounts[i])
+	}
+	wg.Wait()
+
+	report := &AccountCheckReport{
+		Items:       items,
+		Total:       len(items),
+		DeepProbe:   req.DeepProbe,
+		ApplyStatus: req.ApplyStatus,
+		GeneratedAt: time.Now(),
+	}
+	for _, item := range items {
+		switch {
+		case !item.Checked:
+			report.Skipped++
+		case item.Healthy:
+			report.Healthy++
+		default:
+			report.Unhealthy++
+		}
+	}
+	// 不可用的排在前面，其余按 ID
+	sort.SliceStable(report.Items, func(i, j int) bool {
+		a, b := report.Items[i], report.Items[j]
+		if a.Healthy != b.Healthy {
+			return !a.Healthy
+		}
+		return a.ID < b.ID
+	})
+	report.DurationMs = time.Since(startTime).Milliseconds()
+
+	s.log.Info("一键检测完成，共 %d 个，可用 %d 个，不可用 %d 个，未检查 %d 个，耗时 %v",
+		report.Total, report.Healthy, report.Unhealthy, report.Skipped, time.Since(startTime))
+	return report, nil
+}
+
+// checkAccountForReport 检测单个账户，只在 ApplyStatus 时更新账户状态
+func (s *AccountHealthCheckService) checkAccountForReport(account *model.Account, req *AccountCheckAllRequest) AccountCheckItem {
+	item := AccountCheckItem{
+		ID:       account.ID,
+		Name:     account.Name,
+		Platform: account.Platform,
+		Type:     account.Type,
+		Status:   account.Status,
+		Enabled:  account.Enabled,
+		Checked:  hasShallowCheck(account),
+	}
+	if account.Proxy != nil {
+		item.ProxyID = account.Proxy.ID
+		item.ProxyName = account.Proxy.Name
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.healthCheckTimeout(account))
+	defer cancel()
+
+	start := time.Now()
+	result, errMsg := s.shallowCheck(ctx, account)
+	// 显式要求深度探测时不受探测间隔限制，限流中的账号探测也只会拿到 429，跳过
+	if req.DeepProbe && result == healthCheckHealthy && hasDeepProbe(account) {
+		healthy, probeMsg := s.deepProbe(ctx, account)
+		result, errMsg = toHealthCheckResult(healthy, probeMsg), probeMsg
+		item.DeepProbed = true
+		item.Checked = true
+	}
+	item.LatencyMs = time.Since(start).Milliseconds()
+
+	item.Healthy = result.Healthy()
+	item.RateLimited = result == healthCheckRateLimited
+	item.Error = errMsg
+
+	if req.ApplyStatus && item.Checked {
+		s.applyManualCheckResult(account, result, errMsg)
+	}
+	return item
+}
+
+// hasShallowCheck 账户是否有可用的浅层检查方式（与 shallowCheck 的分支保持一致）
+func hasShallowCheck(account *model.Account) bool {
+	if account.HealthCheckURL != "" {
+		return true
+	}
+	switch account.Type {
+	case model.AccountTypeClaudeOfficial, model.AccountTypeOpenAIResponses,
+		model.AccountTypeGemini, model.AccountTypeGeminiAPI:
+		return true
+	case model.AccountTypeClaudeConsole:
+		return account.APIKeys != ""
+	}
+	return false
+}
+
+// hasDeepProbe 账户是否支持深度探测（与 deepProbe 的分支保持一致）
+func hasDeepProbe(account *model.Account) bool {
+	switch account.Type {
+	case model.AccountTypeClaudeOfficial:
+		return account.AccessToken != ""
+	case model.AccountTypeOpenAIResponses:
+		return account.APIKey != ""
+	case model.AccountTypeGemini, model.AccountTypeGeminiAPI:
+		return account.AccessToken != "" || account.APIKey != ""
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+/*
+ * 文件作用：服务启动后的账号就绪预热探测，避免第一批请求撞上明显不可用的账号
+ * 负责功能：
+ *   - 预热调度器账户缓存
+ *   - 并发、短超时地对正常账号做一轮浅层检查（不做深度探测）
+ *   - 探测失败的账号写入临时不可用标记（调度时只作兜底），不修改账号状态
+ * 重要程度：⭐⭐⭐ 一般（冷启动体验）
+ * 依赖模块：repository, cache, scheduler
+ */
+package service
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-aiproxy/internal/cache"
+	"go-aiproxy/internal/model"
+	"go-aiproxy/internal/proxy/scheduler"
+)
+
+// startupProbeMinConcurrency 启动探测的最小并发（比常规检查更激进，尽快完成）
+const startupProbeMinConcurrency = 10
+
+// RunStartupProbe 在后台执行一轮启动预热探测（未开启时直接返回），不阻塞服务启动
+func (s *AccountHealthCheckService) RunStartupProbe() {
+	if !s.configService.GetStartupProbeEnabled() {
+		return
+	}
+	go s.doStartupProbe()
+}
+
+// doStartupProbe 启动预热探测：账号有效但本轮探测失败时标记临时不可用，由后续常规检查决定是否改状态
+func (s *AccountHealthCheckService) doStartupProbe() {
+	startTime := time.Now()
+
+	// 先加载调度器账户缓存，第一批请求不再触发冷启动刷新
+	if err := scheduler.GetScheduler().Refresh(); err != nil {
+		s.log.Warn("启动预热：加载账户缓存失败: %v", err)
+	}
+
+	accounts, err := s.accountRepo.GetAccountsForHealthCheck()
+	if err != nil {
+		s.log.Error("启动预热：获取账号列表失败: %v", err)
+		return
+	}
+	if len(accounts) == 0 {
+		return
+	}
+
+	defaultProxy, err := GetProxyService().GetDefaultProxy()
+	if err != nil {
+		s.log.Warn("获取默认代理失败: %v", err)
+	}
+
+	timeout := s.configService.GetStartupProbeTimeout()
+	marker := cache.GetUnavailableMarker()
+	limiter := s.newHealthCheckLimiter(len(accounts), startupProbeMinConcurrency)
+	s.log.Info("启动预热：探测 %d 个账号，并发数 %d，超时 %v", len(accounts), cap(limiter.global), timeout)
+
+	var failedCount int64
+	var wg sync.WaitGroup
+	for _, account := range accounts {
+		if account.Proxy == nil && defaultProxy != nil {
+			account.Proxy = defaultProxy
+		}
+		wg.Add(1)
+		go func(acc model.Account) {
+			defer wg.Done()
+			release := limiter.acquire(&acc)
+			defer release()
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			result, errMsg := s.shallowCheck(ctx, &acc)
+			if result.Healthy() {
+				return
+			}
+			atomic.AddInt64(&failedCount, 1)
+			marker.Mark(acc.ID, "启动预热探测失败: "+truncateMsg(errMsg, 200), 0)
+			s.log.Warn("[%s] 启动预热探测失败，临时标记为不可用: %s", acc.Name, truncateMsg(errMsg, 200))
+		}(account)
+	}
+	wg.Wait()
+
+	s.log.Info("启动预热完成，共探测 %d 个，失败 %d 个，耗时 %v", len(accounts), failedCount, time.Since(startTime))
+}
@@ -0,0 +1,160 @@
+/*
+ * 文件作用：小时用量聚合，为后台提供当日小时级的请求数/token/费用趋势
+ * 负责功能：
+ *   - 记账时在内存按小时累计，定期批量写库（避免每个请求都更新同一行造成热点锁）
+ *   - 写库失败的增量并回内存，下次继续写
+ *   - 查询某天 24 个小时的用量（含本实例尚未写库的部分）
+ *   - 定期清理超过保留期（7 天）的记录
+ * 重要程度：⭐⭐ 辅助（统计趋势）
+ * 依赖模块：model, repository, logger
+ */
+package service
+
+import (
+	"sync"
+	"time"
+
+	"go-aiproxy/internal/model"
+	"go-aiproxy/internal/repository"
+	"go-aiproxy/pkg/logger"
+)
+
+const (
+	hourlyUsageFlushInterval   = 10 * time.Second // 内存累计写库间隔
+	hourlyUsageCleanupInterval = time.Hour        // 过期记录清理间隔
+)
+
+// hourlyUsageKey 小时用量的内存累计键
+type hourlyUsageKey struct {
+	date string
+	hour int
+}
+
+// HourlyUsageAggregator 小时用量聚合器
+type HourlyUsageAggregator struct {
+	repo *repository.HourlyUsageRepository
+	log  *logger.Logger
+
+	mu          sync.Mutex
+	pending     map[hourlyUsageKey]*model.HourlyUsage // 尚未写库的增量
+	lastCleanup time.Time
+}
+
+var (
+	hourlyUsageAggregator     *HourlyUsageAggregator
+	hourlyUsageAggregatorOnce sync.Once
+)
+
+// GetHourlyUsageAggregator 获取小时用量聚合器单例，首次获取时启动定期写库协程
+func GetHourlyUsageAggregator() *HourlyUsageAggregator {
+	hourlyUsageAggregatorOnce.Do(func() {
+		hourlyUsageAggregator = &HourlyUsageAggregator{
+			repo:    repository.NewHourlyUsageRepository(),
+			log:     logger.GetLogger("usage"),
+			pending: make(map[hourlyUsageKey]*model.HourlyUsage),
+		}
+		go hourlyUsageAggregator.flushLoop()
+	})
+	return hourlyUsageAggregator
+}
+
+// Add 累计一次请求的用量（按请求时间归属小时，与每日汇总一致）
+func (a *HourlyUsageAggregator) Add(log *model.RequestLog) {
+	t := log.CreatedAt
+	if t.IsZero() {
+		t = time.Now()
+	}
+	a.merge(&model.HourlyUsage{
+		Date:                     t.Format("2006-01-02"),
+		Hour:                     t.Hour(),
+		RequestCount:             1,
+		InputTokens:              int64(log.InputTokens),
+		OutputTokens:             int64(log.OutputTokens),
+		CacheCreationInputTokens: int64(log.CacheCreationInputTokens),
+		CacheReadInputTokens:     int64(log.CacheReadInputTokens),
+		TotalTokens:              int64(log.TotalTokens),
+		TotalCost:                log.TotalCost,
+		AccountCost:              log.AccountCost,
+	})
+}
+
+// merge 把增量并入内存累计
+func (a *HourlyUsageAggregator) merge(usage *model.HourlyUsage) {
+	key := hourlyUsageKey{date: usage.Date, hour: usage.Hour}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if existing, ok := a.pending[key]; ok {
+		existing.Add(usage)
+		return
+	}
+	a.pending[key] = usage
+}
+
+// Flush 把内存累计写库，失败的增量并回内存等待下次写入（服务关闭前调用，避免丢失最后一段用量）
+func (a *HourlyUsageAggregator) Flush() {
+	a.mu.Lock()
+	pending := a.pending
+	a.pending = make(map[hourlyUsageKey]*model.HourlyUsage)
+	a.mu.Unlock()
+
+	for _, usage := range pending {
+		if err := a.repo.IncrementUsage(usage); err != nil {
+			a.log.Error("小时用量写库失败 | 时段: %s %02d:00 | 原因: %v", usage.Date, usage.Hour, err)
+			a.merge(usage)
+		}
+	}
+}
+
+// flushLoop 定期写库并清理过期记录
+func (a *HourlyUsageAggregator) flushLoop() {
+	ticker := time.NewTicker(hourlyUsageFlushInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		a.Flush()
+		if time.Since(a.lastCleanup) >= hourlyUsageCleanupInterval {
+			a.lastCleanup = time.Now()
+			a.cleanup()
+		}
+	}
+}
+
+// cleanup 删除超过保留期的记录
+func (a *HourlyUsageAggregator) cleanup() {
+	cutoff := time.Now().AddDate(0, 0, -(model.HourlyUsageRetentionDays - 1)).Format("2006-01-02")
+	deleted, err := a.repo.DeleteBefore(cutoff)
+	if err != nil {
+		a.log.Error("清理过期小时用量失败: %v", err)
+		return
+	}
+	if deleted > 0 {
+		a.log.Info("已清理过期小时用量 | 早于: %s | 条数: %d", cutoff, deleted)
+	}
+}
+
+// GetHourlyUsage 获取某天 24 个小时的用量（没有请求的小时为 0，含本实例尚未写库的部分）
+func (a *HourlyUsageAggregator) GetHourlyUsage(date string) ([]model.HourlyUsage, error) {
+	rows, err := a.repo.ListByDate(date)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]model.HourlyUsage, 24)
+	for hour := range points {
+		points[hour] = model.HourlyUsage{Date: date, Hour: hour}
+	}
+	for i := range rows {
+		if rows[i].Hour >= 0 && rows[i].Hour < 24 {
+			points[rows[i].Hour].Add(&rows[i])
+		}
+	}
+
+	a.mu.Lock()
+	for key, usage := range a.pending {
+		if key.date == date {
+			points[key.hour].Add(usage)
+		}
+	}
+	a.mu.Unlock()
+
+	return points, nil
+}
@@ -0,0 +1,191 @@
+/*
+ * 文件作用：请求日志转发服务，把脱敏后的请求日志异步推送到外部日志系统
+ * 负责功能：
+ *   - 独立发送队列（队列满时丢弃，不阻塞代理主流程）
+ *   - 多协程 POST JSON 到配置的 webhook
+ *   - 失败重试（指数退避），超过次数后丢弃
+ *   - 只转发统计字段，不包含请求头、请求体和密钥
+ * 重要程度：⭐⭐⭐ 一般（审计日志对接）
+ * 依赖模块：config, model, logger
+ */
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-aiproxy/internal/config"
+	"go-aiproxy/internal/model"
+	"go-aiproxy/pkg/logger"
+)
+
+// ForwardedLog 转发的日志格式（已脱敏）
+type ForwardedLog struct {
+	RequestID                string    `json:"request_id,omitempty"`
+	Timestamp                time.Time `json:"timestamp"`
+	UserID                   uint      `json:"user_id"`
+	APIKeyID                 uint      `json:"api_key_id"`
+	AccountID                uint      `json:"account_id"`
+	Platform                 string    `json:"platform"`
+	Model                    string    `json:"model"`
+	Path                     string    `json:"path"`
+	ClientIP                 string    `json:"client_ip"`
+	InputTokens              int       `json:"input_tokens"`
+	OutputTokens             int       `json:"output_tokens"`
+	CacheCreationInputTokens int       `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int       `json:"cache_read_input_tokens"`
+	ThinkingTokens           int       `json:"thinking_tokens"`
+	TotalTokens              int       `json:"total_tokens"`
+	TotalCost                float64   `json:"total_cost"`
+	LatencyMs                int64     `json:"latency_ms"`
+	StatusCode               int       `json:"status_code"`
+	UpstreamStatusCode       int       `json:"upstream_status_code"`
+	Success                  bool      `json:"success"`
+}
+
+// NewForwardedLog 从请求日志构建转发日志，只保留统计字段
+func NewForwardedLog(requestID string, log *model.RequestLog) *ForwardedLog {
+	entry := &ForwardedLog{
+		RequestID:                requestID,
+		Timestamp:                log.CreatedAt,
+		AccountID:                log.AccountID,
+		Platform:                 log.Platform,
+		Model:                    log.Model,
+		Path:                     log.Path,
+		ClientIP:                 log.RequestIP,
+		InputTokens:              log.InputTokens,
+		OutputTokens:             log.OutputTokens,
+		CacheCreationInputTokens: log.CacheCreationInputTokens,
+		CacheReadInputTokens:     log.CacheReadInputTokens,
+		ThinkingTokens:           log.ThinkingTokens,
+		TotalTokens:              log.TotalTokens,
+		TotalCost:                log.TotalCost,
+		LatencyMs:                log.Duration,
+		StatusCode:               log.StatusCode,
+		UpstreamStatusCode:       log.UpstreamStatusCode,
+		Success:                  log.Success,
+	}
+	if log.UserID != nil {
+		entry.UserID = *log.UserID
+	}
+	if log.APIKeyID != nil {
+		entry.APIKeyID = *log.APIKeyID
+	}
+	return entry
+}
+
+// LogForwarder 日志转发器
+type LogForwarder struct {
+	cfg    config.LogForwardConfig
+	queue  chan *ForwardedLog
+	client *http.Client
+	log    *logger.Logger
+
+	dropped int64 // 丢弃计数（队列满或重试耗尽）
+}
+
+var (
+	logForwarder     *LogForwarder
+	logForwarderOnce sync.Once
+)
+
+// GetLogForwarder 获取日志转发器单例，未启用时返回 nil
+func GetLogForwarder() *LogForwarder {
+	logForwarderOnce.Do(func() {
+		if config.Cfg == nil || !config.Cfg.LogForward.Enabled || config.Cfg.LogForward.URL == "" {
+			return
+		}
+		cfg := config.Cfg.LogForward
+		logForwarder = &LogForwarder{
+			cfg:    cfg,
+			queue:  make(chan *ForwardedLog, cfg.GetQueueSize()),
+			client: &http.Client{Timeout: time.Duration(cfg.GetTimeout()) * time.Second},
+			log:    logger.GetLogger("log_forward"),
+		}
+		for i := 0; i < cfg.GetWorkers(); i++ {
+			go logForwarder.worker()
+		}
+	})
+	return logForwarder
+}
+
+// Forward 提交日志到发送队列（非阻塞），转发器未启用时直接忽略
+func (f *LogForwarder) Forward(entry *ForwardedLog) {
+	if f == nil {
+		return
+	}
+	select {
+	case f.queue <- entry:
+	default:
+		f.drop("发送队列已满")
+	}
+}
+
+// worker 从队列取日志并发送
+func (f *LogForwarder) worker() {
+	for entry := range f.queue {
+		f.send(entry)
+	}
+}
+
+// send 发送单条日志，失败按指数退避重试，超过次数后丢弃
+func (f *LogForwarder) send(entry *ForwardedLog) {
+	body, err := json.Marshal(entry)
+	if err != nil {
+		f.drop(fmt.Sprintf("序列化失败: %v", err))
+		return
+	}
+
+	maxRetries := f.cfg.GetMaxRetries()
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		err = f.post(body)
+		if err == nil {
+			return
+		}
+		if attempt >= maxRetries {
+			break
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	f.drop(fmt.Sprintf("重试 %d 次后仍失败: %v", maxRetries, err))
+}
+
+// post 执行一次 POST
+func (f *LogForwarder) post(body []byte) error {
+	req, err := http.NewRequest("POST", f.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if f.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.cfg.Token)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// drop 丢弃日志并计数，每 100 条输出一次警告避免刷屏
+func (f *LogForwarder) drop(reason string) {
+	dropped := atomic.AddInt64(&f.dropped, 1)
+	if dropped == 1 || dropped%100 == 0 {
+		f.log.Warn("请求日志转发丢弃 | 原因: %s | 累计丢弃: %d", reason, dropped)
+	}
+}
@@ -99,6 +99,15 @@ func (s *OAuthAuthService) ReauthorizeWithSessionKey(ctx context.Context, accoun
 	return tokenData, nil
 }
 
+// VerifySessionKey 轻量校验 SessionKey 是否仍有效（只查询组织信息，不执行 OAuth 授权）
+func (s *OAuthAuthService) VerifySessionKey(ctx context.Context, account *model.Account) error {
+	if account.SessionKey == "" {
+		return fmt.Errorf("账号没有 SessionKey")
+	}
+	_, err := s.getOrganizationInfo(ctx, account.SessionKey, s.getProxyConfig(account))
+	return err
+}
+
 // AccountBannedError 账号被封禁错误
 type AccountBannedError struct {
 	AccountName string
@@ -3,8 +3,13 @@
  * 负责功能：
  *   - Token费用计算
  *   - 模型价格查询
- *   - 缓存Token特殊定价
+ *   - 缓存Token特殊定价（Gemini 未配置缓存价时按输入价 25%）
+ *   - 思考Token（extended thinking）单独定价
+ *   - 长上下文（1M context）分段定价
  *   - 费率倍率应用
+ *   - 账户成本（原始 token 按模型单价，不含用户倍率）
+ *   - 批处理（Message Batches）折扣
+ *   - Embedding 模型按输入 token 计价（无输出）
  *   - 费用明细分解
  * 重要程度：⭐⭐⭐⭐ 重要（计费核心）
  * 依赖模块：repository, model
@@ -37,6 +42,10 @@ type TokenUsage struct {
 	OutputTokens             int
 	CacheCreationInputTokens int
 	CacheReadInputTokens     int
+	ThinkingTokens           int // 思考 token，包含在 OutputTokens 中
+	// ContextTokens 原始输入上下文长度（未乘倍率），用于判断长上下文档位
+	// 为 0 时按 InputTokens + 缓存 token 计算
+	ContextTokens int
 }
 
 // CostBreakdown 费用明细
@@ -45,9 +54,11 @@ type CostBreakdown struct {
 	OutputCost      float64 `json:"output_cost"`       // 输出费用
 	CacheCreateCost float64 `json:"cache_create_cost"` // 缓存创建费用
 	CacheReadCost   float64 `json:"cache_read_cost"`   // 缓存读取费用
+	ThinkingCost    float64 `json:"thinking_cost"`     // 思考费用（不包含在 OutputCost 中）
 	TotalCost       float64 `json:"total_cost"`        // 总费用（已计算倍率）
 	BaseCost        float64 `json:"base_cost"`         // 基础费用（未计算倍率）
 	PriceRate       float64 `json:"price_rate"`        // 使用的费率倍率
+	LongContext     bool    `json:"long_context"`      // 是否命中长上下文档位
 }
 
 // GetModelPricing 获取模型定价
@@ -92,6 +103,31 @@ func (s *PricingService) CalculateCost(ctx context.Context, modelName string, us
 	return s.CalculateCostWithModel(aiModel, usage, priceRate), nil
 }
 
+// CalculateBatchCost 计算批处理请求费用（在 priceRate 基础上叠加批处理折扣系数）
+func (s *PricingService) CalculateBatchCost(ctx context.Context, modelName string, usage *TokenUsage, priceRate float64) (*CostBreakdown, error) {
+	discount := GetConfigService().GetBatchPriceDiscount()
+	if discount < 0 {
+		discount = 1
+	}
+	return s.CalculateCost(ctx, modelName, usage, priceRate*discount)
+}
+
+// CalculateAccountCost 计算账户成本（付给上游的费用）
+// usage 为上游返回的原始 token，按模型单价计算，不叠加用户倍率和截断系数；批处理请求上游同样有折扣
+func (s *PricingService) CalculateAccountCost(ctx context.Context, modelName string, usage *TokenUsage, isBatch bool) (float64, error) {
+	var costBreakdown *CostBreakdown
+	var err error
+	if isBatch {
+		costBreakdown, err = s.CalculateBatchCost(ctx, modelName, usage, 1.0)
+	} else {
+		costBreakdown, err = s.CalculateCost(ctx, modelName, usage, 1.0)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return costBreakdown.TotalCost, nil
+}
+
 // CalculateCostWithModel 使用已有的模型定价计算费用
 func (s *PricingService) CalculateCostWithModel(aiModel *model.AIModel, usage *TokenUsage, priceRate float64) *CostBreakdown {
 	// 费率倍率为0表示免费
@@ -101,17 +137,32 @@ func (s *PricingService) CalculateCostWithModel(aiModel *model.AIModel, usage *T
 		}
 	}
 
+	// 思考 token 包含在输出 token 中，拆出来单独计价（未配置思考价格时按输出价格）
+	thinkingTokens := usage.ThinkingTokens
+	if thinkingTokens > usage.OutputTokens {
+		thinkingTokens = usage.OutputTokens
+	}
+	thinkingPrice := aiModel.ThinkingPrice
+	if thinkingPrice <= 0 {
+		thinkingPrice = aiModel.OutputPrice
+	}
+
+	// 长上下文档位：输入上下文超过阈值时整次请求按倍数计价
+	inputMultiplier, outputMultiplier, longContext := longContextMultipliers(aiModel, usage)
+
 	// 计算基础费用（价格单位是 $/1M tokens）
-	inputCost := float64(usage.InputTokens) * aiModel.InputPrice / 1000000
-	outputCost := float64(usage.OutputTokens) * aiModel.OutputPrice / 1000000
-	cacheCreateCost := float64(usage.CacheCreationInputTokens) * aiModel.CacheCreatePrice / 1000000
-	cacheReadCost := float64(usage.CacheReadInputTokens) * aiModel.CacheReadPrice / 1000000
+	inputCost := float64(usage.InputTokens) * aiModel.InputPrice * inputMultiplier / 1000000
+	outputCost := float64(usage.OutputTokens-thinkingTokens) * aiModel.OutputPrice * outputMultiplier / 1000000
+	thinkingCost := float64(thinkingTokens) * thinkingPrice * outputMultiplier / 1000000
+	cacheCreateCost := float64(usage.CacheCreationInputTokens) * aiModel.CacheCreatePrice * inputMultiplier / 1000000
+	cacheReadCost := float64(usage.CacheReadInputTokens) * cacheReadPrice(aiModel) * inputMultiplier / 1000000
 
-	baseCost := inputCost + outputCost + cacheCreateCost + cacheReadCost
+	baseCost := inputCost + outputCost + thinkingCost + cacheCreateCost + cacheReadCost
 
 	// 应用费率倍率
 	finalInputCost := inputCost * priceRate
 	finalOutputCost := outputCost * priceRate
+	finalThinkingCost := thinkingCost * priceRate
 	finalCacheCreateCost := cacheCreateCost * priceRate
 	finalCacheReadCost := cacheReadCost * priceRate
 	totalCost := baseCost * priceRate
@@ -119,12 +170,45 @@ func (s *PricingService) CalculateCostWithModel(aiModel *model.AIModel, usage *T
 	return &CostBreakdown{
 		InputCost:       finalInputCost,
 		OutputCost:      finalOutputCost,
+		ThinkingCost:    finalThinkingCost,
 		CacheCreateCost: finalCacheCreateCost,
 		CacheReadCost:   finalCacheReadCost,
 		TotalCost:       totalCost,
 		BaseCost:        baseCost,
 		PriceRate:       priceRate,
+		LongContext:     longContext,
+	}
+}
+
+// geminiCacheReadRatio Gemini 缓存命中 token 相对输入价格的比例（Google 缓存按输入价 25% 计费）
+const geminiCacheReadRatio = 0.25
+
+// cacheReadPrice 缓存读取单价：Gemini 模型未单独配置时按输入价格的 25%
+func cacheReadPrice(aiModel *model.AIModel) float64 {
+	if aiModel.CacheReadPrice <= 0 && aiModel.Platform == model.PlatformGemini {
+		return aiModel.InputPrice * geminiCacheReadRatio
+	}
+	return aiModel.CacheReadPrice
+}
+
+// longContextMultipliers 返回输入/输出价格倍数及是否命中长上下文档位
+// 与 Anthropic 1M context 计费一致：输入（含缓存）超过阈值时，整次请求的输入和输出都按高价计费
+func longContextMultipliers(aiModel *model.AIModel, usage *TokenUsage) (float64, float64, bool) {
+	if aiModel.LongContextThreshold <= 0 || aiModel.LongContextInputMultiplier <= 0 {
+		return 1, 1, false
+	}
+	contextTokens := usage.ContextTokens
+	if contextTokens <= 0 {
+		contextTokens = usage.InputTokens + usage.CacheCreationInputTokens + usage.CacheReadInputTokens
+	}
+	if contextTokens <= aiModel.LongContextThreshold {
+		return 1, 1, false
+	}
+	outputMultiplier := aiModel.LongContextOutputMultiplier
+	if outputMultiplier <= 0 {
+		outputMultiplier = aiModel.LongContextInputMultiplier
 	}
+	return aiModel.LongContextInputMultiplier, outputMultiplier, true
 }
 
 // GetAllModels 获取所有模型定价
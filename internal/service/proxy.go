@@ -192,3 +192,8 @@ func (s *ProxyService) UpdateTestStatus(id uint, status string, latency int, err
 	s.log.Info("更新代理测试状态: ID=%d, status=%s, latency=%dms", id, status, latency)
 	return nil
 }
+
+// UpdateActiveFallback 更新代理当前生效节点（0 主代理，n 第 n 个备用代理）
+func (s *ProxyService) UpdateActiveFallback(id uint, active int) error {
+	return repository.DB.Model(&model.Proxy{}).Where("id = ?", id).Update("active_fallback", active).Error
+}
@@ -0,0 +1,134 @@
+/*
+ * 文件作用：请求重放服务，用于排查客户端报错时重新发送历史请求
+ * 负责功能：
+ *   - 读取请求日志中保存的请求体和请求头
+ *   - 选择指定账户或原账户，使用账户当前凭证重新发送
+ *   - 返回上游原始响应，不计入计费统计
+ * 重要程度：⭐⭐ 辅助（排查调试）
+ * 依赖模块：repository, adapter, model
+ */
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-aiproxy/internal/proxy/adapter"
+	"go-aiproxy/internal/repository"
+	"go-aiproxy/pkg/logger"
+)
+
+// replayTimeout 重放请求超时时间
+const replayTimeout = 5 * time.Minute
+
+// truncatedBodySuffix 请求体超过 64KB 被截断时的后缀（见 handler.SetRequestDetails）
+const truncatedBodySuffix = "...[truncated]"
+
+// redactedHeaderValue 脱敏后的请求头值（见 handler.filterSensitiveHeaders）
+const redactedHeaderValue = "[REDACTED]"
+
+// RequestReplayService 请求重放服务
+type RequestReplayService struct {
+	logRepo     *repository.RequestLogRepository
+	accountRepo *repository.AccountRepository
+	log         *logger.Logger
+}
+
+// NewRequestReplayService 创建请求重放服务
+func NewRequestReplayService() *RequestReplayService {
+	return &RequestReplayService{
+		logRepo:     repository.NewRequestLogRepository(),
+		accountRepo: repository.NewAccountRepository(),
+		log:         logger.GetLogger("replay"),
+	}
+}
+
+// ReplayResult 重放结果
+type ReplayResult struct {
+	LogID       uint                    `json:"log_id"`
+	AccountID   uint                    `json:"account_id"`
+	AccountName string                  `json:"account_name"`
+	AccountType string                  `json:"account_type"`
+	Model       string                  `json:"model"`
+	Response    *adapter.ReplayResponse `json:"response"`
+}
+
+// Replay 重放请求日志，accountID 为 0 时使用原账户
+// 只返回上游响应，不写请求日志、不计费
+func (s *RequestReplayService) Replay(logID, accountID uint) (*ReplayResult, error) {
+	requestLog, err := s.logRepo.GetByID(logID)
+	if err != nil {
+		return nil, errors.New("请求日志不存在")
+	}
+	if requestLog.RequestBody == "" {
+		return nil, errors.New("该日志未保存请求体，无法重放")
+	}
+	if strings.HasSuffix(requestLog.RequestBody, truncatedBodySuffix) {
+		return nil, errors.New("该日志的请求体超过 64KB 已被截断，无法重放")
+	}
+
+	if accountID == 0 {
+		accountID = requestLog.AccountID
+	}
+	if accountID == 0 {
+		return nil, errors.New("日志未记录账户，请指定重放账户")
+	}
+	account, err := s.accountRepo.GetByID(accountID)
+	if err != nil {
+		return nil, errors.New("账户不存在")
+	}
+	// 请求体按原平台格式保存，跨平台重放上游无法识别
+	if requestLog.Platform != "" && account.Platform != requestLog.Platform {
+		return nil, fmt.Errorf("账户平台 %s 与日志平台 %s 不一致", account.Platform, requestLog.Platform)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), replayTimeout)
+	defer cancel()
+
+	s.log.Info("请求重放 | LogID: %d | AccountID: %d | AccountName: %s | Model: %s",
+		logID, account.ID, account.Name, requestLog.Model)
+
+	resp, err := adapter.Replay(ctx, account, &adapter.ReplayRequest{
+		Body:    []byte(requestLog.RequestBody),
+		Model:   requestLog.Model,
+		Headers: replayHeaders(requestLog.RequestHeaders),
+	})
+	if err != nil {
+		s.log.Warn("请求重放失败 | LogID: %d | AccountID: %d | Error: %v", logID, account.ID, err)
+		return nil, err
+	}
+
+	s.log.Info("请求重放完成 | LogID: %d | AccountID: %d | StatusCode: %d | Duration: %dms",
+		logID, account.ID, resp.StatusCode, resp.Duration)
+
+	return &ReplayResult{
+		LogID:       logID,
+		AccountID:   account.ID,
+		AccountName: account.Name,
+		AccountType: account.Type,
+		Model:       requestLog.Model,
+		Response:    resp,
+	}, nil
+}
+
+// replayHeaders 解析日志中的请求头，去掉已脱敏的头（认证使用账户当前凭证）
+func replayHeaders(headersJSON string) map[string]string {
+	headers := make(map[string]string)
+	if headersJSON == "" {
+		return headers
+	}
+	var logged map[string]string
+	if err := json.Unmarshal([]byte(headersJSON), &logged); err != nil {
+		return headers
+	}
+	for key, value := range logged {
+		if value != redactedHeaderValue {
+			headers[key] = value
+		}
+	}
+	return headers
+}
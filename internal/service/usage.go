@@ -4,10 +4,10 @@
  *   - 用户/API Key使用量统计
  *   - 每日/月度使用汇总
  *   - 按模型使用统计
- *   - 使用记录写入
- *   - 账户费用统计
+ *   - 使用记录写入（可选推送到计费 webhook，见 billing_webhook.go）
+ *   - 账户费用统计（每日预算超限停用）
  * 重要程度：⭐⭐⭐⭐ 重要（计费统计核心）
- * 依赖模块：repository, model
+ * 依赖模块：repository, model, scheduler
  */
 package service
 
@@ -17,7 +17,9 @@ import (
 	"time"
 
 	"go-aiproxy/internal/model"
+	"go-aiproxy/internal/proxy/scheduler"
 	"go-aiproxy/internal/repository"
+	"go-aiproxy/pkg/logger"
 )
 
 // UsageService 使用统计服务（直接写入 MySQL）
@@ -74,7 +76,13 @@ func (s *UsageService) RecordRequest(ctx context.Context, userID, apiKeyID uint,
 	now := time.Now()
 
 	// 1. 更新每日使用统计（UPSERT 到 daily_usage 表）
+	// 按请求时间归属日期，与 request_logs 一致（异步写入跨零点时不会记到第二天）
+	date := now.Format("2006-01-02")
+	if !log.CreatedAt.IsZero() {
+		date = log.CreatedAt.Format("2006-01-02")
+	}
 	dailyUsage := &model.DailyUsage{
+		Date:                     date,
 		RequestCount:             1,
 		InputTokens:              int64(log.InputTokens),
 		OutputTokens:             int64(log.OutputTokens),
@@ -86,6 +94,7 @@ func (s *UsageService) RecordRequest(ctx context.Context, userID, apiKeyID uint,
 		CacheCreateCost:          log.CacheCreateCost,
 		CacheReadCost:            log.CacheReadCost,
 		TotalCost:                log.TotalCost,
+		AccountCost:              log.AccountCost,
 	}
 
 	if err := s.dailyUsageRepo.IncrementUsage(userID, log.Model, dailyUsage); err != nil {
@@ -105,6 +114,7 @@ func (s *UsageService) RecordRequest(ctx context.Context, userID, apiKeyID uint,
 		CacheReadInputTokens:     log.CacheReadInputTokens,
 		TotalTokens:              log.TotalTokens,
 		TotalCost:                log.TotalCost,
+		LongContext:              log.LongContext,
 		RequestTime:              now,
 	}
 
@@ -112,15 +122,34 @@ func (s *UsageService) RecordRequest(ctx context.Context, userID, apiKeyID uint,
 		return err
 	}
 
+	// 3. 累计小时用量（内存累计，定期写库）
+	GetHourlyUsageAggregator().Add(log)
+
+	// 4. 推送到外部计费系统（未启用时忽略）
+	GetBillingWebhook().Publish(NewBillingEvent(userID, apiKeyID, log))
+
 	return nil
 }
 
-// IncrementAccountCost 增加账户费用（直接更新 MySQL accounts 表）
+// IncrementAccountCost 增加账户费用（直接更新 MySQL accounts 表），cost 为账户成本（原始 token 按模型单价），不含用户倍率
+// 账户配置了每日预算且当日费用达到预算时，标记为费用超限并移出调度，次日自动恢复
 func (s *UsageService) IncrementAccountCost(ctx context.Context, accountID uint, cost float64) error {
 	if accountID == 0 {
 		return nil
 	}
-	return s.accountRepo.IncrementTotalCost(accountID, cost)
+	if err := s.accountRepo.IncrementTotalCost(accountID, cost); err != nil {
+		return err
+	}
+
+	limited, err := s.accountRepo.MarkCostLimitedIfOverBudget(accountID)
+	if err != nil {
+		return err
+	}
+	if limited {
+		logger.GetLogger("usage").Warn("账户当日费用达到每日预算，暂停调度至次日 | AccountID: %d", accountID)
+		scheduler.GetScheduler().BroadcastRefresh(accountID, "", model.AccountStatusCostLimited)
+	}
+	return nil
 }
 
 // GetAccountCost 获取账户总费用
@@ -137,7 +166,7 @@ func (s *UsageService) GetAccountsCost(ctx context.Context, accountIDs []uint) (
 	return s.accountRepo.GetTotalCostByIDs(accountIDs)
 }
 
-// GetAccountDailyCost 获取账户某天的费用（从 request_logs 聚合）
+// GetAccountDailyCost 获取账户某天的费用（从 request_logs 聚合账户成本）
 func (s *UsageService) GetAccountDailyCost(ctx context.Context, accountID uint, date string) (float64, error) {
 	startTime, err := time.Parse("2006-01-02", date)
 	if err != nil {
@@ -149,7 +178,7 @@ func (s *UsageService) GetAccountDailyCost(ctx context.Context, accountID uint,
 		TotalCost float64
 	}
 	err = repository.DB.Model(&model.RequestLog{}).
-		Select("COALESCE(SUM(total_cost), 0) as total_cost").
+		Select("COALESCE(SUM(account_cost), 0) as total_cost").
 		Where("account_id = ? AND created_at >= ? AND created_at < ?", accountID, startTime, endTime).
 		Scan(&result).Error
 	if err != nil {
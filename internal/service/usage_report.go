@@ -0,0 +1,153 @@
+/*
+ * 文件作用：用量报表导出，供财务按用户/模型/账户对账
+ * 负责功能：
+ *   - 按维度+日期生成 CSV 明细行
+ *   - 每个分组输出小计行，末尾输出总计行
+ *   - 边查边写，大时间范围不一次性加载到内存
+ * 重要程度：⭐⭐⭐ 一般（财务对账）
+ * 依赖模块：repository, model
+ */
+package service
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"go-aiproxy/internal/model"
+)
+
+// usageReportFlushRows 每写多少行刷新一次输出
+const usageReportFlushRows = 500
+
+// usageReportMaxDays 单次导出的最大天数
+const usageReportMaxDays = 366
+
+// usageReportHeader CSV 表头
+var usageReportHeader = []string{
+	"分组ID", "分组名称", "日期", "请求数",
+	"输入Token", "输出Token", "缓存创建Token", "缓存读取Token", "总Token",
+	"输入费用", "输出费用", "缓存创建费用", "缓存读取费用", "总费用",
+}
+
+// ValidateUsageReportFilter 校验导出条件
+func ValidateUsageReportFilter(filter *model.UsageReportFilter) error {
+	switch filter.GroupBy {
+	case model.UsageReportGroupByUser, model.UsageReportGroupByModel, model.UsageReportGroupByAccount:
+	default:
+		return errors.New("group_by must be user, model or account")
+	}
+	start, err := time.ParseInLocation("2006-01-02", filter.StartDate, time.Local)
+	if err != nil {
+		return errors.New("invalid start date, expected YYYY-MM-DD")
+	}
+	end, err := time.ParseInLocation("2006-01-02", filter.EndDate, time.Local)
+	if err != nil {
+		return errors.New("invalid end date, expected YYYY-MM-DD")
+	}
+	if end.Before(start) {
+		return errors.New("end date must not be before start date")
+	}
+	if end.Sub(start) > usageReportMaxDays*24*time.Hour {
+		return errors.New("date range too large, at most 366 days")
+	}
+	return nil
+}
+
+// ExportUsageReport 将用量报表以 CSV 写入 w（带 UTF-8 BOM，Excel 可直接打开）
+// w 实现 Flush() 时（如 gin.ResponseWriter）会定期刷新，边查边发送
+func (s *UsageService) ExportUsageReport(w io.Writer, filter *model.UsageReportFilter) error {
+	names, err := s.dailyUsageRepo.GetReportGroupNames(filter.GroupBy)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	flusher, _ := w.(interface{ Flush() })
+	flush := func() error {
+		cw.Flush()
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return cw.Error()
+	}
+
+	if err := cw.Write(usageReportHeader); err != nil {
+		return err
+	}
+
+	var (
+		current  *model.UsageReportRow // 当前分组（只用于比较分组和命名）
+		subtotal model.UsageReportRow
+		total    model.UsageReportRow
+		written  int
+	)
+	groupID := func(row *model.UsageReportRow) string {
+		if filter.GroupBy == model.UsageReportGroupByModel {
+			return ""
+		}
+		return strconv.FormatUint(uint64(row.GroupID), 10)
+	}
+	groupName := func(row *model.UsageReportRow) string {
+		if filter.GroupBy == model.UsageReportGroupByModel {
+			return row.GroupKey
+		}
+		return names[row.GroupID]
+	}
+	writeSubtotal := func() error {
+		if current == nil {
+			return nil
+		}
+		return cw.Write(usageReportRecord(groupID(current), groupName(current), "小计", &subtotal))
+	}
+
+	err = s.dailyUsageRepo.StreamUsageReport(filter, func(row *model.UsageReportRow) error {
+		if current != nil && (current.GroupID != row.GroupID || current.GroupKey != row.GroupKey) {
+			if err := writeSubtotal(); err != nil {
+				return err
+			}
+			subtotal = model.UsageReportRow{}
+		}
+		current = row
+		subtotal.Add(row)
+		total.Add(row)
+
+		if err := cw.Write(usageReportRecord(groupID(row), groupName(row), row.Date, row)); err != nil {
+			return err
+		}
+		written++
+		if written%usageReportFlushRows == 0 {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := writeSubtotal(); err != nil {
+		return err
+	}
+	if err := cw.Write(usageReportRecord("", "总计", filter.StartDate+"~"+filter.EndDate, &total)); err != nil {
+		return err
+	}
+	return flush()
+}
+
+// usageReportRecord 格式化一行 CSV
+func usageReportRecord(id, name, date string, row *model.UsageReportRow) []string {
+	formatInt := func(v int64) string { return strconv.FormatInt(v, 10) }
+	formatCost := func(v float64) string { return strconv.FormatFloat(v, 'f', 6, 64) }
+	return []string{
+		id, name, date, formatInt(row.RequestCount),
+		formatInt(row.InputTokens), formatInt(row.OutputTokens),
+		formatInt(row.CacheCreationInputTokens), formatInt(row.CacheReadInputTokens), formatInt(row.TotalTokens),
+		formatCost(row.InputCost), formatCost(row.OutputCost),
+		formatCost(row.CacheCreateCost), formatCost(row.CacheReadCost), formatCost(row.TotalCost),
+	}
+}
@@ -6,6 +6,7 @@
  *   - 密码修改
  *   - JWT Token生成
  *   - 费率倍率批量更新
+ *   - 用户所属组织（调整组织时同步其 API Key）
  * 重要程度：⭐⭐⭐⭐ 重要（用户管理核心）
  * 依赖模块：repository, model, utils
  */
@@ -36,15 +37,24 @@ func getUserLog() *logger.Logger {
 }
 
 type UserService struct {
-	repo *repository.UserRepository
+	repo       *repository.UserRepository
+	apiKeyRepo *repository.APIKeyRepository
 }
 
 func NewUserService() *UserService {
 	return &UserService{
-		repo: repository.NewUserRepository(),
+		repo:       repository.NewUserRepository(),
+		apiKeyRepo: repository.NewAPIKeyRepository(),
 	}
 }
 
+// WithOrg 返回只能操作指定组织用户的服务，orgID 为 0 时不限制
+func (s *UserService) WithOrg(orgID uint) *UserService {
+	scoped := *s
+	scoped.repo = s.repo.WithOrg(orgID)
+	return &scoped
+}
+
 type LoginRequest struct {
 	Username  string `json:"username" binding:"required"`
 	Password  string `json:"password" binding:"required"`
@@ -74,6 +84,7 @@ type AdminCreateUserRequest struct {
 	Status         string  `json:"status" binding:"omitempty,oneof=active disabled"`
 	PriceRate      float64 `json:"price_rate"`
 	MaxConcurrency int     `json:"max_concurrency"`
+	OrgID          uint    `json:"org_id"` // 所属组织（仅超级管理员可指定，组织管理员创建的用户归属本组织）
 }
 
 type UpdateUserRequest struct {
@@ -82,6 +93,7 @@ type UpdateUserRequest struct {
 	Role           string   `json:"role" binding:"omitempty,oneof=admin user"`
 	PriceRate      *float64 `json:"price_rate"`      // 使用指针以区分是否传入
 	MaxConcurrency *int     `json:"max_concurrency"` // 使用指针以区分是否传入
+	OrgID          *uint    `json:"org_id"`          // 所属组织（仅超级管理员可修改）
 }
 
 type ChangePasswordRequest struct {
@@ -112,7 +124,7 @@ func (s *UserService) Login(req *LoginRequest) (*LoginResponse, error) {
 		return nil, errors.New("user is disabled")
 	}
 
-	token, err := utils.GenerateToken(user.ID, user.Username, user.Role)
+	token, err := utils.GenerateToken(user.ID, user.Username, user.Role, user.OrgID)
 	if err != nil {
 		getUserLog().Error("[user] 登录失败 | Username: %s | 原因: Token 生成失败: %v", req.Username, err)
 		return nil, err
@@ -199,6 +211,7 @@ func (s *UserService) AdminCreateUser(req *AdminCreateUserRequest) (*model.User,
 		Status:         status,
 		PriceRate:      priceRate,
 		MaxConcurrency: maxConcurrency,
+		OrgID:          req.OrgID,
 	}
 
 	if err := user.SetPassword(req.Password); err != nil {
@@ -240,11 +253,24 @@ func (s *UserService) Update(id uint, req *UpdateUserRequest) (*model.User, erro
 	if req.MaxConcurrency != nil {
 		user.MaxConcurrency = *req.MaxConcurrency
 	}
+	orgChanged := req.OrgID != nil && *req.OrgID != user.OrgID
+	if orgChanged {
+		user.OrgID = *req.OrgID
+	}
 
 	if err := s.repo.Update(user); err != nil {
 		return nil, err
 	}
 
+	// API Key 跟随用户调整组织，之后只调度新组织的账户
+	if orgChanged {
+		if err := s.apiKeyRepo.UpdateOrgByUserID(user.ID, user.OrgID); err != nil {
+			getUserLog().Error("[user] 同步 API Key 组织失败 | UserID: %d | OrgID: %d | 原因: %v", user.ID, user.OrgID, err)
+			return nil, err
+		}
+		getUserLog().Info("[user] 用户调整组织 | UserID: %d | OrgID: %d", user.ID, user.OrgID)
+	}
+
 	return user, nil
 }
 
@@ -0,0 +1,160 @@
+/*
+ * 文件作用：口令加密工具函数，用于导出/导入时保护敏感字段
+ * 负责功能：
+ *   - 使用 scrypt 由口令和随机盐派生密钥进行 AES-GCM 加密（盐写在密文头部）
+ *   - 同一次导出共用一个盐，密钥只派生一次
+ *   - 解密并校验带前缀的密文，兼容解密旧版（无盐 SHA-256 派生）密文
+ * 重要程度：⭐⭐⭐ 一般（账户导入导出）
+ * 依赖模块：crypto, golang.org/x/crypto/scrypt
+ */
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// EncryptedPrefix 加密字段的前缀，完整格式为 enc:v2:<base64(盐)>:<base64(nonce+密文)>
+	EncryptedPrefix = "enc:v2:"
+	// legacyEncryptedPrefix 旧版密文前缀（口令直接 SHA-256 派生密钥），只用于解密
+	legacyEncryptedPrefix = "enc:v1:"
+
+	passphraseSaltSize = 16
+	// scrypt 参数（N=2^15, r=8, p=1），单次派生约几十毫秒，显著提高离线暴力破解成本
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// ErrDecryptFailed 解密失败（口令错误或密文被篡改）
+var ErrDecryptFailed = errors.New("decrypt failed: wrong passphrase or corrupted data")
+
+// IsEncrypted 判断字段是否为 PassphraseCipher 生成的密文（含旧版）
+func IsEncrypted(s string) bool {
+	return strings.HasPrefix(s, EncryptedPrefix) || strings.HasPrefix(s, legacyEncryptedPrefix)
+}
+
+// PassphraseCipher 口令加解密器
+// 加密时整个生命周期共用一个随机盐（一次导出一个盐），解密时按盐缓存派生出的密钥
+type PassphraseCipher struct {
+	passphrase string
+	salt       []byte
+
+	mu   sync.Mutex
+	keys map[string]cipher.AEAD // base64(盐) -> 派生密钥
+}
+
+// NewPassphraseCipher 创建口令加解密器
+func NewPassphraseCipher(passphrase string) (*PassphraseCipher, error) {
+	if passphrase == "" {
+		return nil, errors.New("passphrase is required")
+	}
+	salt := make([]byte, passphraseSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return &PassphraseCipher{
+		passphrase: passphrase,
+		salt:       salt,
+		keys:       make(map[string]cipher.AEAD),
+	}, nil
+}
+
+// Encrypt 加密，返回带前缀和盐的 base64 密文
+func (p *PassphraseCipher) Encrypt(plaintext string) (string, error) {
+	encodedSalt := base64.StdEncoding.EncodeToString(p.salt)
+	gcm, err := p.derive(encodedSalt, p.salt)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return EncryptedPrefix + encodedSalt + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt 解密 Encrypt 生成的密文，也支持旧版 enc:v1 密文
+func (p *PassphraseCipher) Decrypt(ciphertext string) (string, error) {
+	var (
+		gcm     cipher.AEAD
+		encoded string
+		err     error
+	)
+	switch {
+	case strings.HasPrefix(ciphertext, EncryptedPrefix):
+		encodedSalt, rest, ok := strings.Cut(strings.TrimPrefix(ciphertext, EncryptedPrefix), ":")
+		salt, saltErr := base64.StdEncoding.DecodeString(encodedSalt)
+		if !ok || saltErr != nil || len(salt) == 0 {
+			return "", ErrDecryptFailed
+		}
+		encoded = rest
+		gcm, err = p.derive(encodedSalt, salt)
+	case strings.HasPrefix(ciphertext, legacyEncryptedPrefix):
+		encoded = strings.TrimPrefix(ciphertext, legacyEncryptedPrefix)
+		gcm, err = newPassphraseGCM(p.passphrase)
+	default:
+		return "", ErrDecryptFailed
+	}
+	if err != nil {
+		return "", err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(data) < gcm.NonceSize() {
+		return "", ErrDecryptFailed
+	}
+	nonce, sealed := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", ErrDecryptFailed
+	}
+	return string(plaintext), nil
+}
+
+// derive 用 scrypt 由口令和盐派生 AES-256 密钥（按盐缓存）
+func (p *PassphraseCipher) derive(encodedSalt string, salt []byte) (cipher.AEAD, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if gcm, ok := p.keys[encodedSalt]; ok {
+		return gcm, nil
+	}
+	key, err := scrypt.Key([]byte(p.passphrase), salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	p.keys[encodedSalt] = gcm
+	return gcm, nil
+}
+
+// newPassphraseGCM 由高熵密钥直接 SHA-256 派生 AES-256 密钥
+// 只用于配置文件中的字段加密密钥和解密旧版导出密文，人工口令请使用 PassphraseCipher
+func newPassphraseGCM(passphrase string) (cipher.AEAD, error) {
+	if passphrase == "" {
+		return nil, errors.New("passphrase is required")
+	}
+	key := sha256.Sum256([]byte(passphrase))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,153 @@
+/*
+ * 文件作用：字段级加密工具函数，用于数据库中敏感字段的加密存储
+ * 负责功能：
+ *   - 当前密钥 AES-GCM 加密（密文带密钥指纹前缀）
+ *   - 按指纹选择密钥解密，支持轮换前的旧密钥
+ *   - 明文旧数据原样返回，判断是否需要用当前密钥重新加密
+ * 重要程度：⭐⭐⭐ 一般（凭证加密存储）
+ * 依赖模块：crypto
+ */
+package utils
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// FieldEncryptedPrefix 字段密文前缀，完整格式为 fenc:v1:<密钥指纹>:<base64(nonce+密文)>
+const FieldEncryptedPrefix = "fenc:v1:"
+
+var (
+	// ErrFieldKeyNotFound 密文使用的密钥未配置（当前密钥和旧密钥都不匹配）
+	ErrFieldKeyNotFound = errors.New("decrypt field failed: encryption key not configured")
+	// ErrFieldDecryptFailed 密文被篡改或格式错误
+	ErrFieldDecryptFailed = errors.New("decrypt field failed: corrupted data")
+)
+
+type fieldKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+var (
+	fieldKeysMu     sync.RWMutex
+	fieldPrimaryKey *fieldKey
+	fieldKeys       map[string]*fieldKey
+)
+
+// SetFieldKeys 设置字段加密密钥
+// primary 为当前密钥，用于加密和解密，为空时不加密（新数据明文存储）；oldKeys 为轮换前的旧密钥，只用于解密
+func SetFieldKeys(primary string, oldKeys []string) error {
+	keys := make(map[string]*fieldKey)
+	var primaryKey *fieldKey
+	for i, raw := range append([]string{primary}, oldKeys...) {
+		if raw == "" {
+			continue
+		}
+		aead, err := newPassphraseGCM(raw)
+		if err != nil {
+			return err
+		}
+		key := &fieldKey{id: fieldKeyID(raw), aead: aead}
+		if i == 0 {
+			primaryKey = key
+		}
+		if _, exists := keys[key.id]; !exists {
+			keys[key.id] = key
+		}
+	}
+
+	fieldKeysMu.Lock()
+	fieldPrimaryKey = primaryKey
+	fieldKeys = keys
+	fieldKeysMu.Unlock()
+	return nil
+}
+
+// FieldEncryptionEnabled 是否配置了当前密钥
+func FieldEncryptionEnabled() bool {
+	fieldKeysMu.RLock()
+	defer fieldKeysMu.RUnlock()
+	return fieldPrimaryKey != nil
+}
+
+// IsFieldEncrypted 判断字段是否为 EncryptField 生成的密文
+func IsFieldEncrypted(s string) bool {
+	return strings.HasPrefix(s, FieldEncryptedPrefix)
+}
+
+// EncryptField 使用当前密钥加密，空值、已加密的值和未配置密钥时原样返回
+func EncryptField(plaintext string) (string, error) {
+	if plaintext == "" || IsFieldEncrypted(plaintext) {
+		return plaintext, nil
+	}
+	fieldKeysMu.RLock()
+	key := fieldPrimaryKey
+	fieldKeysMu.RUnlock()
+	if key == nil {
+		return plaintext, nil
+	}
+
+	nonce := make([]byte, key.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := key.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return FieldEncryptedPrefix + key.id + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptField 解密 EncryptField 生成的密文，明文（加密前写入的旧数据）原样返回
+func DecryptField(value string) (string, error) {
+	if !IsFieldEncrypted(value) {
+		return value, nil
+	}
+	id, encoded, ok := strings.Cut(strings.TrimPrefix(value, FieldEncryptedPrefix), ":")
+	if !ok {
+		return "", ErrFieldDecryptFailed
+	}
+
+	fieldKeysMu.RLock()
+	key := fieldKeys[id]
+	fieldKeysMu.RUnlock()
+	if key == nil {
+		return "", ErrFieldKeyNotFound
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(data) < key.aead.NonceSize() {
+		return "", ErrFieldDecryptFailed
+	}
+	nonce, sealed := data[:key.aead.NonceSize()], data[key.aead.NonceSize():]
+	plaintext, err := key.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", ErrFieldDecryptFailed
+	}
+	return string(plaintext), nil
+}
+
+// NeedsFieldReencrypt 存储值是否需要用当前密钥重新加密（明文，或由旧密钥加密）
+// 未配置当前密钥时总是返回 false
+func NeedsFieldReencrypt(value string) bool {
+	if value == "" {
+		return false
+	}
+	fieldKeysMu.RLock()
+	key := fieldPrimaryKey
+	fieldKeysMu.RUnlock()
+	if key == nil {
+		return false
+	}
+	return !strings.HasPrefix(value, FieldEncryptedPrefix+key.id+":")
+}
+
+// fieldKeyID 密钥指纹（不可逆，只用于在多个密钥中选择解密密钥）
+func fieldKeyID(raw string) string {
+	sum := sha256.Sum256([]byte("field-key-id:" + raw))
+	return hex.EncodeToString(sum[:4])
+}
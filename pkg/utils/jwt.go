@@ -23,16 +23,18 @@ type Claims struct {
 	UserID   uint   `json:"user_id"`
 	Username string `json:"username"`
 	Role     string `json:"role"`
+	OrgID    uint   `json:"org_id"` // 所属组织（0=不属于任何组织）
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID uint, username, role string) (string, error) {
+func GenerateToken(userID uint, username, role string, orgID uint) (string, error) {
 	expireTime := time.Now().Add(time.Duration(config.Cfg.JWT.ExpireHours) * time.Hour)
 
 	claims := Claims{
 		UserID:   userID,
 		Username: username,
 		Role:     role,
+		OrgID:    orgID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expireTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -61,6 +61,39 @@ const Post = (url, data, config) => alovaInstance.Post(url, data, config).send()
 const Put = (url, data, config) => alovaInstance.Put(url, data, config).send()
 const Delete = (url, config) => alovaInstance.Delete(url, config).send()
 
+// 下载文件（响应不是 JSON，不经过 alova 的响应处理），返回 Blob
+const Download = async (url, params) => {
+  const query = new URLSearchParams(Object.entries(params || {}).filter(([, v]) => v !== undefined && v !== null && v !== ''))
+  const token = localStorage.getItem('token')
+  const response = await fetch(`/api${url}?${query}`, {
+    headers: token ? { Authorization: `Bearer ${token}` } : {}
+  })
+  if (!response.ok) {
+    const data = await response.json().catch(() => null)
+    const msg = data?.message || data?.error || '下载失败'
+    ElMessage.error(msg)
+    throw new Error(msg)
+  }
+  return response.blob()
+}
+
+// 打开实时请求流（SSE 需要带 Authorization 头，EventSource 不支持自定义头，改用 fetch 读取流），返回 Response
+const Stream = async (url, params, signal) => {
+  const query = new URLSearchParams(Object.entries(params || {}).filter(([, v]) => v !== undefined && v !== null && v !== ''))
+  const token = localStorage.getItem('token')
+  const response = await fetch(`/api${url}?${query}`, {
+    headers: token ? { Authorization: `Bearer ${token}` } : {},
+    signal
+  })
+  if (!response.ok) {
+    const data = await response.json().catch(() => null)
+    const msg = data?.message || data?.error || '连接失败'
+    ElMessage.error(msg)
+    throw new Error(msg)
+  }
+  return response
+}
+
 // API 方法封装
 export default {
   // Auth
@@ -88,6 +121,9 @@ export default {
   updateAccount: (id, data) => Put(`/admin/accounts/${id}`, data),
   deleteAccount: (id) => Delete(`/admin/accounts/${id}`),
   updateAccountStatus: (id, data) => Put(`/admin/accounts/${id}/status`, data),
+  setAccountMaintenance: (id, enabled) => Put(`/admin/accounts/${id}/maintenance`, { enabled }),
+  importAccounts: (data) => Post('/admin/accounts/import', data),
+  exportAccounts: (data) => Post('/admin/accounts/export', data),
 
   // Admin - Account Groups
   getAccountGroups: (params) => Get('/admin/account-groups', { params }),
@@ -104,6 +140,9 @@ export default {
   getRequestLogSummary: (params) => Get('/admin/logs/summary', { params }),
   getAccountLoadStats: (params) => Get('/admin/logs/account-load', { params }),
   getAllUsageSummary: (params) => Get('/admin/logs/usage-summary', { params }),
+  getHourlyUsage: (params) => Get('/admin/usage/hourly', { params }),
+  exportUsageReport: (params) => Download('/admin/usage/export', params),
+  openLiveStream: (params, signal) => Stream('/admin/live', params, signal),
 
   // Admin - User Usage Records
   getUserUsageRecords: (userId, params) => Get(`/admin/users/${userId}/usage/records`, { params }),
@@ -147,6 +186,15 @@ export default {
   adminGetAllAPIKeys: (params) => Get('/admin/api-keys', { params }),
   adminLookupAPIKeys: (ids) => Get('/admin/api-keys/lookup', { params: { ids: (ids || []).join(',') } }),
   adminGetAPIKeyLogs: (keyId, params) => Get(`/admin/api-keys/${keyId}/logs`, { params }),
+  adminUpdateAPIKeyAllowedIPs: (keyId, allowedIPs) => Put(`/admin/api-keys/${keyId}/allowed-ips`, { allowed_ips: allowedIPs }),
+  adminUpdateAPIKeyModelFallback: (keyId, data) => Put(`/admin/api-keys/${keyId}/model-fallback`, data),
+  adminUpdateAPIKeyPriority: (keyId, priority) => Put(`/admin/api-keys/${keyId}/priority`, { priority }),
+  adminUpdateAPIKeyForceAccount: (keyId, allow) => Put(`/admin/api-keys/${keyId}/force-account`, { allow_force_account: allow }),
+  adminUpdateAPIKeyMaxOutputTokens: (keyId, limit) => Put(`/admin/api-keys/${keyId}/max-output-tokens`, { max_output_tokens_limit: limit }),
+  adminUpdateAPIKeySystemPrompt: (keyId, data) => Put(`/admin/api-keys/${keyId}/system-prompt`, data),
+  adminUpdateAPIKeyDegradeResponse: (keyId, data) => Put(`/admin/api-keys/${keyId}/degrade-response`, data),
+  adminUpdateAPIKeyCanary: (keyId, data) => Put(`/admin/api-keys/${keyId}/canary`, data),
+  adminGetAPIKeyIPAccess: (keyId) => Get(`/admin/api-keys/${keyId}/ip-access`),
 
   // Admin - User Rate Management
   batchUpdateUserRates: (data) => Post('/admin/users/batch-rate', data),
@@ -166,6 +214,7 @@ export default {
   getCacheStats: () => Get('/admin/cache/stats'),
   getCacheSessions: (params) => Get('/admin/cache/sessions', { params }),
   removeCacheSession: (sessionId) => Delete(`/admin/cache/sessions/${sessionId}`),
+  unbindAccountSessions: (accountIds) => Post('/admin/cache/sessions/unbind', { account_ids: accountIds }), // 按账号批量解绑会话
   getCacheAccounts: () => Get('/admin/cache/accounts'),   // 获取有缓存的账号列表（聚合）
   getCacheUsers: () => Get('/admin/cache/users'),         // 获取有缓存的用户列表（聚合）
   getUnavailableAccounts: () => Get('/admin/cache/unavailable'),
@@ -199,6 +248,12 @@ export default {
   batchUpdatePriceRate: (data) => Post('/admin/users/batch-price-rate', data),
   updateAllPriceRate: (data) => Post('/admin/users/all-price-rate', data),
 
+  // Admin - Organizations (组织管理，仅超级管理员)
+  getOrganizations: () => Get('/admin/organizations'),
+  createOrganization: (data) => Post('/admin/organizations', data),
+  updateOrganization: (id, data) => Put(`/admin/organizations/${id}`, data),
+  deleteOrganization: (id) => Delete(`/admin/organizations/${id}`),
+
   // Admin - Packages (套餐模板管理)
   getPackages: () => Get('/admin/packages'),
   createPackage: (data) => Post('/admin/packages', data),
@@ -257,6 +312,12 @@ export default {
   deleteFilterRule: (id) => Delete(`/admin/client-filter/rules/${id}`),
   toggleFilterRule: (id) => Put(`/admin/client-filter/rules/${id}/toggle`),
 
+  // Admin - Access Rules (访问规则)
+  getAccessRules: () => Get('/admin/client-filter/access-rules'),
+  createAccessRule: (data) => Post('/admin/client-filter/access-rules', data),
+  updateAccessRule: (id, data) => Put(`/admin/client-filter/access-rules/${id}`, data),
+  deleteAccessRule: (id) => Delete(`/admin/client-filter/access-rules/${id}`),
+
   // Admin - System Monitor (系统监控)
   getMonitorData: () => Get('/admin/monitor'),
   getSystemStats: () => Get('/admin/monitor/system'),
@@ -279,6 +340,9 @@ export default {
   checkAccountHealth: (accountId) => Post(`/admin/accounts/${accountId}/health-check`),
   recoverAccount: (accountId) => Post(`/admin/accounts/${accountId}/recover`),
   refreshAccountToken: (accountId) => Post(`/admin/accounts/${accountId}/refresh-token`),
+  getCredentialHealth: () => Get('/admin/accounts/credential-health'),
+  // 一键检测所有账户可能耗时较长，不使用默认 10 秒超时
+  checkAllAccounts: (data) => Post('/admin/accounts/check-all', data, { timeout: 300000 }),
 
   // Admin - Error Messages (错误消息配置)
   getErrorMessages: () => Get('/admin/error-messages'),
@@ -34,6 +34,7 @@ import {
   Monitor,
   More,
   Notebook,
+  OfficeBuilding,
   Plus,
   Position,
   Refresh,
@@ -80,6 +81,7 @@ const icons = {
   Monitor,
   More,
   Notebook,
+  OfficeBuilding,
   Plus,
   Position,
   Refresh,
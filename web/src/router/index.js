@@ -4,6 +4,7 @@
  *   - 页面路由定义
  *   - 权限路由守卫
  *   - 管理员/用户路由分离
+ *   - 全局配置类页面仅超级管理员可访问（组织管理员只管理本组织数据）
  *   - 登录状态检查
  * 重要程度：⭐⭐⭐⭐ 重要（前端路由核心）
  * 依赖模块：vue-router, user store
@@ -11,6 +12,11 @@
 import { createRouter, createWebHistory } from 'vue-router'
 import { useUserStore } from '@/stores/user'
 
+// 管理后台首页：组织管理员没有系统监控权限，进入账户管理
+function adminHome(userStore) {
+  return userStore.isSuperAdmin ? '/admin/system-monitor' : '/admin/accounts'
+}
+
 const routes = [
   {
     path: '/login',
@@ -31,7 +37,7 @@ const routes = [
     redirect: to => {
       const userStore = useUserStore()
       if (userStore.user?.role === 'admin') {
-        return '/admin/system-monitor'
+        return adminHome(userStore)
       }
       return '/user/dashboard'
     }
@@ -44,12 +50,13 @@ const routes = [
     children: [
       {
         path: '',
-        redirect: '/admin/system-monitor'
+        redirect: () => adminHome(useUserStore())
       },
       {
         path: 'system-monitor',
         name: 'SystemMonitor',
-        component: () => import('@/views/SystemMonitor.vue')
+        component: () => import('@/views/SystemMonitor.vue'),
+        meta: { superAdmin: true }
       },
       {
         path: 'accounts',
@@ -59,7 +66,8 @@ const routes = [
       {
         path: 'models',
         name: 'Models',
-        component: () => import('@/views/Models.vue')
+        component: () => import('@/views/Models.vue'),
+        meta: { superAdmin: true }
       },
       {
         path: 'users',
@@ -74,22 +82,32 @@ const routes = [
       {
         path: 'request-logs',
         name: 'RequestLogs',
-        component: () => import('@/views/RequestLogs.vue')
+        component: () => import('@/views/RequestLogs.vue'),
+        meta: { superAdmin: true }
+      },
+      {
+        path: 'live-requests',
+        name: 'LiveRequests',
+        component: () => import('@/views/LiveRequests.vue'),
+        meta: { superAdmin: true }
       },
       {
         path: 'account-load',
         name: 'AccountLoad',
-        component: () => import('@/views/AccountLoad.vue')
+        component: () => import('@/views/AccountLoad.vue'),
+        meta: { superAdmin: true }
       },
       {
         path: 'cache',
         name: 'Cache',
-        component: () => import('@/views/Cache.vue')
+        component: () => import('@/views/Cache.vue'),
+        meta: { superAdmin: true }
       },
       {
         path: 'settings',
         name: 'Settings',
-        component: () => import('@/views/Settings.vue')
+        component: () => import('@/views/Settings.vue'),
+        meta: { superAdmin: true }
       },
       {
         path: 'packages',
@@ -99,27 +117,38 @@ const routes = [
       {
         path: 'proxies',
         name: 'Proxies',
-        component: () => import('@/views/Proxies.vue')
+        component: () => import('@/views/Proxies.vue'),
+        meta: { superAdmin: true }
       },
       {
         path: 'operation-logs',
         name: 'OperationLogs',
-        component: () => import('@/views/OperationLogs.vue')
+        component: () => import('@/views/OperationLogs.vue'),
+        meta: { superAdmin: true }
       },
       {
         path: 'client-filter',
         name: 'ClientFilter',
-        component: () => import('@/views/ClientFilter.vue')
+        component: () => import('@/views/ClientFilter.vue'),
+        meta: { superAdmin: true }
       },
       {
         path: 'error-messages',
         name: 'ErrorMessages',
-        component: () => import('@/views/ErrorMessages.vue')
+        component: () => import('@/views/ErrorMessages.vue'),
+        meta: { superAdmin: true }
+      },
+      {
+        path: 'organizations',
+        name: 'Organizations',
+        component: () => import('@/views/Organizations.vue'),
+        meta: { superAdmin: true }
       },
       {
         path: 'system-logs',
         name: 'SystemLogs',
-        component: () => import('@/views/SystemLogs.vue')
+        component: () => import('@/views/SystemLogs.vue'),
+        meta: { superAdmin: true }
       }
     ]
   },
@@ -217,7 +246,7 @@ router.beforeEach((to, from, next) => {
   // 已登录访问登录页，跳转到后台
   if (to.path === '/login' && userStore.isLoggedIn) {
     if (userStore.user?.role === 'admin') {
-      next('/admin/system-monitor')
+      next(adminHome(userStore))
     } else {
       next('/user/dashboard')
     }
@@ -230,6 +259,12 @@ router.beforeEach((to, from, next) => {
     return
   }
 
+  // 超级管理员页面，组织管理员跳回后台首页
+  if (to.meta.superAdmin && !userStore.isSuperAdmin) {
+    next(adminHome(userStore))
+    return
+  }
+
   next()
 })
 
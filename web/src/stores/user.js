@@ -5,6 +5,7 @@
  *   - Token存储管理
  *   - 用户信息获取
  *   - 登录状态判断
+ *   - 超级管理员判断（不属于任何组织的管理员）
  * 重要程度：⭐⭐⭐⭐ 重要（认证状态核心）
  * 依赖模块：pinia, api
  */
@@ -17,6 +18,8 @@ export const useUserStore = defineStore('user', () => {
   const user = ref(JSON.parse(localStorage.getItem('user') || 'null'))
 
   const isLoggedIn = computed(() => !!token.value)
+  // 超级管理员：不属于任何组织的管理员，可管理组织和全局配置
+  const isSuperAdmin = computed(() => user.value?.role === 'admin' && !user.value?.org_id)
 
   async function login(loginData) {
     const res = await api.login(loginData)
@@ -40,5 +43,5 @@ export const useUserStore = defineStore('user', () => {
     localStorage.setItem('user', JSON.stringify(user.value))
   }
 
-  return { token, user, isLoggedIn, login, logout, fetchProfile }
+  return { token, user, isLoggedIn, isSuperAdmin, login, logout, fetchProfile }
 })